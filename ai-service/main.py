@@ -1,11 +1,14 @@
-from fastapi import FastAPI, HTTPException, BackgroundTasks
+from fastapi import FastAPI, HTTPException, BackgroundTasks, Request
 from fastapi.middleware.cors import CORSMiddleware
+from fastapi.responses import JSONResponse
+from jose import jwt, JWTError
 from pydantic import BaseModel
 from typing import Optional, Dict, Any
 import asyncio
 import logging
 import os
-from datetime import datetime
+import secrets
+from datetime import datetime, timedelta
 
 # Configure logging
 logging.basicConfig(level=logging.INFO)
@@ -26,6 +29,50 @@ app.add_middleware(
     allow_headers=["*"],
 )
 
+# Service-to-service auth. The Go backend signs a short-lived HS256 token
+# with the shared SVC_AUTH_SECRET and sends it in X-Service-Token.
+SVC_AUTH_HEADER = "X-Service-Token"
+SVC_AUDIENCE = "pygorp-python"
+BACKEND_AUDIENCE = "pygorp-backend"
+
+def _svc_secrets():
+    return [s for s in (os.getenv("SVC_AUTH_SECRET"), os.getenv("SVC_AUTH_PREVIOUS_SECRET")) if s]
+
+def issue_service_token(subject: Optional[str] = None) -> str:
+    """Create a token for calling the Go backend's /internal API"""
+    now = datetime.utcnow()
+    claims = {
+        "iss": SVC_AUDIENCE,
+        "aud": BACKEND_AUDIENCE,
+        "iat": int(now.timestamp()),
+        "exp": int((now + timedelta(minutes=1)).timestamp()),
+        "jti": secrets.token_hex(8),
+    }
+    if subject:
+        claims["sub"] = subject
+    return jwt.encode(claims, os.environ["SVC_AUTH_SECRET"], algorithm="HS256")
+
+@app.middleware("http")
+async def verify_service_token(request: Request, call_next):
+    """Only accept API calls from the backend when SVC_AUTH_SECRET is set"""
+    keys = _svc_secrets()
+    if not keys or not request.url.path.startswith("/api/"):
+        return await call_next(request)
+
+    token = request.headers.get(SVC_AUTH_HEADER, "")
+    for key in keys:
+        try:
+            claims = jwt.decode(token, key, algorithms=["HS256"], audience=SVC_AUDIENCE, options={"leeway": 30})
+            break
+        except JWTError:
+            continue
+    else:
+        return JSONResponse(status_code=401, content={"detail": "Invalid service token"})
+
+    request.state.service = claims.get("iss")
+    request.state.subject = claims.get("sub")
+    return await call_next(request)
+
 # Pydantic models
 class TextAnalysisRequest(BaseModel):
     text: str
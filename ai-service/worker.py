@@ -0,0 +1,98 @@
+"""Task worker for compute offloaded by the Go backend.
+
+The backend pushes task requests onto a Redis list and waits for replies on
+a per-task list. The message formats are defined in
+backend/internal/tasks/contract.go; keep the two in sync.
+"""
+import asyncio
+import json
+import logging
+import os
+from datetime import datetime, timezone
+
+import redis.asyncio as redis
+from fastapi import BackgroundTasks
+
+from main import (
+    ImageAnalysisRequest,
+    MLModelRequest,
+    TextAnalysisRequest,
+    analyze_image,
+    analyze_text,
+    ml_prediction,
+)
+
+logging.basicConfig(level=logging.INFO)
+logger = logging.getLogger("worker")
+
+PROTOCOL_VERSION = 1
+QUEUE_KEY = "pygorp:tasks"
+REPLY_TTL_SECONDS = 3600
+
+
+async def run_analyze_text(data):
+    return await analyze_text(TextAnalysisRequest(**data), BackgroundTasks())
+
+async def run_analyze_image(data):
+    return await analyze_image(ImageAnalysisRequest(**data))
+
+async def run_ml_predict(data):
+    return await ml_prediction(MLModelRequest(**data))
+
+HANDLERS = {
+    "analyze.text": run_analyze_text,
+    "analyze.image": run_analyze_image,
+    "ml.predict": run_ml_predict,
+}
+
+
+async def reply(client, key, message):
+    await client.lpush(key, json.dumps(message))
+    await client.expire(key, REPLY_TTL_SECONDS)
+
+async def handle(client, raw):
+    request = json.loads(raw)
+    reply_to = request["reply_to"]
+
+    if request.get("version") != PROTOCOL_VERSION:
+        await reply(client, reply_to, {"type": "error", "error": f"unsupported protocol version {request.get('version')}"})
+        return
+
+    deadline = datetime.fromisoformat(request["deadline"].replace("Z", "+00:00"))
+    if deadline <= datetime.now(timezone.utc):
+        logger.info(f"Skipping expired task {request['id']}")
+        return
+
+    handler = HANDLERS.get(request["kind"])
+    if handler is None:
+        await reply(client, reply_to, {"type": "error", "error": f"unknown task kind {request['kind']}"})
+        return
+
+    logger.info(f"Running task {request['id']} ({request['kind']})")
+    await reply(client, reply_to, {"type": "progress", "progress": 10})
+    try:
+        response = await handler(request["input"])
+    except Exception as e:
+        logger.error(f"Task {request['id']} failed: {str(e)}")
+        await reply(client, reply_to, {"type": "error", "error": str(e)})
+        return
+
+    if response.status != "completed":
+        await reply(client, reply_to, {"type": "error", "error": response.error or "task failed"})
+        return
+    await reply(client, reply_to, {"type": "result", "result": response.model_dump()})
+
+async def main():
+    client = redis.from_url(os.getenv("REDIS_URL", "redis://localhost:6379"), decode_responses=True)
+    logger.info(f"Waiting for tasks on {QUEUE_KEY}")
+    while True:
+        item = await client.brpop(QUEUE_KEY, timeout=5)
+        if item is None:
+            continue
+        try:
+            await handle(client, item[1])
+        except Exception as e:
+            logger.error(f"Invalid task message: {str(e)}")
+
+if __name__ == "__main__":
+    asyncio.run(main())
@@ -0,0 +1,313 @@
+// Package client is a typed Go client for the pygorp API. It signs requests
+// with a personal access token, service account API key, or session access
+// token, retries requests the server turned away, and pages through lists
+// with iterators:
+//
+//	c := client.New("https://pygorp.example.com", client.WithToken(os.Getenv("PYGORP_TOKEN")))
+//	it := c.Users.List(client.UserListOptions{EmailDomain: "example.com"})
+//	for it.Next(ctx) {
+//		fmt.Println(it.Value().Email)
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+//
+// Request and response types are the server's own models, and every method
+// calls an endpoint listed in Routes, which `pygorp routes --check-client`
+// compares with the server's route table, so the client cannot drift from
+// the API it ships with.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"pygorp/backend/internal/version"
+)
+
+// Defaults for new clients.
+const (
+	DefaultMaxRetries = 3
+	DefaultPageSize   = 50
+)
+
+// Client calls one pygorp instance. It is safe for concurrent use.
+type Client struct {
+	baseURL    string
+	token      string
+	userAgent  string
+	httpClient *http.Client
+	maxRetries int
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	pageSize   int
+
+	Users  *UsersService
+	Groups *GroupsService
+	Tokens *TokensService
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithToken sends token as the bearer token of every request. It can be a
+// personal access token (pgpat_...), a service account API key (pgsa_...),
+// or a session access token.
+func WithToken(token string) Option {
+	return func(c *Client) { c.token = token }
+}
+
+// WithHTTPClient sends requests with h instead of a client with a 30 second
+// timeout.
+func WithHTTPClient(h *http.Client) Option {
+	return func(c *Client) { c.httpClient = h }
+}
+
+// WithRetries sets how many times a failed request is retried, and the
+// bounds of the exponential backoff between attempts. A Retry-After header
+// from the server takes precedence over the backoff.
+func WithRetries(max int, minBackoff, maxBackoff time.Duration) Option {
+	return func(c *Client) {
+		c.maxRetries, c.minBackoff, c.maxBackoff = max, minBackoff, maxBackoff
+	}
+}
+
+// WithPageSize sets how many items list iterators fetch per request.
+func WithPageSize(n int) Option {
+	return func(c *Client) { c.pageSize = n }
+}
+
+// WithUserAgent replaces the User-Agent header.
+func WithUserAgent(ua string) Option {
+	return func(c *Client) { c.userAgent = ua }
+}
+
+// New returns a client for the instance at baseURL, such as
+// "https://pygorp.example.com".
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		userAgent:  "pygorp-go/" + version.Version,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		maxRetries: DefaultMaxRetries,
+		minBackoff: 500 * time.Millisecond,
+		maxBackoff: 10 * time.Second,
+		pageSize:   DefaultPageSize,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.Users = &UsersService{c}
+	c.Groups = &GroupsService{c}
+	c.Tokens = &TokensService{c}
+	return c
+}
+
+// APIError is an error response from the server, read from RFC 7807
+// problem details or the legacy {"error": ...} shape.
+type APIError struct {
+	StatusCode int
+	Message    string
+	// Code names the kind of error, such as "not_found" or
+	// "limit_too_large", and Type is its problem type URI.
+	Code      string
+	Type      string
+	RequestID string
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("pygorp: %d %s", e.StatusCode, e.Message)
+	if e.RequestID != "" {
+		msg += " (request " + e.RequestID + ")"
+	}
+	return msg
+}
+
+// IsNotFound reports whether err is a 404 from the server.
+func IsNotFound(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// envelope is the body of successful responses.
+type envelope struct {
+	Data    json.RawMessage `json:"data"`
+	Message string          `json:"message"`
+}
+
+// call sends a request to the endpoint named route, filling its path
+// parameters in order with params, and decodes the response's data into
+// out unless out is nil.
+func (c *Client) call(ctx context.Context, route string, params []string, query url.Values, body, out interface{}) error {
+	r, ok := Routes[route]
+	if !ok {
+		return fmt.Errorf("pygorp: unknown route %s", route)
+	}
+	path, err := expand(r.Path, params)
+	if err != nil {
+		return err
+	}
+	u := c.baseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	var payload []byte
+	if body != nil {
+		if payload, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		resp, err := c.send(ctx, r.Method, u, payload)
+		if err == nil && resp.StatusCode < 300 {
+			defer resp.Body.Close()
+			return decode(resp, out)
+		}
+
+		var wait time.Duration
+		var failure error
+		if err != nil {
+			failure = err
+			wait = c.backoff(attempt)
+		} else {
+			failure = apiError(resp)
+			wait = c.retryAfter(resp, attempt)
+		}
+		if attempt >= c.maxRetries || !retryable(r.Method, resp, err) || ctx.Err() != nil {
+			return failure
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return failure
+		case <-timer.C:
+		}
+	}
+}
+
+func (c *Client) send(ctx context.Context, method, u string, payload []byte) (*http.Response, error) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", c.userAgent)
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	return c.httpClient.Do(req)
+}
+
+// retryable reports whether a failed request may be sent again. Rate
+// limited, shed, and read-only responses (429 and 503) were turned away
+// before doing anything, so every method is retried on them; other gateway
+// errors and network failures only for idempotent methods.
+func retryable(method string, resp *http.Response, err error) bool {
+	idempotent := method == http.MethodGet || method == http.MethodHead || method == http.MethodPut || method == http.MethodDelete
+	if err != nil {
+		return idempotent && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return true
+	case http.StatusBadGateway, http.StatusGatewayTimeout:
+		return idempotent
+	}
+	return false
+}
+
+// backoff doubles from minBackoff up to maxBackoff, with jitter so clients
+// retrying together spread out.
+func (c *Client) backoff(attempt int) time.Duration {
+	d := c.minBackoff << attempt
+	if d <= 0 || d > c.maxBackoff {
+		d = c.maxBackoff
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// retryAfter honors the server's Retry-After in seconds, up to maxBackoff.
+func (c *Client) retryAfter(resp *http.Response, attempt int) time.Duration {
+	if s, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && s >= 0 {
+		return min(time.Duration(s)*time.Second, c.maxBackoff)
+	}
+	return c.backoff(attempt)
+}
+
+func decode(resp *http.Response, out interface{}) error {
+	if out == nil {
+		_, err := io.Copy(io.Discard, resp.Body)
+		return err
+	}
+	var env envelope
+	if err := json.NewDecoder(resp.Body).Decode(&env); err != nil {
+		return fmt.Errorf("pygorp: invalid response: %v", err)
+	}
+	return json.Unmarshal(env.Data, out)
+}
+
+// apiError reads an error response and closes its body.
+func apiError(resp *http.Response) error {
+	defer resp.Body.Close()
+	var body struct {
+		Detail    string `json:"detail"`
+		Error     string `json:"error"`
+		Code      string `json:"code"`
+		Type      string `json:"type"`
+		RequestID string `json:"request_id"`
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	json.Unmarshal(data, &body)
+	e := &APIError{StatusCode: resp.StatusCode, Message: body.Detail, Code: body.Code, Type: body.Type, RequestID: body.RequestID}
+	if e.Message == "" {
+		e.Message = body.Error
+	}
+	if e.Message == "" {
+		e.Message = http.StatusText(resp.StatusCode)
+	}
+	if e.RequestID == "" {
+		e.RequestID = resp.Header.Get("X-Request-ID")
+	}
+	return e
+}
+
+// expand fills the :name segments of path with params in order.
+func expand(path string, params []string) (string, error) {
+	segments := strings.Split(path, "/")
+	n := 0
+	for i, s := range segments {
+		if !strings.HasPrefix(s, ":") {
+			continue
+		}
+		if n >= len(params) {
+			return "", fmt.Errorf("pygorp: missing %s for %s", s, path)
+		}
+		segments[i] = url.PathEscape(params[n])
+		n++
+	}
+	if n != len(params) {
+		return "", fmt.Errorf("pygorp: too many parameters for %s", path)
+	}
+	return strings.Join(segments, "/"), nil
+}
@@ -0,0 +1,63 @@
+package client
+
+import (
+	"context"
+)
+
+// GroupsService calls the group endpoints.
+type GroupsService struct{ c *Client }
+
+// List pages through all groups.
+func (s *GroupsService) List() *Iterator[Group] {
+	return newIterator(s.c.pageSize, func(ctx context.Context, limit, offset int) ([]Group, error) {
+		var groups []Group
+		err := s.c.call(ctx, "groups.list", nil, page(nil, limit, offset), nil, &groups)
+		return groups, err
+	})
+}
+
+func (s *GroupsService) Get(ctx context.Context, id int) (Group, error) {
+	var group Group
+	err := s.c.call(ctx, "groups.get", ids(id), nil, nil, &group)
+	return group, err
+}
+
+func (s *GroupsService) Create(ctx context.Context, req CreateGroupRequest) (Group, error) {
+	var group Group
+	err := s.c.call(ctx, "groups.create", nil, nil, req, &group)
+	return group, err
+}
+
+// Update changes the fields set in req.
+func (s *GroupsService) Update(ctx context.Context, id int, req UpdateGroupRequest) (Group, error) {
+	var group Group
+	err := s.c.call(ctx, "groups.update", ids(id), nil, req, &group)
+	return group, err
+}
+
+func (s *GroupsService) Delete(ctx context.Context, id int) error {
+	return s.c.call(ctx, "groups.delete", ids(id), nil, nil, nil)
+}
+
+// Members pages through a group's members.
+func (s *GroupsService) Members(id int) *Iterator[GroupMember] {
+	return newIterator(s.c.pageSize, func(ctx context.Context, limit, offset int) ([]GroupMember, error) {
+		var members []GroupMember
+		err := s.c.call(ctx, "groups.members.list", ids(id), page(nil, limit, offset), nil, &members)
+		return members, err
+	})
+}
+
+// AddMembers adds users to a group and returns the IDs that were newly
+// added.
+func (s *GroupsService) AddMembers(ctx context.Context, id int, userIDs ...int) ([]int, error) {
+	var result struct {
+		Added []int `json:"added"`
+	}
+	err := s.c.call(ctx, "groups.members.add", ids(id), nil, map[string][]int{"user_ids": userIDs}, &result)
+	return result.Added, err
+}
+
+func (s *GroupsService) RemoveMember(ctx context.Context, id, userID int) error {
+	return s.c.call(ctx, "groups.members.remove", ids(id, userID), nil, nil, nil)
+}
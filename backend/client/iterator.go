@@ -0,0 +1,74 @@
+package client
+
+import (
+	"context"
+)
+
+// Iterator pages through a list endpoint, fetching the next page when the
+// current one runs out:
+//
+//	for it.Next(ctx) {
+//		use(it.Value())
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+type Iterator[T any] struct {
+	fetch    func(ctx context.Context, limit, offset int) ([]T, error)
+	pageSize int
+	page     []T
+	offset   int
+	done     bool
+	cur      T
+	err      error
+}
+
+func newIterator[T any](pageSize int, fetch func(ctx context.Context, limit, offset int) ([]T, error)) *Iterator[T] {
+	return &Iterator[T]{fetch: fetch, pageSize: pageSize}
+}
+
+// Next advances to the next item and reports whether there is one. It
+// returns false at the end of the list or on an error, which Err returns.
+func (it *Iterator[T]) Next(ctx context.Context) bool {
+	if it.err != nil {
+		return false
+	}
+	if len(it.page) == 0 {
+		if it.done {
+			return false
+		}
+		page, err := it.fetch(ctx, it.pageSize, it.offset)
+		if err != nil {
+			it.err = err
+			return false
+		}
+		// A short page is the last one.
+		it.done = len(page) < it.pageSize
+		it.offset += len(page)
+		it.page = page
+		if len(page) == 0 {
+			return false
+		}
+	}
+	it.cur, it.page = it.page[0], it.page[1:]
+	return true
+}
+
+// Value returns the current item.
+func (it *Iterator[T]) Value() T {
+	return it.cur
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (it *Iterator[T]) Err() error {
+	return it.err
+}
+
+// All collects the remaining items.
+func (it *Iterator[T]) All(ctx context.Context) ([]T, error) {
+	items := []T{}
+	for it.Next(ctx) {
+		items = append(items, it.Value())
+	}
+	return items, it.Err()
+}
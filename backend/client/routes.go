@@ -0,0 +1,30 @@
+package client
+
+// Route is an endpoint the client calls.
+type Route struct {
+	Method string
+	Path   string
+}
+
+// Routes are the endpoints the client calls, by route name in the server's
+// route table.
+var Routes = map[string]Route{
+	"users.list":            {"GET", "/api/v1/users"},
+	"users.get":             {"GET", "/api/v1/users/:id"},
+	"users.create":          {"POST", "/api/v1/users"},
+	"users.update":          {"PUT", "/api/v1/users/:id"},
+	"users.delete":          {"DELETE", "/api/v1/users/:id"},
+	"users.deletion.cancel": {"DELETE", "/api/v1/users/:id/deletion"},
+	"users.groups":          {"GET", "/api/v1/users/:id/groups"},
+	"groups.list":           {"GET", "/api/v1/groups"},
+	"groups.get":            {"GET", "/api/v1/groups/:id"},
+	"groups.create":         {"POST", "/api/v1/groups"},
+	"groups.update":         {"PUT", "/api/v1/groups/:id"},
+	"groups.delete":         {"DELETE", "/api/v1/groups/:id"},
+	"groups.members.list":   {"GET", "/api/v1/groups/:id/members"},
+	"groups.members.add":    {"POST", "/api/v1/groups/:id/members"},
+	"groups.members.remove": {"DELETE", "/api/v1/groups/:id/members/:user_id"},
+	"me.tokens.list":        {"GET", "/api/v1/me/tokens"},
+	"me.tokens.create":      {"POST", "/api/v1/me/tokens"},
+	"me.tokens.revoke":      {"DELETE", "/api/v1/me/tokens/:id"},
+}
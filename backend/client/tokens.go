@@ -0,0 +1,27 @@
+package client
+
+import (
+	"context"
+)
+
+// TokensService manages the caller's personal access tokens.
+type TokensService struct{ c *Client }
+
+// List returns the caller's tokens, without their secrets.
+func (s *TokensService) List(ctx context.Context) ([]PersonalAccessToken, error) {
+	var tokens []PersonalAccessToken
+	err := s.c.call(ctx, "me.tokens.list", nil, nil, nil, &tokens)
+	return tokens, err
+}
+
+// Create mints a token. Its secret is only returned here. The client must
+// be signed in with a session access token.
+func (s *TokensService) Create(ctx context.Context, req CreatePersonalAccessTokenRequest) (CreatedPersonalAccessToken, error) {
+	var token CreatedPersonalAccessToken
+	err := s.c.call(ctx, "me.tokens.create", nil, nil, req, &token)
+	return token, err
+}
+
+func (s *TokensService) Revoke(ctx context.Context, id int) error {
+	return s.c.call(ctx, "me.tokens.revoke", ids(id), nil, nil, nil)
+}
@@ -0,0 +1,20 @@
+package client
+
+import (
+	"pygorp/backend/internal/models"
+)
+
+// Resources and request bodies, shared with the server so both always
+// agree on the wire format.
+type (
+	User                             = models.User
+	CreateUserRequest                = models.CreateUserRequest
+	UpdateUserRequest                = models.UpdateUserRequest
+	Group                            = models.Group
+	CreateGroupRequest               = models.CreateGroupRequest
+	UpdateGroupRequest               = models.UpdateGroupRequest
+	GroupMember                      = models.GroupMember
+	PersonalAccessToken              = models.PersonalAccessToken
+	CreatePersonalAccessTokenRequest = models.CreatePersonalAccessTokenRequest
+	CreatedPersonalAccessToken       = models.CreatedPersonalAccessToken
+)
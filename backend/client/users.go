@@ -0,0 +1,117 @@
+package client
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// UsersService calls the user endpoints.
+type UsersService struct{ c *Client }
+
+// UserListOptions filters and sorts a user list. Empty fields are ignored.
+type UserListOptions struct {
+	EmailDomain  string
+	NameContains string
+	GroupID      int
+	TeamID       int
+	// Attributes match indexed custom attributes exactly.
+	Attributes map[string]string
+	// Sort fields, such as "name" or "-created_at" for descending.
+	Sort []string
+}
+
+func (o UserListOptions) query() url.Values {
+	q := url.Values{}
+	if o.EmailDomain != "" {
+		q.Set("email_domain", o.EmailDomain)
+	}
+	if o.NameContains != "" {
+		q.Set("name_contains", o.NameContains)
+	}
+	if o.GroupID != 0 {
+		q.Set("group_id", strconv.Itoa(o.GroupID))
+	}
+	if o.TeamID != 0 {
+		q.Set("team_id", strconv.Itoa(o.TeamID))
+	}
+	for name, value := range o.Attributes {
+		q.Set("attr."+name, value)
+	}
+	if len(o.Sort) > 0 {
+		q.Set("sort", strings.Join(o.Sort, ","))
+	}
+	return q
+}
+
+// List pages through the users matching opts.
+func (s *UsersService) List(opts UserListOptions) *Iterator[User] {
+	return newIterator(s.c.pageSize, func(ctx context.Context, limit, offset int) ([]User, error) {
+		var users []User
+		err := s.c.call(ctx, "users.list", nil, page(opts.query(), limit, offset), nil, &users)
+		return users, err
+	})
+}
+
+func (s *UsersService) Get(ctx context.Context, id int) (User, error) {
+	var user User
+	err := s.c.call(ctx, "users.get", ids(id), nil, nil, &user)
+	return user, err
+}
+
+func (s *UsersService) Create(ctx context.Context, req CreateUserRequest) (User, error) {
+	var user User
+	err := s.c.call(ctx, "users.create", nil, nil, req, &user)
+	return user, err
+}
+
+// Update changes the fields set in req; attributes are merged.
+func (s *UsersService) Update(ctx context.Context, id int, req UpdateUserRequest) (User, error) {
+	var user User
+	err := s.c.call(ctx, "users.update", ids(id), nil, req, &user)
+	return user, err
+}
+
+// Delete schedules a user for deletion. The returned user carries when it
+// will be purged.
+func (s *UsersService) Delete(ctx context.Context, id int) (User, error) {
+	var user User
+	err := s.c.call(ctx, "users.delete", ids(id), nil, nil, &user)
+	return user, err
+}
+
+// CancelDeletion restores a user pending deletion.
+func (s *UsersService) CancelDeletion(ctx context.Context, id int) (User, error) {
+	var user User
+	err := s.c.call(ctx, "users.deletion.cancel", ids(id), nil, nil, &user)
+	return user, err
+}
+
+// Groups pages through the groups a user is in.
+func (s *UsersService) Groups(id int) *Iterator[Group] {
+	return newIterator(s.c.pageSize, func(ctx context.Context, limit, offset int) ([]Group, error) {
+		var groups []Group
+		err := s.c.call(ctx, "users.groups", ids(id), page(nil, limit, offset), nil, &groups)
+		return groups, err
+	})
+}
+
+// ids formats path parameters.
+func ids(values ...int) []string {
+	params := make([]string, len(values))
+	for i, v := range values {
+		params[i] = strconv.Itoa(v)
+	}
+	return params
+}
+
+// page adds paging parameters to q.
+func page(q url.Values, limit, offset int) url.Values {
+	if q == nil {
+		q = url.Values{}
+	}
+	q.Set("limit", strconv.Itoa(limit))
+	q.Set("offset", strconv.Itoa(offset))
+	return q
+}
@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"pygorp/backend/internal/bootstrap"
+	"pygorp/backend/internal/database"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	applyFile   string
+	applyDryRun bool
+)
+
+var applyCmd = &cobra.Command{
+	Use:   "apply",
+	Short: "Reconcile the database with a bootstrap file",
+	Long: `Reconcile the database with a bootstrap file declaring orgs, roles, users,
+service accounts with API keys, and feature flags.
+
+Everything is applied in one transaction. Orgs, roles, role policies,
+service accounts, and API keys created by apply are removed again when they
+are dropped from the file; users are only created and updated. Use
+--dry-run to print the changes without making them.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		spec, err := bootstrap.Load(applyFile)
+		if err != nil {
+			return err
+		}
+
+		if err := database.InitDB(); err != nil {
+			return fmt.Errorf("failed to connect to database: %v", err)
+		}
+		defer database.CloseDB()
+
+		result, err := bootstrap.Apply(context.Background(), spec, applyDryRun)
+		if err != nil {
+			return err
+		}
+
+		for _, change := range result.Changes {
+			fmt.Println(change)
+		}
+		switch {
+		case len(result.Changes) == 0:
+			fmt.Println("No changes")
+		case applyDryRun:
+			fmt.Printf("Dry run: %d change(s) not applied\n", len(result.Changes))
+		default:
+			fmt.Printf("Applied %d change(s)\n", len(result.Changes))
+		}
+
+		if len(result.GeneratedKeys) > 0 && !applyDryRun {
+			names := make([]string, 0, len(result.GeneratedKeys))
+			for name := range result.GeneratedKeys {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			fmt.Println("\nGenerated API keys (shown only once):")
+			for _, name := range names {
+				fmt.Printf("  %s: %s\n", name, result.GeneratedKeys[name])
+			}
+		}
+		return nil
+	},
+}
+
+func init() {
+	applyCmd.Flags().StringVarP(&applyFile, "file", "f", "bootstrap.yaml", "bootstrap file to apply")
+	applyCmd.Flags().BoolVar(&applyDryRun, "dry-run", false, "print the changes without applying them")
+	rootCmd.AddCommand(applyCmd)
+}
@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"pygorp/backend/internal/backup"
+
+	"github.com/spf13/cobra"
+)
+
+var backupCmd = &cobra.Command{
+	Use:   "backup",
+	Short: "Export the database with pg_dump and apply the retention policy",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := backup.Store()
+		if err != nil {
+			return err
+		}
+		retention, err := backup.RetentionFromEnv()
+		if err != nil {
+			return err
+		}
+
+		created, err := backup.Create(cmd.Context(), store)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Created %s (%d bytes)\n", created.Key, created.Size)
+
+		pruned, err := backup.Prune(cmd.Context(), store, retention)
+		for _, key := range pruned {
+			fmt.Printf("Pruned %s\n", key)
+		}
+		return err
+	},
+}
+
+var backupListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List stored backups, newest first",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := backup.Store()
+		if err != nil {
+			return err
+		}
+		backups, err := backup.List(cmd.Context(), store)
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "KEY\tSIZE\tCREATED")
+		for _, b := range backups {
+			fmt.Fprintf(w, "%s\t%d\t%s\n", b.Key, b.Size, b.LastModified.Format("2006-01-02 15:04:05"))
+		}
+		return w.Flush()
+	},
+}
+
+var restoreYes bool
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore KEY",
+	Short: "Replace the database contents with a backup",
+	Long: `Restore a backup taken with "pygorp backup". All tables except the
+job queue are dropped and recreated from the backup.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if !restoreYes {
+			return fmt.Errorf("restore replaces the current data; rerun with --yes to confirm")
+		}
+		store, err := backup.Store()
+		if err != nil {
+			return err
+		}
+		if err := backup.Restore(cmd.Context(), store, args[0]); err != nil {
+			return err
+		}
+		fmt.Printf("Restored %s\n", args[0])
+		return nil
+	},
+}
+
+func init() {
+	restoreCmd.Flags().BoolVar(&restoreYes, "yes", false, "confirm replacing the current data")
+	backupCmd.AddCommand(backupListCmd)
+	rootCmd.AddCommand(backupCmd, restoreCmd)
+}
@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"pygorp/backend/internal/bench"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	benchURL         string
+	benchToken       string
+	benchRate        int
+	benchConcurrency int
+	benchDuration    time.Duration
+	benchJSON        bool
+)
+
+var benchCmd = &cobra.Command{
+	Use:   "bench <scenario>",
+	Short: "Load a running instance with a canned scenario",
+	Long: "Scenarios send a weighted mix of API requests and report latency percentiles per request kind:\n" +
+		scenarioList() + "\n\n" +
+		"Benchmarks of the repository layer are Go benchmarks: go test ./internal/repository -bench .",
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+
+		sc, ok := bench.Scenarios[args[0]]
+		if !ok {
+			return fmt.Errorf("unknown scenario %q (want %s)", args[0], strings.Join(scenarioNames(), ", "))
+		}
+
+		fmt.Fprintf(os.Stderr, "Running %s against %s for %s\n", sc.Name, benchURL, benchDuration)
+		report, err := bench.Run(ctx, sc, bench.Options{
+			BaseURL:     benchURL,
+			Token:       benchToken,
+			Rate:        benchRate,
+			Concurrency: benchConcurrency,
+			Duration:    benchDuration,
+		})
+		if err != nil {
+			return err
+		}
+		if benchJSON {
+			return printJSON(report)
+		}
+
+		fmt.Printf("%d requests in %s (%.1f/s), %d errors\n\n",
+			report.Requests, report.Duration.Round(time.Millisecond), report.Throughput, report.Errors)
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "STEP\tREQUESTS\tERRORS\tP50\tP90\tP99\tMAX\tSTATUSES")
+		for _, s := range append(report.Steps, report.Total) {
+			fmt.Fprintf(w, "%s\t%d\t%d\t%s\t%s\t%s\t%s\t%s\n", s.Name, s.Requests, s.Errors,
+				ms(s.P50), ms(s.P90), ms(s.P99), ms(s.Max), statuses(s.Statuses))
+		}
+		return w.Flush()
+	},
+}
+
+func printJSON(v interface{}) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func ms(d time.Duration) string {
+	return fmt.Sprintf("%.1fms", float64(d)/float64(time.Millisecond))
+}
+
+func statuses(counts map[string]int) string {
+	var parts []string
+	for status, n := range counts {
+		parts = append(parts, fmt.Sprintf("%s=%d", status, n))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, " ")
+}
+
+func scenarioNames() []string {
+	var names []string
+	for name := range bench.Scenarios {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func scenarioList() string {
+	var lines []string
+	for _, name := range scenarioNames() {
+		lines = append(lines, fmt.Sprintf("  %-8s %s", name, bench.Scenarios[name].Description))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func init() {
+	benchCmd.Flags().StringVar(&benchURL, "url", "http://localhost:8080", "base URL of the instance to load")
+	benchCmd.Flags().StringVar(&benchToken, "token", os.Getenv("BENCH_TOKEN"), "bearer token with users:read and users:write (default $BENCH_TOKEN)")
+	benchCmd.Flags().IntVar(&benchRate, "rate", 0, "target requests per second (0 sends as fast as the workers allow)")
+	benchCmd.Flags().IntVar(&benchConcurrency, "concurrency", 10, "concurrent workers")
+	benchCmd.Flags().DurationVar(&benchDuration, "duration", 30*time.Second, "how long to send load")
+	benchCmd.Flags().BoolVar(&benchJSON, "json", false, "print the report as JSON")
+	rootCmd.AddCommand(benchCmd)
+}
@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"pygorp/backend/internal/bootstrap"
+	"pygorp/backend/internal/config"
+	"pygorp/backend/internal/database"
+	"pygorp/backend/internal/scanner"
+	"pygorp/backend/internal/selfcheck"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	checkJSON  bool
+	skipChecks bool
+)
+
+var checkCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Run the startup self-checks and print a report",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		defer database.CloseDB()
+
+		report := runChecks()
+		if checkJSON {
+			if err := report.PrintJSON(os.Stdout); err != nil {
+				return err
+			}
+		} else {
+			report.Print(os.Stdout)
+		}
+		return report.Err()
+	},
+}
+
+// runChecks runs the startup self-checks. They also connect database.DB.
+func runChecks() *selfcheck.Report {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	return selfcheck.Run(ctx, selfcheck.Default())
+}
+
+// startup connects to the database, running the self-checks first unless
+// --skip-checks is set. The report goes to stderr and any critical failure
+// stops startup. Once connected, feature flags stored by `pygorp apply` are
+// added to the runtime config. An unknown SCANNER also stops startup.
+func startup() error {
+	if skipChecks {
+		if err := database.InitDB(); err != nil {
+			return fmt.Errorf("failed to connect to database: %v", err)
+		}
+	} else {
+		report := runChecks()
+		report.Print(os.Stderr)
+		if err := report.Err(); err != nil {
+			return err
+		}
+	}
+
+	config.SetFeatureFlagSource(bootstrap.StoredFeatureFlags)
+	if _, err := config.Reload(); err != nil {
+		return fmt.Errorf("invalid configuration: %v", err)
+	}
+	if _, err := scanner.Default(); err != nil {
+		return fmt.Errorf("invalid configuration: %v", err)
+	}
+	return nil
+}
+
+func init() {
+	checkCmd.Flags().BoolVar(&checkJSON, "json", false, "print the report as JSON")
+	rootCmd.AddCommand(checkCmd)
+}
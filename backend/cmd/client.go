@@ -0,0 +1,297 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"pygorp/backend/client"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// clientConfig is the profile file, ~/.pygorp.yaml by default:
+//
+//	default_profile: prod
+//	profiles:
+//	  prod:
+//	    url: https://pygorp.example.com
+//	    token: pgpat_...
+type clientConfig struct {
+	DefaultProfile string                   `yaml:"default_profile"`
+	Profiles       map[string]clientProfile `yaml:"profiles"`
+}
+
+type clientProfile struct {
+	URL   string `yaml:"url"`
+	Token string `yaml:"token"`
+}
+
+var clientFlags struct {
+	config  string
+	profile string
+	url     string
+	token   string
+	output  string
+}
+
+var clientCmd = &cobra.Command{
+	Use:   "client",
+	Short: "Call a remote pygorp instance through its API",
+	Long: "Call a remote pygorp instance through its API. The instance and token come from " +
+		"--url and --token, else PYGORP_URL and PYGORP_TOKEN, else the selected profile in " +
+		"~/.pygorp.yaml (--profile, PYGORP_PROFILE, or default_profile).",
+	// Unlike the server commands, the client needs no server configuration.
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if clientFlags.output != "table" && clientFlags.output != "json" {
+			return fmt.Errorf("--output must be table or json")
+		}
+		return nil
+	},
+}
+
+var clientUsersCmd = &cobra.Command{
+	Use:   "users",
+	Short: "List, get, and create users",
+}
+
+var clientUsersListFlags struct {
+	emailDomain  string
+	nameContains string
+	groupID      int
+	teamID       int
+	sort         []string
+	limit        int
+}
+
+var clientUsersListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List users",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		c, err := newAPIClient()
+		if err != nil {
+			return err
+		}
+		f := clientUsersListFlags
+		it := c.Users.List(client.UserListOptions{
+			EmailDomain:  f.emailDomain,
+			NameContains: f.nameContains,
+			GroupID:      f.groupID,
+			TeamID:       f.teamID,
+			Sort:         f.sort,
+		})
+
+		users := []client.User{}
+		for (f.limit == 0 || len(users) < f.limit) && it.Next(cmd.Context()) {
+			users = append(users, it.Value())
+		}
+		if err := it.Err(); err != nil {
+			return err
+		}
+		return printUsers(users)
+	},
+}
+
+var clientUsersGetCmd = &cobra.Command{
+	Use:   "get ID",
+	Short: "Get a user",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var id int
+		if _, err := fmt.Sscan(args[0], &id); err != nil {
+			return fmt.Errorf("invalid user ID %q", args[0])
+		}
+		c, err := newAPIClient()
+		if err != nil {
+			return err
+		}
+		user, err := c.Users.Get(cmd.Context(), id)
+		if err != nil {
+			return err
+		}
+		return printUser(user)
+	},
+}
+
+var clientUsersCreateFlags struct {
+	email string
+	name  string
+	attrs []string
+}
+
+var clientUsersCreateCmd = &cobra.Command{
+	Use:   "create --email EMAIL --name NAME [--attr key=value ...]",
+	Short: "Create a user",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		f := clientUsersCreateFlags
+		req := client.CreateUserRequest{Email: f.email, Name: f.name}
+		for _, attr := range f.attrs {
+			key, raw, ok := strings.Cut(attr, "=")
+			if !ok || key == "" {
+				return fmt.Errorf("invalid --attr %q; use key=value", attr)
+			}
+			if req.Attributes == nil {
+				req.Attributes = map[string]interface{}{}
+			}
+			// Values are JSON when they parse as JSON, so numbers and
+			// booleans keep their type, and strings otherwise.
+			var value interface{}
+			if json.Unmarshal([]byte(raw), &value) != nil {
+				value = raw
+			}
+			req.Attributes[key] = value
+		}
+
+		c, err := newAPIClient()
+		if err != nil {
+			return err
+		}
+		user, err := c.Users.Create(cmd.Context(), req)
+		if err != nil {
+			return err
+		}
+		return printUser(user)
+	},
+}
+
+// newAPIClient builds a client from the flags, environment, and profile.
+func newAPIClient() (*client.Client, error) {
+	var profile clientProfile
+	cfg, err := loadClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	name := firstNonEmpty(clientFlags.profile, os.Getenv("PYGORP_PROFILE"), cfg.DefaultProfile)
+	if name != "" {
+		p, ok := cfg.Profiles[name]
+		if !ok {
+			return nil, fmt.Errorf("no profile %q in %s", name, clientConfigPath())
+		}
+		profile = p
+	}
+
+	url := firstNonEmpty(clientFlags.url, os.Getenv("PYGORP_URL"), profile.URL)
+	if url == "" {
+		return nil, errors.New("no instance to call; set --url, PYGORP_URL, or a profile in " + clientConfigPath())
+	}
+	token := firstNonEmpty(clientFlags.token, os.Getenv("PYGORP_TOKEN"), profile.Token)
+	return client.New(url, client.WithToken(token)), nil
+}
+
+func clientConfigPath() string {
+	if clientFlags.config != "" {
+		return clientFlags.config
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ".pygorp.yaml"
+	}
+	return filepath.Join(home, ".pygorp.yaml")
+}
+
+// loadClientConfig reads the profile file. A missing file is an empty
+// config unless --config names it.
+func loadClientConfig() (clientConfig, error) {
+	var cfg clientConfig
+	path := clientConfigPath()
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && clientFlags.config == "" {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, err
+	}
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("invalid %s: %v", path, err)
+	}
+	if info, err := os.Stat(path); err == nil && info.Mode().Perm()&0o077 != 0 {
+		fmt.Fprintf(os.Stderr, "Warning: %s holds tokens but is readable by other users; run chmod 600 %s\n", path, path)
+	}
+	return cfg, nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// printUsers writes users as a table or, with --output json, as a JSON
+// array.
+func printUsers(users []client.User) error {
+	if clientFlags.output == "json" {
+		return printJSON(users)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tEMAIL\tNAME\tCREATED\tSTATUS")
+	for _, u := range users {
+		status := "active"
+		if u.Deletion != nil {
+			status = "deleting " + u.Deletion.PurgeAt.Format(time.DateOnly)
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\n", u.ID, u.Email, u.Name, u.CreatedAt.Format(time.DateOnly), status)
+	}
+	return tw.Flush()
+}
+
+// printUser writes one user as a table row or a JSON object.
+func printUser(user client.User) error {
+	if clientFlags.output == "json" {
+		return printJSON(user)
+	}
+	return printUsers([]client.User{user})
+}
+
+func init() {
+	flags := clientCmd.PersistentFlags()
+	flags.StringVar(&clientFlags.config, "config", "", "profile file (default ~/.pygorp.yaml)")
+	flags.StringVar(&clientFlags.profile, "profile", "", "profile to use")
+	flags.StringVar(&clientFlags.url, "url", "", "base URL of the instance")
+	flags.StringVar(&clientFlags.token, "token", "", "personal access token or API key")
+	flags.StringVarP(&clientFlags.output, "output", "o", "table", "output format: table or json")
+	clientCmd.RegisterFlagCompletionFunc("profile", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		cfg, err := loadClientConfig()
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveError
+		}
+		names := make([]string, 0, len(cfg.Profiles))
+		for name, p := range cfg.Profiles {
+			names = append(names, name+"\t"+p.URL)
+		}
+		sort.Strings(names)
+		return names, cobra.ShellCompDirectiveNoFileComp
+	})
+	clientCmd.RegisterFlagCompletionFunc("output", cobra.FixedCompletions([]string{"table", "json"}, cobra.ShellCompDirectiveNoFileComp))
+
+	list := clientUsersListCmd.Flags()
+	list.StringVar(&clientUsersListFlags.emailDomain, "email-domain", "", "only users with emails at this domain")
+	list.StringVar(&clientUsersListFlags.nameContains, "name-contains", "", "only users whose name contains this")
+	list.IntVar(&clientUsersListFlags.groupID, "group", 0, "only members of this group ID")
+	list.IntVar(&clientUsersListFlags.teamID, "team", 0, "only members of this team ID")
+	list.StringSliceVar(&clientUsersListFlags.sort, "sort", nil, "sort fields, e.g. name,-created_at")
+	list.IntVar(&clientUsersListFlags.limit, "limit", 50, "most users to print (0 for all)")
+
+	create := clientUsersCreateCmd.Flags()
+	create.StringVar(&clientUsersCreateFlags.email, "email", "", "email address (required)")
+	create.StringVar(&clientUsersCreateFlags.name, "name", "", "display name (required)")
+	create.StringArrayVar(&clientUsersCreateFlags.attrs, "attr", nil, "custom attribute as key=value; repeatable")
+	clientUsersCreateCmd.MarkFlagRequired("email")
+	clientUsersCreateCmd.MarkFlagRequired("name")
+
+	clientUsersCmd.AddCommand(clientUsersListCmd, clientUsersGetCmd, clientUsersCreateCmd)
+	clientCmd.AddCommand(clientUsersCmd)
+	rootCmd.AddCommand(clientCmd)
+}
@@ -0,0 +1,31 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"pygorp/backend/internal/console"
+	"pygorp/backend/internal/database"
+
+	"github.com/spf13/cobra"
+)
+
+var consoleCmd = &cobra.Command{
+	Use:   "console",
+	Short: "Open an interactive operator shell for users and jobs",
+	Long: "Open an interactive operator shell. It talks to the database directly " +
+		"through the repository layer, so it works when the API or dashboard is down.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := database.InitDB(); err != nil {
+			return fmt.Errorf("failed to connect to database: %v", err)
+		}
+		defer database.CloseDB()
+
+		return console.New(os.Stdin, os.Stdout).Run(context.Background())
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(consoleCmd)
+}
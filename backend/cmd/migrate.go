@@ -0,0 +1,165 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"pygorp/backend/internal/database"
+
+	"github.com/spf13/cobra"
+)
+
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Manage database schema migrations",
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := rootCmd.PersistentPreRunE(cmd, args); err != nil {
+			return err
+		}
+		return database.InitDB()
+	},
+	PersistentPostRun: func(cmd *cobra.Command, args []string) {
+		database.CloseDB()
+	},
+}
+
+var (
+	migratePlan  bool
+	migrateForce bool
+)
+
+var migrateUpCmd = &cobra.Command{
+	Use:   "up",
+	Short: "Apply all pending migrations",
+	Long: `Apply all pending migrations.
+
+Pending migrations are checked for operations that would break the running
+binary or hold heavy locks. Unsafe ones are refused unless --force is given;
+--plan prints the checks, locks, and affected row estimates without applying
+anything. Add a "-- safety:ignore" comment above a reviewed statement to
+silence its findings.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if migratePlan {
+			return printPlan()
+		}
+
+		applied, err := database.Migrate(migrateForce)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Applied %d migration(s)\n", len(applied))
+		return nil
+	},
+}
+
+var migrateDownCmd = &cobra.Command{
+	Use:   "down [steps]",
+	Short: "Roll back the most recent migrations (default 1)",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		steps := 1
+		if len(args) == 1 {
+			n, err := strconv.Atoi(args[0])
+			if err != nil || n < 1 {
+				return fmt.Errorf("invalid step count %q", args[0])
+			}
+			steps = n
+		}
+
+		reverted, err := database.Rollback(steps)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Rolled back %d migration(s)\n", len(reverted))
+		return nil
+	},
+}
+
+var migrateStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "List migrations and whether they are applied",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		migrations, err := database.MigrationStatus()
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, m := range migrations {
+			applied := "pending"
+			if m.AppliedAt != nil {
+				applied = m.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(w, "%04d\t%s\t%s\n", m.Version, m.Name, applied)
+		}
+		return w.Flush()
+	},
+}
+
+var migrateVerifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Check that every down migration cleanly reverses its up migration",
+	Long: `Apply each migration, roll it back, and apply it again, comparing the
+schema after each step. Run it in CI against a fresh, disposable database;
+all migrations are left applied.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := database.VerifyRollbacks(); err != nil {
+			return err
+		}
+		fmt.Println("All migrations verified")
+		return nil
+	},
+}
+
+// printPlan shows each pending statement with the lock it takes.
+func printPlan() error {
+	plans, err := database.Plan()
+	if err != nil {
+		return err
+	}
+	if len(plans) == 0 {
+		fmt.Println("No pending migrations")
+		return nil
+	}
+
+	unsafe := 0
+	for _, p := range plans {
+		fmt.Printf("%04d_%s\n", p.Migration.Version, p.Migration.Name)
+		for _, s := range p.Statements {
+			fmt.Printf("  %s\n", truncate(s.SQL, 100))
+			if s.Lock != "" {
+				rows := "unknown rows"
+				if s.EstimatedRows >= 0 {
+					rows = fmt.Sprintf("~%d rows", s.EstimatedRows)
+				}
+				fmt.Printf("    lock: %s on %s (%s)\n", s.Lock, s.Table, rows)
+			}
+			for _, f := range s.Findings {
+				fmt.Printf("    %s: %s\n", f.Severity, f.Message)
+			}
+		}
+		unsafe += len(p.Findings(database.SeverityUnsafe))
+	}
+
+	if unsafe > 0 {
+		fmt.Printf("\n%d unsafe operation(s); \"migrate up\" will refuse without --force\n", unsafe)
+	}
+	return nil
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n-3] + "..."
+}
+
+func init() {
+	migrateUpCmd.Flags().BoolVar(&migratePlan, "plan", false, "print the execution plan and lock impact without applying")
+	migrateUpCmd.Flags().BoolVar(&migrateForce, "force", false, "apply migrations even when they are unsafe")
+	migrateCmd.AddCommand(migrateUpCmd, migrateDownCmd, migrateStatusCmd, migrateVerifyCmd)
+	rootCmd.AddCommand(migrateCmd)
+}
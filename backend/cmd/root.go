@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"pygorp/backend/internal/config"
+	"pygorp/backend/internal/logger"
+	"pygorp/backend/internal/version"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/cobra"
+)
+
+var rootCmd = &cobra.Command{
+	Use:           "pygorp",
+	Short:         "PyGoRP backend: API server, workers, and operational tasks",
+	Version:       version.Version,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		// Set Gin mode
+		gin.SetMode(gin.ReleaseMode)
+		if os.Getenv("GIN_MODE") == "debug" {
+			gin.SetMode(gin.DebugMode)
+		}
+
+		// Load runtime configuration
+		if err := config.Init(); err != nil {
+			return fmt.Errorf("invalid configuration: %v", err)
+		}
+		if err := logger.Init(config.Current().LogLevel); err != nil {
+			return fmt.Errorf("invalid log level: %v", err)
+		}
+		config.OnReload(func(rt *config.Runtime) error {
+			return logger.SetLevel(rt.LogLevel)
+		})
+		return nil
+	},
+}
+
+// Execute runs the CLI and exits non-zero on failure.
+func Execute() {
+	if err := rootCmd.Execute(); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"pygorp/backend/client"
+	"pygorp/backend/internal/routes"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	routesJSON        bool
+	routesCheckClient bool
+	routesOpenAPI     bool
+)
+
+var routesCmd = &cobra.Command{
+	Use:   "routes",
+	Short: "List all registered HTTP routes",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if routesOpenAPI {
+			doc, err := routes.OpenAPI()
+			if err != nil {
+				return err
+			}
+			_, err = os.Stdout.Write(doc)
+			return err
+		}
+
+		list := routes.List()
+		if routesCheckClient {
+			return checkClientRoutes(list)
+		}
+		if routesJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(list)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "METHOD\tPATH\tNAME\tMIDDLEWARE\tSCOPES")
+		for _, r := range list {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", r.Method, r.Path, r.Name,
+				strings.Join(r.Middleware, ","), strings.Join(r.Scopes, ","))
+		}
+		return w.Flush()
+	},
+}
+
+func init() {
+	routesCmd.Flags().BoolVar(&routesJSON, "json", false, "print routes as JSON")
+	routesCmd.Flags().BoolVar(&routesCheckClient, "check-client", false, "check that every endpoint the Go client calls exists, and exit")
+	routesCmd.Flags().BoolVar(&routesOpenAPI, "openapi", false, "print the OpenAPI document generated from the route table")
+	rootCmd.AddCommand(routesCmd)
+}
+
+// checkClientRoutes compares the endpoints in client.Routes with the route
+// table, so a client that has drifted from the server fails the build.
+func checkClientRoutes(list []routes.Info) error {
+	byName := make(map[string]routes.Info, len(list))
+	for _, r := range list {
+		byName[r.Name] = r
+	}
+
+	var problems []string
+	for name, want := range client.Routes {
+		got, ok := byName[name]
+		switch {
+		case !ok:
+			problems = append(problems, fmt.Sprintf("%s: no such route", name))
+		case got.Method != want.Method || got.Path != want.Path:
+			problems = append(problems, fmt.Sprintf("%s: client calls %s %s, server serves %s %s", name, want.Method, want.Path, got.Method, got.Path))
+		}
+	}
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return fmt.Errorf("the Go client is out of date:\n  %s", strings.Join(problems, "\n  "))
+	}
+	fmt.Printf("All %d client routes match the server\n", len(client.Routes))
+	return nil
+}
@@ -0,0 +1,30 @@
+package cmd
+
+import (
+	"fmt"
+
+	"pygorp/backend/internal/database"
+
+	"github.com/spf13/cobra"
+)
+
+var seedCmd = &cobra.Command{
+	Use:   "seed",
+	Short: "Insert sample data for local development",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := database.InitDB(); err != nil {
+			return fmt.Errorf("failed to connect to database: %v", err)
+		}
+		defer database.CloseDB()
+
+		if err := database.Seed(); err != nil {
+			return err
+		}
+		fmt.Println("Seed data inserted")
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(seedCmd)
+}
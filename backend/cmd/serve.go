@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"pygorp/backend/internal/audit"
+	"pygorp/backend/internal/config"
+	"pygorp/backend/internal/database"
+	"pygorp/backend/internal/jobs"
+	"pygorp/backend/internal/kpi"
+	"pygorp/backend/internal/routes"
+	"pygorp/backend/internal/slo"
+	"pygorp/backend/internal/version"
+	"pygorp/backend/internal/warmup"
+
+	"github.com/spf13/cobra"
+)
+
+var serveWithWorker bool
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Run the HTTP API server",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		defer database.CloseDB()
+		if err := startup(); err != nil {
+			return err
+		}
+
+		if err := database.RecordSchemaUsage(version.Get().String()); err != nil {
+			log.Printf("Failed to record schema usage: %v", err)
+		}
+
+		config.WatchSignals()
+		audit.Default()
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		// Get port from environment or default to 8080
+		port := os.Getenv("PORT")
+		if port == "" {
+			port = "8080"
+		}
+
+		srv := &http.Server{Addr: ":" + port, Handler: routes.NewRouter()}
+		warmup.Start(srv.Handler)
+		kpi.Start(ctx)
+		slo.Start(ctx)
+		errCh := make(chan error, 1)
+		go func() {
+			log.Printf("Starting PyGoRP Backend server on port %s", port)
+			if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				errCh <- err
+			}
+			close(errCh)
+		}()
+
+		workerDone := make(chan struct{})
+		if serveWithWorker {
+			go func() {
+				defer close(workerDone)
+				(&jobs.Worker{}).Run(ctx)
+			}()
+		} else {
+			close(workerDone)
+		}
+
+		select {
+		case err := <-errCh:
+			return err
+		case <-ctx.Done():
+		}
+
+		log.Println("Shutting down server...")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			return fmt.Errorf("server shutdown failed: %v", err)
+		}
+		if err := audit.Close(shutdownCtx); err != nil {
+			log.Printf("Failed to flush audit events: %v", err)
+		}
+		<-workerDone
+		return nil
+	},
+}
+
+func init() {
+	serveCmd.Flags().BoolVar(&skipChecks, "skip-checks", false, "start without running the startup self-checks")
+	serveCmd.Flags().BoolVar(&serveWithWorker, "with-worker", false, "also process background jobs in this process")
+	rootCmd.AddCommand(serveCmd)
+}
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"pygorp/backend/internal/config"
+	"pygorp/backend/internal/database"
+	"pygorp/backend/internal/jobs"
+	"pygorp/backend/internal/version"
+
+	// Register job handlers
+	_ "pygorp/backend/internal/attachments"
+	_ "pygorp/backend/internal/avatars"
+	_ "pygorp/backend/internal/backup"
+	_ "pygorp/backend/internal/campaigns"
+	_ "pygorp/backend/internal/disposable"
+	_ "pygorp/backend/internal/mailer"
+	_ "pygorp/backend/internal/operations"
+	_ "pygorp/backend/internal/previews"
+	_ "pygorp/backend/internal/retention"
+	_ "pygorp/backend/internal/tasks"
+	_ "pygorp/backend/internal/tus"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	workerQueues       []string
+	workerConcurrency  int
+	workerPollInterval time.Duration
+)
+
+var workerCmd = &cobra.Command{
+	Use:   "worker",
+	Short: "Process background jobs",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		defer database.CloseDB()
+		if err := startup(); err != nil {
+			return err
+		}
+
+		if err := database.RecordSchemaUsage(version.Get().String()); err != nil {
+			log.Printf("Failed to record schema usage: %v", err)
+		}
+
+		config.WatchSignals()
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		worker := &jobs.Worker{
+			Queues:       workerQueues,
+			Concurrency:  workerConcurrency,
+			PollInterval: workerPollInterval,
+		}
+		worker.Run(ctx)
+		return nil
+	},
+}
+
+func init() {
+	workerCmd.Flags().StringSliceVar(&workerQueues, "queues", []string{jobs.DefaultQueue}, "queues to process")
+	workerCmd.Flags().IntVar(&workerConcurrency, "concurrency", 4, "number of jobs processed in parallel")
+	workerCmd.Flags().DurationVar(&workerPollInterval, "poll-interval", time.Second, "delay between polls when no job is due")
+	workerCmd.Flags().BoolVar(&skipChecks, "skip-checks", false, "start without running the startup self-checks")
+	rootCmd.AddCommand(workerCmd)
+}
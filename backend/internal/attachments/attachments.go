@@ -0,0 +1,343 @@
+// Package attachments stores files attached to resources, enforcing the
+// size and type policy. Identical files are stored once, as a blob keyed by
+// their SHA-256 and counted by reference; unreferenced blobs and the
+// objects of removed uploads are deleted in the background.
+package attachments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"pygorp/backend/internal/clock"
+	"pygorp/backend/internal/idgen"
+	"pygorp/backend/internal/jobs"
+	"pygorp/backend/internal/models"
+	"pygorp/backend/internal/repository"
+	"pygorp/backend/internal/storage"
+)
+
+// SweepJob deletes the objects of removed attachments from storage.
+const SweepJob = "attachments.sweep"
+
+// GCJob deletes blobs no attachment has used for blobGracePeriod.
+const GCJob = "attachments.gc"
+
+// blobGracePeriod keeps unreferenced blobs around for a while, so a file
+// deleted and uploaded again is not stored twice.
+const blobGracePeriod = time.Hour
+
+// sweepBatch is how many objects one sweep deletes at most.
+const sweepBatch = 500
+
+// defaultTypes are the content types accepted when ATTACHMENT_TYPES is
+// not set.
+const defaultTypes = "image/*,application/pdf,text/plain,application/zip,application/json"
+
+func init() {
+	jobs.Register(SweepJob, sweep)
+	jobs.Every(SweepJob, jobs.DefaultQueue, 10*time.Minute)
+	jobs.Register(GCJob, collectBlobs)
+	jobs.Every(GCJob, jobs.DefaultQueue, time.Hour)
+}
+
+var (
+	// ErrTooLarge is returned for files over MaxBytes.
+	ErrTooLarge = errors.New("attachment is too large")
+	// ErrTypeNotAllowed is returned for files of a type not in AllowedTypes.
+	ErrTypeNotAllowed = errors.New("attachment type is not allowed")
+	// ErrPresignUnsupported is returned by Presign when the store cannot
+	// take uploads directly.
+	ErrPresignUnsupported = errors.New("direct uploads need the s3 storage driver")
+	// ErrNotUploaded is returned by Fetch when the client has not put the
+	// file in storage yet.
+	ErrNotUploaded = errors.New("the file has not been uploaded")
+)
+
+// MaxBytes caps the size of one attachment, read from ATTACHMENT_MAX_BYTES
+// and defaulting to 25 MiB.
+func MaxBytes() int64 {
+	if n, err := strconv.ParseInt(os.Getenv("ATTACHMENT_MAX_BYTES"), 10, 64); err == nil && n > 0 {
+		return n
+	}
+	return 25 << 20
+}
+
+// AllowedTypes lists the accepted content types from ATTACHMENT_TYPES,
+// comma-separated. "image/*" accepts any image type.
+func AllowedTypes() []string {
+	raw := os.Getenv("ATTACHMENT_TYPES")
+	if raw == "" {
+		raw = defaultTypes
+	}
+	var types []string
+	for _, t := range strings.Split(raw, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			types = append(types, t)
+		}
+	}
+	return types
+}
+
+// Check applies the policy to a file and returns its content type, which
+// is detected from the bytes rather than trusted from the client.
+func Check(data []byte) (string, error) {
+	if int64(len(data)) > MaxBytes() {
+		return "", ErrTooLarge
+	}
+	contentType := http.DetectContentType(data)
+	if err := CheckType(contentType); err != nil {
+		return "", err
+	}
+	return contentType, nil
+}
+
+// CheckType returns ErrTypeNotAllowed, naming the media type, unless the
+// content type is in AllowedTypes.
+func CheckType(contentType string) error {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	for _, allowed := range AllowedTypes() {
+		if mediaType == allowed || strings.HasSuffix(allowed, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(allowed, "*")) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s", ErrTypeNotAllowed, mediaType)
+}
+
+// PresignTTL is how long a pre-signed upload URL works, read from
+// ATTACHMENT_PRESIGN_TTL and defaulting to 15 minutes.
+func PresignTTL() time.Duration {
+	if d, err := time.ParseDuration(os.Getenv("ATTACHMENT_PRESIGN_TTL")); err == nil && d > 0 {
+		return d
+	}
+	return 15 * time.Minute
+}
+
+// Presign records an upload of a file the client puts straight in storage
+// and returns it with the URL to PUT it to. The declared size and content
+// type must pass the policy; the file is checked again by Fetch.
+func Presign(ctx context.Context, resourceType string, resourceID, userID int, filename, contentType string, size int64) (models.PresignedUpload, error) {
+	if size > MaxBytes() {
+		return models.PresignedUpload{}, ErrTooLarge
+	}
+	if err := CheckType(contentType); err != nil {
+		return models.PresignedUpload{}, err
+	}
+	store, err := storage.Default()
+	if err != nil {
+		return models.PresignedUpload{}, err
+	}
+	presigner, ok := store.(storage.Presigner)
+	if !ok {
+		return models.PresignedUpload{}, ErrPresignUnsupported
+	}
+
+	id := idgen.NewID()
+	key := "uploads/" + id
+	ttl := PresignTTL()
+	url, err := presigner.PresignPut(key, contentType, ttl)
+	if err != nil {
+		return models.PresignedUpload{}, err
+	}
+	upload, err := repository.CreatePresignedUpload(ctx, models.PresignedUpload{
+		ID:           id,
+		ResourceType: resourceType,
+		ResourceID:   resourceID,
+		Filename:     CleanFilename(filename),
+		ContentType:  contentType,
+		Size:         size,
+		StorageKey:   key,
+		CreatedBy:    userID,
+		ExpiresAt:    clock.Now().Add(ttl),
+	})
+	upload.URL = url
+	return upload, err
+}
+
+// Fetch returns the bytes a client uploaded through a pre-signed URL,
+// checking the object's size before downloading it. The object itself is
+// left for the sweep, since the URL can still overwrite it until it
+// expires; callers pass the bytes to Upload to keep a copy that cannot
+// change.
+func Fetch(ctx context.Context, upload models.PresignedUpload) ([]byte, error) {
+	store, err := storage.Default()
+	if err != nil {
+		return nil, err
+	}
+	presigner, ok := store.(storage.Presigner)
+	if !ok {
+		return nil, ErrPresignUnsupported
+	}
+
+	obj, err := presigner.Stat(ctx, upload.StorageKey)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, ErrNotUploaded
+	}
+	if err != nil {
+		return nil, err
+	}
+	if obj.Size > MaxBytes() {
+		return nil, ErrTooLarge
+	}
+	data, err := store.Get(ctx, upload.StorageKey)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, ErrNotUploaded
+	}
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > MaxBytes() {
+		return nil, ErrTooLarge
+	}
+	return data, nil
+}
+
+// Upload checks a file against the policy, stores it, and attaches it to a
+// resource. filename is reduced to its base name.
+func Upload(ctx context.Context, resourceType string, resourceID, uploadedBy int, filename string, data []byte) (models.Attachment, error) {
+	contentType, err := Check(data)
+	if err != nil {
+		return models.Attachment{}, err
+	}
+	store, err := storage.Default()
+	if err != nil {
+		return models.Attachment{}, err
+	}
+
+	// Files with the same content share one blob, stored the first time.
+	key := storage.ContentKey("blobs", data, "")
+	hash := path.Base(key)
+	blob, err := repository.AcquireBlob(ctx, hash, key, int64(len(data)))
+	if err != nil {
+		return models.Attachment{}, err
+	}
+	if !blob.Stored {
+		err := store.Put(ctx, blob.StorageKey, contentType, data)
+		if err == nil {
+			err = repository.MarkBlobStored(ctx, hash)
+		}
+		if err != nil {
+			releaseBlob(ctx, hash)
+			return models.Attachment{}, fmt.Errorf("failed to store attachment: %v", err)
+		}
+	}
+
+	a, err := repository.CreateAttachment(ctx, models.Attachment{
+		ResourceType: resourceType,
+		ResourceID:   resourceID,
+		Filename:     CleanFilename(filename),
+		ContentType:  contentType,
+		Size:         int64(len(data)),
+		SHA256:       hash,
+		StorageKey:   blob.StorageKey,
+		UploadedBy:   &uploadedBy,
+	})
+	if err != nil {
+		releaseBlob(ctx, hash)
+		return a, err
+	}
+	return a, nil
+}
+
+// releaseBlob drops the reference Upload took when it could not create
+// the attachment. A reference that is never dropped only keeps the blob
+// from being collected.
+func releaseBlob(ctx context.Context, hash string) {
+	if err := repository.ReleaseBlob(ctx, hash); err != nil {
+		log.Printf("Failed to release blob %s: %v", hash, err)
+	}
+}
+
+// Read returns an attachment's bytes.
+func Read(ctx context.Context, a models.Attachment) ([]byte, error) {
+	store, err := storage.Default()
+	if err != nil {
+		return nil, err
+	}
+	return store.Get(ctx, a.StorageKey)
+}
+
+// CleanFilename drops any directories and control characters from a
+// client-supplied name.
+func CleanFilename(name string) string {
+	name = path.Base(strings.ReplaceAll(name, "\\", "/"))
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f {
+			return -1
+		}
+		return r
+	}, name)
+	if name == "" || name == "." || name == "/" {
+		name = "attachment"
+	}
+	if len(name) > 255 {
+		ext := path.Ext(name)
+		if len(ext) > 16 {
+			ext = ""
+		}
+		name = strings.ToValidUTF8(name[:255-len(ext)], "") + ext
+	}
+	return name
+}
+
+// sweep expires pre-signed uploads and deletes a batch of queued objects.
+// What is left is picked up by the next run.
+func sweep(ctx context.Context, job *jobs.Job) error {
+	expired, err := repository.DeleteExpiredPresignedUploads(ctx, clock.Now())
+	if err != nil {
+		return err
+	}
+	if expired > 0 {
+		log.Printf("Expired %d pre-signed uploads", expired)
+	}
+
+	keys, err := repository.PendingStorageDeletions(ctx, sweepBatch)
+	if err != nil || len(keys) == 0 {
+		return err
+	}
+	store, err := storage.Default()
+	if err != nil {
+		return err
+	}
+
+	var deleted []string
+	for _, key := range keys {
+		if err := store.Delete(ctx, key); err != nil {
+			log.Printf("Failed to delete attachment object %s: %v", key, err)
+			continue
+		}
+		deleted = append(deleted, key)
+	}
+	if len(deleted) > 0 {
+		log.Printf("Deleted %d attachment objects", len(deleted))
+	}
+	return repository.ClearStorageDeletions(ctx, deleted)
+}
+
+// collectBlobs deletes a batch of blobs that have gone unreferenced for
+// blobGracePeriod, along with their objects.
+func collectBlobs(ctx context.Context, job *jobs.Job) error {
+	store, err := storage.Default()
+	if err != nil {
+		return err
+	}
+	n, err := repository.CollectBlobs(ctx, clock.Now().Add(-blobGracePeriod), sweepBatch, func(key string) error {
+		err := store.Delete(ctx, key)
+		if err != nil {
+			log.Printf("Failed to delete blob %s: %v", key, err)
+		}
+		return err
+	})
+	if n > 0 {
+		log.Printf("Collected %d unreferenced blobs", n)
+	}
+	return err
+}
@@ -0,0 +1,189 @@
+package attributes
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Attribute types.
+const (
+	TypeString  = "string"
+	TypeNumber  = "number"
+	TypeBoolean = "boolean"
+	TypeDate    = "date"
+	TypeEnum    = "enum"
+)
+
+var namePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,63}$`)
+
+// Definition describes one custom user attribute. Indexed attributes can be
+// used as filters when listing users.
+type Definition struct {
+	Name        string     `json:"name"`
+	Type        string     `json:"type"`
+	Required    bool       `json:"required"`
+	Indexed     bool       `json:"indexed"`
+	Validation  Validation `json:"validation"`
+	Description string     `json:"description"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// Validation holds the optional constraints for a definition. Which fields
+// apply depends on the type.
+type Validation struct {
+	Enum      []string `json:"enum,omitempty"`
+	Min       *float64 `json:"min,omitempty"`
+	Max       *float64 `json:"max,omitempty"`
+	MaxLength int      `json:"max_length,omitempty"`
+	Pattern   string   `json:"pattern,omitempty"`
+}
+
+// Check rejects definitions that could never validate a value.
+func (d *Definition) Check() error {
+	if !namePattern.MatchString(d.Name) {
+		return fmt.Errorf("name must be lowercase letters, digits, and underscores, starting with a letter")
+	}
+	switch d.Type {
+	case TypeString:
+		if d.Validation.Pattern != "" {
+			if _, err := regexp.Compile(d.Validation.Pattern); err != nil {
+				return fmt.Errorf("invalid pattern: %v", err)
+			}
+		}
+	case TypeEnum:
+		if len(d.Validation.Enum) == 0 {
+			return fmt.Errorf("enum attributes need validation.enum")
+		}
+	case TypeNumber, TypeBoolean, TypeDate:
+	default:
+		return fmt.Errorf("type must be one of string, number, boolean, date, enum")
+	}
+	if d.Validation.Min != nil && d.Validation.Max != nil && *d.Validation.Min > *d.Validation.Max {
+		return fmt.Errorf("validation.min must not exceed validation.max")
+	}
+	return nil
+}
+
+// Validate checks one value against the definition. Values arrive decoded
+// from JSON, so numbers are float64.
+func (d *Definition) Validate(v interface{}) error {
+	switch d.Type {
+	case TypeString:
+		s, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("%s must be a string", d.Name)
+		}
+		if d.Validation.MaxLength > 0 && len([]rune(s)) > d.Validation.MaxLength {
+			return fmt.Errorf("%s must be at most %d characters", d.Name, d.Validation.MaxLength)
+		}
+		if d.Validation.Pattern != "" && !regexp.MustCompile(d.Validation.Pattern).MatchString(s) {
+			return fmt.Errorf("%s does not match %s", d.Name, d.Validation.Pattern)
+		}
+	case TypeNumber:
+		n, ok := v.(float64)
+		if !ok {
+			return fmt.Errorf("%s must be a number", d.Name)
+		}
+		if d.Validation.Min != nil && n < *d.Validation.Min {
+			return fmt.Errorf("%s must be at least %v", d.Name, *d.Validation.Min)
+		}
+		if d.Validation.Max != nil && n > *d.Validation.Max {
+			return fmt.Errorf("%s must be at most %v", d.Name, *d.Validation.Max)
+		}
+	case TypeBoolean:
+		if _, ok := v.(bool); !ok {
+			return fmt.Errorf("%s must be a boolean", d.Name)
+		}
+	case TypeDate:
+		s, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("%s must be a date (YYYY-MM-DD)", d.Name)
+		}
+		if _, err := time.Parse(time.DateOnly, s); err != nil {
+			return fmt.Errorf("%s must be a date (YYYY-MM-DD)", d.Name)
+		}
+	case TypeEnum:
+		s, _ := v.(string)
+		for _, allowed := range d.Validation.Enum {
+			if s == allowed {
+				return nil
+			}
+		}
+		return fmt.Errorf("%s must be one of %s", d.Name, strings.Join(d.Validation.Enum, ", "))
+	}
+	return nil
+}
+
+// Parse converts a query string value into the attribute's JSON type, for
+// filtering.
+func (d *Definition) Parse(raw string) (interface{}, error) {
+	var v interface{} = raw
+	switch d.Type {
+	case TypeNumber:
+		n, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be a number", d.Name)
+		}
+		v = n
+	case TypeBoolean:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be a boolean", d.Name)
+		}
+		v = b
+	}
+	return v, d.Validate(v)
+}
+
+// Registry is the set of definitions keyed by name.
+type Registry map[string]*Definition
+
+// NewRegistry indexes definitions by name.
+func NewRegistry(defs []*Definition) Registry {
+	r := make(Registry, len(defs))
+	for _, d := range defs {
+		r[d.Name] = d
+	}
+	return r
+}
+
+// Validate checks a set of attribute values. For a full set (on create)
+// required attributes must be present; for a patch they may be omitted, and
+// null removes an optional attribute. Unknown names are rejected.
+func (r Registry) Validate(values map[string]interface{}, patch bool) error {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		d, ok := r[name]
+		if !ok {
+			return fmt.Errorf("unknown attribute %q", name)
+		}
+		if values[name] == nil {
+			if d.Required {
+				return fmt.Errorf("%s is required", name)
+			}
+			continue
+		}
+		if err := d.Validate(values[name]); err != nil {
+			return err
+		}
+	}
+
+	if !patch {
+		for _, d := range r {
+			if _, ok := values[d.Name]; d.Required && !ok {
+				return fmt.Errorf("%s is required", d.Name)
+			}
+		}
+	}
+	return nil
+}
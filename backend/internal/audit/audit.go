@@ -0,0 +1,203 @@
+// Package audit records who did what through the API and ships the records
+// to a SIEM with the exporter chosen by AUDIT_EXPORTER. Events are buffered
+// in memory and sent in batches by a background goroutine, so recording
+// never waits on the SIEM.
+package audit
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"pygorp/backend/internal/audit/exporters"
+	"pygorp/backend/internal/clock"
+	"pygorp/backend/internal/env"
+	"pygorp/backend/internal/idgen"
+	"pygorp/backend/internal/metrics"
+)
+
+// Event is one audited action.
+type Event = exporters.Event
+
+// Shipper batches events for an exporter. When the SIEM is slow or down the
+// buffer fills while batches are retried; further events are then dropped
+// and counted rather than blocking requests.
+type Shipper struct {
+	exporter   exporters.Exporter
+	batchSize  int
+	interval   time.Duration
+	maxRetries int
+
+	queue   chan Event
+	dropped atomic.Int64
+	done    chan struct{}
+	stop    chan struct{}
+}
+
+// NewShipper starts a shipper that sends batches of up to batchSize events
+// at least every interval, holding at most buffer events.
+func NewShipper(exporter exporters.Exporter, buffer, batchSize int, interval time.Duration, maxRetries int) *Shipper {
+	s := &Shipper{
+		exporter:   exporter,
+		batchSize:  batchSize,
+		interval:   interval,
+		maxRetries: maxRetries,
+		queue:      make(chan Event, buffer),
+		done:       make(chan struct{}),
+		stop:       make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+// Record queues an event without blocking.
+func (s *Shipper) Record(e Event) {
+	select {
+	case s.queue <- e:
+	default:
+		if s.dropped.Add(1) == 1 {
+			log.Printf("Audit buffer is full; dropping events until %s catches up", s.exporter.Name())
+		}
+		metrics.AuditEvents.WithLabelValues("dropped").Inc()
+	}
+}
+
+// Close sends the buffered events and stops the shipper. Events still
+// buffered when ctx ends are lost.
+func (s *Shipper) Close(ctx context.Context) error {
+	close(s.stop)
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("audit events not flushed: %v", ctx.Err())
+	}
+}
+
+func (s *Shipper) run() {
+	defer close(s.done)
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	batch := make([]Event, 0, s.batchSize)
+	flush := func() {
+		if len(batch) > 0 {
+			s.send(batch)
+			batch = batch[:0]
+		}
+		if n := s.dropped.Swap(0); n > 0 {
+			log.Printf("Dropped %d audit events while the buffer was full", n)
+		}
+	}
+
+	for {
+		select {
+		case e := <-s.queue:
+			batch = append(batch, e)
+			if len(batch) >= s.batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-s.stop:
+			for {
+				select {
+				case e := <-s.queue:
+					batch = append(batch, e)
+					if len(batch) >= s.batchSize {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// send exports a batch, retrying with exponential backoff. While it
+// retries, new events wait in the buffer.
+func (s *Shipper) send(batch []Event) {
+	backoff := time.Second
+	last := false
+	for attempt := 0; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		err := s.exporter.Export(ctx, batch)
+		cancel()
+		if err == nil {
+			metrics.AuditEvents.WithLabelValues("exported").Add(float64(len(batch)))
+			return
+		}
+		if exporters.IsPermanent(err) || attempt >= s.maxRetries || last {
+			log.Printf("Failed to export %d audit events to %s: %v", len(batch), s.exporter.Name(), err)
+			metrics.AuditEvents.WithLabelValues("failed").Add(float64(len(batch)))
+			return
+		}
+
+		log.Printf("Failed to export audit events to %s, retrying in %s: %v", s.exporter.Name(), backoff, err)
+		select {
+		case <-time.After(backoff):
+		case <-s.stop:
+			// Shutting down: make one last attempt without waiting.
+			last = true
+		}
+		if backoff < 30*time.Second {
+			backoff *= 2
+		}
+	}
+}
+
+var (
+	defaultShipper *Shipper
+	once           sync.Once
+)
+
+// Default returns the shipper configured from the environment, or nil when
+// AUDIT_EXPORTER is unset or invalid.
+func Default() *Shipper {
+	once.Do(func() {
+		exporter, err := exporters.FromEnv()
+		if err != nil {
+			log.Printf("Audit export disabled: %v", err)
+			return
+		}
+		if exporter == nil {
+			return
+		}
+		defaultShipper = NewShipper(exporter,
+			env.Int("AUDIT_BUFFER_SIZE", 10000),
+			env.Int("AUDIT_BATCH_SIZE", 100),
+			env.Duration("AUDIT_FLUSH_INTERVAL", 5*time.Second),
+			env.Int("AUDIT_MAX_RETRIES", 5))
+		log.Printf("Exporting audit events to %s", exporter.Name())
+	})
+	return defaultShipper
+}
+
+// Record queues an event with the default shipper, filling in its ID and
+// time. It does nothing when audit export is off.
+func Record(e Event) {
+	s := Default()
+	if s == nil {
+		return
+	}
+	if e.ID == "" {
+		e.ID = idgen.NewID()
+	}
+	if e.Time.IsZero() {
+		e.Time = clock.Now().UTC()
+	}
+	s.Record(e)
+}
+
+// Close flushes the default shipper, if it was started.
+func Close(ctx context.Context) error {
+	if defaultShipper == nil {
+		return nil
+	}
+	return defaultShipper.Close(ctx)
+}
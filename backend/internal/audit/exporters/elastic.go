@@ -0,0 +1,130 @@
+package exporters
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Elastic indexes events with the bulk API. Documents are created with the
+// event ID as _id, so a retried batch does not duplicate events that were
+// already indexed.
+type Elastic struct {
+	url    string
+	apiKey string
+	index  string
+	client *http.Client
+}
+
+// NewElastic takes the cluster URL, an optional API key (the encoded form
+// sent as "ApiKey <key>"), and the index or data stream to write to.
+func NewElastic(baseURL, apiKey, index string) (*Elastic, error) {
+	if baseURL == "" {
+		return nil, fmt.Errorf("AUDIT_ELASTIC_URL is required")
+	}
+	return &Elastic{
+		url:    strings.TrimRight(baseURL, "/") + "/_bulk",
+		apiKey: apiKey,
+		index:  index,
+		client: &http.Client{Timeout: 15 * time.Second},
+	}, nil
+}
+
+func (e *Elastic) Name() string { return "elastic" }
+
+func (e *Elastic) Export(ctx context.Context, events []Event) error {
+	type action struct {
+		Create struct {
+			Index string `json:"_index"`
+			ID    string `json:"_id"`
+		} `json:"create"`
+	}
+	type document struct {
+		Timestamp time.Time `json:"@timestamp"`
+		Event
+	}
+
+	var body bytes.Buffer
+	enc := json.NewEncoder(&body)
+	for _, event := range events {
+		var a action
+		a.Create.Index, a.Create.ID = e.index, event.ID
+		if err := enc.Encode(a); err != nil {
+			return Permanent(err)
+		}
+		if err := enc.Encode(document{Timestamp: event.Time, Event: event}); err != nil {
+			return Permanent(err)
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, &body)
+	if err != nil {
+		return Permanent(err)
+	}
+	if e.apiKey != "" {
+		req.Header.Set("Authorization", "ApiKey "+e.apiKey)
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return statusError("elastic", resp.StatusCode, detail)
+	}
+
+	var result struct {
+		Errors bool                  `json:"errors"`
+		Items  []map[string]bulkItem `json:"items"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("failed to decode elastic bulk response: %v", err)
+	}
+	if !result.Errors {
+		return nil
+	}
+	return bulkError(result.Items)
+}
+
+// bulkItem is the result for one document in a bulk response, keyed by
+// the action name.
+type bulkItem struct {
+	Status int `json:"status"`
+	Error  struct {
+		Reason string `json:"reason"`
+	} `json:"error"`
+}
+
+// bulkError reports the first failed item. A conflict means the document
+// was indexed by an earlier attempt, so it counts as delivered. The batch is
+// retried when any item was throttled or hit a server error.
+func bulkError(items []map[string]bulkItem) error {
+	var first error
+	retry := false
+	for _, item := range items {
+		for _, result := range item {
+			if result.Status < 300 || result.Status == http.StatusConflict {
+				continue
+			}
+			if result.Status == http.StatusTooManyRequests || result.Status >= 500 {
+				retry = true
+			}
+			if first == nil {
+				first = fmt.Errorf("elastic rejected an event (%d): %s", result.Status, result.Error.Reason)
+			}
+		}
+	}
+	if first == nil || retry {
+		return first
+	}
+	return Permanent(first)
+}
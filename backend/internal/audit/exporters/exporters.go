@@ -0,0 +1,108 @@
+// Package exporters ships audit events to external SIEMs. Each exporter
+// sends a batch in as few requests as the destination allows; batching,
+// retries, and buffering are left to the caller.
+package exporters
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"pygorp/backend/internal/env"
+)
+
+// Outcomes of an audited action.
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+	OutcomeDenied  = "denied"
+)
+
+// Event is one audited action.
+type Event struct {
+	ID        string    `json:"id"`
+	Time      time.Time `json:"time"`
+	Action    string    `json:"action"`
+	Outcome   string    `json:"outcome"`
+	Actor     Actor     `json:"actor"`
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
+	Status    int       `json:"status"`
+	RequestID string    `json:"request_id,omitempty"`
+	ClientIP  string    `json:"client_ip,omitempty"`
+	UserAgent string    `json:"user_agent,omitempty"`
+	// Details describe a change recorded by the handler that made it, such
+	// as a member's old and new role.
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+// Actor identifies who performed an action. At most one field is usually
+// set; none are set for anonymous requests.
+type Actor struct {
+	UserID         string `json:"user_id,omitempty"`
+	ServiceAccount string `json:"service_account,omitempty"`
+	Service        string `json:"service,omitempty"`
+}
+
+// Exporter sends a batch of events to a SIEM.
+type Exporter interface {
+	Name() string
+	// Export delivers every event or returns an error. Errors wrapped with
+	// Permanent are not worth retrying.
+	Export(ctx context.Context, events []Event) error
+}
+
+type permanentError struct{ err error }
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent marks an error that retrying the same batch cannot fix, such as
+// a rejected token or a malformed request.
+func Permanent(err error) error {
+	return permanentError{err}
+}
+
+// IsPermanent reports whether err was marked with Permanent.
+func IsPermanent(err error) bool {
+	var p permanentError
+	return errors.As(err, &p)
+}
+
+// FromEnv builds the exporter selected by AUDIT_EXPORTER: "syslog",
+// "splunk", or "elastic". It returns nil when auditing is off (the default).
+func FromEnv() (Exporter, error) {
+	switch kind := os.Getenv("AUDIT_EXPORTER"); kind {
+	case "", "off":
+		return nil, nil
+	case "syslog":
+		return NewSyslog(os.Getenv("AUDIT_SYSLOG_ADDR"), env.String("AUDIT_SYSLOG_APP_NAME", "pygorp"))
+	case "splunk":
+		return NewSplunk(os.Getenv("AUDIT_SPLUNK_URL"), os.Getenv("AUDIT_SPLUNK_TOKEN"), os.Getenv("AUDIT_SPLUNK_INDEX"))
+	case "elastic":
+		return NewElastic(os.Getenv("AUDIT_ELASTIC_URL"), os.Getenv("AUDIT_ELASTIC_API_KEY"), env.String("AUDIT_ELASTIC_INDEX", "pygorp-audit"))
+	default:
+		return nil, fmt.Errorf("unknown audit exporter %q (want syslog, splunk, or elastic)", kind)
+	}
+}
+
+// statusError turns a non-2xx HTTP status into an error. Client errors other
+// than 408 and 429 are permanent.
+func statusError(name string, status int, detail []byte) error {
+	err := fmt.Errorf("%s returned %d: %s", name, status, strings.TrimSpace(string(detail)))
+	if status >= 400 && status < 500 && status != 408 && status != 429 {
+		return Permanent(err)
+	}
+	return err
+}
+
+func hostname() string {
+	host, err := os.Hostname()
+	if err != nil {
+		return "-"
+	}
+	return host
+}
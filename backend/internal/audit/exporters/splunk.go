@@ -0,0 +1,85 @@
+package exporters
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Splunk sends events to a Splunk HTTP Event Collector. A batch goes in one
+// request as concatenated event objects.
+type Splunk struct {
+	url    string
+	token  string
+	index  string
+	host   string
+	client *http.Client
+}
+
+// NewSplunk takes the HEC base URL, e.g. https://splunk.example.com:8088,
+// and a HEC token. An empty index uses the token's default index.
+func NewSplunk(baseURL, token, index string) (*Splunk, error) {
+	if baseURL == "" || token == "" {
+		return nil, fmt.Errorf("AUDIT_SPLUNK_URL and AUDIT_SPLUNK_TOKEN are required")
+	}
+	return &Splunk{
+		url:    strings.TrimRight(baseURL, "/") + "/services/collector/event",
+		token:  token,
+		index:  index,
+		host:   hostname(),
+		client: &http.Client{Timeout: 15 * time.Second},
+	}, nil
+}
+
+func (s *Splunk) Name() string { return "splunk" }
+
+func (s *Splunk) Export(ctx context.Context, events []Event) error {
+	type hecEvent struct {
+		Time       float64 `json:"time"`
+		Host       string  `json:"host"`
+		Source     string  `json:"source"`
+		SourceType string  `json:"sourcetype"`
+		Index      string  `json:"index,omitempty"`
+		Event      Event   `json:"event"`
+	}
+
+	var body bytes.Buffer
+	enc := json.NewEncoder(&body)
+	for _, e := range events {
+		err := enc.Encode(hecEvent{
+			Time:       float64(e.Time.UnixMilli()) / 1000,
+			Host:       s.host,
+			Source:     "pygorp",
+			SourceType: "pygorp:audit",
+			Index:      s.index,
+			Event:      e,
+		})
+		if err != nil {
+			return Permanent(err)
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, &body)
+	if err != nil {
+		return Permanent(err)
+	}
+	req.Header.Set("Authorization", "Splunk "+s.token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return statusError("splunk", resp.StatusCode, detail)
+	}
+	return nil
+}
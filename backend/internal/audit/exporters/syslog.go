@@ -0,0 +1,129 @@
+package exporters
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Syslog facility and severities. Facility 13 is "log audit".
+const (
+	facilityAudit   = 13
+	severityWarning = 4
+	severityInfo    = 6
+)
+
+// Syslog sends RFC 5424 messages with the event as JSON in the message
+// body. Over tcp and tls, messages are framed with octet counting
+// (RFC 6587); over udp each message is one datagram.
+type Syslog struct {
+	network string
+	addr    string
+	appName string
+	host    string
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// NewSyslog parses an address like udp://host:514, tcp://host:601, or
+// tls://host:6514.
+func NewSyslog(addr, appName string) (*Syslog, error) {
+	u, err := url.Parse(addr)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("AUDIT_SYSLOG_ADDR must look like udp://host:514, tcp://host:601, or tls://host:6514")
+	}
+	switch u.Scheme {
+	case "udp", "tcp", "tls":
+	default:
+		return nil, fmt.Errorf("unsupported syslog transport %q", u.Scheme)
+	}
+	return &Syslog{network: u.Scheme, addr: u.Host, appName: appName, host: hostname()}, nil
+}
+
+func (s *Syslog) Name() string { return "syslog" }
+
+func (s *Syslog) Export(ctx context.Context, events []Event) error {
+	var buf bytes.Buffer
+	var frames [][]byte
+	for _, e := range events {
+		msg, err := s.format(e)
+		if err != nil {
+			return Permanent(err)
+		}
+		if s.network == "udp" {
+			frames = append(frames, msg)
+			continue
+		}
+		buf.WriteString(strconv.Itoa(len(msg)))
+		buf.WriteByte(' ')
+		buf.Write(msg)
+	}
+	if s.network != "udp" {
+		frames = [][]byte{buf.Bytes()}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, frame := range frames {
+		if err := s.write(ctx, frame); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// write sends one frame, dialing if needed. The connection is dropped on
+// failure so the next attempt redials.
+func (s *Syslog) write(ctx context.Context, frame []byte) error {
+	if s.conn == nil {
+		conn, err := s.dial(ctx)
+		if err != nil {
+			return err
+		}
+		s.conn = conn
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	s.conn.SetWriteDeadline(deadline)
+	if _, err := s.conn.Write(frame); err != nil {
+		s.conn.Close()
+		s.conn = nil
+		return err
+	}
+	return nil
+}
+
+func (s *Syslog) dial(ctx context.Context) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	if s.network == "tls" {
+		return (&tls.Dialer{NetDialer: dialer}).DialContext(ctx, "tcp", s.addr)
+	}
+	return dialer.DialContext(ctx, s.network, s.addr)
+}
+
+// format builds "<PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID - MSG".
+// Denied actions are logged as warnings.
+func (s *Syslog) format(e Event) ([]byte, error) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+	severity := severityInfo
+	if e.Outcome == OutcomeDenied {
+		severity = severityWarning
+	}
+	header := fmt.Sprintf("<%d>1 %s %s %s %d audit - ",
+		facilityAudit*8+severity, e.Time.UTC().Format(time.RFC3339Nano), s.host, s.appName, os.Getpid())
+	return append([]byte(header), data...), nil
+}
@@ -0,0 +1,68 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"strings"
+	"time"
+
+	"pygorp/backend/internal/env"
+)
+
+// APIKeyPrefix starts every service account API key, so keys are easy to
+// recognise in the Authorization header and in secret scanners.
+const APIKeyPrefix = "pgsa_"
+
+// PersonalTokenPrefix starts every personal access token.
+const PersonalTokenPrefix = "pgpat_"
+
+// PersonalTokenMaxTTL is the longest a personal access token may last, and
+// how long tokens created without an expiry last. AUTH_PAT_MAX_TTL sets it;
+// the default is a year.
+func PersonalTokenMaxTTL() time.Duration {
+	return env.Duration("AUTH_PAT_MAX_TTL", 365*24*time.Hour)
+}
+
+// NewAPIKey generates a service account API key. It returns the key to hand
+// out once, the public prefix used to look it up, and the hash to store.
+func NewAPIKey() (key, prefix, hash string) {
+	return newKey(APIKeyPrefix)
+}
+
+// ParseAPIKey splits a presented key into its lookup prefix and hash.
+func ParseAPIKey(key string) (prefix, hash string, ok bool) {
+	return parseKey(APIKeyPrefix, key)
+}
+
+// NewPersonalToken generates a personal access token, like NewAPIKey.
+func NewPersonalToken() (token, prefix, hash string) {
+	return newKey(PersonalTokenPrefix)
+}
+
+// ParsePersonalToken splits a presented personal access token into its
+// lookup prefix and hash.
+func ParsePersonalToken(token string) (prefix, hash string, ok bool) {
+	return parseKey(PersonalTokenPrefix, token)
+}
+
+func newKey(kind string) (key, prefix, hash string) {
+	prefix = strings.NewReplacer("-", "", "_", "").Replace(randomToken(9))
+	key = kind + prefix + "_" + randomToken(32)
+	return key, prefix, hashToken(key)
+}
+
+func parseKey(kind, key string) (prefix, hash string, ok bool) {
+	rest, ok := strings.CutPrefix(key, kind)
+	if !ok {
+		return "", "", false
+	}
+	prefix, _, ok = strings.Cut(rest, "_")
+	if !ok || prefix == "" {
+		return "", "", false
+	}
+	return prefix, hashToken(key), true
+}
+
+// HashesMatch compares two key hashes in constant time.
+func HashesMatch(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
@@ -0,0 +1,294 @@
+// Package auth issues and verifies end-user sessions.
+//
+// A session pairs a short-lived access token, an HS256 JWT signed with
+// AUTH_SECRET, with a long-lived refresh token that is stored hashed and
+// replaced on every use. During rotation AUTH_PREVIOUS_SECRET is still
+// accepted for verification. Access tokens are not checked against the
+// sessions table, so revoking a session takes effect when its current
+// access token expires.
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"pygorp/backend/internal/clock"
+	"pygorp/backend/internal/database"
+	"pygorp/backend/internal/env"
+
+	"github.com/lib/pq"
+)
+
+// Issuer and audience of access tokens.
+const (
+	Issuer   = "pygorp-backend"
+	Audience = "pygorp-api"
+)
+
+// clockSkew tolerates small clock differences with token consumers.
+const clockSkew = 30 * time.Second
+
+var (
+	ErrDisabled     = errors.New("authentication is not configured")
+	ErrInvalidToken = errors.New("invalid token")
+	ErrExpired      = errors.New("token has expired")
+	ErrDeactivated  = errors.New("account is pending deletion")
+)
+
+// Claims are the access token claims. Session is the ID of the session the
+// token belongs to, Method how the user signed in, and Scope the
+// space-separated scopes the token grants.
+type Claims struct {
+	Issuer    string `json:"iss"`
+	Audience  string `json:"aud"`
+	Subject   string `json:"sub"`
+	Session   string `json:"sid"`
+	Method    string `json:"amr"`
+	Scope     string `json:"scope"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+	ID        string `json:"jti"`
+}
+
+// Scopes returns the scopes the token grants.
+func (c *Claims) Scopes() []string {
+	return strings.Fields(c.Scope)
+}
+
+// Tokens is the response body for a new or refreshed session.
+type Tokens struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	Scope        string `json:"scope"`
+}
+
+// Client describes where a session was started from. Fingerprint is a hash
+// of browser headers that identifies a device more reliably than the IP.
+type Client struct {
+	IP          string
+	UserAgent   string
+	Fingerprint string
+}
+
+// Fingerprint hashes the header values that describe a device.
+func Fingerprint(headers ...string) string {
+	return hashToken(strings.Join(headers, "\n"))[:32]
+}
+
+func init() {
+	database.UseColumns("sessions", "id, user_id, method, scopes, refresh_hash, previous_refresh_hash, ip, user_agent, expires_at, last_used_at, revoked_at")
+}
+
+var header = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// Enabled reports whether a signing secret is configured.
+func Enabled() bool {
+	return os.Getenv("AUTH_SECRET") != ""
+}
+
+// DefaultScopes are granted to new sessions. AUTH_DEFAULT_SCOPES replaces
+// them with a space- or comma-separated list.
+func DefaultScopes() []string {
+	if value := os.Getenv("AUTH_DEFAULT_SCOPES"); value != "" {
+		return strings.FieldsFunc(value, func(r rune) bool { return r == ' ' || r == ',' })
+	}
+	return []string{"users:read", "users:read_pii", "users:write", "groups:read", "groups:write", "orgs:read", "orgs:write", "tasks:write", "events:read"}
+}
+
+func accessTTL() time.Duration {
+	return env.Duration("AUTH_ACCESS_TTL", 15*time.Minute)
+}
+
+func refreshTTL() time.Duration {
+	return env.Duration("AUTH_REFRESH_TTL", 30*24*time.Hour)
+}
+
+// StartSession creates a session for a user who has just signed in with
+// method, such as "webauthn". The session is granted DefaultScopes, which
+// its tokens keep across refreshes. Users pending deletion get
+// ErrDeactivated.
+func StartSession(ctx context.Context, userID int, method string, client Client) (*Tokens, error) {
+	if !Enabled() {
+		return nil, ErrDisabled
+	}
+
+	sessionID, refresh, scopes := randomToken(16), randomToken(32), DefaultScopes()
+	result, err := database.DB.ExecContext(ctx, `
+		INSERT INTO sessions (id, user_id, method, scopes, refresh_hash, ip, user_agent, expires_at)
+		SELECT $1, id, $3, $4, $5, $6, $7, $8 FROM users WHERE id = $2 AND purge_at IS NULL`,
+		sessionID, userID, method, pq.Array(scopes), hashToken(refresh), client.IP, client.UserAgent, clock.Now().Add(refreshTTL()))
+	if err != nil {
+		return nil, err
+	}
+	// The user is pending deletion (or was just deleted).
+	if n, _ := result.RowsAffected(); n == 0 {
+		return nil, ErrDeactivated
+	}
+	return issue(userID, sessionID, method, scopes, refresh)
+}
+
+// Refresh exchanges a refresh token for new tokens. The old refresh token
+// stops working; presenting it again revokes the session, since it means
+// the token was copied.
+func Refresh(ctx context.Context, refreshToken string, client Client) (*Tokens, error) {
+	if !Enabled() {
+		return nil, ErrDisabled
+	}
+
+	var (
+		sessionID, method string
+		userID            int
+		scopes            []string
+		current           bool
+	)
+	err := database.DB.QueryRowContext(ctx, `
+		SELECT id, user_id, method, scopes, refresh_hash = $1 FROM sessions
+		WHERE (refresh_hash = $1 OR previous_refresh_hash = $1) AND revoked_at IS NULL AND expires_at > $2`,
+		hashToken(refreshToken), clock.Now()).Scan(&sessionID, &userID, &method, pq.Array(&scopes), &current)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !current {
+		if err := Revoke(ctx, sessionID); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidToken
+	}
+
+	refresh := randomToken(32)
+	result, err := database.DB.ExecContext(ctx, `
+		UPDATE sessions SET previous_refresh_hash = refresh_hash, refresh_hash = $1,
+			ip = $2, user_agent = $3, last_used_at = $6
+		WHERE id = $4 AND refresh_hash = $5`,
+		hashToken(refresh), client.IP, client.UserAgent, sessionID, hashToken(refreshToken), clock.Now())
+	if err != nil {
+		return nil, err
+	}
+	// A concurrent refresh with the same token won.
+	if n, _ := result.RowsAffected(); n == 0 {
+		return nil, ErrInvalidToken
+	}
+	return issue(userID, sessionID, method, scopes, refresh)
+}
+
+// Revoke ends a session so its refresh token stops working.
+func Revoke(ctx context.Context, sessionID string) error {
+	_, err := database.DB.ExecContext(ctx, "UPDATE sessions SET revoked_at = $2 WHERE id = $1 AND revoked_at IS NULL", sessionID, clock.Now())
+	return err
+}
+
+// RevokeUser ends every session of a user.
+func RevokeUser(ctx context.Context, userID int) error {
+	return database.WithStmt(ctx, "UPDATE sessions SET revoked_at = $2 WHERE user_id = $1 AND revoked_at IS NULL", func(stmt *sql.Stmt) error {
+		_, err := stmt.ExecContext(ctx, userID, clock.Now())
+		return err
+	})
+}
+
+func issue(userID int, sessionID, method string, scopes []string, refresh string) (*Tokens, error) {
+	now := clock.Now()
+	claims := Claims{
+		Issuer:    Issuer,
+		Audience:  Audience,
+		Subject:   strconv.Itoa(userID),
+		Session:   sessionID,
+		Method:    method,
+		Scope:     strings.Join(scopes, " "),
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(accessTTL()).Unix(),
+		ID:        randomToken(8),
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	unsigned := header + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return &Tokens{
+		AccessToken:  unsigned + "." + sign(unsigned, os.Getenv("AUTH_SECRET")),
+		RefreshToken: refresh,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(accessTTL().Seconds()),
+		Scope:        claims.Scope,
+	}, nil
+}
+
+// Verify checks an access token's signature, expiry, and audience.
+func Verify(token string) (*Claims, error) {
+	if !Enabled() {
+		return nil, ErrDisabled
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+	var head struct {
+		Alg string `json:"alg"`
+	}
+	rawHeader, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil || json.Unmarshal(rawHeader, &head) != nil || head.Alg != "HS256" {
+		return nil, ErrInvalidToken
+	}
+
+	if !validSignature(parts[0]+"."+parts[1], parts[2]) {
+		return nil, ErrInvalidToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	var claims Claims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	now := clock.Now()
+	if now.After(time.Unix(claims.ExpiresAt, 0).Add(clockSkew)) {
+		return nil, ErrExpired
+	}
+	if time.Unix(claims.IssuedAt, 0).After(now.Add(clockSkew)) {
+		return nil, fmt.Errorf("%w: issued in the future", ErrInvalidToken)
+	}
+	if claims.Audience != Audience {
+		return nil, ErrInvalidToken
+	}
+	return &claims, nil
+}
+
+func sign(unsigned, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(unsigned))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// hashToken hashes a refresh token for storage. Tokens are random, so a
+// plain SHA-256 is enough.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomToken(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,150 @@
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"database/sql"
+	"errors"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"pygorp/backend/internal/clock"
+	"pygorp/backend/internal/database"
+	"pygorp/backend/internal/env"
+)
+
+// MagicLinksPerHour is how many links one email address can request per
+// hour.
+const MagicLinksPerHour = 5
+
+// ErrTooManyLinks is returned when an email address has requested too many
+// magic links recently.
+var ErrTooManyLinks = errors.New("too many sign-in links requested; try again later")
+
+func init() {
+	database.UseColumns("magic_links", "id, email, user_id, ip, user_agent, fingerprint, expires_at, consumed_at, consumed_ip, consumed_user_agent, consumed_fingerprint")
+}
+
+// MagicLinkTTL is how long a magic link can be used.
+func MagicLinkTTL() time.Duration {
+	return env.Duration("MAGIC_LINK_TTL", 15*time.Minute)
+}
+
+// MagicLinkURL builds the link emailed to the user. MAGIC_LINK_URL is the
+// frontend page that posts the token to the consume endpoint.
+func MagicLinkURL(token string) string {
+	base := os.Getenv("MAGIC_LINK_URL")
+	if base == "" {
+		base = "http://localhost:3000/auth/magic-link"
+	}
+	return LinkURL(base, token)
+}
+
+// LinkURL adds a token query parameter to base, the frontend page that
+// handles an emailed link.
+func LinkURL(base, token string) string {
+	sep := "?"
+	if strings.Contains(base, "?") {
+		sep = "&"
+	}
+	return base + sep + "token=" + url.QueryEscape(token)
+}
+
+// SignLink returns the token for an emailed link: the ID of the row it
+// refers to and the ID's signature for purpose, so a leaked table of IDs
+// cannot be turned into working links.
+func SignLink(purpose, id string) string {
+	return id + "." + sign(purpose+":"+id, os.Getenv("AUTH_SECRET"))
+}
+
+// ParseLink returns the ID in a token made by SignLink for the same
+// purpose, or ErrInvalidToken.
+func ParseLink(purpose, token string) (string, error) {
+	id, sig, ok := strings.Cut(token, ".")
+	if !ok || !validSignature(purpose+":"+id, sig) {
+		return "", ErrInvalidToken
+	}
+	return id, nil
+}
+
+// NewMagicLink records a sign-in link request for email and returns the
+// token to put in the link. userID is nil when no user has the email; the
+// request still counts towards the limit, but its token is never sent.
+// Tokens are an ID and its HMAC signature, so a leaked magic_links table
+// cannot be used to sign in.
+func NewMagicLink(ctx context.Context, email string, userID *int, client Client) (string, error) {
+	if !Enabled() {
+		return "", ErrDisabled
+	}
+
+	now := clock.Now()
+	var recent int
+	err := database.DB.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM magic_links WHERE email = $1 AND created_at > $2`,
+		email, now.Add(-time.Hour)).Scan(&recent)
+	if err != nil {
+		return "", err
+	}
+	if recent >= MagicLinksPerHour {
+		return "", ErrTooManyLinks
+	}
+
+	id := randomToken(16)
+	_, err = database.DB.ExecContext(ctx, `
+		INSERT INTO magic_links (id, email, user_id, ip, user_agent, fingerprint, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		id, email, userID, client.IP, client.UserAgent, client.Fingerprint, now.Add(MagicLinkTTL()), now)
+	if err != nil {
+		return "", err
+	}
+	return SignLink("magic_link", id), nil
+}
+
+// ConsumeMagicLink checks a magic link token, marks it used, and starts a
+// session for its user. A link opened on a different device than it was
+// requested from still works, but is logged.
+func ConsumeMagicLink(ctx context.Context, token string, client Client) (*Tokens, error) {
+	if !Enabled() {
+		return nil, ErrDisabled
+	}
+
+	id, err := ParseLink("magic_link", token)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		userID      sql.NullInt64
+		fingerprint string
+	)
+	err = database.DB.QueryRowContext(ctx, `
+		UPDATE magic_links SET consumed_at = $5, consumed_ip = $2, consumed_user_agent = $3, consumed_fingerprint = $4
+		WHERE id = $1 AND consumed_at IS NULL AND expires_at > $5 AND user_id IS NOT NULL
+		RETURNING user_id, fingerprint`,
+		id, client.IP, client.UserAgent, client.Fingerprint, clock.Now()).Scan(&userID, &fingerprint)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if fingerprint != client.Fingerprint {
+		log.Printf("Magic link for user %d used from a different device than requested (ip %s, fingerprint %s, requested from %s)",
+			userID.Int64, client.IP, client.Fingerprint, fingerprint)
+	}
+	return StartSession(ctx, int(userID.Int64), "magic_link", client)
+}
+
+// validSignature checks sig against the current and previous secrets.
+func validSignature(unsigned, sig string) bool {
+	for _, secret := range []string{os.Getenv("AUTH_SECRET"), os.Getenv("AUTH_PREVIOUS_SECRET")} {
+		if secret != "" && hmac.Equal([]byte(sig), []byte(sign(unsigned, secret))) {
+			return true
+		}
+	}
+	return false
+}
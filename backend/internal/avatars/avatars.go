@@ -0,0 +1,151 @@
+// Package avatars stores user avatar uploads and renders their smaller
+// sizes in the background.
+package avatars
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+
+	"pygorp/backend/internal/jobs"
+	"pygorp/backend/internal/models"
+	"pygorp/backend/internal/repository"
+	"pygorp/backend/internal/storage"
+)
+
+// MaxBytes caps the size of an uploaded avatar.
+const MaxBytes = 5 << 20
+
+// maxDimension rejects images that would be expensive to decode.
+const maxDimension = 4096
+
+// JobType is the background job that renders avatar sizes.
+const JobType = "avatar.process"
+
+var (
+	// ErrUnsupported is returned for uploads that are not a supported image.
+	ErrUnsupported = errors.New("avatar must be a JPEG, PNG, or GIF image")
+	// ErrTooLarge is returned for images larger than maxDimension.
+	ErrTooLarge = fmt.Errorf("avatar must be at most %dx%d pixels", maxDimension, maxDimension)
+)
+
+// Size is a square rendition generated from the original.
+type Size struct {
+	Name  string
+	Width int
+}
+
+// Sizes lists the generated renditions, smallest first.
+var Sizes = []Size{
+	{Name: "thumbnail", Width: 64},
+	{Name: "medium", Width: 256},
+}
+
+var contentTypes = map[string]string{
+	"jpeg": "image/jpeg",
+	"png":  "image/png",
+	"gif":  "image/gif",
+}
+
+type processPayload struct {
+	UserID int    `json:"user_id"`
+	Key    string `json:"key"`
+	Width  int    `json:"width"`
+}
+
+// Upload validates and stores the original image, then schedules the
+// resized variants. The user's avatar is updated once they are ready.
+func Upload(ctx context.Context, userID int, data []byte) error {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return ErrUnsupported
+	}
+	contentType, ok := contentTypes[format]
+	if !ok {
+		return ErrUnsupported
+	}
+	if cfg.Width > maxDimension || cfg.Height > maxDimension {
+		return ErrTooLarge
+	}
+
+	store, err := storage.Default()
+	if err != nil {
+		return err
+	}
+	key := storage.ContentKey("avatars", data, "."+format)
+	if err := store.Put(ctx, key, contentType, data); err != nil {
+		return fmt.Errorf("failed to store avatar: %v", err)
+	}
+
+	_, err = jobs.Enqueue(ctx, jobs.DefaultQueue, JobType, processPayload{UserID: userID, Key: key, Width: cfg.Width})
+	return err
+}
+
+// process renders every size from the stored original and saves the set on
+// the user. Keys are content-addressed, so a retry rewrites identical objects.
+func process(ctx context.Context, p processPayload) error {
+	store, err := storage.Default()
+	if err != nil {
+		return err
+	}
+	data, err := store.Get(ctx, p.Key)
+	if err != nil {
+		return fmt.Errorf("failed to read original avatar: %v", err)
+	}
+	src, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to decode avatar: %v", err)
+	}
+
+	variants := make([]models.AvatarVariant, 0, len(Sizes)+1)
+	for _, size := range Sizes {
+		encoded, ext, contentType, err := encode(resizeSquare(src, size.Width), format)
+		if err != nil {
+			return err
+		}
+		key := storage.ContentKey("avatars", encoded, ext)
+		if err := store.Put(ctx, key, contentType, encoded); err != nil {
+			return fmt.Errorf("failed to store %s avatar: %v", size.Name, err)
+		}
+		variants = append(variants, models.AvatarVariant{Name: size.Name, Key: key, Width: size.Width})
+	}
+	variants = append(variants, models.AvatarVariant{Name: "original", Key: p.Key, Width: p.Width})
+
+	err = repository.SetUserAvatar(ctx, p.UserID, variants)
+	if errors.Is(err, repository.ErrNotFound) {
+		// The user was deleted while the job was queued.
+		return nil
+	}
+	return err
+}
+
+// encode writes JPEG sources back as JPEG and everything else as PNG.
+func encode(img image.Image, format string) ([]byte, string, string, error) {
+	var buf bytes.Buffer
+	if format == "jpeg" {
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 85}); err != nil {
+			return nil, "", "", err
+		}
+		return buf.Bytes(), ".jpeg", "image/jpeg", nil
+	}
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, "", "", err
+	}
+	return buf.Bytes(), ".png", "image/png", nil
+}
+
+func init() {
+	jobs.Register(JobType, func(ctx context.Context, job *jobs.Job) error {
+		var p processPayload
+		if err := json.Unmarshal(job.Payload, &p); err != nil {
+			return fmt.Errorf("invalid avatar payload: %v", err)
+		}
+		return process(ctx, p)
+	})
+}
@@ -0,0 +1,253 @@
+// Package backup takes and restores logical snapshots of the database with
+// pg_dump and pg_restore.
+package backup
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"pygorp/backend/internal/env"
+	"pygorp/backend/internal/storage"
+)
+
+// prefix is where backups are kept in the backup store.
+const prefix = "backups/"
+
+// queueTables are left alone on restore so the job running the restore, and
+// any other queued work, survive it.
+var queueTables = []string{"jobs", "operations"}
+
+var ErrNotFound = errors.New("backup not found")
+
+// Retention decides which backups are kept after a new one is taken.
+type Retention struct {
+	// Keep is the number of newest backups always kept.
+	Keep int
+	// MaxAge removes older backups beyond Keep. Zero keeps them all.
+	MaxAge time.Duration
+}
+
+// Store returns the backup destination configured by BACKUP_DRIVER. It is
+// separate from the media store so backups are never publicly reachable.
+func Store() (storage.Store, error) {
+	switch driver := env.String("BACKUP_DRIVER", "local"); driver {
+	case "local":
+		return storage.NewLocal(env.String("BACKUP_DIR", "./backups"), ""), nil
+	case "s3":
+		bucket := os.Getenv("BACKUP_S3_BUCKET")
+		if bucket == "" {
+			return nil, errors.New("BACKUP_S3_BUCKET is required for the s3 backup driver")
+		}
+		return storage.NewS3(storage.S3Config{
+			Endpoint:        os.Getenv("S3_ENDPOINT"),
+			Region:          env.String("S3_REGION", "us-east-1"),
+			Bucket:          bucket,
+			AccessKeyID:     os.Getenv("S3_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+		}), nil
+	default:
+		return nil, fmt.Errorf("unknown backup driver %q", driver)
+	}
+}
+
+// RetentionFromEnv reads BACKUP_KEEP and BACKUP_MAX_AGE.
+func RetentionFromEnv() (Retention, error) {
+	keep, err := strconv.Atoi(env.String("BACKUP_KEEP", "7"))
+	if err != nil || keep < 1 {
+		return Retention{}, fmt.Errorf("invalid BACKUP_KEEP: must be a positive integer")
+	}
+	var maxAge time.Duration
+	if v := os.Getenv("BACKUP_MAX_AGE"); v != "" {
+		if maxAge, err = time.ParseDuration(v); err != nil {
+			return Retention{}, fmt.Errorf("invalid BACKUP_MAX_AGE: %v", err)
+		}
+	}
+	return Retention{Keep: keep, MaxAge: maxAge}, nil
+}
+
+// Create dumps the database and uploads it. pg_dump reads from a single
+// snapshot, so the export is consistent even while the app is writing. The
+// dump goes through a temporary file rather than memory.
+func Create(ctx context.Context, store storage.Store) (storage.Object, error) {
+	dump, err := os.CreateTemp("", "pygorp-backup-*.dump")
+	if err != nil {
+		return storage.Object{}, err
+	}
+	defer os.Remove(dump.Name())
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "pg_dump", "--format=custom", "--no-owner", "--no-privileges", "--enable-row-security")
+	cmd.Env = pgEnv()
+	cmd.Stdout = dump
+	cmd.Stderr = &stderr
+	err = cmd.Run()
+	dump.Close()
+	if err != nil {
+		return storage.Object{}, fmt.Errorf("pg_dump failed: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	info, err := os.Stat(dump.Name())
+	if err != nil {
+		return storage.Object{}, err
+	}
+
+	now := time.Now().UTC()
+	key := prefix + "pygorp-" + now.Format("20060102T150405Z") + ".dump"
+	if err := store.PutFile(ctx, key, "application/octet-stream", dump.Name()); err != nil {
+		return storage.Object{}, fmt.Errorf("failed to upload backup: %v", err)
+	}
+
+	log.Printf("Created backup %s (%d bytes)", key, info.Size())
+	return storage.Object{Key: key, Size: info.Size(), LastModified: now}, nil
+}
+
+// List returns the stored backups, newest first.
+func List(ctx context.Context, store storage.Store) ([]storage.Object, error) {
+	objects, err := store.List(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	// Keys embed the creation time, so reverse key order is newest first.
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key > objects[j].Key })
+	return objects, nil
+}
+
+// Prune deletes backups outside the retention policy and returns their keys.
+func Prune(ctx context.Context, store storage.Store, r Retention) ([]string, error) {
+	backups, err := List(ctx, store)
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []string
+	for i, b := range backups {
+		if i < r.Keep {
+			continue
+		}
+		if r.MaxAge > 0 && time.Since(b.LastModified) < r.MaxAge {
+			continue
+		}
+		if err := store.Delete(ctx, b.Key); err != nil {
+			return removed, fmt.Errorf("failed to delete backup %s: %v", b.Key, err)
+		}
+		removed = append(removed, b.Key)
+	}
+	return removed, nil
+}
+
+// Restore replaces the database contents with a backup. The job queue
+// tables are skipped so in-flight work is not lost.
+func Restore(ctx context.Context, store storage.Store, key string) error {
+	if !strings.HasPrefix(key, prefix) {
+		key = prefix + key
+	}
+	dump, err := os.CreateTemp("", "pygorp-restore-*.dump")
+	if err != nil {
+		return err
+	}
+	dump.Close()
+	defer os.Remove(dump.Name())
+
+	err = store.GetFile(ctx, key, dump.Name())
+	if errors.Is(err, storage.ErrNotFound) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to download backup: %v", err)
+	}
+
+	list, err := restoreList(ctx, dump.Name())
+	if err != nil {
+		return err
+	}
+	defer os.Remove(list)
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "pg_restore",
+		"--clean", "--if-exists", "--no-owner", "--no-privileges", "--single-transaction",
+		"--use-list="+list, "--dbname="+env.String("DB_NAME", "pygorp"), dump.Name())
+	cmd.Env = pgEnv()
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("pg_restore failed: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	log.Printf("Restored backup %s", key)
+	return nil
+}
+
+// restoreList writes the dump's table of contents without the queue tables
+// and returns the file name, for pg_restore --use-list.
+func restoreList(ctx context.Context, dump string) (string, error) {
+	var out, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "pg_restore", "--list", dump)
+	cmd.Stdout = &out
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("failed to read backup contents: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	list, err := os.CreateTemp("", "pygorp-restore-*.list")
+	if err != nil {
+		return "", err
+	}
+	defer list.Close()
+
+	w := bufio.NewWriter(list)
+	scanner := bufio.NewScanner(&out)
+	for scanner.Scan() {
+		if !touchesQueue(scanner.Text()) {
+			fmt.Fprintln(w, scanner.Text())
+		}
+	}
+	if err := w.Flush(); err != nil {
+		os.Remove(list.Name())
+		return "", err
+	}
+	return list.Name(), nil
+}
+
+// touchesQueue reports whether a TOC entry belongs to a queue table. Entries
+// look like "215; 1259 16390 TABLE public jobs postgres".
+func touchesQueue(entry string) bool {
+	if strings.HasPrefix(entry, ";") {
+		return false
+	}
+	fields := strings.Fields(entry)
+	for i := 0; i+1 < len(fields); i++ {
+		if fields[i] != "public" {
+			continue
+		}
+		for _, table := range queueTables {
+			name := fields[i+1]
+			if name == table || strings.HasPrefix(name, table+"_") || strings.HasPrefix(name, "idx_"+table+"_") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// pgEnv passes the app's database settings to the Postgres tools.
+func pgEnv() []string {
+	return append(os.Environ(),
+		"PGHOST="+env.String("DB_HOST", "localhost"),
+		"PGPORT="+env.String("DB_PORT", "5432"),
+		"PGUSER="+env.String("DB_USER", "postgres"),
+		"PGPASSWORD="+env.String("DB_PASSWORD", "password"),
+		"PGDATABASE="+env.String("DB_NAME", "pygorp"),
+		"PGSSLMODE="+env.String("DB_SSLMODE", "disable"),
+		// Like the backend's own connections, see every row despite the
+		// row-level security policies.
+		"PGOPTIONS=-c app.bypass_rls=on",
+	)
+}
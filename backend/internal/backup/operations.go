@@ -0,0 +1,63 @@
+package backup
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"pygorp/backend/internal/operations"
+)
+
+// Operation types for backups started from the admin API.
+const (
+	TypeCreate  = "db.backup"
+	TypeRestore = "db.restore"
+)
+
+// RestoreParams names the backup to restore.
+type RestoreParams struct {
+	Key string `json:"key"`
+}
+
+func init() {
+	operations.Register(TypeCreate, createOperation)
+	operations.Register(TypeRestore, restoreOperation)
+}
+
+func createOperation(ctx context.Context, task *operations.Task) (*operations.Result, error) {
+	store, err := Store()
+	if err != nil {
+		return nil, err
+	}
+	retention, err := RetentionFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	created, err := Create(ctx, store)
+	if err != nil {
+		return nil, err
+	}
+	task.SetProgress(ctx, 90)
+
+	pruned, err := Prune(ctx, store, retention)
+	if err != nil {
+		return nil, err
+	}
+	return &operations.Result{Data: map[string]interface{}{"backup": created, "pruned": pruned}}, nil
+}
+
+func restoreOperation(ctx context.Context, task *operations.Task) (*operations.Result, error) {
+	var p RestoreParams
+	if err := json.Unmarshal(task.Params, &p); err != nil {
+		return nil, fmt.Errorf("invalid restore params: %v", err)
+	}
+	store, err := Store()
+	if err != nil {
+		return nil, err
+	}
+	if err := Restore(ctx, store, p.Key); err != nil {
+		return nil, err
+	}
+	return &operations.Result{Data: map[string]string{"restored": p.Key}}, nil
+}
@@ -0,0 +1,214 @@
+// Package batch runs several API requests sent in one POST, so mobile
+// clients on slow links can save round-trips. Each request goes through the
+// router in-process with the caller's headers, so it is authenticated,
+// authorized, and rate limited as if it had been sent on its own, and gets
+// its own status and body in the response.
+//
+// Requests run in order. By default each stands alone and a failure does
+// not stop the ones after it. In an atomic batch they share one database
+// transaction: the first failure rolls back every change, later requests
+// are not run, and the events of the batch are published only once it
+// commits. Only routes whose writes all go through the request transaction
+// may take part; the route table marks them.
+package batch
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+
+	"pygorp/backend/internal/config"
+	"pygorp/backend/internal/database"
+	"pygorp/backend/internal/events"
+	"pygorp/backend/internal/render"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Request is one request of a batch. Path is the full path, such as
+// /api/v1/users/7, with any query string. Headers are added to those of
+// the batch request.
+type Request struct {
+	Method  string            `json:"method" binding:"required"`
+	Path    string            `json:"path" binding:"required"`
+	Headers map[string]string `json:"headers"`
+	Body    json.RawMessage   `json:"body"`
+}
+
+// Result is the response to one request. Body is the response body, as
+// JSON when it is JSON and as a string otherwise. Requests not run because
+// an earlier one in an atomic batch failed have status 424.
+type Result struct {
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    json.RawMessage   `json:"body,omitempty"`
+}
+
+type batchRequest struct {
+	Atomic   bool      `json:"atomic"`
+	Requests []Request `json:"requests" binding:"required"`
+}
+
+// Handler answers batch requests by sending each through router. Paths must
+// be under prefix. atomic reports whether a request may run in an atomic
+// batch; reads always may, since they change nothing.
+func Handler(prefix string, router http.Handler, atomic func(method, path string) bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req batchRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			render.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if limit := config.Current().MaxBatchRequests; len(req.Requests) > limit {
+			render.JSON(c, http.StatusBadRequest, gin.H{"error": fmt.Sprintf("A batch may carry at most %d requests", limit)})
+			return
+		}
+		for i, r := range req.Requests {
+			if err := check(c, prefix, r); err != nil {
+				render.JSON(c, http.StatusBadRequest, gin.H{"error": fmt.Sprintf("requests[%d]: %v", i, err)})
+				return
+			}
+			if req.Atomic && !isRead(r.Method) && !atomic(strings.ToUpper(r.Method), pathOnly(r.Path)) {
+				render.JSON(c, http.StatusBadRequest, gin.H{"error": fmt.Sprintf("requests[%d]: %s %s cannot run in an atomic batch", i, strings.ToUpper(r.Method), r.Path)})
+				return
+			}
+		}
+
+		// Each request gets a transaction of its own, or the batch's, never
+		// the one this request may run in.
+		ctx := database.WithoutTx(c.Request.Context())
+		if !req.Atomic {
+			results := make([]Result, len(req.Requests))
+			for i, r := range req.Requests {
+				results[i] = send(ctx, c, router, r)
+			}
+			render.JSON(c, http.StatusOK, gin.H{"data": results})
+			return
+		}
+
+		base := ctx
+		ctx, tx, err := begin(ctx)
+		if err != nil {
+			log.Printf("Failed to begin batch transaction: %v", err)
+			render.JSON(c, http.StatusServiceUnavailable, gin.H{"error": "Database unavailable"})
+			return
+		}
+		ctx, held := events.Hold(ctx)
+		defer func() {
+			if p := recover(); p != nil {
+				tx.Rollback()
+				panic(p)
+			}
+		}()
+
+		results := make([]Result, len(req.Requests))
+		failed := false
+		for i, r := range req.Requests {
+			if failed {
+				results[i] = Result{Status: http.StatusFailedDependency}
+				continue
+			}
+			results[i] = send(ctx, c, router, r)
+			failed = results[i].Status >= http.StatusBadRequest
+		}
+		if failed {
+			tx.Rollback()
+			render.JSON(c, http.StatusOK, gin.H{"data": results, "committed": false})
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			c.Error(err)
+			render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to commit batch"})
+			return
+		}
+		held.Release(base)
+		render.JSON(c, http.StatusOK, gin.H{"data": results, "committed": true})
+	}
+}
+
+// begin starts the transaction of an atomic batch, with the tenant settings
+// when requests run in tenant transactions.
+func begin(ctx context.Context) (context.Context, *sql.Tx, error) {
+	if tenant := database.TenantFrom(ctx); database.RLSEnabled() && tenant != (database.Tenant{}) {
+		return database.BeginTenant(ctx, tenant)
+	}
+	return database.Begin(ctx)
+}
+
+func check(c *gin.Context, prefix string, r Request) error {
+	switch strings.ToUpper(r.Method) {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+	default:
+		return fmt.Errorf("unsupported method %q", r.Method)
+	}
+	u, err := url.Parse(r.Path)
+	if err != nil || u.IsAbs() || u.Host != "" {
+		return fmt.Errorf("invalid path %q", r.Path)
+	}
+	if !strings.HasPrefix(u.Path, prefix+"/") {
+		return fmt.Errorf("path must start with %s/", prefix)
+	}
+	if u.Path == c.Request.URL.Path {
+		return fmt.Errorf("batches cannot be nested")
+	}
+	return nil
+}
+
+func isRead(method string) bool {
+	method = strings.ToUpper(method)
+	return method == http.MethodGet || method == http.MethodHead
+}
+
+func pathOnly(path string) string {
+	path, _, _ = strings.Cut(path, "?")
+	return path
+}
+
+// send runs one request through router with the headers of the batch
+// request.
+func send(ctx context.Context, c *gin.Context, router http.Handler, r Request) Result {
+	hasBody := len(r.Body) > 0 && string(r.Body) != "null"
+	var body []byte
+	if hasBody {
+		body = r.Body
+	}
+	req, err := http.NewRequestWithContext(ctx, strings.ToUpper(r.Method), r.Path, bytes.NewReader(body))
+	if err != nil {
+		return Result{Status: http.StatusBadRequest}
+	}
+	req.Host = c.Request.Host
+	req.RemoteAddr = c.Request.RemoteAddr
+	req.Header = c.Request.Header.Clone()
+	req.Header.Del("Content-Length")
+	req.Header.Del("Content-Type")
+	req.Header.Del("Accept-Encoding")
+	if hasBody {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for name, value := range r.Headers {
+		req.Header.Set(name, value)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	result := Result{Status: w.Code, Headers: map[string]string{}}
+	for name, values := range w.Header() {
+		result.Headers[name] = strings.Join(values, ", ")
+	}
+	if w.Body.Len() > 0 {
+		if render.IsAnyJSON(w.Header().Get("Content-Type")) && json.Valid(w.Body.Bytes()) {
+			result.Body = w.Body.Bytes()
+		} else {
+			result.Body, _ = json.Marshal(w.Body.String())
+		}
+	}
+	return result
+}
@@ -0,0 +1,339 @@
+// Package bench drives load against a running instance with canned
+// scenarios and reports latency percentiles per request kind. It is meant
+// for local and staging instances: scenarios create and delete users.
+package bench
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Options configure a run.
+type Options struct {
+	// BaseURL is the instance to load, e.g. http://localhost:8080.
+	BaseURL string
+	// Token is sent as a bearer token; it needs users:read and users:write.
+	Token string
+	// Rate is the target requests per second. Zero sends as fast as the
+	// workers allow.
+	Rate        int
+	Concurrency int
+	Duration    time.Duration
+}
+
+// Step is one kind of request in a scenario, picked with probability
+// proportional to Weight.
+type Step struct {
+	Name   string
+	Weight int
+	// Build returns the request to send, or nil to skip this pick (for
+	// example an update before any user exists).
+	Build func(s *State) *Request
+	// Done sees the response of a successful request.
+	Done func(s *State, status int, body []byte)
+}
+
+// Request is a request a step wants sent.
+type Request struct {
+	Method string
+	Path   string
+	Body   []byte
+}
+
+// Scenario is a weighted mix of steps.
+type Scenario struct {
+	Name        string
+	Description string
+	Steps       []Step
+}
+
+// State is shared by the steps of a run. It tracks users created during the
+// run so updates and deletes have targets, and so they can be cleaned up.
+type State struct {
+	mu    sync.Mutex
+	rng   *rand.Rand
+	runID string
+	seq   int
+	users []int
+}
+
+// Intn returns a random number in [0, n).
+func (s *State) Intn(n int) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rng.Intn(n)
+}
+
+// NextEmail returns an address unique to this run.
+func (s *State) NextEmail() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seq++
+	return fmt.Sprintf("bench-%s-%d@example.com", s.runID, s.seq)
+}
+
+// AddUser records a user created during the run.
+func (s *State) AddUser(id int) {
+	s.mu.Lock()
+	s.users = append(s.users, id)
+	s.mu.Unlock()
+}
+
+// AnyUser returns a user created during the run, if there is one.
+func (s *State) AnyUser() (int, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.users) == 0 {
+		return 0, false
+	}
+	return s.users[s.rng.Intn(len(s.users))], true
+}
+
+// TakeUser removes and returns a user created during the run, so it is
+// deleted only once.
+func (s *State) TakeUser() (int, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.users) == 0 {
+		return 0, false
+	}
+	i := s.rng.Intn(len(s.users))
+	id := s.users[i]
+	s.users[i] = s.users[len(s.users)-1]
+	s.users = s.users[:len(s.users)-1]
+	return id, true
+}
+
+// StepReport summarizes the requests of one step.
+type StepReport struct {
+	Name     string         `json:"name"`
+	Requests int            `json:"requests"`
+	Errors   int            `json:"errors"`
+	Statuses map[string]int `json:"statuses"`
+	P50      time.Duration  `json:"p50"`
+	P90      time.Duration  `json:"p90"`
+	P99      time.Duration  `json:"p99"`
+	Max      time.Duration  `json:"max"`
+
+	latencies []time.Duration
+}
+
+// Report summarizes a run.
+type Report struct {
+	Scenario   string        `json:"scenario"`
+	Duration   time.Duration `json:"duration"`
+	Requests   int           `json:"requests"`
+	Errors     int           `json:"errors"`
+	Throughput float64       `json:"throughput"`
+	Steps      []*StepReport `json:"steps"`
+	Total      *StepReport   `json:"total"`
+}
+
+// Run sends the scenario's mix until opts.Duration has passed or ctx ends,
+// then deletes the users the run created.
+func Run(ctx context.Context, sc Scenario, opts Options) (*Report, error) {
+	if len(sc.Steps) == 0 {
+		return nil, fmt.Errorf("scenario %s has no steps", sc.Name)
+	}
+	if opts.Concurrency < 1 {
+		opts.Concurrency = 1
+	}
+	r := &runner{
+		opts:   opts,
+		client: &http.Client{Timeout: 30 * time.Second},
+		state:  &State{rng: rand.New(rand.NewSource(time.Now().UnixNano())), runID: fmt.Sprintf("%x", time.Now().UnixNano())},
+		steps:  map[string]*StepReport{},
+	}
+	for _, step := range sc.Steps {
+		r.totalWeight += step.Weight
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, opts.Duration)
+	defer cancel()
+
+	// With a rate, a ticker hands out permits; without one, workers loop.
+	var permits <-chan time.Time
+	if opts.Rate > 0 {
+		ticker := time.NewTicker(time.Second / time.Duration(opts.Rate))
+		defer ticker.Stop()
+		permits = ticker.C
+	}
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < opts.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				if permits != nil {
+					select {
+					case <-permits:
+					case <-ctx.Done():
+						return
+					}
+				} else if ctx.Err() != nil {
+					return
+				}
+				r.once(ctx, r.pick(sc.Steps))
+			}
+		}()
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	r.cleanup()
+	return r.report(sc.Name, elapsed), nil
+}
+
+type runner struct {
+	opts        Options
+	client      *http.Client
+	state       *State
+	totalWeight int
+
+	mu    sync.Mutex
+	steps map[string]*StepReport
+}
+
+func (r *runner) pick(steps []Step) Step {
+	n := r.state.Intn(r.totalWeight)
+	for _, step := range steps {
+		if n < step.Weight {
+			return step
+		}
+		n -= step.Weight
+	}
+	return steps[len(steps)-1]
+}
+
+func (r *runner) once(ctx context.Context, step Step) {
+	req := step.Build(r.state)
+	if req == nil {
+		return
+	}
+	start := time.Now()
+	status, body, err := r.send(ctx, req)
+	latency := time.Since(start)
+	if ctx.Err() != nil {
+		// Cut off by the end of the run; not a server error.
+		return
+	}
+	if err == nil && status < 400 && step.Done != nil {
+		step.Done(r.state, status, body)
+	}
+	r.record(step.Name, status, err, latency)
+}
+
+func (r *runner) send(ctx context.Context, req *Request) (int, []byte, error) {
+	var body io.Reader
+	if req.Body != nil {
+		body = bytes.NewReader(req.Body)
+	}
+	hreq, err := http.NewRequestWithContext(ctx, req.Method, strings.TrimRight(r.opts.BaseURL, "/")+req.Path, body)
+	if err != nil {
+		return 0, nil, err
+	}
+	if req.Body != nil {
+		hreq.Header.Set("Content-Type", "application/json")
+	}
+	if r.opts.Token != "" {
+		hreq.Header.Set("Authorization", "Bearer "+r.opts.Token)
+	}
+	hreq.Header.Set("User-Agent", "pygorp-bench")
+
+	resp, err := r.client.Do(hreq)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	return resp.StatusCode, data, err
+}
+
+func (r *runner) record(name string, status int, err error, latency time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.steps[name]
+	if !ok {
+		s = &StepReport{Name: name, Statuses: map[string]int{}}
+		r.steps[name] = s
+	}
+	s.Requests++
+	s.latencies = append(s.latencies, latency)
+	switch {
+	case err != nil:
+		s.Errors++
+		s.Statuses["error"]++
+	default:
+		if status >= 400 {
+			s.Errors++
+		}
+		s.Statuses[fmt.Sprintf("%dxx", status/100)]++
+	}
+}
+
+// cleanup deletes the users the run created and did not delete itself.
+func (r *runner) cleanup() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	for {
+		id, ok := r.state.TakeUser()
+		if !ok {
+			return
+		}
+		r.send(ctx, &Request{Method: http.MethodDelete, Path: fmt.Sprintf("/api/v1/users/%d", id)})
+	}
+}
+
+func (r *runner) report(scenario string, elapsed time.Duration) *Report {
+	rep := &Report{Scenario: scenario, Duration: elapsed}
+	total := &StepReport{Name: "total", Statuses: map[string]int{}}
+	for _, s := range r.steps {
+		total.Requests += s.Requests
+		total.Errors += s.Errors
+		for k, v := range s.Statuses {
+			total.Statuses[k] += v
+		}
+		total.latencies = append(total.latencies, s.latencies...)
+		s.summarize()
+		rep.Steps = append(rep.Steps, s)
+	}
+	total.summarize()
+	sort.Slice(rep.Steps, func(i, j int) bool { return rep.Steps[i].Name < rep.Steps[j].Name })
+
+	rep.Total = total
+	rep.Requests, rep.Errors = total.Requests, total.Errors
+	if elapsed > 0 {
+		rep.Throughput = float64(total.Requests) / elapsed.Seconds()
+	}
+	return rep
+}
+
+func (s *StepReport) summarize() {
+	if len(s.latencies) == 0 {
+		return
+	}
+	sort.Slice(s.latencies, func(i, j int) bool { return s.latencies[i] < s.latencies[j] })
+	s.P50 = percentile(s.latencies, 50)
+	s.P90 = percentile(s.latencies, 90)
+	s.P99 = percentile(s.latencies, 99)
+	s.Max = s.latencies[len(s.latencies)-1]
+}
+
+// percentile returns the p-th percentile of sorted latencies.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	i := (len(sorted)*p - 1) / 100
+	if i < 0 {
+		i = 0
+	}
+	return sorted[i]
+}
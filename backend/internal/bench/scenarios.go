@@ -0,0 +1,101 @@
+package bench
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+var firstNames = []string{"Ada", "Grace", "Alan", "Edsger", "Barbara", "Ken", "Margaret", "Linus", "Radia", "Dennis"}
+
+var lastNames = []string{"Lovelace", "Hopper", "Turing", "Dijkstra", "Liskov", "Thompson", "Hamilton", "Torvalds", "Perlman", "Ritchie"}
+
+// Scenarios are the canned load mixes, keyed by name.
+var Scenarios = map[string]Scenario{
+	"crud": {
+		Name:        "crud",
+		Description: "Typical admin traffic: mostly reads, with creates, updates, and deletes",
+		Steps: []Step{
+			{Name: "list", Weight: 40, Build: listUsers},
+			{Name: "get", Weight: 25, Build: getUser},
+			{Name: "create", Weight: 15, Build: createUser, Done: userCreated},
+			{Name: "update", Weight: 15, Build: updateUser},
+			{Name: "delete", Weight: 5, Build: deleteUser},
+		},
+	},
+	"search": {
+		Name:        "search",
+		Description: "Typeahead and filtered lists, with a trickle of creates to search for",
+		Steps: []Step{
+			{Name: "suggest", Weight: 50, Build: suggestUsers},
+			{Name: "filter", Weight: 25, Build: filterUsers},
+			{Name: "table", Weight: 20, Build: userTable},
+			{Name: "create", Weight: 5, Build: createUser, Done: userCreated},
+		},
+	},
+}
+
+func listUsers(s *State) *Request {
+	return &Request{Method: http.MethodGet, Path: fmt.Sprintf("/api/v1/users?limit=20&offset=%d", 20*s.Intn(5))}
+}
+
+func getUser(s *State) *Request {
+	id, ok := s.AnyUser()
+	if !ok {
+		return listUsers(s)
+	}
+	return &Request{Method: http.MethodGet, Path: fmt.Sprintf("/api/v1/users/%d", id)}
+}
+
+func createUser(s *State) *Request {
+	body, _ := json.Marshal(map[string]string{
+		"name":  firstNames[s.Intn(len(firstNames))] + " " + lastNames[s.Intn(len(lastNames))],
+		"email": s.NextEmail(),
+	})
+	return &Request{Method: http.MethodPost, Path: "/api/v1/users", Body: body}
+}
+
+func userCreated(s *State, status int, body []byte) {
+	var resp struct {
+		Data struct {
+			ID int `json:"id"`
+		} `json:"data"`
+	}
+	if json.Unmarshal(body, &resp) == nil && resp.Data.ID != 0 {
+		s.AddUser(resp.Data.ID)
+	}
+}
+
+func updateUser(s *State) *Request {
+	id, ok := s.AnyUser()
+	if !ok {
+		return nil
+	}
+	body, _ := json.Marshal(map[string]string{
+		"name": firstNames[s.Intn(len(firstNames))] + " " + lastNames[s.Intn(len(lastNames))],
+	})
+	return &Request{Method: http.MethodPut, Path: fmt.Sprintf("/api/v1/users/%d", id), Body: body}
+}
+
+func deleteUser(s *State) *Request {
+	id, ok := s.TakeUser()
+	if !ok {
+		return nil
+	}
+	return &Request{Method: http.MethodDelete, Path: fmt.Sprintf("/api/v1/users/%d", id)}
+}
+
+func suggestUsers(s *State) *Request {
+	name := firstNames[s.Intn(len(firstNames))]
+	prefix := name[:2+s.Intn(len(name)-1)]
+	return &Request{Method: http.MethodGet, Path: "/api/v1/users/suggest?q=" + url.QueryEscape(prefix)}
+}
+
+func filterUsers(s *State) *Request {
+	return &Request{Method: http.MethodGet, Path: "/api/v1/users?limit=20&name_contains=" + url.QueryEscape(lastNames[s.Intn(len(lastNames))])}
+}
+
+func userTable(s *State) *Request {
+	return &Request{Method: http.MethodGet, Path: "/api/v1/users/table?limit=50&sort=-created_at"}
+}
@@ -0,0 +1,183 @@
+// Package blocklist rejects names containing blocked words. Words come from
+// the blocked_words table, managed through the admin API, and from the file
+// named by BLOCKLIST_FILE.
+package blocklist
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Match types.
+const (
+	// MatchExact blocks a name with the word as one of its words, ignoring
+	// case.
+	MatchExact = "exact"
+	// MatchNormalized blocks a name containing the word anywhere once both
+	// are normalized: case, accents, common digit and symbol substitutions,
+	// and everything but letters and digits are ignored, so "B.a.d",
+	// "bäd", and "b4d" all match "bad".
+	MatchNormalized = "normalized"
+)
+
+// Entry is one blocked word.
+type Entry struct {
+	Word      string    `json:"word"`
+	Match     string    `json:"match"`
+	Source    string    `json:"source"`
+	CreatedAt time.Time `json:"created_at,omitempty"`
+}
+
+// Check rejects entries that could never match or would match everything.
+func (e *Entry) Check() error {
+	e.Word = Canonical(e.Word)
+	if e.Match == "" {
+		e.Match = MatchNormalized
+	}
+	switch e.Match {
+	case MatchExact:
+		if len(words(e.Word)) != 1 {
+			return fmt.Errorf("exact words must be a single word")
+		}
+	case MatchNormalized:
+		if Normalize(e.Word) == "" {
+			return fmt.Errorf("word must contain a letter or digit")
+		}
+	default:
+		return fmt.Errorf("match must be exact or normalized")
+	}
+	return nil
+}
+
+// Canonical returns the form a word is stored and looked up in.
+func Canonical(word string) string {
+	return strings.ToLower(strings.TrimSpace(norm.NFC.String(word)))
+}
+
+// Error reports a blocked name. Field names the checked value.
+type Error struct {
+	Field string
+	Entry *Entry
+}
+
+func (e *Error) Error() string {
+	return e.Field + " contains a blocked word"
+}
+
+// List is a set of blocked words.
+type List []*Entry
+
+// Check returns an *Error if s contains a word on the list.
+func (l List) Check(field, s string) error {
+	if len(l) == 0 || s == "" {
+		return nil
+	}
+	normalized := Normalize(s)
+	tokens := map[string]bool{}
+	for _, w := range words(strings.ToLower(norm.NFC.String(s))) {
+		tokens[w] = true
+	}
+
+	for _, e := range l {
+		switch e.Match {
+		case MatchExact:
+			if tokens[e.Word] {
+				return &Error{Field: field, Entry: e}
+			}
+		default:
+			if w := Normalize(e.Word); w != "" && strings.Contains(normalized, w) {
+				return &Error{Field: field, Entry: e}
+			}
+		}
+	}
+	return nil
+}
+
+var substitutions = map[rune]rune{
+	'0': 'o', '1': 'i', '3': 'e', '4': 'a', '5': 's', '7': 't', '8': 'b',
+	'@': 'a', '$': 's', '!': 'i', '|': 'l', '+': 't',
+}
+
+// Normalize reduces s to lowercase letters and digits for normalized
+// matching: accents are removed and common lookalike substitutions undone.
+func Normalize(s string) string {
+	var b strings.Builder
+	for _, r := range norm.NFKD.String(s) {
+		if sub, ok := substitutions[r]; ok {
+			r = sub
+		}
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(unicode.ToLower(r))
+		}
+	}
+	return b.String()
+}
+
+func words(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+var file struct {
+	sync.Mutex
+	path    string
+	modTime time.Time
+	list    List
+}
+
+// File returns the words in BLOCKLIST_FILE, rereading it when it changes.
+// The file has one word per line; a line starting with "=" is an exact
+// word, and blank lines and lines starting with "#" are ignored.
+func File() (List, error) {
+	path := os.Getenv("BLOCKLIST_FILE")
+	if path == "" {
+		return nil, nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	file.Lock()
+	defer file.Unlock()
+	if file.path == path && file.modTime.Equal(info.ModTime()) {
+		return file.list, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var list List
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		e := &Entry{Word: line, Match: MatchNormalized, Source: "file"}
+		if strings.HasPrefix(line, "=") {
+			e.Word, e.Match = line[1:], MatchExact
+		}
+		if err := e.Check(); err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, n, err)
+		}
+		list = append(list, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	file.path, file.modTime, file.list = path, info.ModTime(), list
+	return list, nil
+}
@@ -0,0 +1,525 @@
+package bootstrap
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+
+	"pygorp/backend/internal/auth"
+	"pygorp/backend/internal/database"
+	"pygorp/backend/internal/events"
+
+	"github.com/lib/pq"
+)
+
+// Kinds of resources tracked in bootstrap_resources.
+const (
+	kindOrg            = "org"
+	kindRole           = "role"
+	kindRolePolicy     = "role_policy"
+	kindServiceAccount = "service_account"
+	kindAPIKey         = "api_key"
+)
+
+// Change is one difference applied to the database.
+type Change struct {
+	Action string `json:"action"`
+	Kind   string `json:"kind"`
+	Name   string `json:"name"`
+}
+
+func (c Change) String() string {
+	symbol := map[string]string{"create": "+", "update": "~", "delete": "-"}[c.Action]
+	return symbol + " " + c.Kind + " " + c.Name
+}
+
+// Result lists the changes made and any API keys generated, keyed by
+// "<service account>/<key name>". Generated keys are only shown once.
+type Result struct {
+	Changes       []Change
+	GeneratedKeys map[string]string
+}
+
+type applier struct {
+	ctx     context.Context
+	tx      *sql.Tx
+	result  *Result
+	created []events.UserCreatedV1
+
+	orgIDs  map[string]int
+	roleIDs map[string]int
+}
+
+// Apply reconciles the database with spec in one transaction. With dryRun
+// the transaction is rolled back, so the result is only a plan.
+func Apply(ctx context.Context, spec *Spec, dryRun bool) (*Result, error) {
+	tx, err := database.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	a := &applier{
+		ctx:     ctx,
+		tx:      tx,
+		result:  &Result{GeneratedKeys: map[string]string{}},
+		orgIDs:  map[string]int{},
+		roleIDs: map[string]int{},
+	}
+	steps := []func(*Spec) error{a.applyOrgs, a.applyRoles, a.applyUsers, a.applyServiceAccounts, a.applyFeatureFlags}
+	for _, step := range steps {
+		if err := step(spec); err != nil {
+			return nil, err
+		}
+	}
+
+	if dryRun {
+		return a.result, nil
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	for _, u := range a.created {
+		events.Publish(ctx, events.UserCreated, 1, u)
+	}
+	return a.result, nil
+}
+
+func (a *applier) change(action, kind, name string) {
+	a.result.Changes = append(a.result.Changes, Change{Action: action, Kind: kind, Name: name})
+}
+
+// managed returns the tracked resources of a kind, by name.
+func (a *applier) managed(kind string) (map[string]int, error) {
+	rows, err := a.tx.QueryContext(a.ctx, "SELECT name, resource_id FROM bootstrap_resources WHERE kind = $1", kind)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := map[string]int{}
+	for rows.Next() {
+		var name string
+		var id int
+		if err := rows.Scan(&name, &id); err != nil {
+			return nil, err
+		}
+		ids[name] = id
+	}
+	return ids, rows.Err()
+}
+
+func (a *applier) track(kind, name string, id int) error {
+	_, err := a.tx.ExecContext(a.ctx, `
+		INSERT INTO bootstrap_resources (kind, name, resource_id) VALUES ($1, $2, $3)
+		ON CONFLICT (kind, name) DO UPDATE SET resource_id = EXCLUDED.resource_id`,
+		kind, name, id)
+	return err
+}
+
+func (a *applier) untrack(kind, name string) error {
+	_, err := a.tx.ExecContext(a.ctx, "DELETE FROM bootstrap_resources WHERE kind = $1 AND name = $2", kind, name)
+	return err
+}
+
+// exec runs a statement and returns the number of affected rows.
+func (a *applier) exec(query string, args ...interface{}) (int64, error) {
+	result, err := a.tx.ExecContext(a.ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (a *applier) applyOrgs(spec *Spec) error {
+	managed, err := a.managed(kindOrg)
+	if err != nil {
+		return err
+	}
+
+	for _, o := range spec.Orgs {
+		a.orgIDs[o.Name] = o.ID
+		var name string
+		err := a.tx.QueryRowContext(a.ctx, "SELECT name FROM orgs WHERE id = $1", o.ID).Scan(&name)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			if _, err := a.exec("INSERT INTO orgs (id, name) VALUES ($1, $2)", o.ID, o.Name); err != nil {
+				return fmt.Errorf("org %s: %v", o.Name, err)
+			}
+			a.change("create", kindOrg, o.Name)
+		case err != nil:
+			return err
+		case name != o.Name:
+			if _, err := a.exec("UPDATE orgs SET name = $1, updated_at = NOW() WHERE id = $2", o.Name, o.ID); err != nil {
+				return fmt.Errorf("org %s: %v", o.Name, err)
+			}
+			a.change("update", kindOrg, o.Name)
+		}
+		key := strconv.Itoa(o.ID)
+		delete(managed, key)
+		if err := a.track(kindOrg, key, o.ID); err != nil {
+			return err
+		}
+	}
+
+	for key, id := range managed {
+		if _, err := a.exec("DELETE FROM orgs WHERE id = $1", id); err != nil {
+			return err
+		}
+		if err := a.untrack(kindOrg, key); err != nil {
+			return err
+		}
+		a.change("delete", kindOrg, key)
+	}
+	return nil
+}
+
+// applyRoles makes each role a group with exactly the declared policies.
+// An existing group with the role's name is adopted.
+func (a *applier) applyRoles(spec *Spec) error {
+	managed, err := a.managed(kindRole)
+	if err != nil {
+		return err
+	}
+	policies, err := a.managed(kindRolePolicy)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range spec.Roles {
+		var (
+			id          int
+			description string
+		)
+		err := a.tx.QueryRowContext(a.ctx, "SELECT id, description FROM groups WHERE name = $1", r.Name).Scan(&id, &description)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			err := a.tx.QueryRowContext(a.ctx, "INSERT INTO groups (name, description) VALUES ($1, $2) RETURNING id", r.Name, r.Description).Scan(&id)
+			if err != nil {
+				return fmt.Errorf("role %s: %v", r.Name, err)
+			}
+			a.change("create", kindRole, r.Name)
+		case err != nil:
+			return err
+		case description != r.Description:
+			if _, err := a.exec("UPDATE groups SET description = $1, updated_at = NOW() WHERE id = $2", r.Description, id); err != nil {
+				return err
+			}
+			a.change("update", kindRole, r.Name)
+		}
+		a.roleIDs[r.Name] = id
+		delete(managed, r.Name)
+		if err := a.track(kindRole, r.Name, id); err != nil {
+			return err
+		}
+
+		for i, rp := range r.Policies {
+			key := r.Name + "#" + strconv.Itoa(i+1)
+			if err := a.applyRolePolicy(key, id, rp, policies); err != nil {
+				return err
+			}
+			delete(policies, key)
+		}
+	}
+
+	// Policies left over belong to removed roles or to policies removed
+	// from a role.
+	for key, id := range policies {
+		if _, err := a.exec("DELETE FROM policies WHERE id = $1", id); err != nil {
+			return err
+		}
+		if err := a.untrack(kindRolePolicy, key); err != nil {
+			return err
+		}
+		a.change("delete", kindRolePolicy, key)
+	}
+	for name, id := range managed {
+		if _, err := a.exec("DELETE FROM groups WHERE id = $1", id); err != nil {
+			return err
+		}
+		if err := a.untrack(kindRole, name); err != nil {
+			return err
+		}
+		a.change("delete", kindRole, name)
+	}
+	return nil
+}
+
+func (a *applier) applyRolePolicy(key string, groupID int, rp RolePolicy, managed map[string]int) error {
+	subjects := []string{"group:" + strconv.Itoa(groupID)}
+	if id, ok := managed[key]; ok {
+		n, err := a.exec(`
+			UPDATE policies SET effect = $1, subjects = $2, actions = $3, resources = $4, description = $5, updated_at = NOW()
+			WHERE id = $6 AND (effect, subjects, actions, resources, description) IS DISTINCT FROM ($1, $2, $3, $4, $5)`,
+			rp.Effect, pq.Array(subjects), pq.Array(rp.Actions), pq.Array(rp.Resources), rp.Description, id)
+		if err != nil {
+			return fmt.Errorf("role policy %s: %v", key, err)
+		}
+		if n > 0 {
+			a.change("update", kindRolePolicy, key)
+		}
+		return nil
+	}
+
+	var id int
+	err := a.tx.QueryRowContext(a.ctx, `
+		INSERT INTO policies (effect, subjects, actions, resources, description)
+		VALUES ($1, $2, $3, $4, $5) RETURNING id`,
+		rp.Effect, pq.Array(subjects), pq.Array(rp.Actions), pq.Array(rp.Resources), rp.Description).Scan(&id)
+	if err != nil {
+		return fmt.Errorf("role policy %s: %v", key, err)
+	}
+	a.change("create", kindRolePolicy, key)
+	return a.track(kindRolePolicy, key, id)
+}
+
+// applyUsers creates or updates users and sets each role's members to the
+// users that list it.
+func (a *applier) applyUsers(spec *Spec) error {
+	members := map[string][]int{}
+	for _, u := range spec.Users {
+		var orgID *int
+		if u.Org != "" {
+			id := a.orgIDs[u.Org]
+			orgID = &id
+		}
+
+		var (
+			id      int
+			name    string
+			current sql.NullInt64
+		)
+		err := a.tx.QueryRowContext(a.ctx, "SELECT id, name, org_id FROM users WHERE email = $1", u.Email).Scan(&id, &name, &current)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			err := a.tx.QueryRowContext(a.ctx, "INSERT INTO users (email, name, org_id) VALUES ($1, $2, $3) RETURNING id", u.Email, u.Name, orgID).Scan(&id)
+			if err != nil {
+				return fmt.Errorf("user %s: %v", u.Email, err)
+			}
+			a.created = append(a.created, events.UserCreatedV1{ID: id, Email: u.Email, Name: u.Name})
+			a.change("create", "user", u.Email)
+		case err != nil:
+			return err
+		case name != u.Name || (orgID != nil && (!current.Valid || int(current.Int64) != *orgID)):
+			if _, err := a.exec("UPDATE users SET name = $1, org_id = COALESCE($2, org_id) WHERE id = $3", u.Name, orgID, id); err != nil {
+				return fmt.Errorf("user %s: %v", u.Email, err)
+			}
+			a.change("update", "user", u.Email)
+		}
+
+		for _, role := range u.Roles {
+			members[role] = append(members[role], id)
+		}
+	}
+
+	roles := make([]string, 0, len(a.roleIDs))
+	for role := range a.roleIDs {
+		roles = append(roles, role)
+	}
+	sort.Strings(roles)
+	for _, role := range roles {
+		// A non-nil slice, so a role without members is emptied.
+		ids := append([]int{}, members[role]...)
+		removed, err := a.exec("DELETE FROM group_members WHERE group_id = $1 AND NOT (user_id = ANY($2))", a.roleIDs[role], pq.Array(ids))
+		if err != nil {
+			return err
+		}
+		added, err := a.exec(`
+			INSERT INTO group_members (group_id, user_id) SELECT $1, unnest($2::int[])
+			ON CONFLICT DO NOTHING`, a.roleIDs[role], pq.Array(ids))
+		if err != nil {
+			return err
+		}
+		if added > 0 || removed > 0 {
+			a.change("update", "role members", fmt.Sprintf("%s (+%d -%d)", role, added, removed))
+		}
+	}
+	return nil
+}
+
+func (a *applier) applyServiceAccounts(spec *Spec) error {
+	managed, err := a.managed(kindServiceAccount)
+	if err != nil {
+		return err
+	}
+	keys, err := a.managed(kindAPIKey)
+	if err != nil {
+		return err
+	}
+
+	for _, sa := range spec.ServiceAccounts {
+		var (
+			id          int
+			description string
+			scopes      []string
+			disabled    bool
+		)
+		err := a.tx.QueryRowContext(a.ctx, "SELECT id, description, scopes, disabled_at IS NOT NULL FROM service_accounts WHERE name = $1", sa.Name).
+			Scan(&id, &description, pq.Array(&scopes), &disabled)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			err := a.tx.QueryRowContext(a.ctx, `
+				INSERT INTO service_accounts (name, description, scopes, disabled_at)
+				VALUES ($1, $2, $3, CASE WHEN $4 THEN NOW() END) RETURNING id`,
+				sa.Name, sa.Description, pq.Array(sa.Scopes), sa.Disabled).Scan(&id)
+			if err != nil {
+				return fmt.Errorf("service account %s: %v", sa.Name, err)
+			}
+			a.change("create", kindServiceAccount, sa.Name)
+		case err != nil:
+			return err
+		case description != sa.Description || !slices.Equal(scopes, sa.Scopes) || disabled != sa.Disabled:
+			_, err := a.exec(`
+				UPDATE service_accounts SET description = $1, scopes = $2,
+					disabled_at = CASE WHEN $3 THEN COALESCE(disabled_at, NOW()) END, updated_at = NOW()
+				WHERE id = $4`,
+				sa.Description, pq.Array(sa.Scopes), sa.Disabled, id)
+			if err != nil {
+				return fmt.Errorf("service account %s: %v", sa.Name, err)
+			}
+			a.change("update", kindServiceAccount, sa.Name)
+		}
+		delete(managed, sa.Name)
+		if err := a.track(kindServiceAccount, sa.Name, id); err != nil {
+			return err
+		}
+
+		for _, k := range sa.APIKeys {
+			name := sa.Name + "/" + k.Name
+			if err := a.applyAPIKey(name, id, k, keys); err != nil {
+				return err
+			}
+			delete(keys, name)
+		}
+	}
+
+	for name, id := range keys {
+		if _, err := a.exec("UPDATE service_account_keys SET revoked_at = NOW() WHERE id = $1 AND revoked_at IS NULL", id); err != nil {
+			return err
+		}
+		if err := a.untrack(kindAPIKey, name); err != nil {
+			return err
+		}
+		a.change("delete", kindAPIKey, name)
+	}
+	for name, id := range managed {
+		if _, err := a.exec("DELETE FROM service_accounts WHERE id = $1", id); err != nil {
+			return err
+		}
+		if err := a.untrack(kindServiceAccount, name); err != nil {
+			return err
+		}
+		a.change("delete", kindServiceAccount, name)
+	}
+	return nil
+}
+
+// applyAPIKey keeps a managed key while it is live and, for keys read from
+// the environment, still matches. Otherwise the old key is revoked and a
+// new one stored.
+func (a *applier) applyAPIKey(name string, accountID int, k APIKey, managed map[string]int) error {
+	var key, prefix, hash string
+	if k.KeyEnv != "" {
+		key = os.Getenv(k.KeyEnv)
+		var ok bool
+		if prefix, hash, ok = auth.ParseAPIKey(key); !ok {
+			return fmt.Errorf("API key %s: %s must hold a key starting with %s", name, k.KeyEnv, auth.APIKeyPrefix)
+		}
+	}
+
+	action := "create"
+	if id, ok := managed[name]; ok {
+		var storedHash string
+		var live bool
+		err := a.tx.QueryRowContext(a.ctx, `
+			SELECT key_hash, revoked_at IS NULL AND (expires_at IS NULL OR expires_at > NOW())
+			FROM service_account_keys WHERE id = $1`, id).Scan(&storedHash, &live)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		if err == nil && live && (k.KeyEnv == "" || auth.HashesMatch(storedHash, hash)) {
+			return nil
+		}
+		if _, err := a.exec("UPDATE service_account_keys SET revoked_at = NOW() WHERE id = $1 AND revoked_at IS NULL", id); err != nil {
+			return err
+		}
+		action = "update"
+	}
+
+	if k.KeyEnv == "" {
+		key, prefix, hash = auth.NewAPIKey()
+		a.result.GeneratedKeys[name] = key
+	}
+	var id int
+	err := a.tx.QueryRowContext(a.ctx, `
+		INSERT INTO service_account_keys (service_account_id, name, prefix, key_hash)
+		VALUES ($1, $2, $3, $4) RETURNING id`,
+		accountID, k.Name, prefix, hash).Scan(&id)
+	if err != nil {
+		return fmt.Errorf("API key %s: %v", name, err)
+	}
+	a.change(action, kindAPIKey, name)
+	return a.track(kindAPIKey, name, id)
+}
+
+// applyFeatureFlags makes the feature_flags table hold exactly the declared
+// flags.
+func (a *applier) applyFeatureFlags(spec *Spec) error {
+	rows, err := a.tx.QueryContext(a.ctx, "SELECT name, enabled FROM feature_flags")
+	if err != nil {
+		return err
+	}
+	current := map[string]bool{}
+	for rows.Next() {
+		var name string
+		var enabled bool
+		if err := rows.Scan(&name, &enabled); err != nil {
+			rows.Close()
+			return err
+		}
+		current[name] = enabled
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(spec.FeatureFlags))
+	for name := range spec.FeatureFlags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		enabled := spec.FeatureFlags[name]
+		prev, ok := current[name]
+		delete(current, name)
+		if ok && prev == enabled {
+			continue
+		}
+		_, err := a.exec(`
+			INSERT INTO feature_flags (name, enabled) VALUES ($1, $2)
+			ON CONFLICT (name) DO UPDATE SET enabled = EXCLUDED.enabled, updated_at = NOW()`,
+			name, enabled)
+		if err != nil {
+			return err
+		}
+		action := "update"
+		if !ok {
+			action = "create"
+		}
+		a.change(action, "feature flag", fmt.Sprintf("%s = %t", name, enabled))
+	}
+
+	for name := range current {
+		if _, err := a.exec("DELETE FROM feature_flags WHERE name = $1", name); err != nil {
+			return err
+		}
+		a.change("delete", "feature flag", name)
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package bootstrap
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"pygorp/backend/internal/database"
+
+	"github.com/lib/pq"
+)
+
+// StoredFeatureFlags reads the feature flags set by apply. It returns none
+// when the feature_flags migration has not been applied yet.
+func StoredFeatureFlags() (map[string]bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rows, err := database.DB.QueryContext(ctx, "SELECT name, enabled FROM feature_flags")
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "42P01" {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	flags := map[string]bool{}
+	for rows.Next() {
+		var name string
+		var enabled bool
+		if err := rows.Scan(&name, &enabled); err != nil {
+			return nil, err
+		}
+		flags[name] = enabled
+	}
+	return flags, rows.Err()
+}
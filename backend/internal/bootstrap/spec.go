@@ -0,0 +1,166 @@
+// Package bootstrap reconciles the database with a declarative bootstrap
+// file of orgs, roles, users, service accounts, and feature flags.
+package bootstrap
+
+import (
+	"bytes"
+	"fmt"
+	"net/mail"
+	"os"
+
+	"pygorp/backend/internal/policy"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Spec is the content of a bootstrap file.
+type Spec struct {
+	Orgs            []Org            `yaml:"orgs"`
+	Roles           []Role           `yaml:"roles"`
+	Users           []User           `yaml:"users"`
+	ServiceAccounts []ServiceAccount `yaml:"service_accounts"`
+	FeatureFlags    map[string]bool  `yaml:"feature_flags"`
+}
+
+// Org is an organization. IDs are explicit so users.org_id stays stable
+// across environments.
+type Org struct {
+	ID   int    `yaml:"id"`
+	Name string `yaml:"name"`
+}
+
+// Role is a group whose policies are managed by the file. Its members are
+// exactly the users that list it.
+type Role struct {
+	Name        string       `yaml:"name"`
+	Description string       `yaml:"description"`
+	Policies    []RolePolicy `yaml:"policies"`
+}
+
+// RolePolicy is a policy whose subject is the role's group.
+type RolePolicy struct {
+	Effect      string   `yaml:"effect"`
+	Actions     []string `yaml:"actions"`
+	Resources   []string `yaml:"resources"`
+	Description string   `yaml:"description"`
+}
+
+// User is created when no user has the email, and otherwise updated. Users
+// are never deleted by apply.
+type User struct {
+	Email string   `yaml:"email"`
+	Name  string   `yaml:"name"`
+	Org   string   `yaml:"org"`
+	Roles []string `yaml:"roles"`
+}
+
+type ServiceAccount struct {
+	Name        string   `yaml:"name"`
+	Description string   `yaml:"description"`
+	Scopes      []string `yaml:"scopes"`
+	Disabled    bool     `yaml:"disabled"`
+	APIKeys     []APIKey `yaml:"api_keys"`
+}
+
+// APIKey is a service account key. KeyEnv names an environment variable
+// holding the key, so the same key can be applied everywhere; without it a
+// key is generated once and printed.
+type APIKey struct {
+	Name   string `yaml:"name"`
+	KeyEnv string `yaml:"key_env"`
+}
+
+// Load reads and validates a bootstrap file. Unknown fields are rejected so
+// typos do not silently drop resources.
+func Load(path string) (*Spec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bootstrap file: %v", err)
+	}
+
+	var spec Spec
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&spec); err != nil {
+		return nil, fmt.Errorf("failed to parse bootstrap file: %v", err)
+	}
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+	return &spec, nil
+}
+
+// Validate checks references and uniqueness within the file.
+func (s *Spec) Validate() error {
+	orgIDs, orgNames := map[int]bool{}, map[string]bool{}
+	for _, o := range s.Orgs {
+		if o.ID < 1 || o.Name == "" {
+			return fmt.Errorf("orgs need a positive id and a name")
+		}
+		if orgIDs[o.ID] || orgNames[o.Name] {
+			return fmt.Errorf("org %d (%s) is declared twice", o.ID, o.Name)
+		}
+		orgIDs[o.ID], orgNames[o.Name] = true, true
+	}
+
+	roles := map[string]bool{}
+	for _, r := range s.Roles {
+		if len(r.Name) < 2 || len(r.Name) > 100 {
+			return fmt.Errorf("role name %q must be 2 to 100 characters", r.Name)
+		}
+		if roles[r.Name] {
+			return fmt.Errorf("role %s is declared twice", r.Name)
+		}
+		roles[r.Name] = true
+		for i, rp := range r.Policies {
+			p := policy.Policy{Effect: rp.Effect, Subjects: []string{"group:0"}, Actions: rp.Actions, Resources: rp.Resources}
+			if err := p.Check(); err != nil {
+				return fmt.Errorf("role %s policy %d: %v", r.Name, i+1, err)
+			}
+		}
+	}
+
+	emails := map[string]bool{}
+	for _, u := range s.Users {
+		if _, err := mail.ParseAddress(u.Email); err != nil {
+			return fmt.Errorf("user %q: invalid email", u.Email)
+		}
+		if len(u.Name) < 2 || len(u.Name) > 100 {
+			return fmt.Errorf("user %s: name must be 2 to 100 characters", u.Email)
+		}
+		if emails[u.Email] {
+			return fmt.Errorf("user %s is declared twice", u.Email)
+		}
+		emails[u.Email] = true
+		if u.Org != "" && !orgNames[u.Org] {
+			return fmt.Errorf("user %s: unknown org %q", u.Email, u.Org)
+		}
+		for _, role := range u.Roles {
+			if !roles[role] {
+				return fmt.Errorf("user %s: unknown role %q", u.Email, role)
+			}
+		}
+	}
+
+	accounts := map[string]bool{}
+	for _, a := range s.ServiceAccounts {
+		if len(a.Name) < 2 || len(a.Name) > 100 {
+			return fmt.Errorf("service account name %q must be 2 to 100 characters", a.Name)
+		}
+		if accounts[a.Name] {
+			return fmt.Errorf("service account %s is declared twice", a.Name)
+		}
+		accounts[a.Name] = true
+		if len(a.Scopes) == 0 {
+			return fmt.Errorf("service account %s needs at least one scope", a.Name)
+		}
+		keys := map[string]bool{}
+		for _, k := range a.APIKeys {
+			if k.Name == "" || keys[k.Name] {
+				return fmt.Errorf("service account %s: API keys need unique names", a.Name)
+			}
+			keys[k.Name] = true
+		}
+	}
+	return nil
+}
@@ -0,0 +1,445 @@
+// Package campaigns emails every user in a segment. Creating a campaign
+// queues a start job, which matches the segment once into
+// campaign_recipients. A dispatch job then enqueues one send job per
+// recipient, at most the campaign's rate each minute and spread across the
+// minute, and reschedules itself until no recipients are left.
+package campaigns
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strconv"
+	"time"
+
+	"pygorp/backend/internal/clock"
+	"pygorp/backend/internal/database"
+	"pygorp/backend/internal/jobs"
+	"pygorp/backend/internal/mailer"
+	"pygorp/backend/internal/models"
+	"pygorp/backend/internal/repository"
+	"pygorp/backend/internal/segments"
+	"pygorp/backend/internal/sqlb"
+	"pygorp/backend/internal/templates"
+)
+
+// Job types.
+const (
+	StartJob    = "campaigns.start"
+	DispatchJob = "campaigns.dispatch"
+	SendJob     = "campaigns.send"
+)
+
+var (
+	// ErrNotFound is returned when a campaign does not exist.
+	ErrNotFound = errors.New("campaign not found")
+	// ErrFinished is returned when cancelling a campaign that has already
+	// completed, failed, or been cancelled.
+	ErrFinished = errors.New("campaign has already finished")
+)
+
+const campaignColumns = "id, name, segment, template, data, rate_per_minute, status, error, created_at, started_at, finished_at"
+
+// selectCampaigns reads campaigns with their recipients counted by status.
+const selectCampaigns = `
+	SELECT c.id, c.name, c.segment, c.template, c.data, c.rate_per_minute, c.status, c.error, c.created_at, c.started_at, c.finished_at,
+		p.total, p.pending, p.queued, p.sent, p.failed, p.skipped, p.cancelled
+	FROM campaigns c
+	CROSS JOIN LATERAL (
+		SELECT COUNT(*) AS total,
+			COUNT(*) FILTER (WHERE status = 'pending') AS pending,
+			COUNT(*) FILTER (WHERE status = 'queued') AS queued,
+			COUNT(*) FILTER (WHERE status = 'sent') AS sent,
+			COUNT(*) FILTER (WHERE status = 'failed') AS failed,
+			COUNT(*) FILTER (WHERE status = 'skipped') AS skipped,
+			COUNT(*) FILTER (WHERE status = 'cancelled') AS cancelled
+		FROM campaign_recipients WHERE campaign_id = c.id
+	) p`
+
+func init() {
+	database.UseColumns("campaigns", campaignColumns)
+	database.UseColumns("campaign_recipients", "campaign_id, user_id, status, error, updated_at")
+	jobs.Register(StartJob, start)
+	jobs.Register(DispatchJob, dispatch)
+	jobs.Register(SendJob, send)
+}
+
+type campaignPayload struct {
+	CampaignID int `json:"campaign_id"`
+}
+
+type sendPayload struct {
+	CampaignID int `json:"campaign_id"`
+	UserID     int `json:"user_id"`
+}
+
+// DefaultRate is how many emails a campaign sends a minute unless it says
+// otherwise, read from CAMPAIGN_RATE_PER_MINUTE and defaulting to 60.
+func DefaultRate() int {
+	if n, err := strconv.Atoi(os.Getenv("CAMPAIGN_RATE_PER_MINUTE")); err == nil && n > 0 {
+		return n
+	}
+	return 60
+}
+
+// Validate checks that a campaign's segment compiles and its template
+// renders, so a campaign never starts only to fail on every recipient.
+func Validate(req models.CreateCampaignRequest) error {
+	if _, err := segments.Compile(req.Segment, clock.Now()); err != nil {
+		return fmt.Errorf("invalid segment: %v", err)
+	}
+	if !slices.Contains(templates.Campaigns, req.Template) {
+		return fmt.Errorf("template must be one of %v", templates.Campaigns)
+	}
+	if req.Template == templates.Announcement && (req.Subject == "" || req.Message == "") {
+		return errors.New("announcements need a subject and a message")
+	}
+	data := emailData(models.CampaignData{Subject: req.Subject, Message: req.Message, Link: req.Link}, models.User{Name: "Jane Doe"})
+	if _, err := templates.Render(req.Template, data); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Create records a campaign and queues its start. Validate it first.
+func Create(ctx context.Context, req models.CreateCampaignRequest) (models.Campaign, error) {
+	rate := req.RatePerMinute
+	if rate == 0 {
+		rate = DefaultRate()
+	}
+	data, err := json.Marshal(models.CampaignData{Subject: req.Subject, Message: req.Message, Link: req.Link})
+	if err != nil {
+		return models.Campaign{}, err
+	}
+
+	tx, err := database.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return models.Campaign{}, err
+	}
+	defer tx.Rollback()
+
+	var id int
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO campaigns (name, segment, template, data, rate_per_minute, status, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id`,
+		req.Name, req.Segment, req.Template, data, rate, models.CampaignPending, clock.Now()).Scan(&id)
+	if err != nil {
+		return models.Campaign{}, err
+	}
+	if _, err := jobs.EnqueueWith(ctx, tx, jobs.DefaultQueue, StartJob, campaignPayload{CampaignID: id}); err != nil {
+		return models.Campaign{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return models.Campaign{}, err
+	}
+	return Get(ctx, id)
+}
+
+// Get returns a campaign with its progress.
+func Get(ctx context.Context, id int) (models.Campaign, error) {
+	c, err := scanCampaign(database.DB.QueryRowContext(ctx, selectCampaigns+" WHERE c.id = $1", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return c, ErrNotFound
+	}
+	return c, err
+}
+
+// List returns a page of campaigns, newest first.
+func List(ctx context.Context, page sqlb.Page) ([]models.Campaign, error) {
+	rows, err := database.DB.QueryContext(ctx, selectCampaigns+" ORDER BY c.id DESC LIMIT $1 OFFSET $2", page.Limit, page.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []models.Campaign{}
+	for rows.Next() {
+		c, err := scanCampaign(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, c)
+	}
+	return list, rows.Err()
+}
+
+// Cancel stops a pending or running campaign. Recipients not yet emailed
+// are marked cancelled, and send jobs already queued for them do nothing.
+func Cancel(ctx context.Context, id int) (models.Campaign, error) {
+	tx, err := database.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return models.Campaign{}, err
+	}
+	defer tx.Rollback()
+
+	now := clock.Now()
+	var status string
+	err = tx.QueryRowContext(ctx, "SELECT status FROM campaigns WHERE id = $1 FOR UPDATE", id).Scan(&status)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Campaign{}, ErrNotFound
+	}
+	if err != nil {
+		return models.Campaign{}, err
+	}
+	if status != models.CampaignPending && status != models.CampaignRunning {
+		return models.Campaign{}, ErrFinished
+	}
+	if _, err := tx.ExecContext(ctx,
+		"UPDATE campaigns SET status = $2, finished_at = $3 WHERE id = $1",
+		id, models.CampaignCancelled, now); err != nil {
+		return models.Campaign{}, err
+	}
+	if _, err := tx.ExecContext(ctx,
+		"UPDATE campaign_recipients SET status = $2, updated_at = $3 WHERE campaign_id = $1 AND status IN ($4, $5)",
+		id, models.RecipientCancelled, now, models.RecipientPending, models.RecipientQueued); err != nil {
+		return models.Campaign{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return models.Campaign{}, err
+	}
+	return Get(ctx, id)
+}
+
+func scanCampaign(row interface{ Scan(...interface{}) error }) (models.Campaign, error) {
+	var c models.Campaign
+	var data []byte
+	p := &c.Progress
+	err := row.Scan(&c.ID, &c.Name, &c.Segment, &c.Template, &data, &c.RatePerMinute, &c.Status, &c.Error, &c.CreatedAt, &c.StartedAt, &c.FinishedAt,
+		&p.Total, &p.Pending, &p.Queued, &p.Sent, &p.Failed, &p.Skipped, &p.Cancelled)
+	if err != nil {
+		return c, err
+	}
+	return c, json.Unmarshal(data, &c.Data)
+}
+
+// start matches the segment into recipients and begins dispatching. The
+// segment is compiled as of the campaign's creation, so relative dates
+// mean what they did when it was created.
+func start(ctx context.Context, job *jobs.Job) error {
+	var p campaignPayload
+	if err := json.Unmarshal(job.Payload, &p); err != nil {
+		return fmt.Errorf("invalid campaign payload: %v", err)
+	}
+	c, err := Get(ctx, p.CampaignID)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil || c.Status != models.CampaignPending {
+		return err
+	}
+
+	where, err := segments.Compile(c.Segment, c.CreatedAt)
+	if err != nil {
+		_, err = database.DB.ExecContext(ctx,
+			"UPDATE campaigns SET status = $2, error = $3, finished_at = $4 WHERE id = $1 AND status = $5",
+			c.ID, models.CampaignFailed, "Invalid segment: "+err.Error(), clock.Now(), models.CampaignPending)
+		return err
+	}
+	// The campaign ID is an integer, so it is safe to put in the statement.
+	query, args, err := sqlb.Select(strconv.Itoa(c.ID), "id").From("users").WhereExpr(where).Build()
+	if err != nil {
+		return err
+	}
+
+	tx, err := database.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Locking the campaign makes a concurrent cancel wait, or be seen.
+	var status string
+	if err := tx.QueryRowContext(ctx, "SELECT status FROM campaigns WHERE id = $1 FOR UPDATE", c.ID).Scan(&status); err != nil || status != models.CampaignPending {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "INSERT INTO campaign_recipients (campaign_id, user_id) "+query+" ON CONFLICT DO NOTHING", args...); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx,
+		"UPDATE campaigns SET status = $2, started_at = $3 WHERE id = $1",
+		c.ID, models.CampaignRunning, clock.Now()); err != nil {
+		return err
+	}
+	if _, err := jobs.EnqueueWith(ctx, tx, jobs.DefaultQueue, DispatchJob, campaignPayload{CampaignID: c.ID}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// dispatch queues send jobs for the next minute's worth of recipients,
+// spaced evenly, and schedules itself a minute later while any are left.
+func dispatch(ctx context.Context, job *jobs.Job) error {
+	var p campaignPayload
+	if err := json.Unmarshal(job.Payload, &p); err != nil {
+		return fmt.Errorf("invalid campaign payload: %v", err)
+	}
+
+	tx, err := database.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var status string
+	var rate int
+	err = tx.QueryRowContext(ctx, "SELECT status, rate_per_minute FROM campaigns WHERE id = $1 FOR UPDATE", p.CampaignID).Scan(&status, &rate)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil || status != models.CampaignRunning {
+		return err
+	}
+
+	now := clock.Now()
+	rows, err := tx.QueryContext(ctx, `
+		UPDATE campaign_recipients SET status = $2, updated_at = $3
+		WHERE campaign_id = $1 AND user_id IN (
+			SELECT user_id FROM campaign_recipients
+			WHERE campaign_id = $1 AND status = $4
+			ORDER BY user_id
+			LIMIT $5
+		)
+		RETURNING user_id`,
+		p.CampaignID, models.RecipientQueued, now, models.RecipientPending, rate)
+	if err != nil {
+		return err
+	}
+	var userIDs []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		userIDs = append(userIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	spacing := time.Minute / time.Duration(rate)
+	for i, userID := range userIDs {
+		payload := sendPayload{CampaignID: p.CampaignID, UserID: userID}
+		if _, err := jobs.EnqueueAt(ctx, tx, jobs.DefaultQueue, SendJob, payload, now.Add(time.Duration(i)*spacing)); err != nil {
+			return err
+		}
+	}
+	if len(userIDs) == rate {
+		if _, err := jobs.EnqueueAt(ctx, tx, jobs.DefaultQueue, DispatchJob, p, now.Add(time.Minute)); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	// A campaign with no recipients, or whose last sends already failed,
+	// has nothing left to finish it.
+	if err := finish(ctx, p.CampaignID); err != nil {
+		log.Printf("Failed to finish campaign %d: %v", p.CampaignID, err)
+	}
+	return nil
+}
+
+// send emails one recipient. Recipients who were cancelled or already
+// handled are left alone, and users pending deletion are skipped.
+func send(ctx context.Context, job *jobs.Job) error {
+	var p sendPayload
+	if err := json.Unmarshal(job.Payload, &p); err != nil {
+		return fmt.Errorf("invalid campaign payload: %v", err)
+	}
+
+	var campaignStatus, template, recipientStatus string
+	var data []byte
+	err := database.DB.QueryRowContext(ctx, `
+		SELECT c.status, c.template, c.data, r.status
+		FROM campaigns c JOIN campaign_recipients r ON r.campaign_id = c.id
+		WHERE c.id = $1 AND r.user_id = $2`,
+		p.CampaignID, p.UserID).Scan(&campaignStatus, &template, &data, &recipientStatus)
+	if errors.Is(err, sql.ErrNoRows) {
+		// The campaign or the user was deleted, and the user may have been
+		// the last one outstanding.
+		return finish(ctx, p.CampaignID)
+	}
+	if err != nil {
+		return err
+	}
+	if campaignStatus != models.CampaignRunning || recipientStatus != models.RecipientQueued {
+		return nil
+	}
+	var content models.CampaignData
+	if err := json.Unmarshal(data, &content); err != nil {
+		return err
+	}
+
+	user, err := repository.GetUser(ctx, p.UserID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return finish(ctx, p.CampaignID)
+	}
+	if err != nil {
+		return err
+	}
+	if user.Deletion != nil {
+		return record(ctx, p, models.RecipientSkipped, "")
+	}
+
+	m, err := mailer.Default()
+	if err == nil {
+		err = m.SendTemplate(ctx, user.Email, template, emailData(content, user))
+	}
+	if err != nil {
+		if job.Attempts >= job.MaxAttempts {
+			if recordErr := record(ctx, p, models.RecipientFailed, err.Error()); recordErr != nil {
+				log.Printf("Failed to record campaign %d send to user %d: %v", p.CampaignID, p.UserID, recordErr)
+			}
+		}
+		return err
+	}
+	// The email is out, so a failure to record it is logged rather than
+	// retried, which would send it again.
+	if err := record(ctx, p, models.RecipientSent, ""); err != nil {
+		log.Printf("Failed to record campaign %d send to user %d: %v", p.CampaignID, p.UserID, err)
+	}
+	return nil
+}
+
+// record sets a recipient's final status and finishes the campaign if it
+// was the last one outstanding.
+func record(ctx context.Context, p sendPayload, status, errMsg string) error {
+	_, err := database.DB.ExecContext(ctx,
+		"UPDATE campaign_recipients SET status = $3, error = $4, updated_at = $5 WHERE campaign_id = $1 AND user_id = $2",
+		p.CampaignID, p.UserID, status, errMsg, clock.Now())
+	if err != nil {
+		return err
+	}
+	return finish(ctx, p.CampaignID)
+}
+
+// finish marks a running campaign completed once no recipient is pending
+// or queued.
+func finish(ctx context.Context, id int) error {
+	_, err := database.DB.ExecContext(ctx, `
+		UPDATE campaigns SET status = $2, finished_at = $3
+		WHERE id = $1 AND status = $4 AND NOT EXISTS (
+			SELECT 1 FROM campaign_recipients WHERE campaign_id = $1 AND status IN ($5, $6)
+		)`,
+		id, models.CampaignCompleted, clock.Now(), models.CampaignRunning, models.RecipientPending, models.RecipientQueued)
+	return err
+}
+
+// emailData fills in a campaign's template for one user.
+func emailData(content models.CampaignData, user models.User) templates.Data {
+	return templates.Data{
+		Name:    user.Name,
+		Subject: content.Subject,
+		Message: content.Message,
+		Link:    content.Link,
+	}
+}
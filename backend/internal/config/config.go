@@ -0,0 +1,466 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"pygorp/backend/internal/env"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Runtime holds the non-critical settings that can be reloaded without a
+// restart. Database and listener settings are read once at startup.
+type Runtime struct {
+	LogLevel string `json:"log_level" yaml:"log_level"`
+	// LogLevelResetAfter is the default lifetime of a temporary log level
+	// override set through the admin API.
+	LogLevelResetAfter string `json:"log_level_reset_after" yaml:"log_level_reset_after"`
+	// LogSampleRate is the fraction of successful requests written to the
+	// request log; failed ones are always logged.
+	LogSampleRate float64   `json:"log_sample_rate" yaml:"log_sample_rate"`
+	RateLimit     RateLimit `json:"rate_limit" yaml:"rate_limit"`
+	// RateLimitClasses overrides RateLimit for routes declaring a rate-limit
+	// class in the route table.
+	RateLimitClasses map[string]RateLimit `json:"rate_limit_classes" yaml:"rate_limit_classes"`
+	FeatureFlags     map[string]bool      `json:"feature_flags" yaml:"feature_flags"`
+	CORSOrigins      []string             `json:"cors_origins" yaml:"cors_origins"`
+	// ReadOnly rejects mutating requests; the admin API can override it
+	// until restart.
+	ReadOnly bool `json:"read_only" yaml:"read_only"`
+	// PolicyMode controls the policy engine: off, audit (log denials only),
+	// or enforce.
+	PolicyMode string `json:"policy_mode" yaml:"policy_mode"`
+	// DisposableEmails decides what happens to signups from disposable email
+	// domains: off, flag (create the user and flag it for review), or reject.
+	DisposableEmails string `json:"disposable_emails" yaml:"disposable_emails"`
+	// EmailMXCheck looks up MX records for the domain of new users' emails:
+	// off, soft (log domains without them), or enforce (reject them).
+	EmailMXCheck string `json:"email_mx_check" yaml:"email_mx_check"`
+	// MaxPageSize is the largest limit a paged list accepts.
+	MaxPageSize int `json:"max_page_size" yaml:"max_page_size"`
+	// MaxRows caps the rows any single request can return, paged or not.
+	MaxRows int `json:"max_rows" yaml:"max_rows"`
+	// MaxBatchRequests is the most requests one batch may carry.
+	MaxBatchRequests int      `json:"max_batch_requests" yaml:"max_batch_requests"`
+	LoadShed         LoadShed `json:"load_shed" yaml:"load_shed"`
+	// ErrorFormat is the shape of error responses: problem (RFC 7807 problem
+	// details) or legacy (the {"error": ...} object, kept while clients
+	// migrate).
+	ErrorFormat string `json:"error_format" yaml:"error_format"`
+	Render      Render `json:"render" yaml:"render"`
+	// Retention lists the data retention rules the scheduler applies.
+	Retention []RetentionRule `json:"retention" yaml:"retention"`
+	// ConsentGates turns away users who have not accepted a policy requiring
+	// consent, per route group prefix.
+	ConsentGates map[string]ConsentGate `json:"consent_gates" yaml:"consent_gates"`
+	// SLOs sets the service level objectives tracked per route group prefix.
+	SLOs map[string]SLO `json:"slos" yaml:"slos"`
+	// SLOAlertEmails are emailed when an SLO burns its error budget too fast.
+	SLOAlertEmails []string `json:"slo_alert_emails" yaml:"slo_alert_emails"`
+}
+
+// SLO sets the objectives of a route group: Availability is the share of
+// requests that must not fail with a 5xx, and LatencyTarget the share that
+// must be answered within Latency. A zero target leaves its objective out.
+type SLO struct {
+	Availability  float64 `json:"availability" yaml:"availability"`
+	Latency       string  `json:"latency" yaml:"latency"`
+	LatencyTarget float64 `json:"latency_target" yaml:"latency_target"`
+}
+
+// LatencyThreshold returns Latency as a duration.
+func (s SLO) LatencyThreshold() time.Duration {
+	d, _ := time.ParseDuration(s.Latency)
+	return d
+}
+
+// ConsentGate answers requests to a route group by users who owe a consent
+// with Status, 451 (the default) or 409, except for paths under an Exempt
+// prefix. Disabled lets the group's requests through.
+type ConsentGate struct {
+	Status   int      `json:"status" yaml:"status"`
+	Exempt   []string `json:"exempt" yaml:"exempt"`
+	Disabled bool     `json:"disabled" yaml:"disabled"`
+}
+
+// RetentionRule purges or anonymizes the records of Target older than
+// After. Disabled rules are only previewed, so their effect can be checked
+// before they are enabled.
+type RetentionRule struct {
+	Name    string `json:"name" yaml:"name"`
+	Target  string `json:"target" yaml:"target"`
+	Action  string `json:"action" yaml:"action"`
+	After   string `json:"after" yaml:"after"`
+	Enabled bool   `json:"enabled" yaml:"enabled"`
+}
+
+// RetentionTargets are the records retention rules can apply to, with the
+// action each supports.
+var RetentionTargets = map[string]string{
+	"events":         "purge",
+	"sent_emails":    "purge",
+	"ai_requests":    "purge",
+	"sessions":       "purge",
+	"user_history":   "purge",
+	"inactive_users": "anonymize",
+}
+
+// AfterDuration returns After as a duration.
+func (r RetentionRule) AfterDuration() time.Duration {
+	d, _ := time.ParseDuration(r.After)
+	return d
+}
+
+// Render is the JSON rendering policy. Nulls decides what optional fields
+// without a value become: keep (null), omit, or default (the type's empty
+// value). Timestamps is rfc3339, rfc3339_ms, or rfc3339_s; all are UTC.
+type Render struct {
+	Nulls      string `json:"nulls" yaml:"nulls"`
+	Timestamps string `json:"timestamps" yaml:"timestamps"`
+}
+
+// LoadShed configures load shedding. The server is overloaded when more
+// than MaxInFlight requests are being handled or a route's p99 latency
+// exceeds its budget: LatencyBudget, or the route's entry in RouteBudgets.
+// A zero MaxInFlight or empty budget disables that signal. RetryAfter is
+// sent with the 503 answering a shed request.
+type LoadShed struct {
+	MaxInFlight   int               `json:"max_in_flight" yaml:"max_in_flight"`
+	LatencyBudget string            `json:"latency_budget" yaml:"latency_budget"`
+	RouteBudgets  map[string]string `json:"route_budgets" yaml:"route_budgets"`
+	RetryAfter    string            `json:"retry_after" yaml:"retry_after"`
+}
+
+// RateLimit configures the per-client request limiter. A zero
+// RequestsPerSecond disables limiting.
+type RateLimit struct {
+	RequestsPerSecond float64 `json:"requests_per_second" yaml:"requests_per_second"`
+	Burst             int     `json:"burst" yaml:"burst"`
+}
+
+var flagSource atomic.Pointer[func() (map[string]bool, error)]
+
+// SetFeatureFlagSource registers where stored feature flags, such as those
+// set by `pygorp apply`, are read from. They override FEATURE_FLAGS and are
+// overridden by the config file, and apply from the next Load.
+func SetFeatureFlagSource(src func() (map[string]bool, error)) {
+	flagSource.Store(&src)
+}
+
+// Load builds the runtime config from environment defaults and stored
+// feature flags, overlaid with the YAML file named by CONFIG_FILE when set.
+func Load() (*Runtime, error) {
+	rt := &Runtime{
+		LogLevel:           env.String("LOG_LEVEL", "info"),
+		LogLevelResetAfter: env.String("LOG_LEVEL_RESET_AFTER", "15m"),
+		LogSampleRate:      env.Float("LOG_SAMPLE_RATE", 1),
+		RateLimit: RateLimit{
+			RequestsPerSecond: env.Float("RATE_LIMIT_RPS", 0),
+			Burst:             int(env.Float("RATE_LIMIT_BURST", 20)),
+		},
+		FeatureFlags:     map[string]bool{},
+		CORSOrigins:      splitList(env.String("CORS_ORIGINS", "http://localhost:3000,http://localhost:3001")),
+		ReadOnly:         env.String("READ_ONLY", "false") == "true",
+		PolicyMode:       env.String("POLICY_MODE", "off"),
+		DisposableEmails: env.String("DISPOSABLE_EMAILS", "flag"),
+		EmailMXCheck:     env.String("EMAIL_MX_CHECK", "off"),
+		MaxPageSize:      int(env.Float("MAX_PAGE_SIZE", 200)),
+		MaxRows:          int(env.Float("MAX_ROWS", 1000)),
+		MaxBatchRequests: int(env.Float("MAX_BATCH_REQUESTS", 20)),
+		LoadShed: LoadShed{
+			MaxInFlight:   int(env.Float("LOAD_SHED_MAX_IN_FLIGHT", 0)),
+			LatencyBudget: os.Getenv("LOAD_SHED_LATENCY_BUDGET"),
+			RetryAfter:    env.String("LOAD_SHED_RETRY_AFTER", "5s"),
+		},
+		ErrorFormat: env.String("ERROR_FORMAT", "problem"),
+		Render: Render{
+			Nulls:      env.String("RENDER_NULLS", "keep"),
+			Timestamps: env.String("RENDER_TIMESTAMPS", "rfc3339"),
+		},
+		ConsentGates: map[string]ConsentGate{
+			"/api/v1": {Status: int(env.Float("CONSENT_GATE_STATUS", 451))},
+		},
+		SLOs: map[string]SLO{
+			"/api/v1": {Availability: 0.999, Latency: "1s", LatencyTarget: 0.99},
+		},
+		SLOAlertEmails: splitList(os.Getenv("SLO_ALERT_EMAILS")),
+	}
+	for _, flag := range splitList(os.Getenv("FEATURE_FLAGS")) {
+		rt.FeatureFlags[flag] = true
+	}
+	if src := flagSource.Load(); src != nil {
+		flags, err := (*src)()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load stored feature flags: %v", err)
+		}
+		for name, enabled := range flags {
+			rt.FeatureFlags[name] = enabled
+		}
+	}
+
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file: %v", err)
+		}
+		if err := yaml.Unmarshal(data, rt); err != nil {
+			return nil, fmt.Errorf("failed to parse config file: %v", err)
+		}
+	}
+
+	if err := rt.Validate(); err != nil {
+		return nil, err
+	}
+	return rt, nil
+}
+
+// Validate rejects settings that would leave the server misconfigured.
+func (rt *Runtime) Validate() error {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(rt.LogLevel)); err != nil {
+		return fmt.Errorf("invalid log_level %q", rt.LogLevel)
+	}
+	if d, err := time.ParseDuration(rt.LogLevelResetAfter); err != nil || d <= 0 {
+		return fmt.Errorf("invalid log_level_reset_after %q", rt.LogLevelResetAfter)
+	}
+	if rt.LogSampleRate < 0 || rt.LogSampleRate > 1 {
+		return fmt.Errorf("log_sample_rate must be between 0 and 1")
+	}
+	if err := rt.RateLimit.validate("rate_limit"); err != nil {
+		return err
+	}
+	for class, limit := range rt.RateLimitClasses {
+		if err := limit.validate("rate_limit_classes." + class); err != nil {
+			return err
+		}
+	}
+	switch rt.PolicyMode {
+	case "off", "audit", "enforce":
+	default:
+		return fmt.Errorf("invalid policy_mode %q (want off, audit, or enforce)", rt.PolicyMode)
+	}
+	switch rt.DisposableEmails {
+	case "off", "flag", "reject":
+	default:
+		return fmt.Errorf("invalid disposable_emails %q (want off, flag, or reject)", rt.DisposableEmails)
+	}
+	switch rt.EmailMXCheck {
+	case "off", "soft", "enforce":
+	default:
+		return fmt.Errorf("invalid email_mx_check %q (want off, soft, or enforce)", rt.EmailMXCheck)
+	}
+	switch rt.ErrorFormat {
+	case "problem", "legacy":
+	default:
+		return fmt.Errorf("invalid error_format %q (want problem or legacy)", rt.ErrorFormat)
+	}
+	switch rt.Render.Nulls {
+	case "keep", "omit", "default":
+	default:
+		return fmt.Errorf("invalid render.nulls %q (want keep, omit, or default)", rt.Render.Nulls)
+	}
+	switch rt.Render.Timestamps {
+	case "rfc3339", "rfc3339_ms", "rfc3339_s":
+	default:
+		return fmt.Errorf("invalid render.timestamps %q (want rfc3339, rfc3339_ms, or rfc3339_s)", rt.Render.Timestamps)
+	}
+	if rt.MaxPageSize < 1 {
+		return fmt.Errorf("max_page_size must be at least 1")
+	}
+	if rt.MaxRows < rt.MaxPageSize {
+		return fmt.Errorf("max_rows must be at least max_page_size")
+	}
+	if rt.MaxBatchRequests < 1 {
+		return fmt.Errorf("max_batch_requests must be at least 1")
+	}
+	if err := rt.LoadShed.validate(); err != nil {
+		return err
+	}
+	names := map[string]bool{}
+	for i, rule := range rt.Retention {
+		if err := rule.validate(fmt.Sprintf("retention[%d]", i)); err != nil {
+			return err
+		}
+		if names[rule.Name] {
+			return fmt.Errorf("duplicate retention rule %q", rule.Name)
+		}
+		names[rule.Name] = true
+	}
+	for group, gate := range rt.ConsentGates {
+		if err := gate.validate("consent_gates." + group); err != nil {
+			return err
+		}
+	}
+	for group, slo := range rt.SLOs {
+		if err := slo.validate("slos." + group); err != nil {
+			return err
+		}
+	}
+	if len(rt.CORSOrigins) == 0 {
+		return fmt.Errorf("cors_origins must not be empty")
+	}
+	for _, origin := range rt.CORSOrigins {
+		if origin == "*" {
+			continue
+		}
+		u, err := url.Parse(origin)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("invalid cors origin %q", origin)
+		}
+	}
+	return nil
+}
+
+func (rl RateLimit) validate(field string) error {
+	if rl.RequestsPerSecond < 0 {
+		return fmt.Errorf("%s.requests_per_second must not be negative", field)
+	}
+	if rl.RequestsPerSecond > 0 && rl.Burst < 1 {
+		return fmt.Errorf("%s.burst must be at least 1", field)
+	}
+	return nil
+}
+
+func (ls LoadShed) validate() error {
+	if ls.MaxInFlight < 0 {
+		return fmt.Errorf("load_shed.max_in_flight must not be negative")
+	}
+	if ls.LatencyBudget != "" {
+		if d, err := time.ParseDuration(ls.LatencyBudget); err != nil || d <= 0 {
+			return fmt.Errorf("invalid load_shed.latency_budget %q", ls.LatencyBudget)
+		}
+	}
+	for route, budget := range ls.RouteBudgets {
+		if d, err := time.ParseDuration(budget); err != nil || d <= 0 {
+			return fmt.Errorf("invalid load_shed.route_budgets.%s %q", route, budget)
+		}
+	}
+	if d, err := time.ParseDuration(ls.RetryAfter); err != nil || d < time.Second {
+		return fmt.Errorf("invalid load_shed.retry_after %q (want at least 1s)", ls.RetryAfter)
+	}
+	return nil
+}
+
+func (r RetentionRule) validate(field string) error {
+	if r.Name == "" {
+		return fmt.Errorf("%s.name must not be empty", field)
+	}
+	action, ok := RetentionTargets[r.Target]
+	if !ok {
+		return fmt.Errorf("invalid %s.target %q", field, r.Target)
+	}
+	if r.Action != action {
+		return fmt.Errorf("invalid %s.action %q (want %s for %s)", field, r.Action, action, r.Target)
+	}
+	if d, err := time.ParseDuration(r.After); err != nil || d < 24*time.Hour {
+		return fmt.Errorf("invalid %s.after %q (want at least 24h)", field, r.After)
+	}
+	return nil
+}
+
+func (g ConsentGate) validate(field string) error {
+	if g.Disabled {
+		return nil
+	}
+	if g.Status != 0 && g.Status != 451 && g.Status != 409 {
+		return fmt.Errorf("invalid %s.status %d (want 451 or 409)", field, g.Status)
+	}
+	for _, prefix := range g.Exempt {
+		if !strings.HasPrefix(prefix, "/") {
+			return fmt.Errorf("invalid %s.exempt %q (want a path)", field, prefix)
+		}
+	}
+	return nil
+}
+
+func (s SLO) validate(field string) error {
+	if s.Availability < 0 || s.Availability >= 1 {
+		return fmt.Errorf("%s.availability must be at least 0 and below 1", field)
+	}
+	if s.LatencyTarget < 0 || s.LatencyTarget >= 1 {
+		return fmt.Errorf("%s.latency_target must be at least 0 and below 1", field)
+	}
+	if s.LatencyTarget > 0 {
+		if d, err := time.ParseDuration(s.Latency); err != nil || d <= 0 {
+			return fmt.Errorf("invalid %s.latency %q", field, s.Latency)
+		}
+	}
+	return nil
+}
+
+// BudgetFor returns a route's latency budget, or zero when it has none.
+func (ls LoadShed) BudgetFor(route string) time.Duration {
+	budget, ok := ls.RouteBudgets[route]
+	if !ok {
+		budget = ls.LatencyBudget
+	}
+	d, _ := time.ParseDuration(budget)
+	return d
+}
+
+// RetryAfterSeconds returns RetryAfter in whole seconds, rounded up.
+func (ls LoadShed) RetryAfterSeconds() int {
+	d, _ := time.ParseDuration(ls.RetryAfter)
+	return int((d + time.Second - 1) / time.Second)
+}
+
+// StatusCode returns Status, or 451 when it is unset.
+func (g ConsentGate) StatusCode() int {
+	if g.Status == 0 {
+		return 451
+	}
+	return g.Status
+}
+
+// ConsentGateFor returns the consent gate of a route group, and false when
+// its requests are not gated.
+func (rt *Runtime) ConsentGateFor(group string) (ConsentGate, bool) {
+	gate, ok := rt.ConsentGates[group]
+	return gate, ok && !gate.Disabled
+}
+
+// RateLimitFor returns the limit for a rate-limit class, falling back to
+// the default RateLimit for unknown classes.
+func (rt *Runtime) RateLimitFor(class string) RateLimit {
+	if limit, ok := rt.RateLimitClasses[class]; ok {
+		return limit
+	}
+	return rt.RateLimit
+}
+
+// LogLevelResetDuration returns LogLevelResetAfter as a duration.
+func (rt *Runtime) LogLevelResetDuration() time.Duration {
+	d, _ := time.ParseDuration(rt.LogLevelResetAfter)
+	return d
+}
+
+// FeatureEnabled reports whether the named feature flag is switched on.
+func (rt *Runtime) FeatureEnabled(name string) bool {
+	return rt.FeatureFlags[name]
+}
+
+// AllowsOrigin reports whether CORS requests from origin are permitted.
+func (rt *Runtime) AllowsOrigin(origin string) bool {
+	for _, allowed := range rt.CORSOrigins {
+		if allowed == "*" || allowed == origin {
+			return true
+		}
+	}
+	return false
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
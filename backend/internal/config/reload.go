@@ -0,0 +1,83 @@
+package config
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+)
+
+var (
+	current atomic.Pointer[Runtime]
+
+	mu    sync.Mutex
+	hooks []func(*Runtime) error
+)
+
+// Init loads the initial runtime config. It must be called before Current.
+func Init() error {
+	rt, err := Load()
+	if err != nil {
+		return err
+	}
+	current.Store(rt)
+	return nil
+}
+
+// Current returns the active runtime config. The returned value must not be
+// modified.
+func Current() *Runtime {
+	return current.Load()
+}
+
+// OnReload registers a hook that applies a newly loaded config. Returning an
+// error from a hook aborts the reload and restores the previous config.
+func OnReload(hook func(*Runtime) error) {
+	mu.Lock()
+	defer mu.Unlock()
+	hooks = append(hooks, hook)
+}
+
+// Reload re-reads the config and swaps it in. On validation or apply errors
+// the previous config stays active.
+func Reload() (*Runtime, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	next, err := Load()
+	if err != nil {
+		return nil, err
+	}
+
+	prev := current.Swap(next)
+	for _, hook := range hooks {
+		if err := hook(next); err != nil {
+			current.Store(prev)
+			for _, h := range hooks {
+				if rollbackErr := h(prev); rollbackErr != nil {
+					log.Printf("Config rollback hook failed: %v", rollbackErr)
+				}
+			}
+			return nil, fmt.Errorf("failed to apply config: %v", err)
+		}
+	}
+
+	log.Println("Runtime configuration reloaded")
+	return next, nil
+}
+
+// WatchSignals reloads the config whenever the process receives SIGHUP.
+func WatchSignals() {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGHUP)
+	go func() {
+		for range sig {
+			if _, err := Reload(); err != nil {
+				log.Printf("Config reload failed, keeping previous config: %v", err)
+			}
+		}
+	}()
+}
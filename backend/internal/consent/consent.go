@@ -0,0 +1,76 @@
+// Package consent records which policies users have agreed to. Policy
+// documents (the terms of service, the privacy policy, and marketing
+// communications) are published in numbered versions; users consent to the
+// current version of each, and marketing consent can be withdrawn. A
+// version published as requiring consent locks users out of the API until
+// they accept it or a later version.
+package consent
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Kinds of policy documents.
+const (
+	Terms     = "terms"
+	Privacy   = "privacy"
+	Marketing = "marketing"
+)
+
+// Kinds lists every kind of policy document.
+var Kinds = []string{Terms, Privacy, Marketing}
+
+const maxTitleLength = 200
+
+// Document is one published version of a policy.
+type Document struct {
+	Kind    string `json:"kind"`
+	Version int    `json:"version"`
+	Title   string `json:"title"`
+	Content string `json:"content"`
+	// RequiresConsent makes users accept this version, or a later one,
+	// before they can use the API again.
+	RequiresConsent bool      `json:"requires_consent"`
+	PublishedAt     time.Time `json:"published_at"`
+}
+
+// Check rejects documents that cannot be published.
+func (d *Document) Check() error {
+	if !slices.Contains(Kinds, d.Kind) {
+		return fmt.Errorf("kind must be one of %s", strings.Join(Kinds, ", "))
+	}
+	d.Title = strings.TrimSpace(d.Title)
+	if d.Title == "" || len(d.Title) > maxTitleLength {
+		return fmt.Errorf("title must be 1 to %d characters", maxTitleLength)
+	}
+	if strings.TrimSpace(d.Content) == "" {
+		return fmt.Errorf("content is required")
+	}
+	if d.RequiresConsent && Optional(d.Kind) {
+		return fmt.Errorf("%s consent is optional and cannot be required", d.Kind)
+	}
+	return nil
+}
+
+// Optional reports whether users may withhold or withdraw consent to a kind
+// of policy.
+func Optional(kind string) bool {
+	return kind == Marketing
+}
+
+// Consent is a user's consent to a version of a policy, or its withdrawal.
+type Consent struct {
+	Kind      string    `json:"kind"`
+	Version   int       `json:"version"`
+	Granted   bool      `json:"granted"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Source is where a consent was given from, kept with it as evidence.
+type Source struct {
+	IP        string
+	UserAgent string
+}
@@ -0,0 +1,235 @@
+package consent
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"time"
+
+	"pygorp/backend/internal/clock"
+	"pygorp/backend/internal/database"
+
+	"github.com/lib/pq"
+)
+
+var (
+	// ErrNotFound is returned when a policy document does not exist.
+	ErrNotFound = errors.New("policy document not found")
+	// ErrOutdated is returned when consenting to a version that is no
+	// longer the current one.
+	ErrOutdated = errors.New("policy document is not the current version")
+	// ErrConflict is returned when two versions of a kind are published at
+	// once.
+	ErrConflict = errors.New("another version was published at the same time")
+)
+
+// cacheTTL bounds how long other instances keep letting users through after
+// a version requiring consent is published. Publishing through this
+// instance applies immediately.
+const cacheTTL = 30 * time.Second
+
+const (
+	columns        = "kind, version, title, content, requires_consent, published_at"
+	consentColumns = "kind, version, granted, created_at"
+)
+
+func init() {
+	database.UseColumns("policy_documents", columns)
+	database.UseColumns("consents", "id, user_id, "+consentColumns+", ip, user_agent")
+}
+
+// cache holds the current version of each kind and the version users must
+// have accepted, for kinds with one.
+var cache struct {
+	sync.Mutex
+	current  map[string]*Document
+	required map[string]int
+	loaded   time.Time
+}
+
+func scan(row interface{ Scan(...interface{}) error }) (*Document, error) {
+	var d Document
+	err := row.Scan(&d.Kind, &d.Version, &d.Title, &d.Content, &d.RequiresConsent, &d.PublishedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return &d, err
+}
+
+func scanAll(rows *sql.Rows, err error) ([]*Document, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	docs := []*Document{}
+	for rows.Next() {
+		d, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, d)
+	}
+	return docs, rows.Err()
+}
+
+// List returns every version of the policy documents, or of one kind when
+// kind is set, newest first.
+func List(ctx context.Context, kind string) ([]*Document, error) {
+	return scanAll(database.DB.QueryContext(ctx,
+		"SELECT "+columns+" FROM policy_documents WHERE $1 = '' OR kind = $1 ORDER BY published_at DESC, kind", kind))
+}
+
+// Current returns the current version of each kind that has one.
+func Current(ctx context.Context) ([]*Document, error) {
+	return scanAll(database.DB.QueryContext(ctx,
+		"SELECT DISTINCT ON (kind) "+columns+" FROM policy_documents ORDER BY kind, version DESC"))
+}
+
+// Get returns a version of a policy document.
+func Get(ctx context.Context, kind string, version int) (*Document, error) {
+	return scan(database.DB.QueryRowContext(ctx,
+		"SELECT "+columns+" FROM policy_documents WHERE kind = $1 AND version = $2", kind, version))
+}
+
+// Publish stores d as the next version of its kind.
+func Publish(ctx context.Context, d *Document) (*Document, error) {
+	defer Invalidate()
+	published, err := scan(database.DB.QueryRowContext(ctx, `
+		INSERT INTO policy_documents (kind, version, title, content, requires_consent, published_at)
+		SELECT $1, COALESCE(MAX(version), 0) + 1, $2, $3, $4, $5 FROM policy_documents WHERE kind = $1
+		RETURNING `+columns,
+		d.Kind, d.Title, d.Content, d.RequiresConsent, clock.Now()))
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return nil, ErrConflict
+	}
+	return published, err
+}
+
+// Record stores a user's consent to the current version of a kind, or its
+// withdrawal.
+func Record(ctx context.Context, userID int, kind string, version int, granted bool, source Source) (*Consent, error) {
+	var c Consent
+	err := database.DB.QueryRowContext(ctx, `
+		INSERT INTO consents (user_id, kind, version, granted, ip, user_agent, created_at)
+		SELECT $1, $2, $3, $4, NULLIF($5, ''), NULLIF($6, ''), $7
+		WHERE $3 = (SELECT MAX(version) FROM policy_documents WHERE kind = $2)
+		RETURNING `+consentColumns,
+		userID, kind, version, granted, source.IP, source.UserAgent, clock.Now()).
+		Scan(&c.Kind, &c.Version, &c.Granted, &c.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		if _, err := Get(ctx, kind, version); err != nil {
+			return nil, err
+		}
+		return nil, ErrOutdated
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// ForUser returns a user's current consent to each kind it has given or
+// withdrawn consent to.
+func ForUser(ctx context.Context, userID int) ([]Consent, error) {
+	return forUser(ctx, userID, Kinds)
+}
+
+func forUser(ctx context.Context, userID int, kinds []string) ([]Consent, error) {
+	rows, err := database.DB.QueryContext(ctx,
+		"SELECT DISTINCT ON (kind) "+consentColumns+" FROM consents WHERE user_id = $1 AND kind = ANY($2) ORDER BY kind, id DESC",
+		userID, pq.Array(kinds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	consents := []Consent{}
+	for rows.Next() {
+		var c Consent
+		if err := rows.Scan(&c.Kind, &c.Version, &c.Granted, &c.CreatedAt); err != nil {
+			return nil, err
+		}
+		consents = append(consents, c)
+	}
+	return consents, rows.Err()
+}
+
+// Pending returns the current versions of the policies a user must accept
+// before using the API: those where a version requiring consent was
+// published after the one the user last accepted.
+func Pending(ctx context.Context, userID int) ([]*Document, error) {
+	current, required, err := load(ctx)
+	if err != nil || len(required) == 0 {
+		return nil, err
+	}
+	kinds := make([]string, 0, len(required))
+	for kind := range required {
+		kinds = append(kinds, kind)
+	}
+	consents, err := forUser(ctx, userID, kinds)
+	if err != nil {
+		return nil, err
+	}
+
+	accepted := map[string]bool{}
+	for _, c := range consents {
+		accepted[c.Kind] = c.Granted && c.Version >= required[c.Kind]
+	}
+	var pending []*Document
+	for _, kind := range Kinds {
+		if _, ok := required[kind]; ok && !accepted[kind] {
+			pending = append(pending, current[kind])
+		}
+	}
+	return pending, nil
+}
+
+// Invalidate drops the cached versions, so the next request reloads them.
+func Invalidate() {
+	cache.Lock()
+	cache.current, cache.required = nil, nil
+	cache.Unlock()
+}
+
+// load returns the cached current version of each kind and the versions
+// users must have accepted, reloading them when the cache has expired.
+func load(ctx context.Context) (map[string]*Document, map[string]int, error) {
+	cache.Lock()
+	defer cache.Unlock()
+
+	if cache.current != nil && time.Since(cache.loaded) < cacheTTL {
+		return cache.current, cache.required, nil
+	}
+	docs, err := Current(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	current := make(map[string]*Document, len(docs))
+	for _, d := range docs {
+		current[d.Kind] = d
+	}
+	required := map[string]int{}
+	rows, err := database.DB.QueryContext(ctx,
+		"SELECT kind, MAX(version) FROM policy_documents WHERE requires_consent GROUP BY kind")
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var kind string
+		var version int
+		if err := rows.Scan(&kind, &version); err != nil {
+			return nil, nil, err
+		}
+		required[kind] = version
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	cache.current, cache.required, cache.loaded = current, required, time.Now()
+	return current, required, nil
+}
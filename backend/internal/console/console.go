@@ -0,0 +1,281 @@
+package console
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"pygorp/backend/internal/jobs"
+	"pygorp/backend/internal/models"
+	"pygorp/backend/internal/operations"
+	"pygorp/backend/internal/repository"
+)
+
+// errQuit ends the session.
+var errQuit = errors.New("quit")
+
+type command struct {
+	usage string
+	help  string
+	run   func(ctx context.Context, s *Session, args []string) error
+}
+
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"help":       {"help", "List commands", cmdHelp},
+		"exit":       {"exit", "Leave the console", func(context.Context, *Session, []string) error { return errQuit }},
+		"user":       {"user ID|EMAIL", "Show one user", cmdUser},
+		"users":      {"users TEXT|@DOMAIN", "Find users by name, or by email domain", cmdUsers},
+		"jobs":       {"jobs [STATUS] [QUEUE]", "List the 20 newest jobs", cmdJobs},
+		"job":        {"job ID", "Show one job with its payload and last error", cmdJob},
+		"retry":      {"retry ID", "Requeue a dead or cancelled job", cmdRetry},
+		"cancel":     {"cancel ID", "Cancel a queued job", cmdCancel},
+		"retry-dead": {"retry-dead [QUEUE]", "Requeue all dead jobs", cmdRetryDead},
+		"stats":      {"stats", "Show per-queue depth and latency", cmdStats},
+		"operation":  {"operation ID", "Show a long-running operation", cmdOperation},
+	}
+}
+
+// Session is an interactive console reading commands line by line.
+type Session struct {
+	in  *bufio.Scanner
+	out io.Writer
+}
+
+// New creates a session reading from in and writing to out.
+func New(in io.Reader, out io.Writer) *Session {
+	return &Session{in: bufio.NewScanner(in), out: out}
+}
+
+// Run reads and executes commands until EOF or "exit". Command errors are
+// printed and the session continues.
+func (s *Session) Run(ctx context.Context) error {
+	fmt.Fprintln(s.out, `PyGoRP console. Type "help" for commands.`)
+	for {
+		fmt.Fprint(s.out, "pygorp> ")
+		if !s.in.Scan() {
+			fmt.Fprintln(s.out)
+			return s.in.Err()
+		}
+		fields := strings.Fields(s.in.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		err := s.Exec(ctx, fields[0], fields[1:])
+		if errors.Is(err, errQuit) {
+			return nil
+		}
+		if err != nil {
+			fmt.Fprintln(s.out, "error:", err)
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+}
+
+// Exec runs a single command.
+func (s *Session) Exec(ctx context.Context, name string, args []string) error {
+	if name == "quit" {
+		name = "exit"
+	}
+	cmd, ok := commands[name]
+	if !ok {
+		return fmt.Errorf("unknown command %q", name)
+	}
+	return cmd.run(ctx, s, args)
+}
+
+func (s *Session) printJSON(v interface{}) error {
+	enc := json.NewEncoder(s.out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func cmdHelp(ctx context.Context, s *Session, args []string) error {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	w := tabwriter.NewWriter(s.out, 0, 0, 2, ' ', 0)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %s\t%s\n", commands[name].usage, commands[name].help)
+	}
+	return w.Flush()
+}
+
+func cmdUser(ctx context.Context, s *Session, args []string) error {
+	if len(args) != 1 {
+		return usage("user")
+	}
+
+	var user models.User
+	var err error
+	if id, convErr := strconv.Atoi(args[0]); convErr == nil {
+		user, err = repository.GetUser(ctx, id)
+	} else {
+		user, err = repository.GetUserByEmail(ctx, args[0])
+	}
+	if err != nil {
+		return err
+	}
+	return s.printJSON(user)
+}
+
+func cmdUsers(ctx context.Context, s *Session, args []string) error {
+	if len(args) == 0 {
+		return usage("users")
+	}
+
+	query := repository.UserQuery{Sort: []string{"id ASC"}}
+	query.Page.Limit = 20
+	text := strings.Join(args, " ")
+	if domain, ok := strings.CutPrefix(text, "@"); ok {
+		query.Filter.EmailDomain = domain
+	} else {
+		query.Filter.NameContains = text
+	}
+
+	users, err := repository.ListUsers(ctx, query)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(s.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tEMAIL\tNAME\tCREATED")
+	for _, u := range users {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", u.ID, u.Email, u.Name, u.CreatedAt.Format("2006-01-02 15:04"))
+	}
+	w.Flush()
+	fmt.Fprintf(s.out, "%d user(s)\n", len(users))
+	return nil
+}
+
+func cmdJobs(ctx context.Context, s *Session, args []string) error {
+	filter := jobs.Filter{Limit: 20}
+	if len(args) > 0 {
+		filter.Status = args[0]
+	}
+	if len(args) > 1 {
+		filter.Queue = args[1]
+	}
+
+	list, err := jobs.List(ctx, filter)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(s.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tQUEUE\tTYPE\tSTATUS\tATTEMPTS\tRUN AT")
+	for _, j := range list {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%d/%d\t%s\n", j.ID, j.Queue, j.Type, j.Status,
+			j.Attempts, j.MaxAttempts, j.RunAt.Format("2006-01-02 15:04:05"))
+	}
+	return w.Flush()
+}
+
+func cmdJob(ctx context.Context, s *Session, args []string) error {
+	id, err := jobID("job", args)
+	if err != nil {
+		return err
+	}
+	job, err := jobs.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	return s.printJSON(job)
+}
+
+func cmdRetry(ctx context.Context, s *Session, args []string) error {
+	id, err := jobID("retry", args)
+	if err != nil {
+		return err
+	}
+	job, err := jobs.Retry(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := operations.JobRetried(ctx, id); err != nil {
+		fmt.Fprintf(s.out, "warning: failed to reset operation: %v\n", err)
+	}
+	fmt.Fprintf(s.out, "job %d requeued\n", job.ID)
+	return nil
+}
+
+func cmdCancel(ctx context.Context, s *Session, args []string) error {
+	id, err := jobID("cancel", args)
+	if err != nil {
+		return err
+	}
+	job, err := jobs.Cancel(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := operations.JobCancelled(ctx, id); err != nil {
+		fmt.Fprintf(s.out, "warning: failed to cancel operation: %v\n", err)
+	}
+	fmt.Fprintf(s.out, "job %d cancelled\n", job.ID)
+	return nil
+}
+
+func cmdRetryDead(ctx context.Context, s *Session, args []string) error {
+	queue := ""
+	if len(args) > 0 {
+		queue = args[0]
+	}
+	count, err := jobs.RetryDead(ctx, queue)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(s.out, "%d job(s) requeued\n", count)
+	return nil
+}
+
+func cmdStats(ctx context.Context, s *Session, args []string) error {
+	stats, err := jobs.Stats(ctx)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(s.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "QUEUE\tQUEUED\tDUE\tRUNNING\tDEAD\tOLDEST DUE\tAVG WAIT")
+	for _, q := range stats {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%.1fs\t%.1fs\n", q.Queue, q.Queued, q.Due, q.Running, q.Dead, q.OldestDue, q.AvgWait)
+	}
+	return w.Flush()
+}
+
+func cmdOperation(ctx context.Context, s *Session, args []string) error {
+	if len(args) != 1 {
+		return usage("operation")
+	}
+	op, err := operations.Get(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	return s.printJSON(op)
+}
+
+func jobID(name string, args []string) (int64, error) {
+	if len(args) != 1 {
+		return 0, usage(name)
+	}
+	id, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid job ID %q", args[0])
+	}
+	return id, nil
+}
+
+func usage(name string) error {
+	return fmt.Errorf("usage: %s", commands[name].usage)
+}
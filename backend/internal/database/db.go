@@ -4,7 +4,8 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
-	"os"
+
+	"pygorp/backend/internal/env"
 
 	_ "github.com/lib/pq"
 )
@@ -12,18 +13,8 @@ import (
 var DB *sql.DB
 
 func InitDB() error {
-	host := getEnv("DB_HOST", "localhost")
-	port := getEnv("DB_PORT", "5432")
-	user := getEnv("DB_USER", "postgres")
-	password := getEnv("DB_PASSWORD", "password")
-	dbname := getEnv("DB_NAME", "pygorp")
-	sslmode := getEnv("DB_SSLMODE", "disable")
-
-	connStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
-		host, port, user, password, dbname, sslmode)
-
 	var err error
-	DB, err = sql.Open("postgres", connStr)
+	DB, err = sql.Open("postgres", connString(env.String("DB_HOST", "localhost"), env.String("DB_PORT", "5432")))
 	if err != nil {
 		return fmt.Errorf("failed to open database: %v", err)
 	}
@@ -33,17 +24,29 @@ func InitDB() error {
 	}
 
 	log.Println("Successfully connected to PostgreSQL database")
+	initReplica()
 	return nil
 }
 
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
-	}
-	return defaultValue
+// connString builds a connection string for host and port with the
+// credentials and options from the environment. The backend's connections
+// set app.bypass_rls, so the row-level security policies, which fail closed,
+// let jobs, the CLI, and requests without a tenant see every row; tenant
+// transactions turn it off (see BeginTenant).
+func connString(host, port string) string {
+	user := env.String("DB_USER", "postgres")
+	password := env.String("DB_PASSWORD", "password")
+	dbname := env.String("DB_NAME", "pygorp")
+	sslmode := env.String("DB_SSLMODE", "disable")
+
+	return fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s options='-c app.bypass_rls=on'",
+		host, port, user, password, dbname, sslmode)
 }
 
 func CloseDB() error {
+	if replica != nil {
+		replica.Close()
+	}
 	if DB != nil {
 		return DB.Close()
 	}
@@ -0,0 +1,216 @@
+package database
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"log"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+type Migration struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	Up        string     `json:"-"`
+	Down      string     `json:"-"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+}
+
+// Migrations returns the embedded migrations ordered by version. Files are
+// named <version>_<name>.up.sql and <version>_<name>.down.sql.
+func Migrations() ([]*Migration, error) {
+	entries, err := migrationFiles.ReadDir("migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		file := entry.Name()
+		base, direction, ok := strings.Cut(strings.TrimSuffix(file, ".sql"), ".")
+		if !ok || (direction != "up" && direction != "down") {
+			return nil, fmt.Errorf("invalid migration file name %q", file)
+		}
+		prefix, name, _ := strings.Cut(base, "_")
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %q", file)
+		}
+
+		body, err := migrationFiles.ReadFile(path.Join("migrations", file))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		}
+		if direction == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	migrations := make([]*Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		migrations = append(migrations, m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// MigrationStatus returns all migrations with AppliedAt set for the ones
+// already applied.
+func MigrationStatus() ([]*Migration, error) {
+	if err := ensureMigrationsTable(); err != nil {
+		return nil, err
+	}
+
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := DB.Query("SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema_migrations: %v", err)
+	}
+	defer rows.Close()
+
+	applied := map[int]time.Time{}
+	for rows.Next() {
+		var version int
+		var at time.Time
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		applied[version] = at
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, m := range migrations {
+		if at, ok := applied[m.Version]; ok {
+			m.AppliedAt = &at
+		}
+	}
+	return migrations, nil
+}
+
+// Migrate applies all pending migrations, each in its own transaction, and
+// returns the ones it applied. Pending migrations are checked first; unsafe
+// findings stop the run unless force is set. Row-level security is then
+// turned on or off to match DB_RLS (see SyncRLS).
+func Migrate(force bool) ([]*Migration, error) {
+	plans, err := Plan()
+	if err != nil {
+		return nil, err
+	}
+	var unsafe []string
+	for _, p := range plans {
+		for _, f := range p.Findings(SeverityWarning) {
+			log.Printf("Warning in migration %04d_%s: %s", p.Migration.Version, p.Migration.Name, f.Message)
+		}
+		for _, f := range p.Findings(SeverityUnsafe) {
+			unsafe = append(unsafe, fmt.Sprintf("%04d_%s: %s", p.Migration.Version, p.Migration.Name, f.Message))
+		}
+	}
+	if len(unsafe) > 0 {
+		if !force {
+			return nil, fmt.Errorf("refusing to apply unsafe migrations:\n  %s", strings.Join(unsafe, "\n  "))
+		}
+		log.Printf("Applying unsafe migrations because of --force:\n  %s", strings.Join(unsafe, "\n  "))
+	}
+
+	migrations, err := MigrationStatus()
+	if err != nil {
+		return nil, err
+	}
+
+	var applied []*Migration
+	defer func() {
+		if len(applied) > 0 {
+			ResetStatements()
+		}
+	}()
+	for _, m := range migrations {
+		if m.AppliedAt != nil {
+			continue
+		}
+		if err := runMigration(m.Up, "INSERT INTO schema_migrations (version, name) VALUES ($1, $2)", m.Version, m.Name); err != nil {
+			return applied, fmt.Errorf("migration %04d_%s failed: %v", m.Version, m.Name, err)
+		}
+		log.Printf("Applied migration %04d_%s", m.Version, m.Name)
+		applied = append(applied, m)
+	}
+	return applied, SyncRLS(context.Background())
+}
+
+// Rollback reverts the given number of most recently applied migrations.
+func Rollback(steps int) ([]*Migration, error) {
+	migrations, err := MigrationStatus()
+	if err != nil {
+		return nil, err
+	}
+
+	var reverted []*Migration
+	defer func() {
+		if len(reverted) > 0 {
+			ResetStatements()
+		}
+	}()
+	for i := len(migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+		m := migrations[i]
+		if m.AppliedAt == nil {
+			continue
+		}
+		if m.Down == "" {
+			return reverted, fmt.Errorf("migration %04d_%s has no down migration", m.Version, m.Name)
+		}
+		if err := runMigration(m.Down, "DELETE FROM schema_migrations WHERE version = $1", m.Version); err != nil {
+			return reverted, fmt.Errorf("rollback of %04d_%s failed: %v", m.Version, m.Name, err)
+		}
+		log.Printf("Rolled back migration %04d_%s", m.Version, m.Name)
+		reverted = append(reverted, m)
+	}
+	return reverted, nil
+}
+
+func runMigration(body, record string, args ...interface{}) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(body); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(record, args...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func ensureMigrationsTable() error {
+	_, err := DB.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		applied_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %v", err)
+	}
+	return nil
+}
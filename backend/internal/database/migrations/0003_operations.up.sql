@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS operations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    type VARCHAR(100) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    progress INTEGER NOT NULL DEFAULT 0,
+    job_id BIGINT REFERENCES jobs(id) ON DELETE SET NULL,
+    result JSONB,
+    result_file BYTEA,
+    result_content_type VARCHAR(100),
+    error TEXT,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    completed_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_operations_job_id ON operations(job_id);
+
+DROP TRIGGER IF EXISTS update_operations_updated_at ON operations;
+CREATE TRIGGER update_operations_updated_at
+    BEFORE UPDATE ON operations
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_jobs_queue_status;
+
+UPDATE jobs SET status = 'failed' WHERE status IN ('dead', 'cancelled');
@@ -0,0 +1,3 @@
+UPDATE jobs SET status = 'dead' WHERE status = 'failed';
+
+CREATE INDEX IF NOT EXISTS idx_jobs_queue_status ON jobs(queue, status);
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS sent_emails (
+    id BIGSERIAL PRIMARY KEY,
+    recipient VARCHAR(255) NOT NULL,
+    subject TEXT NOT NULL,
+    template VARCHAR(100),
+    provider VARCHAR(50),
+    provider_message_id VARCHAR(255),
+    status VARCHAR(20) NOT NULL,
+    error TEXT,
+    attempts JSONB NOT NULL DEFAULT '[]',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_sent_emails_recipient ON sent_emails(recipient);
+CREATE INDEX IF NOT EXISTS idx_sent_emails_status ON sent_emails(status);
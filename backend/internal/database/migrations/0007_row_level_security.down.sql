@@ -0,0 +1,12 @@
+DROP POLICY IF EXISTS ai_requests_owner ON ai_requests;
+ALTER TABLE ai_requests NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE ai_requests DISABLE ROW LEVEL SECURITY;
+
+DROP POLICY IF EXISTS users_tenant ON users;
+ALTER TABLE users NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE users DISABLE ROW LEVEL SECURITY;
+
+DROP FUNCTION IF EXISTS app_setting_int(TEXT);
+
+DROP INDEX IF EXISTS idx_users_org_id;
+ALTER TABLE users DROP COLUMN IF EXISTS org_id;
@@ -0,0 +1,22 @@
+-- Row-level security for tenant isolation. The backend sets
+-- app.current_user_id and app.current_org_id per request with SET LOCAL
+-- (see DB_RLS). When they are unset every row stays visible, so jobs, the
+-- CLI, and deployments without RLS behave as before. The policies only take
+-- effect once RLS is enabled on the tables, which `pygorp migrate up` does
+-- when DB_RLS=true (see database.SyncRLS).
+ALTER TABLE users ADD COLUMN IF NOT EXISTS org_id INTEGER;
+
+CREATE INDEX IF NOT EXISTS idx_users_org_id ON users(org_id);
+
+CREATE OR REPLACE FUNCTION app_setting_int(name TEXT)
+RETURNS INTEGER AS $$
+    SELECT NULLIF(current_setting(name, true), '')::INTEGER
+$$ LANGUAGE sql STABLE;
+
+DROP POLICY IF EXISTS users_tenant ON users;
+CREATE POLICY users_tenant ON users
+    USING (app_setting_int('app.current_org_id') IS NULL OR org_id = app_setting_int('app.current_org_id'));
+
+DROP POLICY IF EXISTS ai_requests_owner ON ai_requests;
+CREATE POLICY ai_requests_owner ON ai_requests
+    USING (app_setting_int('app.current_user_id') IS NULL OR user_id = app_setting_int('app.current_user_id'));
@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS magic_links (
+    id VARCHAR(64) PRIMARY KEY,
+    email VARCHAR(255) NOT NULL,
+    -- NULL when nobody has the email; the request is still recorded so the
+    -- per-email limit does not reveal which addresses have accounts.
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    ip VARCHAR(64) NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    fingerprint VARCHAR(64) NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    consumed_at TIMESTAMP WITH TIME ZONE,
+    consumed_ip VARCHAR(64),
+    consumed_user_agent TEXT,
+    consumed_fingerprint VARCHAR(64)
+);
+
+CREATE INDEX IF NOT EXISTS idx_magic_links_email_created_at ON magic_links(email, created_at);
@@ -0,0 +1,5 @@
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS scopes TEXT[] NOT NULL DEFAULT '{}';
+
+-- Sessions started before scopes existed keep the access they had.
+UPDATE sessions SET scopes = ARRAY['users:read', 'users:write', 'groups:read', 'groups:write', 'tasks:write', 'events:read']
+WHERE scopes = '{}';
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS bootstrap_resources;
+DROP TABLE IF EXISTS feature_flags;
+DROP TABLE IF EXISTS orgs;
@@ -0,0 +1,24 @@
+-- Organizations referenced by users.org_id.
+CREATE TABLE IF NOT EXISTS orgs (
+    id INTEGER PRIMARY KEY,
+    name VARCHAR(100) UNIQUE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Feature flags set by `pygorp apply`, layered between the environment and
+-- the config file.
+CREATE TABLE IF NOT EXISTS feature_flags (
+    name VARCHAR(100) PRIMARY KEY,
+    enabled BOOLEAN NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Rows created from a bootstrap file, so removing them from the file
+-- removes them from the database.
+CREATE TABLE IF NOT EXISTS bootstrap_resources (
+    kind VARCHAR(30) NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    resource_id INTEGER NOT NULL,
+    PRIMARY KEY (kind, name)
+);
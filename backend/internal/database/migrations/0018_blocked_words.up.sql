@@ -0,0 +1,7 @@
+-- Words that may not appear in user-provided names. Words from
+-- BLOCKLIST_FILE are checked as well but not stored here.
+CREATE TABLE IF NOT EXISTS blocked_words (
+    word VARCHAR(100) PRIMARY KEY,
+    match_type VARCHAR(20) NOT NULL DEFAULT 'normalized',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS user_flags;
+DROP TABLE IF EXISTS disposable_domains;
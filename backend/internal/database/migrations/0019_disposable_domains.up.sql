@@ -0,0 +1,15 @@
+-- Disposable email domains fetched from DISPOSABLE_DOMAINS_URL, checked
+-- along with the list bundled in the binary.
+CREATE TABLE IF NOT EXISTS disposable_domains (
+    domain VARCHAR(255) PRIMARY KEY,
+    fetched_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Users flagged for review, e.g. for signing up with a disposable email.
+CREATE TABLE IF NOT EXISTS user_flags (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    flag VARCHAR(50) NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, flag)
+);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_users_purge_at;
+ALTER TABLE users DROP COLUMN IF EXISTS purge_at;
+ALTER TABLE users DROP COLUMN IF EXISTS deletion_requested_at;
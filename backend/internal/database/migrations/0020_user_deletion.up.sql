@@ -0,0 +1,6 @@
+-- Users whose deletion was requested are kept until purge_at, so the
+-- deletion can be cancelled during the grace period.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deletion_requested_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS purge_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_users_purge_at ON users(purge_at) WHERE purge_at IS NOT NULL;
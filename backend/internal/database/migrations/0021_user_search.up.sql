@@ -0,0 +1,6 @@
+-- Trigram indexes for the user suggest endpoint. They serve the prefix
+-- LIKE matches on lower(name) and lower(email) and the similarity ranking.
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX IF NOT EXISTS idx_users_name_trgm ON users USING GIN (lower(name) gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_users_email_trgm ON users USING GIN (lower(email) gin_trgm_ops);
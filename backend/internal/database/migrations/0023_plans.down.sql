@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS org_usage;
+ALTER TABLE orgs DROP COLUMN IF EXISTS plan;
+DROP TABLE IF EXISTS plans;
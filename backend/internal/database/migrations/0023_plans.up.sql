@@ -0,0 +1,21 @@
+-- Plans set per-tenant rate limits by rate-limit class, falling back to the
+-- "default" class, and a daily request quota (0 for none). An org without a
+-- plan is limited per client IP like anonymous callers.
+CREATE TABLE IF NOT EXISTS plans (
+    name VARCHAR(50) PRIMARY KEY,
+    rate_limits JSONB NOT NULL DEFAULT '{}',
+    daily_quota INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE orgs ADD COLUMN IF NOT EXISTS plan VARCHAR(50) REFERENCES plans(name) ON DELETE SET NULL;
+
+-- Requests counted against each org's daily quota, by UTC day. Instances
+-- add their counts every few seconds.
+CREATE TABLE IF NOT EXISTS org_usage (
+    org_id INTEGER NOT NULL REFERENCES orgs(id) ON DELETE CASCADE,
+    day DATE NOT NULL,
+    requests BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (org_id, day)
+);
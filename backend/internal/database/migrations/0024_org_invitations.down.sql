@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS org_invitations;
+ALTER TABLE users DROP COLUMN IF EXISTS org_role;
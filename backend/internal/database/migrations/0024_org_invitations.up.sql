@@ -0,0 +1,23 @@
+-- A user's role in their org: owner, admin, or member. NULL for users
+-- placed in an org before roles existed, who count as members.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS org_role VARCHAR(20);
+
+-- Emailed invitations to join an org. The link carries the ID signed with
+-- AUTH_SECRET, so the table alone cannot be used to accept one.
+CREATE TABLE IF NOT EXISTS org_invitations (
+    id UUID PRIMARY KEY,
+    org_id INTEGER NOT NULL REFERENCES orgs(id) ON DELETE CASCADE,
+    email VARCHAR(255) NOT NULL,
+    role VARCHAR(20) NOT NULL,
+    invited_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    sent_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    accepted_at TIMESTAMP WITH TIME ZONE,
+    accepted_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    revoked_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- One open invitation per email and org.
+CREATE UNIQUE INDEX IF NOT EXISTS idx_org_invitations_open
+    ON org_invitations(org_id, LOWER(email)) WHERE accepted_at IS NULL AND revoked_at IS NULL;
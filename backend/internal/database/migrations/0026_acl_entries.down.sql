@@ -0,0 +1,6 @@
+DROP TRIGGER IF EXISTS delete_teams_acl_entries ON teams;
+DROP TRIGGER IF EXISTS delete_users_acl_entries ON users;
+DROP TRIGGER IF EXISTS delete_projects_acl_entries ON projects;
+DROP FUNCTION IF EXISTS delete_acl_entries_for_principal();
+DROP FUNCTION IF EXISTS delete_acl_entries_for_resource();
+DROP TABLE IF EXISTS acl_entries;
@@ -0,0 +1,52 @@
+-- Shares of a resource, such as project 12, with a principal ("user:5" or
+-- "team:3", in the policy engine's subject format). Write implies read.
+CREATE TABLE IF NOT EXISTS acl_entries (
+    id SERIAL PRIMARY KEY,
+    resource_type VARCHAR(50) NOT NULL,
+    resource_id INTEGER NOT NULL,
+    principal VARCHAR(100) NOT NULL,
+    permission VARCHAR(10) NOT NULL,
+    granted_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (resource_type, resource_id, principal)
+);
+
+CREATE INDEX IF NOT EXISTS idx_acl_entries_principal ON acl_entries(principal);
+
+-- Entries have no foreign keys, since they point at any table, so deleting
+-- a resource or principal deletes its entries here. The trigger argument is
+-- the resource type or principal kind the table stands for.
+CREATE OR REPLACE FUNCTION delete_acl_entries_for_resource()
+RETURNS TRIGGER AS $$
+BEGIN
+    DELETE FROM acl_entries WHERE resource_type = TG_ARGV[0] AND resource_id = OLD.id;
+    RETURN OLD;
+END;
+$$ language 'plpgsql';
+
+CREATE OR REPLACE FUNCTION delete_acl_entries_for_principal()
+RETURNS TRIGGER AS $$
+BEGIN
+    DELETE FROM acl_entries WHERE principal = TG_ARGV[0] || ':' || OLD.id;
+    RETURN OLD;
+END;
+$$ language 'plpgsql';
+
+DROP TRIGGER IF EXISTS delete_projects_acl_entries ON projects;
+CREATE TRIGGER delete_projects_acl_entries
+    AFTER DELETE ON projects
+    FOR EACH ROW
+    EXECUTE FUNCTION delete_acl_entries_for_resource('project');
+
+DROP TRIGGER IF EXISTS delete_users_acl_entries ON users;
+CREATE TRIGGER delete_users_acl_entries
+    AFTER DELETE ON users
+    FOR EACH ROW
+    EXECUTE FUNCTION delete_acl_entries_for_principal('user');
+
+DROP TRIGGER IF EXISTS delete_teams_acl_entries ON teams;
+CREATE TRIGGER delete_teams_acl_entries
+    AFTER DELETE ON teams
+    FOR EACH ROW
+    EXECUTE FUNCTION delete_acl_entries_for_principal('team');
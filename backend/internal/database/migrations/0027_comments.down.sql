@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS delete_projects_comments ON projects;
+DROP FUNCTION IF EXISTS delete_comments_for_resource();
+DROP TABLE IF EXISTS comments;
@@ -0,0 +1,33 @@
+-- Comments on any resource, such as project 12. Replies point at their
+-- parent; deleted comments keep their row, without a body, so threads
+-- stay intact. mentions holds the users the body mentions and who were
+-- notified.
+CREATE TABLE IF NOT EXISTS comments (
+    id SERIAL PRIMARY KEY,
+    resource_type VARCHAR(50) NOT NULL,
+    resource_id INTEGER NOT NULL,
+    parent_id INTEGER REFERENCES comments(id) ON DELETE CASCADE,
+    author_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    body TEXT NOT NULL,
+    mentions INTEGER[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_comments_resource ON comments(resource_type, resource_id, parent_id, created_at);
+
+-- Like ACL entries, comments have no foreign key to their resource.
+CREATE OR REPLACE FUNCTION delete_comments_for_resource()
+RETURNS TRIGGER AS $$
+BEGIN
+    DELETE FROM comments WHERE resource_type = TG_ARGV[0] AND resource_id = OLD.id;
+    RETURN OLD;
+END;
+$$ language 'plpgsql';
+
+DROP TRIGGER IF EXISTS delete_projects_comments ON projects;
+CREATE TRIGGER delete_projects_comments
+    AFTER DELETE ON projects
+    FOR EACH ROW
+    EXECUTE FUNCTION delete_comments_for_resource('project');
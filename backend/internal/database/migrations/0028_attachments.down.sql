@@ -0,0 +1,6 @@
+DROP TRIGGER IF EXISTS delete_projects_attachments ON projects;
+DROP FUNCTION IF EXISTS delete_attachments_for_resource();
+DROP TRIGGER IF EXISTS queue_attachments_deletion ON attachments;
+DROP FUNCTION IF EXISTS queue_attachment_deletion();
+DROP TABLE IF EXISTS storage_deletions;
+DROP TABLE IF EXISTS attachments;
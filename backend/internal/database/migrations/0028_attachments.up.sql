@@ -0,0 +1,50 @@
+-- Files attached to any resource, such as project 12. The bytes live in
+-- object storage under storage_key.
+CREATE TABLE IF NOT EXISTS attachments (
+    id SERIAL PRIMARY KEY,
+    resource_type VARCHAR(50) NOT NULL,
+    resource_id INTEGER NOT NULL,
+    filename VARCHAR(255) NOT NULL,
+    content_type VARCHAR(255) NOT NULL,
+    size BIGINT NOT NULL,
+    storage_key VARCHAR(255) NOT NULL UNIQUE,
+    uploaded_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_attachments_resource ON attachments(resource_type, resource_id, created_at);
+
+-- Objects whose rows were deleted, however that happened, wait here until
+-- a worker deletes them from storage.
+CREATE TABLE IF NOT EXISTS storage_deletions (
+    storage_key VARCHAR(255) PRIMARY KEY,
+    deleted_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE OR REPLACE FUNCTION queue_attachment_deletion()
+RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO storage_deletions (storage_key) VALUES (OLD.storage_key) ON CONFLICT DO NOTHING;
+    RETURN OLD;
+END;
+$$ language 'plpgsql';
+
+DROP TRIGGER IF EXISTS queue_attachments_deletion ON attachments;
+CREATE TRIGGER queue_attachments_deletion
+    AFTER DELETE ON attachments
+    FOR EACH ROW
+    EXECUTE FUNCTION queue_attachment_deletion();
+
+CREATE OR REPLACE FUNCTION delete_attachments_for_resource()
+RETURNS TRIGGER AS $$
+BEGIN
+    DELETE FROM attachments WHERE resource_type = TG_ARGV[0] AND resource_id = OLD.id;
+    RETURN OLD;
+END;
+$$ language 'plpgsql';
+
+DROP TRIGGER IF EXISTS delete_projects_attachments ON projects;
+CREATE TRIGGER delete_projects_attachments
+    AFTER DELETE ON projects
+    FOR EACH ROW
+    EXECUTE FUNCTION delete_attachments_for_resource('project');
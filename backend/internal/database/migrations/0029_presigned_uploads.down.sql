@@ -0,0 +1,2 @@
+DROP TRIGGER IF EXISTS queue_presigned_uploads_deletion ON presigned_uploads;
+DROP TABLE IF EXISTS presigned_uploads;
@@ -0,0 +1,25 @@
+-- Uploads a client sends straight to object storage through a pre-signed
+-- URL. Each becomes an attachment once the client completes it; rows stay
+-- until the URL has expired so the staged object is only deleted when it
+-- can no longer be overwritten.
+CREATE TABLE IF NOT EXISTS presigned_uploads (
+    id VARCHAR(36) PRIMARY KEY,
+    resource_type VARCHAR(50) NOT NULL,
+    resource_id INTEGER NOT NULL,
+    filename VARCHAR(255) NOT NULL,
+    content_type VARCHAR(255) NOT NULL,
+    size BIGINT NOT NULL,
+    storage_key VARCHAR(255) NOT NULL UNIQUE,
+    created_by INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    completed_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_presigned_uploads_expires_at ON presigned_uploads(expires_at);
+
+DROP TRIGGER IF EXISTS queue_presigned_uploads_deletion ON presigned_uploads;
+CREATE TRIGGER queue_presigned_uploads_deletion
+    AFTER DELETE ON presigned_uploads
+    FOR EACH ROW
+    EXECUTE FUNCTION queue_attachment_deletion();
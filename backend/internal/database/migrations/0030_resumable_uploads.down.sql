@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS queue_resumable_uploads_chunks ON resumable_uploads;
+DROP FUNCTION IF EXISTS queue_resumable_upload_chunks();
+DROP TABLE IF EXISTS resumable_uploads;
@@ -0,0 +1,44 @@
+-- Uploads sent in chunks with the tus protocol. Each PATCH is stored as an
+-- object in chunk_keys, in order, until upload_offset reaches
+-- upload_length and the chunks are assembled for the target.
+CREATE TABLE IF NOT EXISTS resumable_uploads (
+    id VARCHAR(36) PRIMARY KEY,
+    target VARCHAR(50) NOT NULL,
+    resource_type VARCHAR(50),
+    resource_id INTEGER,
+    filename VARCHAR(255) NOT NULL DEFAULT '',
+    metadata TEXT NOT NULL DEFAULT '',
+    upload_length BIGINT NOT NULL,
+    upload_offset BIGINT NOT NULL DEFAULT 0,
+    chunk_keys TEXT[] NOT NULL DEFAULT '{}',
+    created_by INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    completed_at TIMESTAMP WITH TIME ZONE,
+    result VARCHAR(255) NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS idx_resumable_uploads_expires_at ON resumable_uploads(expires_at);
+
+-- Chunks dropped from an upload, or left by a deleted one, are queued for
+-- deletion from storage.
+CREATE OR REPLACE FUNCTION queue_resumable_upload_chunks()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        INSERT INTO storage_deletions (storage_key)
+        SELECT unnest(OLD.chunk_keys) ON CONFLICT DO NOTHING;
+        RETURN OLD;
+    END IF;
+    INSERT INTO storage_deletions (storage_key)
+    SELECT unnest(OLD.chunk_keys) EXCEPT SELECT unnest(NEW.chunk_keys) ON CONFLICT DO NOTHING;
+    RETURN NEW;
+END;
+$$ language 'plpgsql';
+
+DROP TRIGGER IF EXISTS queue_resumable_uploads_chunks ON resumable_uploads;
+CREATE TRIGGER queue_resumable_uploads_chunks
+    AFTER UPDATE OF chunk_keys OR DELETE ON resumable_uploads
+    FOR EACH ROW
+    EXECUTE FUNCTION queue_resumable_upload_chunks();
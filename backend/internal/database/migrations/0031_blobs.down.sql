@@ -0,0 +1,11 @@
+DROP TRIGGER IF EXISTS queue_attachments_deletion ON attachments;
+CREATE TRIGGER queue_attachments_deletion
+    AFTER DELETE ON attachments
+    FOR EACH ROW
+    EXECUTE FUNCTION queue_attachment_deletion();
+DROP FUNCTION IF EXISTS release_attachment_blob();
+
+-- Attachments keep their blob keys. Several can share one object, which
+-- is deleted along with the first of them after this rollback.
+ALTER TABLE attachments DROP COLUMN IF EXISTS sha256;
+DROP TABLE IF EXISTS blobs;
@@ -0,0 +1,39 @@
+-- Attachment bytes stored once per distinct content, keyed by SHA-256.
+-- ref_count counts the attachments and in-flight uploads using a blob;
+-- stored is set once its object is in storage. Blobs left unreferenced are
+-- garbage-collected by a worker.
+CREATE TABLE IF NOT EXISTS blobs (
+    sha256 CHAR(64) PRIMARY KEY,
+    storage_key VARCHAR(255) NOT NULL UNIQUE,
+    size BIGINT NOT NULL,
+    ref_count INTEGER NOT NULL DEFAULT 0 CHECK (ref_count >= 0),
+    stored BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    unreferenced_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_blobs_unreferenced_at ON blobs(unreferenced_at) WHERE ref_count = 0;
+
+ALTER TABLE attachments ADD COLUMN IF NOT EXISTS sha256 CHAR(64);
+
+-- Deleting an attachment drops its reference to its blob. Attachments from
+-- before blobs have their object queued for deletion as before.
+CREATE OR REPLACE FUNCTION release_attachment_blob()
+RETURNS TRIGGER AS $$
+BEGIN
+    UPDATE blobs
+    SET ref_count = ref_count - 1,
+        unreferenced_at = CASE WHEN ref_count = 1 THEN CURRENT_TIMESTAMP ELSE unreferenced_at END
+    WHERE storage_key = OLD.storage_key;
+    IF NOT FOUND THEN
+        INSERT INTO storage_deletions (storage_key) VALUES (OLD.storage_key) ON CONFLICT DO NOTHING;
+    END IF;
+    RETURN OLD;
+END;
+$$ language 'plpgsql';
+
+DROP TRIGGER IF EXISTS queue_attachments_deletion ON attachments;
+CREATE TRIGGER queue_attachments_deletion
+    AFTER DELETE ON attachments
+    FOR EACH ROW
+    EXECUTE FUNCTION release_attachment_blob();
@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS queue_attachment_previews_deletion ON attachment_previews;
+DROP FUNCTION IF EXISTS queue_preview_deletion();
+DROP TABLE IF EXISTS attachment_previews;
@@ -0,0 +1,32 @@
+-- Resized previews of attachments, rendered on demand by a worker. They
+-- are keyed by the source object, so attachments sharing a blob share
+-- their previews too.
+CREATE TABLE IF NOT EXISTS attachment_previews (
+    id SERIAL PRIMARY KEY,
+    source_key VARCHAR(255) NOT NULL,
+    width INTEGER NOT NULL,
+    height INTEGER NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    storage_key VARCHAR(255),
+    content_type VARCHAR(255) NOT NULL DEFAULT '',
+    error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (source_key, width, height)
+);
+
+CREATE OR REPLACE FUNCTION queue_preview_deletion()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF OLD.storage_key IS NOT NULL THEN
+        INSERT INTO storage_deletions (storage_key) VALUES (OLD.storage_key) ON CONFLICT DO NOTHING;
+    END IF;
+    RETURN OLD;
+END;
+$$ language 'plpgsql';
+
+DROP TRIGGER IF EXISTS queue_attachment_previews_deletion ON attachment_previews;
+CREATE TRIGGER queue_attachment_previews_deletion
+    AFTER DELETE ON attachment_previews
+    FOR EACH ROW
+    EXECUTE FUNCTION queue_preview_deletion();
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_projects_purge_at;
+ALTER TABLE users DROP COLUMN IF EXISTS deletion_requested_by;
+ALTER TABLE projects DROP COLUMN IF EXISTS purge_at;
+ALTER TABLE projects DROP COLUMN IF EXISTS deleted_by;
+ALTER TABLE projects DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,4 @@
+DROP TRIGGER IF EXISTS record_user_history ON users;
+DROP FUNCTION IF EXISTS record_user_history();
+DROP FUNCTION IF EXISTS user_history_state(users);
+DROP TABLE IF EXISTS user_history;
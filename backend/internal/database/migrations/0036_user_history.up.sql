@@ -0,0 +1,64 @@
+-- Every version of a user. A trigger records the user as created, or as it
+-- was when this migration ran, as version 1, and each update that changes a
+-- tracked field as the next version, with the full state and the fields it
+-- changed as {"field": {"from": ..., "to": ...}}. changed_by is the user the
+-- request ran as, known when it ran in a tenant transaction (DB_RLS).
+CREATE TABLE IF NOT EXISTS user_history (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    version INTEGER NOT NULL,
+    state JSONB NOT NULL,
+    changes JSONB NOT NULL,
+    changed_by INTEGER,
+    changed_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (user_id, version)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_history_changes ON user_history USING GIN (changes);
+
+CREATE OR REPLACE FUNCTION user_history_state(u users)
+RETURNS JSONB AS $$
+    SELECT jsonb_build_object(
+        'email', u.email, 'name', u.name, 'attributes', u.attributes, 'avatar', u.avatar,
+        'external_id', u.external_id, 'active', u.active, 'org_id', u.org_id, 'org_role', u.org_role)
+$$ LANGUAGE sql IMMUTABLE;
+
+CREATE OR REPLACE FUNCTION record_user_history()
+RETURNS TRIGGER AS $$
+DECLARE
+    old_state JSONB := '{}';
+    new_state JSONB := user_history_state(NEW);
+    diff JSONB;
+BEGIN
+    IF TG_OP = 'UPDATE' THEN
+        old_state := user_history_state(OLD);
+    END IF;
+    SELECT COALESCE(jsonb_object_agg(n.key, jsonb_build_object('from', o.value, 'to', n.value)), '{}')
+    INTO diff
+    FROM jsonb_each(new_state) n LEFT JOIN jsonb_each(old_state) o ON o.key = n.key
+    WHERE o.value IS DISTINCT FROM n.value AND NOT (o.value IS NULL AND n.value = 'null');
+    IF diff = '{}' AND TG_OP = 'UPDATE' THEN
+        RETURN NULL;
+    END IF;
+
+    INSERT INTO user_history (user_id, version, state, changes, changed_by, changed_at)
+    SELECT NEW.id, COALESCE(MAX(version), 0) + 1, new_state, diff,
+        app_setting_int('app.current_user_id'), COALESCE(NEW.updated_at, CURRENT_TIMESTAMP)
+    FROM user_history WHERE user_id = NEW.id;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+-- safety:ignore Every existing user gets version 1 once, like a new user.
+INSERT INTO user_history (user_id, version, state, changes, changed_at)
+SELECT u.id, 1, s.state,
+    (SELECT COALESCE(jsonb_object_agg(key, jsonb_build_object('from', NULL, 'to', value)), '{}')
+     FROM jsonb_each(s.state) WHERE value <> 'null'),
+    COALESCE(u.updated_at, u.created_at, CURRENT_TIMESTAMP)
+FROM users u CROSS JOIN LATERAL (SELECT user_history_state(u) AS state) s
+ON CONFLICT DO NOTHING;
+
+DROP TRIGGER IF EXISTS record_user_history ON users;
+CREATE TRIGGER record_user_history
+    AFTER INSERT OR UPDATE ON users
+    FOR EACH ROW
+    EXECUTE FUNCTION record_user_history();
@@ -0,0 +1 @@
+ALTER TABLE user_history DROP COLUMN IF EXISTS restored_from;
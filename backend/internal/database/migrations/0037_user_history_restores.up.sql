@@ -0,0 +1,2 @@
+-- Versions written by restoring a user to an earlier version name it.
+ALTER TABLE user_history ADD COLUMN IF NOT EXISTS restored_from INTEGER;
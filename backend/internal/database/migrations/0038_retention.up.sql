@@ -0,0 +1,3 @@
+-- Users anonymized by a retention rule keep their row, so references to
+-- them survive, but lose their personal data.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS anonymized_at TIMESTAMP WITH TIME ZONE;
@@ -0,0 +1,8 @@
+-- Users under legal hold must be kept as they are: purges, erasure, and
+-- retention rules skip them, and the foreign key refuses to delete them
+-- should anything else try.
+CREATE TABLE IF NOT EXISTS legal_holds (
+    user_id INTEGER PRIMARY KEY CONSTRAINT legal_holds_user_id_fkey REFERENCES users(id),
+    reason TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
@@ -0,0 +1,30 @@
+-- Versioned policy documents: the terms of service, the privacy policy, and
+-- marketing communications. Versions of a kind are numbered from 1 and never
+-- change once published. requires_consent makes users accept the version,
+-- or a later one, before they can use the API again.
+CREATE TABLE IF NOT EXISTS policy_documents (
+    kind TEXT NOT NULL,
+    version INTEGER NOT NULL,
+    title TEXT NOT NULL,
+    content TEXT NOT NULL,
+    requires_consent BOOLEAN NOT NULL DEFAULT FALSE,
+    published_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (kind, version)
+);
+
+-- Every consent given or withdrawn, with where it came from, as a record of
+-- what each user agreed to. A user's latest row of a kind is its current
+-- consent.
+CREATE TABLE IF NOT EXISTS consents (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    kind TEXT NOT NULL,
+    version INTEGER NOT NULL,
+    granted BOOLEAN NOT NULL,
+    ip TEXT,
+    user_agent TEXT,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    FOREIGN KEY (kind, version) REFERENCES policy_documents(kind, version)
+);
+
+CREATE INDEX IF NOT EXISTS idx_consents_user_kind ON consents (user_id, kind, id DESC);
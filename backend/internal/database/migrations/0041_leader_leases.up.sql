@@ -0,0 +1,10 @@
+-- Leases elect the one instance that runs a singleton background process,
+-- such as a queue's job scheduler. The holder renews its lease well before
+-- expires_at; once it lapses, another instance takes it over.
+CREATE TABLE IF NOT EXISTS leader_leases (
+    name TEXT PRIMARY KEY,
+    holder TEXT NOT NULL,
+    acquired_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    renewed_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
@@ -0,0 +1,11 @@
+DROP POLICY IF EXISTS users_tenant ON users;
+CREATE POLICY users_tenant ON users
+    USING (app_setting_int('app.current_org_id') IS NULL OR org_id = app_setting_int('app.current_org_id'));
+
+DROP POLICY IF EXISTS ai_requests_owner ON ai_requests;
+CREATE POLICY ai_requests_owner ON ai_requests
+    USING (app_setting_int('app.current_user_id') IS NULL OR user_id = app_setting_int('app.current_user_id'));
+
+ALTER TABLE users ALTER COLUMN org_id DROP DEFAULT;
+
+DROP FUNCTION IF EXISTS app_bypass_rls();
@@ -0,0 +1,22 @@
+-- Row-level security fails closed where it is enabled (DB_RLS=true): rows
+-- are only visible to the tenant they belong to, or to connections that set
+-- app.bypass_rls. The backend sets it on its connections and turns it off in
+-- tenant transactions, so a request that leaves its transaction or has no
+-- tenant settings sees nothing rather than everything. Users see their org's members, or only themselves
+-- outside an org, and new users join the org of the user creating them.
+CREATE OR REPLACE FUNCTION app_bypass_rls()
+RETURNS BOOLEAN AS $$
+    SELECT COALESCE(current_setting('app.bypass_rls', true), '') = 'on'
+$$ LANGUAGE sql STABLE;
+
+ALTER TABLE users ALTER COLUMN org_id SET DEFAULT app_setting_int('app.current_org_id');
+
+DROP POLICY IF EXISTS users_tenant ON users;
+CREATE POLICY users_tenant ON users
+    USING (app_bypass_rls()
+        OR org_id = app_setting_int('app.current_org_id')
+        OR id = app_setting_int('app.current_user_id'));
+
+DROP POLICY IF EXISTS ai_requests_owner ON ai_requests;
+CREATE POLICY ai_requests_owner ON ai_requests
+    USING (app_bypass_rls() OR user_id = app_setting_int('app.current_user_id'));
@@ -0,0 +1,4 @@
+-- The principal that started each operation, such as "user:12", so only it
+-- can read the operation and download its result. NULL for operations
+-- started anonymously or before owners were recorded.
+ALTER TABLE operations ADD COLUMN IF NOT EXISTS created_by TEXT;
@@ -0,0 +1,96 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net"
+	"os"
+	"strings"
+
+	"pygorp/backend/internal/env"
+	"pygorp/backend/internal/metrics"
+	"pygorp/backend/internal/region"
+
+	"github.com/lib/pq"
+)
+
+// replica is the read replica in this instance's region, or nil when there
+// is none and all reads go to the primary.
+var replica *sql.DB
+
+// initReplica connects to the DB_READ_REPLICAS entry for REGION. Entries
+// look like "eu-west-1=replica-euw1:5432", comma-separated; replicas in
+// other regions are ignored, since crossing regions would be slower than
+// the primary. A replica that cannot be reached at startup is not used.
+func initReplica() {
+	host, port, ok := localReplica(os.Getenv("DB_READ_REPLICAS"), region.Name())
+	if !ok {
+		return
+	}
+	db, err := sql.Open("postgres", connString(host, port))
+	if err == nil {
+		err = db.Ping()
+	}
+	if err != nil {
+		log.Printf("Read replica %s unavailable, reading from the primary: %v", host, err)
+		if db != nil {
+			db.Close()
+		}
+		return
+	}
+	replica = db
+	log.Printf("Reading from replica %s in region %s", host, region.Name())
+}
+
+// localReplica finds the replica for a region in a DB_READ_REPLICAS list.
+func localReplica(list, local string) (host, port string, ok bool) {
+	if local == "" {
+		return "", "", false
+	}
+	for _, entry := range strings.Split(list, ",") {
+		name, addr, found := strings.Cut(strings.TrimSpace(entry), "=")
+		if !found || name != local || addr == "" {
+			continue
+		}
+		if h, p, err := net.SplitHostPort(addr); err == nil {
+			return h, p, true
+		}
+		return addr, env.String("DB_PORT", "5432"), true
+	}
+	return "", "", false
+}
+
+// HasReplica reports whether reads go to a region-local replica.
+func HasReplica() bool {
+	return replica != nil
+}
+
+// WithReadStmt is WithStmt for reads that tolerate replication lag, such as
+// listings, counts, and search. They run on the region-local replica when
+// there is one. If the replica cannot be reached the read is retried on the
+// primary, so fn must reset anything it filled in. Inside a tenant
+// transaction reads stay in the transaction, on the primary.
+func WithReadStmt(ctx context.Context, query string, fn func(*sql.Stmt) error) error {
+	if replica == nil || InTx(ctx) {
+		return WithStmt(ctx, query, fn)
+	}
+
+	err := withStmtOn(ctx, replica, query, fn)
+	if err == nil || !isConnectionError(ctx, err) {
+		metrics.ReplicaReads.WithLabelValues("replica").Inc()
+		return err
+	}
+	log.Printf("Replica read failed, retrying on the primary: %v", err)
+	metrics.ReplicaReads.WithLabelValues("fallback").Inc()
+	return WithStmt(ctx, query, fn)
+}
+
+// isConnectionError reports errors that say nothing about the query itself:
+// anything other than an error from Postgres, a missing row, or the caller
+// giving up.
+func isConnectionError(ctx context.Context, err error) bool {
+	var pqErr *pq.Error
+	return !errors.As(err, &pqErr) && !errors.Is(err, sql.ErrNoRows) && ctx.Err() == nil
+}
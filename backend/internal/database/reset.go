@@ -0,0 +1,56 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// resetKeeps are the tables Reset leaves alone: migration and schema usage
+// bookkeeping, and the disposable domain list, which is fetched reference
+// data rather than state a test creates.
+var resetKeeps = []string{"schema_migrations", "schema_usage", "disposable_domains"}
+
+// Reset empties every other table in the current schema, restarting their
+// ID sequences, and then runs Seed. It is meant for ephemeral test
+// databases only. It returns the tables it truncated.
+func Reset(ctx context.Context) ([]string, error) {
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx,
+		"SELECT tablename FROM pg_tables WHERE schemaname = current_schema() AND NOT tablename = ANY($1) ORDER BY tablename",
+		pq.Array(resetKeeps))
+	if err != nil {
+		return nil, err
+	}
+	var tables, quoted []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		tables = append(tables, name)
+		quoted = append(quoted, pq.QuoteIdentifier(name))
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(tables) > 0 {
+		if _, err := tx.ExecContext(ctx, "TRUNCATE "+strings.Join(quoted, ", ")+" RESTART IDENTITY CASCADE"); err != nil {
+			return nil, fmt.Errorf("failed to truncate tables: %v", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return tables, Seed()
+}
@@ -0,0 +1,308 @@
+package database
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Finding severities. Unsafe findings stop "migrate up" unless forced.
+const (
+	SeverityWarning = "warning"
+	SeverityUnsafe  = "unsafe"
+)
+
+// ignoreMarker in a statement's comments silences its findings once the
+// statement has been reviewed.
+const ignoreMarker = "safety:ignore"
+
+// Postgres lock modes taken by common DDL.
+const (
+	lockAccessExclusive      = "ACCESS EXCLUSIVE"
+	lockShare                = "SHARE"
+	lockShareRowExclusive    = "SHARE ROW EXCLUSIVE"
+	lockShareUpdateExclusive = "SHARE UPDATE EXCLUSIVE"
+	lockRowExclusive         = "ROW EXCLUSIVE"
+)
+
+type Finding struct {
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+// PlannedStatement is one statement of a pending migration with the lock it
+// takes and, when known, the number of rows in the table it locks.
+type PlannedStatement struct {
+	SQL           string    `json:"sql"`
+	Table         string    `json:"table,omitempty"`
+	Lock          string    `json:"lock,omitempty"`
+	EstimatedRows int64     `json:"estimated_rows"`
+	Findings      []Finding `json:"findings,omitempty"`
+}
+
+type MigrationPlan struct {
+	Migration  *Migration         `json:"migration"`
+	Statements []PlannedStatement `json:"statements"`
+}
+
+// Findings returns every finding in the plan with the given severity.
+func (p *MigrationPlan) Findings(severity string) []Finding {
+	var findings []Finding
+	for _, s := range p.Statements {
+		for _, f := range s.Findings {
+			if f.Severity == severity {
+				findings = append(findings, f)
+			}
+		}
+	}
+	return findings
+}
+
+var (
+	alterTablePattern  = regexp.MustCompile(`(?i)^ALTER TABLE (?:IF EXISTS )?(?:ONLY )?(\S+) (.*)$`)
+	createIndexPattern = regexp.MustCompile(`(?i)^CREATE (?:UNIQUE )?INDEX (CONCURRENTLY )?(?:IF NOT EXISTS )?(?:\S+ )?ON (?:ONLY )?([^\s(]+)`)
+	createTablePattern = regexp.MustCompile(`(?i)^CREATE (?:UNLOGGED )?TABLE (?:IF NOT EXISTS )?([^\s(]+)`)
+	dropTablePattern   = regexp.MustCompile(`(?i)^DROP TABLE (?:IF EXISTS )?([^;]+?)(?: CASCADE| RESTRICT)?$`)
+	dropIndexPattern   = regexp.MustCompile(`(?i)^DROP INDEX (CONCURRENTLY )?`)
+	truncatePattern    = regexp.MustCompile(`(?i)^TRUNCATE (?:TABLE )?(?:ONLY )?([^\s,]+)`)
+	dmlPattern         = regexp.MustCompile(`(?i)^(?:UPDATE (?:ONLY )?|DELETE FROM (?:ONLY )?|INSERT INTO )([^\s(]+)`)
+
+	dropColumnPattern    = regexp.MustCompile(`(?i)^DROP (?:COLUMN )?(?:IF EXISTS )?(\S+)`)
+	renameColumnPattern  = regexp.MustCompile(`(?i)^RENAME (?:COLUMN )?(\S+) TO `)
+	renameTablePattern   = regexp.MustCompile(`(?i)^RENAME TO `)
+	addColumnPattern     = regexp.MustCompile(`(?i)^ADD (?:COLUMN )?(?:IF NOT EXISTS )?(\S+) (.*)$`)
+	alterTypePattern     = regexp.MustCompile(`(?i)^ALTER (?:COLUMN )?(\S+) (?:SET DATA )?TYPE `)
+	setNotNullPattern    = regexp.MustCompile(`(?i)^ALTER (?:COLUMN )?(\S+) SET NOT NULL`)
+	addConstraintPattern = regexp.MustCompile(`(?i)^ADD (?:CONSTRAINT \S+ )?(FOREIGN KEY|CHECK|UNIQUE|PRIMARY KEY)`)
+	volatileDefault      = regexp.MustCompile(`(?i)\b(random|gen_random_uuid|uuid_generate_v4|clock_timestamp|timeofday|nextval)\s*\(|\b(BIG|SMALL)?SERIAL\b`)
+)
+
+// analyzer checks the statements of one migration. Tables created earlier in
+// the same migration are empty, so locking them is harmless.
+type analyzer struct {
+	refs    map[string]map[string][]string
+	created map[string]bool
+}
+
+func (a *analyzer) analyze(raw string) PlannedStatement {
+	stmt := stripComments(raw)
+	ps := PlannedStatement{SQL: stmt, EstimatedRows: -1}
+
+	switch {
+	case createTablePattern.MatchString(stmt):
+		table := tableName(createTablePattern.FindStringSubmatch(stmt)[1])
+		a.created[table] = true
+		ps.Table = table
+
+	case alterTablePattern.MatchString(stmt):
+		m := alterTablePattern.FindStringSubmatch(stmt)
+		ps.Table = tableName(m[1])
+		for _, action := range splitTopLevel(m[2]) {
+			lock, findings := a.alterAction(ps.Table, action)
+			if ps.Lock != lockAccessExclusive {
+				ps.Lock = lock
+			}
+			ps.Findings = append(ps.Findings, findings...)
+		}
+
+	case createIndexPattern.MatchString(stmt):
+		m := createIndexPattern.FindStringSubmatch(stmt)
+		ps.Table = tableName(m[2])
+		if m[1] != "" {
+			ps.Lock = lockShareUpdateExclusive
+			ps.Findings = append(ps.Findings, Finding{SeverityUnsafe,
+				"CREATE INDEX CONCURRENTLY cannot run inside the migration transaction"})
+		} else {
+			ps.Lock = lockShare
+			if !a.created[ps.Table] {
+				ps.Findings = append(ps.Findings, Finding{SeverityWarning,
+					fmt.Sprintf("CREATE INDEX blocks writes to %s until the index is built", ps.Table)})
+			}
+		}
+
+	case dropTablePattern.MatchString(stmt):
+		ps.Lock = lockAccessExclusive
+		for _, name := range strings.Split(dropTablePattern.FindStringSubmatch(stmt)[1], ",") {
+			table := tableName(name)
+			ps.Table = table
+			if versions := a.tableUsers(table); versions != nil {
+				ps.Findings = append(ps.Findings, Finding{SeverityUnsafe,
+					fmt.Sprintf("table %s is still used by %s", table, strings.Join(versions, ", "))})
+			}
+		}
+
+	case dropIndexPattern.MatchString(stmt):
+		ps.Lock = lockAccessExclusive
+		if dropIndexPattern.FindStringSubmatch(stmt)[1] != "" {
+			ps.Lock = lockShareUpdateExclusive
+			ps.Findings = append(ps.Findings, Finding{SeverityUnsafe,
+				"DROP INDEX CONCURRENTLY cannot run inside the migration transaction"})
+		}
+
+	case truncatePattern.MatchString(stmt):
+		ps.Table = tableName(truncatePattern.FindStringSubmatch(stmt)[1])
+		ps.Lock = lockAccessExclusive
+
+	case dmlPattern.MatchString(stmt):
+		ps.Table = tableName(dmlPattern.FindStringSubmatch(stmt)[1])
+		ps.Lock = lockRowExclusive
+	}
+
+	if a.created[ps.Table] && !createTablePattern.MatchString(stmt) {
+		// Only findings about the running binary matter for new tables.
+		ps.Findings = nil
+	}
+	if strings.Contains(raw, ignoreMarker) {
+		ps.Findings = nil
+	}
+	return ps
+}
+
+// alterAction checks one action of an ALTER TABLE statement and returns the
+// lock it takes.
+func (a *analyzer) alterAction(table, action string) (string, []Finding) {
+	var findings []Finding
+	add := func(severity, format string, args ...interface{}) {
+		findings = append(findings, Finding{severity, fmt.Sprintf(format, args...)})
+	}
+
+	switch {
+	case renameTablePattern.MatchString(action):
+		if versions := a.tableUsers(table); versions != nil {
+			add(SeverityUnsafe, "table %s is renamed but still used by %s", table, strings.Join(versions, ", "))
+		}
+
+	case renameColumnPattern.MatchString(action):
+		column := columnName(renameColumnPattern.FindStringSubmatch(action)[1])
+		if versions := a.refs[table][column]; versions != nil {
+			add(SeverityUnsafe, "column %s.%s is renamed but still used by %s", table, column, strings.Join(versions, ", "))
+		}
+
+	case strings.HasPrefix(strings.ToUpper(action), "DROP CONSTRAINT"):
+		// Cheap; no finding.
+
+	case dropColumnPattern.MatchString(action):
+		column := columnName(dropColumnPattern.FindStringSubmatch(action)[1])
+		if versions := a.refs[table][column]; versions != nil {
+			add(SeverityUnsafe, "column %s.%s is dropped but still used by %s", table, column, strings.Join(versions, ", "))
+		}
+
+	case addConstraintPattern.MatchString(action):
+		kind := strings.ToUpper(addConstraintPattern.FindStringSubmatch(action)[1])
+		upper := strings.ToUpper(action)
+		switch {
+		case (kind == "FOREIGN KEY" || kind == "CHECK") && !strings.Contains(upper, "NOT VALID"):
+			add(SeverityWarning, "%s on %s validates every row while locked; add it NOT VALID and VALIDATE CONSTRAINT separately", kind, table)
+		case (kind == "UNIQUE" || kind == "PRIMARY KEY") && !strings.Contains(upper, "USING INDEX"):
+			add(SeverityWarning, "%s on %s builds an index while locked; build it CONCURRENTLY and add it USING INDEX", kind, table)
+		}
+		if kind == "FOREIGN KEY" {
+			return lockShareRowExclusive, findings
+		}
+
+	case addColumnPattern.MatchString(action):
+		m := addColumnPattern.FindStringSubmatch(action)
+		column, definition := columnName(m[1]), strings.ToUpper(m[2])
+		hasDefault := strings.Contains(definition, "DEFAULT")
+		if strings.Contains(definition, "NOT NULL") && !hasDefault {
+			add(SeverityUnsafe, "column %s.%s is added NOT NULL without a default, which fails on existing rows and breaks inserts from the running binary", table, column)
+		}
+		if volatileDefault.MatchString(definition) {
+			add(SeverityWarning, "column %s.%s has a volatile default, which rewrites the whole table", table, column)
+		}
+
+	case alterTypePattern.MatchString(action):
+		column := columnName(alterTypePattern.FindStringSubmatch(action)[1])
+		add(SeverityWarning, "changing the type of %s.%s may rewrite the whole table while locked", table, column)
+
+	case setNotNullPattern.MatchString(action):
+		column := columnName(setNotNullPattern.FindStringSubmatch(action)[1])
+		add(SeverityWarning, "SET NOT NULL on %s.%s scans the whole table while locked", table, column)
+	}
+	return lockAccessExclusive, findings
+}
+
+// tableUsers returns the versions that use any column of table.
+func (a *analyzer) tableUsers(table string) []string {
+	seen := map[string]bool{}
+	var versions []string
+	for _, vs := range a.refs[table] {
+		for _, v := range vs {
+			if !seen[v] {
+				seen[v] = true
+				versions = append(versions, v)
+			}
+		}
+	}
+	sort.Strings(versions)
+	return versions
+}
+
+// Plan analyzes the pending migrations without applying them.
+func Plan() ([]*MigrationPlan, error) {
+	migrations, err := MigrationStatus()
+	if err != nil {
+		return nil, err
+	}
+	refs, err := columnReferences()
+	if err != nil {
+		return nil, err
+	}
+
+	var plans []*MigrationPlan
+	for _, m := range migrations {
+		if m.AppliedAt != nil {
+			continue
+		}
+		a := &analyzer{refs: refs, created: map[string]bool{}}
+		plan := &MigrationPlan{Migration: m}
+		for _, raw := range splitStatements(m.Up) {
+			ps := a.analyze(raw)
+			if ps.Table != "" && ps.Lock != "" && !a.created[ps.Table] {
+				ps.EstimatedRows = estimateRows(ps.Table)
+			}
+			plan.Statements = append(plan.Statements, ps)
+		}
+		plans = append(plans, plan)
+	}
+	return plans, nil
+}
+
+// estimateRows reads the planner's row estimate, or -1 when unknown.
+func estimateRows(table string) int64 {
+	var rows int64
+	if err := DB.QueryRow("SELECT COALESCE(reltuples::bigint, -1) FROM pg_class WHERE oid = to_regclass($1)", table).Scan(&rows); err != nil {
+		return -1
+	}
+	return rows
+}
+
+// splitTopLevel splits ALTER TABLE actions on commas outside parentheses.
+func splitTopLevel(s string) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i, r := range s {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, strings.TrimSpace(s[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, strings.TrimSpace(s[start:]))
+}
+
+func tableName(name string) string {
+	name = strings.Trim(strings.TrimSpace(name), `"`)
+	return strings.TrimPrefix(strings.ToLower(name), "public.")
+}
+
+func columnName(name string) string {
+	return strings.ToLower(strings.Trim(name, `"`))
+}
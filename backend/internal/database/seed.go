@@ -0,0 +1,17 @@
+package database
+
+import "fmt"
+
+// Seed inserts the sample data used for local development. It is safe to run
+// repeatedly.
+func Seed() error {
+	_, err := DB.Exec(`INSERT INTO users (email, name) VALUES
+		('john.doe@example.com', 'John Doe'),
+		('jane.smith@example.com', 'Jane Smith'),
+		('bob.wilson@example.com', 'Bob Wilson')
+	ON CONFLICT (email) DO NOTHING`)
+	if err != nil {
+		return fmt.Errorf("failed to seed users: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package database
+
+import (
+	"regexp"
+	"strings"
+)
+
+var dollarTag = regexp.MustCompile(`^\$[A-Za-z_0-9]*\$`)
+
+// splitStatements splits a migration into statements on top-level
+// semicolons, skipping quoted strings, comments, and dollar-quoted bodies.
+// Each statement keeps its leading comments.
+func splitStatements(sql string) []string {
+	var statements []string
+	start := 0
+	for i := 0; i < len(sql); i++ {
+		switch {
+		case sql[i] == '\'' || sql[i] == '"':
+			quote := sql[i]
+			for i++; i < len(sql); i++ {
+				if sql[i] == quote {
+					if i+1 < len(sql) && sql[i+1] == quote {
+						i++
+						continue
+					}
+					break
+				}
+			}
+		case strings.HasPrefix(sql[i:], "--"):
+			for i < len(sql) && sql[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(sql[i:], "/*"):
+			end := strings.Index(sql[i+2:], "*/")
+			if end < 0 {
+				i = len(sql)
+			} else {
+				i += end + 3
+			}
+		case sql[i] == '$':
+			tag := dollarTag.FindString(sql[i:])
+			if tag == "" {
+				continue
+			}
+			end := strings.Index(sql[i+len(tag):], tag)
+			if end < 0 {
+				i = len(sql)
+			} else {
+				i += len(tag) + end + len(tag) - 1
+			}
+		case sql[i] == ';':
+			if s := strings.TrimSpace(sql[start:i]); s != "" {
+				statements = append(statements, s)
+			}
+			start = i + 1
+		}
+	}
+	if s := strings.TrimSpace(sql[min(start, len(sql)):]); stripComments(s) != "" {
+		statements = append(statements, s)
+	}
+	return statements
+}
+
+// stripComments removes comments and collapses whitespace, for matching.
+func stripComments(stmt string) string {
+	var b strings.Builder
+	for _, line := range strings.Split(stmt, "\n") {
+		if i := strings.Index(line, "--"); i >= 0 {
+			line = line[:i]
+		}
+		b.WriteString(line)
+		b.WriteByte(' ')
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
@@ -0,0 +1,189 @@
+package database
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+
+	"pygorp/backend/internal/env"
+	"pygorp/backend/internal/metrics"
+
+	"github.com/lib/pq"
+)
+
+// stmtKey identifies a cached statement. Statements belong to the pool
+// they were prepared on, so the primary and a replica cache separately.
+type stmtKey struct {
+	db    *sql.DB
+	query string
+}
+
+// cachedStmt is a cached statement and the number of WithStmt calls using
+// it. An evicted statement is closed once the last of them is done, so
+// invalidating it never pulls it out from under a running query.
+type cachedStmt struct {
+	stmt    *sql.Stmt
+	users   int
+	evicted bool
+	// elem is the statement's entry in stmtLRU.
+	elem *list.Element
+}
+
+// defaultStmtCacheSize is the number of statements cached when
+// DB_STMT_CACHE_SIZE is unset. Queries built from request filters can
+// produce many distinct statements, so the cache evicts the least recently
+// used rather than holding a server-side statement for each.
+const defaultStmtCacheSize = 256
+
+var (
+	stmtMu sync.Mutex
+	stmts  = map[stmtKey]*cachedStmt{}
+	// stmtLRU orders the keys of stmts from most to least recently used.
+	stmtLRU = list.New()
+)
+
+// stmtCacheSize returns DB_STMT_CACHE_SIZE, or the default when it is unset
+// or not a positive number.
+func stmtCacheSize() int {
+	size, err := strconv.Atoi(env.String("DB_STMT_CACHE_SIZE", ""))
+	if err != nil || size <= 0 {
+		return defaultStmtCacheSize
+	}
+	return size
+}
+
+// acquireStmt returns the cached statement for query, preparing it on first
+// use, and counts the caller as a user until releaseStmt. The statement is
+// prepared without holding stmtMu, so a slow prepare does not stall lookups
+// of other queries; if two callers race, the loser closes its copy. Adding a
+// statement to a full cache evicts the least recently used one.
+func acquireStmt(ctx context.Context, db *sql.DB, query string) (*cachedStmt, error) {
+	key := stmtKey{db, query}
+	stmtMu.Lock()
+	if cs, ok := stmts[key]; ok {
+		cs.users++
+		stmtLRU.MoveToFront(cs.elem)
+		stmtMu.Unlock()
+		metrics.StatementCache.WithLabelValues("hit").Inc()
+		return cs, nil
+	}
+	stmtMu.Unlock()
+
+	stmt, err := db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	metrics.StatementCache.WithLabelValues("miss").Inc()
+
+	stmtMu.Lock()
+	defer stmtMu.Unlock()
+	if cs, ok := stmts[key]; ok {
+		stmt.Close()
+		cs.users++
+		stmtLRU.MoveToFront(cs.elem)
+		return cs, nil
+	}
+	cs := &cachedStmt{stmt: stmt, users: 1, elem: stmtLRU.PushFront(key)}
+	stmts[key] = cs
+	for size := stmtCacheSize(); len(stmts) > size; {
+		oldest := stmtLRU.Back().Value.(stmtKey)
+		evictStmt(oldest, stmts[oldest])
+		metrics.StatementCache.WithLabelValues("evicted").Inc()
+	}
+	return cs, nil
+}
+
+func releaseStmt(cs *cachedStmt) {
+	stmtMu.Lock()
+	defer stmtMu.Unlock()
+	cs.users--
+	if cs.evicted && cs.users == 0 {
+		cs.stmt.Close()
+	}
+}
+
+// evictStmt drops cs from the cache. Callers must hold stmtMu.
+func evictStmt(key stmtKey, cs *cachedStmt) {
+	if stmts[key] == cs {
+		delete(stmts, key)
+		stmtLRU.Remove(cs.elem)
+	}
+	if !cs.evicted {
+		cs.evicted = true
+		if cs.users == 0 {
+			cs.stmt.Close()
+		}
+	}
+}
+
+// WithStmt runs fn with the cached statement for query. If the statement was
+// invalidated by a schema change it is prepared again and fn retried once.
+// Inside a request transaction (see BeginTenant and Begin) the statement runs in that
+// transaction and is not retried, since the failure aborted it.
+func WithStmt(ctx context.Context, query string, fn func(*sql.Stmt) error) error {
+	return withStmtOn(ctx, DB, query, fn)
+}
+
+func withStmtOn(ctx context.Context, db *sql.DB, query string, fn func(*sql.Stmt) error) error {
+	for attempt := 0; ; attempt++ {
+		cs, err := acquireStmt(ctx, db, query)
+		if err != nil {
+			return err
+		}
+
+		ok, err := withTenantStmt(ctx, cs.stmt, fn)
+		if !ok {
+			err = fn(cs.stmt)
+		}
+		releaseStmt(cs)
+		if !ok && attempt == 0 && isStaleStatement(err) {
+			invalidateStmt(db, query, cs)
+			continue
+		}
+		return err
+	}
+}
+
+// ResetStatements evicts all cached statements, e.g. after migrations.
+// Statements still in use are closed when their queries finish.
+func ResetStatements() {
+	stmtMu.Lock()
+	defer stmtMu.Unlock()
+
+	for key, cs := range stmts {
+		evictStmt(key, cs)
+	}
+	metrics.StatementCache.WithLabelValues("invalidated").Inc()
+}
+
+// invalidateStmt evicts cs, the statement that failed as stale. A newer
+// statement another caller already prepared for query is kept.
+func invalidateStmt(db *sql.DB, query string, cs *cachedStmt) {
+	stmtMu.Lock()
+	defer stmtMu.Unlock()
+
+	if !cs.evicted {
+		evictStmt(stmtKey{db, query}, cs)
+		metrics.StatementCache.WithLabelValues("invalidated").Inc()
+	}
+}
+
+// isStaleStatement reports errors Postgres raises when a prepared statement
+// no longer matches the schema or was dropped server-side.
+func isStaleStatement(err error) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+	switch pqErr.Code {
+	case "0A000":
+		return strings.Contains(pqErr.Message, "cached plan")
+	case "26000":
+		return true
+	}
+	return false
+}
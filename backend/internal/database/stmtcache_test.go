@@ -0,0 +1,85 @@
+package database
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+)
+
+// fakeDriver prepares statements that do nothing, so the statement cache
+// can be tested without a database.
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) { return fakeConn{}, nil }
+
+type fakeConn struct{}
+
+func (fakeConn) Prepare(string) (driver.Stmt, error) { return fakeStmt{}, nil }
+func (fakeConn) Close() error                        { return nil }
+func (fakeConn) Begin() (driver.Tx, error)           { return nil, errors.New("not supported") }
+
+type fakeStmt struct{}
+
+func (fakeStmt) Close() error                               { return nil }
+func (fakeStmt) NumInput() int                              { return -1 }
+func (fakeStmt) Exec([]driver.Value) (driver.Result, error) { return driver.ResultNoRows, nil }
+func (fakeStmt) Query([]driver.Value) (driver.Rows, error)  { return nil, errors.New("not supported") }
+
+func init() {
+	sql.Register("stmtcache-test", fakeDriver{})
+}
+
+// TestStmtCacheEviction checks that the cache keeps the most recently used
+// statements up to DB_STMT_CACHE_SIZE, and that an evicted statement in use
+// stays open until its caller releases it.
+func TestStmtCacheEviction(t *testing.T) {
+	t.Setenv("DB_STMT_CACHE_SIZE", "2")
+	db, err := sql.Open("stmtcache-test", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	savedStmts, savedLRU := stmts, stmtLRU
+	stmts, stmtLRU = map[stmtKey]*cachedStmt{}, list.New()
+	defer func() { stmts, stmtLRU = savedStmts, savedLRU }()
+
+	ctx := context.Background()
+	acquire := func(query string) *cachedStmt {
+		t.Helper()
+		cs, err := acquireStmt(ctx, db, query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cs
+	}
+	cached := func(query string) bool {
+		_, ok := stmts[stmtKey{db, query}]
+		return ok
+	}
+
+	held := acquire("a")
+	releaseStmt(acquire("b"))
+	releaseStmt(acquire("c"))
+	if cached("a") || !cached("b") || !cached("c") {
+		t.Fatalf("after a, b, c: cached a=%v b=%v c=%v, want only b and c", cached("a"), cached("b"), cached("c"))
+	}
+	if _, err := held.stmt.Exec(); err != nil {
+		t.Errorf("evicted statement in use: %v", err)
+	}
+	releaseStmt(held)
+	if _, err := held.stmt.Exec(); err == nil {
+		t.Error("evicted statement still open after release")
+	}
+
+	releaseStmt(acquire("b"))
+	releaseStmt(acquire("d"))
+	if !cached("b") || cached("c") || !cached("d") {
+		t.Errorf("after using b again and adding d: cached b=%v c=%v d=%v, want b and d", cached("b"), cached("c"), cached("d"))
+	}
+	if len(stmts) != stmtLRU.Len() {
+		t.Errorf("%d statements cached but %d in the LRU list", len(stmts), stmtLRU.Len())
+	}
+}
@@ -0,0 +1,215 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+
+	"pygorp/backend/internal/env"
+)
+
+// Tenant identifies whose rows a request may see. It is applied to the
+// request's transaction as app.current_user_id and app.current_org_id, which
+// the row-level security policies compare against. Empty fields are unset,
+// and match no rows.
+type Tenant struct {
+	UserID string
+	OrgID  string
+}
+
+// Key identifies the tenant in cache and coalescing keys so results are never
+// shared between tenants.
+func (t Tenant) Key() string {
+	return t.UserID + "/" + t.OrgID
+}
+
+// RLSEnabled reports whether requests run in tenant-scoped transactions.
+func RLSEnabled() bool {
+	return env.String("DB_RLS", "false") == "true"
+}
+
+// rlsTables are the tables with row-level security policies.
+var rlsTables = []string{"users", "ai_requests"}
+
+// SyncRLS enables and forces row-level security on the tables with policies
+// when DB_RLS is on, and turns it off otherwise, so the policies, which fail
+// closed, only apply where requests run in tenant transactions. Tables
+// already in that state are left alone, since ALTER TABLE locks them.
+func SyncRLS(ctx context.Context) error {
+	enabled := RLSEnabled()
+	for _, table := range rlsTables {
+		on, forced, err := rlsState(ctx, table)
+		if err != nil {
+			return err
+		}
+		if on == enabled && forced == enabled {
+			continue
+		}
+		alter := "ALTER TABLE " + table + " NO FORCE ROW LEVEL SECURITY, DISABLE ROW LEVEL SECURITY"
+		if enabled {
+			alter = "ALTER TABLE " + table + " ENABLE ROW LEVEL SECURITY, FORCE ROW LEVEL SECURITY"
+		}
+		if _, err := DB.ExecContext(ctx, alter); err != nil {
+			return fmt.Errorf("failed to set row-level security on %s: %v", table, err)
+		}
+		log.Printf("Row-level security on %s: %t", table, enabled)
+	}
+	return nil
+}
+
+// CheckRLS reports an error when DB_RLS is on but the policies would not
+// apply: a table's row-level security is off, or the connecting role is a
+// superuser or has BYPASSRLS, which skip policies even when forced.
+func CheckRLS(ctx context.Context) error {
+	if !RLSEnabled() {
+		return nil
+	}
+	var role string
+	var bypass bool
+	err := DB.QueryRowContext(ctx, "SELECT rolname, rolsuper OR rolbypassrls FROM pg_roles WHERE rolname = current_user").Scan(&role, &bypass)
+	if err != nil {
+		return fmt.Errorf("failed to read the database role: %v", err)
+	}
+	if bypass {
+		return fmt.Errorf("database role %s is a superuser or has BYPASSRLS, so row-level security does not apply; connect as a regular role", role)
+	}
+	for _, table := range rlsTables {
+		on, forced, err := rlsState(ctx, table)
+		if err != nil {
+			return err
+		}
+		if !on || !forced {
+			return fmt.Errorf("row-level security is off on %s; run \"pygorp migrate up\" with DB_RLS=true", table)
+		}
+	}
+	return nil
+}
+
+func rlsState(ctx context.Context, table string) (on, forced bool, err error) {
+	err = DB.QueryRowContext(ctx, "SELECT relrowsecurity, relforcerowsecurity FROM pg_class WHERE oid = $1::regclass", table).Scan(&on, &forced)
+	if err != nil {
+		return false, false, fmt.Errorf("failed to read row-level security of %s: %v", table, err)
+	}
+	return on, forced, nil
+}
+
+type tenantKey struct{}
+
+type txKey struct{}
+
+// tenantTx is a request transaction. Postgres runs one statement at a time per
+// connection, so concurrent queries from the same request take turns.
+type tenantTx struct {
+	mu sync.Mutex
+	tx *sql.Tx
+}
+
+// TenantFrom returns the tenant bound to ctx, if any.
+func TenantFrom(ctx context.Context) Tenant {
+	t, _ := ctx.Value(tenantKey{}).(Tenant)
+	return t
+}
+
+// BeginTenant starts a transaction with the tenant settings applied via
+// SET LOCAL and app.bypass_rls turned off, and returns a context that routes
+// WithStmt through it. Without an OrgID, the user's org is looked up first.
+// The caller must commit or roll back the returned transaction. The
+// transaction ignores cancellation of ctx, since work shared between
+// requests detaches from it.
+func BeginTenant(ctx context.Context, tenant Tenant) (context.Context, *sql.Tx, error) {
+	tx, err := DB.BeginTx(context.WithoutCancel(ctx), nil)
+	if err != nil {
+		return ctx, nil, err
+	}
+	if tenant, err = applyTenant(ctx, tx, tenant); err != nil {
+		tx.Rollback()
+		return ctx, nil, err
+	}
+
+	ctx = context.WithValue(ctx, tenantKey{}, tenant)
+	ctx = context.WithValue(ctx, txKey{}, &tenantTx{tx: tx})
+	return ctx, tx, nil
+}
+
+// applyTenant looks up the org of the tenant's user when it has none, and
+// scopes tx to the tenant.
+func applyTenant(ctx context.Context, tx *sql.Tx, tenant Tenant) (Tenant, error) {
+	if tenant.OrgID == "" && tenant.UserID != "" {
+		var orgID sql.NullString
+		err := tx.QueryRowContext(ctx, "SELECT org_id::text FROM users WHERE id = $1", tenant.UserID).Scan(&orgID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return tenant, fmt.Errorf("failed to look up the tenant's org: %v", err)
+		}
+		tenant.OrgID = orgID.String
+	}
+	// set_config with is_local=true is SET LOCAL with bind parameters.
+	_, err := tx.ExecContext(ctx,
+		"SELECT set_config('app.current_user_id', $1, true), set_config('app.current_org_id', $2, true), set_config('app.bypass_rls', 'off', true)",
+		tenant.UserID, tenant.OrgID)
+	if err != nil {
+		return tenant, fmt.Errorf("failed to set tenant: %v", err)
+	}
+	return tenant, nil
+}
+
+// BeginTx starts a transaction of its own, outside any request transaction
+// in ctx. When ctx has a tenant the transaction is scoped to it too, so
+// row-level security still applies.
+func BeginTx(ctx context.Context) (*sql.Tx, error) {
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	if tenant := TenantFrom(ctx); tenant != (Tenant{}) {
+		if _, err := applyTenant(ctx, tx, tenant); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+	return tx, nil
+}
+
+// Begin starts a request transaction without tenant settings and returns a
+// context that routes WithStmt through it, like BeginTenant. It is for
+// requests that group several changes, such as atomic batches, when no
+// tenant transaction is in use.
+func Begin(ctx context.Context) (context.Context, *sql.Tx, error) {
+	tx, err := DB.BeginTx(context.WithoutCancel(ctx), nil)
+	if err != nil {
+		return ctx, nil, err
+	}
+	return context.WithValue(ctx, txKey{}, &tenantTx{tx: tx}), tx, nil
+}
+
+// InTx reports whether ctx carries a request transaction.
+func InTx(ctx context.Context) bool {
+	t, _ := ctx.Value(txKey{}).(*tenantTx)
+	return t != nil
+}
+
+// WithoutTx returns ctx without its request transaction, so work started
+// from it runs outside the transaction or in one of its own.
+func WithoutTx(ctx context.Context) context.Context {
+	if !InTx(ctx) {
+		return ctx
+	}
+	return context.WithValue(ctx, txKey{}, (*tenantTx)(nil))
+}
+
+// withTenantStmt runs fn with stmt bound to the request transaction in ctx.
+// It reports false when ctx has no transaction.
+func withTenantStmt(ctx context.Context, stmt *sql.Stmt, fn func(*sql.Stmt) error) (bool, error) {
+	t, _ := ctx.Value(txKey{}).(*tenantTx)
+	if t == nil {
+		return false, nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	txStmt := t.tx.StmtContext(ctx, stmt)
+	defer txStmt.Close()
+	return true, fn(txStmt)
+}
@@ -0,0 +1,112 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// usageWindow is how recently a binary must have started for its column
+// usage to count as "still running".
+const usageWindow = "7 days"
+
+var (
+	usageMu     sync.Mutex
+	usedColumns = map[string]map[string]bool{}
+)
+
+// UseColumns declares the columns of table that this binary reads or
+// writes, as a comma-separated list. Migrations that drop or rename them are
+// refused.
+func UseColumns(table, columns string) {
+	usageMu.Lock()
+	defer usageMu.Unlock()
+
+	if usedColumns[table] == nil {
+		usedColumns[table] = map[string]bool{}
+	}
+	for _, column := range strings.Split(columns, ",") {
+		usedColumns[table][strings.TrimSpace(column)] = true
+	}
+}
+
+// RecordSchemaUsage stores this binary's column usage. During a blue/green
+// deploy the new binary runs the migrations while the old one still serves
+// traffic; the recorded usage lets it avoid breaking the old one.
+func RecordSchemaUsage(version string) error {
+	if err := ensureUsageTable(); err != nil {
+		return err
+	}
+
+	usageMu.Lock()
+	data, err := json.Marshal(usedColumns)
+	usageMu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	_, err = DB.Exec(`
+		INSERT INTO schema_usage (version, columns, seen_at) VALUES ($1, $2, NOW())
+		ON CONFLICT (version) DO UPDATE SET columns = EXCLUDED.columns, seen_at = NOW()`, version, data)
+	if err != nil {
+		return fmt.Errorf("failed to record schema usage: %v", err)
+	}
+	return nil
+}
+
+// columnReferences merges this binary's usage with that recorded by
+// binaries started recently. The result maps table to column to the
+// versions using it.
+func columnReferences() (map[string]map[string][]string, error) {
+	refs := map[string]map[string][]string{}
+	add := func(version string, usage map[string]map[string]bool) {
+		for table, columns := range usage {
+			if refs[table] == nil {
+				refs[table] = map[string][]string{}
+			}
+			for column := range columns {
+				refs[table][column] = append(refs[table][column], version)
+			}
+		}
+	}
+
+	usageMu.Lock()
+	add("this binary", usedColumns)
+	usageMu.Unlock()
+
+	if err := ensureUsageTable(); err != nil {
+		return nil, err
+	}
+	rows, err := DB.Query("SELECT version, columns FROM schema_usage WHERE seen_at > NOW() - INTERVAL '" + usageWindow + "'")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema usage: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var version string
+		var data []byte
+		if err := rows.Scan(&version, &data); err != nil {
+			return nil, err
+		}
+		var usage map[string]map[string]bool
+		if err := json.Unmarshal(data, &usage); err != nil {
+			return nil, fmt.Errorf("invalid schema usage for %s: %v", version, err)
+		}
+		add(version, usage)
+	}
+	return refs, rows.Err()
+}
+
+func ensureUsageTable() error {
+	_, err := DB.Exec(`CREATE TABLE IF NOT EXISTS schema_usage (
+		version VARCHAR(100) PRIMARY KEY,
+		columns JSONB NOT NULL,
+		seen_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_usage: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+// schemaQueries describe the public schema, one line per object, so two
+// snapshots can be compared. Bookkeeping tables are excluded.
+var schemaQueries = []string{
+	`SELECT 'column ' || table_name || '.' || column_name || ' ' || data_type || ' null=' || is_nullable || ' default=' || COALESCE(column_default, '')
+	 FROM information_schema.columns
+	 WHERE table_schema = 'public' AND table_name NOT IN ('schema_migrations', 'schema_usage')`,
+	`SELECT 'index ' || indexname || ' ' || indexdef
+	 FROM pg_indexes
+	 WHERE schemaname = 'public' AND tablename NOT IN ('schema_migrations', 'schema_usage')`,
+	`SELECT 'constraint ' || conrelid::regclass || ' ' || conname || ' ' || pg_get_constraintdef(oid)
+	 FROM pg_constraint
+	 WHERE connamespace = 'public'::regnamespace AND conrelid <> 0`,
+	`SELECT 'trigger ' || tgrelid::regclass || ' ' || tgname
+	 FROM pg_trigger
+	 WHERE NOT tgisinternal`,
+	`SELECT 'function ' || proname || '(' || pg_get_function_identity_arguments(oid) || ')'
+	 FROM pg_proc
+	 WHERE pronamespace = 'public'::regnamespace`,
+}
+
+// VerifyRollbacks checks every migration's down step on a fresh database:
+// it applies the migration, rolls it back and expects the previous schema,
+// then applies it again and expects the same schema as the first time. All
+// migrations are left applied.
+func VerifyRollbacks() error {
+	migrations, err := MigrationStatus()
+	if err != nil {
+		return err
+	}
+	for _, m := range migrations {
+		if m.AppliedAt != nil {
+			return errors.New("verify needs a fresh database with no applied migrations")
+		}
+	}
+
+	const up = "INSERT INTO schema_migrations (version, name) VALUES ($1, $2)"
+	const down = "DELETE FROM schema_migrations WHERE version = $1"
+
+	defer ResetStatements()
+	for _, m := range migrations {
+		name := fmt.Sprintf("%04d_%s", m.Version, m.Name)
+		if m.Down == "" {
+			return fmt.Errorf("%s has no down migration", name)
+		}
+
+		before, err := schemaSnapshot()
+		if err != nil {
+			return err
+		}
+		if err := runMigration(m.Up, up, m.Version, m.Name); err != nil {
+			return fmt.Errorf("%s: up failed: %v", name, err)
+		}
+		applied, err := schemaSnapshot()
+		if err != nil {
+			return err
+		}
+
+		if err := runMigration(m.Down, down, m.Version); err != nil {
+			return fmt.Errorf("%s: down failed: %v", name, err)
+		}
+		reverted, err := schemaSnapshot()
+		if err != nil {
+			return err
+		}
+		if diff := schemaDiff(before, reverted); diff != "" {
+			return fmt.Errorf("%s: down does not restore the previous schema:\n%s", name, diff)
+		}
+
+		if err := runMigration(m.Up, up, m.Version, m.Name); err != nil {
+			return fmt.Errorf("%s: up failed after rollback: %v", name, err)
+		}
+		reapplied, err := schemaSnapshot()
+		if err != nil {
+			return err
+		}
+		if diff := schemaDiff(applied, reapplied); diff != "" {
+			return fmt.Errorf("%s: re-applying produces a different schema:\n%s", name, diff)
+		}
+
+		log.Printf("Verified migration %s", name)
+	}
+	return nil
+}
+
+func schemaSnapshot() ([]string, error) {
+	var lines []string
+	for _, query := range schemaQueries {
+		rows, err := DB.Query(query)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read schema: %v", err)
+		}
+		for rows.Next() {
+			var line string
+			if err := rows.Scan(&line); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			lines = append(lines, line)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(lines)
+	return lines, nil
+}
+
+// schemaDiff lists objects present in only one snapshot, prefixed with - for
+// want and + for got.
+func schemaDiff(want, got []string) string {
+	wantSet := map[string]bool{}
+	for _, l := range want {
+		wantSet[l] = true
+	}
+	gotSet := map[string]bool{}
+	for _, l := range got {
+		gotSet[l] = true
+	}
+
+	var diff []string
+	for _, l := range want {
+		if !gotSet[l] {
+			diff = append(diff, "  - "+l)
+		}
+	}
+	for _, l := range got {
+		if !wantSet[l] {
+			diff = append(diff, "  + "+l)
+		}
+	}
+	return strings.Join(diff, "\n")
+}
@@ -0,0 +1,72 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// TestVerifyRollbacks round-trips every migration, as `pygorp migrate
+// verify` does, in a scratch database created on the server named by
+// TEST_DATABASE_URL. The connecting role needs CREATEDB.
+func TestVerifyRollbacks(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		var err error
+		if dsn, err = pq.ParseURL(dsn); err != nil {
+			t.Fatalf("invalid TEST_DATABASE_URL: %v", err)
+		}
+	}
+
+	admin, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer admin.Close()
+
+	name := fmt.Sprintf("pygorp_verify_%d", time.Now().UnixNano())
+	if _, err := admin.Exec("CREATE DATABASE " + name); err != nil {
+		t.Fatalf("failed to create scratch database: %v", err)
+	}
+	defer func() {
+		if _, err := admin.Exec("DROP DATABASE IF EXISTS " + name + " WITH (FORCE)"); err != nil {
+			t.Errorf("failed to drop scratch database %s: %v", name, err)
+		}
+	}()
+
+	// Later keywords win, so this points the DSN at the scratch database.
+	scratch, err := sql.Open("postgres", dsn+" dbname="+name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer scratch.Close()
+
+	saved := DB
+	DB = scratch
+	defer func() { DB = saved }()
+
+	if err := VerifyRollbacks(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSchemaDiff(t *testing.T) {
+	want := []string{"column users.id integer", "index users_pkey"}
+	got := []string{"column users.id bigint", "index users_pkey"}
+
+	diff := schemaDiff(want, got)
+	if diff != "  - column users.id integer\n  + column users.id bigint" {
+		t.Errorf("schemaDiff = %q", diff)
+	}
+	if diff := schemaDiff(want, want); diff != "" {
+		t.Errorf("schemaDiff of equal snapshots = %q", diff)
+	}
+}
@@ -0,0 +1,187 @@
+// Package disposable recognizes email addresses at disposable (throwaway)
+// domains. The list bundled with the binary is extended by one fetched from
+// DISPOSABLE_DOMAINS_URL by a scheduled job and stored in the database, so
+// every server sees the same refreshed list.
+package disposable
+
+import (
+	"bufio"
+	"context"
+	_ "embed"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"pygorp/backend/internal/database"
+	"pygorp/backend/internal/jobs"
+
+	"github.com/lib/pq"
+)
+
+// RefreshJob is the job type that refetches the remote list.
+const RefreshJob = "disposable.refresh"
+
+// DefaultURL is the community-maintained list fetched when
+// DISPOSABLE_DOMAINS_URL is unset. Set it to "off" to use only the bundled
+// list.
+const DefaultURL = "https://raw.githubusercontent.com/disposable-email-domains/disposable-email-domains/master/disposable_email_blocklist.conf"
+
+// cacheTTL is how long servers reuse the stored list before rereading it.
+const cacheTTL = 5 * time.Minute
+
+//go:embed domains.txt
+var bundledList string
+
+var bundled = parse(strings.NewReader(bundledList))
+
+var cache struct {
+	sync.Mutex
+	loaded  time.Time
+	domains map[string]bool
+}
+
+func init() {
+	jobs.Register(RefreshJob, refresh)
+	if sourceURL() != "" {
+		jobs.Every(RefreshJob, jobs.DefaultQueue, refreshInterval())
+	}
+}
+
+func sourceURL() string {
+	switch url := os.Getenv("DISPOSABLE_DOMAINS_URL"); url {
+	case "":
+		return DefaultURL
+	case "off":
+		return ""
+	default:
+		return url
+	}
+}
+
+// refreshInterval reads DISPOSABLE_DOMAINS_REFRESH, defaulting to a day.
+func refreshInterval() time.Duration {
+	if d, err := time.ParseDuration(os.Getenv("DISPOSABLE_DOMAINS_REFRESH")); err == nil && d > 0 {
+		return d
+	}
+	return 24 * time.Hour
+}
+
+// Check reports whether email is at a disposable domain or one of its
+// subdomains.
+func Check(ctx context.Context, email string) (bool, error) {
+	domain := strings.ToLower(email[strings.LastIndex(email, "@")+1:])
+	fetched, err := stored(ctx)
+	if err != nil {
+		return false, err
+	}
+	for d := domain; d != ""; {
+		if bundled[d] || fetched[d] {
+			return true, nil
+		}
+		i := strings.IndexByte(d, '.')
+		if i < 0 {
+			break
+		}
+		d = d[i+1:]
+	}
+	return false, nil
+}
+
+func stored(ctx context.Context) (map[string]bool, error) {
+	cache.Lock()
+	defer cache.Unlock()
+	if cache.domains != nil && time.Since(cache.loaded) < cacheTTL {
+		return cache.domains, nil
+	}
+
+	rows, err := database.DB.QueryContext(ctx, "SELECT domain FROM disposable_domains")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	domains := map[string]bool{}
+	for rows.Next() {
+		var d string
+		if err := rows.Scan(&d); err != nil {
+			return nil, err
+		}
+		domains[d] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	cache.loaded, cache.domains = time.Now(), domains
+	return domains, nil
+}
+
+// refresh replaces the stored list with the one at the source URL. An empty
+// or unreadable list keeps the stored one.
+func refresh(ctx context.Context, job *jobs.Job) error {
+	url := sourceURL()
+	if url == "" {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %d", url, resp.StatusCode)
+	}
+
+	fetched := parse(io.LimitReader(resp.Body, 16<<20))
+	if len(fetched) == 0 {
+		return fmt.Errorf("%s returned no domains", url)
+	}
+	domains := make([]string, 0, len(fetched))
+	for d := range fetched {
+		domains = append(domains, d)
+	}
+
+	tx, err := database.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, "DELETE FROM disposable_domains"); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "INSERT INTO disposable_domains (domain) SELECT unnest($1::text[])", pq.Array(domains)); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	log.Printf("Refreshed disposable email domains: %d from %s", len(domains), url)
+	return nil
+}
+
+// parse reads one domain per line, skipping blank lines, comments, and
+// lines that are not domain names.
+func parse(r io.Reader) map[string]bool {
+	domains := map[string]bool{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.ToLower(strings.TrimSpace(scanner.Text()))
+		if line == "" || strings.HasPrefix(line, "#") || !strings.Contains(line, ".") || len(line) > 255 || strings.ContainsAny(line, " \t@/") {
+			continue
+		}
+		domains[line] = true
+	}
+	return domains
+}
@@ -0,0 +1,44 @@
+// Package env reads settings from environment variables, falling back to a
+// default when a variable is unset or invalid.
+package env
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// String returns the variable, or defaultValue when it is unset or empty.
+func String(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+// Int returns the variable as a positive integer, or defaultValue when it
+// is unset or not one.
+func Int(key string, defaultValue int) int {
+	if n, err := strconv.Atoi(os.Getenv(key)); err == nil && n > 0 {
+		return n
+	}
+	return defaultValue
+}
+
+// Float returns the variable as a number, or defaultValue when it is unset
+// or not one.
+func Float(key string, defaultValue float64) float64 {
+	if f, err := strconv.ParseFloat(os.Getenv(key), 64); err == nil {
+		return f
+	}
+	return defaultValue
+}
+
+// Duration returns the variable as a positive duration such as "30s", or
+// defaultValue when it is unset or not one.
+func Duration(key string, defaultValue time.Duration) time.Duration {
+	if d, err := time.ParseDuration(os.Getenv(key)); err == nil && d > 0 {
+		return d
+	}
+	return defaultValue
+}
@@ -0,0 +1,33 @@
+package env
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDefaults(t *testing.T) {
+	t.Setenv("ENV_TEST_SET", "42")
+	t.Setenv("ENV_TEST_ZERO", "0")
+	t.Setenv("ENV_TEST_BAD", "forty")
+	t.Setenv("ENV_TEST_DURATION", "90s")
+
+	for _, tc := range []struct {
+		name      string
+		got, want interface{}
+	}{
+		{"set string", String("ENV_TEST_SET", "x"), "42"},
+		{"unset string", String("ENV_TEST_UNSET", "x"), "x"},
+		{"set int", Int("ENV_TEST_SET", 7), 42},
+		{"zero int", Int("ENV_TEST_ZERO", 7), 7},
+		{"invalid int", Int("ENV_TEST_BAD", 7), 7},
+		{"zero float", Float("ENV_TEST_ZERO", 0.5), 0.0},
+		{"invalid float", Float("ENV_TEST_BAD", 0.5), 0.5},
+		{"set duration", Duration("ENV_TEST_DURATION", time.Second), 90 * time.Second},
+		{"zero duration", Duration("ENV_TEST_ZERO", time.Second), time.Second},
+		{"unset duration", Duration("ENV_TEST_UNSET", time.Second), time.Second},
+	} {
+		if tc.got != tc.want {
+			t.Errorf("%s: got %v, want %v", tc.name, tc.got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"log/slog"
+	"sync"
+	"time"
+
+	"pygorp/backend/internal/clock"
+	"pygorp/backend/internal/idgen"
+)
+
+// Event types. Each version has a schema in schema/.
+const (
+	UserCreated = "user.created"
+	UserUpdated = "user.updated"
+	UserDeleted = "user.deleted"
+	UserMerged  = "user.merged"
+)
+
+// UserCreatedV1 is the data of user.created v1.
+type UserCreatedV1 struct {
+	ID    int    `json:"id"`
+	Email string `json:"email"`
+	Name  string `json:"name"`
+}
+
+// UserUpdatedV1 is the data of user.updated v1.
+type UserUpdatedV1 struct {
+	ID     int      `json:"id"`
+	Fields []string `json:"fields"`
+}
+
+// UserUpdatedV2 is the data of user.updated v2, which adds the
+// "attributes" field name.
+type UserUpdatedV2 struct {
+	ID     int      `json:"id"`
+	Fields []string `json:"fields"`
+}
+
+// UserDeletedV1 is the data of user.deleted v1.
+type UserDeletedV1 struct {
+	ID int `json:"id"`
+}
+
+// UserMergedV1 is the data of user.merged v1. Moved counts the rows
+// re-parented from the source user, by kind.
+type UserMergedV1 struct {
+	ID    int              `json:"id"`
+	Into  int              `json:"into"`
+	Moved map[string]int64 `json:"moved"`
+}
+
+// Event is the envelope around a domain event. Data matches the registered
+// schema for Type and Version. Seq is its position in the event log, set
+// once the event is stored.
+type Event struct {
+	Seq        int64           `json:"seq"`
+	ID         string          `json:"id"`
+	Type       string          `json:"type"`
+	Version    int             `json:"version"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	Data       json.RawMessage `json:"data"`
+}
+
+// Sink receives every emitted event.
+type Sink func(ctx context.Context, event *Event) error
+
+var (
+	mu    sync.RWMutex
+	sinks = []Sink{logSink}
+)
+
+// AddSink registers a sink. Sinks must be added during initialization.
+func AddSink(sink Sink) {
+	mu.Lock()
+	defer mu.Unlock()
+	sinks = append(sinks, sink)
+}
+
+// New builds an event and validates its data against the schema registry.
+// Unknown types or versions are rejected, so every event has a schema.
+func New(eventType string, version int, data interface{}) (*Event, error) {
+	schema, ok := Lookup(eventType, version)
+	if !ok {
+		return nil, fmt.Errorf("no schema registered for %s v%d", eventType, version)
+	}
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	if err := schema.Validate(raw); err != nil {
+		return nil, fmt.Errorf("%s v%d does not match its schema: %v", eventType, version, err)
+	}
+
+	return &Event{
+		ID:         idgen.NewID(),
+		Type:       eventType,
+		Version:    version,
+		OccurredAt: clock.Now().UTC(),
+		Data:       raw,
+	}, nil
+}
+
+// Emit validates an event and hands it to every sink.
+func Emit(ctx context.Context, eventType string, version int, data interface{}) error {
+	event, err := New(eventType, version, data)
+	if err != nil {
+		return err
+	}
+	return deliver(ctx, event)
+}
+
+func deliver(ctx context.Context, event *Event) error {
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, sink := range sinks {
+		if err := sink(ctx, event); err != nil {
+			return fmt.Errorf("failed to deliver %s: %v", event.Type, err)
+		}
+	}
+	return nil
+}
+
+// Publish emits an event after the change it describes has been committed.
+// Failures are logged rather than returned, since the change cannot be
+// undone at that point.
+//
+// Inside a transaction that commits later, such as an atomic batch's, events
+// are held until the caller releases them (see Hold).
+func Publish(ctx context.Context, eventType string, version int, data interface{}) {
+	if h, ok := ctx.Value(heldKey{}).(*Held); ok {
+		event, err := New(eventType, version, data)
+		if err != nil {
+			log.Printf("Failed to emit event: %v", err)
+			return
+		}
+		h.mu.Lock()
+		h.events = append(h.events, event)
+		h.mu.Unlock()
+		return
+	}
+	if err := Emit(ctx, eventType, version, data); err != nil {
+		log.Printf("Failed to emit event: %v", err)
+	}
+}
+
+type heldKey struct{}
+
+// Held collects the events published in a context returned by Hold.
+type Held struct {
+	mu     sync.Mutex
+	events []*Event
+}
+
+// Hold returns a context in which Publish collects events in the returned
+// Held instead of emitting them. Once the transaction the changes were made
+// in commits, Release emits them; if it rolls back they are dropped by not
+// releasing them.
+func Hold(ctx context.Context) (context.Context, *Held) {
+	h := &Held{}
+	return context.WithValue(ctx, heldKey{}, h), h
+}
+
+// Release emits the held events in the order they were published.
+func (h *Held) Release(ctx context.Context) {
+	h.mu.Lock()
+	held := h.events
+	h.events = nil
+	h.mu.Unlock()
+	for _, event := range held {
+		if err := deliver(ctx, event); err != nil {
+			log.Printf("Failed to emit event: %v", err)
+		}
+	}
+}
+
+func logSink(ctx context.Context, event *Event) error {
+	slog.Debug("Event emitted", "id", event.ID, "type", event.Type, "version", event.Version, "data", string(event.Data))
+	return nil
+}
@@ -0,0 +1,100 @@
+package events
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"pygorp/backend/internal/jsonschema"
+)
+
+// Schemas live in schema/<type>.v<version>.json. A published version must
+// never change; add a new version instead and emit both during migration.
+//
+//go:embed schema/*.json
+var schemaFiles embed.FS
+
+// Schema is a registered event schema.
+type Schema struct {
+	Type    string          `json:"type"`
+	Version int             `json:"version"`
+	Schema  json.RawMessage `json:"schema"`
+
+	root *jsonschema.Schema
+}
+
+var registry = map[string]*Schema{}
+
+func init() {
+	entries, err := schemaFiles.ReadDir("schema")
+	if err != nil {
+		panic(err)
+	}
+	for _, entry := range entries {
+		s, err := loadSchema(entry.Name())
+		if err != nil {
+			panic(fmt.Sprintf("invalid event schema %s: %v", entry.Name(), err))
+		}
+		registry[key(s.Type, s.Version)] = s
+	}
+}
+
+func loadSchema(file string) (*Schema, error) {
+	base := strings.TrimSuffix(file, ".json")
+	i := strings.LastIndex(base, ".v")
+	if i < 0 {
+		return nil, fmt.Errorf("file name must be <type>.v<version>.json")
+	}
+	version, err := strconv.Atoi(base[i+2:])
+	if err != nil || version < 1 {
+		return nil, fmt.Errorf("invalid version in file name")
+	}
+
+	data, err := schemaFiles.ReadFile(path.Join("schema", file))
+	if err != nil {
+		return nil, err
+	}
+	var root jsonschema.Schema
+	if err := json.Unmarshal(data, &root); err != nil {
+		return nil, err
+	}
+	return &Schema{Type: base[:i], Version: version, Schema: data, root: &root}, nil
+}
+
+// Lookup returns the schema for an event type and version.
+func Lookup(eventType string, version int) (*Schema, bool) {
+	s, ok := registry[key(eventType, version)]
+	return s, ok
+}
+
+// Schemas returns every registered schema ordered by type and version.
+func Schemas() []*Schema {
+	list := make([]*Schema, 0, len(registry))
+	for _, s := range registry {
+		list = append(list, s)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Type != list[j].Type {
+			return list[i].Type < list[j].Type
+		}
+		return list[i].Version < list[j].Version
+	})
+	return list
+}
+
+// Validate checks event data against the schema.
+func (s *Schema) Validate(data json.RawMessage) error {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	return s.root.Validate("data", v)
+}
+
+func key(eventType string, version int) string {
+	return eventType + ".v" + strconv.Itoa(version)
+}
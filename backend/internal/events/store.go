@@ -0,0 +1,81 @@
+package events
+
+import (
+	"context"
+	"database/sql"
+
+	"pygorp/backend/internal/database"
+	"pygorp/backend/internal/sqlb"
+
+	"github.com/lib/pq"
+)
+
+// appendLockID serializes appends to the event log. Holding it until commit
+// makes sequence numbers become visible in order, so a consumer that has
+// read up to seq N never misses an event with a lower seq committed later.
+const appendLockID = 0x6576656e7473 // "events"
+
+const eventColumns = "seq, id, type, version, data, occurred_at"
+
+func init() {
+	database.UseColumns("events", eventColumns)
+	AddSink(appendToLog)
+}
+
+// appendToLog persists an event and sets its sequence number.
+func appendToLog(ctx context.Context, event *Event) error {
+	tx, err := database.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", appendLockID); err != nil {
+		return err
+	}
+	err = tx.QueryRowContext(ctx,
+		"INSERT INTO events (id, type, version, data, occurred_at) VALUES ($1, $2, $3, $4, $5) RETURNING seq",
+		event.ID, event.Type, event.Version, []byte(event.Data), event.OccurredAt).Scan(&event.Seq)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// List returns up to limit events with a sequence number above afterSeq, in
+// order. When types is non-empty only those event types are returned.
+func List(ctx context.Context, afterSeq int64, types []string, limit int) ([]Event, error) {
+	q := sqlb.Select(eventColumns).From("events").Where("seq > ?", afterSeq).OrderBy("seq").Limit(limit)
+	if len(types) > 0 {
+		q.Where("type = ANY(?)", pq.Array(types))
+	}
+	query, args, err := q.Build()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := database.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []Event{}
+	for rows.Next() {
+		var e Event
+		var data []byte
+		if err := rows.Scan(&e.Seq, &e.ID, &e.Type, &e.Version, &data, &e.OccurredAt); err != nil {
+			return nil, err
+		}
+		e.Data = data
+		list = append(list, e)
+	}
+	return list, rows.Err()
+}
+
+// LatestSeq returns the highest sequence number in the log, or 0 when empty.
+func LatestSeq(ctx context.Context) (int64, error) {
+	var seq sql.NullInt64
+	err := database.DB.QueryRowContext(ctx, "SELECT MAX(seq) FROM events").Scan(&seq)
+	return seq.Int64, err
+}
@@ -0,0 +1,134 @@
+// Package fixtures fills a staging database with fake users and groups
+// whose shape resembles real data: common names and large email domains
+// appear more often, sign-ups grow toward the present, and group sizes
+// follow a long tail. Addresses use the reserved .example TLD, so nothing
+// generated here can reach a real mailbox.
+package fixtures
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"time"
+
+	"pygorp/backend/internal/database"
+
+	"github.com/lib/pq"
+)
+
+// Enabled reports whether fixtures may be generated: everywhere except
+// ENV=production.
+func Enabled() bool {
+	return os.Getenv("ENV") != "production"
+}
+
+// Options say how much to generate. The same Seed generates the same users
+// and groups, with dates relative to the current time.
+type Options struct {
+	Users  int
+	Groups int
+	Seed   int64
+}
+
+// Result counts what was inserted. Users and groups whose email or name
+// already exists are skipped.
+type Result struct {
+	Seed          int64 `json:"seed"`
+	Users         int   `json:"users"`
+	Groups        int   `json:"groups"`
+	Memberships   int   `json:"memberships"`
+	SkippedUsers  int   `json:"skipped_users"`
+	SkippedGroups int   `json:"skipped_groups"`
+}
+
+const (
+	insertUsersQuery = `
+		INSERT INTO users (email, name, created_at, updated_at)
+		SELECT * FROM unnest($1::text[], $2::text[], $3::timestamptz[], $4::timestamptz[])
+		ON CONFLICT (email) DO NOTHING
+		RETURNING id, created_at`
+	insertGroupsQuery = `
+		INSERT INTO groups (name, description, created_at, updated_at)
+		SELECT name, description, created_at, created_at
+		FROM unnest($1::text[], $2::text[], $3::timestamptz[]) AS g(name, description, created_at)
+		ON CONFLICT (name) DO NOTHING
+		RETURNING id, created_at`
+	insertMembersQuery = `
+		INSERT INTO group_members (group_id, user_id, added_at)
+		SELECT * FROM unnest($1::int[], $2::int[], $3::timestamptz[])
+		ON CONFLICT DO NOTHING`
+)
+
+// row is an inserted user or group.
+type row struct {
+	id        int
+	createdAt time.Time
+}
+
+// Generate inserts the users, then the groups with members drawn from the
+// new users, in one transaction. Rows are written directly, so no
+// user.created events are published and no emails are sent.
+func Generate(ctx context.Context, opts Options) (*Result, error) {
+	g := newGenerator(opts.Seed)
+	result := &Result{Seed: opts.Seed}
+
+	tx, err := database.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var users []row
+	if opts.Users > 0 {
+		emails, names, created, updated := g.users(opts.Users)
+		users, err = insertRows(ctx, tx, insertUsersQuery, pq.Array(emails), pq.Array(names), pq.Array(created), pq.Array(updated))
+		if err != nil {
+			return nil, fmt.Errorf("insert users: %w", err)
+		}
+		result.Users, result.SkippedUsers = len(users), opts.Users-len(users)
+	}
+
+	if opts.Groups > 0 {
+		names, descriptions, created := g.groups(opts.Groups)
+		groups, err := insertRows(ctx, tx, insertGroupsQuery, pq.Array(names), pq.Array(descriptions), pq.Array(created))
+		if err != nil {
+			return nil, fmt.Errorf("insert groups: %w", err)
+		}
+		result.Groups, result.SkippedGroups = len(groups), opts.Groups-len(groups)
+
+		if len(users) > 0 && len(groups) > 0 {
+			groupIDs, userIDs, added := g.memberships(groups, users)
+			res, err := tx.ExecContext(ctx, insertMembersQuery, pq.Array(groupIDs), pq.Array(userIDs), pq.Array(added))
+			if err != nil {
+				return nil, fmt.Errorf("insert group members: %w", err)
+			}
+			n, _ := res.RowsAffected()
+			result.Memberships = int(n)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// insertRows runs an INSERT ... RETURNING id, created_at.
+func insertRows(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) ([]row, error) {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var inserted []row
+	for rows.Next() {
+		var r row
+		if err := rows.Scan(&r.id, &r.createdAt); err != nil {
+			return nil, err
+		}
+		inserted = append(inserted, r)
+	}
+	return inserted, rows.Err()
+}
@@ -0,0 +1,170 @@
+package fixtures
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"time"
+
+	"pygorp/backend/internal/clock"
+)
+
+// history is how far back generated sign-ups go.
+const history = 365 * 24 * time.Hour
+
+// Pools are ordered from most to least common; picks are Zipf-distributed
+// over them.
+var (
+	firstNames = []string{
+		"James", "Mary", "Maria", "Wei", "Mohammed", "Anna", "David", "Sofia", "Michael", "Emma",
+		"Ahmed", "Olivia", "Daniel", "Yuki", "Lucas", "Fatima", "Juan", "Priya", "Ivan", "Chloe",
+		"Ali", "Hannah", "Kenji", "Amara", "Mateo", "Ingrid", "Tomasz", "Aisha", "Noah", "Leila",
+		"Arjun", "Elif", "Oliver", "Zanele", "Pierre", "Mei", "Kwame", "Astrid", "Diego", "Siobhan",
+	}
+	lastNames = []string{
+		"Smith", "Wang", "Garcia", "Kim", "Müller", "Nguyen", "Johnson", "Rossi", "Silva", "Kowalski",
+		"Li", "Brown", "Martin", "Sato", "Ivanov", "Khan", "Jones", "Dubois", "Hansen", "Okafor",
+		"Patel", "Novak", "O'Brien", "Yilmaz", "Fernández", "Andersson", "Tanaka", "Mensah", "Costa", "Haddad",
+	}
+	// domains mix a few large employers with a long tail of small ones.
+	domains = []string{
+		"acme.example", "globex.example", "initech.example", "umbrella.example", "hooli.example",
+		"stark.example", "wayne.example", "soylent.example", "cyberdyne.example", "tyrell.example",
+		"wonka.example", "oscorp.example", "aperture.example", "blackmesa.example", "vandelay.example",
+	}
+	departments = []string{
+		"Engineering", "Sales", "Support", "Marketing", "Operations", "Finance", "Design",
+		"Product", "Security", "People", "Legal", "Data", "Research", "Facilities",
+	}
+	sites = []string{"Berlin", "Jakarta", "Austin", "Lagos", "Osaka", "São Paulo", "Toronto", "Warsaw"}
+)
+
+type generator struct {
+	rng *rand.Rand
+	now time.Time
+}
+
+func newGenerator(seed int64) *generator {
+	return &generator{rng: rand.New(rand.NewSource(seed)), now: clock.Now()}
+}
+
+// pick returns an index into a pool of n, favouring the front.
+func (g *generator) pick(n int) int {
+	return int(rand.NewZipf(g.rng, 1.2, 2, uint64(n-1)).Uint64())
+}
+
+// signupTime skews toward the present, as for a product that is growing:
+// the age is history times the square of a uniform draw.
+func (g *generator) signupTime() time.Time {
+	u := g.rng.Float64()
+	return g.now.Add(-time.Duration(u * u * float64(history))).Truncate(time.Second)
+}
+
+// between returns a time in [from, g.now], or from if it is later.
+func (g *generator) between(from time.Time) time.Time {
+	if !from.Before(g.now) {
+		return from
+	}
+	return from.Add(time.Duration(g.rng.Int63n(int64(g.now.Sub(from)) + 1))).Truncate(time.Second)
+}
+
+func (g *generator) users(n int) (emails, names, created, updated []string) {
+	seen := make(map[string]bool, n)
+	for len(emails) < n {
+		first, last := firstNames[g.pick(len(firstNames))], lastNames[g.pick(len(lastNames))]
+		local := g.localPart(first, last)
+		email := local + "@" + domains[g.pick(len(domains))]
+		if seen[email] {
+			email = fmt.Sprintf("%s%d@%s", local, g.rng.Intn(10000), domains[g.pick(len(domains))])
+			if seen[email] {
+				continue
+			}
+		}
+		seen[email] = true
+
+		createdAt := g.signupTime()
+		updatedAt := createdAt
+		// Most accounts are never edited after sign-up.
+		if g.rng.Float64() < 0.3 {
+			updatedAt = g.between(createdAt)
+		}
+		emails = append(emails, email)
+		names = append(names, first+" "+last)
+		created = append(created, createdAt.Format(time.RFC3339))
+		updated = append(updated, updatedAt.Format(time.RFC3339))
+	}
+	return emails, names, created, updated
+}
+
+// localPart formats an address the way companies commonly do.
+func (g *generator) localPart(first, last string) string {
+	first, last = asciiLower(first), asciiLower(last)
+	switch r := g.rng.Float64(); {
+	case r < 0.6:
+		return first + "." + last
+	case r < 0.8:
+		return first[:1] + last
+	case r < 0.9:
+		return first + last[:1]
+	default:
+		return first + "_" + last
+	}
+}
+
+// asciiLower lowercases a name and drops what is awkward in an address.
+func asciiLower(s string) string {
+	s = strings.NewReplacer("ü", "u", "á", "a", "é", "e", "ã", "a", "'", "").Replace(s)
+	return strings.ToLower(s)
+}
+
+func (g *generator) groups(n int) (names, descriptions, created []string) {
+	seen := make(map[string]bool, n)
+	for attempts := 0; len(names) < n && attempts < 10*n; attempts++ {
+		dept := departments[g.pick(len(departments))]
+		name := dept + " " + sites[g.rng.Intn(len(sites))]
+		if seen[name] {
+			name = fmt.Sprintf("%s %d", name, 2+g.rng.Intn(98))
+		}
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		names = append(names, name)
+		descriptions = append(descriptions, fmt.Sprintf("%s team in %s", dept, strings.TrimPrefix(name, dept+" ")))
+		created = append(created, g.signupTime().Format(time.RFC3339))
+	}
+	return names, descriptions, created
+}
+
+// maxGroupSize bounds generated groups, which keeps the membership insert
+// small however many users there are.
+const maxGroupSize = 250
+
+// memberships assigns users to groups. Sizes follow a power law, so a few
+// groups are large and most are small; a member is added no earlier than
+// both the group and the user exist.
+func (g *generator) memberships(groups, users []row) (groupIDs, userIDs []int, added []string) {
+	idx := make([]int, len(users))
+	for i := range idx {
+		idx[i] = i
+	}
+	for _, group := range groups {
+		size := int(math.Ceil(float64(min(len(users), maxGroupSize)) * math.Pow(g.rng.Float64(), 3)))
+		size = min(max(size, 1+g.rng.Intn(5)), len(users))
+		// A partial shuffle picks size distinct users.
+		for k := 0; k < size; k++ {
+			j := k + g.rng.Intn(len(idx)-k)
+			idx[k], idx[j] = idx[j], idx[k]
+			user := users[idx[k]]
+			since := group.createdAt
+			if user.createdAt.After(since) {
+				since = user.createdAt
+			}
+			groupIDs = append(groupIDs, group.id)
+			userIDs = append(userIDs, user.id)
+			added = append(added, g.between(since).Format(time.RFC3339))
+		}
+	}
+	return groupIDs, userIDs, added
+}
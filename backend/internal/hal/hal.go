@@ -0,0 +1,233 @@
+// Package hal adds HAL hypermedia links (https://stateless.co/hal_specification.html)
+// to responses for clients that send "Accept: application/hal+json", so
+// they can follow links instead of hard-coding URL templates. Records in
+// the {"data": ...} envelope get "_links" to themselves and to the GET
+// routes nested under them, and the envelope gets links to the current
+// page and its neighbours. Links come from the route table, so they always
+// point at routes that exist.
+package hal
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"pygorp/backend/internal/render"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MediaType is the HAL media type.
+const MediaType = "application/hal+json"
+
+// Template is a GET route that can be linked from a resource of some type,
+// such as /api/v1/users/:id/groups for users.
+type Template struct {
+	// Rel names the link: "self" for the resource's own route, otherwise
+	// the path after it, such as "groups".
+	Rel  string
+	Path string
+	// IDParam is the path parameter the resource's ID fills.
+	IDParam string
+}
+
+// Registry lists the link templates of each resource type.
+type Registry map[string][]Template
+
+// Add records the links a GET route offers: for every fixed segment
+// followed by a parameter, such as users/:id, the route is a link of that
+// resource type when the rest of its path has no other parameters.
+func (r Registry) Add(path string) {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for i := 0; i+1 < len(segments); i++ {
+		typ, param := segments[i], segments[i+1]
+		if isParam(typ) || !strings.HasPrefix(param, ":") {
+			continue
+		}
+		rest := segments[i+2:]
+		rel := "self"
+		if len(rest) > 0 {
+			rel = strings.Join(rest, "_")
+		}
+		if strings.ContainsAny(rel, ":*") {
+			continue
+		}
+		r[typ] = append(r[typ], Template{Rel: rel, Path: path, IDParam: param[1:]})
+	}
+}
+
+func isParam(segment string) bool {
+	return strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*")
+}
+
+// Middleware adds links from registry to JSON responses for requests that
+// accept MediaType. The resource type comes from "resource_type" in the
+// context, set by the route table.
+func Middleware(registry Registry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !accepts(c.GetHeader("Accept")) {
+			c.Next()
+			return
+		}
+
+		w := render.NewBuffer(c.Writer, render.HoldAll)
+		c.Writer = w
+		c.Next()
+		c.Writer = w.ResponseWriter
+
+		body := w.Bytes()
+		if len(body) > 0 && w.Status() < http.StatusBadRequest && render.IsJSON(w.Header().Get("Content-Type")) {
+			if linked, ok := addLinks(c, registry, body); ok {
+				w.Header().Set("Content-Type", MediaType)
+				body = linked
+			}
+		}
+		w.Release(body)
+	}
+}
+
+func accepts(header string) bool {
+	for _, entry := range strings.Split(header, ",") {
+		if mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(entry)); err == nil && mediaType == MediaType {
+			return true
+		}
+	}
+	return false
+}
+
+type link struct {
+	Href      string `json:"href"`
+	Templated bool   `json:"templated,omitempty"`
+}
+
+// addLinks adds _links to the records in an envelope body and to the
+// envelope itself.
+func addLinks(c *gin.Context, registry Registry, body []byte) ([]byte, bool) {
+	var envelope map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if err := dec.Decode(&envelope); err != nil || envelope == nil {
+		return nil, false
+	}
+
+	api := apiPrefix(c.FullPath())
+	var templates []Template
+	for _, t := range registry[c.GetString("resource_type")] {
+		if apiPrefix(t.Path) == api {
+			templates = append(templates, t)
+		}
+	}
+	resolve := func(t Template, id string) (string, bool) {
+		return expand(t.Path, func(name string) string {
+			if name == t.IDParam {
+				return id
+			}
+			return c.Param(name)
+		})
+	}
+	linkRecord := func(v interface{}) {
+		record, ok := v.(map[string]interface{})
+		if !ok || record["id"] == nil {
+			return
+		}
+		links := map[string]link{}
+		for _, t := range templates {
+			if href, ok := resolve(t, idString(record["id"])); ok {
+				links[t.Rel] = link{Href: href}
+			}
+		}
+		if len(links) > 0 {
+			record["_links"] = links
+		}
+	}
+
+	links := map[string]link{"self": {Href: c.Request.URL.RequestURI()}}
+	switch data := envelope["data"].(type) {
+	case map[string]interface{}:
+		linkRecord(data)
+	case []interface{}:
+		for _, item := range data {
+			linkRecord(item)
+		}
+		pageLinks(c.Request.URL, len(data), links)
+		// Clients fill {id} from each item to reach it.
+		for _, t := range templates {
+			if t.Rel != "self" {
+				continue
+			}
+			if href, ok := resolve(t, "{id}"); ok {
+				links["item"] = link{Href: strings.Replace(href, url.PathEscape("{id}"), "{id}", 1), Templated: true}
+			}
+		}
+	}
+	envelope["_links"] = links
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(envelope); err != nil {
+		return nil, false
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), true
+}
+
+// expand fills a route path's parameters with value, reporting false when
+// one has no value.
+func expand(path string, value func(name string) string) (string, bool) {
+	segments := strings.Split(path, "/")
+	for i, s := range segments {
+		if !isParam(s) {
+			continue
+		}
+		v := value(s[1:])
+		if v == "" {
+			return "", false
+		}
+		segments[i] = url.PathEscape(v)
+	}
+	return strings.Join(segments, "/"), true
+}
+
+// apiPrefix returns the first two segments of a path, such as /api/v1, so
+// links stay within the API the request was made to.
+func apiPrefix(path string) string {
+	segments := strings.SplitN(strings.TrimPrefix(path, "/"), "/", 3)
+	return strings.Join(segments[:min(2, len(segments))], "/")
+}
+
+func idString(id interface{}) string {
+	switch v := id.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	}
+	b, _ := json.Marshal(id)
+	return string(b)
+}
+
+// pageLinks adds first, prev, and next links to lists paged with limit and
+// offset. A full page is assumed to have a next one.
+func pageLinks(u *url.URL, n int, links map[string]link) {
+	limit, err := strconv.Atoi(u.Query().Get("limit"))
+	if err != nil || limit < 1 {
+		return
+	}
+	offset, _ := strconv.Atoi(u.Query().Get("offset"))
+	at := func(offset int) link {
+		q := u.Query()
+		q.Set("offset", strconv.Itoa(max(offset, 0)))
+		return link{Href: u.Path + "?" + q.Encode()}
+	}
+	links["first"] = at(0)
+	if offset > 0 {
+		links["prev"] = at(offset - limit)
+	}
+	if n >= limit {
+		links["next"] = at(offset + limit)
+	}
+}
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"pygorp/backend/internal/config"
+	"pygorp/backend/internal/leader"
+	"pygorp/backend/internal/loadshed"
+	"pygorp/backend/internal/logger"
+	"pygorp/backend/internal/middleware"
+	"pygorp/backend/internal/render"
+	"pygorp/backend/internal/slo"
+
+	"github.com/gin-gonic/gin"
+)
+
+func ReloadConfig(c *gin.Context) {
+	rt, err := config.Reload()
+	if err != nil {
+		render.JSON(c, http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+
+	render.JSON(c, http.StatusOK, gin.H{"data": rt})
+}
+
+type setLogLevelRequest struct {
+	Level    string `json:"level" binding:"required"`
+	Duration string `json:"duration"`
+}
+
+func GetLogLevel(c *gin.Context) {
+	render.JSON(c, http.StatusOK, gin.H{"data": logger.Current()})
+}
+
+func SetLogLevel(c *gin.Context) {
+	var req setLogLevelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	duration := config.Current().LogLevelResetDuration()
+	if req.Duration != "" {
+		d, err := time.ParseDuration(req.Duration)
+		if err != nil || d <= 0 {
+			render.JSON(c, http.StatusBadRequest, gin.H{"error": "Invalid duration"})
+			return
+		}
+		duration = d
+	}
+
+	status, err := logger.Override(req.Level, duration)
+	if err != nil {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": "Invalid log level"})
+		return
+	}
+
+	render.JSON(c, http.StatusOK, gin.H{"data": status})
+}
+
+type setReadOnlyRequest struct {
+	// Enabled switches read-only mode on or off; null clears the override.
+	Enabled *bool `json:"enabled"`
+}
+
+func GetReadOnly(c *gin.Context) {
+	render.JSON(c, http.StatusOK, gin.H{"data": middleware.ReadOnly()})
+}
+
+func SetReadOnly(c *gin.Context) {
+	var req setReadOnlyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	render.JSON(c, http.StatusOK, gin.H{"data": middleware.SetReadOnly(req.Enabled)})
+}
+
+type routeLoad struct {
+	Route      string   `json:"route"`
+	P99Ms      float64  `json:"p99_ms"`
+	Samples    int      `json:"samples"`
+	BudgetMs   *float64 `json:"budget_ms"`
+	OverBudget bool     `json:"over_budget"`
+}
+
+// GetLoad reports requests in flight and each route's p99 latency over the
+// last minute against its budget.
+func GetLoad(c *gin.Context) {
+	cfg := config.Current().LoadShed
+	routes := []routeLoad{}
+	for _, l := range loadshed.Default.Latencies() {
+		r := routeLoad{Route: l.Route, P99Ms: milliseconds(l.P99), Samples: l.Samples}
+		if budget := cfg.BudgetFor(l.Route); budget > 0 {
+			ms := milliseconds(budget)
+			r.BudgetMs = &ms
+			r.OverBudget = l.Samples >= loadshed.MinSamples && l.P99 > budget
+		}
+		routes = append(routes, r)
+	}
+
+	render.JSON(c, http.StatusOK, gin.H{"data": gin.H{
+		"in_flight":     loadshed.Default.InFlight(),
+		"max_in_flight": cfg.MaxInFlight,
+		"routes":        routes,
+	}})
+}
+
+// GetSLO reports the objectives of each route group with their burn rates
+// over each window and the alerts firing, for this instance.
+func GetSLO(c *gin.Context) {
+	render.JSON(c, http.StatusOK, gin.H{"data": slo.Report()})
+}
+
+// ListLeaders returns the lease of each singleton background process: the
+// instance leading it, and whether the lease has lapsed.
+func ListLeaders(c *gin.Context) {
+	leases, err := leader.Leases(c.Request.Context())
+	if err != nil {
+		c.Error(err)
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to fetch leases"})
+		return
+	}
+	render.JSON(c, http.StatusOK, gin.H{"data": leases})
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+
+	"pygorp/backend/internal/attachments"
+	"pygorp/backend/internal/models"
+	"pygorp/backend/internal/policy"
+	"pygorp/backend/internal/render"
+	"pygorp/backend/internal/repository"
+	"pygorp/backend/internal/sqlb"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListProjectAttachments returns a page of the files attached to a project,
+// newest first.
+func ListProjectAttachments(c *gin.Context) {
+	project, _, _, ok := projectAccess(c, policy.PermissionRead)
+	if !ok {
+		return
+	}
+	limit, offset, ok := parsePage(c)
+	if !ok {
+		return
+	}
+
+	list, err := repository.ListAttachments(c.Request.Context(), models.ProjectResource, project.ID, sqlb.Page{Limit: limit, Offset: offset})
+	if err != nil {
+		c.Error(err)
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to fetch attachments"})
+		return
+	}
+
+	render.JSON(c, http.StatusOK, gin.H{"data": list})
+}
+
+// UploadProjectAttachment attaches a multipart "file" to a project. Files
+// over ATTACHMENT_MAX_BYTES or of a type outside ATTACHMENT_TYPES are
+// rejected.
+func UploadProjectAttachment(c *gin.Context) {
+	project, userID, _, ok := projectAccess(c, policy.PermissionWrite)
+	if !ok {
+		return
+	}
+	maxBytes := attachments.MaxBytes()
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": "file is required"})
+		return
+	}
+	if fileHeader.Size > maxBytes {
+		render.JSON(c, http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("Attachment must be at most %d bytes", maxBytes)})
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": "Failed to read file"})
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxBytes))
+	if err != nil {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": "Failed to read file"})
+		return
+	}
+
+	if !scanUpload(c, data) {
+		return
+	}
+
+	attachment, err := attachments.Upload(c.Request.Context(), models.ProjectResource, project.ID, userID, fileHeader.Filename, data)
+	if !attachmentPolicyOK(c, err, "Failed to store attachment") {
+		return
+	}
+
+	render.JSON(c, http.StatusCreated, gin.H{"data": attachment})
+}
+
+func GetProjectAttachment(c *gin.Context) {
+	attachment, ok := projectAttachment(c, policy.PermissionRead, "Failed to fetch attachment")
+	if !ok {
+		return
+	}
+
+	render.JSON(c, http.StatusOK, gin.H{"data": attachment})
+}
+
+// DownloadProjectAttachment returns an attachment's bytes. They are always
+// served as a download so uploaded HTML or SVG never renders inline.
+func DownloadProjectAttachment(c *gin.Context) {
+	attachment, ok := projectAttachment(c, policy.PermissionRead, "Failed to fetch attachment")
+	if !ok {
+		return
+	}
+
+	data, err := attachments.Read(c.Request.Context(), attachment)
+	if err != nil {
+		c.Error(err)
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to read attachment"})
+		return
+	}
+
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Filename}))
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("Cache-Control", "private, no-cache")
+	c.Data(http.StatusOK, attachment.ContentType, data)
+}
+
+// DeleteProjectAttachment removes an attachment. Its bytes are deleted from
+// storage in the background.
+func DeleteProjectAttachment(c *gin.Context) {
+	attachment, ok := projectAttachment(c, policy.PermissionWrite, "Failed to delete attachment")
+	if !ok {
+		return
+	}
+
+	err := repository.DeleteAttachment(c.Request.Context(), attachment.ResourceType, attachment.ResourceID, attachment.ID)
+	if !attachmentOK(c, err, "Failed to delete attachment") {
+		return
+	}
+
+	render.JSON(c, http.StatusOK, gin.H{"message": "Attachment deleted successfully"})
+}
+
+// projectAttachment loads the attachment in the path for a caller holding
+// the wanted permission on its project.
+func projectAttachment(c *gin.Context, want, message string) (models.Attachment, bool) {
+	project, _, _, ok := projectAccess(c, want)
+	if !ok {
+		return models.Attachment{}, false
+	}
+	attachmentID, ok := parseIDParam(c, "attachment_id", "Invalid attachment ID")
+	if !ok {
+		return models.Attachment{}, false
+	}
+
+	attachment, err := repository.GetAttachment(c.Request.Context(), models.ProjectResource, project.ID, attachmentID)
+	return attachment, attachmentOK(c, err, message)
+}
+
+// attachmentPolicyOK writes the response for an upload the attachment
+// policy or storage rejected and reports whether err was nil.
+func attachmentPolicyOK(c *gin.Context, err error, message string) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, attachments.ErrTooLarge):
+		render.JSON(c, http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("Attachment must be at most %d bytes", attachments.MaxBytes())})
+	case errors.Is(err, attachments.ErrTypeNotAllowed):
+		render.JSON(c, http.StatusUnsupportedMediaType, gin.H{"error": err.Error()})
+	default:
+		log.Printf("Attachment upload failed: %v", err)
+		c.Error(err)
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": message})
+	}
+	return false
+}
+
+// attachmentOK writes the response for a failed attachment lookup or
+// change and reports whether err was nil.
+func attachmentOK(c *gin.Context, err error, message string) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, repository.ErrNotFound):
+		render.JSON(c, http.StatusNotFound, gin.H{"error": "Attachment not found"})
+	default:
+		c.Error(err)
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": message})
+	}
+	return false
+}
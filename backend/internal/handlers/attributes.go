@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"pygorp/backend/internal/attributes"
+	"pygorp/backend/internal/render"
+	"pygorp/backend/internal/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+func ListUserAttributes(c *gin.Context) {
+	defs, err := repository.ListAttributeDefinitions(c.Request.Context())
+	if err != nil {
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to fetch attribute definitions"})
+		return
+	}
+
+	render.JSON(c, http.StatusOK, gin.H{"data": defs})
+}
+
+// PutUserAttribute creates or replaces the definition named in the path.
+func PutUserAttribute(c *gin.Context) {
+	var def attributes.Definition
+	if err := c.ShouldBindJSON(&def); err != nil {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	def.Name = c.Param("name")
+	if err := def.Check(); err != nil {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	saved, err := repository.PutAttributeDefinition(c.Request.Context(), &def)
+	if err != nil {
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to save attribute definition"})
+		return
+	}
+
+	render.JSON(c, http.StatusOK, gin.H{"data": saved})
+}
+
+// DeleteUserAttribute removes a definition along with its values on every
+// user.
+func DeleteUserAttribute(c *gin.Context) {
+	err := repository.DeleteAttributeDefinition(c.Request.Context(), c.Param("name"))
+	if errors.Is(err, repository.ErrNotFound) {
+		render.JSON(c, http.StatusNotFound, gin.H{"error": "Attribute definition not found"})
+		return
+	}
+	if err != nil {
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to delete attribute definition"})
+		return
+	}
+
+	render.JSON(c, http.StatusOK, gin.H{"message": "Attribute definition deleted successfully"})
+}
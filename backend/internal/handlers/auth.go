@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"pygorp/backend/internal/auth"
+	"pygorp/backend/internal/render"
+
+	"github.com/gin-gonic/gin"
+)
+
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// RefreshSession exchanges a refresh token for new tokens.
+func RefreshSession(c *gin.Context) {
+	var req refreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tokens, err := auth.Refresh(c.Request.Context(), req.RefreshToken, authClient(c))
+	if !authOK(c, err) {
+		return
+	}
+
+	render.JSON(c, http.StatusOK, gin.H{"data": tokens})
+}
+
+// Logout revokes the session of the access token used for the request.
+func Logout(c *gin.Context) {
+	session := c.GetString("auth_session")
+	if session == "" {
+		render.JSON(c, http.StatusUnauthorized, gin.H{"error": "Not signed in"})
+		return
+	}
+
+	if err := auth.Revoke(c.Request.Context(), session); err != nil {
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to sign out"})
+		return
+	}
+
+	render.JSON(c, http.StatusOK, gin.H{"message": "Signed out successfully"})
+}
+
+func authClient(c *gin.Context) auth.Client {
+	return auth.Client{
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+		Fingerprint: auth.Fingerprint(
+			c.Request.UserAgent(),
+			c.GetHeader("Accept-Language"),
+			c.GetHeader("Sec-CH-UA-Platform"),
+		),
+	}
+}
+
+// authOK writes the response for a failed session operation and reports
+// whether err was nil.
+func authOK(c *gin.Context, err error) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, auth.ErrDisabled):
+		render.JSON(c, http.StatusForbidden, gin.H{"error": "Authentication is disabled"})
+	case errors.Is(err, auth.ErrInvalidToken), errors.Is(err, auth.ErrExpired):
+		render.JSON(c, http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+	case errors.Is(err, auth.ErrDeactivated):
+		render.JSON(c, http.StatusForbidden, gin.H{"error": "Account is pending deletion"})
+	case errors.Is(err, auth.ErrTooManyLinks):
+		render.JSON(c, http.StatusTooManyRequests, gin.H{"error": err.Error()})
+	default:
+		c.Error(err)
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to issue session"})
+	}
+	return false
+}
+
+// currentUserID returns the signed-in user, writing a 401 response when the
+// request has neither a session nor a personal access token.
+func currentUserID(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.GetString("auth_subject"))
+	if err != nil || (c.GetString("auth_session") == "" && c.GetString("auth_token") == "") {
+		render.JSON(c, http.StatusUnauthorized, gin.H{"error": "Not signed in"})
+		return 0, false
+	}
+	return id, true
+}
+
+// sessionUserID is currentUserID for managing credentials: it also writes
+// a 403 response with message when the request is not made from a
+// signed-in session, so a personal access token cannot add or remove
+// credentials whatever its scopes.
+func sessionUserID(c *gin.Context, message string) (int, bool) {
+	id, ok := currentUserID(c)
+	if !ok {
+		return 0, false
+	}
+	if c.GetString("auth_session") == "" {
+		render.JSON(c, http.StatusForbidden, gin.H{"error": message})
+		return 0, false
+	}
+	return id, true
+}
+
+// callerID returns the ID of the user making the request, or 0 when the
+// caller is not a user, such as a service account or an anonymous caller.
+func callerID(c *gin.Context) int {
+	id, _ := strconv.Atoi(c.GetString("auth_subject"))
+	return id
+}
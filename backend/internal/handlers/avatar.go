@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"pygorp/backend/internal/avatars"
+	"pygorp/backend/internal/render"
+	"pygorp/backend/internal/repository"
+	"pygorp/backend/internal/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UploadAvatar stores a new avatar for the user. Resized variants are
+// rendered by a worker and appear on the user resource when ready.
+func UploadAvatar(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": "file is required"})
+		return
+	}
+	if fileHeader.Size > avatars.MaxBytes {
+		render.JSON(c, http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("Avatar must be at most %d bytes", avatars.MaxBytes)})
+		return
+	}
+
+	if _, err := repository.GetUser(c.Request.Context(), id); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			render.JSON(c, http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": "Failed to read file"})
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, avatars.MaxBytes))
+	if err != nil {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": "Failed to read file"})
+		return
+	}
+
+	if !scanUpload(c, data) {
+		return
+	}
+
+	if err := avatars.Upload(c.Request.Context(), id, data); err != nil {
+		if errors.Is(err, avatars.ErrUnsupported) || errors.Is(err, avatars.ErrTooLarge) {
+			render.JSON(c, http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("Avatar upload for user %d failed: %v", id, err)
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to store avatar"})
+		return
+	}
+
+	render.JSON(c, http.StatusAccepted, gin.H{"data": gin.H{"status": "processing"}})
+}
+
+// ServeMedia serves avatars from local storage. Keys are content-addressed,
+// so responses can be cached indefinitely. Everything else in storage, such
+// as attachment blobs and upload chunks, is private and only served through
+// endpoints that check access.
+func ServeMedia(c *gin.Context) {
+	store, err := storage.Default()
+	local, ok := store.(*storage.Local)
+	key := strings.TrimPrefix(c.Param("key"), "/")
+	if err != nil || !ok || !strings.HasPrefix(path.Clean("/"+key), "/avatars/") {
+		render.JSON(c, http.StatusNotFound, gin.H{"error": "Not found"})
+		return
+	}
+
+	data, err := local.Get(c.Request.Context(), key)
+	if err != nil {
+		render.JSON(c, http.StatusNotFound, gin.H{"error": "Not found"})
+		return
+	}
+
+	c.Header("Cache-Control", "public, max-age=31536000, immutable")
+	c.Data(http.StatusOK, http.DetectContentType(data), data)
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"net/http"
+
+	"pygorp/backend/internal/backup"
+	"pygorp/backend/internal/render"
+
+	"github.com/gin-gonic/gin"
+)
+
+func ListBackups(c *gin.Context) {
+	store, err := backup.Store()
+	if err != nil {
+		c.Error(err)
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	backups, err := backup.List(c.Request.Context(), store)
+	if err != nil {
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to list backups"})
+		return
+	}
+	render.JSON(c, http.StatusOK, gin.H{"data": backups})
+}
+
+// CreateBackup takes a backup in the background and applies the retention
+// policy.
+func CreateBackup(c *gin.Context) {
+	startOperation(c, backup.TypeCreate, struct{}{})
+}
+
+// RestoreBackup restores a backup in the background. Everything except the
+// job queue is replaced.
+func RestoreBackup(c *gin.Context) {
+	var req backup.RestoreParams
+	if err := c.ShouldBindJSON(&req); err != nil || req.Key == "" {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": "key is required"})
+		return
+	}
+	startOperation(c, backup.TypeRestore, req)
+}
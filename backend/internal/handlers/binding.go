@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"net/http"
+
+	"pygorp/backend/internal/render"
+	"pygorp/backend/internal/sanitize"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// Request strings tagged with sanitize are cleaned after binding and
+// before validation.
+func init() {
+	binding.Validator = sanitize.Validator(binding.Validator)
+}
+
+// checkName normalizes a bound name in place and responds 400 when it
+// contains invisible, bidirectional, or unprintable characters. Empty names
+// are left to the binding rules.
+func checkName(c *gin.Context, field string, s *string) bool {
+	return checkText(c, sanitize.Name, field, s)
+}
+
+// checkEmail is checkName for email addresses.
+func checkEmail(c *gin.Context, field string, s *string) bool {
+	return checkText(c, sanitize.Email, field, s)
+}
+
+func checkText(c *gin.Context, check func(field, s string) (string, error), field string, s *string) bool {
+	if s == nil || *s == "" {
+		return true
+	}
+	normalized, err := check(field, *s)
+	if err != nil {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	*s = normalized
+	return true
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"pygorp/backend/internal/blocklist"
+	"pygorp/backend/internal/render"
+	"pygorp/backend/internal/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+func ListBlockedWords(c *gin.Context) {
+	list, err := repository.Blocklist(c.Request.Context())
+	if err != nil {
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to fetch blocked words"})
+		return
+	}
+
+	render.JSON(c, http.StatusOK, gin.H{"data": list})
+}
+
+// PutBlockedWord adds the word named in the path, or changes how it is
+// matched.
+func PutBlockedWord(c *gin.Context) {
+	var entry blocklist.Entry
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&entry); err != nil {
+			render.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	entry.Word = c.Param("word")
+	if err := entry.Check(); err != nil {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	saved, err := repository.PutBlockedWord(c.Request.Context(), &entry)
+	if err != nil {
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to save blocked word"})
+		return
+	}
+
+	render.JSON(c, http.StatusOK, gin.H{"data": saved})
+}
+
+func DeleteBlockedWord(c *gin.Context) {
+	err := repository.DeleteBlockedWord(c.Request.Context(), blocklist.Canonical(c.Param("word")))
+	if errors.Is(err, repository.ErrNotFound) {
+		render.JSON(c, http.StatusNotFound, gin.H{"error": "Blocked word not found"})
+		return
+	}
+	if err != nil {
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to delete blocked word"})
+		return
+	}
+
+	render.JSON(c, http.StatusOK, gin.H{"message": "Blocked word deleted successfully"})
+}
+
+// checkBlocked responds 400 with code "blocked_word" when a user-provided
+// name contains a blocked word. Empty names are skipped.
+func checkBlocked(c *gin.Context, field, value string) bool {
+	if value == "" {
+		return true
+	}
+	list, err := repository.Blocklist(c.Request.Context())
+	if err != nil {
+		c.Error(err)
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to load blocked words"})
+		return false
+	}
+	if err := list.Check(field, value); err != nil {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error(), "code": "blocked_word"})
+		return false
+	}
+	return true
+}
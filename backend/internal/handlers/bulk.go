@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"pygorp/backend/internal/models"
+	"pygorp/backend/internal/operations"
+	"pygorp/backend/internal/render"
+	"pygorp/backend/internal/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxBulkItems is the hard cap on records touched by one bulk request.
+const maxBulkItems = 1000
+
+func BulkDeleteUsers(c *gin.Context) {
+	ids, err := parseIDList(c.Query("ids"))
+	if err != nil {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(ids) == 0 {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": "ids query parameter is required"})
+		return
+	}
+	if len(ids) > maxBulkItems {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": fmt.Sprintf("At most %d users can be deleted per request", maxBulkItems)})
+		return
+	}
+
+	if c.Query("async") == "true" {
+		startBulkOperation(c, operations.TypeUserBulkDelete, ids, models.UpdateUserRequest{})
+		return
+	}
+
+	results := repository.BulkDeleteUsers(c.Request.Context(), ids)
+	render.JSON(c, http.StatusOK, gin.H{"data": bulkReport(results)})
+}
+
+func BulkUpdateUsers(c *gin.Context) {
+	var req models.BulkUpdateUsersRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Patch.Email != "" {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": "email cannot be changed in bulk"})
+		return
+	}
+	if req.Patch.Attributes != nil {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": "attributes cannot be changed in bulk"})
+		return
+	}
+	if req.Patch.Name == "" {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": "patch must set at least one field"})
+		return
+	}
+	if !checkName(c, "name", &req.Patch.Name) || !checkBlocked(c, "name", req.Patch.Name) {
+		return
+	}
+	if (len(req.IDs) == 0) == (req.Filter == nil) {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": "exactly one of ids or filter is required"})
+		return
+	}
+
+	ids := dedupeIDs(req.IDs)
+	if req.Filter != nil {
+		var err error
+		// Fetch one more than the cap to detect filters that match too much.
+		ids, err = repository.FindUserIDs(c.Request.Context(), *req.Filter, maxBulkItems+1)
+		if err != nil {
+			render.JSON(c, http.StatusBadRequest, gin.H{"error": "Invalid filter"})
+			return
+		}
+	}
+	if len(ids) > maxBulkItems {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": fmt.Sprintf("At most %d users can be updated per request", maxBulkItems)})
+		return
+	}
+
+	if c.Query("async") == "true" {
+		startBulkOperation(c, operations.TypeUserBulkUpdate, ids, req.Patch)
+		return
+	}
+
+	results := repository.BulkUpdateUsers(c.Request.Context(), ids, req.Patch)
+	render.JSON(c, http.StatusOK, gin.H{"data": bulkReport(results)})
+}
+
+func bulkReport(results []models.BulkItemResult) gin.H {
+	summary := map[string]int{}
+	for _, r := range results {
+		summary[r.Status]++
+	}
+	return gin.H{"results": results, "summary": summary}
+}
+
+// parseIDList parses a comma-separated list of positive IDs, dropping
+// duplicates.
+func parseIDList(raw string) ([]int, error) {
+	var ids []int
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		id, err := strconv.Atoi(part)
+		if err != nil || id < 1 {
+			return nil, fmt.Errorf("invalid user ID %q", part)
+		}
+		ids = append(ids, id)
+	}
+	return dedupeIDs(ids), nil
+}
+
+func dedupeIDs(ids []int) []int {
+	seen := make(map[int]bool, len(ids))
+	unique := ids[:0:0]
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,20 @@
+package handlers
+
+import "testing"
+
+// FuzzIDList parses the ids query parameter of bulk requests.
+func FuzzIDList(f *testing.F) {
+	f.Fuzz(func(t *testing.T, raw string) {
+		ids, err := parseIDList(raw)
+		if err != nil {
+			return
+		}
+		seen := map[int]bool{}
+		for _, id := range ids {
+			if id < 1 || seen[id] {
+				t.Fatalf("%q parsed to %v", raw, ids)
+			}
+			seen[id] = true
+		}
+	})
+}
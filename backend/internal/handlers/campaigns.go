@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"pygorp/backend/internal/campaigns"
+	"pygorp/backend/internal/models"
+	"pygorp/backend/internal/render"
+	"pygorp/backend/internal/sqlb"
+
+	"github.com/gin-gonic/gin"
+)
+
+func ListCampaigns(c *gin.Context) {
+	limit, offset, ok := parsePage(c)
+	if !ok {
+		return
+	}
+
+	list, err := campaigns.List(c.Request.Context(), sqlb.Page{Limit: limit, Offset: offset})
+	if err != nil {
+		c.Error(err)
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to fetch campaigns"})
+		return
+	}
+
+	render.JSON(c, http.StatusOK, gin.H{"data": list})
+}
+
+// CreateCampaign emails a template to every user in a segment, in the
+// background. The response is the pending campaign; poll it for progress.
+func CreateCampaign(c *gin.Context) {
+	var req models.CreateCampaignRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := campaigns.Validate(req); err != nil {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	campaign, err := campaigns.Create(c.Request.Context(), req)
+	if err != nil {
+		c.Error(err)
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to create campaign"})
+		return
+	}
+
+	render.JSON(c, http.StatusAccepted, gin.H{"data": campaign})
+}
+
+// GetCampaign returns a campaign with its recipients counted by status.
+func GetCampaign(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "Invalid campaign ID")
+	if !ok {
+		return
+	}
+
+	campaign, err := campaigns.Get(c.Request.Context(), id)
+	if !campaignOK(c, err, "Failed to fetch campaign") {
+		return
+	}
+
+	render.JSON(c, http.StatusOK, gin.H{"data": campaign})
+}
+
+// CancelCampaign stops a campaign. Emails already sent stay sent.
+func CancelCampaign(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "Invalid campaign ID")
+	if !ok {
+		return
+	}
+
+	campaign, err := campaigns.Cancel(c.Request.Context(), id)
+	if !campaignOK(c, err, "Failed to cancel campaign") {
+		return
+	}
+
+	render.JSON(c, http.StatusOK, gin.H{"data": campaign})
+}
+
+// campaignOK writes the response for a failed campaign lookup or change
+// and reports whether err was nil.
+func campaignOK(c *gin.Context, err error, message string) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, campaigns.ErrNotFound):
+		render.JSON(c, http.StatusNotFound, gin.H{"error": "Campaign not found"})
+	case errors.Is(err, campaigns.ErrFinished):
+		render.JSON(c, http.StatusConflict, gin.H{"error": "Campaign has already finished"})
+	default:
+		c.Error(err)
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": message})
+	}
+	return false
+}
@@ -0,0 +1,307 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+
+	"pygorp/backend/internal/loader"
+	"pygorp/backend/internal/mailer"
+	"pygorp/backend/internal/models"
+	"pygorp/backend/internal/policy"
+	"pygorp/backend/internal/render"
+	"pygorp/backend/internal/repository"
+	"pygorp/backend/internal/sqlb"
+	"pygorp/backend/internal/templates"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxMentions caps the users one comment can notify.
+const maxMentions = 20
+
+// mentionPattern matches @user:<id> in a comment body.
+var mentionPattern = regexp.MustCompile(`@user:([1-9][0-9]{0,9})\b`)
+
+// commentURL builds the link in mention emails. COMMENT_URL is the
+// frontend page that shows a comment in its project.
+func commentURL(cm models.Comment, orgID int) string {
+	base := os.Getenv("COMMENT_URL")
+	if base == "" {
+		base = "http://localhost:3000/comments"
+	}
+	q := url.Values{}
+	q.Set("org_id", strconv.Itoa(orgID))
+	q.Set("project_id", strconv.Itoa(cm.ResourceID))
+	q.Set("comment_id", strconv.Itoa(cm.ID))
+	return base + "?" + q.Encode()
+}
+
+// ListProjectComments returns a page of a project's top-level comments, or
+// with ?parent_id= the replies to one, oldest first.
+func ListProjectComments(c *gin.Context) {
+	project, _, _, ok := projectAccess(c, policy.PermissionRead)
+	if !ok {
+		return
+	}
+	parentID := 0
+	if raw := c.Query("parent_id"); raw != "" {
+		id, err := strconv.Atoi(raw)
+		if err != nil || id < 1 {
+			render.JSON(c, http.StatusBadRequest, gin.H{"error": "Invalid parent ID"})
+			return
+		}
+		parentID = id
+	}
+	limit, offset, ok := parsePage(c)
+	if !ok {
+		return
+	}
+
+	comments, err := repository.ListComments(c.Request.Context(), models.ProjectResource, project.ID, parentID, sqlb.Page{Limit: limit, Offset: offset})
+	if err != nil {
+		c.Error(err)
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to fetch comments"})
+		return
+	}
+
+	render.JSON(c, http.StatusOK, gin.H{"data": comments})
+}
+
+func GetProjectComment(c *gin.Context) {
+	project, _, _, ok := projectAccess(c, policy.PermissionRead)
+	if !ok {
+		return
+	}
+	commentID, ok := parseIDParam(c, "comment_id", "Invalid comment ID")
+	if !ok {
+		return
+	}
+
+	comment, err := repository.GetComment(c.Request.Context(), models.ProjectResource, project.ID, commentID)
+	if !commentOK(c, err, "Failed to fetch comment") {
+		return
+	}
+
+	render.JSON(c, http.StatusOK, gin.H{"data": comment})
+}
+
+// CreateProjectComment comments on a project, or replies to one of its
+// comments, as anyone who can read it. Users mentioned with @user:<id> who
+// can also read the project are emailed.
+func CreateProjectComment(c *gin.Context) {
+	project, userID, _, ok := projectAccess(c, policy.PermissionRead)
+	if !ok {
+		return
+	}
+	var req models.CreateCommentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctx := c.Request.Context()
+
+	mentions, err := mentionedReaders(c, project, req.Body, userID)
+	if err != nil {
+		c.Error(err)
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to create comment"})
+		return
+	}
+	comment, err := repository.CreateComment(ctx, models.ProjectResource, project.ID, userID, req, mentions)
+	if errors.Is(err, repository.ErrParentNotFound) {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": "parent_id is not a comment on this project"})
+		return
+	}
+	if !commentOK(c, err, "Failed to create comment") {
+		return
+	}
+
+	notifyMentions(c, project, comment, mentions, userID)
+	render.JSON(c, http.StatusCreated, gin.H{"data": comment})
+}
+
+// UpdateProjectComment edits the body of the caller's own comment. Only
+// users mentioned for the first time are emailed.
+func UpdateProjectComment(c *gin.Context) {
+	project, userID, _, ok := projectAccess(c, policy.PermissionRead)
+	if !ok {
+		return
+	}
+	commentID, ok := parseIDParam(c, "comment_id", "Invalid comment ID")
+	if !ok {
+		return
+	}
+	var req models.UpdateCommentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctx := c.Request.Context()
+
+	comment, err := repository.GetComment(ctx, models.ProjectResource, project.ID, commentID)
+	if !commentOK(c, err, "Failed to update comment") {
+		return
+	}
+	if comment.AuthorID == nil || *comment.AuthorID != userID {
+		render.JSON(c, http.StatusForbidden, gin.H{"error": "You can only edit your own comments"})
+		return
+	}
+
+	mentions, err := mentionedReaders(c, project, req.Body, userID)
+	if err != nil {
+		c.Error(err)
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to update comment"})
+		return
+	}
+	previous := comment.Mentions
+	comment, err = repository.UpdateComment(ctx, models.ProjectResource, project.ID, commentID, req.Body, mentions)
+	if !commentOK(c, err, "Failed to update comment") {
+		return
+	}
+
+	var added []int
+	for _, id := range mentions {
+		if !slices.Contains(previous, id) {
+			added = append(added, id)
+		}
+	}
+	notifyMentions(c, project, comment, added, userID)
+	render.JSON(c, http.StatusOK, gin.H{"data": comment})
+}
+
+// DeleteProjectComment deletes a comment, keeping its replies. Authors can
+// delete their own comments, and anyone who can write to the project can
+// delete any.
+func DeleteProjectComment(c *gin.Context) {
+	project, userID, role, ok := projectAccess(c, policy.PermissionRead)
+	if !ok {
+		return
+	}
+	commentID, ok := parseIDParam(c, "comment_id", "Invalid comment ID")
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+
+	comment, err := repository.GetComment(ctx, models.ProjectResource, project.ID, commentID)
+	if !commentOK(c, err, "Failed to delete comment") {
+		return
+	}
+	if (comment.AuthorID == nil || *comment.AuthorID != userID) && role == models.OrgRoleMember {
+		held, err := projectPermission(c, project, userID)
+		if err != nil {
+			c.Error(err)
+			render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to delete comment"})
+			return
+		}
+		if !policy.Covers(held, policy.PermissionWrite) {
+			render.JSON(c, http.StatusForbidden, gin.H{"error": "You can only delete your own comments"})
+			return
+		}
+	}
+
+	err = repository.DeleteComment(ctx, models.ProjectResource, project.ID, commentID)
+	if !commentOK(c, err, "Failed to delete comment") {
+		return
+	}
+
+	render.JSON(c, http.StatusOK, gin.H{"message": "Comment deleted successfully"})
+}
+
+// mentionedReaders returns the users a body mentions, other than its
+// author, who can read the project. Mentions past maxMentions are ignored.
+func mentionedReaders(c *gin.Context, project models.Project, body string, authorID int) ([]int, error) {
+	ctx := c.Request.Context()
+	readers := []int{}
+	var seen []int
+	for _, m := range mentionPattern.FindAllStringSubmatch(body, -1) {
+		id, _ := strconv.Atoi(m[1])
+		if id == authorID || slices.Contains(seen, id) {
+			continue
+		}
+		if len(seen) == maxMentions {
+			break
+		}
+		seen = append(seen, id)
+
+		role, err := repository.OrgRole(ctx, project.OrgID, id)
+		if errors.Is(err, repository.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if role == models.OrgRoleMember {
+			held, err := projectPermission(c, project, id)
+			if err != nil {
+				return nil, err
+			}
+			if held == "" {
+				continue
+			}
+		}
+		readers = append(readers, id)
+	}
+	return readers, nil
+}
+
+// notifyMentions queues an email to each mentioned user. The author and
+// the mentioned users are loaded in one query. Failures are logged; the
+// comment stands either way.
+func notifyMentions(c *gin.Context, project models.Project, comment models.Comment, userIDs []int, authorID int) {
+	if len(userIDs) == 0 {
+		return
+	}
+	ctx := c.Request.Context()
+	users, errs := loader.For(c).Users.LoadMany(ctx, append([]int{authorID}, userIDs...))
+	author, err := users[0], errs[0]
+	if err != nil {
+		log.Printf("Failed to load the author of comment %d for mention emails: %v", comment.ID, err)
+		return
+	}
+
+	for i, id := range userIDs {
+		user, err := users[i+1], errs[i+1]
+		if err == nil {
+			err = mailer.Enqueue(ctx, user.Email, templates.Mention, templates.Data{
+				Name:    user.Name,
+				Author:  author.Name,
+				Project: project.Name,
+				Excerpt: excerpt(comment.Body, 280),
+				Link:    commentURL(comment, project.OrgID),
+			})
+		}
+		if err != nil {
+			log.Printf("Failed to enqueue mention email for comment %d to user %d: %v", comment.ID, id, err)
+		}
+	}
+}
+
+// excerpt shortens s to at most n runes, marking the cut with an ellipsis.
+func excerpt(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-1]) + "…"
+}
+
+// commentOK writes the response for a failed comment lookup or change and
+// reports whether err was nil.
+func commentOK(c *gin.Context, err error, message string) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, repository.ErrNotFound):
+		render.JSON(c, http.StatusNotFound, gin.H{"error": "Comment not found"})
+	default:
+		c.Error(err)
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": message})
+	}
+	return false
+}
@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+
+	"pygorp/backend/internal/consent"
+	"pygorp/backend/internal/render"
+
+	"github.com/gin-gonic/gin"
+)
+
+type consentRequest struct {
+	Kind    string `json:"kind" binding:"required"`
+	Version int    `json:"version" binding:"required"`
+	// Granted defaults to true; false withdraws an optional consent.
+	Granted *bool `json:"granted"`
+}
+
+// ListPolicyDocuments returns the current version of each policy document.
+func ListPolicyDocuments(c *gin.Context) {
+	docs, err := consent.Current(c.Request.Context())
+	if err != nil {
+		c.Error(err)
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to fetch policy documents"})
+		return
+	}
+	render.JSON(c, http.StatusOK, gin.H{"data": docs})
+}
+
+// GetPolicyDocument returns a version of a policy document, current or not.
+func GetPolicyDocument(c *gin.Context) {
+	version, ok := parseIDParam(c, "version", "Invalid version")
+	if !ok {
+		return
+	}
+
+	doc, err := consent.Get(c.Request.Context(), c.Param("kind"), version)
+	if errors.Is(err, consent.ErrNotFound) {
+		render.JSON(c, http.StatusNotFound, gin.H{"error": "Policy document not found"})
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to fetch policy document"})
+		return
+	}
+	render.JSON(c, http.StatusOK, gin.H{"data": doc})
+}
+
+// ListMyConsents returns the caller's current consent to each policy, and
+// the current versions the caller must accept before using the API again.
+func ListMyConsents(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	consents, err := consent.ForUser(ctx, userID)
+	if err != nil {
+		c.Error(err)
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to fetch consents"})
+		return
+	}
+	pending, err := consent.Pending(ctx, userID)
+	if err != nil {
+		c.Error(err)
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to fetch consents"})
+		return
+	}
+	if pending == nil {
+		pending = []*consent.Document{}
+	}
+	render.JSON(c, http.StatusOK, gin.H{"data": consents, "pending": pending})
+}
+
+// GiveConsent records the caller's consent to the current version of a
+// policy, or the withdrawal of an optional one, with the caller's IP and
+// user agent.
+func GiveConsent(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	var req consentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !slices.Contains(consent.Kinds, req.Kind) {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": "Unknown policy kind"})
+		return
+	}
+	granted := req.Granted == nil || *req.Granted
+	if !granted && !consent.Optional(req.Kind) {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": "Consent to " + req.Kind + " cannot be withdrawn; delete the account instead"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	given, err := consent.Record(ctx, userID, req.Kind, req.Version, granted, consent.Source{IP: c.ClientIP(), UserAgent: c.Request.UserAgent()})
+	switch {
+	case errors.Is(err, consent.ErrNotFound):
+		render.JSON(c, http.StatusNotFound, gin.H{"error": "Policy document not found"})
+		return
+	case errors.Is(err, consent.ErrOutdated):
+		render.JSON(c, http.StatusConflict, gin.H{"error": "A newer version of the policy has been published; review and accept it instead"})
+		return
+	case err != nil:
+		c.Error(err)
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to record consent"})
+		return
+	}
+	render.JSON(c, http.StatusCreated, gin.H{"data": given})
+}
+
+// ListPolicyDocumentVersions lists every published version of the policy
+// documents, or of ?kind=, newest first.
+func ListPolicyDocumentVersions(c *gin.Context) {
+	kind := c.Query("kind")
+	if kind != "" && !slices.Contains(consent.Kinds, kind) {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": "Unknown policy kind"})
+		return
+	}
+
+	docs, err := consent.List(c.Request.Context(), kind)
+	if err != nil {
+		c.Error(err)
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to fetch policy documents"})
+		return
+	}
+	render.JSON(c, http.StatusOK, gin.H{"data": docs})
+}
+
+// PublishPolicyDocument publishes the next version of a policy document.
+// With requires_consent, users must accept it before using the API again.
+func PublishPolicyDocument(c *gin.Context) {
+	var doc consent.Document
+	if err := c.ShouldBindJSON(&doc); err != nil {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := doc.Check(); err != nil {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	published, err := consent.Publish(c.Request.Context(), &doc)
+	if errors.Is(err, consent.ErrConflict) {
+		render.JSON(c, http.StatusConflict, gin.H{"error": "Another version was published at the same time"})
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to publish policy document"})
+		return
+	}
+
+	recordChange(c, "policy_documents.published", gin.H{"kind": published.Kind, "version": published.Version, "requires_consent": published.RequiresConsent})
+	render.JSON(c, http.StatusCreated, gin.H{"data": published})
+}
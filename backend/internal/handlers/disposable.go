@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"pygorp/backend/internal/config"
+	"pygorp/backend/internal/disposable"
+	"pygorp/backend/internal/render"
+	"pygorp/backend/internal/repository"
+	"pygorp/backend/internal/sqlb"
+
+	"github.com/gin-gonic/gin"
+)
+
+// checkDisposable applies the disposable_emails setting to a signup. In
+// reject mode it responds 400 with code "disposable_email"; in flag mode it
+// reports whether the new user should be flagged. A failed lookup is logged
+// and lets the signup through.
+func checkDisposable(c *gin.Context, email string) (flag, ok bool) {
+	mode := config.Current().DisposableEmails
+	if mode == "off" {
+		return false, true
+	}
+	found, err := disposable.Check(c.Request.Context(), email)
+	if err != nil {
+		log.Printf("Failed to check disposable email domains: %v", err)
+		return false, true
+	}
+	if found && mode == "reject" {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": "Email addresses from disposable domains are not accepted", "code": "disposable_email"})
+		return false, false
+	}
+	return found, true
+}
+
+// flagDisposable flags a user who signed up with a disposable email.
+// Failing to flag does not fail the signup.
+func flagDisposable(c *gin.Context, userID int, email string) {
+	if err := repository.FlagUser(c.Request.Context(), userID, repository.FlagDisposableEmail, "signed up with "+email); err != nil {
+		log.Printf("Failed to flag user %d: %v", userID, err)
+	}
+}
+
+// ListUserFlags lists a page of users flagged for review
+// (?flag=disposable_email).
+func ListUserFlags(c *gin.Context) {
+	limit, offset, ok := parsePage(c)
+	if !ok {
+		return
+	}
+
+	flags, err := repository.ListUserFlags(c.Request.Context(), c.Query("flag"), sqlb.Page{Limit: limit, Offset: offset})
+	if err != nil {
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to fetch user flags"})
+		return
+	}
+
+	render.JSON(c, http.StatusOK, gin.H{"data": flags})
+}
+
+// ClearUserFlag removes a flag once the user has been reviewed.
+func ClearUserFlag(c *gin.Context) {
+	userID, ok := parseIDParam(c, "user_id", "Invalid user ID")
+	if !ok {
+		return
+	}
+
+	err := repository.ClearUserFlag(c.Request.Context(), userID, c.Param("flag"))
+	if errors.Is(err, repository.ErrNotFound) {
+		render.JSON(c, http.StatusNotFound, gin.H{"error": "User flag not found"})
+		return
+	}
+	if err != nil {
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to clear user flag"})
+		return
+	}
+
+	render.JSON(c, http.StatusOK, gin.H{"message": "User flag cleared successfully"})
+}
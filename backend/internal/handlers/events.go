@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"pygorp/backend/internal/config"
+	"pygorp/backend/internal/events"
+	"pygorp/backend/internal/render"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListEventSchemas returns the JSON Schema of every event type and version
+// the backend emits.
+func ListEventSchemas(c *gin.Context) {
+	render.JSON(c, http.StatusOK, gin.H{"data": events.Schemas()})
+}
+
+// GetEventSchema returns one schema document as-is, so it can be fed
+// directly to a JSON Schema validator.
+func GetEventSchema(c *gin.Context) {
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": "Invalid schema version"})
+		return
+	}
+
+	schema, ok := events.Lookup(c.Param("type"), version)
+	if !ok {
+		render.JSON(c, http.StatusNotFound, gin.H{"error": "Event schema not found"})
+		return
+	}
+
+	c.Header("Cache-Control", "public, max-age=3600")
+	c.Data(http.StatusOK, "application/schema+json", schema.Schema)
+}
+
+// ListEvents returns stored events after the after_seq cursor, oldest first.
+// Consumers pass the returned next_after_seq on their next call to catch up
+// after downtime.
+func ListEvents(c *gin.Context) {
+	var afterSeq int64
+	if raw := c.Query("after_seq"); raw != "" {
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || n < 0 {
+			render.JSON(c, http.StatusBadRequest, gin.H{"error": "after_seq must be a non-negative integer"})
+			return
+		}
+		afterSeq = n
+	}
+	maxLimit := config.Current().MaxRows
+	limit := min(100, maxLimit)
+	if raw := c.Query("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			render.JSON(c, http.StatusBadRequest, gin.H{"error": "limit must be a positive integer"})
+			return
+		}
+		if n > maxLimit {
+			limitTooLarge(c, maxLimit)
+			return
+		}
+		limit = n
+	}
+	var types []string
+	if raw := c.Query("type"); raw != "" {
+		types = strings.Split(raw, ",")
+	}
+
+	list, err := events.List(c.Request.Context(), afterSeq, types, limit)
+	if err != nil {
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to fetch events"})
+		return
+	}
+
+	next := afterSeq
+	if len(list) > 0 {
+		next = list[len(list)-1].Seq
+	}
+	render.JSON(c, http.StatusOK, gin.H{"data": list, "next_after_seq": next, "has_more": len(list) == limit})
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"net/http"
+
+	"pygorp/backend/internal/clock"
+	"pygorp/backend/internal/fixtures"
+	"pygorp/backend/internal/render"
+
+	"github.com/gin-gonic/gin"
+)
+
+type generateFixturesRequest struct {
+	Users  int `json:"users" binding:"min=0,max=10000"`
+	Groups int `json:"groups" binding:"min=0,max=1000"`
+	// Seed makes a run reproducible; a random one is used and returned
+	// when it is omitted.
+	Seed *int64 `json:"seed"`
+}
+
+// GenerateFixtures fills the database with fake users and groups for QA.
+// It is refused when ENV=production.
+func GenerateFixtures(c *gin.Context) {
+	if !fixtures.Enabled() {
+		render.JSON(c, http.StatusForbidden, gin.H{"error": "Fixture generation is disabled in production"})
+		return
+	}
+	var req generateFixturesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Users == 0 && req.Groups == 0 {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": "Set users, groups, or both"})
+		return
+	}
+
+	opts := fixtures.Options{Users: req.Users, Groups: req.Groups, Seed: clock.Now().UnixNano()}
+	if req.Seed != nil {
+		opts.Seed = *req.Seed
+	}
+	result, err := fixtures.Generate(c.Request.Context(), opts)
+	if err != nil {
+		c.Error(err)
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to generate fixtures"})
+		return
+	}
+	render.JSON(c, http.StatusCreated, gin.H{"data": result})
+}
@@ -0,0 +1,221 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"pygorp/backend/internal/models"
+	"pygorp/backend/internal/render"
+	"pygorp/backend/internal/repository"
+	"pygorp/backend/internal/sqlb"
+
+	"github.com/gin-gonic/gin"
+)
+
+func ListGroups(c *gin.Context) {
+	limit, offset, ok := parsePage(c)
+	if !ok {
+		return
+	}
+
+	groups, err := repository.ListGroups(c.Request.Context(), sqlb.Page{Limit: limit, Offset: offset})
+	if err != nil {
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to fetch groups"})
+		return
+	}
+
+	render.JSON(c, http.StatusOK, gin.H{"data": groups})
+}
+
+func GetGroup(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "Invalid group ID")
+	if !ok {
+		return
+	}
+
+	group, err := repository.GetGroup(c.Request.Context(), id)
+	if !groupOK(c, err, "Failed to fetch group") {
+		return
+	}
+
+	render.JSON(c, http.StatusOK, gin.H{"data": group})
+}
+
+func CreateGroup(c *gin.Context) {
+	var req models.CreateGroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !checkName(c, "name", &req.Name) || !checkBlocked(c, "name", req.Name) {
+		return
+	}
+
+	group, err := repository.CreateGroup(c.Request.Context(), req)
+	if !groupOK(c, err, "Failed to create group") {
+		return
+	}
+
+	render.JSON(c, http.StatusCreated, gin.H{"data": group})
+}
+
+func UpdateGroup(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "Invalid group ID")
+	if !ok {
+		return
+	}
+
+	var req models.UpdateGroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !checkName(c, "name", &req.Name) || !checkBlocked(c, "name", req.Name) {
+		return
+	}
+
+	group, err := repository.UpdateGroup(c.Request.Context(), id, req)
+	if !groupOK(c, err, "Failed to update group") {
+		return
+	}
+
+	render.JSON(c, http.StatusOK, gin.H{"data": group})
+}
+
+func DeleteGroup(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "Invalid group ID")
+	if !ok {
+		return
+	}
+
+	err := repository.DeleteGroup(c.Request.Context(), id)
+	if !groupOK(c, err, "Failed to delete group") {
+		return
+	}
+
+	render.JSON(c, http.StatusOK, gin.H{"message": "Group deleted successfully"})
+}
+
+// ListGroupMembers returns a page of members, each with the full user and
+// when they joined.
+func ListGroupMembers(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "Invalid group ID")
+	if !ok {
+		return
+	}
+	limit, offset, ok := parsePage(c)
+	if !ok {
+		return
+	}
+
+	_, err := repository.GetGroup(c.Request.Context(), id)
+	if !groupOK(c, err, "Failed to fetch group members") {
+		return
+	}
+	members, err := repository.ListGroupMembers(c.Request.Context(), id, sqlb.Page{Limit: limit, Offset: offset})
+	if err != nil {
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to fetch group members"})
+		return
+	}
+
+	render.JSON(c, http.StatusOK, gin.H{"data": members})
+}
+
+// AddGroupMembers adds users to a group. The response lists the IDs that
+// were newly added; existing members and unknown users are skipped.
+func AddGroupMembers(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "Invalid group ID")
+	if !ok {
+		return
+	}
+
+	var req models.AddGroupMembersRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	_, err := repository.GetGroup(c.Request.Context(), id)
+	if !groupOK(c, err, "Failed to add group members") {
+		return
+	}
+	added, err := repository.AddGroupMembers(c.Request.Context(), id, dedupeIDs(req.UserIDs))
+	if err != nil {
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to add group members"})
+		return
+	}
+
+	render.JSON(c, http.StatusOK, gin.H{"data": gin.H{"added": added}})
+}
+
+func RemoveGroupMember(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "Invalid group ID")
+	if !ok {
+		return
+	}
+	userID, ok := parseIDParam(c, "user_id", "Invalid user ID")
+	if !ok {
+		return
+	}
+
+	err := repository.RemoveGroupMember(c.Request.Context(), id, userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		render.JSON(c, http.StatusNotFound, gin.H{"error": "Membership not found"})
+		return
+	}
+	if err != nil {
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to remove group member"})
+		return
+	}
+
+	render.JSON(c, http.StatusOK, gin.H{"message": "Group member removed successfully"})
+}
+
+// GetUserGroups lists the groups a user belongs to.
+func GetUserGroups(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "Invalid user ID")
+	if !ok {
+		return
+	}
+	limit, offset, ok := parsePage(c)
+	if !ok {
+		return
+	}
+
+	groups, err := repository.UserGroups(c.Request.Context(), id, sqlb.Page{Limit: limit, Offset: offset})
+	if err != nil {
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to fetch groups"})
+		return
+	}
+
+	render.JSON(c, http.StatusOK, gin.H{"data": groups})
+}
+
+// parseIDParam reads a numeric path parameter, writing a 400 response when
+// it is invalid.
+func parseIDParam(c *gin.Context, name, message string) (int, bool) {
+	id, err := strconv.Atoi(c.Param(name))
+	if err != nil || id < 1 {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": message})
+		return 0, false
+	}
+	return id, true
+}
+
+// groupOK writes the response for a failed group lookup or change and
+// reports whether err was nil.
+func groupOK(c *gin.Context, err error, message string) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, repository.ErrNotFound):
+		render.JSON(c, http.StatusNotFound, gin.H{"error": "Group not found"})
+	case errors.Is(err, repository.ErrGroupExists):
+		render.JSON(c, http.StatusConflict, gin.H{"error": "Group name already exists"})
+	default:
+		c.Error(err)
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": message})
+	}
+	return false
+}
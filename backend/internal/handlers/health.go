@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"pygorp/backend/internal/database"
+	"pygorp/backend/internal/middleware"
+	"pygorp/backend/internal/region"
+	"pygorp/backend/internal/render"
+	"pygorp/backend/internal/warmup"
+
+	"github.com/gin-gonic/gin"
+)
+
+func Health(c *gin.Context) {
+	render.JSON(c, http.StatusOK, gin.H{
+		"status":  "healthy",
+		"service": "pygorp-backend",
+	})
+}
+
+// Ready reports whether the server can serve traffic. It fails while the
+// server is warming up or the database is unreachable, and reports read-only
+// mode so load balancers and operators can see it.
+func Ready(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 2*time.Second)
+	defer cancel()
+
+	status, code, dbStatus := "ready", http.StatusOK, "ok"
+	if err := database.DB.PingContext(ctx); err != nil {
+		status, code, dbStatus = "not_ready", http.StatusServiceUnavailable, err.Error()
+	} else if !warmup.Done() {
+		status, code = "warming_up", http.StatusServiceUnavailable
+	}
+
+	render.JSON(c, code, gin.H{
+		"status":    status,
+		"database":  dbStatus,
+		"read_only": middleware.ReadOnly().Enabled,
+		"region":    region.Name(),
+	})
+}
+
+func Ping(c *gin.Context) {
+	render.JSON(c, http.StatusOK, gin.H{"message": "pong"})
+}
@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"pygorp/backend/internal/models"
+	"pygorp/backend/internal/pii"
+	"pygorp/backend/internal/render"
+	"pygorp/backend/internal/repository"
+	"pygorp/backend/internal/sqlb"
+
+	"github.com/gin-gonic/gin"
+)
+
+// historyFields are the user fields whose changes are kept in its history,
+// those of user_history_state in the database.
+var historyFields = []string{"email", "name", "attributes", "avatar", "external_id", "active", "org_id", "org_role"}
+
+// ListUserHistory lists a page of a user's versions, newest first, with the
+// fields each changed. ?field= (repeated or comma-separated) keeps only the
+// versions changing one of the named fields.
+func ListUserHistory(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "Invalid user ID")
+	if !ok {
+		return
+	}
+	var fields []string
+	for _, value := range c.QueryArray("field") {
+		for _, field := range strings.Split(value, ",") {
+			if field = strings.TrimSpace(field); field == "" {
+				continue
+			}
+			if !slices.Contains(historyFields, field) {
+				render.JSON(c, http.StatusBadRequest, gin.H{"error": "field must be one of " + strings.Join(historyFields, ", ")})
+				return
+			}
+			fields = append(fields, field)
+		}
+	}
+	limit, offset, ok := parsePage(c)
+	if !ok {
+		return
+	}
+	if !userExists(c, id) {
+		return
+	}
+
+	versions, err := repository.ListUserHistory(c.Request.Context(), id, fields, sqlb.Page{Limit: limit, Offset: offset})
+	if err != nil {
+		c.Error(err)
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to fetch user history"})
+		return
+	}
+	for i := range versions {
+		redactChanges(c, versions[i].Changes)
+	}
+	render.JSON(c, http.StatusOK, gin.H{"data": versions})
+}
+
+// GetUserVersion returns one version of a user, with its state as of that
+// version.
+func GetUserVersion(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "Invalid user ID")
+	if !ok {
+		return
+	}
+	version, ok := parseIDParam(c, "version", "Invalid version")
+	if !ok {
+		return
+	}
+	if !userExists(c, id) {
+		return
+	}
+
+	v, err := repository.GetUserVersion(c.Request.Context(), id, version)
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		render.JSON(c, http.StatusNotFound, gin.H{"error": "Version not found"})
+		return
+	case err != nil:
+		c.Error(err)
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to fetch user version"})
+		return
+	}
+	redactChanges(c, v.Changes)
+	render.JSON(c, http.StatusOK, gin.H{"data": v})
+}
+
+// RestoreUser puts a user's email, name, attributes, and avatar back as they
+// were in the version named by ?version=. It honors If-Match and
+// If-Unmodified-Since like updates.
+func RestoreUser(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "Invalid user ID")
+	if !ok {
+		return
+	}
+	version, err := strconv.Atoi(c.Query("version"))
+	if err != nil || version < 1 {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": "version must be a positive integer"})
+		return
+	}
+
+	user, err := repository.RestoreUserVersion(c.Request.Context(), id, version, precondition(c))
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		render.JSON(c, http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	case errors.Is(err, repository.ErrVersionNotFound):
+		render.JSON(c, http.StatusNotFound, gin.H{"error": "Version not found"})
+		return
+	case errors.Is(err, repository.ErrPreconditionFailed):
+		render.JSON(c, http.StatusPreconditionFailed, gin.H{"error": "User has changed since it was read"})
+		return
+	case errors.Is(err, repository.ErrUserExists):
+		render.JSON(c, http.StatusConflict, gin.H{"error": "The version's email now belongs to another user"})
+		return
+	case err != nil:
+		c.Error(err)
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to restore user"})
+		return
+	}
+
+	setVersion(c, user)
+	render.JSON(c, http.StatusOK, gin.H{"data": user})
+}
+
+// userExists responds 404 unless the user exists and is visible to the
+// caller.
+func userExists(c *gin.Context, id int) bool {
+	_, err := repository.GetUser(c.Request.Context(), id)
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		render.JSON(c, http.StatusNotFound, gin.H{"error": "User not found"})
+		return false
+	case err != nil:
+		c.Error(err)
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return false
+	}
+	return true
+}
+
+// redactChanges masks email changes for callers that may not see personal
+// data. The PII middleware masks email strings, but here the email field
+// holds a change rather than an address.
+func redactChanges(c *gin.Context, changes map[string]models.FieldChange) {
+	change, ok := changes["email"]
+	if !ok || pii.Allowed(c) {
+		return
+	}
+	change.From, change.To = maskedEmail(change.From), maskedEmail(change.To)
+	changes["email"] = change
+}
+
+func maskedEmail(value json.RawMessage) json.RawMessage {
+	var email string
+	if json.Unmarshal(value, &email) != nil || email == "" {
+		return value
+	}
+	masked, _ := json.Marshal(pii.MaskEmail(email))
+	return masked
+}
@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"pygorp/backend/internal/clock"
+	"pygorp/backend/internal/config"
+	"pygorp/backend/internal/jobs"
+	"pygorp/backend/internal/operations"
+	"pygorp/backend/internal/render"
+
+	"github.com/gin-gonic/gin"
+)
+
+func ListJobs(c *gin.Context) {
+	limit, offset, ok := parsePage(c)
+	if !ok {
+		return
+	}
+
+	list, err := jobs.List(c.Request.Context(), jobs.Filter{
+		Queue:  c.Query("queue"),
+		Status: c.Query("status"),
+		Type:   c.Query("type"),
+		Limit:  limit,
+		Offset: offset,
+	})
+	if err != nil {
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to fetch jobs"})
+		return
+	}
+
+	render.JSON(c, http.StatusOK, gin.H{"data": list})
+}
+
+func GetJob(c *gin.Context) {
+	id, ok := parseJobID(c)
+	if !ok {
+		return
+	}
+
+	job, err := jobs.Get(c.Request.Context(), id)
+	if err != nil {
+		render.JSON(c, http.StatusNotFound, gin.H{"error": "Job not found"})
+		return
+	}
+
+	render.JSON(c, http.StatusOK, gin.H{"data": job})
+}
+
+func RetryJob(c *gin.Context) {
+	id, ok := parseJobID(c)
+	if !ok {
+		return
+	}
+
+	job, err := jobs.Retry(c.Request.Context(), id)
+	if !respondJobError(c, err) {
+		return
+	}
+	if err := operations.JobRetried(c.Request.Context(), id); err != nil {
+		log.Printf("Failed to reset operation for job %d: %v", id, err)
+	}
+
+	render.JSON(c, http.StatusOK, gin.H{"data": job})
+}
+
+func CancelJob(c *gin.Context) {
+	id, ok := parseJobID(c)
+	if !ok {
+		return
+	}
+
+	job, err := jobs.Cancel(c.Request.Context(), id)
+	if !respondJobError(c, err) {
+		return
+	}
+	if err := operations.JobCancelled(c.Request.Context(), id); err != nil {
+		log.Printf("Failed to cancel operation for job %d: %v", id, err)
+	}
+
+	render.JSON(c, http.StatusOK, gin.H{"data": job})
+}
+
+func GetJobStats(c *gin.Context) {
+	stats, err := jobs.Stats(c.Request.Context())
+	if err != nil {
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to fetch job stats"})
+		return
+	}
+
+	render.JSON(c, http.StatusOK, gin.H{"data": stats})
+}
+
+func RetryDeadJobs(c *gin.Context) {
+	count, err := jobs.RetryDead(c.Request.Context(), c.Query("queue"))
+	if err != nil {
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to retry dead jobs"})
+		return
+	}
+
+	render.JSON(c, http.StatusOK, gin.H{"data": gin.H{"requeued": count}})
+}
+
+func PurgeDeadJobs(c *gin.Context) {
+	olderThan := 7 * 24 * time.Hour
+	if raw := c.Query("older_than"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d < 0 {
+			render.JSON(c, http.StatusBadRequest, gin.H{"error": "Invalid older_than duration"})
+			return
+		}
+		olderThan = d
+	}
+
+	count, err := jobs.PurgeDead(c.Request.Context(), c.Query("queue"), clock.Now().Add(-olderThan))
+	if err != nil {
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to purge dead jobs"})
+		return
+	}
+
+	render.JSON(c, http.StatusOK, gin.H{"data": gin.H{"purged": count}})
+}
+
+func parseJobID(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": "Invalid job ID"})
+		return 0, false
+	}
+	return id, true
+}
+
+// respondJobError writes the response for a failed job transition and
+// reports whether the caller should continue.
+func respondJobError(c *gin.Context, err error) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, jobs.ErrNotFound):
+		render.JSON(c, http.StatusNotFound, gin.H{"error": "Job not found"})
+	case errors.Is(err, jobs.ErrInvalidState):
+		render.JSON(c, http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to update job"})
+	}
+	return false
+}
+
+// parsePage reads limit and offset query parameters, writing a 400 response
+// when they are invalid. The limit defaults to 50 and may not exceed the
+// configured maximum page size.
+func parsePage(c *gin.Context) (limit, offset int, ok bool) {
+	maxLimit := config.Current().MaxPageSize
+	limit, offset = min(50, maxLimit), 0
+	if raw := c.Query("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			render.JSON(c, http.StatusBadRequest, gin.H{"error": "limit must be a positive integer"})
+			return 0, 0, false
+		}
+		if n > maxLimit {
+			limitTooLarge(c, maxLimit)
+			return 0, 0, false
+		}
+		limit = n
+	}
+	if raw := c.Query("offset"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			render.JSON(c, http.StatusBadRequest, gin.H{"error": "offset must not be negative"})
+			return 0, 0, false
+		}
+		offset = n
+	}
+	return limit, offset, true
+}
+
+// limitTooLarge answers a limit above the maximum page size.
+func limitTooLarge(c *gin.Context, maxLimit int) {
+	render.JSON(c, http.StatusBadRequest, gin.H{
+		"error":     fmt.Sprintf("limit must be at most %d; use offset to fetch further pages", maxLimit),
+		"code":      "limit_too_large",
+		"max_limit": maxLimit,
+	})
+}
+
+// tooManyRows answers a request whose result exceeds the configured cap on
+// rows per request.
+func tooManyRows(c *gin.Context, maxRows int) {
+	render.JSON(c, http.StatusBadRequest, gin.H{
+		"error": fmt.Sprintf("The result has more than %d rows, the most one request may return; "+
+			"pass limit (at most %d) and offset to page through it", maxRows, config.Current().MaxPageSize),
+		"code":     "too_many_rows",
+		"max_rows": maxRows,
+	})
+}
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"pygorp/backend/internal/models"
+	"pygorp/backend/internal/render"
+	"pygorp/backend/internal/repository"
+	"pygorp/backend/internal/sqlb"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListLegalHolds lists a page of the users under legal hold, most recently
+// held first.
+func ListLegalHolds(c *gin.Context) {
+	limit, offset, ok := parsePage(c)
+	if !ok {
+		return
+	}
+
+	holds, err := repository.ListLegalHolds(c.Request.Context(), sqlb.Page{Limit: limit, Offset: offset})
+	if err != nil {
+		c.Error(err)
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to fetch legal holds"})
+		return
+	}
+	render.JSON(c, http.StatusOK, gin.H{"data": holds})
+}
+
+// SetLegalHold places a user under legal hold, or changes the reason of its
+// hold. While held, the user cannot be deleted and is skipped by purges and
+// retention rules.
+func SetLegalHold(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "Invalid user ID")
+	if !ok {
+		return
+	}
+	var req models.LegalHoldRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	hold, existed, err := repository.SetLegalHold(c.Request.Context(), id, req.Reason)
+	if errors.Is(err, repository.ErrNotFound) {
+		render.JSON(c, http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to set legal hold"})
+		return
+	}
+
+	recordChange(c, "users.legal_hold.set", gin.H{"user_id": id, "reason": hold.Reason, "updated": existed})
+	status := http.StatusCreated
+	if existed {
+		status = http.StatusOK
+	}
+	render.JSON(c, status, gin.H{"data": hold})
+}
+
+// ClearLegalHold lifts a user's legal hold.
+func ClearLegalHold(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "Invalid user ID")
+	if !ok {
+		return
+	}
+
+	hold, err := repository.ClearLegalHold(c.Request.Context(), id)
+	if errors.Is(err, repository.ErrNotFound) {
+		render.JSON(c, http.StatusNotFound, gin.H{"error": "User is not under legal hold"})
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to clear legal hold"})
+		return
+	}
+
+	recordChange(c, "users.legal_hold.cleared", gin.H{"user_id": id, "reason": hold.Reason, "held_since": hold.CreatedAt})
+	render.JSON(c, http.StatusOK, gin.H{"message": "Legal hold cleared"})
+}
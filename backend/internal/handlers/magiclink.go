@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"pygorp/backend/internal/auth"
+	"pygorp/backend/internal/mailer"
+	"pygorp/backend/internal/render"
+	"pygorp/backend/internal/repository"
+	"pygorp/backend/internal/templates"
+
+	"github.com/gin-gonic/gin"
+)
+
+type magicLinkRequest struct {
+	Email string `json:"email" binding:"required,email" sanitize:"trim,control"`
+}
+
+type consumeMagicLinkRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// RequestMagicLink emails a single-use sign-in link. The response is the
+// same whether or not an account has the email.
+func RequestMagicLink(c *gin.Context) {
+	var req magicLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !checkEmail(c, "email", &req.Email) {
+		return
+	}
+	ctx := c.Request.Context()
+
+	var userID *int
+	user, err := repository.GetUserByEmail(ctx, req.Email)
+	switch {
+	case err == nil:
+		userID = &user.ID
+	case !errors.Is(err, repository.ErrNotFound):
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to send sign-in link"})
+		return
+	}
+
+	token, err := auth.NewMagicLink(ctx, req.Email, userID, authClient(c))
+	if !authOK(c, err) {
+		return
+	}
+	if userID != nil {
+		err := mailer.Enqueue(ctx, user.Email, templates.MagicLink, templates.Data{
+			Name:      user.Name,
+			Link:      auth.MagicLinkURL(token),
+			ExpiresIn: humanDuration(auth.MagicLinkTTL()),
+		})
+		if err != nil {
+			log.Printf("Failed to enqueue magic link email for user %d: %v", user.ID, err)
+			render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to send sign-in link"})
+			return
+		}
+	}
+
+	render.JSON(c, http.StatusAccepted, gin.H{"message": "If an account exists for this email, a sign-in link is on its way"})
+}
+
+// ConsumeMagicLink exchanges a magic link token for session tokens.
+func ConsumeMagicLink(c *gin.Context) {
+	var req consumeMagicLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tokens, err := auth.ConsumeMagicLink(c.Request.Context(), req.Token, authClient(c))
+	if !authOK(c, err) {
+		return
+	}
+
+	render.JSON(c, http.StatusOK, gin.H{"data": tokens})
+}
+
+// humanDuration formats a link lifetime for emails, e.g. "15 minutes".
+func humanDuration(d time.Duration) string {
+	n, unit := int(d.Minutes()), "minute"
+	switch {
+	case d >= 24*time.Hour && d%(24*time.Hour) == 0:
+		n, unit = int(d.Hours())/24, "day"
+	case d >= time.Hour && d%time.Hour == 0:
+		n, unit = int(d.Hours()), "hour"
+	}
+	if n != 1 {
+		unit += "s"
+	}
+	return strconv.Itoa(n) + " " + unit
+}
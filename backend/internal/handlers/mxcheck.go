@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"pygorp/backend/internal/config"
+	"pygorp/backend/internal/mxcheck"
+	"pygorp/backend/internal/render"
+
+	"github.com/gin-gonic/gin"
+)
+
+// checkMX applies the email_mx_check setting to a new user's email. In
+// enforce mode a domain without MX records is rejected with 400 and code
+// "undeliverable_email"; in soft mode it is only logged. Lookups that fail
+// or time out never block the request.
+func checkMX(c *gin.Context, email string) bool {
+	mode := config.Current().EmailMXCheck
+	if mode == "off" {
+		return true
+	}
+	err := mxcheck.Check(c.Request.Context(), email)
+	switch {
+	case err == nil:
+		return true
+	case !errors.Is(err, mxcheck.ErrNoMX):
+		log.Printf("MX lookup for %s failed: %v", mxcheck.Domain(email), err)
+		return true
+	case mode == "soft":
+		log.Printf("Accepting a new user at %s, which has no MX records", mxcheck.Domain(email))
+		return true
+	}
+	render.JSON(c, http.StatusBadRequest, gin.H{"error": "Email domain does not accept email", "code": "undeliverable_email"})
+	return false
+}
@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"pygorp/backend/internal/models"
+	"pygorp/backend/internal/operations"
+	"pygorp/backend/internal/pii"
+	"pygorp/backend/internal/render"
+	"pygorp/backend/internal/repository"
+	"pygorp/backend/internal/scanner"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxImportBytes caps the size of an uploaded import file.
+const maxImportBytes = 10 << 20
+
+func GetOperation(c *gin.Context) {
+	getOperation(c, false)
+}
+
+// AdminGetOperation returns any operation, whoever started it.
+func AdminGetOperation(c *gin.Context) {
+	getOperation(c, true)
+}
+
+func GetOperationResult(c *gin.Context) {
+	getOperationResult(c, false)
+}
+
+// AdminGetOperationResult downloads the result of any operation.
+func AdminGetOperationResult(c *gin.Context) {
+	getOperationResult(c, true)
+}
+
+func getOperation(c *gin.Context, anyCreator bool) {
+	op, ok := findOperation(c, anyCreator)
+	if !ok {
+		return
+	}
+	render.JSON(c, http.StatusOK, gin.H{"data": op})
+}
+
+// getOperationResult serves the file an operation produced. Exports hold
+// every user's personal data in plaintext, so fetching one also requires
+// the PII scope.
+func getOperationResult(c *gin.Context, anyCreator bool) {
+	op, ok := findOperation(c, anyCreator)
+	if !ok {
+		return
+	}
+	if op.Type == operations.TypeUserExport && !anyCreator && !pii.Allowed(c) {
+		render.JSON(c, http.StatusForbidden, gin.H{"error": "Downloading an export requires the " + pii.Scope + " scope"})
+		return
+	}
+
+	file, contentType, err := operations.ResultFile(c.Request.Context(), op.ID)
+	if errors.Is(err, operations.ErrNotFound) {
+		render.JSON(c, http.StatusNotFound, gin.H{"error": "Operation result not found"})
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to fetch operation result"})
+		return
+	}
+
+	c.Data(http.StatusOK, contentType, file)
+}
+
+// findOperation loads the operation named by the id parameter and writes a
+// 404 unless the caller started it, so operation IDs cannot be probed.
+// Anonymous callers own nothing, and operations with no recorded owner are
+// only visible with anyCreator, where the owner is not checked.
+func findOperation(c *gin.Context, anyCreator bool) (*operations.Operation, bool) {
+	op, err := operations.Get(c.Request.Context(), c.Param("id"))
+	if err == nil && !anyCreator {
+		if owner := operationOwner(c); owner == "" || op.CreatedBy == "" || op.CreatedBy != owner {
+			err = operations.ErrNotFound
+		}
+	}
+	if errors.Is(err, operations.ErrNotFound) {
+		render.JSON(c, http.StatusNotFound, gin.H{"error": "Operation not found"})
+		return nil, false
+	}
+	if err != nil {
+		c.Error(err)
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to fetch operation"})
+		return nil, false
+	}
+	return op, true
+}
+
+// operationOwner names the principal making the request, as recorded on the
+// operations it starts: "service_account:<name>" for an API key,
+// "user:<id>" for a user whether signed in, using a personal access token or
+// delegated by another service, "service:<name>" for a service acting on
+// its own, and empty for anonymous callers.
+func operationOwner(c *gin.Context) string {
+	if name := c.GetString("auth_service_account"); name != "" {
+		return "service_account:" + name
+	}
+	if subject := c.GetString("auth_subject"); subject != "" {
+		return "user:" + subject
+	}
+	if service := c.GetString("service"); service != "" {
+		return "service:" + service
+	}
+	return ""
+}
+
+// ExportUsers starts a CSV export. With {"encrypt": true} the CSV comes as
+// a password-protected ZIP and the password is emailed separately.
+func ExportUsers(c *gin.Context) {
+	var req models.ExportUsersRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			render.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if !checkEmail(c, "password_to", &req.PasswordTo) {
+		return
+	}
+
+	params := operations.ExportParams{Encrypt: req.Encrypt, PasswordTo: req.PasswordTo}
+	if req.Encrypt && params.PasswordTo == "" {
+		id, err := strconv.Atoi(c.GetString("auth_subject"))
+		if err != nil || c.GetString("auth_session") == "" {
+			render.JSON(c, http.StatusBadRequest, gin.H{"error": "password_to is required for encrypted exports without a signed-in user"})
+			return
+		}
+		user, err := repository.GetUser(c.Request.Context(), id)
+		if err != nil {
+			c.Error(err)
+			render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to look up the password recipient"})
+			return
+		}
+		params.PasswordTo, params.Name = user.Email, user.Name
+	}
+	startOperation(c, operations.TypeUserExport, params)
+}
+
+func ImportUsers(c *gin.Context) {
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": "file is required"})
+		return
+	}
+	if fileHeader.Size > maxImportBytes {
+		render.JSON(c, http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("Import file must be at most %d bytes", maxImportBytes)})
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": "Failed to read file"})
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxImportBytes))
+	if err != nil {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": "Failed to read file"})
+		return
+	}
+
+	if !scanUpload(c, data) {
+		return
+	}
+
+	startOperation(c, operations.TypeUserImport, operations.ImportParams{CSV: string(data)})
+}
+
+// startOperation creates an operation and responds 202 with a Location
+// pointing at the operation resource.
+func startOperation(c *gin.Context, opType string, params interface{}) {
+	op, err := operations.Start(c.Request.Context(), opType, operationOwner(c), params)
+	if err != nil {
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to start operation"})
+		return
+	}
+
+	c.Header("Location", "/api/v1/operations/"+op.ID)
+	render.JSON(c, http.StatusAccepted, gin.H{"data": op})
+}
+
+func startBulkOperation(c *gin.Context, opType string, ids []int, patch models.UpdateUserRequest) {
+	startOperation(c, opType, operations.BulkParams{IDs: ids, Patch: patch})
+}
+
+// scanUpload runs the configured scanner over an upload and writes the
+// error response when it is rejected.
+func scanUpload(c *gin.Context, data []byte) bool {
+	s, err := scanner.Default()
+	if err == nil {
+		err = s.Scan(c.Request.Context(), bytes.NewReader(data))
+	}
+	switch {
+	case err == nil:
+		return true
+	case scanner.IsInfected(err):
+		render.JSON(c, http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	default:
+		log.Printf("Upload scan failed: %v", err)
+		render.JSON(c, http.StatusServiceUnavailable, gin.H{"error": "Failed to scan file"})
+	}
+	return false
+}
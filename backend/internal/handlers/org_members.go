@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"pygorp/backend/internal/audit"
+	"pygorp/backend/internal/audit/exporters"
+	"pygorp/backend/internal/models"
+	"pygorp/backend/internal/render"
+	"pygorp/backend/internal/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+type adminOrgRoleRequest struct {
+	Role string `json:"role" binding:"required,oneof=owner admin member"`
+}
+
+// ListOrgMembers lists the users in the org, or with ?team_id= in one of
+// its teams, to any of its members.
+func ListOrgMembers(c *gin.Context) {
+	orgID, _, _, ok := orgAccess(c)
+	if !ok {
+		return
+	}
+	teamID, ok := teamIDQuery(c)
+	if !ok {
+		return
+	}
+
+	members, err := repository.ListOrgMembers(c.Request.Context(), orgID, teamID)
+	if err != nil {
+		c.Error(err)
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to fetch members"})
+		return
+	}
+
+	render.JSON(c, http.StatusOK, gin.H{"data": members})
+}
+
+// SetOrgMemberRole makes a member an admin or a plain member. Only owners
+// change roles.
+func SetOrgMemberRole(c *gin.Context) {
+	orgID, _, _, ok := orgAccess(c, models.OrgRoleOwner)
+	if !ok {
+		return
+	}
+	memberID, ok := parseIDParam(c, "user_id", "Invalid user ID")
+	if !ok {
+		return
+	}
+	var req models.SetOrgRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	setOrgRole(c, orgID, memberID, req.Role)
+}
+
+// AdminSetOrgMemberRole sets any role, owner included, so operators can
+// give an org without owners its first one.
+func AdminSetOrgMemberRole(c *gin.Context) {
+	orgID, ok := parseIDParam(c, "id", "Invalid org ID")
+	if !ok {
+		return
+	}
+	memberID, ok := parseIDParam(c, "user_id", "Invalid user ID")
+	if !ok {
+		return
+	}
+	var req adminOrgRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	setOrgRole(c, orgID, memberID, req.Role)
+}
+
+func setOrgRole(c *gin.Context, orgID, memberID int, role string) {
+	previous, err := repository.SetOrgRole(c.Request.Context(), orgID, memberID, role)
+	if !orgMemberOK(c, err, "Failed to change role") {
+		return
+	}
+
+	member := gin.H{"org_id": orgID, "user_id": memberID, "role": role}
+	render.JSON(c, http.StatusOK, gin.H{"data": member})
+	if previous != role {
+		recordChange(c, "org.member.role_changed", gin.H{"org_id": orgID, "user_id": memberID, "from": previous, "to": role})
+	}
+}
+
+// RemoveOrgMember takes a user out of the org. Anyone can leave; admins
+// can also remove members, and owners anyone. The last owner has to
+// transfer ownership first.
+func RemoveOrgMember(c *gin.Context) {
+	orgID, userID, role, ok := orgAccess(c)
+	if !ok {
+		return
+	}
+	memberID, ok := parseIDParam(c, "user_id", "Invalid user ID")
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+
+	if memberID != userID && role != models.OrgRoleOwner {
+		memberRole, err := repository.OrgRole(ctx, orgID, memberID)
+		if !orgMemberOK(c, err, "Failed to remove member") {
+			return
+		}
+		if role != models.OrgRoleAdmin || memberRole != models.OrgRoleMember {
+			render.JSON(c, http.StatusForbidden, gin.H{"error": "Your org role does not allow this"})
+			return
+		}
+	}
+
+	previous, err := repository.RemoveOrgMember(ctx, orgID, memberID)
+	if !orgMemberOK(c, err, "Failed to remove member") {
+		return
+	}
+
+	render.JSON(c, http.StatusOK, gin.H{"message": "Member removed successfully"})
+	recordChange(c, "org.member.removed", gin.H{"org_id": orgID, "user_id": memberID, "role": previous})
+}
+
+// TransferOrgOwnership hands the org to another member, who becomes an
+// owner while the caller becomes an admin. The body must repeat the org's
+// name.
+func TransferOrgOwnership(c *gin.Context) {
+	orgID, userID, _, ok := orgAccess(c, models.OrgRoleOwner)
+	if !ok {
+		return
+	}
+	var req models.TransferOrgRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.UserID == userID {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": "You already own this org"})
+		return
+	}
+	ctx := c.Request.Context()
+
+	name, err := repository.OrgName(ctx, orgID)
+	if !orgMemberOK(c, err, "Failed to transfer ownership") {
+		return
+	}
+	if req.Confirm != name {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": "confirm must be the org's name"})
+		return
+	}
+
+	err = repository.TransferOrgOwnership(ctx, orgID, userID, req.UserID)
+	if errors.Is(err, repository.ErrNotOwner) {
+		render.JSON(c, http.StatusForbidden, gin.H{"error": "Your org role does not allow this"})
+		return
+	}
+	if !orgMemberOK(c, err, "Failed to transfer ownership") {
+		return
+	}
+
+	render.JSON(c, http.StatusOK, gin.H{"data": gin.H{"org_id": orgID, "owner_id": req.UserID}})
+	recordChange(c, "org.ownership.transferred", gin.H{"org_id": orgID, "from": userID, "to": req.UserID})
+}
+
+// orgMemberOK writes the response for a failed membership lookup or change
+// and reports whether err was nil.
+func orgMemberOK(c *gin.Context, err error, message string) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, repository.ErrNotFound):
+		render.JSON(c, http.StatusNotFound, gin.H{"error": "Member not found"})
+	case errors.Is(err, repository.ErrLastOwner):
+		render.JSON(c, http.StatusConflict, gin.H{"error": "An org must keep at least one owner; transfer ownership first"})
+	default:
+		c.Error(err)
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": message})
+	}
+	return false
+}
+
+// recordChange audits a change, such as to an org membership, with its
+// details, alongside the request-level record the audit middleware writes.
+func recordChange(c *gin.Context, action string, details map[string]interface{}) {
+	audit.Record(audit.Event{
+		Action:  action,
+		Outcome: exporters.OutcomeSuccess,
+		Actor: exporters.Actor{
+			UserID: c.GetString("auth_subject"),
+		},
+		Method:    c.Request.Method,
+		Path:      c.Request.URL.Path,
+		Status:    c.Writer.Status(),
+		RequestID: c.GetString("request_id"),
+		ClientIP:  c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+		Details:   details,
+	})
+}
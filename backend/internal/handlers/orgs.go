@@ -0,0 +1,274 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+	"time"
+
+	"pygorp/backend/internal/auth"
+	"pygorp/backend/internal/clock"
+	"pygorp/backend/internal/mailer"
+	"pygorp/backend/internal/models"
+	"pygorp/backend/internal/render"
+	"pygorp/backend/internal/repository"
+	"pygorp/backend/internal/templates"
+
+	"github.com/gin-gonic/gin"
+)
+
+// invitationLink is the purpose invitation tokens are signed for.
+const invitationLink = "org_invitation"
+
+// orgInvitationTTL is how long an invitation can be accepted after it was
+// last sent, read from ORG_INVITATION_TTL and defaulting to 7 days.
+func orgInvitationTTL() time.Duration {
+	if d, err := time.ParseDuration(os.Getenv("ORG_INVITATION_TTL")); err == nil && d > 0 {
+		return d
+	}
+	return 7 * 24 * time.Hour
+}
+
+// orgInvitationURL builds the link emailed to the invitee. ORG_INVITATION_URL
+// is the frontend page that posts the token to the accept endpoint.
+func orgInvitationURL(token string) string {
+	base := os.Getenv("ORG_INVITATION_URL")
+	if base == "" {
+		base = "http://localhost:3000/invitations/accept"
+	}
+	return auth.LinkURL(base, token)
+}
+
+// CreateOrgInvitation emails an invitation to join the org. Only its
+// owners and admins can invite.
+func CreateOrgInvitation(c *gin.Context) {
+	orgID, userID, _, ok := orgAccess(c, models.OrgRoleOwner, models.OrgRoleAdmin)
+	if !ok {
+		return
+	}
+	var req models.CreateOrgInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !checkEmail(c, "email", &req.Email) {
+		return
+	}
+	if req.Role == "" {
+		req.Role = models.OrgRoleMember
+	}
+
+	inv, err := repository.CreateOrgInvitation(c.Request.Context(), orgID, req.Email, req.Role, userID, clock.Now().Add(orgInvitationTTL()))
+	if errors.Is(err, repository.ErrAlreadyInvited) {
+		render.JSON(c, http.StatusConflict, gin.H{"error": "This email already has an open invitation; resend it instead"})
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to create invitation"})
+		return
+	}
+	if !sendOrgInvitation(c, inv, userID) {
+		return
+	}
+
+	render.JSON(c, http.StatusCreated, gin.H{"data": inv})
+}
+
+// ListOrgInvitations lists the org's open invitations, including expired
+// ones that can be resent.
+func ListOrgInvitations(c *gin.Context) {
+	orgID, _, _, ok := orgAccess(c, models.OrgRoleOwner, models.OrgRoleAdmin)
+	if !ok {
+		return
+	}
+
+	invitations, err := repository.ListOrgInvitations(c.Request.Context(), orgID)
+	if err != nil {
+		c.Error(err)
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to fetch invitations"})
+		return
+	}
+
+	render.JSON(c, http.StatusOK, gin.H{"data": invitations})
+}
+
+// ResendOrgInvitation emails an open invitation again, with a fresh expiry.
+func ResendOrgInvitation(c *gin.Context) {
+	orgID, userID, _, ok := orgAccess(c, models.OrgRoleOwner, models.OrgRoleAdmin)
+	if !ok {
+		return
+	}
+
+	inv, err := repository.RenewOrgInvitation(c.Request.Context(), orgID, c.Param("invitation_id"), clock.Now().Add(orgInvitationTTL()))
+	if errors.Is(err, repository.ErrNotFound) {
+		render.JSON(c, http.StatusNotFound, gin.H{"error": "Invitation not found"})
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to resend invitation"})
+		return
+	}
+	if !sendOrgInvitation(c, inv, userID) {
+		return
+	}
+
+	render.JSON(c, http.StatusOK, gin.H{"data": inv})
+}
+
+// RevokeOrgInvitation stops an open invitation from being accepted.
+func RevokeOrgInvitation(c *gin.Context) {
+	orgID, _, _, ok := orgAccess(c, models.OrgRoleOwner, models.OrgRoleAdmin)
+	if !ok {
+		return
+	}
+
+	err := repository.RevokeOrgInvitation(c.Request.Context(), orgID, c.Param("invitation_id"))
+	if errors.Is(err, repository.ErrNotFound) {
+		render.JSON(c, http.StatusNotFound, gin.H{"error": "Invitation not found"})
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to revoke invitation"})
+		return
+	}
+
+	render.JSON(c, http.StatusOK, gin.H{"message": "Invitation revoked successfully"})
+}
+
+// AcceptOrgInvitation puts the account with the invited email in the org,
+// creating it with the given name if there is none, and signs it in. The
+// link proves the invitee controls the email, like a magic link.
+func AcceptOrgInvitation(c *gin.Context) {
+	var req models.AcceptOrgInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !auth.Enabled() {
+		authOK(c, auth.ErrDisabled)
+		return
+	}
+	ctx := c.Request.Context()
+
+	id, err := auth.ParseLink(invitationLink, req.Token)
+	if !authOK(c, err) {
+		return
+	}
+	inv, err := repository.GetOrgInvitation(ctx, id)
+	if errors.Is(err, repository.ErrNotFound) || err == nil && !inv.ExpiresAt.After(clock.Now()) {
+		authOK(c, auth.ErrInvalidToken)
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to accept invitation"})
+		return
+	}
+
+	user, err := repository.GetUserByEmail(ctx, inv.Email)
+	if errors.Is(err, repository.ErrNotFound) {
+		if req.Name == "" {
+			render.JSON(c, http.StatusBadRequest, gin.H{"error": "name is required to create an account"})
+			return
+		}
+		if !checkName(c, "name", &req.Name) || !checkBlocked(c, "name", req.Name) {
+			return
+		}
+		user, err = repository.CreateUser(ctx, models.CreateUserRequest{Email: inv.Email, Name: req.Name})
+	}
+	if err != nil {
+		c.Error(err)
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to accept invitation"})
+		return
+	}
+
+	inv, joined, err := repository.AcceptOrgInvitation(ctx, id, user.ID)
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		authOK(c, auth.ErrInvalidToken)
+		return
+	case errors.Is(err, repository.ErrOtherOrg):
+		render.JSON(c, http.StatusConflict, gin.H{"error": "This account already belongs to another org"})
+		return
+	case err != nil:
+		c.Error(err)
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to accept invitation"})
+		return
+	}
+
+	if joined {
+		recordChange(c, "org.member.joined", gin.H{"org_id": inv.OrgID, "user_id": user.ID, "role": inv.Role, "invitation_id": inv.ID})
+	}
+
+	tokens, err := auth.StartSession(ctx, user.ID, "invitation", authClient(c))
+	if !authOK(c, err) {
+		return
+	}
+
+	render.JSON(c, http.StatusOK, gin.H{"data": gin.H{"user": user, "org_id": inv.OrgID, "tokens": tokens}})
+}
+
+// orgAccess checks that the signed-in user is in the org in the path and,
+// when roles are given, has one of them. Non-members get 404, so org IDs
+// cannot be probed.
+func orgAccess(c *gin.Context, roles ...string) (orgID, userID int, role string, ok bool) {
+	if userID, ok = currentUserID(c); !ok {
+		return 0, 0, "", false
+	}
+	if orgID, ok = parseIDParam(c, "id", "Invalid org ID"); !ok {
+		return 0, 0, "", false
+	}
+	if role, ok = orgRoleOK(c, orgID, userID, roles...); !ok {
+		return 0, 0, "", false
+	}
+	return orgID, userID, role, true
+}
+
+// orgRoleOK returns a user's role in an org, writing the response and
+// returning false when they are not in it or their role is not one of
+// roles.
+func orgRoleOK(c *gin.Context, orgID, userID int, roles ...string) (string, bool) {
+	role, err := repository.OrgRole(c.Request.Context(), orgID, userID)
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		render.JSON(c, http.StatusNotFound, gin.H{"error": "Org not found"})
+		return "", false
+	case err != nil:
+		c.Error(err)
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to check org role"})
+		return "", false
+	case len(roles) > 0 && !slices.Contains(roles, role):
+		render.JSON(c, http.StatusForbidden, gin.H{"error": "Your org role does not allow this"})
+		return "", false
+	}
+	return role, true
+}
+
+// sendOrgInvitation queues the invitation email, writing a 500 response if
+// it cannot. The invitation is kept, so it can be resent.
+func sendOrgInvitation(c *gin.Context, inv models.OrgInvitation, inviterID int) bool {
+	ctx := c.Request.Context()
+	org, err := repository.OrgName(ctx, inv.OrgID)
+	if err == nil {
+		var inviter models.User
+		if inviter, err = repository.GetUser(ctx, inviterID); err == nil {
+			err = mailer.Enqueue(ctx, inv.Email, templates.OrgInvitation, templates.Data{
+				Org:       org,
+				InvitedBy: inviter.Name,
+				Link:      orgInvitationURL(auth.SignLink(invitationLink, inv.ID)),
+				ExpiresIn: humanDuration(inv.ExpiresAt.Sub(inv.SentAt)),
+			})
+		}
+	}
+	if err != nil {
+		log.Printf("Failed to enqueue invitation email for invitation %s: %v", inv.ID, err)
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to send invitation"})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"pygorp/backend/internal/auth"
+	"pygorp/backend/internal/clock"
+	"pygorp/backend/internal/models"
+	"pygorp/backend/internal/render"
+	"pygorp/backend/internal/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListPersonalTokens lists the caller's personal access tokens, including
+// revoked and expired ones.
+func ListPersonalTokens(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	tokens, err := repository.ListPersonalTokens(c.Request.Context(), userID)
+	if err != nil {
+		c.Error(err)
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to fetch personal access tokens"})
+		return
+	}
+
+	render.JSON(c, http.StatusOK, gin.H{"data": tokens})
+}
+
+// CreatePersonalToken mints a personal access token for the signed-in user.
+// Tokens can only grant scopes the session has, and cannot be used to mint
+// more tokens. The token is only shown in this response.
+func CreatePersonalToken(c *gin.Context) {
+	userID, ok := sessionUserID(c, "Personal access tokens can only be created from a signed-in session")
+	if !ok {
+		return
+	}
+
+	var req models.CreatePersonalAccessTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	granted := c.GetStringSlice("auth_scopes")
+	for _, scope := range req.Scopes {
+		if !slices.Contains(granted, scope) {
+			render.JSON(c, http.StatusForbidden, gin.H{"error": "Session does not have scope " + scope})
+			return
+		}
+	}
+	slices.Sort(req.Scopes)
+	scopes := slices.Compact(req.Scopes)
+
+	now := clock.Now()
+	maxExpiry := now.Add(auth.PersonalTokenMaxTTL())
+	expiresAt := maxExpiry
+	if req.ExpiresAt != nil {
+		if !req.ExpiresAt.After(now) {
+			render.JSON(c, http.StatusBadRequest, gin.H{"error": "expires_at must be in the future"})
+			return
+		}
+		if req.ExpiresAt.After(maxExpiry) {
+			render.JSON(c, http.StatusBadRequest, gin.H{"error": fmt.Sprintf("expires_at must be within %s", auth.PersonalTokenMaxTTL())})
+			return
+		}
+		expiresAt = *req.ExpiresAt
+	}
+
+	token, err := repository.CreatePersonalToken(c.Request.Context(), userID, req.Name, scopes, expiresAt)
+	if err != nil {
+		c.Error(err)
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to create personal access token"})
+		return
+	}
+
+	render.JSON(c, http.StatusCreated, gin.H{"data": token})
+}
+
+// RevokePersonalToken revokes one of the caller's personal access tokens.
+// A token can revoke itself, but only a signed-in session can revoke other
+// tokens.
+func RevokePersonalToken(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	id, ok := parseIDParam(c, "id", "Invalid token ID")
+	if !ok {
+		return
+	}
+	if c.GetString("auth_session") == "" && c.GetString("auth_token") != strconv.Itoa(id) {
+		render.JSON(c, http.StatusForbidden, gin.H{"error": "Other personal access tokens can only be revoked from a signed-in session"})
+		return
+	}
+
+	err := repository.RevokePersonalToken(c.Request.Context(), userID, id)
+	if errors.Is(err, repository.ErrNotFound) {
+		render.JSON(c, http.StatusNotFound, gin.H{"error": "Personal access token not found"})
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to revoke personal access token"})
+		return
+	}
+
+	render.JSON(c, http.StatusOK, gin.H{"message": "Personal access token revoked successfully"})
+}
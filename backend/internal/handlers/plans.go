@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"pygorp/backend/internal/quota"
+	"pygorp/backend/internal/render"
+
+	"github.com/gin-gonic/gin"
+)
+
+type orgPlanRequest struct {
+	Plan string `json:"plan"`
+}
+
+func ListPlans(c *gin.Context) {
+	plans, err := quota.List(c.Request.Context())
+	if err != nil {
+		c.Error(err)
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to fetch plans"})
+		return
+	}
+
+	render.JSON(c, http.StatusOK, gin.H{"data": plans})
+}
+
+// PutPlan creates or replaces the plan named in the path.
+func PutPlan(c *gin.Context) {
+	var p quota.Plan
+	if err := c.ShouldBindJSON(&p); err != nil {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	p.Name = c.Param("name")
+	if err := p.Check(); err != nil {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	saved, err := quota.Put(c.Request.Context(), &p)
+	if err != nil {
+		c.Error(err)
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to save plan"})
+		return
+	}
+
+	render.JSON(c, http.StatusOK, gin.H{"data": saved})
+}
+
+// DeletePlan removes a plan; its orgs go back to per-client-IP limits.
+func DeletePlan(c *gin.Context) {
+	err := quota.Delete(c.Request.Context(), c.Param("name"))
+	if errors.Is(err, quota.ErrNotFound) {
+		render.JSON(c, http.StatusNotFound, gin.H{"error": "Plan not found"})
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to delete plan"})
+		return
+	}
+
+	render.JSON(c, http.StatusOK, gin.H{"message": "Plan deleted successfully"})
+}
+
+// SetOrgPlan puts an org on the plan in the body, or takes it off its plan
+// when the plan is empty.
+func SetOrgPlan(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "Invalid org ID")
+	if !ok {
+		return
+	}
+	var req orgPlanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := quota.SetOrgPlan(c.Request.Context(), id, req.Plan)
+	switch {
+	case errors.Is(err, quota.ErrNotFound):
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": "Plan not found"})
+		return
+	case errors.Is(err, quota.ErrOrgNotFound):
+		render.JSON(c, http.StatusNotFound, gin.H{"error": "Org not found"})
+		return
+	case err != nil:
+		c.Error(err)
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to set org plan"})
+		return
+	}
+
+	render.JSON(c, http.StatusOK, gin.H{"data": gin.H{"org_id": id, "plan": req.Plan}})
+}
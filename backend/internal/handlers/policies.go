@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"pygorp/backend/internal/policy"
+	"pygorp/backend/internal/render"
+
+	"github.com/gin-gonic/gin"
+)
+
+func ListPolicies(c *gin.Context) {
+	policies, err := policy.List(c.Request.Context())
+	if err != nil {
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to fetch policies"})
+		return
+	}
+
+	render.JSON(c, http.StatusOK, gin.H{"data": policies})
+}
+
+func GetPolicy(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "Invalid policy ID")
+	if !ok {
+		return
+	}
+
+	p, err := policy.Get(c.Request.Context(), id)
+	if !policyOK(c, err, "Failed to fetch policy") {
+		return
+	}
+
+	render.JSON(c, http.StatusOK, gin.H{"data": p})
+}
+
+func CreatePolicy(c *gin.Context) {
+	p, ok := bindPolicy(c)
+	if !ok {
+		return
+	}
+
+	created, err := policy.Create(c.Request.Context(), p)
+	if !policyOK(c, err, "Failed to create policy") {
+		return
+	}
+
+	render.JSON(c, http.StatusCreated, gin.H{"data": created})
+}
+
+// UpdatePolicy replaces a policy.
+func UpdatePolicy(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "Invalid policy ID")
+	if !ok {
+		return
+	}
+	p, ok := bindPolicy(c)
+	if !ok {
+		return
+	}
+
+	updated, err := policy.Update(c.Request.Context(), id, p)
+	if !policyOK(c, err, "Failed to update policy") {
+		return
+	}
+
+	render.JSON(c, http.StatusOK, gin.H{"data": updated})
+}
+
+func DeletePolicy(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "Invalid policy ID")
+	if !ok {
+		return
+	}
+
+	err := policy.Delete(c.Request.Context(), id)
+	if !policyOK(c, err, "Failed to delete policy") {
+		return
+	}
+
+	render.JSON(c, http.StatusOK, gin.H{"message": "Policy deleted successfully"})
+}
+
+// EvaluatePolicies dry-runs a request against the stored policies, for
+// testing changes before switching policy_mode to enforce.
+func EvaluatePolicies(c *gin.Context) {
+	var req policy.Request
+	if err := c.ShouldBindJSON(&req); err != nil {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	policies, err := policy.List(c.Request.Context())
+	if err != nil {
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to fetch policies"})
+		return
+	}
+
+	render.JSON(c, http.StatusOK, gin.H{"data": policy.Evaluate(policies, req)})
+}
+
+func bindPolicy(c *gin.Context) (*policy.Policy, bool) {
+	var p policy.Policy
+	if err := c.ShouldBindJSON(&p); err != nil {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	if err := p.Check(); err != nil {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return &p, true
+}
+
+// policyOK writes the response for a failed policy lookup or change and
+// reports whether err was nil.
+func policyOK(c *gin.Context, err error, message string) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, policy.ErrNotFound):
+		render.JSON(c, http.StatusNotFound, gin.H{"error": "Policy not found"})
+	default:
+		c.Error(err)
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": message})
+	}
+	return false
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"pygorp/backend/internal/models"
+	"pygorp/backend/internal/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// userETag is the entity tag of a user's version, its updated_at to the
+// microsecond as Postgres stores it.
+func userETag(user models.User) string {
+	return `"` + strconv.FormatInt(user.UpdatedAt.UnixMicro(), 36) + `"`
+}
+
+// setVersion sends the user's version as ETag and Last-Modified, for
+// clients to make later writes conditional on.
+func setVersion(c *gin.Context, user models.User) {
+	c.Header("ETag", userETag(user))
+	c.Header("Last-Modified", user.UpdatedAt.UTC().Format(http.TimeFormat))
+}
+
+// precondition reads If-Match and If-Unmodified-Since. As RFC 9110
+// requires, If-Unmodified-Since is ignored when If-Match is sent or it is
+// not a valid date, and If-Match compares strongly, so weak tags and tags
+// this server did not issue never match.
+func precondition(c *gin.Context) repository.Precondition {
+	var pre repository.Precondition
+	if header := c.GetHeader("If-Match"); header != "" {
+		if strings.TrimSpace(header) == "*" {
+			return pre
+		}
+		pre.Versions = []time.Time{}
+		for _, tag := range strings.Split(header, ",") {
+			tag = strings.TrimSpace(tag)
+			if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+				continue
+			}
+			if us, err := strconv.ParseInt(tag[1:len(tag)-1], 36, 64); err == nil {
+				pre.Versions = append(pre.Versions, time.UnixMicro(us))
+			}
+		}
+		return pre
+	}
+	if since, err := http.ParseTime(c.GetHeader("If-Unmodified-Since")); err == nil {
+		pre.UnmodifiedSince = since
+	}
+	return pre
+}
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"pygorp/backend/internal/clock"
+	"pygorp/backend/internal/models"
+	"pygorp/backend/internal/policy"
+	"pygorp/backend/internal/previews"
+	"pygorp/backend/internal/render"
+	"pygorp/backend/internal/repository"
+	"pygorp/backend/internal/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetAttachmentPreview returns a preview of an image or PDF attachment
+// that fits in w x h. The first request for a size queues it and answers
+// 202; once it is ready the response has a signed URL for the image.
+func GetAttachmentPreview(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "Invalid attachment ID")
+	if !ok {
+		return
+	}
+	width, err := previews.Size(c.Query("w"))
+	if err != nil {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": "w " + err.Error()})
+		return
+	}
+	height, err := previews.Size(c.Query("h"))
+	if err != nil {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": "h " + err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	attachment, err := repository.GetAttachmentByID(ctx, id)
+	if err == nil && attachment.ResourceType != models.ProjectResource {
+		err = repository.ErrNotFound
+	}
+	if !attachmentOK(c, err, "Failed to fetch attachment") {
+		return
+	}
+	orgID, err := repository.ProjectOrg(ctx, attachment.ResourceID)
+	if !projectOK(c, err, "Failed to fetch attachment") {
+		return
+	}
+	if _, _, _, ok := projectAccessTo(c, orgID, attachment.ResourceID, policy.PermissionRead); !ok {
+		return
+	}
+
+	preview, err := previews.Request(ctx, attachment.StorageKey, attachment.ContentType, width, height)
+	if errors.Is(err, previews.ErrUnsupported) {
+		render.JSON(c, http.StatusUnsupportedMediaType, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to fetch preview"})
+		return
+	}
+
+	c.Header("Cache-Control", "private, no-cache")
+	switch preview.Status {
+	case previews.StatusReady:
+		render.JSON(c, http.StatusOK, gin.H{"data": previews.Sign(preview)})
+	case previews.StatusFailed:
+		render.JSON(c, http.StatusUnprocessableEntity, gin.H{"error": preview.Error})
+	default:
+		c.Header("Retry-After", "2")
+		render.JSON(c, http.StatusAccepted, gin.H{"data": preview})
+	}
+}
+
+// ServePreview serves the image behind a signed preview URL. The URL is
+// the credential, so it needs no session, and the bytes behind it never
+// change, so browsers may cache them until it expires.
+func ServePreview(c *gin.Context) {
+	id, expires, err := previews.Verify(c.Param("token"))
+	if err != nil {
+		render.JSON(c, http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	ctx := c.Request.Context()
+	preview, err := previews.Get(ctx, id)
+	if errors.Is(err, previews.ErrNotFound) || (err == nil && preview.Status != previews.StatusReady) {
+		render.JSON(c, http.StatusNotFound, gin.H{"error": "Not found"})
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to fetch preview"})
+		return
+	}
+
+	etag := `"` + strconv.Itoa(preview.ID) + "-" + strconv.FormatInt(preview.UpdatedAt.Unix(), 10) + `"`
+	maxAge := int(math.Ceil(expires.Sub(clock.Now()).Seconds()))
+	c.Header("Cache-Control", "private, max-age="+strconv.Itoa(maxAge)+", immutable")
+	c.Header("Expires", expires.UTC().Format(http.TimeFormat))
+	c.Header("ETag", etag)
+	c.Header("X-Content-Type-Options", "nosniff")
+	if c.GetHeader("If-None-Match") == etag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	store, err := storage.Default()
+	if err != nil {
+		c.Error(err)
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to read preview"})
+		return
+	}
+	data, err := store.Get(ctx, preview.StorageKey)
+	if errors.Is(err, storage.ErrNotFound) {
+		render.JSON(c, http.StatusNotFound, gin.H{"error": "Not found"})
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to read preview"})
+		return
+	}
+	c.Data(http.StatusOK, preview.ContentType, data)
+}
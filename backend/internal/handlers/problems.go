@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"net/http"
+
+	"pygorp/backend/internal/problem"
+	"pygorp/backend/internal/render"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListProblemTypes documents every problem type error responses can have.
+func ListProblemTypes(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=3600")
+	render.JSON(c, http.StatusOK, gin.H{"data": problem.Types()})
+}
+
+// GetProblemType documents one problem type. Its path is the type URI of
+// error responses with that code.
+func GetProblemType(c *gin.Context) {
+	t, ok := problem.Lookup(c.Param("code"))
+	if !ok {
+		render.JSON(c, http.StatusNotFound, gin.H{"error": "Problem type not found"})
+		return
+	}
+	c.Header("Cache-Control", "public, max-age=3600")
+	render.JSON(c, http.StatusOK, gin.H{"data": t})
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"pygorp/backend/internal/models"
+	"pygorp/backend/internal/policy"
+	"pygorp/backend/internal/render"
+	"pygorp/backend/internal/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListProjectACL lists who a project is shared with, to anyone who can
+// read it.
+func ListProjectACL(c *gin.Context) {
+	project, _, _, ok := projectAccess(c, policy.PermissionRead)
+	if !ok {
+		return
+	}
+
+	entries, err := policy.ListACL(c.Request.Context(), projectResource(project))
+	if err != nil {
+		c.Error(err)
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to fetch shares"})
+		return
+	}
+
+	render.JSON(c, http.StatusOK, gin.H{"data": entries})
+}
+
+// ShareProject shares a project with a member or team of its org. Anyone
+// who can write to the project can share it.
+func ShareProject(c *gin.Context) {
+	project, userID, _, ok := projectAccess(c, policy.PermissionWrite)
+	if !ok {
+		return
+	}
+	var req models.ShareRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !principalOK(c, project.OrgID, req.Principal) {
+		return
+	}
+	resource := projectResource(project)
+
+	entry, err := policy.Grant(c.Request.Context(), resource, req.Principal, req.Permission, userID)
+	if err != nil {
+		c.Error(err)
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to share project"})
+		return
+	}
+
+	render.JSON(c, http.StatusOK, gin.H{"data": entry})
+	recordChange(c, "acl.granted", gin.H{"resource": resource.Type, "resource_id": resource.ID, "principal": entry.Principal, "permission": entry.Permission})
+}
+
+// UnshareProject deletes one of a project's ACL entries.
+func UnshareProject(c *gin.Context) {
+	project, _, _, ok := projectAccess(c, policy.PermissionWrite)
+	if !ok {
+		return
+	}
+	entryID, ok := parseIDParam(c, "entry_id", "Invalid entry ID")
+	if !ok {
+		return
+	}
+	resource := projectResource(project)
+
+	entry, err := policy.Revoke(c.Request.Context(), resource, entryID)
+	if errors.Is(err, policy.ErrEntryNotFound) {
+		render.JSON(c, http.StatusNotFound, gin.H{"error": "Share not found"})
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to unshare project"})
+		return
+	}
+
+	render.JSON(c, http.StatusOK, gin.H{"message": "Share removed successfully"})
+	recordChange(c, "acl.revoked", gin.H{"resource": resource.Type, "resource_id": resource.ID, "principal": entry.Principal, "permission": entry.Permission})
+}
+
+func projectResource(project models.Project) policy.Resource {
+	return policy.Resource{Type: models.ProjectResource, ID: project.ID}
+}
+
+// principalOK checks that a principal is a member or team of the org,
+// writing a 400 response when it is not.
+func principalOK(c *gin.Context, orgID int, principal string) bool {
+	kind, rawID, _ := strings.Cut(principal, ":")
+	id, err := strconv.Atoi(rawID)
+	if err != nil || id < 1 || kind != "user" && kind != "team" {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": `principal must be "user:<id>" or "team:<id>"`})
+		return false
+	}
+
+	ctx := c.Request.Context()
+	if kind == "user" {
+		_, err = repository.OrgRole(ctx, orgID, id)
+	} else {
+		_, err = repository.GetTeam(ctx, orgID, id)
+	}
+	if errors.Is(err, repository.ErrNotFound) {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": "principal is not a member or team of this org"})
+		return false
+	}
+	if err != nil {
+		c.Error(err)
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to check principal"})
+		return false
+	}
+	return true
+}
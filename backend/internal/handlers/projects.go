@@ -0,0 +1,279 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"pygorp/backend/internal/models"
+	"pygorp/backend/internal/policy"
+	"pygorp/backend/internal/render"
+	"pygorp/backend/internal/repository"
+	"pygorp/backend/internal/retention"
+	"pygorp/backend/internal/sqlb"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListProjects returns a page of the org's projects that the caller can
+// see, optionally only those of ?team_id=. Owners and admins see every
+// team's projects; members see the org-wide ones, their teams', and those
+// shared with them.
+func ListProjects(c *gin.Context) {
+	orgID, userID, role, ok := orgAccess(c)
+	if !ok {
+		return
+	}
+	teamID, ok := teamIDQuery(c)
+	if !ok {
+		return
+	}
+	limit, offset, ok := parsePage(c)
+	if !ok {
+		return
+	}
+
+	query := repository.ProjectQuery{
+		ViewerID: userID,
+		AllTeams: role != models.OrgRoleMember,
+		TeamID:   teamID,
+		Page:     sqlb.Page{Limit: limit, Offset: offset},
+	}
+	if !query.AllTeams {
+		shared, err := sharedProjects(c, userID)
+		if err != nil {
+			c.Error(err)
+			render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to fetch projects"})
+			return
+		}
+		query.SharedIDs = shared
+	}
+
+	projects, err := repository.ListProjects(c.Request.Context(), orgID, query)
+	if err != nil {
+		c.Error(err)
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to fetch projects"})
+		return
+	}
+
+	render.JSON(c, http.StatusOK, gin.H{"data": projects})
+}
+
+func GetProject(c *gin.Context) {
+	project, _, _, ok := projectAccess(c, policy.PermissionRead)
+	if !ok {
+		return
+	}
+
+	render.JSON(c, http.StatusOK, gin.H{"data": project})
+}
+
+// CreateProject adds a project to the org, or to one of its teams. Members
+// can only add projects to teams they are in.
+func CreateProject(c *gin.Context) {
+	orgID, userID, role, ok := orgAccess(c)
+	if !ok {
+		return
+	}
+	var req models.CreateProjectRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !checkName(c, "name", &req.Name) || !checkBlocked(c, "name", req.Name) {
+		return
+	}
+	if req.TeamID != nil && !teamMemberOK(c, *req.TeamID, userID, role) {
+		return
+	}
+
+	project, err := repository.CreateProject(c.Request.Context(), orgID, userID, req)
+	if !projectOK(c, err, "Failed to create project") {
+		return
+	}
+
+	render.JSON(c, http.StatusCreated, gin.H{"data": project})
+}
+
+// UpdateProject changes a project, including moving it to another team or
+// opening it to the whole org with a team_id of 0.
+func UpdateProject(c *gin.Context) {
+	project, userID, role, ok := projectAccess(c, policy.PermissionWrite)
+	if !ok {
+		return
+	}
+	var req models.UpdateProjectRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !checkName(c, "name", &req.Name) || !checkBlocked(c, "name", req.Name) {
+		return
+	}
+	if req.TeamID != nil && *req.TeamID != 0 && !teamMemberOK(c, *req.TeamID, userID, role) {
+		return
+	}
+
+	project, err := repository.UpdateProject(c.Request.Context(), project.OrgID, project.ID, req)
+	if !projectOK(c, err, "Failed to update project") {
+		return
+	}
+
+	render.JSON(c, http.StatusOK, gin.H{"data": project})
+}
+
+// DeleteProject moves a project to the trash, from which it is purged after
+// PROJECT_DELETION_GRACE.
+func DeleteProject(c *gin.Context) {
+	project, userID, _, ok := projectAccess(c, policy.PermissionWrite)
+	if !ok {
+		return
+	}
+
+	err := repository.DeleteProject(c.Request.Context(), project.OrgID, project.ID, userID, retention.ProjectDeletionGrace())
+	if !projectOK(c, err, "Failed to delete project") {
+		return
+	}
+
+	render.JSON(c, http.StatusOK, gin.H{"message": "Project moved to the trash"})
+}
+
+// projectAccess loads the project in the path for a member of its org who
+// holds the wanted permission on it, as worked out by projectPermission.
+// Members with no permission get 404, and those with only read get 403 when
+// they want write.
+func projectAccess(c *gin.Context, want string) (project models.Project, userID int, role string, ok bool) {
+	orgID, userID, role, ok := orgAccess(c)
+	if !ok {
+		return project, 0, "", false
+	}
+	projectID, ok := parseIDParam(c, "project_id", "Invalid project ID")
+	if !ok {
+		return project, 0, "", false
+	}
+	return projectRoleAccess(c, orgID, projectID, userID, role, want)
+}
+
+// projectAccessTo is projectAccess for a project named outside the path,
+// such as in a request body.
+func projectAccessTo(c *gin.Context, orgID, projectID int, want string) (project models.Project, userID int, role string, ok bool) {
+	if userID, ok = currentUserID(c); !ok {
+		return project, 0, "", false
+	}
+	if role, ok = orgRoleOK(c, orgID, userID); !ok {
+		return project, 0, "", false
+	}
+	return projectRoleAccess(c, orgID, projectID, userID, role, want)
+}
+
+// projectRoleAccess finishes projectAccess once the caller's org role is
+// known.
+func projectRoleAccess(c *gin.Context, orgID, projectID, userID int, role, want string) (models.Project, int, string, bool) {
+	project, err := repository.GetProject(c.Request.Context(), orgID, projectID)
+	if !projectOK(c, err, "Failed to fetch project") {
+		return project, 0, "", false
+	}
+	if role != models.OrgRoleMember {
+		return project, userID, role, true
+	}
+
+	held, err := projectPermission(c, project, userID)
+	switch {
+	case err != nil:
+		c.Error(err)
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to check project access"})
+		return project, 0, "", false
+	case held == "":
+		render.JSON(c, http.StatusNotFound, gin.H{"error": "Project not found"})
+		return project, 0, "", false
+	case !policy.Covers(held, want):
+		render.JSON(c, http.StatusForbidden, gin.H{"error": "You do not have write access to this project"})
+		return project, 0, "", false
+	}
+	return project, userID, role, true
+}
+
+// projectPermission returns the strongest permission a plain member holds
+// on a project, or "" for none. The project's team, and for an org-wide
+// project its creator, can write; the rest of the org can read org-wide
+// projects. The project's ACL entries for the member, or for their teams
+// and groups, can grant more.
+func projectPermission(c *gin.Context, project models.Project, userID int) (string, error) {
+	ctx := c.Request.Context()
+	subjects, err := policy.Subjects(ctx, strconv.Itoa(userID), "", "")
+	if err != nil {
+		return "", err
+	}
+
+	var held string
+	switch {
+	case project.TeamID != nil:
+		if slices.Contains(subjects, "team:"+strconv.Itoa(*project.TeamID)) {
+			held = policy.PermissionWrite
+		}
+	case project.CreatedBy != nil && *project.CreatedBy == userID:
+		held = policy.PermissionWrite
+	default:
+		held = policy.PermissionRead
+	}
+	if held == policy.PermissionWrite {
+		return held, nil
+	}
+
+	granted, err := policy.Granted(ctx, subjects, policy.Resource{Type: models.ProjectResource, ID: project.ID})
+	if err != nil {
+		return "", err
+	}
+	if held == "" || granted == policy.PermissionWrite {
+		held = granted
+	}
+	return held, nil
+}
+
+// sharedProjects returns the IDs of the projects shared with a user or
+// their teams and groups.
+func sharedProjects(c *gin.Context, userID int) ([]int, error) {
+	ctx := c.Request.Context()
+	subjects, err := policy.Subjects(ctx, strconv.Itoa(userID), "", "")
+	if err != nil {
+		return nil, err
+	}
+	return policy.Shared(ctx, subjects, models.ProjectResource)
+}
+
+// teamMemberOK checks that a member putting a project in a team is in it,
+// writing a 403 response when not. Owners and admins can use any team.
+func teamMemberOK(c *gin.Context, teamID, userID int, role string) bool {
+	if role != models.OrgRoleMember {
+		return true
+	}
+	in, err := repository.InTeam(c.Request.Context(), teamID, userID)
+	if err != nil {
+		c.Error(err)
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to check team membership"})
+		return false
+	}
+	if !in {
+		render.JSON(c, http.StatusForbidden, gin.H{"error": "You can only put projects in your own teams"})
+		return false
+	}
+	return true
+}
+
+// projectOK writes the response for a failed project lookup or change and
+// reports whether err was nil.
+func projectOK(c *gin.Context, err error, message string) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, repository.ErrNotFound):
+		render.JSON(c, http.StatusNotFound, gin.H{"error": "Project not found"})
+	case errors.Is(err, repository.ErrTeamNotFound):
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": "team_id is not a team of this org"})
+	default:
+		c.Error(err)
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": message})
+	}
+	return false
+}
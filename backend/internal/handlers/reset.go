@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"os"
+
+	"pygorp/backend/internal/database"
+	"pygorp/backend/internal/policy"
+	"pygorp/backend/internal/render"
+
+	"github.com/gin-gonic/gin"
+)
+
+type resetDatabaseRequest struct {
+	// Confirm must repeat TESTING_RESET_TOKEN.
+	Confirm string `json:"confirm" binding:"required"`
+}
+
+// resetEnabled reports whether this instance allows database resets:
+// TESTING_RESET=true and TESTING_RESET_TOKEN set, outside production.
+func resetEnabled() bool {
+	return os.Getenv("TESTING_RESET") == "true" && os.Getenv("TESTING_RESET_TOKEN") != "" &&
+		os.Getenv("ENV") != "production"
+}
+
+// ResetDatabase empties the database and reseeds it, so end-to-end suites
+// start each run from a known state.
+func ResetDatabase(c *gin.Context) {
+	if !resetEnabled() {
+		render.JSON(c, http.StatusForbidden, gin.H{"error": "Database reset is disabled"})
+		return
+	}
+	var req resetDatabaseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(req.Confirm), []byte(os.Getenv("TESTING_RESET_TOKEN"))) != 1 {
+		render.JSON(c, http.StatusForbidden, gin.H{"error": "Confirmation token does not match"})
+		return
+	}
+
+	tables, err := database.Reset(c.Request.Context())
+	// Policies are cached; drop them even after a partial reset.
+	policy.Invalidate()
+	if err != nil {
+		c.Error(err)
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to reset the database"})
+		return
+	}
+	render.JSON(c, http.StatusOK, gin.H{"data": gin.H{"truncated": tables, "seeded": true}})
+}
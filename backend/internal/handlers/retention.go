@@ -0,0 +1,22 @@
+package handlers
+
+import (
+	"net/http"
+
+	"pygorp/backend/internal/render"
+	"pygorp/backend/internal/retention"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PreviewRetention lists the retention rules with what each would purge or
+// anonymize if it ran now, whether or not it is enabled.
+func PreviewRetention(c *gin.Context) {
+	previews, err := retention.PreviewRules(c.Request.Context())
+	if err != nil {
+		c.Error(err)
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to preview retention rules"})
+		return
+	}
+	render.JSON(c, http.StatusOK, gin.H{"data": previews})
+}
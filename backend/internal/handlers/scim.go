@@ -0,0 +1,262 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"pygorp/backend/internal/config"
+	"pygorp/backend/internal/models"
+	"pygorp/backend/internal/repository"
+	"pygorp/backend/internal/sanitize"
+	"pygorp/backend/internal/scim"
+	"pygorp/backend/internal/sqlb"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxScimPage caps the count parameter of SCIM list requests.
+const maxScimPage = 200
+
+// ScimListUsers serves GET /scim/v2/Users with filter, startIndex (1-based),
+// and count parameters.
+func ScimListUsers(c *gin.Context) {
+	var where sqlb.Expr
+	if raw := c.Query("filter"); raw != "" {
+		filter, err := scim.ParseFilter(raw)
+		if err == nil {
+			where, err = filter.SQL(scim.UserAttributes)
+		}
+		if err != nil {
+			scimError(c, http.StatusBadRequest, "invalidFilter", err.Error())
+			return
+		}
+	}
+
+	startIndex := scimInt(c, "startIndex", 1)
+	if startIndex < 1 {
+		startIndex = 1
+	}
+	count := scimInt(c, "count", 100)
+	if count < 0 {
+		count = 0
+	}
+	// SCIM clients must accept fewer results than requested, so large
+	// counts are lowered rather than rejected.
+	count = min(count, maxScimPage, config.Current().MaxPageSize)
+
+	total, err := repository.CountProvisionedUsers(c.Request.Context(), where)
+	if err != nil {
+		scimError(c, http.StatusInternalServerError, "", "Failed to fetch users")
+		return
+	}
+	users := []models.ProvisionedUser{}
+	if count > 0 && total > 0 {
+		users, err = repository.ListProvisionedUsers(c.Request.Context(), where, sqlb.Page{Limit: count, Offset: startIndex - 1})
+		if err != nil {
+			scimError(c, http.StatusInternalServerError, "", "Failed to fetch users")
+			return
+		}
+	}
+
+	resources := make([]scim.User, len(users))
+	for i, u := range users {
+		resources[i] = scim.FromModel(u, scimLocation(c))
+	}
+	scimJSON(c, http.StatusOK, scim.ListResponse{
+		Schemas:      []string{scim.ListResponseSchema},
+		TotalResults: total,
+		StartIndex:   startIndex,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	})
+}
+
+func ScimGetUser(c *gin.Context) {
+	user, ok := scimLoadUser(c)
+	if !ok {
+		return
+	}
+
+	scimJSON(c, http.StatusOK, scim.FromModel(user, scimLocation(c)))
+}
+
+func ScimCreateUser(c *gin.Context) {
+	var res scim.User
+	if err := c.ShouldBindJSON(&res); err != nil {
+		scimError(c, http.StatusBadRequest, "invalidSyntax", err.Error())
+		return
+	}
+	req, ok := scimProvisionRequest(c, &res)
+	if !ok {
+		return
+	}
+
+	user, err := repository.ProvisionUser(c.Request.Context(), req)
+	if !scimSaved(c, err) {
+		return
+	}
+
+	location := scimLocation(c)
+	c.Header("Location", location+"/"+strconv.Itoa(user.ID))
+	scimJSON(c, http.StatusCreated, scim.FromModel(user, location))
+}
+
+// ScimReplaceUser serves PUT, replacing every provisioned field.
+func ScimReplaceUser(c *gin.Context) {
+	user, ok := scimLoadUser(c)
+	if !ok {
+		return
+	}
+	var res scim.User
+	if err := c.ShouldBindJSON(&res); err != nil {
+		scimError(c, http.StatusBadRequest, "invalidSyntax", err.Error())
+		return
+	}
+	scimSave(c, user.ID, &res)
+}
+
+// ScimPatchUser applies a PatchOp to the current resource and stores the
+// result. Setting active to false deprovisions the user without deleting it.
+func ScimPatchUser(c *gin.Context) {
+	user, ok := scimLoadUser(c)
+	if !ok {
+		return
+	}
+	var patch scim.PatchRequest
+	if err := c.ShouldBindJSON(&patch); err != nil {
+		scimError(c, http.StatusBadRequest, "invalidSyntax", err.Error())
+		return
+	}
+
+	res := scim.FromModel(user, scimLocation(c))
+	if err := patch.Apply(&res); err != nil {
+		scimError(c, http.StatusBadRequest, "invalidPath", err.Error())
+		return
+	}
+	scimSave(c, user.ID, &res)
+}
+
+func ScimDeleteUser(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		scimError(c, http.StatusNotFound, "", "User not found")
+		return
+	}
+
+	err = repository.DeleteUser(c.Request.Context(), id)
+	if errors.Is(err, repository.ErrNotFound) {
+		scimError(c, http.StatusNotFound, "", "User not found")
+		return
+	}
+	if errors.Is(err, repository.ErrLegalHold) {
+		scimError(c, http.StatusLocked, "", "User is under legal hold")
+		return
+	}
+	if err != nil {
+		scimError(c, http.StatusInternalServerError, "", "Failed to delete user")
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func scimSave(c *gin.Context, id int, res *scim.User) {
+	req, ok := scimProvisionRequest(c, res)
+	if !ok {
+		return
+	}
+
+	user, err := repository.ReplaceProvisionedUser(c.Request.Context(), id, req)
+	if !scimSaved(c, err) {
+		return
+	}
+
+	scimJSON(c, http.StatusOK, scim.FromModel(user, scimLocation(c)))
+}
+
+func scimLoadUser(c *gin.Context) (models.ProvisionedUser, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		scimError(c, http.StatusNotFound, "", "User not found")
+		return models.ProvisionedUser{}, false
+	}
+
+	user, err := repository.GetProvisionedUser(c.Request.Context(), id)
+	if errors.Is(err, repository.ErrNotFound) {
+		scimError(c, http.StatusNotFound, "", "User not found")
+		return user, false
+	}
+	if err != nil {
+		scimError(c, http.StatusInternalServerError, "", "Failed to fetch user")
+		return user, false
+	}
+	return user, true
+}
+
+func scimProvisionRequest(c *gin.Context, res *scim.User) (repository.ProvisionRequest, bool) {
+	email, name, externalID, active, err := res.Fields()
+	if err != nil {
+		scimError(c, http.StatusBadRequest, "invalidValue", err.Error())
+		return repository.ProvisionRequest{}, false
+	}
+	if email, err = sanitize.Email("email", email); err == nil {
+		name, err = sanitize.Name("name", name)
+	}
+	if err != nil {
+		scimError(c, http.StatusBadRequest, "invalidValue", err.Error())
+		return repository.ProvisionRequest{}, false
+	}
+	blocked, err := repository.Blocklist(c.Request.Context())
+	if err != nil {
+		scimError(c, http.StatusInternalServerError, "", "Failed to load blocked words")
+		return repository.ProvisionRequest{}, false
+	}
+	if err := blocked.Check("name", name); err != nil {
+		scimError(c, http.StatusBadRequest, "invalidValue", err.Error())
+		return repository.ProvisionRequest{}, false
+	}
+	return repository.ProvisionRequest{Email: email, Name: name, ExternalID: externalID, Active: active}, true
+}
+
+// scimSaved writes the response for a failed create or replace and reports
+// whether err was nil.
+func scimSaved(c *gin.Context, err error) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, repository.ErrNotFound):
+		scimError(c, http.StatusNotFound, "", "User not found")
+	case errors.Is(err, repository.ErrUserExists):
+		scimError(c, http.StatusConflict, "uniqueness", "A user with this userName or externalId already exists")
+	default:
+		scimError(c, http.StatusInternalServerError, "", "Failed to save user")
+	}
+	return false
+}
+
+// scimLocation returns the absolute URL of the Users endpoint.
+func scimLocation(c *gin.Context) string {
+	scheme := "http"
+	if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + c.Request.Host + "/scim/v2/Users"
+}
+
+func scimInt(c *gin.Context, name string, defaultValue int) int {
+	n, err := strconv.Atoi(c.Query(name))
+	if err != nil {
+		return defaultValue
+	}
+	return n
+}
+
+func scimJSON(c *gin.Context, status int, body interface{}) {
+	c.Header("Content-Type", scim.ContentType)
+	c.JSON(status, body)
+}
+
+func scimError(c *gin.Context, status int, scimType, detail string) {
+	scimJSON(c, status, scim.NewError(status, scimType, detail))
+}
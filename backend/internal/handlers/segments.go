@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"pygorp/backend/internal/clock"
+	"pygorp/backend/internal/models"
+	"pygorp/backend/internal/render"
+	"pygorp/backend/internal/repository"
+	"pygorp/backend/internal/segments"
+	"pygorp/backend/internal/sqlb"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Default and largest number of sample users in a segment preview.
+const (
+	defaultSegmentSample = 10
+	maxSegmentSample     = 50
+)
+
+// PreviewSegment counts the users in the segment given as q and returns
+// the newest of them as a sample, so a segment can be checked before it is
+// used to target anyone.
+func PreviewSegment(c *gin.Context) {
+	where, err := segments.Compile(c.Query("q"), clock.Now())
+	if err != nil {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": "Invalid segment: " + err.Error()})
+		return
+	}
+	sample := defaultSegmentSample
+	if raw := c.Query("sample"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 || n > maxSegmentSample {
+			render.JSON(c, http.StatusBadRequest, gin.H{"error": "sample must be between 0 and " + strconv.Itoa(maxSegmentSample)})
+			return
+		}
+		sample = n
+	}
+
+	ctx := c.Request.Context()
+	count, err := repository.CountUsersWhere(ctx, where)
+	if err != nil {
+		c.Error(err)
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to count users"})
+		return
+	}
+	users := []models.User{}
+	if sample > 0 && count > 0 {
+		// A limit of 0 would mean no limit.
+		users, err = repository.ListUsersWhere(ctx, where, sqlb.Page{Limit: sample})
+		if err != nil {
+			c.Error(err)
+			render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to fetch users"})
+			return
+		}
+	}
+
+	render.JSON(c, http.StatusOK, gin.H{"data": gin.H{"count": count, "sample": users}})
+}
@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"pygorp/backend/internal/models"
+	"pygorp/backend/internal/render"
+	"pygorp/backend/internal/repository"
+	"pygorp/backend/internal/sqlb"
+
+	"github.com/gin-gonic/gin"
+)
+
+func ListServiceAccounts(c *gin.Context) {
+	limit, offset, ok := parsePage(c)
+	if !ok {
+		return
+	}
+
+	accounts, err := repository.ListServiceAccounts(c.Request.Context(), sqlb.Page{Limit: limit, Offset: offset})
+	if err != nil {
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to fetch service accounts"})
+		return
+	}
+
+	render.JSON(c, http.StatusOK, gin.H{"data": accounts})
+}
+
+func GetServiceAccount(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "Invalid service account ID")
+	if !ok {
+		return
+	}
+
+	account, err := repository.GetServiceAccount(c.Request.Context(), id)
+	if !serviceAccountOK(c, err, "Failed to fetch service account") {
+		return
+	}
+
+	render.JSON(c, http.StatusOK, gin.H{"data": account})
+}
+
+func CreateServiceAccount(c *gin.Context) {
+	var req models.CreateServiceAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !checkName(c, "name", &req.Name) || !checkBlocked(c, "name", req.Name) {
+		return
+	}
+
+	account, err := repository.CreateServiceAccount(c.Request.Context(), req)
+	if !serviceAccountOK(c, err, "Failed to create service account") {
+		return
+	}
+
+	render.JSON(c, http.StatusCreated, gin.H{"data": account})
+}
+
+// UpdateServiceAccount changes a service account's description or scopes.
+// New scopes apply to existing keys immediately.
+func UpdateServiceAccount(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "Invalid service account ID")
+	if !ok {
+		return
+	}
+
+	var req models.UpdateServiceAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	account, err := repository.UpdateServiceAccount(c.Request.Context(), id, req)
+	if !serviceAccountOK(c, err, "Failed to update service account") {
+		return
+	}
+
+	render.JSON(c, http.StatusOK, gin.H{"data": account})
+}
+
+// DisableServiceAccount rejects the account's keys until it is enabled
+// again.
+func DisableServiceAccount(c *gin.Context) {
+	setServiceAccountDisabled(c, true)
+}
+
+func EnableServiceAccount(c *gin.Context) {
+	setServiceAccountDisabled(c, false)
+}
+
+func setServiceAccountDisabled(c *gin.Context, disabled bool) {
+	id, ok := parseIDParam(c, "id", "Invalid service account ID")
+	if !ok {
+		return
+	}
+
+	account, err := repository.SetServiceAccountDisabled(c.Request.Context(), id, disabled)
+	if !serviceAccountOK(c, err, "Failed to update service account") {
+		return
+	}
+
+	render.JSON(c, http.StatusOK, gin.H{"data": account})
+}
+
+func DeleteServiceAccount(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "Invalid service account ID")
+	if !ok {
+		return
+	}
+
+	err := repository.DeleteServiceAccount(c.Request.Context(), id)
+	if !serviceAccountOK(c, err, "Failed to delete service account") {
+		return
+	}
+
+	render.JSON(c, http.StatusOK, gin.H{"message": "Service account deleted successfully"})
+}
+
+func ListAPIKeys(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "Invalid service account ID")
+	if !ok {
+		return
+	}
+
+	_, err := repository.GetServiceAccount(c.Request.Context(), id)
+	if !serviceAccountOK(c, err, "Failed to fetch API keys") {
+		return
+	}
+	keys, err := repository.ListAPIKeys(c.Request.Context(), id)
+	if err != nil {
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to fetch API keys"})
+		return
+	}
+
+	render.JSON(c, http.StatusOK, gin.H{"data": keys})
+}
+
+// CreateAPIKey issues a key for a service account. The key is only shown in
+// this response.
+func CreateAPIKey(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "Invalid service account ID")
+	if !ok {
+		return
+	}
+
+	var req models.CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	_, err := repository.GetServiceAccount(c.Request.Context(), id)
+	if !serviceAccountOK(c, err, "Failed to create API key") {
+		return
+	}
+	key, err := repository.CreateAPIKey(c.Request.Context(), id, req)
+	if err != nil {
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to create API key"})
+		return
+	}
+
+	render.JSON(c, http.StatusCreated, gin.H{"data": key})
+}
+
+func RevokeAPIKey(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "Invalid service account ID")
+	if !ok {
+		return
+	}
+	keyID, ok := parseIDParam(c, "key_id", "Invalid API key ID")
+	if !ok {
+		return
+	}
+
+	err := repository.RevokeAPIKey(c.Request.Context(), id, keyID)
+	if errors.Is(err, repository.ErrNotFound) {
+		render.JSON(c, http.StatusNotFound, gin.H{"error": "API key not found"})
+		return
+	}
+	if err != nil {
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to revoke API key"})
+		return
+	}
+
+	render.JSON(c, http.StatusOK, gin.H{"message": "API key revoked successfully"})
+}
+
+// serviceAccountOK writes the response for a failed service account lookup
+// or change and reports whether err was nil.
+func serviceAccountOK(c *gin.Context, err error, message string) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, repository.ErrNotFound):
+		render.JSON(c, http.StatusNotFound, gin.H{"error": "Service account not found"})
+	case errors.Is(err, repository.ErrServiceAccountExists):
+		render.JSON(c, http.StatusConflict, gin.H{"error": "Service account name already exists"})
+	default:
+		c.Error(err)
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": message})
+	}
+	return false
+}
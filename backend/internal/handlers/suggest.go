@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"pygorp/backend/internal/models"
+	"pygorp/backend/internal/render"
+	"pygorp/backend/internal/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Suggestion limits. Queries shorter than minSuggestQuery match too many
+// users to be useful and return nothing.
+const (
+	defaultSuggestLimit = 10
+	maxSuggestLimit     = 20
+	minSuggestQuery     = 2
+	maxSuggestQuery     = 100
+)
+
+// SuggestUsers serves typeahead pickers: users whose name, a word of the
+// name, or email starts with q.
+func SuggestUsers(c *gin.Context) {
+	q := strings.TrimSpace(c.Query("q"))
+	if utf8.RuneCountInString(q) > maxSuggestQuery {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": "q must be at most 100 characters"})
+		return
+	}
+	limit := defaultSuggestLimit
+	if raw := c.Query("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxSuggestLimit {
+			render.JSON(c, http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 20"})
+			return
+		}
+		limit = n
+	}
+	if utf8.RuneCountInString(q) < minSuggestQuery {
+		render.JSON(c, http.StatusOK, gin.H{"data": []models.UserSuggestion{}})
+		return
+	}
+
+	suggestions, err := repository.SuggestUsers(c.Request.Context(), q, limit)
+	if err != nil {
+		c.Error(err)
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to fetch suggestions"})
+		return
+	}
+	render.JSON(c, http.StatusOK, gin.H{"data": suggestions})
+}
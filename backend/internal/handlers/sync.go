@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"pygorp/backend/internal/render"
+	"pygorp/backend/internal/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// syncBatchSize caps the events folded into one sync response.
+const syncBatchSize = 1000
+
+// SyncUsers returns user changes since the since cursor for offline-first
+// clients. Without a cursor it returns every user. Clients store next_cursor
+// and call again while has_more is set.
+func SyncUsers(c *gin.Context) {
+	var delta *repository.UserDelta
+	var err error
+	if raw := c.Query("since"); raw == "" {
+		delta, err = repository.UserSnapshot(c.Request.Context())
+	} else {
+		seq, ok := decodeSyncCursor(raw)
+		if !ok {
+			render.JSON(c, http.StatusBadRequest, gin.H{"error": "Invalid sync cursor"})
+			return
+		}
+		delta, err = repository.UserChanges(c.Request.Context(), seq, syncBatchSize)
+	}
+	if err != nil {
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to compute changes"})
+		return
+	}
+
+	render.JSON(c, http.StatusOK, gin.H{
+		"data":        gin.H{"users": delta},
+		"next_cursor": encodeSyncCursor(delta.Cursor),
+		"has_more":    delta.HasMore,
+	})
+}
+
+// Cursors are opaque to clients so the encoding can change later.
+func encodeSyncCursor(seq int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("v1:" + strconv.FormatInt(seq, 10)))
+}
+
+func decodeSyncCursor(cursor string) (int64, bool) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, false
+	}
+	value, ok := strings.CutPrefix(string(raw), "v1:")
+	if !ok {
+		return 0, false
+	}
+	seq, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || seq < 0 {
+		return 0, false
+	}
+	return seq, true
+}
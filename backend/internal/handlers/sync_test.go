@@ -0,0 +1,20 @@
+package handlers
+
+import "testing"
+
+// FuzzSyncCursor decodes client-supplied cursors and checks that any cursor
+// accepted re-encodes to one naming the same position.
+func FuzzSyncCursor(f *testing.F) {
+	f.Fuzz(func(t *testing.T, cursor string) {
+		seq, ok := decodeSyncCursor(cursor)
+		if !ok {
+			return
+		}
+		if seq < 0 {
+			t.Fatalf("cursor %q decoded to %d", cursor, seq)
+		}
+		if again, ok := decodeSyncCursor(encodeSyncCursor(seq)); !ok || again != seq {
+			t.Fatalf("cursor for %d decoded to %d, %v", seq, again, ok)
+		}
+	})
+}
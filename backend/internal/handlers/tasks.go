@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"net/http"
+
+	"pygorp/backend/internal/models"
+	"pygorp/backend/internal/render"
+	"pygorp/backend/internal/tasks"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateTask offloads a compute task to the Python worker. The response is
+// an operation to poll for progress and the result.
+func CreateTask(c *gin.Context) {
+	var req models.CreateTaskRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	params := tasks.Params{Kind: req.Kind, Input: req.Input, TimeoutSeconds: req.TimeoutSeconds}
+	if err := tasks.Validate(params.Kind, params.Input); err != nil {
+		render.JSON(c, http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+
+	startOperation(c, tasks.OperationType, params)
+}
@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"pygorp/backend/internal/models"
+	"pygorp/backend/internal/render"
+	"pygorp/backend/internal/repository"
+	"pygorp/backend/internal/sqlb"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListTeams returns a page of the org's teams to any of its members.
+func ListTeams(c *gin.Context) {
+	orgID, _, _, ok := orgAccess(c)
+	if !ok {
+		return
+	}
+	limit, offset, ok := parsePage(c)
+	if !ok {
+		return
+	}
+
+	teams, err := repository.ListTeams(c.Request.Context(), orgID, sqlb.Page{Limit: limit, Offset: offset})
+	if err != nil {
+		c.Error(err)
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to fetch teams"})
+		return
+	}
+
+	render.JSON(c, http.StatusOK, gin.H{"data": teams})
+}
+
+func GetTeam(c *gin.Context) {
+	orgID, _, _, ok := orgAccess(c)
+	if !ok {
+		return
+	}
+	teamID, ok := parseIDParam(c, "team_id", "Invalid team ID")
+	if !ok {
+		return
+	}
+
+	team, err := repository.GetTeam(c.Request.Context(), orgID, teamID)
+	if !teamOK(c, err, "Failed to fetch team") {
+		return
+	}
+
+	render.JSON(c, http.StatusOK, gin.H{"data": team})
+}
+
+// CreateTeam adds a team to the org. Only owners and admins manage teams.
+func CreateTeam(c *gin.Context) {
+	orgID, _, _, ok := orgAccess(c, models.OrgRoleOwner, models.OrgRoleAdmin)
+	if !ok {
+		return
+	}
+	var req models.CreateTeamRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !checkName(c, "name", &req.Name) || !checkBlocked(c, "name", req.Name) {
+		return
+	}
+
+	team, err := repository.CreateTeam(c.Request.Context(), orgID, req)
+	if !teamOK(c, err, "Failed to create team") {
+		return
+	}
+
+	render.JSON(c, http.StatusCreated, gin.H{"data": team})
+}
+
+func UpdateTeam(c *gin.Context) {
+	orgID, _, _, ok := orgAccess(c, models.OrgRoleOwner, models.OrgRoleAdmin)
+	if !ok {
+		return
+	}
+	teamID, ok := parseIDParam(c, "team_id", "Invalid team ID")
+	if !ok {
+		return
+	}
+	var req models.UpdateTeamRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !checkName(c, "name", &req.Name) || !checkBlocked(c, "name", req.Name) {
+		return
+	}
+
+	team, err := repository.UpdateTeam(c.Request.Context(), orgID, teamID, req)
+	if !teamOK(c, err, "Failed to update team") {
+		return
+	}
+
+	render.JSON(c, http.StatusOK, gin.H{"data": team})
+}
+
+// DeleteTeam removes a team and its memberships. A team that still has
+// projects answers 409 unless ?delete_projects=true is given, in which case
+// its projects are deleted with it.
+func DeleteTeam(c *gin.Context) {
+	orgID, _, _, ok := orgAccess(c, models.OrgRoleOwner, models.OrgRoleAdmin)
+	if !ok {
+		return
+	}
+	teamID, ok := parseIDParam(c, "team_id", "Invalid team ID")
+	if !ok {
+		return
+	}
+	deleteProjects := c.Query("delete_projects") == "true"
+
+	projects, err := repository.DeleteTeam(c.Request.Context(), orgID, teamID, deleteProjects)
+	if errors.Is(err, repository.ErrTeamHasProjects) {
+		render.JSON(c, http.StatusConflict, gin.H{
+			"error":    "Team still has projects; move them or pass delete_projects=true",
+			"projects": projects,
+		})
+		return
+	}
+	if !teamOK(c, err, "Failed to delete team") {
+		return
+	}
+
+	render.JSON(c, http.StatusOK, gin.H{"message": "Team deleted successfully", "projects_deleted": projects})
+	recordChange(c, "org.team.deleted", gin.H{"org_id": orgID, "team_id": teamID, "projects_deleted": projects})
+}
+
+// AddTeamMembers adds members of the org to a team. The response lists the
+// IDs that were newly added; existing members and users outside the org are
+// skipped.
+func AddTeamMembers(c *gin.Context) {
+	orgID, _, _, ok := orgAccess(c, models.OrgRoleOwner, models.OrgRoleAdmin)
+	if !ok {
+		return
+	}
+	teamID, ok := parseIDParam(c, "team_id", "Invalid team ID")
+	if !ok {
+		return
+	}
+	var req models.AddTeamMembersRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctx := c.Request.Context()
+
+	_, err := repository.GetTeam(ctx, orgID, teamID)
+	if !teamOK(c, err, "Failed to add team members") {
+		return
+	}
+	added, err := repository.AddTeamMembers(ctx, orgID, teamID, dedupeIDs(req.UserIDs))
+	if err != nil {
+		c.Error(err)
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to add team members"})
+		return
+	}
+
+	render.JSON(c, http.StatusOK, gin.H{"data": gin.H{"added": added}})
+}
+
+// RemoveTeamMember takes a user out of a team. Owners and admins remove
+// anyone; members can leave.
+func RemoveTeamMember(c *gin.Context) {
+	orgID, userID, role, ok := orgAccess(c)
+	if !ok {
+		return
+	}
+	teamID, ok := parseIDParam(c, "team_id", "Invalid team ID")
+	if !ok {
+		return
+	}
+	memberID, ok := parseIDParam(c, "user_id", "Invalid user ID")
+	if !ok {
+		return
+	}
+	if memberID != userID && role == models.OrgRoleMember {
+		render.JSON(c, http.StatusForbidden, gin.H{"error": "Your org role does not allow this"})
+		return
+	}
+
+	err := repository.RemoveTeamMember(c.Request.Context(), orgID, teamID, memberID)
+	if errors.Is(err, repository.ErrNotFound) {
+		render.JSON(c, http.StatusNotFound, gin.H{"error": "Membership not found"})
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to remove team member"})
+		return
+	}
+
+	render.JSON(c, http.StatusOK, gin.H{"message": "Team member removed successfully"})
+}
+
+// teamOK writes the response for a failed team lookup or change and
+// reports whether err was nil.
+func teamOK(c *gin.Context, err error, message string) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, repository.ErrNotFound):
+		render.JSON(c, http.StatusNotFound, gin.H{"error": "Team not found"})
+	case errors.Is(err, repository.ErrTeamExists):
+		render.JSON(c, http.StatusConflict, gin.H{"error": "Team name already exists"})
+	default:
+		c.Error(err)
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": message})
+	}
+	return false
+}
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"net/http"
+
+	"pygorp/backend/internal/render"
+	"pygorp/backend/internal/templates"
+
+	"github.com/gin-gonic/gin"
+)
+
+func ListEmailTemplates(c *gin.Context) {
+	render.JSON(c, http.StatusOK, gin.H{"data": templates.Names()})
+}
+
+// PreviewEmailTemplate renders a template with sample data. Use ?format=html
+// or ?format=text to view one part directly in the browser.
+func PreviewEmailTemplate(c *gin.Context) {
+	email, err := templates.Render(c.Param("name"), templates.Data{
+		Name:      "Jane Doe",
+		Link:      "https://example.com/action?token=sample",
+		ExpiresIn: "24 hours",
+		Org:       "Acme",
+		InvitedBy: "John Smith",
+		Author:    "John Smith",
+		Project:   "Website redesign",
+		Excerpt:   "@user:1 can you review the new homepage copy?",
+		Subject:   "Projects now support attachments",
+		Message:   "You can now attach files to your projects.\nOpen a project to try it.",
+		Password:  "sample-password",
+	})
+	if err != nil {
+		render.JSON(c, http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	switch c.Query("format") {
+	case "html":
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(email.HTML))
+	case "text":
+		c.String(http.StatusOK, email.Text)
+	default:
+		render.JSON(c, http.StatusOK, gin.H{"data": email})
+	}
+}
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"pygorp/backend/internal/models"
+	"pygorp/backend/internal/render"
+	"pygorp/backend/internal/repository"
+	"pygorp/backend/internal/sqlb"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListTrash lists a page of deleted users and projects that can still be
+// restored, most recently deleted first, optionally only those of ?type=
+// (user or project).
+func ListTrash(c *gin.Context) {
+	kind := c.Query("type")
+	if kind != "" && kind != models.TrashUser && kind != models.TrashProject {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": "type must be user or project"})
+		return
+	}
+	limit, offset, ok := parsePage(c)
+	if !ok {
+		return
+	}
+
+	items, err := repository.ListTrash(c.Request.Context(), kind, sqlb.Page{Limit: limit, Offset: offset})
+	if err != nil {
+		c.Error(err)
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to fetch the trash"})
+		return
+	}
+	render.JSON(c, http.StatusOK, gin.H{"data": items})
+}
+
+// RestoreTrash takes users and projects out of the trash and reports the
+// outcome per ID.
+func RestoreTrash(c *gin.Context) {
+	req, ok := bindTrashRequest(c)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+	render.JSON(c, http.StatusOK, gin.H{"data": gin.H{
+		"users":    bulkReport(repository.RestoreUsers(ctx, req.Users)),
+		"projects": bulkReport(repository.RestoreProjects(ctx, req.Projects)),
+	}})
+}
+
+// PurgeTrash deletes users and projects in the trash for good, without
+// waiting for their grace period to end, and reports the outcome per ID.
+func PurgeTrash(c *gin.Context) {
+	req, ok := bindTrashRequest(c)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+	render.JSON(c, http.StatusOK, gin.H{"data": gin.H{
+		"users":    bulkReport(repository.PurgeUsers(ctx, req.Users)),
+		"projects": bulkReport(repository.PurgeProjects(ctx, req.Projects)),
+	}})
+}
+
+func bindTrashRequest(c *gin.Context) (models.TrashRequest, bool) {
+	var req models.TrashRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return req, false
+	}
+	req.Users, req.Projects = dedupeIDs(req.Users), dedupeIDs(req.Projects)
+	if len(req.Users)+len(req.Projects) == 0 {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": "users or projects is required"})
+		return req, false
+	}
+	if len(req.Users)+len(req.Projects) > maxBulkItems {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": fmt.Sprintf("At most %d items can be changed per request", maxBulkItems)})
+		return req, false
+	}
+	return req, true
+}
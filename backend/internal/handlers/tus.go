@@ -0,0 +1,318 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"pygorp/backend/internal/attachments"
+	"pygorp/backend/internal/models"
+	"pygorp/backend/internal/operations"
+	"pygorp/backend/internal/policy"
+	"pygorp/backend/internal/render"
+	"pygorp/backend/internal/repository"
+	"pygorp/backend/internal/tus"
+
+	"github.com/gin-gonic/gin"
+)
+
+// tusPath is where resumable uploads are addressed once created.
+const tusPath = "/api/v1/uploads/tus/"
+
+// TusOptions describes the tus protocol support for clients that ask.
+func TusOptions(c *gin.Context) {
+	c.Header("Tus-Resumable", tus.Version)
+	c.Header("Tus-Version", tus.Version)
+	c.Header("Tus-Extension", tus.Extensions)
+	c.Header("Tus-Max-Size", strconv.FormatInt(max(attachments.MaxBytes(), maxImportBytes), 10))
+	c.Status(http.StatusNoContent)
+}
+
+// CreateAttachmentUpload starts a resumable upload of a project
+// attachment. The filetype in Upload-Metadata, when given, is checked
+// against ATTACHMENT_TYPES up front; the assembled file is checked again.
+func CreateAttachmentUpload(c *gin.Context) {
+	if !tusResumableOK(c) {
+		return
+	}
+	project, userID, _, ok := projectAccess(c, policy.PermissionWrite)
+	if !ok {
+		return
+	}
+	createResumableUpload(c, models.ResumableUpload{
+		Target:       models.UploadTargetAttachment,
+		ResourceType: models.ProjectResource,
+		ResourceID:   project.ID,
+		CreatedBy:    userID,
+	}, attachments.MaxBytes())
+}
+
+// CreateImportUpload starts a resumable upload of a user import CSV, which
+// is imported like one sent to ImportUsers once it is complete.
+func CreateImportUpload(c *gin.Context) {
+	if !tusResumableOK(c) {
+		return
+	}
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	createResumableUpload(c, models.ResumableUpload{
+		Target:    models.UploadTargetUserImport,
+		CreatedBy: userID,
+	}, maxImportBytes)
+}
+
+// HeadUpload reports how much of an upload the server has, so the client
+// can resume from there.
+func HeadUpload(c *gin.Context) {
+	u, ok := resumableUpload(c)
+	if !ok {
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Header("Upload-Length", strconv.FormatInt(u.Length, 10))
+	if u.Metadata != "" {
+		c.Header("Upload-Metadata", u.Metadata)
+	}
+	writeUploadHeaders(c, u)
+	c.Status(http.StatusOK)
+}
+
+// PatchUpload appends a chunk to an upload. The chunk that completes it
+// hands the file to its target, and the response's Upload-Result header
+// is the path of what was created. If that fails, an empty PATCH at the
+// final offset tries again.
+func PatchUpload(c *gin.Context) {
+	u, ok := resumableUpload(c)
+	if !ok {
+		return
+	}
+	if c.ContentType() != "application/offset+octet-stream" {
+		render.JSON(c, http.StatusUnsupportedMediaType, gin.H{"error": "Content-Type must be application/offset+octet-stream"})
+		return
+	}
+	offset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": "Upload-Offset must be a non-negative integer"})
+		return
+	}
+	if u.CompletedAt != nil {
+		if offset != u.Offset {
+			render.JSON(c, http.StatusConflict, gin.H{"error": tus.ErrOffsetMismatch.Error()})
+			return
+		}
+		writeUploadHeaders(c, u)
+		c.Status(http.StatusNoContent)
+		return
+	}
+
+	u, err = tus.Append(c.Request.Context(), u, offset, c.Request.Body)
+	switch {
+	case errors.Is(err, tus.ErrOffsetMismatch):
+		render.JSON(c, http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, tus.ErrTooLong):
+		render.JSON(c, http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.Error(err)
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to store chunk"})
+		return
+	}
+
+	if u.Offset == u.Length {
+		if u, ok = finishResumableUpload(c, u); !ok {
+			return
+		}
+	}
+	writeUploadHeaders(c, u)
+	c.Status(http.StatusNoContent)
+}
+
+// DeleteUpload abandons an upload, deleting the chunks received so far.
+func DeleteUpload(c *gin.Context) {
+	if !tusResumableOK(c) {
+		return
+	}
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	err := repository.DeleteResumableUpload(c.Request.Context(), c.Param("upload_id"), userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		render.JSON(c, http.StatusNotFound, gin.H{"error": "Upload not found"})
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to delete upload"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// createResumableUpload reads the creation headers into u and records it,
+// answering with its Location.
+func createResumableUpload(c *gin.Context, u models.ResumableUpload, maxBytes int64) {
+	length, err := strconv.ParseInt(c.GetHeader("Upload-Length"), 10, 64)
+	if err != nil || length < 1 {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": "Upload-Length must be a positive integer"})
+		return
+	}
+	if length > maxBytes {
+		render.JSON(c, http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("Upload must be at most %d bytes", maxBytes)})
+		return
+	}
+	metadata, err := tus.ParseMetadata(c.GetHeader("Upload-Metadata"))
+	if err != nil {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if filetype := metadata["filetype"]; filetype != "" && u.Target == models.UploadTargetAttachment {
+		if err := attachments.CheckType(filetype); err != nil {
+			render.JSON(c, http.StatusUnsupportedMediaType, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	u.Length = length
+	u.Metadata = c.GetHeader("Upload-Metadata")
+	u.Filename = attachments.CleanFilename(metadata["filename"])
+
+	u, err = tus.Create(c.Request.Context(), u)
+	if err != nil {
+		c.Error(err)
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to create upload"})
+		return
+	}
+
+	c.Header("Location", tusPath+u.ID)
+	writeUploadHeaders(c, u)
+	c.Status(http.StatusCreated)
+}
+
+// resumableUpload loads the caller's upload in the path after checking the
+// protocol version.
+func resumableUpload(c *gin.Context) (models.ResumableUpload, bool) {
+	if !tusResumableOK(c) {
+		return models.ResumableUpload{}, false
+	}
+	userID, ok := currentUserID(c)
+	if !ok {
+		return models.ResumableUpload{}, false
+	}
+
+	u, err := repository.GetResumableUpload(c.Request.Context(), c.Param("upload_id"), userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		render.JSON(c, http.StatusNotFound, gin.H{"error": "Upload not found"})
+		return u, false
+	}
+	if err != nil {
+		c.Error(err)
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to fetch upload"})
+		return u, false
+	}
+	return u, true
+}
+
+// finishResumableUpload hands a fully received upload to its target,
+// writing the error response and leaving it to be tried again if that
+// fails.
+func finishResumableUpload(c *gin.Context, u models.ResumableUpload) (models.ResumableUpload, bool) {
+	ctx := c.Request.Context()
+	u, err := repository.ClaimResumableUpload(ctx, u.ID)
+	if errors.Is(err, repository.ErrNotFound) {
+		render.JSON(c, http.StatusConflict, gin.H{"error": "The upload is already being completed"})
+		return u, false
+	}
+	if err != nil {
+		c.Error(err)
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to complete upload"})
+		return u, false
+	}
+	finished := false
+	defer func() {
+		if finished {
+			return
+		}
+		if err := repository.ReleaseResumableUpload(ctx, u.ID); err != nil {
+			c.Error(err)
+		}
+	}()
+
+	data, err := tus.Assemble(ctx, u)
+	if err != nil {
+		c.Error(err)
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to assemble upload"})
+		return u, false
+	}
+	if !scanUpload(c, data) {
+		return u, false
+	}
+
+	var result string
+	switch u.Target {
+	case models.UploadTargetAttachment:
+		orgID, err := repository.ProjectOrg(ctx, u.ResourceID)
+		if !projectOK(c, err, "Failed to complete upload") {
+			return u, false
+		}
+		project, _, _, ok := projectAccessTo(c, orgID, u.ResourceID, policy.PermissionWrite)
+		if !ok {
+			return u, false
+		}
+		attachment, err := attachments.Upload(ctx, models.ProjectResource, project.ID, u.CreatedBy, u.Filename, data)
+		if !attachmentPolicyOK(c, err, "Failed to complete upload") {
+			return u, false
+		}
+		result = fmt.Sprintf("/api/v1/orgs/%d/projects/%d/attachments/%d", orgID, project.ID, attachment.ID)
+	case models.UploadTargetUserImport:
+		op, err := operations.Start(ctx, operations.TypeUserImport, operationOwner(c), operations.ImportParams{CSV: string(data)})
+		if err != nil {
+			c.Error(err)
+			render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to start operation"})
+			return u, false
+		}
+		result = "/api/v1/operations/" + op.ID
+	default:
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Unknown upload target"})
+		return u, false
+	}
+	finished = true
+
+	// The target is done either way; a failure here only leaves the chunks
+	// until the upload expires.
+	finishedUpload, err := repository.FinishResumableUpload(ctx, u.ID, result)
+	if err != nil {
+		log.Printf("Failed to finish resumable upload %s: %v", u.ID, err)
+		u.Result = result
+		return u, true
+	}
+	return finishedUpload, true
+}
+
+// tusResumableOK sets Tus-Resumable on the response and rejects requests
+// for another protocol version with 412.
+func tusResumableOK(c *gin.Context) bool {
+	c.Header("Tus-Resumable", tus.Version)
+	if c.GetHeader("Tus-Resumable") != tus.Version {
+		c.Header("Tus-Version", tus.Version)
+		render.JSON(c, http.StatusPreconditionFailed, gin.H{"error": "Tus-Resumable must be " + tus.Version})
+		return false
+	}
+	return true
+}
+
+// writeUploadHeaders sets the headers describing an upload's progress.
+func writeUploadHeaders(c *gin.Context, u models.ResumableUpload) {
+	c.Header("Upload-Offset", strconv.FormatInt(u.Offset, 10))
+	c.Header("Upload-Expires", u.ExpiresAt.UTC().Format(http.TimeFormat))
+	if u.Result != "" {
+		c.Header("Upload-Result", u.Result)
+	}
+}
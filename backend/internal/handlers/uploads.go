@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"pygorp/backend/internal/attachments"
+	"pygorp/backend/internal/models"
+	"pygorp/backend/internal/policy"
+	"pygorp/backend/internal/render"
+	"pygorp/backend/internal/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PresignUpload starts an upload of a project attachment that the client
+// sends straight to object storage, so large bodies never pass through the
+// API. The response's url takes one PUT of the file with the declared
+// Content-Type until expires_at; CompleteUpload then attaches it.
+func PresignUpload(c *gin.Context) {
+	var req models.PresignUploadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	project, userID, _, ok := projectAccessTo(c, req.OrgID, req.ProjectID, policy.PermissionWrite)
+	if !ok {
+		return
+	}
+
+	upload, err := attachments.Presign(c.Request.Context(), models.ProjectResource, project.ID, userID, req.Filename, req.ContentType, req.Size)
+	if errors.Is(err, attachments.ErrPresignUnsupported) {
+		render.JSON(c, http.StatusNotImplemented, gin.H{"error": "Direct uploads are not available with this storage driver"})
+		return
+	}
+	if !attachmentPolicyOK(c, err, "Failed to start upload") {
+		return
+	}
+
+	render.JSON(c, http.StatusCreated, gin.H{"data": upload})
+}
+
+// CompleteUpload checks the file the caller put at a pre-signed URL and
+// attaches it. Access to the project is checked again, and the file goes
+// through the same size, type, and scanning policy as a multipart upload.
+// If it fails, the client can PUT the file again and retry until the URL
+// expires.
+func CompleteUpload(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+
+	upload, err := repository.ClaimPresignedUpload(ctx, c.Param("upload_id"), userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		render.JSON(c, http.StatusNotFound, gin.H{"error": "Upload not found, expired, or already completed"})
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to complete upload"})
+		return
+	}
+	completed := false
+	defer func() {
+		if completed {
+			return
+		}
+		if err := repository.ReleasePresignedUpload(ctx, upload.ID); err != nil {
+			c.Error(err)
+		}
+	}()
+
+	orgID, err := repository.ProjectOrg(ctx, upload.ResourceID)
+	if !projectOK(c, err, "Failed to complete upload") {
+		return
+	}
+	project, _, _, ok := projectAccessTo(c, orgID, upload.ResourceID, policy.PermissionWrite)
+	if !ok {
+		return
+	}
+
+	data, err := attachments.Fetch(ctx, upload)
+	if errors.Is(err, attachments.ErrNotUploaded) {
+		render.JSON(c, http.StatusConflict, gin.H{"error": "The file has not been uploaded yet"})
+		return
+	}
+	if !attachmentPolicyOK(c, err, "Failed to complete upload") {
+		return
+	}
+	if !scanUpload(c, data) {
+		return
+	}
+
+	attachment, err := attachments.Upload(ctx, models.ProjectResource, project.ID, userID, upload.Filename, data)
+	if !attachmentPolicyOK(c, err, "Failed to complete upload") {
+		return
+	}
+	completed = true
+
+	render.JSON(c, http.StatusCreated, gin.H{"data": attachment})
+}
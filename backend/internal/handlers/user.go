@@ -1,125 +1,422 @@
 package handlers
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 
+	"pygorp/backend/internal/auth"
+	"pygorp/backend/internal/config"
 	"pygorp/backend/internal/database"
+	"pygorp/backend/internal/metrics"
 	"pygorp/backend/internal/models"
+	"pygorp/backend/internal/render"
+	"pygorp/backend/internal/repository"
+	"pygorp/backend/internal/retention"
+	"pygorp/backend/internal/sqlb"
 
 	"github.com/gin-gonic/gin"
+	"golang.org/x/sync/singleflight"
 )
 
+// userReads coalesces identical concurrent reads so a burst of requests for
+// the same data results in a single database query. Shared queries detach
+// from the leader's context so its cancellation doesn't fail the followers.
+// Keys include the tenant so rows hidden by row-level security never leak
+// between tenants.
+var userReads singleflight.Group
+
 func GetUsers(c *gin.Context) {
-	rows, err := database.DB.Query("SELECT id, email, name, created_at, updated_at FROM users ORDER BY created_at DESC")
+	query, ok := userListQuery(c)
+	if !ok {
+		return
+	}
+	// Without a limit every match is returned, as before paging existed, up
+	// to the cap on rows per request. One extra row shows the cap was hit.
+	maxRows := config.Current().MaxRows
+	paged := c.Query("limit") != "" || c.Query("offset") != ""
+	if paged {
+		limit, offset, ok := parsePage(c)
+		if !ok {
+			return
+		}
+		query.Page = sqlb.Page{Limit: limit, Offset: offset}
+	} else {
+		query.Page = sqlb.Page{Limit: maxRows + 1}
+	}
+
+	attrKey, _ := json.Marshal(query.Filter.Attributes)
+	key := fmt.Sprintf("users:list:%s:%q:%q:%s:%d:%d:%q:%d:%d", database.TenantFrom(c.Request.Context()).Key(),
+		query.Filter.EmailDomain, query.Filter.NameContains, attrKey, query.Filter.GroupID, query.Filter.TeamID,
+		strings.Join(query.Sort, ","), query.Page.Limit, query.Page.Offset)
+	result, err, shared := userReads.Do(key, func() (interface{}, error) {
+		return repository.ListUsers(context.WithoutCancel(c.Request.Context()), query)
+	})
+	if shared {
+		metrics.CoalescedRequests.WithLabelValues("users.list").Inc()
+	}
+
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch users"})
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to fetch users"})
+		return
+	}
+
+	users := result.([]models.User)
+	if !paged && len(users) > maxRows {
+		tooManyRows(c, maxRows)
 		return
 	}
-	defer rows.Close()
+	render.JSON(c, http.StatusOK, gin.H{"data": users})
+}
+
+// userListQuery parses the filter and sort parameters shared by the user
+// list endpoints and responds 400 when they are invalid. Paging is left to
+// the caller.
+func userListQuery(c *gin.Context) (repository.UserQuery, bool) {
+	query := repository.UserQuery{
+		Filter: models.UserFilter{
+			EmailDomain:  c.Query("email_domain"),
+			NameContains: c.Query("name_contains"),
+		},
+	}
+	sort, err := sqlb.ParseSort(c.Query("sort"), repository.UserSortFields)
+	if err != nil {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return query, false
+	}
+	query.Sort = sort
+	attrs, err := attributeFilter(c)
+	if err != nil {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return query, false
+	}
+	query.Filter.Attributes = attrs
+	if raw := c.Query("group_id"); raw != "" {
+		id, err := strconv.Atoi(raw)
+		if err != nil || id < 1 {
+			render.JSON(c, http.StatusBadRequest, gin.H{"error": "Invalid group ID"})
+			return query, false
+		}
+		query.Filter.GroupID = id
+	}
+	teamID, ok := teamIDQuery(c)
+	if !ok {
+		return query, false
+	}
+	query.Filter.TeamID = teamID
+	return query, true
+}
+
+// teamIDQuery parses the optional team_id filter, 0 when it is absent, and
+// responds 400 when it is invalid.
+func teamIDQuery(c *gin.Context) (int, bool) {
+	raw := c.Query("team_id")
+	if raw == "" {
+		return 0, true
+	}
+	id, err := strconv.Atoi(raw)
+	if err != nil || id < 1 {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": "Invalid team ID"})
+		return 0, false
+	}
+	return id, true
+}
 
-	var users []models.User
-	for rows.Next() {
-		var user models.User
-		err := rows.Scan(&user.ID, &user.Email, &user.Name, &user.CreatedAt, &user.UpdatedAt)
+// attributeFilter collects attr.<name>=value query parameters. Only indexed
+// attributes may be used, and values are parsed into the attribute's type.
+func attributeFilter(c *gin.Context) (map[string]interface{}, error) {
+	var names []string
+	for param := range c.Request.URL.Query() {
+		if strings.HasPrefix(param, "attr.") {
+			names = append(names, strings.TrimPrefix(param, "attr."))
+		}
+	}
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	registry, err := repository.AttributeRegistry(c.Request.Context())
+	if err != nil {
+		return nil, fmt.Errorf("failed to load attribute definitions")
+	}
+	attrs := make(map[string]interface{}, len(names))
+	for _, name := range names {
+		d, ok := registry[name]
+		if !ok || !d.Indexed {
+			return nil, fmt.Errorf("cannot filter on attribute %q", name)
+		}
+		v, err := d.Parse(c.Query("attr." + name))
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan user"})
-			return
+			return nil, err
 		}
-		users = append(users, user)
+		attrs[name] = v
 	}
+	return attrs, nil
+}
 
-	c.JSON(http.StatusOK, gin.H{"data": users})
+// validateAttributes checks request attributes against the registry and
+// responds 400 when they are invalid.
+func validateAttributes(c *gin.Context, values map[string]interface{}, patch bool) bool {
+	if patch && len(values) == 0 {
+		return true
+	}
+	registry, err := repository.AttributeRegistry(c.Request.Context())
+	if err != nil {
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to load attribute definitions"})
+		return false
+	}
+	if err := registry.Validate(values, patch); err != nil {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	return true
 }
 
 func GetUser(c *gin.Context) {
 	idStr := c.Param("id")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
 		return
 	}
 
-	var user models.User
-	err = database.DB.QueryRow("SELECT id, email, name, created_at, updated_at FROM users WHERE id = $1", id).
-		Scan(&user.ID, &user.Email, &user.Name, &user.CreatedAt, &user.UpdatedAt)
+	result, err, shared := userReads.Do("users:"+database.TenantFrom(c.Request.Context()).Key()+":"+strconv.Itoa(id), func() (interface{}, error) {
+		return repository.GetUser(context.WithoutCancel(c.Request.Context()), id)
+	})
+	if shared {
+		metrics.CoalescedRequests.WithLabelValues("users.get").Inc()
+	}
 
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		render.JSON(c, http.StatusNotFound, gin.H{"error": "User not found"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"data": user})
+	setVersion(c, result.(models.User))
+	render.JSON(c, http.StatusOK, gin.H{"data": result.(models.User)})
 }
 
 func CreateUser(c *gin.Context) {
 	var req models.CreateUserRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !checkEmail(c, "email", &req.Email) || !checkName(c, "name", &req.Name) || !checkBlocked(c, "name", req.Name) {
 		return
 	}
+	if !validateAttributes(c, req.Attributes, false) {
+		return
+	}
+	flag, ok := checkDisposable(c, req.Email)
+	if !ok || !checkMX(c, req.Email) {
+		return
+	}
+
+	user, err := repository.CreateUser(c.Request.Context(), req)
+	if err != nil {
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
+		return
+	}
+	if flag {
+		flagDisposable(c, user.ID, user.Email)
+	}
 
-	var user models.User
-	err := database.DB.QueryRow(
-		"INSERT INTO users (email, name) VALUES ($1, $2) RETURNING id, email, name, created_at, updated_at",
-		req.Email, req.Name,
-	).Scan(&user.ID, &user.Email, &user.Name, &user.CreatedAt, &user.UpdatedAt)
+	setVersion(c, user)
+	render.JSON(c, http.StatusCreated, gin.H{"data": user})
+}
+
+// UserMethod dispatches custom methods on the users collection, such as
+// PUT /users:upsert. Gin cannot route a literal colon, so the method name
+// arrives in the "method" parameter with its leading colon.
+func UserMethod(c *gin.Context) {
+	switch c.Param("method") {
+	case ":upsert":
+		UpsertUser(c)
+	default:
+		render.JSON(c, http.StatusNotFound, gin.H{"error": "Unknown method"})
+	}
+}
+
+// UpsertUser creates or updates the user with the given email. It responds
+// 201 when the user was created and 200 otherwise, with "result" set to
+// created, updated, or unchanged.
+func UpsertUser(c *gin.Context) {
+	var req models.CreateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !checkEmail(c, "email", &req.Email) || !checkName(c, "name", &req.Name) || !checkBlocked(c, "name", req.Name) {
+		return
+	}
+	// Attributes replace nothing on an existing user, so they are checked
+	// as a patch there and as a full set for a new one.
+	_, err := repository.GetUserByEmail(c.Request.Context(), req.Email)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to upsert user"})
+		return
+	}
+	exists := err == nil
+	if !validateAttributes(c, req.Attributes, exists) {
+		return
+	}
+	flag := false
+	if !exists {
+		var ok bool
+		if flag, ok = checkDisposable(c, req.Email); !ok {
+			return
+		}
+	}
 
+	user, result, err := repository.UpsertUser(c.Request.Context(), req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to upsert user"})
 		return
 	}
+	if flag && result == repository.UpsertCreated {
+		flagDisposable(c, user.ID, user.Email)
+	}
 
-	c.JSON(http.StatusCreated, gin.H{"data": user})
+	status := http.StatusOK
+	if result == repository.UpsertCreated {
+		status = http.StatusCreated
+	}
+	render.JSON(c, status, gin.H{"data": user, "result": result})
 }
 
 func UpdateUser(c *gin.Context) {
 	idStr := c.Param("id")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
 		return
 	}
 
 	var req models.UpdateUserRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !checkEmail(c, "email", &req.Email) || !checkName(c, "name", &req.Name) || !checkBlocked(c, "name", req.Name) {
+		return
+	}
+	if !validateAttributes(c, req.Attributes, true) {
+		return
+	}
+
+	user, err := repository.UpdateUser(c.Request.Context(), id, req, precondition(c))
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		render.JSON(c, http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	case errors.Is(err, repository.ErrPreconditionFailed):
+		render.JSON(c, http.StatusPreconditionFailed, gin.H{"error": "User has changed since it was read"})
+		return
+	case err != nil:
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
 		return
 	}
 
-	var user models.User
-	err = database.DB.QueryRow(
-		"UPDATE users SET email = COALESCE($1, email), name = COALESCE($2, name), updated_at = NOW() WHERE id = $3 RETURNING id, email, name, created_at, updated_at",
-		req.Email, req.Name, id,
-	).Scan(&user.ID, &user.Email, &user.Name, &user.CreatedAt, &user.UpdatedAt)
+	setVersion(c, user)
+	render.JSON(c, http.StatusOK, gin.H{"data": user})
+}
 
+// MergeUser merges the user in the path into the one named by the "into"
+// query parameter and deletes it.
+func MergeUser(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "Invalid user ID")
+	if !ok {
+		return
+	}
+	into, err := strconv.Atoi(c.Query("into"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": "into must be a user ID"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"data": user})
+	result, err := repository.MergeUsers(c.Request.Context(), id, into)
+	switch {
+	case errors.Is(err, repository.ErrMergeSelf):
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": "Cannot merge a user into itself"})
+		return
+	case errors.Is(err, repository.ErrNotFound):
+		render.JSON(c, http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	case errors.Is(err, repository.ErrLegalHold):
+		render.JSON(c, http.StatusLocked, gin.H{"error": "User is under legal hold"})
+		return
+	case err != nil:
+		c.Error(err)
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to merge users"})
+		return
+	}
+
+	render.JSON(c, http.StatusOK, gin.H{"data": result})
 }
 
+// DeleteUser schedules a user for deletion after the grace period and ends
+// its sessions. The user is purged by a worker unless the deletion is
+// cancelled first. Like updates, it can be made conditional on the user's
+// version with If-Match or If-Unmodified-Since.
 func DeleteUser(c *gin.Context) {
 	idStr := c.Param("id")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
 		return
 	}
 
-	result, err := database.DB.Exec("DELETE FROM users WHERE id = $1", id)
+	user, err := repository.RequestUserDeletion(c.Request.Context(), id, callerID(c), retention.DeletionGrace(), precondition(c))
+	if errors.Is(err, repository.ErrNotFound) {
+		render.JSON(c, http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if errors.Is(err, repository.ErrPreconditionFailed) {
+		render.JSON(c, http.StatusPreconditionFailed, gin.H{"error": "User has changed since it was read"})
+		return
+	}
+	if errors.Is(err, repository.ErrLegalHold) {
+		render.JSON(c, http.StatusLocked, gin.H{"error": "User is under legal hold"})
+		return
+	}
+	if err == nil {
+		err = auth.RevokeUser(c.Request.Context(), id)
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete user"})
+		c.Error(err)
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to delete user"})
 		return
 	}
 
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	setVersion(c, user)
+	render.JSON(c, http.StatusAccepted, gin.H{"data": user, "message": "User scheduled for deletion"})
+}
+
+// CancelUserDeletion restores a user pending deletion.
+func CancelUserDeletion(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "Invalid user ID")
+	if !ok {
+		return
+	}
+
+	user, err := repository.CancelUserDeletion(c.Request.Context(), id)
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		render.JSON(c, http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	case errors.Is(err, repository.ErrNotPendingDeletion):
+		render.JSON(c, http.StatusConflict, gin.H{"error": "User is not pending deletion"})
+		return
+	case err != nil:
+		c.Error(err)
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to cancel user deletion"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "User deleted successfully"})
+	render.JSON(c, http.StatusOK, gin.H{"data": user})
 }
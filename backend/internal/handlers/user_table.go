@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"pygorp/backend/internal/attributes"
+	"pygorp/backend/internal/models"
+	"pygorp/backend/internal/render"
+	"pygorp/backend/internal/repository"
+	"pygorp/backend/internal/sqlb"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Column types in the table metadata. Attribute columns use the attribute
+// type (string, number, boolean, date, or enum).
+const (
+	columnNumber   = "number"
+	columnString   = "string"
+	columnDateTime = "datetime"
+)
+
+// tableColumn describes one column of GET /users/table. Filter names the
+// query parameter that filters on the column, if any.
+type tableColumn struct {
+	Field    string   `json:"field"`
+	Header   string   `json:"header"`
+	Type     string   `json:"type"`
+	Sortable bool     `json:"sortable"`
+	Filter   string   `json:"filter,omitempty"`
+	Options  []string `json:"options,omitempty"`
+
+	value func(u *models.User) interface{}
+}
+
+var userTableColumns = []*tableColumn{
+	{Field: "id", Header: "ID", Type: columnNumber, Sortable: true,
+		value: func(u *models.User) interface{} { return u.ID }},
+	{Field: "email", Header: "Email", Type: columnString, Sortable: true, Filter: "email_domain",
+		value: func(u *models.User) interface{} { return u.Email }},
+	{Field: "name", Header: "Name", Type: columnString, Sortable: true, Filter: "name_contains",
+		value: func(u *models.User) interface{} { return u.Name }},
+	{Field: "status", Header: "Status", Type: attributes.TypeEnum, Options: []string{"active", "pending_deletion"},
+		value: func(u *models.User) interface{} {
+			if u.Deletion != nil {
+				return "pending_deletion"
+			}
+			return "active"
+		}},
+	{Field: "created_at", Header: "Created", Type: columnDateTime, Sortable: true,
+		value: func(u *models.User) interface{} { return u.CreatedAt }},
+	{Field: "updated_at", Header: "Updated", Type: columnDateTime, Sortable: true,
+		value: func(u *models.User) interface{} { return u.UpdatedAt }},
+}
+
+var defaultUserTableColumns = []string{"id", "email", "name", "status", "created_at"}
+
+// attributeColumn describes the column for a custom attribute.
+func attributeColumn(d *attributes.Definition) *tableColumn {
+	col := &tableColumn{
+		Field:   "attr." + d.Name,
+		Header:  d.Name,
+		Type:    d.Type,
+		Options: d.Validation.Enum,
+		value:   func(u *models.User) interface{} { return u.Attributes[d.Name] },
+	}
+	if d.Description != "" {
+		col.Header = d.Description
+	}
+	if d.Indexed {
+		col.Filter = col.Field
+	}
+	return col
+}
+
+// UserTable returns a page of users shaped for data grids: column metadata,
+// flat rows with raw and formatted values, and the total row count. It takes
+// the same filter and sort parameters as GetUsers, plus columns to pick the
+// columns and tz to format times in.
+func UserTable(c *gin.Context) {
+	query, ok := userListQuery(c)
+	if !ok {
+		return
+	}
+	limit, offset, ok := parsePage(c)
+	if !ok {
+		return
+	}
+	query.Page = sqlb.Page{Limit: limit, Offset: offset}
+
+	loc := time.UTC
+	if tz := c.Query("tz"); tz != "" {
+		var err error
+		if loc, err = time.LoadLocation(tz); err != nil {
+			render.JSON(c, http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Unknown time zone %q", tz)})
+			return
+		}
+	}
+
+	registry, err := repository.AttributeRegistry(c.Request.Context())
+	if err != nil {
+		c.Error(err)
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to load attribute definitions"})
+		return
+	}
+	columns, err := selectUserTableColumns(c.Query("columns"), registry)
+	if err != nil {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	total, err := repository.CountUsers(c.Request.Context(), query.Filter)
+	if err != nil {
+		c.Error(err)
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to count users"})
+		return
+	}
+	users, err := repository.ListUsers(c.Request.Context(), query)
+	if err != nil {
+		c.Error(err)
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to fetch users"})
+		return
+	}
+
+	rows := make([]gin.H, 0, len(users))
+	for i := range users {
+		u := &users[i]
+		// Grids key rows by id, so it is present even when not a column.
+		row := gin.H{"id": u.ID}
+		formatted := make(map[string]string, len(columns))
+		for _, col := range columns {
+			v := col.value(u)
+			row[col.Field] = v
+			formatted[col.Field] = formatCell(v, loc)
+		}
+		row["formatted"] = formatted
+		rows = append(rows, row)
+	}
+
+	render.JSON(c, http.StatusOK, gin.H{"data": gin.H{
+		"columns": columns,
+		"rows":    rows,
+		"total":   total,
+		"limit":   limit,
+		"offset":  offset,
+	}})
+}
+
+// selectUserTableColumns resolves a comma-separated column list against the
+// built-in columns and attribute definitions. Empty selects the defaults.
+func selectUserTableColumns(param string, registry attributes.Registry) ([]*tableColumn, error) {
+	fields := defaultUserTableColumns
+	if param != "" {
+		fields = strings.Split(param, ",")
+	}
+
+	columns := make([]*tableColumn, 0, len(fields))
+	seen := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		field = strings.TrimSpace(field)
+		if seen[field] {
+			continue
+		}
+		seen[field] = true
+
+		col := builtinUserColumn(field)
+		if name, ok := strings.CutPrefix(field, "attr."); ok {
+			if d, ok := registry[name]; ok {
+				col = attributeColumn(d)
+			}
+		}
+		if col == nil {
+			return nil, fmt.Errorf("unknown column %q", field)
+		}
+		columns = append(columns, col)
+	}
+	return columns, nil
+}
+
+func builtinUserColumn(field string) *tableColumn {
+	for _, col := range userTableColumns {
+		if col.Field == field {
+			return col
+		}
+	}
+	return nil
+}
+
+// formatCell renders a value for display. Missing values are empty.
+func formatCell(v interface{}, loc *time.Location) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case time.Time:
+		return v.In(loc).Format("2006-01-02 15:04 MST")
+	case bool:
+		if v {
+			return "Yes"
+		}
+		return "No"
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case int:
+		return strconv.Itoa(v)
+	case string:
+		return v
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,14 @@
+package handlers
+
+import (
+	"net/http"
+
+	"pygorp/backend/internal/render"
+	"pygorp/backend/internal/version"
+
+	"github.com/gin-gonic/gin"
+)
+
+func GetVersion(c *gin.Context) {
+	render.JSON(c, http.StatusOK, gin.H{"data": version.Get()})
+}
@@ -0,0 +1,330 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"pygorp/backend/internal/auth"
+	"pygorp/backend/internal/models"
+	"pygorp/backend/internal/render"
+	"pygorp/backend/internal/repository"
+	"pygorp/backend/internal/webauthn"
+
+	"github.com/gin-gonic/gin"
+)
+
+type passkeyRegisterBeginRequest struct {
+	Email string `json:"email" binding:"required,email" sanitize:"trim,control"`
+	Name  string `json:"name" binding:"omitempty,min=2,max=100" sanitize:"trim,control,html"`
+}
+
+type passkeyRegisterFinishRequest struct {
+	ChallengeID string `json:"challenge_id" binding:"required"`
+	Credential  struct {
+		RawID    webauthn.Bytes               `json:"rawId" binding:"required"`
+		Response webauthn.AttestationResponse `json:"response" binding:"required"`
+	} `json:"credential" binding:"required"`
+	// Name labels the passkey in the credential list, e.g. "Work laptop".
+	Name string `json:"name" binding:"max=100" sanitize:"trim,control,html"`
+}
+
+type passkeyLoginBeginRequest struct {
+	Email string `json:"email" binding:"omitempty,email" sanitize:"trim,control"`
+}
+
+type passkeyLoginFinishRequest struct {
+	ChallengeID string `json:"challenge_id" binding:"required"`
+	Credential  struct {
+		RawID    webauthn.Bytes             `json:"rawId" binding:"required"`
+		Response webauthn.AssertionResponse `json:"response" binding:"required"`
+	} `json:"credential" binding:"required"`
+}
+
+// BeginPasskeyRegistration starts registering a passkey. For a new email it
+// signs up a new account when the ceremony finishes; adding a passkey to an
+// existing account requires a signed-in session of that user, not a
+// personal access token.
+func BeginPasskeyRegistration(c *gin.Context) {
+	var req passkeyRegisterBeginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !checkEmail(c, "email", &req.Email) || !checkName(c, "name", &req.Name) || !checkBlocked(c, "name", req.Name) {
+		return
+	}
+	if !auth.Enabled() {
+		render.JSON(c, http.StatusForbidden, gin.H{"error": "Authentication is disabled"})
+		return
+	}
+	ctx := c.Request.Context()
+
+	ch := webauthn.Challenge{Kind: webauthn.KindRegister, Email: req.Email, Name: req.Name}
+	var exclude []webauthn.CredentialDescriptor
+	user, err := repository.GetUserByEmail(ctx, req.Email)
+	switch {
+	case err == nil:
+		if c.GetString("auth_session") == "" || c.GetString("auth_subject") != strconv.Itoa(user.ID) {
+			render.JSON(c, http.StatusForbidden, gin.H{"error": "Sign in to add a passkey to an existing account"})
+			return
+		}
+		ch.UserID, ch.Name = &user.ID, user.Name
+		creds, err := webauthn.UserCredentials(ctx, user.ID)
+		if err != nil {
+			render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to start passkey registration"})
+			return
+		}
+		for _, cred := range creds {
+			exclude = append(exclude, cred.Descriptor())
+		}
+	case errors.Is(err, repository.ErrNotFound):
+		if _, ok := checkDisposable(c, req.Email); !ok {
+			return
+		}
+		if ch.Name == "" {
+			ch.Name = strings.SplitN(req.Email, "@", 2)[0]
+		}
+	default:
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to start passkey registration"})
+		return
+	}
+
+	ch.UserHandle, err = webauthn.UserHandle(ctx, ch.UserID)
+	if err != nil {
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to start passkey registration"})
+		return
+	}
+	stored, err := webauthn.NewChallenge(ctx, ch)
+	if err != nil {
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to start passkey registration"})
+		return
+	}
+
+	cfg := webauthn.ConfigFromEnv()
+	options := cfg.CreationOptions(stored.Challenge, webauthn.UserEntity{ID: ch.UserHandle, Name: ch.Email, DisplayName: ch.Name}, exclude)
+	render.JSON(c, http.StatusOK, gin.H{"data": gin.H{"challenge_id": stored.ID, "public_key": options}})
+}
+
+// FinishPasskeyRegistration verifies the new credential and stores it. A
+// new account is created and signed in; for an existing account only the
+// credential is returned.
+func FinishPasskeyRegistration(c *gin.Context) {
+	var req passkeyRegisterFinishRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !checkName(c, "name", &req.Name) {
+		return
+	}
+	ctx := c.Request.Context()
+
+	ch, err := webauthn.ConsumeChallenge(ctx, req.ChallengeID, webauthn.KindRegister)
+	if !passkeyChallengeOK(c, err) {
+		return
+	}
+	cred, err := webauthn.ConfigFromEnv().VerifyRegistration(ch.Challenge, req.Credential.Response)
+	if err != nil {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var user *models.User
+	userID := 0
+	if ch.UserID != nil {
+		userID = *ch.UserID
+		if c.GetString("auth_session") == "" || c.GetString("auth_subject") != strconv.Itoa(userID) {
+			render.JSON(c, http.StatusForbidden, gin.H{"error": "Sign in to add a passkey to an existing account"})
+			return
+		}
+	} else {
+		if _, err := repository.GetUserByEmail(ctx, ch.Email); err == nil {
+			render.JSON(c, http.StatusConflict, gin.H{"error": "An account with this email already exists"})
+			return
+		}
+		flag, ok := checkDisposable(c, ch.Email)
+		if !ok {
+			return
+		}
+		created, err := repository.CreateUser(ctx, models.CreateUserRequest{Email: ch.Email, Name: ch.Name})
+		if err != nil {
+			render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
+			return
+		}
+		if flag {
+			flagDisposable(c, created.ID, created.Email)
+		}
+		user, userID = &created, created.ID
+	}
+
+	stored, err := webauthn.SaveCredential(ctx, userID, ch.UserHandle, cred, req.Credential.Response.Transports, req.Name)
+	if errors.Is(err, webauthn.ErrCredentialExists) {
+		render.JSON(c, http.StatusConflict, gin.H{"error": "Passkey is already registered"})
+		return
+	}
+	if err != nil {
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to save passkey"})
+		return
+	}
+	if user == nil {
+		render.JSON(c, http.StatusCreated, gin.H{"data": gin.H{"credential": stored}})
+		return
+	}
+
+	tokens, err := auth.StartSession(ctx, userID, "webauthn", authClient(c))
+	if !authOK(c, err) {
+		return
+	}
+	render.JSON(c, http.StatusCreated, gin.H{"data": gin.H{"user": user, "credential": stored, "session": tokens}})
+}
+
+// BeginPasskeyLogin starts a sign-in. With an email only that user's
+// passkeys are offered; unknown emails get the same response as known ones.
+func BeginPasskeyLogin(c *gin.Context) {
+	var req passkeyLoginBeginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !checkEmail(c, "email", &req.Email) {
+		return
+	}
+	if !auth.Enabled() {
+		render.JSON(c, http.StatusForbidden, gin.H{"error": "Authentication is disabled"})
+		return
+	}
+	ctx := c.Request.Context()
+
+	ch := webauthn.Challenge{Kind: webauthn.KindLogin, Email: req.Email}
+	var allow []webauthn.CredentialDescriptor
+	if req.Email != "" {
+		user, err := repository.GetUserByEmail(ctx, req.Email)
+		if err != nil && !errors.Is(err, repository.ErrNotFound) {
+			render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to start passkey sign-in"})
+			return
+		}
+		if err == nil {
+			ch.UserID = &user.ID
+			creds, err := webauthn.UserCredentials(ctx, user.ID)
+			if err != nil {
+				render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to start passkey sign-in"})
+				return
+			}
+			for _, cred := range creds {
+				allow = append(allow, cred.Descriptor())
+			}
+		}
+	}
+
+	stored, err := webauthn.NewChallenge(ctx, ch)
+	if err != nil {
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to start passkey sign-in"})
+		return
+	}
+	options := webauthn.ConfigFromEnv().RequestOptions(stored.Challenge, allow)
+	render.JSON(c, http.StatusOK, gin.H{"data": gin.H{"challenge_id": stored.ID, "public_key": options}})
+}
+
+// FinishPasskeyLogin verifies the assertion and starts a session.
+func FinishPasskeyLogin(c *gin.Context) {
+	var req passkeyLoginFinishRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctx := c.Request.Context()
+
+	ch, err := webauthn.ConsumeChallenge(ctx, req.ChallengeID, webauthn.KindLogin)
+	if !passkeyChallengeOK(c, err) {
+		return
+	}
+	cred, err := webauthn.GetCredential(ctx, req.Credential.RawID)
+	if errors.Is(err, webauthn.ErrNotFound) {
+		render.JSON(c, http.StatusUnauthorized, gin.H{"error": "Unknown passkey"})
+		return
+	}
+	if err != nil {
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to verify passkey"})
+		return
+	}
+	resp := req.Credential.Response
+	if (ch.UserID != nil && *ch.UserID != cred.UserID) ||
+		(len(resp.UserHandle) > 0 && string(resp.UserHandle) != string(cred.UserHandle)) {
+		render.JSON(c, http.StatusUnauthorized, gin.H{"error": "Passkey does not belong to this user"})
+		return
+	}
+
+	signCount, err := webauthn.ConfigFromEnv().VerifyAssertion(ch.Challenge, cred.PublicKey, cred.SignCount, resp)
+	if err != nil {
+		render.JSON(c, http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	if err := webauthn.RecordUse(ctx, cred.ID, signCount); err != nil {
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to verify passkey"})
+		return
+	}
+
+	tokens, err := auth.StartSession(ctx, cred.UserID, "webauthn", authClient(c))
+	if !authOK(c, err) {
+		return
+	}
+	render.JSON(c, http.StatusOK, gin.H{"data": tokens})
+}
+
+// ListPasskeys lists the signed-in user's passkeys.
+func ListPasskeys(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	creds, err := webauthn.UserCredentials(c.Request.Context(), userID)
+	if err != nil {
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to fetch passkeys"})
+		return
+	}
+
+	render.JSON(c, http.StatusOK, gin.H{"data": creds})
+}
+
+// DeletePasskey removes one of the signed-in user's passkeys. The ID is the
+// credential ID in base64url.
+func DeletePasskey(c *gin.Context) {
+	userID, ok := sessionUserID(c, "Passkeys can only be deleted from a signed-in session")
+	if !ok {
+		return
+	}
+	id, err := base64.RawURLEncoding.DecodeString(c.Param("id"))
+	if err != nil {
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": "Invalid passkey ID"})
+		return
+	}
+
+	err = webauthn.DeleteCredential(c.Request.Context(), userID, id)
+	if errors.Is(err, webauthn.ErrNotFound) {
+		render.JSON(c, http.StatusNotFound, gin.H{"error": "Passkey not found"})
+		return
+	}
+	if err != nil {
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to delete passkey"})
+		return
+	}
+
+	render.JSON(c, http.StatusOK, gin.H{"message": "Passkey deleted successfully"})
+}
+
+func passkeyChallengeOK(c *gin.Context, err error) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, webauthn.ErrChallenge):
+		render.JSON(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.Error(err)
+		render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to verify passkey"})
+	}
+	return false
+}
@@ -0,0 +1,60 @@
+// Package idgen generates the UUIDs given to events, audit records, and
+// other application-assigned IDs. Tests install a Sequence to get
+// predictable IDs.
+package idgen
+
+import (
+	"crypto/rand"
+	"fmt"
+	"sync/atomic"
+)
+
+// Generator returns a new unique ID on each call.
+type Generator interface {
+	NewID() string
+}
+
+type random struct{}
+
+// NewID returns a random UUID (version 4).
+func (random) NewID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// Random generates version 4 UUIDs, and is used unless Set installs
+// another generator.
+var Random Generator = random{}
+
+type holder struct{ g Generator }
+
+var current atomic.Pointer[holder]
+
+func init() {
+	current.Store(&holder{Random})
+}
+
+// NewID returns an ID from the installed generator.
+func NewID() string {
+	return current.Load().g.NewID()
+}
+
+// Set installs g and returns a function that restores the previous
+// generator.
+func Set(g Generator) (restore func()) {
+	prev := current.Swap(&holder{g})
+	return func() { current.Store(prev) }
+}
+
+// Sequence generates well-formed UUIDs numbered from 1:
+// 00000000-0000-4000-8000-000000000001, then ...002, and so on.
+type Sequence struct {
+	n atomic.Uint64
+}
+
+func (s *Sequence) NewID() string {
+	return fmt.Sprintf("00000000-0000-4000-8000-%012x", s.n.Add(1))
+}
@@ -0,0 +1,156 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"pygorp/backend/internal/database"
+	"pygorp/backend/internal/sqlb"
+)
+
+var (
+	// ErrNotFound is returned when no job has the given ID.
+	ErrNotFound = errors.New("job not found")
+	// ErrInvalidState is returned when a job cannot transition as requested,
+	// e.g. cancelling a job that is already running.
+	ErrInvalidState = errors.New("job is not in a state that allows this action")
+)
+
+// Filter narrows job listings. Empty fields are ignored.
+type Filter struct {
+	Queue  string
+	Status string
+	Type   string
+	Limit  int
+	Offset int
+}
+
+// QueueStats summarizes one queue.
+type QueueStats struct {
+	Queue     string  `json:"queue"`
+	Queued    int     `json:"queued"`
+	Due       int     `json:"due"`
+	Running   int     `json:"running"`
+	Dead      int     `json:"dead"`
+	OldestDue float64 `json:"oldest_due_seconds"`
+	AvgWait   float64 `json:"avg_wait_seconds"`
+}
+
+// List returns jobs matching filter, newest first.
+func List(ctx context.Context, filter Filter) ([]Job, error) {
+	q := sqlb.Select(jobColumns).From("jobs").OrderBy("id DESC").Limit(filter.Limit).Offset(filter.Offset)
+	if filter.Queue != "" {
+		q.Where("queue = ?", filter.Queue)
+	}
+	if filter.Status != "" {
+		q.Where("status = ?", filter.Status)
+	}
+	if filter.Type != "" {
+		q.Where("type = ?", filter.Type)
+	}
+	query, args, err := q.Build()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := database.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	jobs := []Job{}
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, *job)
+	}
+	return jobs, rows.Err()
+}
+
+// Retry requeues a dead or cancelled job with a fresh set of attempts.
+func Retry(ctx context.Context, id int64) (*Job, error) {
+	return transition(ctx, id, `
+		UPDATE jobs SET status = 'queued', attempts = 0, last_error = NULL, run_at = NOW(),
+			started_at = NULL, finished_at = NULL
+		WHERE id = $1 AND status IN ('dead', 'cancelled')
+		RETURNING `+jobColumns)
+}
+
+// Cancel stops a queued job from running.
+func Cancel(ctx context.Context, id int64) (*Job, error) {
+	return transition(ctx, id, `
+		UPDATE jobs SET status = 'cancelled', finished_at = NOW()
+		WHERE id = $1 AND status = 'queued'
+		RETURNING `+jobColumns)
+}
+
+// RetryDead requeues every dead job in queue, or in all queues when queue is
+// empty, and returns how many were requeued.
+func RetryDead(ctx context.Context, queue string) (int64, error) {
+	result, err := database.DB.ExecContext(ctx, `
+		UPDATE jobs SET status = 'queued', attempts = 0, last_error = NULL, run_at = NOW(),
+			started_at = NULL, finished_at = NULL
+		WHERE status = 'dead' AND ($1 = '' OR queue = $1)`, queue)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// PurgeDead deletes dead jobs that finished before the cutoff.
+func PurgeDead(ctx context.Context, queue string, before time.Time) (int64, error) {
+	result, err := database.DB.ExecContext(ctx,
+		"DELETE FROM jobs WHERE status = 'dead' AND ($1 = '' OR queue = $1) AND finished_at < $2", queue, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// Stats returns depth and latency figures per queue. AvgWait covers jobs
+// started in the last hour.
+func Stats(ctx context.Context) ([]QueueStats, error) {
+	rows, err := database.DB.QueryContext(ctx, `
+		SELECT queue,
+			COUNT(*) FILTER (WHERE status = 'queued'),
+			COUNT(*) FILTER (WHERE status = 'queued' AND run_at <= NOW()),
+			COUNT(*) FILTER (WHERE status = 'running'),
+			COUNT(*) FILTER (WHERE status = 'dead'),
+			COALESCE(EXTRACT(EPOCH FROM NOW() - MIN(run_at) FILTER (WHERE status = 'queued' AND run_at <= NOW())), 0),
+			COALESCE(EXTRACT(EPOCH FROM AVG(started_at - run_at) FILTER (WHERE started_at > NOW() - INTERVAL '1 hour')), 0)
+		FROM jobs
+		GROUP BY queue
+		ORDER BY queue`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stats := []QueueStats{}
+	for rows.Next() {
+		var s QueueStats
+		if err := rows.Scan(&s.Queue, &s.Queued, &s.Due, &s.Running, &s.Dead, &s.OldestDue, &s.AvgWait); err != nil {
+			return nil, err
+		}
+		stats = append(stats, s)
+	}
+	return stats, rows.Err()
+}
+
+func transition(ctx context.Context, id int64, query string) (*Job, error) {
+	job, err := scanJob(database.DB.QueryRowContext(ctx, query, id))
+	if !errors.Is(err, sql.ErrNoRows) {
+		return job, err
+	}
+
+	// Distinguish a missing job from one in the wrong state.
+	if _, err := Get(ctx, id); errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return nil, ErrInvalidState
+}
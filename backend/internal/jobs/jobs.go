@@ -0,0 +1,126 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"pygorp/backend/internal/database"
+)
+
+const DefaultQueue = "default"
+
+// Job statuses. Jobs that exhaust their attempts are moved to the dead
+// letter state, where operators can inspect, retry, or purge them.
+const (
+	StatusQueued    = "queued"
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusDead      = "dead"
+	StatusCancelled = "cancelled"
+)
+
+type Job struct {
+	ID          int64           `json:"id"`
+	Queue       string          `json:"queue"`
+	Type        string          `json:"type"`
+	Payload     json.RawMessage `json:"payload"`
+	Status      string          `json:"status"`
+	Attempts    int             `json:"attempts"`
+	MaxAttempts int             `json:"max_attempts"`
+	LastError   *string         `json:"last_error,omitempty"`
+	RunAt       time.Time       `json:"run_at"`
+	CreatedAt   time.Time       `json:"created_at"`
+	StartedAt   *time.Time      `json:"started_at,omitempty"`
+	FinishedAt  *time.Time      `json:"finished_at,omitempty"`
+}
+
+// HandlerFunc processes a job. Returning an error schedules a retry until
+// the job runs out of attempts.
+type HandlerFunc func(ctx context.Context, job *Job) error
+
+var (
+	mu       sync.RWMutex
+	handlers = map[string]HandlerFunc{}
+)
+
+// Register associates a handler with a job type. Handlers must be registered
+// before workers start.
+func Register(jobType string, handler HandlerFunc) {
+	mu.Lock()
+	defer mu.Unlock()
+	handlers[jobType] = handler
+}
+
+func handlerFor(jobType string) (HandlerFunc, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	h, ok := handlers[jobType]
+	return h, ok
+}
+
+const jobColumns = "id, queue, type, payload, status, attempts, max_attempts, last_error, run_at, created_at, started_at, finished_at"
+
+func init() {
+	database.UseColumns("jobs", jobColumns)
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanJob(row scanner) (*Job, error) {
+	var job Job
+	err := row.Scan(&job.ID, &job.Queue, &job.Type, &job.Payload, &job.Status, &job.Attempts,
+		&job.MaxAttempts, &job.LastError, &job.RunAt, &job.CreatedAt, &job.StartedAt, &job.FinishedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// Queryer is satisfied by *sql.DB and *sql.Tx, so jobs can be enqueued in
+// the same transaction as the change that triggers them.
+type Queryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// Enqueue adds a job to the given queue to run as soon as a worker is free.
+func Enqueue(ctx context.Context, queue, jobType string, payload interface{}) (*Job, error) {
+	return EnqueueWith(ctx, database.DB, queue, jobType, payload)
+}
+
+// EnqueueWith is like Enqueue but inserts the job through q.
+func EnqueueWith(ctx context.Context, q Queryer, queue, jobType string, payload interface{}) (*Job, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode job payload: %v", err)
+	}
+
+	row := q.QueryRowContext(ctx,
+		"INSERT INTO jobs (queue, type, payload) VALUES ($1, $2, $3) RETURNING "+jobColumns,
+		queue, jobType, data)
+	return scanJob(row)
+}
+
+// EnqueueAt is like EnqueueWith but holds the job until runAt.
+func EnqueueAt(ctx context.Context, q Queryer, queue, jobType string, payload interface{}, runAt time.Time) (*Job, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode job payload: %v", err)
+	}
+
+	row := q.QueryRowContext(ctx,
+		"INSERT INTO jobs (queue, type, payload, run_at) VALUES ($1, $2, $3, $4) RETURNING "+jobColumns,
+		queue, jobType, data, runAt)
+	return scanJob(row)
+}
+
+// Get returns the job with the given ID.
+func Get(ctx context.Context, id int64) (*Job, error) {
+	row := database.DB.QueryRowContext(ctx, "SELECT "+jobColumns+" FROM jobs WHERE id = $1", id)
+	return scanJob(row)
+}
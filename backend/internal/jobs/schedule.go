@@ -0,0 +1,96 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"pygorp/backend/internal/database"
+	"pygorp/backend/internal/leader"
+)
+
+// Schedule is a job enqueued periodically by workers.
+type Schedule struct {
+	Type     string
+	Queue    string
+	Interval time.Duration
+}
+
+var (
+	schedulesMu sync.RWMutex
+	schedules   []Schedule
+)
+
+// Every enqueues a job of jobType with an empty payload on queue once per
+// interval. Like handlers, schedules must be registered before workers
+// start. Workers processing the queue elect one of them to enqueue its
+// scheduled jobs (see package leader), and a job is only enqueued when none
+// is queued or running and none succeeded within the interval, so the
+// handler runs roughly once per interval however many workers there are.
+func Every(jobType, queue string, interval time.Duration) {
+	schedulesMu.Lock()
+	defer schedulesMu.Unlock()
+	schedules = append(schedules, Schedule{Type: jobType, Queue: queue, Interval: interval})
+}
+
+// scheduleTick is how often workers check for due scheduled jobs.
+const scheduleTick = time.Minute
+
+// runSchedules enqueues due scheduled jobs for each of the worker's queues
+// while it leads the queue's scheduler, until ctx is cancelled.
+func runSchedules(ctx context.Context, queues []string) {
+	schedulesMu.RLock()
+	due := map[string][]Schedule{}
+	for _, s := range schedules {
+		for _, q := range queues {
+			if s.Queue == q {
+				due[q] = append(due[q], s)
+			}
+		}
+	}
+	schedulesMu.RUnlock()
+
+	var wg sync.WaitGroup
+	for q, scheduled := range due {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			leader.Run(ctx, "scheduler:"+q, func(ctx context.Context) {
+				schedule(ctx, scheduled)
+			})
+		}()
+	}
+	wg.Wait()
+}
+
+// schedule enqueues the due jobs among scheduled every scheduleTick until
+// ctx is cancelled.
+func schedule(ctx context.Context, due []Schedule) {
+	for {
+		for _, s := range due {
+			if err := enqueueIfDue(ctx, s); err != nil && ctx.Err() == nil {
+				log.Printf("Failed to schedule %s job: %v", s.Type, err)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(scheduleTick):
+		}
+	}
+}
+
+func enqueueIfDue(ctx context.Context, s Schedule) error {
+	_, err := database.DB.ExecContext(ctx, `
+		INSERT INTO jobs (queue, type, payload)
+		SELECT $1, $2, '{}'
+		WHERE NOT EXISTS (
+			SELECT 1 FROM jobs
+			WHERE type = $2 AND (
+				status IN ('queued', 'running')
+				OR (status = 'succeeded' AND finished_at > NOW() - $3 * INTERVAL '1 second')
+			)
+		)`, s.Queue, s.Type, s.Interval.Seconds())
+	return err
+}
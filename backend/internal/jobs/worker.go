@@ -0,0 +1,132 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"pygorp/backend/internal/database"
+
+	"github.com/lib/pq"
+)
+
+// Worker polls the jobs table and runs registered handlers.
+type Worker struct {
+	Queues       []string
+	Concurrency  int
+	PollInterval time.Duration
+}
+
+// Run processes jobs until ctx is cancelled, then waits for in-flight jobs.
+func (w *Worker) Run(ctx context.Context) {
+	queues := w.Queues
+	if len(queues) == 0 {
+		queues = []string{DefaultQueue}
+	}
+	concurrency := w.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	interval := w.PollInterval
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	log.Printf("Starting job worker on queues %v with concurrency %d", queues, concurrency)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		runSchedules(ctx, queues)
+	}()
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				job, err := claim(ctx, queues)
+				if err != nil && !errors.Is(err, sql.ErrNoRows) && ctx.Err() == nil {
+					log.Printf("Failed to claim job: %v", err)
+				}
+				if job == nil {
+					select {
+					case <-ctx.Done():
+					case <-time.After(interval):
+					}
+					continue
+				}
+				process(context.WithoutCancel(ctx), job)
+			}
+		}()
+	}
+	wg.Wait()
+	log.Println("Job worker stopped")
+}
+
+// claim locks the next due job so concurrent workers never run it twice.
+func claim(ctx context.Context, queues []string) (*Job, error) {
+	row := database.DB.QueryRowContext(ctx, `
+		UPDATE jobs SET status = 'running', attempts = attempts + 1, started_at = NOW()
+		WHERE id = (
+			SELECT id FROM jobs
+			WHERE status = 'queued' AND run_at <= NOW() AND queue = ANY($1)
+			ORDER BY run_at
+			FOR UPDATE SKIP LOCKED
+			LIMIT 1
+		)
+		RETURNING `+jobColumns, pq.Array(queues))
+	return scanJob(row)
+}
+
+func process(ctx context.Context, job *Job) {
+	err := execute(ctx, job)
+	if err == nil {
+		_, err = database.DB.ExecContext(ctx,
+			"UPDATE jobs SET status = 'succeeded', last_error = NULL, finished_at = NOW() WHERE id = $1", job.ID)
+		if err != nil {
+			log.Printf("Failed to mark job %d succeeded: %v", job.ID, err)
+		}
+		return
+	}
+
+	log.Printf("Job %d (%s) attempt %d failed: %v", job.ID, job.Type, job.Attempts, err)
+	if job.Attempts >= job.MaxAttempts {
+		_, err = database.DB.ExecContext(ctx,
+			"UPDATE jobs SET status = 'dead', last_error = $2, finished_at = NOW() WHERE id = $1", job.ID, err.Error())
+	} else {
+		_, err = database.DB.ExecContext(ctx,
+			"UPDATE jobs SET status = 'queued', last_error = $2, run_at = NOW() + $3 * INTERVAL '1 second' WHERE id = $1",
+			job.ID, err.Error(), backoff(job.Attempts).Seconds())
+	}
+	if err != nil {
+		log.Printf("Failed to record failure of job %d: %v", job.ID, err)
+	}
+}
+
+func execute(ctx context.Context, job *Job) (err error) {
+	handler, ok := handlerFor(job.Type)
+	if !ok {
+		return fmt.Errorf("no handler registered for job type %q", job.Type)
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+	return handler(ctx, job)
+}
+
+// backoff grows exponentially with the attempt number, capped at one hour.
+func backoff(attempt int) time.Duration {
+	d := time.Duration(1<<uint(attempt)) * 5 * time.Second
+	if d > time.Hour || d <= 0 {
+		return time.Hour
+	}
+	return d
+}
@@ -0,0 +1,391 @@
+// Package jsonapi serves responses as JSON:API documents
+// (https://jsonapi.org) to clients that ask for them with
+// "Accept: application/vnd.api+json". Handlers keep answering in the usual
+// {"data": ...} envelope; the middleware rewrites it on the way out:
+//
+//   - objects with an "id" become resource objects with a type, a string
+//     id, and attributes;
+//   - embedded users, groups, orgs, projects, and teams, and foreign keys
+//     such as author_id, become relationships, and embedded resources are
+//     also listed in "included";
+//   - other payloads and fields such as "message" go to "meta";
+//   - {"error": ...} bodies become an "errors" array.
+//
+// Request bodies sent as application/vnd.api+json are flattened back into
+// plain JSON before they reach the handler.
+package jsonapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"pygorp/backend/internal/render"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MediaType is the JSON:API media type.
+const MediaType = "application/vnd.api+json"
+
+// relationships maps fields holding another resource, embedded or by ID, to
+// that resource's type.
+var relationships = map[string]string{
+	"user":    "users",
+	"author":  "users",
+	"group":   "groups",
+	"org":     "orgs",
+	"project": "projects",
+	"team":    "teams",
+}
+
+// Middleware rewrites JSON responses into JSON:API documents for requests
+// that accept MediaType. The resource type comes from "resource_type" in the
+// context, set by the route table. It must run outside the error renderer
+// so server errors are converted after they have been scrubbed.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Writer.Header().Add("Vary", "Accept")
+		want, acceptable := accepts(c.GetHeader("Accept"))
+		if !acceptable {
+			writeErrors(c, http.StatusNotAcceptable, "JSON:API media type parameters are not supported")
+			return
+		}
+		if !want {
+			c.Next()
+			return
+		}
+		if !unwrapRequest(c) {
+			return
+		}
+
+		w := render.NewBuffer(c.Writer, render.HoldAll)
+		c.Writer = w
+		c.Next()
+		c.Writer = w.ResponseWriter
+
+		body := w.Bytes()
+		if len(body) > 0 && render.IsJSON(w.Header().Get("Content-Type")) {
+			if doc, ok := convert(c, w.Status(), body); ok {
+				w.Header().Set("Content-Type", MediaType)
+				body = doc
+			}
+		}
+		w.Release(body)
+	}
+}
+
+// accepts reports whether the Accept header asks for JSON:API, and whether
+// the request is acceptable at all: the spec requires 406 when every
+// JSON:API entry carries media type parameters other than ext and profile.
+func accepts(header string) (want, acceptable bool) {
+	sawParams := false
+	for _, entry := range strings.Split(header, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(entry))
+		if err != nil || mediaType != MediaType {
+			continue
+		}
+		delete(params, "q")
+		delete(params, "ext")
+		delete(params, "profile")
+		if len(params) > 0 {
+			sawParams = true
+			continue
+		}
+		want = true
+	}
+	return want, want || !sawParams
+}
+
+// unwrapRequest flattens a JSON:API request body into the plain object
+// handlers bind: the attributes, plus <name>_id for each to-one
+// relationship. It responds 415 or 400 and returns false when the body
+// cannot be used.
+func unwrapRequest(c *gin.Context) bool {
+	mediaType, params, err := mime.ParseMediaType(c.GetHeader("Content-Type"))
+	if err != nil || mediaType != MediaType {
+		return true
+	}
+	delete(params, "ext")
+	delete(params, "profile")
+	if len(params) > 0 {
+		writeErrors(c, http.StatusUnsupportedMediaType, "JSON:API media type parameters are not supported")
+		return false
+	}
+
+	var doc struct {
+		Data *struct {
+			ID            string                     `json:"id"`
+			Attributes    map[string]json.RawMessage `json:"attributes"`
+			Relationships map[string]struct {
+				Data *struct {
+					ID string `json:"id"`
+				} `json:"data"`
+			} `json:"relationships"`
+		} `json:"data"`
+	}
+	raw, err := io.ReadAll(c.Request.Body)
+	if err == nil {
+		err = json.Unmarshal(raw, &doc)
+	}
+	if err != nil || doc.Data == nil {
+		writeErrors(c, http.StatusBadRequest, "Request body must be a JSON:API document with a data object")
+		return false
+	}
+
+	flat := map[string]json.RawMessage{}
+	for key, value := range doc.Data.Attributes {
+		flat[key] = value
+	}
+	for name, rel := range doc.Data.Relationships {
+		if rel.Data == nil {
+			flat[name+"_id"] = json.RawMessage("null")
+			continue
+		}
+		flat[name+"_id"] = idValue(rel.Data.ID)
+	}
+	body, _ := json.Marshal(flat)
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	c.Request.ContentLength = int64(len(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	return true
+}
+
+// idValue writes numeric IDs as numbers, the way handlers expect them.
+func idValue(id string) json.RawMessage {
+	if _, err := strconv.Atoi(id); err == nil {
+		return json.RawMessage(id)
+	}
+	b, _ := json.Marshal(id)
+	return b
+}
+
+func writeErrors(c *gin.Context, status int, detail string) {
+	c.Header("Content-Type", MediaType)
+	body, _ := json.Marshal(gin.H{
+		"jsonapi": gin.H{"version": "1.1"},
+		"errors":  []gin.H{{"status": strconv.Itoa(status), "title": http.StatusText(status), "detail": detail}},
+	})
+	c.Status(status)
+	c.Writer.Write(body)
+	c.Abort()
+}
+
+// convert rewrites an envelope body into a JSON:API document. Bodies that
+// are not JSON objects are left alone.
+func convert(c *gin.Context, status int, body []byte) ([]byte, bool) {
+	var envelope map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if err := dec.Decode(&envelope); err != nil || envelope == nil {
+		return nil, false
+	}
+
+	doc := map[string]interface{}{"jsonapi": map[string]string{"version": "1.1"}}
+	meta := map[string]interface{}{}
+
+	if status >= http.StatusBadRequest {
+		e := map[string]interface{}{"status": strconv.Itoa(status), "title": http.StatusText(status)}
+		for key, value := range envelope {
+			switch key {
+			case "error":
+				e["detail"] = value
+			case "code":
+				e["code"] = value
+			default:
+				meta[key] = value
+			}
+		}
+		doc["errors"] = []interface{}{e}
+		if len(meta) > 0 {
+			doc["meta"] = meta
+		}
+		return marshal(doc)
+	}
+
+	b := &builder{resourceType: c.GetString("resource_type"), seen: map[string]bool{}}
+	if strings.HasPrefix(lastSegment(c.FullPath()), ":") {
+		b.self = c.Request.URL.Path
+	}
+	links := map[string]string{"self": c.Request.URL.RequestURI()}
+
+	for key, value := range envelope {
+		if key != "data" {
+			meta[key] = value
+		}
+	}
+	if data, ok := envelope["data"]; ok {
+		switch v := data.(type) {
+		case nil:
+			doc["data"] = nil
+		case map[string]interface{}:
+			if isResource(v) {
+				doc["data"] = b.primary(v)
+			} else {
+				for key, value := range v {
+					meta[key] = value
+				}
+			}
+		case []interface{}:
+			if items, ok := resources(v); ok {
+				primary := make([]interface{}, len(items))
+				for i, item := range items {
+					primary[i] = b.primary(item)
+				}
+				doc["data"] = primary
+				pageLinks(c.Request.URL, len(items), links)
+			} else {
+				meta["data"] = v
+			}
+		default:
+			meta["data"] = v
+		}
+	}
+
+	doc["links"] = links
+	if len(b.included) > 0 {
+		doc["included"] = b.included
+	}
+	if len(meta) > 0 {
+		doc["meta"] = meta
+	}
+	return marshal(doc)
+}
+
+// builder turns envelope objects into resource objects, collecting
+// embedded resources for "included".
+type builder struct {
+	resourceType string
+	// self is the request path when the route addresses one resource, as
+	// in /users/:id; lists and other routes give resources no self link.
+	self     string
+	included []interface{}
+	seen     map[string]bool
+}
+
+func (b *builder) primary(obj map[string]interface{}) map[string]interface{} {
+	b.seen[b.resourceType+":"+idString(obj["id"])] = true
+	return b.resource(b.resourceType, obj, b.self)
+}
+
+func (b *builder) resource(typ string, obj map[string]interface{}, self string) map[string]interface{} {
+	attributes := map[string]interface{}{}
+	rels := map[string]interface{}{}
+	for key, value := range obj {
+		if key == "id" {
+			continue
+		}
+		if relType, ok := relationships[key]; ok {
+			if embedded, ok := value.(map[string]interface{}); ok && isResource(embedded) {
+				rels[key] = map[string]interface{}{"data": identifier(relType, embedded["id"])}
+				b.include(relType, embedded)
+				continue
+			}
+		}
+		if name, ok := strings.CutSuffix(key, "_id"); ok {
+			if relType, ok := relationships[name]; ok {
+				linkage := interface{}(nil)
+				if value != nil {
+					linkage = identifier(relType, value)
+				}
+				rels[name] = map[string]interface{}{"data": linkage}
+				continue
+			}
+		}
+		attributes[key] = value
+	}
+
+	r := map[string]interface{}{"type": typ, "id": idString(obj["id"]), "attributes": attributes}
+	if len(rels) > 0 {
+		r["relationships"] = rels
+	}
+	if self != "" {
+		r["links"] = map[string]string{"self": self}
+	}
+	return r
+}
+
+// include adds an embedded resource to "included" once.
+func (b *builder) include(typ string, obj map[string]interface{}) {
+	key := typ + ":" + idString(obj["id"])
+	if b.seen[key] {
+		return
+	}
+	b.seen[key] = true
+	b.included = append(b.included, b.resource(typ, obj, ""))
+}
+
+func identifier(typ string, id interface{}) map[string]string {
+	return map[string]string{"type": typ, "id": idString(id)}
+}
+
+func isResource(obj map[string]interface{}) bool {
+	id, ok := obj["id"]
+	return ok && id != nil
+}
+
+// resources returns the items of a list when every one is a resource.
+func resources(list []interface{}) ([]map[string]interface{}, bool) {
+	items := make([]map[string]interface{}, 0, len(list))
+	for _, item := range list {
+		obj, ok := item.(map[string]interface{})
+		if !ok || !isResource(obj) {
+			return nil, false
+		}
+		items = append(items, obj)
+	}
+	return items, true
+}
+
+func idString(id interface{}) string {
+	switch v := id.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	}
+	b, _ := json.Marshal(id)
+	return string(b)
+}
+
+// pageLinks adds first, prev, and next links to lists paged with limit and
+// offset. A full page is assumed to have a next one.
+func pageLinks(u *url.URL, n int, links map[string]string) {
+	limit, err := strconv.Atoi(u.Query().Get("limit"))
+	if err != nil || limit < 1 {
+		return
+	}
+	offset, _ := strconv.Atoi(u.Query().Get("offset"))
+	at := func(offset int) string {
+		q := u.Query()
+		q.Set("offset", strconv.Itoa(max(offset, 0)))
+		return u.Path + "?" + q.Encode()
+	}
+	links["first"] = at(0)
+	if offset > 0 {
+		links["prev"] = at(offset - limit)
+	}
+	if n >= limit {
+		links["next"] = at(offset + limit)
+	}
+}
+
+// marshal encodes a document without escaping the & in links.
+func marshal(doc interface{}) ([]byte, bool) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(doc); err != nil {
+		return nil, false
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), true
+}
+
+func lastSegment(path string) string {
+	return path[strings.LastIndex(path, "/")+1:]
+}
@@ -0,0 +1,180 @@
+// Package jsonschema validates decoded JSON against the subset of JSON
+// Schema the event schemas and the OpenAPI document use.
+package jsonschema
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/mail"
+	"sort"
+	"time"
+)
+
+// Schema supports type, required, properties, additionalProperties, items,
+// enum, minimum, minLength, minItems, the date-time and email formats, and
+// $ref once resolved.
+type Schema struct {
+	Ref                  string             `json:"$ref"`
+	Type                 interface{}        `json:"type"`
+	Required             []string           `json:"required"`
+	Properties           map[string]*Schema `json:"properties"`
+	AdditionalProperties *bool              `json:"additionalProperties"`
+	Items                *Schema            `json:"items"`
+	Enum                 []interface{}      `json:"enum"`
+	Minimum              *float64           `json:"minimum"`
+	MinLength            *int               `json:"minLength"`
+	MinItems             *int               `json:"minItems"`
+	Format               string             `json:"format"`
+
+	target *Schema
+}
+
+// Resolve links every $ref in the schema to the schema lookup returns for
+// it, failing on references lookup does not know.
+func (s *Schema) Resolve(lookup func(ref string) (*Schema, bool)) error {
+	if s == nil {
+		return nil
+	}
+	if s.Ref != "" && s.target == nil {
+		target, ok := lookup(s.Ref)
+		if !ok {
+			return fmt.Errorf("unknown $ref %q", s.Ref)
+		}
+		s.target = target
+	}
+	for _, prop := range s.Properties {
+		if err := prop.Resolve(lookup); err != nil {
+			return err
+		}
+	}
+	return s.Items.Resolve(lookup)
+}
+
+// Validate checks a decoded JSON value against the schema and returns the
+// first violation, prefixed with path.
+func (s *Schema) Validate(path string, v interface{}) error {
+	if s.Ref != "" {
+		if s.target == nil {
+			return errors.New(path + ": unresolved $ref " + s.Ref)
+		}
+		return s.target.Validate(path, v)
+	}
+	if s.Type != nil && !s.typeMatches(v) {
+		return fmt.Errorf("%s: expected %v, got %s", path, s.Type, jsonType(v))
+	}
+	if len(s.Enum) > 0 && !inEnum(s.Enum, v) {
+		return fmt.Errorf("%s: %v is not one of %v", path, v, s.Enum)
+	}
+
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for _, name := range s.Required {
+			if _, ok := v[name]; !ok {
+				return fmt.Errorf("%s: missing required property %q", path, name)
+			}
+		}
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			prop, ok := s.Properties[name]
+			if !ok {
+				if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+					return fmt.Errorf("%s: unexpected property %q", path, name)
+				}
+				continue
+			}
+			if err := prop.Validate(path+"."+name, v[name]); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		if s.MinItems != nil && len(v) < *s.MinItems {
+			return fmt.Errorf("%s: needs at least %d items", path, *s.MinItems)
+		}
+		if s.Items != nil {
+			for i, item := range v {
+				if err := s.Items.Validate(fmt.Sprintf("%s[%d]", path, i), item); err != nil {
+					return err
+				}
+			}
+		}
+	case string:
+		if s.MinLength != nil && len([]rune(v)) < *s.MinLength {
+			return fmt.Errorf("%s: shorter than %d characters", path, *s.MinLength)
+		}
+		switch s.Format {
+		case "date-time":
+			if _, err := time.Parse(time.RFC3339, v); err != nil {
+				return fmt.Errorf("%s: not an RFC 3339 date-time", path)
+			}
+		case "email":
+			if _, err := mail.ParseAddress(v); err != nil {
+				return fmt.Errorf("%s: not an email address", path)
+			}
+		}
+	case float64:
+		if s.Minimum != nil && v < *s.Minimum {
+			return fmt.Errorf("%s: less than %v", path, *s.Minimum)
+		}
+	}
+	return nil
+}
+
+func (s *Schema) typeMatches(v interface{}) bool {
+	switch t := s.Type.(type) {
+	case string:
+		return typeIs(t, v)
+	case []interface{}:
+		for _, name := range t {
+			if name, ok := name.(string); ok && typeIs(name, v) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func typeIs(name string, v interface{}) bool {
+	if name == "integer" {
+		f, ok := v.(float64)
+		return ok && f == math.Trunc(f)
+	}
+	if name == "number" {
+		_, ok := v.(float64)
+		return ok
+	}
+	return jsonType(v) == name
+}
+
+func jsonType(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+func inEnum(enum []interface{}, v interface{}) bool {
+	encoded, _ := json.Marshal(v)
+	for _, allowed := range enum {
+		if a, _ := json.Marshal(allowed); string(a) == string(encoded) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,116 @@
+// Package kpi exports business KPIs on /metrics so dashboards need no
+// database access: users, daily signups, active sessions, and email
+// delivery success. Collectors query the database in the background every
+// KPI_INTERVAL (default 1m) and set gauges, so scrapes never wait on the
+// database. Every instance running the API collects them; aggregate across
+// instances with max, not sum.
+package kpi
+
+import (
+	"context"
+	"log"
+	"math"
+	"os"
+	"time"
+
+	"pygorp/backend/internal/clock"
+	"pygorp/backend/internal/database"
+	"pygorp/backend/internal/env"
+	"pygorp/backend/internal/metrics"
+)
+
+// collector computes one group of KPIs and sets their gauges.
+type collector struct {
+	name    string
+	collect func(ctx context.Context) error
+}
+
+var collectors = []collector{
+	{"users", collectUsers},
+	{"sessions", collectSessions},
+	{"email_deliveries", collectEmailDeliveries},
+}
+
+// collectTimeout bounds one run of a collector.
+const collectTimeout = 30 * time.Second
+
+// Start runs the collectors now and then every KPI_INTERVAL until ctx is
+// cancelled. KPI_INTERVAL=0 disables them.
+func Start(ctx context.Context) {
+	if d, err := time.ParseDuration(os.Getenv("KPI_INTERVAL")); err == nil && d <= 0 {
+		return
+	}
+	interval := env.Duration("KPI_INTERVAL", time.Minute)
+	go func() {
+		for {
+			collectAll(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(interval):
+			}
+		}
+	}()
+}
+
+// collectAll runs each collector, logging failures. A failed collector
+// keeps its previous values.
+func collectAll(ctx context.Context) {
+	for _, c := range collectors {
+		cctx, cancel := context.WithTimeout(ctx, collectTimeout)
+		err := c.collect(cctx)
+		cancel()
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("Failed to collect %s KPIs: %v", c.name, err)
+			}
+			continue
+		}
+		metrics.KPICollected.WithLabelValues(c.name).Set(float64(clock.Now().Unix()))
+	}
+}
+
+func collectUsers(ctx context.Context) error {
+	var active, pending, signups int64
+	midnight := clock.Now().UTC().Truncate(24 * time.Hour)
+	err := database.DB.QueryRowContext(ctx, `
+		SELECT COUNT(*) FILTER (WHERE purge_at IS NULL), COUNT(*) FILTER (WHERE purge_at IS NOT NULL),
+			COUNT(*) FILTER (WHERE created_at >= $1)
+		FROM users`, midnight).Scan(&active, &pending, &signups)
+	if err != nil {
+		return err
+	}
+	metrics.Users.WithLabelValues("active").Set(float64(active))
+	metrics.Users.WithLabelValues("pending_deletion").Set(float64(pending))
+	metrics.DailySignups.Set(float64(signups))
+	return nil
+}
+
+func collectSessions(ctx context.Context) error {
+	var active int64
+	err := database.DB.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM sessions WHERE revoked_at IS NULL AND expires_at > $1", clock.Now()).Scan(&active)
+	if err != nil {
+		return err
+	}
+	metrics.ActiveSessions.Set(float64(active))
+	return nil
+}
+
+func collectEmailDeliveries(ctx context.Context) error {
+	var sent, failed int64
+	err := database.DB.QueryRowContext(ctx, `
+		SELECT COUNT(*) FILTER (WHERE status = 'sent'), COUNT(*) FILTER (WHERE status = 'failed')
+		FROM sent_emails WHERE created_at >= $1`, clock.Now().Add(-24*time.Hour)).Scan(&sent, &failed)
+	if err != nil {
+		return err
+	}
+	metrics.EmailDeliveries.WithLabelValues("sent").Set(float64(sent))
+	metrics.EmailDeliveries.WithLabelValues("failed").Set(float64(failed))
+	ratio := math.NaN()
+	if sent+failed > 0 {
+		ratio = float64(sent) / float64(sent+failed)
+	}
+	metrics.EmailDeliverySuccess.Set(ratio)
+	return nil
+}
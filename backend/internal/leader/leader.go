@@ -0,0 +1,206 @@
+// Package leader elects one instance to run each singleton background
+// process, such as a queue's job scheduler, when several replicas run. An
+// instance leads while it holds the process's lease in the leader_leases
+// table, which it renews every third of LEADER_LEASE_TTL (default 30s).
+// Other instances try to take the lease over as often, so when the leader
+// dies another one leads within about LEADER_LEASE_TTL. Lease times come
+// from the database's clock, so clock skew between instances does not
+// matter.
+//
+// The leader measures each term from just before it sent the renewal, which
+// is no later than the database started the lease, and cancels the
+// process's context half a renewal interval before the term ends unless a
+// later renewal extends it. A leader that cannot reach the database, or
+// whose renewal hangs, therefore stops on its own timer before the lease
+// can lapse, and two instances never run the process at once as long as it
+// returns within that half interval of being cancelled.
+package leader
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"pygorp/backend/internal/database"
+	"pygorp/backend/internal/env"
+	"pygorp/backend/internal/metrics"
+	"pygorp/backend/internal/region"
+)
+
+func init() {
+	database.UseColumns("leader_leases", "name,holder,acquired_at,renewed_at,expires_at")
+}
+
+// releaseTimeout bounds giving up a lease on shutdown.
+const releaseTimeout = 5 * time.Second
+
+// holder identifies this process as a lease holder, e.g.
+// "eu-west-1/worker-7f9c/1".
+var holder = fmt.Sprintf("%s/%d", region.ServedBy(), os.Getpid())
+
+// Lease is the last holder of a singleton process's lease.
+type Lease struct {
+	Name       string    `json:"name"`
+	Holder     string    `json:"holder"`
+	AcquiredAt time.Time `json:"acquired_at"`
+	RenewedAt  time.Time `json:"renewed_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	// Expired is true when nobody leads: the holder stopped or died and no
+	// other instance has taken the lease over yet.
+	Expired bool `json:"expired"`
+}
+
+// Leases returns every lease, ordered by name.
+func Leases(ctx context.Context) ([]Lease, error) {
+	rows, err := database.DB.QueryContext(ctx, `
+		SELECT name, holder, acquired_at, renewed_at, expires_at, expires_at <= NOW()
+		FROM leader_leases ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	leases := []Lease{}
+	for rows.Next() {
+		var l Lease
+		if err := rows.Scan(&l.Name, &l.Holder, &l.AcquiredAt, &l.RenewedAt, &l.ExpiresAt, &l.Expired); err != nil {
+			return nil, err
+		}
+		leases = append(leases, l)
+	}
+	return leases, rows.Err()
+}
+
+// Run runs fn while this instance holds the lease called name, until ctx is
+// cancelled. fn is started when the lease is won and its context is
+// cancelled when the lease is lost or its term runs out unrenewed; Run waits
+// for it to return each time. On shutdown the lease is released so another
+// instance takes over at once.
+func Run(ctx context.Context, name string, fn func(ctx context.Context)) {
+	ttl := env.Duration("LEADER_LEASE_TTL", 30*time.Second)
+	renewEvery := ttl / 3
+	// margin is the time fn has to return after its term is cut short.
+	margin := renewEvery / 2
+
+	var (
+		runCtx context.Context
+		cancel context.CancelFunc
+		done   chan struct{}
+		expiry *time.Timer
+	)
+	stop := func() {
+		expiry.Stop()
+		cancel()
+		<-done
+		cancel = nil
+		metrics.Leader.WithLabelValues(name).Set(0)
+	}
+	defer func() {
+		if cancel != nil {
+			stop()
+			release(name)
+		}
+	}()
+
+	for {
+		sent := time.Now()
+		actx, cancelAcquire := context.WithTimeout(ctx, renewEvery)
+		won, err := acquire(actx, name, ttl)
+		cancelAcquire()
+		termEnd := sent.Add(ttl - margin)
+
+		switch {
+		case err != nil:
+			if ctx.Err() == nil {
+				log.Printf("Failed to renew %s lease: %v", name, err)
+			}
+		case won:
+			if cancel != nil && !expiry.Stop() {
+				// The term ran out while renewing, so fn is already being
+				// stopped; finish that and start a new term.
+				log.Printf("Stepping down as %s leader: lease was renewed too late", name)
+				stop()
+			}
+			if cancel != nil {
+				expiry.Reset(time.Until(termEnd))
+				break
+			}
+			log.Printf("Elected %s leader as %s", name, holder)
+			var runCancel context.CancelFunc
+			runCtx, runCancel = context.WithCancel(ctx)
+			cancel, done = runCancel, make(chan struct{})
+			expiry = time.AfterFunc(time.Until(termEnd), runCancel)
+			go func(runCtx context.Context, done chan struct{}) {
+				defer close(done)
+				fn(runCtx)
+			}(runCtx, done)
+			metrics.Leader.WithLabelValues(name).Set(1)
+		case cancel != nil:
+			log.Printf("Lost %s lease to another instance", name)
+			stop()
+		}
+
+		// Wait for the next renewal, noticing if fn stops meanwhile
+		// because its term ran out.
+		wait := time.NewTimer(renewEvery)
+		for waiting := true; waiting; {
+			var finished chan struct{}
+			if cancel != nil {
+				finished = done
+			}
+			select {
+			case <-ctx.Done():
+				wait.Stop()
+				return
+			case <-finished:
+				if runCtx.Err() != nil {
+					log.Printf("Stepping down as %s leader: lease could not be renewed", name)
+				} else {
+					log.Printf("%s stopped while leading; restarting it on the next renewal", name)
+				}
+				stop()
+			case <-wait.C:
+				waiting = false
+			}
+		}
+	}
+}
+
+// acquire takes the lease if it is free or has lapsed, or renews it if this
+// instance holds it, and reports whether this instance holds it now.
+func acquire(ctx context.Context, name string, ttl time.Duration) (bool, error) {
+	var got string
+	err := database.DB.QueryRowContext(ctx, `
+		INSERT INTO leader_leases (name, holder, acquired_at, renewed_at, expires_at)
+		VALUES ($1, $2, NOW(), NOW(), NOW() + $3 * INTERVAL '1 second')
+		ON CONFLICT (name) DO UPDATE SET
+			holder = EXCLUDED.holder,
+			acquired_at = CASE WHEN leader_leases.holder = EXCLUDED.holder AND leader_leases.expires_at > NOW()
+				THEN leader_leases.acquired_at ELSE NOW() END,
+			renewed_at = NOW(),
+			expires_at = EXCLUDED.expires_at
+		WHERE leader_leases.holder = EXCLUDED.holder OR leader_leases.expires_at <= NOW()
+		RETURNING holder`, name, holder, ttl.Seconds()).Scan(&got)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// release expires the lease if this instance holds it.
+func release(name string) {
+	ctx, cancel := context.WithTimeout(context.Background(), releaseTimeout)
+	defer cancel()
+	_, err := database.DB.ExecContext(ctx,
+		"UPDATE leader_leases SET expires_at = NOW() WHERE name = $1 AND holder = $2", name, holder)
+	if err != nil {
+		log.Printf("Failed to release %s lease: %v", name, err)
+	}
+}
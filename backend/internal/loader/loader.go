@@ -0,0 +1,185 @@
+package loader
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrNotFound is returned for keys missing from a batch result.
+var ErrNotFound = errors.New("not found")
+
+// BatchFunc fetches values for a set of keys in one round trip, typically a
+// single "WHERE id = ANY($1)" query. Keys absent from the returned map are
+// reported as ErrNotFound.
+type BatchFunc[K comparable, V any] func(ctx context.Context, keys []K) (map[K]V, error)
+
+// fetchTimeout bounds one BatchFunc call.
+const fetchTimeout = 10 * time.Second
+
+// Loader batches individual Load calls made within a short window into one
+// BatchFunc call and caches results for its lifetime. A Loader is meant to
+// live for a single request, so the cache never serves stale data across
+// requests.
+//
+// A batch serves every caller that joined it, so it runs with the values
+// of the context it was created with (such as the request's tenant) but
+// not its cancellation: one caller giving up does not fail the others. It
+// is bounded by fetchTimeout instead, and each Load still returns as soon
+// as its own context is done.
+type Loader[K comparable, V any] struct {
+	ctx      context.Context
+	fetch    BatchFunc[K, V]
+	wait     time.Duration
+	maxBatch int
+
+	mu      sync.Mutex
+	cache   map[K]*result[V]
+	pending *batch[K, V]
+}
+
+type result[V any] struct {
+	done  chan struct{}
+	value V
+	err   error
+}
+
+type batch[K comparable, V any] struct {
+	keys    []K
+	results map[K]*result[V]
+	timer   *time.Timer
+}
+
+// New creates a loader for the request whose context is ctx. It waits up to
+// wait for more keys before dispatching, and never sends more than
+// maxBatch keys in one call.
+func New[K comparable, V any](ctx context.Context, fetch BatchFunc[K, V], wait time.Duration, maxBatch int) *Loader[K, V] {
+	if maxBatch < 1 {
+		maxBatch = 100
+	}
+	return &Loader[K, V]{
+		ctx:      context.WithoutCancel(ctx),
+		fetch:    fetch,
+		wait:     wait,
+		maxBatch: maxBatch,
+		cache:    map[K]*result[V]{},
+	}
+}
+
+// Load returns the value for key, joining the current batch if needed.
+func (l *Loader[K, V]) Load(ctx context.Context, key K) (V, error) {
+	l.mu.Lock()
+	r, ok := l.cache[key]
+	if !ok {
+		r = &result[V]{done: make(chan struct{})}
+		l.cache[key] = r
+		l.enqueue(key, r)
+	}
+	l.mu.Unlock()
+
+	select {
+	case <-r.done:
+		return r.value, r.err
+	case <-ctx.Done():
+		var zero V
+		return zero, ctx.Err()
+	}
+}
+
+// LoadMany loads several keys, batching them together. Values and errors are
+// returned in key order.
+func (l *Loader[K, V]) LoadMany(ctx context.Context, keys []K) ([]V, []error) {
+	values := make([]V, len(keys))
+	errs := make([]error, len(keys))
+
+	var wg sync.WaitGroup
+	for i, key := range keys {
+		wg.Add(1)
+		go func(i int, key K) {
+			defer wg.Done()
+			values[i], errs[i] = l.Load(ctx, key)
+		}(i, key)
+	}
+	wg.Wait()
+	return values, errs
+}
+
+// Prime stores a value that was fetched elsewhere so later loads skip the
+// database.
+func (l *Loader[K, V]) Prime(key K, value V) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.cache[key]; ok {
+		return
+	}
+	r := &result[V]{done: make(chan struct{}), value: value}
+	close(r.done)
+	l.cache[key] = r
+}
+
+// Clear drops a cached key, e.g. after the underlying row was modified.
+func (l *Loader[K, V]) Clear(key K) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.cache, key)
+}
+
+// enqueue adds key to the pending batch. Callers must hold l.mu.
+func (l *Loader[K, V]) enqueue(key K, r *result[V]) {
+	if l.pending == nil {
+		b := &batch[K, V]{results: map[K]*result[V]{}}
+		b.timer = time.AfterFunc(l.wait, func() { l.dispatch(b) })
+		l.pending = b
+	}
+
+	b := l.pending
+	b.keys = append(b.keys, key)
+	b.results[key] = r
+
+	if len(b.keys) >= l.maxBatch {
+		b.timer.Stop()
+		l.pending = nil
+		go l.run(b)
+	}
+}
+
+func (l *Loader[K, V]) dispatch(b *batch[K, V]) {
+	l.mu.Lock()
+	if l.pending != b {
+		// Already dispatched because the batch filled up.
+		l.mu.Unlock()
+		return
+	}
+	l.pending = nil
+	l.mu.Unlock()
+
+	l.run(b)
+}
+
+func (l *Loader[K, V]) run(b *batch[K, V]) {
+	ctx, cancel := context.WithTimeout(l.ctx, fetchTimeout)
+	values, err := l.fetch(ctx, b.keys)
+	cancel()
+	for key, r := range b.results {
+		if err != nil {
+			r.err = err
+		} else if v, ok := values[key]; ok {
+			r.value = v
+		} else {
+			r.err = ErrNotFound
+		}
+		close(r.done)
+	}
+
+	if err != nil {
+		// Do not cache failures; a later load may succeed.
+		l.mu.Lock()
+		for key, r := range b.results {
+			if l.cache[key] == r {
+				delete(l.cache, key)
+			}
+		}
+		l.mu.Unlock()
+	}
+}
@@ -0,0 +1,33 @@
+package loader
+
+import (
+	"context"
+	"time"
+
+	"pygorp/backend/internal/models"
+	"pygorp/backend/internal/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+const contextKey = "loaders"
+
+// Loaders holds the per-request loaders for related entities.
+type Loaders struct {
+	Users *Loader[int, models.User]
+}
+
+// For returns the request's loaders, creating them on first use so requests
+// that load nothing pay nothing.
+func For(c *gin.Context) *Loaders {
+	if l, ok := c.Get(contextKey); ok {
+		return l.(*Loaders)
+	}
+	l := &Loaders{Users: New(c.Request.Context(), usersByID, 2*time.Millisecond, 500)}
+	c.Set(contextKey, l)
+	return l
+}
+
+func usersByID(ctx context.Context, ids []int) (map[int]models.User, error) {
+	return repository.UsersByIDs(ctx, ids)
+}
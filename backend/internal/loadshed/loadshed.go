@@ -0,0 +1,151 @@
+// Package loadshed tracks in-flight requests and recent latency per route,
+// so the server can tell when it is overloaded and which routes are over
+// their latency budget.
+package loadshed
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Route priorities. Critical routes are never shed.
+const (
+	PriorityCritical = "critical"
+	PriorityNormal   = "normal"
+	PriorityLow      = "low"
+)
+
+const (
+	// window is how far back latency samples count, so a route that stops
+	// being slow, or stops receiving traffic, recovers.
+	window = time.Minute
+	// maxSamples bounds the samples kept per route.
+	maxSamples = 1000
+	// MinSamples is the number of samples needed before a route's p99 is
+	// trusted; a handful of slow requests says little.
+	MinSamples = 20
+)
+
+type sample struct {
+	at       time.Time
+	duration time.Duration
+}
+
+// routeStats is a ring of recent samples for one route. The percentile is
+// recomputed at most once a second, since every request asks for it.
+type routeStats struct {
+	mu      sync.Mutex
+	samples []sample
+	next    int
+
+	computedAt time.Time
+	p99Value   time.Duration
+	count      int
+}
+
+func (r *routeStats) add(s sample) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.samples) < maxSamples {
+		r.samples = append(r.samples, s)
+		return
+	}
+	r.samples[r.next] = s
+	r.next = (r.next + 1) % maxSamples
+}
+
+// p99 returns the 99th percentile of the samples within the window and how
+// many there were.
+func (r *routeStats) p99(now time.Time) (time.Duration, int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if now.Sub(r.computedAt) < time.Second {
+		return r.p99Value, r.count
+	}
+
+	durations := make([]time.Duration, 0, len(r.samples))
+	for _, s := range r.samples {
+		if now.Sub(s.at) <= window {
+			durations = append(durations, s.duration)
+		}
+	}
+	r.computedAt, r.p99Value, r.count = now, 0, len(durations)
+	if len(durations) > 0 {
+		sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+		r.p99Value = durations[(len(durations)*99-1)/100]
+	}
+	return r.p99Value, r.count
+}
+
+// Tracker records requests across all routes.
+type Tracker struct {
+	inFlight atomic.Int64
+	routes   sync.Map // route name -> *routeStats
+}
+
+// Begin counts a request as in flight and returns a function that records
+// its latency once it has been handled.
+func (t *Tracker) Begin(route string) func() {
+	start := time.Now()
+	t.inFlight.Add(1)
+	return func() {
+		t.inFlight.Add(-1)
+		now := time.Now()
+		t.stats(route).add(sample{at: now, duration: now.Sub(start)})
+	}
+}
+
+// InFlight returns the number of requests being handled.
+func (t *Tracker) InFlight() int64 {
+	return t.inFlight.Load()
+}
+
+// P99 returns a route's 99th percentile latency over the last minute, or
+// zero when there are too few samples to tell.
+func (t *Tracker) P99(route string) time.Duration {
+	v, ok := t.routes.Load(route)
+	if !ok {
+		return 0
+	}
+	p99, n := v.(*routeStats).p99(time.Now())
+	if n < MinSamples {
+		return 0
+	}
+	return p99
+}
+
+// RouteLatency is the recent latency of one route.
+type RouteLatency struct {
+	Route   string
+	P99     time.Duration
+	Samples int
+}
+
+// Latencies returns the recent latency of every route that has served
+// requests, ordered by route name.
+func (t *Tracker) Latencies() []RouteLatency {
+	now := time.Now()
+	var list []RouteLatency
+	t.routes.Range(func(key, value interface{}) bool {
+		p99, n := value.(*routeStats).p99(now)
+		if n > 0 {
+			list = append(list, RouteLatency{Route: key.(string), P99: p99, Samples: n})
+		}
+		return true
+	})
+	sort.Slice(list, func(i, j int) bool { return list[i].Route < list[j].Route })
+	return list
+}
+
+func (t *Tracker) stats(route string) *routeStats {
+	if v, ok := t.routes.Load(route); ok {
+		return v.(*routeStats)
+	}
+	v, _ := t.routes.LoadOrStore(route, &routeStats{})
+	return v.(*routeStats)
+}
+
+// Default is the tracker used by the load-shedding middleware.
+var Default = &Tracker{}
@@ -0,0 +1,118 @@
+package logger
+
+import (
+	"log"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"pygorp/backend/internal/region"
+)
+
+var (
+	level = new(slog.LevelVar)
+
+	mu            sync.Mutex
+	base          slog.Level
+	resetTimer    *time.Timer
+	overrideUntil time.Time
+	// overrideGen numbers overrides so a stale timer can tell it was
+	// replaced.
+	overrideGen uint64
+)
+
+// Status describes the active log level and any temporary override.
+type Status struct {
+	Level         string     `json:"level"`
+	BaseLevel     string     `json:"base_level"`
+	OverrideUntil *time.Time `json:"override_until,omitempty"`
+}
+
+// Init installs a leveled handler as the default logger. Output from the
+// standard log package is routed through it at info level. When REGION is
+// set every record carries it.
+func Init(name string) error {
+	l := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: level}))
+	if r := region.Name(); r != "" {
+		l = l.With("region", r)
+	}
+	slog.SetDefault(l)
+	return SetLevel(name)
+}
+
+// SetLevel changes the configured minimum level. While a temporary override
+// is active the new level takes effect once the override expires.
+func SetLevel(name string) error {
+	l, err := parse(name)
+	if err != nil {
+		return err
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	base = l
+	if resetTimer == nil {
+		level.Set(l)
+	}
+	return nil
+}
+
+// Override temporarily switches to the given level, reverting to the
+// configured level after d.
+func Override(name string, d time.Duration) (Status, error) {
+	l, err := parse(name)
+	if err != nil {
+		return Status{}, err
+	}
+
+	mu.Lock()
+	if resetTimer != nil {
+		resetTimer.Stop()
+	}
+	level.Set(l)
+	overrideUntil = time.Now().Add(d)
+	overrideGen++
+	gen := overrideGen
+	resetTimer = time.AfterFunc(d, func() { reset(gen) })
+	mu.Unlock()
+
+	log.Printf("Log level overridden to %s for %s", l, d)
+	return Current(), nil
+}
+
+// Current returns the active log level status.
+func Current() Status {
+	mu.Lock()
+	defer mu.Unlock()
+
+	status := Status{Level: level.Level().String(), BaseLevel: base.String()}
+	if resetTimer != nil {
+		until := overrideUntil
+		status.OverrideUntil = &until
+	}
+	return status
+}
+
+// reset ends override number gen. A timer that fired just as a newer
+// override replaced it finds gen is stale and does nothing.
+func reset(gen uint64) {
+	mu.Lock()
+	if gen != overrideGen || resetTimer == nil {
+		mu.Unlock()
+		return
+	}
+	l := base
+	level.Set(l)
+	resetTimer = nil
+	overrideUntil = time.Time{}
+	mu.Unlock()
+
+	log.Printf("Log level override expired, reverted to %s", l)
+}
+
+func parse(name string) (slog.Level, error) {
+	var l slog.Level
+	err := l.UnmarshalText([]byte(name))
+	return l, err
+}
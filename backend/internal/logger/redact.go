@@ -0,0 +1,139 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Placeholders for removed values.
+const (
+	Redacted      = "[REDACTED]"
+	RedactedEmail = "[EMAIL]"
+)
+
+var (
+	emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9\-]+(\.[A-Za-z0-9\-]+)*\.[A-Za-z]{2,}`)
+	// tokenPatterns match credentials wherever they appear: authorization
+	// schemes, JWTs such as session tokens, service account API keys, and
+	// personal access tokens (see auth.APIKeyPrefix and
+	// auth.PersonalTokenPrefix).
+	tokenPatterns = []*regexp.Regexp{
+		regexp.MustCompile(`(?i)\b(bearer|basic)\s+[A-Za-z0-9._~+/=\-]+`),
+		regexp.MustCompile(`\beyJ[A-Za-z0-9_\-]*\.[A-Za-z0-9_\-]*\.[A-Za-z0-9_\-]*`),
+		regexp.MustCompile(`\bpgsa_[A-Za-z0-9_\-]+`),
+		regexp.MustCompile(`\bpgpat_[A-Za-z0-9_\-]+`),
+	}
+)
+
+// sensitiveNames are fragments of header, query parameter, and JSON key
+// names whose values are always removed, compared without case, dashes, or
+// underscores.
+var sensitiveNames = []string{"authorization", "cookie", "token", "secret", "password", "apikey", "signature", "session", "confirm"}
+
+func sensitive(name string) bool {
+	name = strings.NewReplacer("-", "", "_", "").Replace(strings.ToLower(name))
+	for _, s := range sensitiveNames {
+		if strings.Contains(name, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// RedactString removes email addresses and credentials from free text.
+func RedactString(s string) string {
+	for _, p := range tokenPatterns {
+		s = p.ReplaceAllString(s, Redacted)
+	}
+	return emailPattern.ReplaceAllString(s, RedactedEmail)
+}
+
+// RedactHeaders returns headers for logging, with sensitive ones removed
+// and the rest scrubbed by RedactString.
+func RedactHeaders(h http.Header) map[string]string {
+	out := make(map[string]string, len(h))
+	for name, values := range h {
+		if sensitive(name) {
+			out[name] = Redacted
+			continue
+		}
+		out[name] = RedactString(strings.Join(values, ", "))
+	}
+	return out
+}
+
+// RedactQuery scrubs a raw query string like RedactHeaders scrubs headers.
+func RedactQuery(raw string) string {
+	if raw == "" {
+		return ""
+	}
+	values, err := url.ParseQuery(raw)
+	if err != nil {
+		return RedactString(raw)
+	}
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, k := range keys {
+		for _, v := range values[k] {
+			if b.Len() > 0 {
+				b.WriteByte('&')
+			}
+			if sensitive(k) {
+				v = Redacted
+			} else {
+				v = RedactString(v)
+			}
+			b.WriteString(k + "=" + v)
+		}
+	}
+	return b.String()
+}
+
+// RedactBody returns a request body for logging. JSON has the values of
+// sensitive keys removed and its strings scrubbed; anything else, including
+// truncated JSON, is scrubbed as text.
+func RedactBody(body []byte) string {
+	var v interface{}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil || dec.More() {
+		return RedactString(string(body))
+	}
+	var out bytes.Buffer
+	enc := json.NewEncoder(&out)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(redactJSON(v)); err != nil {
+		return RedactString(string(body))
+	}
+	return strings.TrimSuffix(out.String(), "\n")
+}
+
+func redactJSON(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, val := range v {
+			if sensitive(k) && val != nil {
+				v[k] = Redacted
+				continue
+			}
+			v[k] = redactJSON(val)
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = redactJSON(v[i])
+		}
+	case string:
+		return RedactString(v)
+	}
+	return v
+}
@@ -0,0 +1,186 @@
+package mailer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"pygorp/backend/internal/database"
+	"pygorp/backend/internal/env"
+	"pygorp/backend/internal/jobs"
+	"pygorp/backend/internal/templates"
+)
+
+// Message is an outbound email.
+type Message struct {
+	From     string
+	To       string
+	Subject  string
+	HTML     string
+	Text     string
+	Template string
+}
+
+// Provider delivers messages through one email service.
+type Provider interface {
+	Name() string
+	// Send delivers msg and returns the provider's message ID if it has one.
+	Send(ctx context.Context, msg *Message) (string, error)
+}
+
+type attempt struct {
+	Provider string `json:"provider"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Mailer sends through its providers in order, failing over to the next
+// provider when one errors.
+type Mailer struct {
+	From      string
+	Providers []Provider
+}
+
+var (
+	once          sync.Once
+	defaultMailer *Mailer
+	initErr       error
+)
+
+// Default returns the mailer configured from the environment. MAIL_PROVIDERS
+// lists providers in failover order: smtp, sendgrid, ses, or log.
+func Default() (*Mailer, error) {
+	once.Do(func() {
+		defaultMailer, initErr = fromEnv()
+	})
+	return defaultMailer, initErr
+}
+
+func fromEnv() (*Mailer, error) {
+	m := &Mailer{From: env.String("MAIL_FROM", "no-reply@pygorp.local")}
+	for _, name := range strings.Split(env.String("MAIL_PROVIDERS", "log"), ",") {
+		switch strings.TrimSpace(name) {
+		case "smtp":
+			poolSize, _ := strconv.Atoi(env.String("SMTP_POOL_SIZE", "4"))
+			m.Providers = append(m.Providers, NewSMTP(
+				env.String("SMTP_HOST", "localhost"),
+				env.String("SMTP_PORT", "587"),
+				os.Getenv("SMTP_USERNAME"),
+				os.Getenv("SMTP_PASSWORD"),
+				poolSize,
+			))
+		case "sendgrid":
+			m.Providers = append(m.Providers, NewSendGrid(os.Getenv("SENDGRID_API_KEY")))
+		case "ses":
+			m.Providers = append(m.Providers, NewSES(
+				env.String("SES_REGION", "us-east-1"),
+				os.Getenv("SES_ACCESS_KEY_ID"),
+				os.Getenv("SES_SECRET_ACCESS_KEY"),
+				os.Getenv("SES_SESSION_TOKEN"),
+			))
+		case "log":
+			m.Providers = append(m.Providers, logProvider{})
+		case "":
+		default:
+			return nil, fmt.Errorf("unknown mail provider %q", name)
+		}
+	}
+	if len(m.Providers) == 0 {
+		return nil, errors.New("no mail providers configured")
+	}
+	return m, nil
+}
+
+// Send delivers msg through the first provider that succeeds and records
+// the outcome in sent_emails.
+func (m *Mailer) Send(ctx context.Context, msg *Message) error {
+	if msg.From == "" {
+		msg.From = m.From
+	}
+
+	var attempts []attempt
+	for _, p := range m.Providers {
+		id, err := p.Send(ctx, msg)
+		if err == nil {
+			attempts = append(attempts, attempt{Provider: p.Name()})
+			record(ctx, msg, "sent", p.Name(), id, "", attempts)
+			return nil
+		}
+
+		log.Printf("Mail provider %s failed, trying next: %v", p.Name(), err)
+		attempts = append(attempts, attempt{Provider: p.Name(), Error: err.Error()})
+	}
+
+	err := fmt.Errorf("all mail providers failed: %s", attempts[len(attempts)-1].Error)
+	record(ctx, msg, "failed", "", "", err.Error(), attempts)
+	return err
+}
+
+// SendTemplate renders an email template and sends it.
+func (m *Mailer) SendTemplate(ctx context.Context, to, name string, data templates.Data) error {
+	email, err := templates.Render(name, data)
+	if err != nil {
+		return err
+	}
+	return m.Send(ctx, &Message{To: to, Subject: email.Subject, HTML: email.HTML, Text: email.Text, Template: name})
+}
+
+func record(ctx context.Context, msg *Message, status, provider, providerID, errMsg string, attempts []attempt) {
+	data, _ := json.Marshal(attempts)
+	_, err := database.DB.ExecContext(ctx, `
+		INSERT INTO sent_emails (recipient, subject, template, provider, provider_message_id, status, error, attempts)
+		VALUES ($1, $2, NULLIF($3, ''), NULLIF($4, ''), NULLIF($5, ''), $6, NULLIF($7, ''), $8)`,
+		msg.To, msg.Subject, msg.Template, provider, providerID, status, errMsg, data)
+	if err != nil {
+		log.Printf("Failed to record email delivery to %s: %v", msg.To, err)
+	}
+}
+
+// JobType is the background job that sends a templated email.
+const JobType = "email.send"
+
+type sendPayload struct {
+	To       string         `json:"to"`
+	Template string         `json:"template"`
+	Data     templates.Data `json:"data"`
+}
+
+// Enqueue schedules a templated email to be sent by a worker, which retries
+// when every provider fails.
+func Enqueue(ctx context.Context, to, name string, data templates.Data) error {
+	_, err := jobs.Enqueue(ctx, jobs.DefaultQueue, JobType, sendPayload{To: to, Template: name, Data: data})
+	return err
+}
+
+func init() {
+	database.UseColumns("sent_emails", "recipient, subject, template, provider, provider_message_id, status, error, attempts")
+
+	jobs.Register(JobType, func(ctx context.Context, job *jobs.Job) error {
+		var p sendPayload
+		if err := json.Unmarshal(job.Payload, &p); err != nil {
+			return fmt.Errorf("invalid email payload: %v", err)
+		}
+
+		m, err := Default()
+		if err != nil {
+			return err
+		}
+		return m.SendTemplate(ctx, p.To, p.Template, p.Data)
+	})
+}
+
+// logProvider writes messages to the log instead of delivering them, for
+// local development.
+type logProvider struct{}
+
+func (logProvider) Name() string { return "log" }
+
+func (logProvider) Send(ctx context.Context, msg *Message) (string, error) {
+	log.Printf("Email to %s: %s\n%s", msg.To, msg.Subject, msg.Text)
+	return "", nil
+}
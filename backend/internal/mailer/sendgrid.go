@@ -0,0 +1,71 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// SendGridProvider sends through the SendGrid v3 mail API.
+type SendGridProvider struct {
+	apiKey string
+	client *http.Client
+}
+
+func NewSendGrid(apiKey string) *SendGridProvider {
+	return &SendGridProvider{apiKey: apiKey, client: &http.Client{Timeout: 15 * time.Second}}
+}
+
+func (p *SendGridProvider) Name() string { return "sendgrid" }
+
+func (p *SendGridProvider) Send(ctx context.Context, msg *Message) (string, error) {
+	type address struct {
+		Email string `json:"email"`
+	}
+	type content struct {
+		Type  string `json:"type"`
+		Value string `json:"value"`
+	}
+
+	payload := map[string]interface{}{
+		"personalizations": []map[string]interface{}{{"to": []address{{Email: msg.To}}}},
+		"from":             address{Email: msg.From},
+		"subject":          msg.Subject,
+	}
+	var contents []content
+	if msg.Text != "" {
+		contents = append(contents, content{Type: "text/plain", Value: msg.Text})
+	}
+	if msg.HTML != "" {
+		contents = append(contents, content{Type: "text/html", Value: msg.HTML})
+	}
+	payload["content"] = contents
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://api.sendgrid.com/v3/mail/send", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", fmt.Errorf("sendgrid returned %d: %s", resp.StatusCode, detail)
+	}
+	return resp.Header.Get("X-Message-Id"), nil
+}
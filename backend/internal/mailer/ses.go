@@ -0,0 +1,88 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"pygorp/backend/internal/awssig"
+)
+
+// SESProvider sends through the Amazon SES v2 API, signing requests with
+// AWS Signature Version 4.
+type SESProvider struct {
+	region string
+	creds  awssig.Credentials
+	client *http.Client
+}
+
+func NewSES(region, accessKey, secretKey, sessionToken string) *SESProvider {
+	return &SESProvider{
+		region: region,
+		creds: awssig.Credentials{
+			AccessKeyID:     accessKey,
+			SecretAccessKey: secretKey,
+			SessionToken:    sessionToken,
+		},
+		client: &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+func (p *SESProvider) Name() string { return "ses" }
+
+func (p *SESProvider) Send(ctx context.Context, msg *Message) (string, error) {
+	type text struct {
+		Data    string `json:"Data"`
+		Charset string `json:"Charset"`
+	}
+	bodyParts := map[string]text{}
+	if msg.Text != "" {
+		bodyParts["Text"] = text{Data: msg.Text, Charset: "UTF-8"}
+	}
+	if msg.HTML != "" {
+		bodyParts["Html"] = text{Data: msg.HTML, Charset: "UTF-8"}
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"FromEmailAddress": msg.From,
+		"Destination":      map[string][]string{"ToAddresses": {msg.To}},
+		"Content": map[string]interface{}{
+			"Simple": map[string]interface{}{
+				"Subject": text{Data: msg.Subject, Charset: "UTF-8"},
+				"Body":    bodyParts,
+			},
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	host := "email." + p.region + ".amazonaws.com"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://"+host+"/v2/email/outbound-emails", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	awssig.Sign(req, body, "ses", p.region, p.creds, time.Now())
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode >= 300 {
+		return "", fmt.Errorf("ses returned %d: %s", resp.StatusCode, respBody)
+	}
+
+	var result struct {
+		MessageID string `json:"MessageId"`
+	}
+	json.Unmarshal(respBody, &result)
+	return result.MessageID, nil
+}
@@ -0,0 +1,135 @@
+package mailer
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/smtp"
+	"time"
+)
+
+// SMTPProvider sends through an SMTP relay, keeping a pool of authenticated
+// connections so each message skips the handshake.
+type SMTPProvider struct {
+	host string
+	addr string
+	auth smtp.Auth
+	pool chan *smtp.Client
+}
+
+// NewSMTP creates an SMTP provider. Port 465 uses implicit TLS; other ports
+// upgrade with STARTTLS when the server offers it.
+func NewSMTP(host, port, username, password string, poolSize int) *SMTPProvider {
+	if poolSize < 1 {
+		poolSize = 1
+	}
+	p := &SMTPProvider{
+		host: host,
+		addr: net.JoinHostPort(host, port),
+		pool: make(chan *smtp.Client, poolSize),
+	}
+	if username != "" {
+		p.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return p
+}
+
+func (p *SMTPProvider) Name() string { return "smtp" }
+
+func (p *SMTPProvider) Send(ctx context.Context, msg *Message) (string, error) {
+	body, messageID, err := buildMIME(msg)
+	if err != nil {
+		return "", err
+	}
+
+	c, err := p.get(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	if err := p.deliver(c, msg, body); err != nil {
+		c.Close()
+		return "", err
+	}
+	p.put(c)
+	return messageID, nil
+}
+
+func (p *SMTPProvider) deliver(c *smtp.Client, msg *Message, body []byte) error {
+	if err := c.Mail(msg.From); err != nil {
+		return err
+	}
+	if err := c.Rcpt(msg.To); err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(body); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Reset()
+}
+
+// get returns a pooled connection that still answers NOOP, or dials a new one.
+func (p *SMTPProvider) get(ctx context.Context) (*smtp.Client, error) {
+	for {
+		select {
+		case c := <-p.pool:
+			if c.Noop() == nil {
+				return c, nil
+			}
+			c.Close()
+			continue
+		default:
+		}
+		return p.dial(ctx)
+	}
+}
+
+func (p *SMTPProvider) put(c *smtp.Client) {
+	select {
+	case p.pool <- c:
+	default:
+		c.Quit()
+	}
+}
+
+func (p *SMTPProvider) dial(ctx context.Context) (*smtp.Client, error) {
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	tlsConfig := &tls.Config{ServerName: p.host}
+
+	var conn net.Conn
+	var err error
+	if _, port, _ := net.SplitHostPort(p.addr); port == "465" {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: tlsConfig}).DialContext(ctx, "tcp", p.addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", p.addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := smtp.NewClient(conn, p.host)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(tlsConfig); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	if p.auth != nil {
+		if err := c.Auth(p.auth); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
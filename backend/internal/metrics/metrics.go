@@ -0,0 +1,164 @@
+package metrics
+
+import (
+	"pygorp/backend/internal/region"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// factory registers the metrics below. When REGION is set every one of them
+// carries a region label, so dashboards aggregating several regions can
+// split by it.
+var factory = promauto.With(registerer())
+
+func registerer() prometheus.Registerer {
+	if r := region.Name(); r != "" {
+		return prometheus.WrapRegistererWith(prometheus.Labels{"region": r}, prometheus.DefaultRegisterer)
+	}
+	return prometheus.DefaultRegisterer
+}
+
+var (
+	// CoalescedRequests counts requests that shared the result of an
+	// identical in-flight query instead of hitting the database.
+	CoalescedRequests = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "pygorp_coalesced_requests_total",
+		Help: "Requests served from an identical in-flight query.",
+	}, []string{"query"})
+
+	// StatementCache counts prepared statement cache lookups by result:
+	// hit, miss, invalidated, or evicted when the cache is full.
+	StatementCache = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "pygorp_statement_cache_total",
+		Help: "Prepared statement cache lookups, invalidations and evictions.",
+	}, []string{"result"})
+
+	// ProxyRequests counts requests forwarded to the Python service by
+	// outcome: a status class (2xx, 5xx, ...), error, or rejected when the
+	// circuit breaker is open.
+	ProxyRequests = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "pygorp_proxy_requests_total",
+		Help: "Requests forwarded to the Python service.",
+	}, []string{"outcome"})
+
+	// PolicyDecisions counts policy engine checks by decision (allow or
+	// deny) and mode (audit or enforce).
+	PolicyDecisions = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "pygorp_policy_decisions_total",
+		Help: "Requests checked by the policy engine.",
+	}, []string{"decision", "mode"})
+
+	// AuditEvents counts audit events by result: exported, dropped when the
+	// buffer was full, or failed when the exporter gave up on them.
+	AuditEvents = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "pygorp_audit_events_total",
+		Help: "Audit events handed to the SIEM exporter.",
+	}, []string{"result"})
+
+	// RequestDuration observes handling time per route, for latency
+	// percentiles and budgets.
+	RequestDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "pygorp_request_duration_seconds",
+		Help:    "Time taken to handle requests, by route.",
+		Buckets: []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+	}, []string{"route"})
+
+	// ShedRequests counts requests turned away with 503 by reason:
+	// in_flight when too many requests were being handled, or latency when
+	// a route was over its latency budget.
+	ShedRequests = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "pygorp_shed_requests_total",
+		Help: "Requests rejected by load shedding.",
+	}, []string{"route", "reason"})
+
+	// ReplicaReads counts reads sent to the region-local replica by result:
+	// replica when it answered, or fallback when it could not be reached
+	// and the primary answered instead.
+	ReplicaReads = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "pygorp_replica_reads_total",
+		Help: "Reads routed to the region-local database replica.",
+	}, []string{"result"})
+
+	// DeprecatedRequests counts requests to deprecated routes, with an
+	// empty field, and requests using deprecated fields, by route and field.
+	DeprecatedRequests = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "pygorp_deprecated_requests_total",
+		Help: "Requests using deprecated routes or fields.",
+	}, []string{"route", "field"})
+
+	// RetentionRecords counts records purged or anonymized by retention
+	// rules.
+	RetentionRecords = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "pygorp_retention_records_total",
+		Help: "Records purged or anonymized by retention rules.",
+	}, []string{"rule", "action"})
+
+	// Users counts users by status: active, or pending_deletion.
+	Users = factory.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "pygorp_users",
+		Help: "Users, by status.",
+	}, []string{"status"})
+
+	// DailySignups counts users created since midnight UTC.
+	DailySignups = factory.NewGauge(prometheus.GaugeOpts{
+		Name: "pygorp_daily_signups",
+		Help: "Users created since midnight UTC.",
+	})
+
+	// ActiveSessions counts sessions neither expired nor revoked.
+	ActiveSessions = factory.NewGauge(prometheus.GaugeOpts{
+		Name: "pygorp_active_sessions",
+		Help: "Sessions neither expired nor revoked.",
+	})
+
+	// EmailDeliveries counts emails handed to a provider over the last 24
+	// hours by status: sent, or failed when every provider failed.
+	EmailDeliveries = factory.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "pygorp_email_deliveries_24h",
+		Help: "Emails sent or failed over the last 24 hours.",
+	}, []string{"status"})
+
+	// EmailDeliverySuccess is the share of emails over the last 24 hours
+	// that a provider accepted, NaN while there are none.
+	EmailDeliverySuccess = factory.NewGauge(prometheus.GaugeOpts{
+		Name: "pygorp_email_delivery_success_ratio",
+		Help: "Share of emails over the last 24 hours accepted by a provider.",
+	})
+
+	// SLOBurnRate is how fast each objective of a route group is burning
+	// its error budget over a window: 1 spends it exactly over the SLO
+	// period.
+	SLOBurnRate = factory.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "pygorp_slo_burn_rate",
+		Help: "Error budget burn rate, by route group, objective, and window.",
+	}, []string{"group", "objective", "window"})
+
+	// SLOAlerts counts alerts raised for objectives burning their error
+	// budget too fast, by severity: page or ticket.
+	SLOAlerts = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "pygorp_slo_alerts_total",
+		Help: "Alerts raised for fast error budget burn.",
+	}, []string{"group", "objective", "severity"})
+
+	// Leader is 1 while this instance holds the lease of a singleton
+	// background process and runs it.
+	Leader = factory.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "pygorp_leader",
+		Help: "Whether this instance leads, by singleton process lease.",
+	}, []string{"lease"})
+
+	// KPICollected is when each business KPI collector last succeeded, so
+	// dashboards can tell stale values apart.
+	KPICollected = factory.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "pygorp_kpi_collected_timestamp_seconds",
+		Help: "Unix time of the last successful collection, by KPI collector.",
+	}, []string{"collector"})
+)
+
+// Handler exposes all registered metrics in the Prometheus text format.
+func Handler() gin.HandlerFunc {
+	return gin.WrapH(promhttp.Handler())
+}
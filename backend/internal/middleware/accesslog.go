@@ -0,0 +1,155 @@
+package middleware
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"pygorp/backend/internal/config"
+	"pygorp/backend/internal/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Access log formats, as named by Apache.
+const (
+	AccessLogCommon   = "common"
+	AccessLogCombined = "combined"
+)
+
+const clfTime = "02/Jan/2006:15:04:05 -0700"
+
+// accessLog is the destination named by ACCESS_LOG, shared by every router.
+var accessLog struct {
+	once sync.Once
+	mu   sync.Mutex
+	path string
+	w    io.Writer
+	file *os.File
+}
+
+// AccessLog writes a line per request in Common or Combined Log Format
+// (ACCESS_LOG_FORMAT, default combined) to ACCESS_LOG: "stdout", "stderr",
+// or a file path. It does nothing when ACCESS_LOG is unset. Files are
+// appended to and reopened on every config reload (SIGHUP), so logrotate
+// can move them. Query strings are scrubbed like the request log.
+func AccessLog() gin.HandlerFunc {
+	accessLog.once.Do(openAccessLog)
+	if accessLog.path == "" {
+		return func(c *gin.Context) { c.Next() }
+	}
+	format := os.Getenv("ACCESS_LOG_FORMAT")
+	if format == "" {
+		format = AccessLogCombined
+	}
+	if format != AccessLogCommon && format != AccessLogCombined {
+		log.Printf("Unknown ACCESS_LOG_FORMAT %q, using %s", format, AccessLogCombined)
+		format = AccessLogCombined
+	}
+
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		accessLog.mu.Lock()
+		w := accessLog.w
+		accessLog.mu.Unlock()
+		if w == nil {
+			return
+		}
+		io.WriteString(w, accessLine(c, start, format))
+	}
+}
+
+func openAccessLog() {
+	accessLog.path = os.Getenv("ACCESS_LOG")
+	switch accessLog.path {
+	case "":
+		return
+	case "stdout":
+		accessLog.w = os.Stdout
+		return
+	case "stderr":
+		accessLog.w = os.Stderr
+		return
+	}
+	if err := reopenAccessLog(); err != nil {
+		log.Printf("Access log disabled: %v", err)
+		return
+	}
+	config.OnReload(func(*config.Runtime) error {
+		if err := reopenAccessLog(); err != nil {
+			log.Printf("Failed to reopen access log, keeping the old file: %v", err)
+		}
+		return nil
+	})
+}
+
+func reopenAccessLog() error {
+	f, err := os.OpenFile(accessLog.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	accessLog.mu.Lock()
+	old := accessLog.file
+	accessLog.file, accessLog.w = f, f
+	accessLog.mu.Unlock()
+	if old != nil {
+		old.Close()
+	}
+	return nil
+}
+
+// accessLine formats one request, e.g.
+//
+//	203.0.113.7 - 42 [16/Oct/2026:10:20:24 +0000] "GET /api/v1/users?limit=20 HTTP/1.1" 200 5120 "-" "curl/8.5.0"
+//
+// The user is the authenticated subject, if any.
+func accessLine(c *gin.Context, start time.Time, format string) string {
+	target := c.Request.URL.Path
+	if q := c.Request.URL.RawQuery; q != "" {
+		target += "?" + logger.RedactQuery(q)
+	}
+	size := "-"
+	if n := c.Writer.Size(); n > 0 {
+		size = strconv.Itoa(n)
+	}
+
+	line := fmt.Sprintf(`%s - %s [%s] "%s %s %s" %d %s`,
+		c.ClientIP(), orDash(clfEscape(c.GetString("auth_subject"))), start.Format(clfTime),
+		c.Request.Method, clfEscape(target), c.Request.Proto, c.Writer.Status(), size)
+	if format == AccessLogCombined {
+		line += fmt.Sprintf(` "%s" "%s"`, orDash(clfEscape(logger.RedactString(c.Request.Referer()))), orDash(clfEscape(c.Request.UserAgent())))
+	}
+	return line + "\n"
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// clfEscape escapes quotes, backslashes, and control characters the way
+// Apache does, so a field cannot break the line apart.
+func clfEscape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch ch := s[i]; {
+		case ch == '"' || ch == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(ch)
+		case ch < 0x20 || ch == 0x7f:
+			fmt.Fprintf(&b, `\x%02x`, ch)
+		default:
+			b.WriteByte(ch)
+		}
+	}
+	return b.String()
+}
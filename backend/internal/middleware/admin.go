@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"os"
+	"strings"
+
+	"pygorp/backend/internal/render"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminAuth guards operator endpoints with the shared ADMIN_TOKEN, sent as a
+// bearer token. When ADMIN_TOKEN is unset the admin API is disabled. The
+// token grants the "admin" scope.
+func AdminAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := os.Getenv("ADMIN_TOKEN")
+		if token == "" {
+			render.AbortJSON(c, http.StatusForbidden, gin.H{"error": "Admin API is disabled"})
+			return
+		}
+
+		provided := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			render.AbortJSON(c, http.StatusUnauthorized, gin.H{"error": "Invalid admin token"})
+			return
+		}
+		c.Set("auth_scopes", []string{"admin"})
+		c.Next()
+	}
+}
@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"net/http"
+
+	"pygorp/backend/internal/audit"
+	"pygorp/backend/internal/audit/exporters"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Audit records writes and refused requests as audit events, with the route
+// name as the action. Reads are not recorded; they are too frequent to be
+// useful in a SIEM. Neither are requests that matched no route.
+func Audit() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if c.FullPath() == "" {
+			return
+		}
+		// Requests refused before the route's own middleware ran, e.g. by
+		// authentication, have no route name yet.
+		action := c.GetString("route_name")
+		if action == "" {
+			action = c.Request.Method + " " + c.FullPath()
+		}
+
+		status := c.Writer.Status()
+		outcome := exporters.OutcomeSuccess
+		switch {
+		case status == http.StatusUnauthorized || status == http.StatusForbidden:
+			outcome = exporters.OutcomeDenied
+		case status >= 400:
+			outcome = exporters.OutcomeFailure
+		}
+		if outcome != exporters.OutcomeDenied && !isWrite(c.Request.Method) {
+			return
+		}
+
+		audit.Record(audit.Event{
+			Action:  action,
+			Outcome: outcome,
+			Actor: exporters.Actor{
+				UserID:         c.GetString("auth_subject"),
+				ServiceAccount: c.GetString("auth_service_account"),
+				Service:        c.GetString("service"),
+			},
+			Method:    c.Request.Method,
+			Path:      c.Request.URL.Path,
+			Status:    status,
+			RequestID: c.GetString("request_id"),
+			ClientIP:  c.ClientIP(),
+			UserAgent: c.Request.UserAgent(),
+		})
+	}
+}
+
+func isWrite(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,87 @@
+package middleware
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"pygorp/backend/internal/auth"
+	"pygorp/backend/internal/render"
+	"pygorp/backend/internal/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Authenticate reads a session access token from the Authorization header
+// and stores its user ("auth_subject"), session ("auth_session"), sign-in
+// method ("auth_method"), and scopes ("auth_scopes") in the context.
+// Service account API keys set the account name ("auth_service_account")
+// and its scopes instead. Personal access tokens set the user, the token
+// ("auth_token"), the method "pat", and the token's scopes, but no session.
+// Requests without a token continue anonymously; invalid tokens are
+// rejected. When AUTH_SECRET is unset only API keys and personal access
+// tokens are read.
+func Authenticate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if ok && strings.HasPrefix(token, auth.APIKeyPrefix) {
+			authenticateAPIKey(c, token)
+			return
+		}
+		if ok && strings.HasPrefix(token, auth.PersonalTokenPrefix) {
+			authenticatePersonalToken(c, token)
+			return
+		}
+		if !auth.Enabled() || !ok {
+			c.Next()
+			return
+		}
+
+		claims, err := auth.Verify(token)
+		if err != nil {
+			render.AbortJSON(c, http.StatusUnauthorized, gin.H{"error": "Invalid access token"})
+			return
+		}
+		c.Set("auth_subject", claims.Subject)
+		c.Set("auth_session", claims.Session)
+		c.Set("auth_method", claims.Method)
+		c.Set("auth_scopes", claims.Scopes())
+		c.Next()
+	}
+}
+
+func authenticateAPIKey(c *gin.Context, key string) {
+	account, err := repository.AuthenticateAPIKey(c.Request.Context(), key)
+	if errors.Is(err, repository.ErrNotFound) {
+		render.AbortJSON(c, http.StatusUnauthorized, gin.H{"error": "Invalid API key"})
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to authenticate API key: %v", err)
+		render.AbortJSON(c, http.StatusServiceUnavailable, gin.H{"error": "Authentication is unavailable"})
+		return
+	}
+	c.Set("auth_service_account", account.Name)
+	c.Set("auth_scopes", account.Scopes)
+	c.Next()
+}
+
+func authenticatePersonalToken(c *gin.Context, secret string) {
+	token, err := repository.AuthenticatePersonalToken(c.Request.Context(), secret)
+	if errors.Is(err, repository.ErrNotFound) {
+		render.AbortJSON(c, http.StatusUnauthorized, gin.H{"error": "Invalid personal access token"})
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to authenticate personal access token: %v", err)
+		render.AbortJSON(c, http.StatusServiceUnavailable, gin.H{"error": "Authentication is unavailable"})
+		return
+	}
+	c.Set("auth_subject", strconv.Itoa(token.UserID))
+	c.Set("auth_token", strconv.Itoa(token.ID))
+	c.Set("auth_method", "pat")
+	c.Set("auth_scopes", token.Scopes)
+	c.Next()
+}
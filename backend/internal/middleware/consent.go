@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"pygorp/backend/internal/config"
+	"pygorp/backend/internal/consent"
+	"pygorp/backend/internal/render"
+
+	"github.com/gin-gonic/gin"
+)
+
+// consentURL is where users give the consents they owe.
+const consentURL = "/api/v1/me/consents"
+
+// consentExempt lists path prefixes users can always reach while they owe
+// a consent, so they can read the policies and accept them.
+var consentExempt = []string{consentURL, "/api/v1/policy-documents"}
+
+// RequireConsent gates the route group with prefix group according to its
+// entry in the runtime config's consent_gates. Requests by users who have
+// not accepted the current version of a policy requiring consent are
+// answered with the gate's status, 451 or 409, and code
+// "consent_required", listing the versions to accept and pointing to
+// consentURL. Groups without a gate, exempt paths, service accounts, and
+// anonymous requests are let through. It relies on Authenticate having run.
+func RequireConsent(group string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		gate, ok := config.Current().ConsentGateFor(group)
+		if !ok {
+			c.Next()
+			return
+		}
+		userID, err := strconv.Atoi(c.GetString("auth_subject"))
+		if err != nil {
+			c.Next()
+			return
+		}
+		for _, prefix := range slices.Concat(consentExempt, gate.Exempt) {
+			if strings.HasPrefix(c.Request.URL.Path, prefix) {
+				c.Next()
+				return
+			}
+		}
+
+		pending, err := consent.Pending(c.Request.Context(), userID)
+		if err != nil {
+			log.Printf("Failed to check consents: %v", err)
+			render.AbortJSON(c, http.StatusServiceUnavailable, gin.H{"error": "Consent check is unavailable"})
+			return
+		}
+		if len(pending) == 0 {
+			c.Next()
+			return
+		}
+
+		owed := make([]gin.H, len(pending))
+		for i, d := range pending {
+			owed[i] = gin.H{
+				"kind":    d.Kind,
+				"version": d.Version,
+				"title":   d.Title,
+				"url":     fmt.Sprintf("/api/v1/policy-documents/%s/%d", d.Kind, d.Version),
+			}
+		}
+		c.Header("Link", `<`+consentURL+`>; rel="consent"`)
+		render.AbortJSON(c, gate.StatusCode(), gin.H{
+			"error":       "Accept the current policies to continue",
+			"code":        "consent_required",
+			"consents":    owed,
+			"consent_url": consentURL,
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"pygorp/backend/internal/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxScannedBody is how much of a request body is searched for deprecated
+// fields. Larger bodies are passed on unscanned.
+const maxScannedBody = 1 << 20
+
+// DeprecationNotice describes a deprecated route or request field: since
+// when, when it stops working, and, for routes, the path of the route
+// replacing it. Zero times and an empty Successor are left out of the
+// response.
+type DeprecationNotice struct {
+	Since     time.Time
+	Sunset    time.Time
+	Successor string
+}
+
+// Deprecation announces deprecations in the response headers and counts
+// them in pygorp_deprecated_requests_total. route is set when the route
+// itself is deprecated; fields are deprecated query parameters and
+// top-level JSON body fields, announced only when the request uses them,
+// with their names in X-Deprecated-Fields.
+//
+// Deprecation carries the earliest Since as an RFC 9745 date, or "true" when
+// none is known, and Sunset the earliest RFC 8594 sunset.
+func Deprecation(name string, route *DeprecationNotice, fields map[string]DeprecationNotice) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var notices []DeprecationNotice
+		if route != nil {
+			notices = append(notices, *route)
+			metrics.DeprecatedRequests.WithLabelValues(name, "").Inc()
+		}
+		used := usedFields(c, fields)
+		for _, field := range used {
+			notices = append(notices, fields[field])
+			metrics.DeprecatedRequests.WithLabelValues(name, field).Inc()
+		}
+		if len(notices) == 0 {
+			c.Next()
+			return
+		}
+
+		var since, sunset time.Time
+		for _, n := range notices {
+			if !n.Since.IsZero() && (since.IsZero() || n.Since.Before(since)) {
+				since = n.Since
+			}
+			if !n.Sunset.IsZero() && (sunset.IsZero() || n.Sunset.Before(sunset)) {
+				sunset = n.Sunset
+			}
+		}
+		if since.IsZero() {
+			c.Header("Deprecation", "true")
+		} else {
+			c.Header("Deprecation", "@"+strconv.FormatInt(since.Unix(), 10))
+		}
+		if !sunset.IsZero() {
+			c.Header("Sunset", sunset.UTC().Format(http.TimeFormat))
+		}
+		if route != nil && route.Successor != "" {
+			c.Header("Link", `<`+route.Successor+`>; rel="successor-version"`)
+		}
+		if len(used) > 0 {
+			c.Header("X-Deprecated-Fields", strings.Join(used, ", "))
+		}
+		c.Next()
+	}
+}
+
+// usedFields returns the deprecated fields a request sets in its query
+// string or JSON body, sorted.
+func usedFields(c *gin.Context, fields map[string]DeprecationNotice) []string {
+	if len(fields) == 0 {
+		return nil
+	}
+	seen := map[string]bool{}
+	query := c.Request.URL.Query()
+	for field := range fields {
+		if query.Has(field) {
+			seen[field] = true
+		}
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(c.GetHeader("Content-Type"))
+	if mediaType == "application/json" && c.Request.Body != nil && c.Request.Body != http.NoBody {
+		head, _ := io.ReadAll(io.LimitReader(c.Request.Body, maxScannedBody+1))
+		body := c.Request.Body
+		c.Request.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(head), body), body}
+
+		var object map[string]json.RawMessage
+		if len(head) <= maxScannedBody && json.Unmarshal(head, &object) == nil {
+			for field := range fields {
+				if _, ok := object[field]; ok {
+					seen[field] = true
+				}
+			}
+		}
+	}
+
+	used := make([]string, 0, len(seen))
+	for field := range seen {
+		used = append(used, field)
+	}
+	sort.Strings(used)
+	return used
+}
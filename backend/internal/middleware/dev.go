@@ -0,0 +1,20 @@
+package middleware
+
+import (
+	"net/http"
+
+	"pygorp/backend/internal/render"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DevOnly hides routes unless the server runs in debug mode.
+func DevOnly() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !gin.IsDebugging() {
+			render.AbortJSON(c, http.StatusNotFound, gin.H{"error": "Not found"})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,128 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"runtime/debug"
+	"strings"
+
+	"pygorp/backend/internal/render"
+
+	"github.com/gin-gonic/gin"
+)
+
+// internalDetail matches text that should not reach clients in production:
+// SQL, database driver errors, network errors, and stack frames.
+var internalDetail = regexp.MustCompile(`(?i)\bpq:|\bsql:|\bselect\b.+\bfrom\b|\binsert into\b|\bupdate\b.+\bset\b|\bdelete from\b|` +
+	`goroutine \d+|\.go:\d+|\bdial (tcp|unix)\b|connection refused|no such host`)
+
+const genericError = "Internal server error"
+
+// Errors recovers panics and renders server errors according to ENV. Errors
+// attached with c.Error and panics are always logged with the request ID.
+// For 5xx responses, ENV=production replaces strings that look like
+// internal details (SQL, driver errors, stack traces) with a generic
+// message; other environments add a "debug" object with the attached
+// errors and, for panics, the stack.
+func Errors() gin.HandlerFunc {
+	production := os.Getenv("ENV") == "production"
+
+	return func(c *gin.Context) {
+		w := &errorWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+
+		var stack []string
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					if r == http.ErrAbortHandler {
+						panic(r)
+					}
+					stack = strings.Split(strings.TrimSpace(string(debug.Stack())), "\n")
+					log.Printf("Panic serving %s %s (request %s): %v\n%s",
+						c.Request.Method, c.Request.URL.Path, c.GetString("request_id"), r, strings.Join(stack, "\n"))
+					c.Error(fmt.Errorf("panic: %v", r))
+					render.AbortJSON(c, http.StatusInternalServerError, gin.H{"error": genericError})
+				}
+			}()
+			c.Next()
+		}()
+
+		for _, err := range c.Errors {
+			log.Printf("Error serving %s %s (request %s, status %d): %v",
+				c.Request.Method, c.Request.URL.Path, c.GetString("request_id"), w.Status(), err.Err)
+		}
+		if w.buffered {
+			w.ResponseWriter.Write(renderError(c, w.body.Bytes(), production, stack))
+		}
+	}
+}
+
+// renderError applies the environment's policy to a 5xx response body.
+// Bodies that are not JSON objects are replaced in production and passed
+// through elsewhere.
+func renderError(c *gin.Context, body []byte, production bool, stack []string) []byte {
+	var fields map[string]interface{}
+	if err := json.Unmarshal(body, &fields); err != nil {
+		if !production {
+			return body
+		}
+		c.Header("Content-Type", "application/json; charset=utf-8")
+		fields = map[string]interface{}{"error": genericError}
+	}
+
+	if production {
+		for key, value := range fields {
+			if s, ok := value.(string); ok && internalDetail.MatchString(s) {
+				log.Printf("Redacted %q from response (request %s): %s", key, c.GetString("request_id"), s)
+				fields[key] = genericError
+			}
+		}
+	} else {
+		debugInfo := gin.H{"route": c.GetString("route_name")}
+		if len(c.Errors) > 0 {
+			debugInfo["errors"] = c.Errors.Errors()
+		}
+		if stack != nil {
+			debugInfo["stack"] = stack
+		}
+		fields["debug"] = debugInfo
+	}
+	if id := c.GetString("request_id"); id != "" {
+		fields["request_id"] = id
+	}
+
+	rendered, err := json.Marshal(fields)
+	if err != nil {
+		return body
+	}
+	return rendered
+}
+
+// errorWriter holds back the body of 5xx responses so Errors can render it
+// once the handler is done. Other responses are written through.
+type errorWriter struct {
+	gin.ResponseWriter
+	body     bytes.Buffer
+	buffered bool
+}
+
+func (w *errorWriter) Write(data []byte) (int, error) {
+	if w.Status() < http.StatusInternalServerError {
+		return w.ResponseWriter.Write(data)
+	}
+	if !w.buffered {
+		w.buffered = true
+		w.Header().Del("Content-Length")
+	}
+	return w.body.Write(data)
+}
+
+func (w *errorWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"pygorp/backend/internal/config"
+	"pygorp/backend/internal/loadshed"
+	"pygorp/backend/internal/metrics"
+	"pygorp/backend/internal/render"
+
+	"github.com/gin-gonic/gin"
+)
+
+// breachHold is how long a route over its latency budget keeps the server
+// marked as overloaded.
+const breachHold = 5 * time.Second
+
+// lastBreach is when a request last found its route over budget, in Unix
+// nanoseconds.
+var lastBreach atomic.Int64
+
+// LoadShed tracks the route's latency and, while the server is overloaded,
+// answers 503 with Retry-After instead of handling the request. Overloaded
+// means too many requests in flight or a route over its latency budget.
+// Low-priority routes are shed first; normal routes only while they are
+// over budget themselves; critical routes never.
+func LoadShed(route, priority string) gin.HandlerFunc {
+	observer := metrics.RequestDuration.WithLabelValues(route)
+	return func(c *gin.Context) {
+		cfg := config.Current().LoadShed
+		now := time.Now()
+
+		overBudget := false
+		if budget := cfg.BudgetFor(route); budget > 0 && loadshed.Default.P99(route) > budget {
+			overBudget = true
+			lastBreach.Store(now.UnixNano())
+		}
+		reason := ""
+		switch {
+		case cfg.MaxInFlight > 0 && loadshed.Default.InFlight() >= int64(cfg.MaxInFlight):
+			reason = "in_flight"
+		case now.Sub(time.Unix(0, lastBreach.Load())) < breachHold:
+			reason = "latency"
+		}
+
+		if reason != "" && (priority == loadshed.PriorityLow || (priority == loadshed.PriorityNormal && overBudget)) {
+			metrics.ShedRequests.WithLabelValues(route, reason).Inc()
+			c.Header("Retry-After", strconv.Itoa(cfg.RetryAfterSeconds()))
+			render.AbortJSON(c, http.StatusServiceUnavailable, gin.H{
+				"error": "The server is overloaded; retry later",
+				"code":  "overloaded",
+			})
+			return
+		}
+
+		done := loadshed.Default.Begin(route)
+		defer func() {
+			done()
+			observer.Observe(time.Since(now).Seconds())
+		}()
+		c.Next()
+	}
+}
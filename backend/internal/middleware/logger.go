@@ -0,0 +1,97 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"pygorp/backend/internal/config"
+	"pygorp/backend/internal/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxLoggedBody caps how much of a request body LogBody keeps.
+const maxLoggedBody = 4 << 10
+
+const logBodyKey = "log_body"
+
+// RequestLogger writes one structured record per request. Failed requests
+// (status 400 and up, or with handler errors) are always logged, at warn
+// or error level; successful ones are sampled at the configured
+// log_sample_rate. Query strings are scrubbed of emails and credentials,
+// and routes that opt in with LogBody also get their headers and body.
+func RequestLogger() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		failed := status >= 400 || len(c.Errors) > 0
+		if !failed {
+			if rate := config.Current().LogSampleRate; rate < 1 && rand.Float64() >= rate {
+				return
+			}
+		}
+
+		attrs := []slog.Attr{
+			slog.String("method", c.Request.Method),
+			slog.String("path", c.Request.URL.Path),
+			slog.Int("status", status),
+			slog.Duration("latency", time.Since(start)),
+			slog.Int("bytes", max(c.Writer.Size(), 0)),
+			slog.String("client_ip", c.ClientIP()),
+			slog.String("request_id", c.GetString("request_id")),
+		}
+		if q := c.Request.URL.RawQuery; q != "" {
+			attrs = append(attrs, slog.String("query", logger.RedactQuery(q)))
+		}
+		if route := c.GetString("route_name"); route != "" {
+			attrs = append(attrs, slog.String("route", route))
+		}
+		if body, ok := c.Get(logBodyKey); ok {
+			attrs = append(attrs,
+				slog.Any("headers", logger.RedactHeaders(c.Request.Header)),
+				slog.String("body", body.(string)))
+		}
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, slog.String("errors", logger.RedactString(c.Errors.String())))
+		}
+
+		level := slog.LevelInfo
+		switch {
+		case status >= 500:
+			level = slog.LevelError
+		case failed:
+			level = slog.LevelWarn
+		}
+		slog.LogAttrs(c.Request.Context(), level, "request", attrs...)
+	}
+}
+
+// LogBody keeps the start of the request body, scrubbed, for RequestLogger.
+// The handler still reads the whole body.
+func LogBody() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Body == nil || c.Request.Body == http.NoBody {
+			c.Next()
+			return
+		}
+		head, _ := io.ReadAll(io.LimitReader(c.Request.Body, maxLoggedBody+1))
+		body := c.Request.Body
+		c.Request.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(head), body), body}
+
+		logged := logger.RedactBody(head[:min(len(head), maxLoggedBody)])
+		if len(head) > maxLoggedBody {
+			logged += "...(truncated)"
+		}
+		c.Set(logBodyKey, logged)
+		c.Next()
+	}
+}
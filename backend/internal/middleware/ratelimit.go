@@ -0,0 +1,120 @@
+package middleware
+
+import (
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"pygorp/backend/internal/clock"
+	"pygorp/backend/internal/config"
+	"pygorp/backend/internal/quota"
+	"pygorp/backend/internal/render"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/time/rate"
+)
+
+type client struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// RateLimit limits requests using the token bucket settings configured for
+// the given rate-limit class. Users whose org is on a plan share one bucket
+// per org, sized by the plan's limit for the class, and count against the
+// plan's daily quota; everyone else is limited per client IP. Limits are
+// read per request, so reloaded config and changed plans apply to existing
+// clients.
+//
+// Limited responses carry X-RateLimit-Limit (the burst),
+// X-RateLimit-Remaining, and X-RateLimit-Reset (seconds until the bucket is
+// full again); orgs with a quota also get X-Quota-Limit, X-Quota-Remaining,
+// and X-Quota-Reset (seconds until midnight UTC). Rejections set
+// Retry-After.
+func RateLimit(class string) gin.HandlerFunc {
+	var (
+		mu      sync.Mutex
+		clients = map[string]*client{}
+	)
+
+	go func() {
+		for range time.Tick(time.Minute) {
+			mu.Lock()
+			for key, cl := range clients {
+				if time.Since(cl.lastSeen) > 3*time.Minute {
+					delete(clients, key)
+				}
+			}
+			mu.Unlock()
+		}
+	}()
+
+	return func(c *gin.Context) {
+		cfg := config.Current().RateLimitFor(class)
+		key := c.ClientIP()
+		tenant, err := quota.ForUser(c.Request.Context(), c.GetString("auth_subject"))
+		if err != nil {
+			log.Printf("Failed to look up plan, limiting per client IP: %v", err)
+		}
+		if tenant != nil {
+			if limit, ok := tenant.Plan.RateLimitFor(class); ok {
+				cfg, key = limit, "org:"+strconv.Itoa(tenant.OrgID)
+			}
+		}
+		h := c.Writer.Header()
+
+		if cfg.RequestsPerSecond > 0 {
+			limit := rate.Limit(cfg.RequestsPerSecond)
+			now := time.Now()
+			mu.Lock()
+			cl, ok := clients[key]
+			if !ok {
+				cl = &client{limiter: rate.NewLimiter(limit, cfg.Burst)}
+				clients[key] = cl
+			}
+			if cl.limiter.Limit() != limit || cl.limiter.Burst() != cfg.Burst {
+				cl.limiter.SetLimit(limit)
+				cl.limiter.SetBurst(cfg.Burst)
+			}
+			cl.lastSeen = now
+			allowed := cl.limiter.AllowN(now, 1)
+			tokens := cl.limiter.TokensAt(now)
+			mu.Unlock()
+
+			h.Set("X-RateLimit-Limit", strconv.Itoa(cfg.Burst))
+			h.Set("X-RateLimit-Remaining", strconv.Itoa(max(int(tokens), 0)))
+			h.Set("X-RateLimit-Reset", seconds((float64(cfg.Burst)-tokens)/cfg.RequestsPerSecond))
+			if !allowed {
+				h.Set("Retry-After", seconds((1-tokens)/cfg.RequestsPerSecond))
+				render.AbortJSON(c, http.StatusTooManyRequests, gin.H{"error": "Rate limit exceeded"})
+				return
+			}
+		}
+
+		if tenant != nil {
+			daily := tenant.Plan.DailyQuota
+			used, ok := quota.Use(tenant.OrgID, daily)
+			if daily > 0 {
+				now := clock.Now()
+				reset := seconds(quota.NextDay(now).Sub(now).Seconds())
+				h.Set("X-Quota-Limit", strconv.Itoa(daily))
+				h.Set("X-Quota-Remaining", strconv.FormatInt(max(int64(daily)-used, 0), 10))
+				h.Set("X-Quota-Reset", reset)
+				if !ok {
+					h.Set("Retry-After", reset)
+					render.AbortJSON(c, http.StatusTooManyRequests, gin.H{"error": "Daily quota exceeded"})
+					return
+				}
+			}
+		}
+		c.Next()
+	}
+}
+
+// seconds formats a wait for a header, rounded up to whole seconds.
+func seconds(s float64) string {
+	return strconv.Itoa(max(int(math.Ceil(s)), 0))
+}
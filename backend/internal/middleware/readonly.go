@@ -0,0 +1,89 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"pygorp/backend/internal/clock"
+	"pygorp/backend/internal/config"
+	"pygorp/backend/internal/render"
+
+	"github.com/gin-gonic/gin"
+)
+
+// readOnlyExempt lists path prefixes that keep accepting writes in read-only
+// mode: authentication, and the admin API so the mode can be switched off.
+var readOnlyExempt = []string{"/api/v1/auth/", "/admin/"}
+
+var (
+	readOnlyMu       sync.Mutex
+	readOnlyOverride *bool
+	readOnlySince    time.Time
+)
+
+// ReadOnlyStatus describes whether writes are currently rejected and why.
+type ReadOnlyStatus struct {
+	Enabled bool       `json:"enabled"`
+	Source  string     `json:"source"`
+	Since   *time.Time `json:"since,omitempty"`
+}
+
+// ReadOnly reports the active read-only mode. An admin override wins over
+// the runtime config.
+func ReadOnly() ReadOnlyStatus {
+	readOnlyMu.Lock()
+	defer readOnlyMu.Unlock()
+
+	if readOnlyOverride != nil {
+		since := readOnlySince
+		return ReadOnlyStatus{Enabled: *readOnlyOverride, Source: "admin", Since: &since}
+	}
+	return ReadOnlyStatus{Enabled: config.Current().ReadOnly, Source: "config"}
+}
+
+// SetReadOnly overrides the configured read-only mode. A nil value clears the
+// override so the config applies again.
+func SetReadOnly(enabled *bool) ReadOnlyStatus {
+	readOnlyMu.Lock()
+	readOnlyOverride = enabled
+	readOnlySince = clock.Now()
+	readOnlyMu.Unlock()
+
+	switch {
+	case enabled == nil:
+		log.Println("Read-only override cleared")
+	case *enabled:
+		log.Println("Read-only mode enabled")
+	default:
+		log.Println("Read-only mode disabled")
+	}
+	return ReadOnly()
+}
+
+// RejectWritesWhenReadOnly answers mutating requests with 503 while
+// read-only mode is on. The mode is checked on every request.
+func RejectWritesWhenReadOnly() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+		for _, prefix := range readOnlyExempt {
+			if strings.HasPrefix(c.Request.URL.Path, prefix) {
+				c.Next()
+				return
+			}
+		}
+
+		if ReadOnly().Enabled {
+			c.Header("Retry-After", "60")
+			render.AbortJSON(c, http.StatusServiceUnavailable, gin.H{"error": "Service is in read-only mode"})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"os"
+	"strings"
+
+	"pygorp/backend/internal/scim"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ScimAuth guards the SCIM provisioning API with the SCIM_TOKEN bearer
+// token configured in the identity provider. When SCIM_TOKEN is unset the
+// SCIM API is disabled. The token grants the "scim" scope.
+func ScimAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := os.Getenv("SCIM_TOKEN")
+		if token == "" {
+			c.Header("Content-Type", scim.ContentType)
+			c.AbortWithStatusJSON(http.StatusForbidden, scim.NewError(http.StatusForbidden, "", "SCIM API is disabled"))
+			return
+		}
+
+		provided := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			c.Header("Content-Type", scim.ContentType)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, scim.NewError(http.StatusUnauthorized, "", "Invalid SCIM token"))
+			return
+		}
+		c.Set("auth_scopes", []string{"scim"})
+		c.Next()
+	}
+}
@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"pygorp/backend/internal/auth"
+	"pygorp/backend/internal/render"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireScopes rejects requests whose access token does not grant every
+// one of scopes: 401 without a token or API key, 403 with one that lacks a
+// scope. It relies on Authenticate having run. Anonymous requests are let
+// through when AUTH_SECRET is unset, since no session tokens can be issued
+// then.
+func RequireScopes(scopes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		granted, ok := c.Get("auth_scopes")
+		if !ok && !auth.Enabled() {
+			c.Next()
+			return
+		}
+		if !ok {
+			c.Header("WWW-Authenticate", `Bearer scope="`+strings.Join(scopes, " ")+`"`)
+			render.AbortJSON(c, http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+			return
+		}
+
+		for _, scope := range scopes {
+			if !slices.Contains(granted.([]string), scope) {
+				c.Header("WWW-Authenticate", `Bearer error="insufficient_scope", scope="`+strings.Join(scopes, " ")+`"`)
+				render.AbortJSON(c, http.StatusForbidden, gin.H{"error": "Token is missing scope " + scope})
+				return
+			}
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,20 @@
+package middleware
+
+import (
+	"pygorp/backend/internal/region"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ServedByHeader tells clients and load balancers which region and instance
+// answered a request.
+const ServedByHeader = "X-Served-By"
+
+// ServedBy sets X-Served-By on every response.
+func ServedBy() gin.HandlerFunc {
+	servedBy := region.ServedBy()
+	return func(c *gin.Context) {
+		c.Header(ServedByHeader, servedBy)
+		c.Next()
+	}
+}
@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"time"
+
+	"pygorp/backend/internal/config"
+	"pygorp/backend/internal/slo"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TrackSLO counts the route's requests toward the objectives of its route
+// group, the one with prefix group, while the runtime config sets any.
+// Requests shed or rejected further down the chain count too, since
+// clients see them fail all the same.
+func TrackSLO(group string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		objectives, ok := config.Current().SLOs[group]
+		if !ok {
+			c.Next()
+			return
+		}
+		start := time.Now()
+		c.Next()
+		slo.Record(group, c.Writer.Status(), time.Since(start), objectives.LatencyThreshold())
+	}
+}
@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+
+	"pygorp/backend/internal/render"
+	"pygorp/backend/internal/svcauth"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ServiceAuth accepts only requests carrying a valid service token for
+// audience. The calling service is stored in the context as "service".
+func ServiceAuth(audience string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, err := svcauth.Verify(c.GetHeader(svcauth.Header), audience)
+		if err != nil {
+			if errors.Is(err, svcauth.ErrDisabled) {
+				render.AbortJSON(c, http.StatusForbidden, gin.H{"error": "Internal API is disabled"})
+				return
+			}
+			render.AbortJSON(c, http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.Set("service", claims.Issuer)
+		if claims.Subject != "" {
+			c.Set("auth_subject", claims.Subject)
+		}
+		c.Next()
+	}
+}
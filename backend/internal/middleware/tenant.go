@@ -0,0 +1,88 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"pygorp/backend/internal/database"
+	"pygorp/backend/internal/render"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Tenant runs the request in a transaction scoped to the authenticated user
+// ("auth_subject") and the org they belong to, which it stores as
+// "auth_org", so row-level security policies filter every query made
+// through the repository. It must come after authentication. It does nothing
+// unless DB_RLS=true and the caller is a user; admins, service accounts, and
+// anonymous callers are not scoped.
+//
+// The transaction commits when the handler responds with a status below 400
+// and rolls back otherwise. The response is only sent once the transaction
+// is done; if the commit fails the client gets a 500 instead. Requests that
+// already run in a transaction, such as the items of an atomic batch, keep
+// it.
+func Tenant() gin.HandlerFunc {
+	enabled := database.RLSEnabled()
+	return func(c *gin.Context) {
+		tenant := database.Tenant{UserID: numericID(c.GetString("auth_subject"))}
+		if !enabled || tenant.UserID == "" || database.InTx(c.Request.Context()) {
+			c.Next()
+			return
+		}
+
+		ctx, tx, err := database.BeginTenant(c.Request.Context(), tenant)
+		if err != nil {
+			log.Printf("Failed to begin tenant transaction: %v", err)
+			render.AbortJSON(c, http.StatusServiceUnavailable, gin.H{"error": "Database unavailable"})
+			return
+		}
+		c.Request = c.Request.WithContext(ctx)
+		if orgID := database.TenantFrom(ctx).OrgID; orgID != "" {
+			c.Set("auth_org", orgID)
+		}
+
+		// The response is held back until the transaction is done, so a
+		// client never sees success for changes that failed to commit.
+		header := c.Writer.Header().Clone()
+		w := render.NewBuffer(c.Writer, render.HoldAll)
+		c.Writer = w
+		defer func() {
+			c.Writer = w.ResponseWriter
+			if p := recover(); p != nil {
+				tx.Rollback()
+				panic(p)
+			}
+			if w.Status() >= http.StatusBadRequest {
+				tx.Rollback()
+				w.Release(w.Bytes())
+				return
+			}
+			if err := tx.Commit(); err != nil {
+				log.Printf("Failed to commit tenant transaction: %v", err)
+				// Drop what the handler set for its response.
+				for key := range w.Header() {
+					w.Header().Del(key)
+				}
+				for key, values := range header {
+					w.Header()[key] = values
+				}
+				c.Error(err)
+				render.JSON(c, http.StatusInternalServerError, gin.H{"error": "Failed to save changes"})
+				return
+			}
+			w.Release(w.Bytes())
+		}()
+		c.Next()
+	}
+}
+
+// numericID returns id if it is a number and "" otherwise. Policies compare
+// the settings with integer keys, so anything else must not be set.
+func numericID(id string) string {
+	if _, err := strconv.Atoi(id); err != nil {
+		return ""
+	}
+	return id
+}
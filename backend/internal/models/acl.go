@@ -0,0 +1,9 @@
+package models
+
+// ShareRequest grants a principal, "user:<id>" or "team:<id>", read or
+// write access to a resource. Sharing again with the same principal
+// replaces the permission.
+type ShareRequest struct {
+	Principal  string `json:"principal" binding:"required" sanitize:"trim,control"`
+	Permission string `json:"permission" binding:"required,oneof=read write"`
+}
@@ -0,0 +1,87 @@
+package models
+
+import (
+	"time"
+)
+
+// Attachment is a file attached to a resource. Its bytes are in object
+// storage under StorageKey, which clients never see; they download through
+// the API, which checks access. Attachments with the same SHA256 share one
+// stored blob.
+type Attachment struct {
+	ID           int       `json:"id" db:"id"`
+	ResourceType string    `json:"resource_type" db:"resource_type"`
+	ResourceID   int       `json:"resource_id" db:"resource_id"`
+	Filename     string    `json:"filename" db:"filename"`
+	ContentType  string    `json:"content_type" db:"content_type"`
+	Size         int64     `json:"size" db:"size"`
+	SHA256       string    `json:"sha256,omitempty" db:"sha256"`
+	StorageKey   string    `json:"-" db:"storage_key"`
+	UploadedBy   *int      `json:"uploaded_by" db:"uploaded_by"`
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+}
+
+// PresignUploadRequest asks for a URL to upload a file straight to storage
+// for attaching to a project.
+type PresignUploadRequest struct {
+	OrgID       int    `json:"org_id" binding:"required,min=1"`
+	ProjectID   int    `json:"project_id" binding:"required,min=1"`
+	Filename    string `json:"filename" binding:"required,max=255"`
+	ContentType string `json:"content_type" binding:"required,max=255"`
+	Size        int64  `json:"size" binding:"required,min=1"`
+}
+
+// PresignedUpload is an upload a client sends straight to storage. URL is
+// only returned when it is created; the client PUTs the file there with
+// the same Content-Type, then completes the upload.
+type PresignedUpload struct {
+	ID           string     `json:"id" db:"id"`
+	ResourceType string     `json:"resource_type" db:"resource_type"`
+	ResourceID   int        `json:"resource_id" db:"resource_id"`
+	Filename     string     `json:"filename" db:"filename"`
+	ContentType  string     `json:"content_type" db:"content_type"`
+	Size         int64      `json:"size" db:"size"`
+	StorageKey   string     `json:"-" db:"storage_key"`
+	CreatedBy    int        `json:"created_by" db:"created_by"`
+	CreatedAt    time.Time  `json:"created_at" db:"created_at"`
+	ExpiresAt    time.Time  `json:"expires_at" db:"expires_at"`
+	CompletedAt  *time.Time `json:"completed_at" db:"completed_at"`
+	URL          string     `json:"url,omitempty"`
+}
+
+// Targets a resumable upload can be made for.
+const (
+	UploadTargetAttachment = "attachment"
+	UploadTargetUserImport = "user_import"
+)
+
+// ResumableUpload is an upload sent in chunks with the tus protocol. Once
+// Offset reaches Length it is handed to its target, and Result is the path
+// of what that created.
+type ResumableUpload struct {
+	ID           string     `json:"id" db:"id"`
+	Target       string     `json:"target" db:"target"`
+	ResourceType string     `json:"resource_type,omitempty" db:"resource_type"`
+	ResourceID   int        `json:"resource_id,omitempty" db:"resource_id"`
+	Filename     string     `json:"filename" db:"filename"`
+	Metadata     string     `json:"-" db:"metadata"`
+	Length       int64      `json:"length" db:"upload_length"`
+	Offset       int64      `json:"offset" db:"upload_offset"`
+	ChunkKeys    []string   `json:"-" db:"chunk_keys"`
+	CreatedBy    int        `json:"created_by" db:"created_by"`
+	CreatedAt    time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at" db:"updated_at"`
+	ExpiresAt    time.Time  `json:"expires_at" db:"expires_at"`
+	CompletedAt  *time.Time `json:"completed_at" db:"completed_at"`
+	Result       string     `json:"result,omitempty" db:"result"`
+}
+
+// Blob is the stored content of one or more attachments, keyed by its
+// SHA-256.
+type Blob struct {
+	SHA256     string `json:"sha256" db:"sha256"`
+	StorageKey string `json:"-" db:"storage_key"`
+	Size       int64  `json:"size" db:"size"`
+	RefCount   int    `json:"ref_count" db:"ref_count"`
+	Stored     bool   `json:"stored" db:"stored"`
+}
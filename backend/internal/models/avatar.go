@@ -0,0 +1,49 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// AvatarVariant is one stored rendition of an avatar, as kept in the users
+// avatar column.
+type AvatarVariant struct {
+	Name  string `json:"name"`
+	Key   string `json:"key"`
+	Width int    `json:"width"`
+}
+
+type AvatarSize struct {
+	Name  string `json:"name"`
+	Width int    `json:"width"`
+	URL   string `json:"url"`
+}
+
+// Avatar lists the available renditions, smallest first, along with a
+// ready-made srcset attribute value.
+type Avatar struct {
+	URL    string       `json:"url"`
+	Sizes  []AvatarSize `json:"sizes"`
+	SrcSet string       `json:"srcset"`
+}
+
+// NewAvatar resolves stored variants to URLs. The "original" variant becomes
+// the default URL.
+func NewAvatar(variants []AvatarVariant, urlFor func(key string) string) *Avatar {
+	if len(variants) == 0 {
+		return nil
+	}
+
+	avatar := &Avatar{}
+	srcset := make([]string, 0, len(variants))
+	for _, v := range variants {
+		url := urlFor(v.Key)
+		avatar.Sizes = append(avatar.Sizes, AvatarSize{Name: v.Name, Width: v.Width, URL: url})
+		srcset = append(srcset, fmt.Sprintf("%s %dw", url, v.Width))
+		if v.Name == "original" || avatar.URL == "" {
+			avatar.URL = url
+		}
+	}
+	avatar.SrcSet = strings.Join(srcset, ", ")
+	return avatar
+}
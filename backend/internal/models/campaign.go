@@ -0,0 +1,74 @@
+package models
+
+import (
+	"time"
+)
+
+// Campaign statuses. A campaign is pending until its recipients are
+// matched, running while their emails go out, and then completed, or
+// cancelled or failed.
+const (
+	CampaignPending   = "pending"
+	CampaignRunning   = "running"
+	CampaignCompleted = "completed"
+	CampaignCancelled = "cancelled"
+	CampaignFailed    = "failed"
+)
+
+// Recipient statuses.
+const (
+	RecipientPending   = "pending"
+	RecipientQueued    = "queued"
+	RecipientSent      = "sent"
+	RecipientFailed    = "failed"
+	RecipientSkipped   = "skipped"
+	RecipientCancelled = "cancelled"
+)
+
+// Campaign is an email sent to the users in a segment, at most
+// RatePerMinute a minute.
+type Campaign struct {
+	ID            int              `json:"id" db:"id"`
+	Name          string           `json:"name" db:"name"`
+	Segment       string           `json:"segment" db:"segment"`
+	Template      string           `json:"template" db:"template"`
+	Data          CampaignData     `json:"data" db:"data"`
+	RatePerMinute int              `json:"rate_per_minute" db:"rate_per_minute"`
+	Status        string           `json:"status" db:"status"`
+	Error         string           `json:"error,omitempty" db:"error"`
+	Progress      CampaignProgress `json:"progress" db:"-"`
+	CreatedAt     time.Time        `json:"created_at" db:"created_at"`
+	StartedAt     *time.Time       `json:"started_at" db:"started_at"`
+	FinishedAt    *time.Time       `json:"finished_at" db:"finished_at"`
+}
+
+// CampaignData fills in the campaign's email template for every
+// recipient, along with their name.
+type CampaignData struct {
+	Subject string `json:"subject,omitempty"`
+	Message string `json:"message,omitempty"`
+	Link    string `json:"link,omitempty"`
+}
+
+// CampaignProgress counts a campaign's recipients by status.
+type CampaignProgress struct {
+	Total     int `json:"total"`
+	Pending   int `json:"pending"`
+	Queued    int `json:"queued"`
+	Sent      int `json:"sent"`
+	Failed    int `json:"failed"`
+	Skipped   int `json:"skipped"`
+	Cancelled int `json:"cancelled"`
+}
+
+// Segment uses the segment filter language. RatePerMinute defaults to
+// CAMPAIGN_RATE_PER_MINUTE.
+type CreateCampaignRequest struct {
+	Name          string `json:"name" binding:"required,max=200" sanitize:"trim,control"`
+	Segment       string `json:"segment" binding:"required"`
+	Template      string `json:"template" binding:"required"`
+	Subject       string `json:"subject" binding:"max=200" sanitize:"trim,control"`
+	Message       string `json:"message" binding:"max=10000" sanitize:"trim,control,multiline"`
+	Link          string `json:"link" binding:"omitempty,url,max=2000" sanitize:"trim,control"`
+	RatePerMinute int    `json:"rate_per_minute" binding:"omitempty,min=1,max=10000"`
+}
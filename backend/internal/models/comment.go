@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+)
+
+// Comment is a note on a resource, or a reply to another comment when
+// ParentID is set. Deleted comments keep their place in the thread with an
+// empty body.
+type Comment struct {
+	ID           int        `json:"id" db:"id"`
+	ResourceType string     `json:"resource_type" db:"resource_type"`
+	ResourceID   int        `json:"resource_id" db:"resource_id"`
+	ParentID     *int       `json:"parent_id" db:"parent_id"`
+	AuthorID     *int       `json:"author_id" db:"author_id"`
+	Body         string     `json:"body" db:"body"`
+	Mentions     []int      `json:"mentions" db:"mentions"`
+	ReplyCount   int        `json:"reply_count" db:"reply_count"`
+	CreatedAt    time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at" db:"updated_at"`
+	DeletedAt    *time.Time `json:"deleted_at" db:"deleted_at"`
+}
+
+// The body mentions users as @user:<id>.
+type CreateCommentRequest struct {
+	Body     string `json:"body" binding:"required,max=10000" sanitize:"trim,control,multiline,html"`
+	ParentID *int   `json:"parent_id" binding:"omitempty,min=1"`
+}
+
+type UpdateCommentRequest struct {
+	Body string `json:"body" binding:"required,max=10000" sanitize:"trim,control,multiline,html"`
+}
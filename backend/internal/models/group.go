@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+)
+
+type Group struct {
+	ID          int       `json:"id" db:"id"`
+	Name        string    `json:"name" db:"name"`
+	Description string    `json:"description" db:"description"`
+	MemberCount int       `json:"member_count" db:"member_count"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+}
+
+type CreateGroupRequest struct {
+	Name        string `json:"name" binding:"required,min=2,max=100" sanitize:"trim,control,html"`
+	Description string `json:"description" binding:"max=1000" sanitize:"trim,control,multiline,html"`
+}
+
+// Empty fields are left unchanged.
+type UpdateGroupRequest struct {
+	Name        string `json:"name" binding:"omitempty,min=2,max=100" sanitize:"trim,control,html"`
+	Description string `json:"description" binding:"max=1000" sanitize:"trim,control,multiline,html"`
+}
+
+type AddGroupMembersRequest struct {
+	UserIDs []int `json:"user_ids" binding:"required,min=1,max=1000"`
+}
+
+// GroupMember is a user in a group along with when they were added.
+type GroupMember struct {
+	User    User      `json:"user"`
+	AddedAt time.Time `json:"added_at"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// UserVersion is one version of a user in its change history. Version 1 is
+// the user as created, or as it was when history began to be kept. Changes
+// holds the fields the version changed, by name; State, the user's tracked
+// fields as of the version, is only set when a single version is fetched.
+// ChangedBy is the user who made the change, when known, and RestoredFrom
+// the version it restored, if it was a restore.
+type UserVersion struct {
+	Version      int                    `json:"version"`
+	Changes      map[string]FieldChange `json:"changes"`
+	State        json.RawMessage        `json:"state,omitempty"`
+	ChangedBy    *int                   `json:"changed_by"`
+	ChangedAt    time.Time              `json:"changed_at"`
+	RestoredFrom *int                   `json:"restored_from,omitempty"`
+}
+
+// FieldChange is a field's value before and after a change, null when it
+// had none.
+type FieldChange struct {
+	From json.RawMessage `json:"from"`
+	To   json.RawMessage `json:"to"`
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// LegalHold keeps a user from being deleted, purged, or anonymized until
+// it is cleared.
+type LegalHold struct {
+	UserID    int       `json:"user_id"`
+	Reason    string    `json:"reason"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// LegalHoldRequest places a user under legal hold.
+type LegalHoldRequest struct {
+	Reason string `json:"reason" binding:"required"`
+}
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"time"
+)
+
+// Org roles, from most to least privileged. Owners and admins manage
+// invitations; users without a recorded role are members.
+const (
+	OrgRoleOwner  = "owner"
+	OrgRoleAdmin  = "admin"
+	OrgRoleMember = "member"
+)
+
+// OrgInvitation is an emailed invitation to join an org with a role. It is
+// open until accepted, revoked, or replaced; an open invitation past
+// ExpiresAt can be resent.
+type OrgInvitation struct {
+	ID        string    `json:"id" db:"id"`
+	OrgID     int       `json:"org_id" db:"org_id"`
+	Email     string    `json:"email" db:"email"`
+	Role      string    `json:"role" db:"role"`
+	InvitedBy *int      `json:"invited_by" db:"invited_by"`
+	ExpiresAt time.Time `json:"expires_at" db:"expires_at"`
+	SentAt    time.Time `json:"sent_at" db:"sent_at"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// Role defaults to member. Owners are made by transferring ownership, not
+// by invitation.
+type CreateOrgInvitationRequest struct {
+	Email string `json:"email" binding:"required,email" sanitize:"trim,control"`
+	Role  string `json:"role" binding:"omitempty,oneof=admin member"`
+}
+
+// Name is required when no account has the invited email yet.
+type AcceptOrgInvitationRequest struct {
+	Token string `json:"token" binding:"required"`
+	Name  string `json:"name" binding:"omitempty,min=2,max=100" sanitize:"trim,control,html"`
+}
+
+// OrgMember is a user as seen by the other members of their org.
+type OrgMember struct {
+	ID    int    `json:"id"`
+	Email string `json:"email"`
+	Name  string `json:"name"`
+	Role  string `json:"role"`
+}
+
+// Owners can only be made by transferring ownership, except through the
+// admin API.
+type SetOrgRoleRequest struct {
+	Role string `json:"role" binding:"required,oneof=admin member"`
+}
+
+// Confirm must repeat the org's name, so ownership is not handed over by a
+// stray request.
+type TransferOrgRequest struct {
+	UserID  int    `json:"user_id" binding:"required,min=1"`
+	Confirm string `json:"confirm" binding:"required"`
+}
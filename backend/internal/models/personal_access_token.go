@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+)
+
+// PersonalAccessToken describes a token a user minted for scripts and CLI
+// clients. The secret is only returned once, in CreatedPersonalAccessToken,
+// and is stored hashed.
+type PersonalAccessToken struct {
+	ID         int        `json:"id" db:"id"`
+	UserID     int        `json:"user_id" db:"user_id"`
+	Name       string     `json:"name" db:"name"`
+	Prefix     string     `json:"prefix" db:"prefix"`
+	Scopes     []string   `json:"scopes" db:"scopes"`
+	ExpiresAt  time.Time  `json:"expires_at" db:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at" db:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at" db:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+}
+
+// Tokens without expires_at expire after the longest lifetime allowed.
+type CreatePersonalAccessTokenRequest struct {
+	Name      string     `json:"name" binding:"required,max=100" sanitize:"trim,control"`
+	Scopes    []string   `json:"scopes" binding:"required,min=1,dive,min=1,max=100" sanitize:"trim,control"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// CreatedPersonalAccessToken is the response for a new token, the only time
+// its secret is shown.
+type CreatedPersonalAccessToken struct {
+	PersonalAccessToken
+	Token string `json:"token"`
+}
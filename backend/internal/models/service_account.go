@@ -0,0 +1,54 @@
+package models
+
+import (
+	"time"
+)
+
+// ServiceAccount is a non-human principal, such as a CI pipeline or an
+// integration, that calls the API with API keys instead of a user session.
+type ServiceAccount struct {
+	ID          int        `json:"id" db:"id"`
+	Name        string     `json:"name" db:"name"`
+	Description string     `json:"description" db:"description"`
+	Scopes      []string   `json:"scopes" db:"scopes"`
+	DisabledAt  *time.Time `json:"disabled_at" db:"disabled_at"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+type CreateServiceAccountRequest struct {
+	Name        string   `json:"name" binding:"required,min=2,max=100" sanitize:"trim,control"`
+	Description string   `json:"description" binding:"max=1000" sanitize:"trim,control,multiline"`
+	Scopes      []string `json:"scopes" binding:"required,min=1,dive,min=1,max=100" sanitize:"trim,control"`
+}
+
+// Omitted fields are left unchanged.
+type UpdateServiceAccountRequest struct {
+	Description *string  `json:"description" binding:"omitempty,max=1000" sanitize:"trim,control,multiline"`
+	Scopes      []string `json:"scopes" binding:"omitempty,min=1,dive,min=1,max=100" sanitize:"trim,control"`
+}
+
+// APIKey describes a service account key. The secret is only returned once,
+// in CreatedAPIKey, and is stored hashed.
+type APIKey struct {
+	ID               int        `json:"id" db:"id"`
+	ServiceAccountID int        `json:"service_account_id" db:"service_account_id"`
+	Name             string     `json:"name" db:"name"`
+	Prefix           string     `json:"prefix" db:"prefix"`
+	ExpiresAt        *time.Time `json:"expires_at" db:"expires_at"`
+	LastUsedAt       *time.Time `json:"last_used_at" db:"last_used_at"`
+	RevokedAt        *time.Time `json:"revoked_at" db:"revoked_at"`
+	CreatedAt        time.Time  `json:"created_at" db:"created_at"`
+}
+
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name" binding:"max=100" sanitize:"trim,control"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// CreatedAPIKey is the response for a new key, the only time its secret is
+// shown.
+type CreatedAPIKey struct {
+	APIKey
+	Key string `json:"key"`
+}
@@ -0,0 +1,9 @@
+package models
+
+import "encoding/json"
+
+type CreateTaskRequest struct {
+	Kind           string          `json:"kind" binding:"required"`
+	Input          json.RawMessage `json:"input" binding:"required"`
+	TimeoutSeconds int             `json:"timeout_seconds" binding:"omitempty,min=1,max=3600"`
+}
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"time"
+)
+
+// Team is a named set of an org's members.
+type Team struct {
+	ID          int       `json:"id" db:"id"`
+	OrgID       int       `json:"org_id" db:"org_id"`
+	Name        string    `json:"name" db:"name"`
+	Description string    `json:"description" db:"description"`
+	MemberCount int       `json:"member_count" db:"member_count"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+}
+
+type CreateTeamRequest struct {
+	Name        string `json:"name" binding:"required,min=2,max=100" sanitize:"trim,control,html"`
+	Description string `json:"description" binding:"max=1000" sanitize:"trim,control,multiline,html"`
+}
+
+// Empty fields are left unchanged.
+type UpdateTeamRequest struct {
+	Name        string `json:"name" binding:"omitempty,min=2,max=100" sanitize:"trim,control,html"`
+	Description string `json:"description" binding:"max=1000" sanitize:"trim,control,multiline,html"`
+}
+
+// Only members of the team's org can be added.
+type AddTeamMembersRequest struct {
+	UserIDs []int `json:"user_ids" binding:"required,min=1,max=1000"`
+}
+
+// ProjectResource is the resource type of projects in ACL entries.
+const ProjectResource = "project"
+
+// Project is an org's resource. With a TeamID it is scoped to that team:
+// only the team and the org's owners and admins see it.
+type Project struct {
+	ID          int       `json:"id" db:"id"`
+	OrgID       int       `json:"org_id" db:"org_id"`
+	TeamID      *int      `json:"team_id" db:"team_id"`
+	Name        string    `json:"name" db:"name"`
+	Description string    `json:"description" db:"description"`
+	CreatedBy   *int      `json:"created_by" db:"created_by"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// Without a team_id the project is visible to the whole org.
+type CreateProjectRequest struct {
+	Name        string `json:"name" binding:"required,min=2,max=100" sanitize:"trim,control,html"`
+	Description string `json:"description" binding:"max=1000" sanitize:"trim,control,multiline,html"`
+	TeamID      *int   `json:"team_id" binding:"omitempty,min=1"`
+}
+
+// Empty fields are left unchanged. A team_id of 0 opens the project to the
+// whole org.
+type UpdateProjectRequest struct {
+	Name        string `json:"name" binding:"omitempty,min=2,max=100" sanitize:"trim,control,html"`
+	Description string `json:"description" binding:"max=1000" sanitize:"trim,control,multiline,html"`
+	TeamID      *int   `json:"team_id" binding:"omitempty,min=0"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// Kinds of resources in the trash.
+const (
+	TrashUser    = "user"
+	TrashProject = "project"
+)
+
+// TrashItem is a deleted resource that can still be restored: a user
+// pending deletion or a deleted project. It is purged at PurgeAt. OrgID is
+// set for projects, and DeletedBy when the deleting user is known.
+type TrashItem struct {
+	Type      string    `json:"type"`
+	ID        int       `json:"id"`
+	OrgID     *int      `json:"org_id"`
+	Name      string    `json:"name"`
+	DeletedAt time.Time `json:"deleted_at"`
+	DeletedBy *int      `json:"deleted_by"`
+	PurgeAt   time.Time `json:"purge_at"`
+}
+
+// TrashRequest names the users and projects to restore or purge.
+type TrashRequest struct {
+	Users    []int `json:"users"`
+	Projects []int `json:"projects"`
+}
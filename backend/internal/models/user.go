@@ -5,19 +5,94 @@ import (
 )
 
 type User struct {
-	ID        int       `json:"id" db:"id"`
-	Email     string    `json:"email" db:"email"`
-	Name      string    `json:"name" db:"name"`
-	CreatedAt time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+	ID         int                    `json:"id" db:"id"`
+	Email      string                 `json:"email" db:"email"`
+	Name       string                 `json:"name" db:"name"`
+	Avatar     *Avatar                `json:"avatar,omitempty" db:"avatar"`
+	Attributes map[string]interface{} `json:"attributes" db:"attributes"`
+	Deletion   *Deletion              `json:"deletion,omitempty" db:"deletion"`
+	CreatedAt  time.Time              `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time              `json:"updated_at" db:"updated_at"`
+}
+
+// Deletion is set on a user whose deletion was requested. The user cannot
+// sign in and is purged at PurgeAt unless the deletion is cancelled first.
+type Deletion struct {
+	RequestedAt time.Time `json:"requested_at"`
+	PurgeAt     time.Time `json:"purge_at"`
+}
+
+// UserSuggestion is a typeahead match, with only the fields a picker shows.
+type UserSuggestion struct {
+	ID    int    `json:"id"`
+	Email string `json:"email"`
+	Name  string `json:"name"`
 }
 
+// ProvisionedUser is a user along with the fields managed by SCIM
+// provisioning. Inactive users are kept but flagged as deprovisioned.
+type ProvisionedUser struct {
+	User
+	ExternalID *string `json:"external_id" db:"external_id"`
+	Active     bool    `json:"active" db:"active"`
+}
+
+// Attributes are custom fields validated against the admin-defined
+// attribute definitions.
 type CreateUserRequest struct {
-	Email string `json:"email" binding:"required,email"`
-	Name  string `json:"name" binding:"required,min=2,max=100"`
+	Email      string                 `json:"email" binding:"required,email" sanitize:"trim,control"`
+	Name       string                 `json:"name" binding:"required,min=2,max=100" sanitize:"trim,control,html"`
+	Attributes map[string]interface{} `json:"attributes"`
 }
 
+// Attributes are merged into the stored ones; null removes an attribute.
 type UpdateUserRequest struct {
-	Email string `json:"email" binding:"omitempty,email"`
-	Name  string `json:"name" binding:"omitempty,min=2,max=100"`
+	Email      string                 `json:"email" binding:"omitempty,email" sanitize:"trim,control"`
+	Name       string                 `json:"name" binding:"omitempty,min=2,max=100" sanitize:"trim,control,html"`
+	Attributes map[string]interface{} `json:"attributes"`
+}
+
+// ExportUsersRequest configures a user export. PasswordTo receives the
+// password of an encrypted export and defaults to the signed-in user.
+type ExportUsersRequest struct {
+	Encrypt    bool   `json:"encrypt"`
+	PasswordTo string `json:"password_to" binding:"omitempty,email" sanitize:"trim,control"`
+}
+
+// UserFilter selects users for bulk operations. Empty fields are ignored.
+// Attributes match users whose attributes contain all the given values.
+type UserFilter struct {
+	EmailDomain  string                 `json:"email_domain"`
+	NameContains string                 `json:"name_contains"`
+	Attributes   map[string]interface{} `json:"attributes"`
+	GroupID      int                    `json:"group_id"`
+	TeamID       int                    `json:"team_id"`
+}
+
+type BulkUpdateUsersRequest struct {
+	IDs    []int             `json:"ids"`
+	Filter *UserFilter       `json:"filter"`
+	Patch  UpdateUserRequest `json:"patch"`
+}
+
+// BulkItemResult reports the outcome of a bulk operation for one record.
+type BulkItemResult struct {
+	ID     int    `json:"id"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// UserFlag marks a user for review, e.g. "disposable_email" for a signup
+// from a disposable email domain.
+type UserFlag struct {
+	UserID    int       `json:"user_id" db:"user_id"`
+	Flag      string    `json:"flag" db:"flag"`
+	Reason    string    `json:"reason" db:"reason"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// Tombstone marks a deleted user in a sync response.
+type Tombstone struct {
+	ID        int       `json:"id"`
+	DeletedAt time.Time `json:"deleted_at"`
 }
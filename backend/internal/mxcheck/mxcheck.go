@@ -0,0 +1,103 @@
+// Package mxcheck checks that an email address's domain has MX records, so
+// signups with mistyped or made-up domains can be caught before any email
+// bounces. Results are cached per domain.
+package mxcheck
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNoMX reports a domain that has no MX records, or a null MX
+// (RFC 7505) saying it accepts no email.
+var ErrNoMX = errors.New("email domain does not accept email")
+
+// Cache lifetimes. Missing records are cached for less time so a domain
+// that is being set up is accepted soon after.
+const (
+	foundTTL    = time.Hour
+	notFoundTTL = 5 * time.Minute
+)
+
+// maxCached bounds the cache; it is emptied when full.
+const maxCached = 10000
+
+type entry struct {
+	err     error
+	expires time.Time
+}
+
+var (
+	mu       sync.Mutex
+	cache    = map[string]entry{}
+	resolver = net.DefaultResolver
+)
+
+// Timeout is the budget for one lookup, read from EMAIL_MX_TIMEOUT and
+// defaulting to two seconds.
+func Timeout() time.Duration {
+	if d, err := time.ParseDuration(os.Getenv("EMAIL_MX_TIMEOUT")); err == nil && d > 0 {
+		return d
+	}
+	return 2 * time.Second
+}
+
+// Check returns nil if the domain of email has MX records and ErrNoMX if it
+// has none. Other errors mean the lookup failed or ran out of time, and are
+// not cached.
+func Check(ctx context.Context, email string) error {
+	domain := Domain(email)
+
+	mu.Lock()
+	e, ok := cache[domain]
+	mu.Unlock()
+	if ok && time.Now().Before(e.expires) {
+		return e.err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, Timeout())
+	defer cancel()
+	err := lookup(ctx, domain)
+
+	var dnsErr *net.DNSError
+	switch {
+	case err == nil:
+		e = entry{expires: time.Now().Add(foundTTL)}
+	case errors.Is(err, ErrNoMX), errors.As(err, &dnsErr) && dnsErr.IsNotFound:
+		err = ErrNoMX
+		e = entry{err: err, expires: time.Now().Add(notFoundTTL)}
+	default:
+		return err
+	}
+
+	mu.Lock()
+	if len(cache) >= maxCached {
+		cache = map[string]entry{}
+	}
+	cache[domain] = e
+	mu.Unlock()
+	return err
+}
+
+// Domain returns the lowercased domain of an email address.
+func Domain(email string) string {
+	return strings.ToLower(strings.TrimSuffix(email[strings.LastIndex(email, "@")+1:], "."))
+}
+
+func lookup(ctx context.Context, domain string) error {
+	records, err := resolver.LookupMX(ctx, domain)
+	if err != nil {
+		return err
+	}
+	for _, mx := range records {
+		if mx.Host != "." && mx.Host != "" {
+			return nil
+		}
+	}
+	return ErrNoMX
+}
@@ -0,0 +1,268 @@
+package openapi
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// overlay holds what the route table cannot say about the API: the info
+// block, the responses of documented operations keyed by operationId, and
+// the components they refer to.
+//
+//go:embed overlay.json
+var overlay []byte
+
+// Route is what the generator needs to know about one route.
+type Route struct {
+	Name   string
+	Method string
+	// Path is the Gin path, such as "/api/v1/users/:id".
+	Path string
+	// Security names the security scheme of the route's credentials, or is
+	// empty for routes anonymous callers may use.
+	Security string
+	// Scopes are the scopes the route requires of those credentials.
+	Scopes []string
+	// Deprecation is set when the route is deprecated.
+	Deprecation *Deprecation
+	// DeprecatedFields are the route's deprecated query parameters and
+	// top-level JSON body fields, by name.
+	DeprecatedFields map[string]Deprecation
+}
+
+// Deprecation is when a route or field was deprecated, when it will be
+// removed, and what replaces it: an operationId for routes, a field name
+// for fields. Each part is optional.
+type Deprecation struct {
+	Since       time.Time
+	Sunset      time.Time
+	Replacement string
+}
+
+// bodyMethods are the methods whose deprecated fields may be sent in a
+// JSON body as well as in the query string.
+var bodyMethods = map[string]bool{http.MethodPost: true, http.MethodPut: true, http.MethodPatch: true}
+
+// securityScheme is the part of a components.securitySchemes entry the
+// generator checks routes against.
+type securityScheme struct {
+	Type  string `json:"type"`
+	Flows map[string]struct {
+		Scopes map[string]string `json:"scopes"`
+	} `json:"flows"`
+}
+
+// undocumented is the response of operations the overlay does not describe.
+var undocumented = map[string]interface{}{
+	"default": map[string]interface{}{"description": "Not described here; see the README."},
+}
+
+// Generate builds the OpenAPI document for routes: one operation per route,
+// named by the route, with its path parameters, security requirement and
+// deprecations, merged with the overlay. It fails when the overlay describes an operation
+// no route has, or a route needs a security scheme or OAuth2 scope the
+// overlay does not define.
+func Generate(routes []Route) ([]byte, error) {
+	return generate(routes, overlay)
+}
+
+func generate(routes []Route, overlay []byte) ([]byte, error) {
+	var ov struct {
+		Info       json.RawMessage                   `json:"info"`
+		Operations map[string]map[string]interface{} `json:"operations"`
+		Components json.RawMessage                   `json:"components"`
+	}
+	if err := json.Unmarshal(overlay, &ov); err != nil {
+		return nil, fmt.Errorf("invalid OpenAPI overlay: %v", err)
+	}
+	var components struct {
+		SecuritySchemes map[string]securityScheme `json:"securitySchemes"`
+	}
+	if err := json.Unmarshal(ov.Components, &components); err != nil {
+		return nil, fmt.Errorf("invalid OpenAPI overlay: %v", err)
+	}
+
+	paths := map[string]map[string]interface{}{}
+	seen := map[string]bool{}
+	for _, r := range routes {
+		path := Path(r.Path)
+		op := map[string]interface{}{
+			"operationId": r.Name,
+			"responses":   undocumented,
+		}
+		if params := pathParameters(path); len(params) > 0 {
+			op["parameters"] = params
+		}
+		if r.Security != "" {
+			if err := checkScopes(components.SecuritySchemes, r); err != nil {
+				return nil, err
+			}
+			scopes := r.Scopes
+			if scopes == nil {
+				scopes = []string{}
+			}
+			op["security"] = []interface{}{map[string]interface{}{r.Security: scopes}}
+		}
+		for key, value := range ov.Operations[r.Name] {
+			op[key] = value
+		}
+		if r.Deprecation != nil {
+			op["deprecated"] = true
+			deprecate(op, *r.Deprecation)
+		}
+		if len(r.DeprecatedFields) > 0 {
+			deprecateFields(op, r.Method, r.DeprecatedFields)
+		}
+		seen[r.Name] = true
+
+		if paths[path] == nil {
+			paths[path] = map[string]interface{}{}
+		}
+		paths[path][strings.ToLower(r.Method)] = op
+	}
+
+	var unknown []string
+	for name := range ov.Operations {
+		if !seen[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("the OpenAPI overlay describes operations with no route: %s", strings.Join(unknown, ", "))
+	}
+
+	doc, err := json.MarshalIndent(map[string]interface{}{
+		"openapi":    "3.1.0",
+		"info":       ov.Info,
+		"paths":      paths,
+		"components": ov.Components,
+	}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(doc, '\n'), nil
+}
+
+// deprecateFields documents a route's deprecated fields as deprecated query
+// parameters and, for methods with a body, as deprecated properties of a
+// JSON request body, unless the overlay describes the body itself.
+func deprecateFields(op map[string]interface{}, method string, fields map[string]Deprecation) {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	params, _ := op["parameters"].([]interface{})
+	properties := map[string]interface{}{}
+	for _, name := range names {
+		param := map[string]interface{}{
+			"name":       name,
+			"in":         "query",
+			"deprecated": true,
+			"schema":     map[string]interface{}{},
+		}
+		deprecate(param, fields[name])
+		params = append(params, param)
+
+		property := map[string]interface{}{"deprecated": true}
+		deprecate(property, fields[name])
+		properties[name] = property
+	}
+	op["parameters"] = params
+
+	if _, ok := op["requestBody"]; !ok && bodyMethods[method] {
+		op["requestBody"] = map[string]interface{}{
+			"content": map[string]interface{}{
+				"application/json": map[string]interface{}{
+					"schema": map[string]interface{}{"type": "object", "properties": properties},
+				},
+			},
+		}
+	}
+}
+
+// deprecate adds the extension fields describing d to an operation,
+// parameter or schema: x-deprecated-since and x-sunset as dates, and
+// x-replacement.
+func deprecate(object map[string]interface{}, d Deprecation) {
+	if !d.Since.IsZero() {
+		object["x-deprecated-since"] = d.Since.UTC().Format(time.DateOnly)
+	}
+	if !d.Sunset.IsZero() {
+		object["x-sunset"] = d.Sunset.UTC().Format(time.DateOnly)
+	}
+	if d.Replacement != "" {
+		object["x-replacement"] = d.Replacement
+	}
+}
+
+// checkScopes reports an error unless the route's security scheme is defined
+// and, for OAuth2, one of its flows grants every scope the route requires.
+// Other schemes take the scopes as role names, which OpenAPI 3.1 allows.
+func checkScopes(schemes map[string]securityScheme, r Route) error {
+	scheme, ok := schemes[r.Security]
+	if !ok {
+		return fmt.Errorf("route %s: no security scheme %q in the OpenAPI overlay", r.Name, r.Security)
+	}
+	if scheme.Type != "oauth2" {
+		return nil
+	}
+	for _, scope := range r.Scopes {
+		granted := false
+		for _, flow := range scheme.Flows {
+			if _, ok := flow.Scopes[scope]; ok {
+				granted = true
+			}
+		}
+		if !granted {
+			return fmt.Errorf("route %s: security scheme %q has no scope %q", r.Name, r.Security, scope)
+		}
+	}
+	return nil
+}
+
+// Path turns a Gin path into an OpenAPI path template: ":id" and "*key"
+// become "{id}" and "{key}".
+func Path(ginPath string) string {
+	var sb strings.Builder
+	for i := 0; i < len(ginPath); i++ {
+		ch := ginPath[i]
+		if ch != ':' && ch != '*' {
+			sb.WriteByte(ch)
+			continue
+		}
+		end := strings.IndexByte(ginPath[i:], '/')
+		if end < 0 {
+			end = len(ginPath) - i
+		}
+		sb.WriteString("{" + ginPath[i+1:i+end] + "}")
+		i += end - 1
+	}
+	return sb.String()
+}
+
+// pathParameters lists the parameters of an OpenAPI path template.
+func pathParameters(path string) []interface{} {
+	var params []interface{}
+	for rest := path; ; {
+		start := strings.IndexByte(rest, '{')
+		if start < 0 {
+			return params
+		}
+		end := strings.IndexByte(rest[start:], '}')
+		params = append(params, map[string]interface{}{
+			"name":     rest[start+1 : start+end],
+			"in":       "path",
+			"required": true,
+			"schema":   map[string]interface{}{"type": "string"},
+		})
+		rest = rest[start+end+1:]
+	}
+}
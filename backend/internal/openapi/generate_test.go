@@ -0,0 +1,110 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testOverlay = `{
+  "info": {"title": "Test", "version": "1"},
+  "operations": {},
+  "components": {
+    "securitySchemes": {
+      "accessToken": {"type": "oauth2", "flows": {"password": {"tokenUrl": "/token", "scopes": {"users:read": ""}}}},
+      "adminToken": {"type": "http", "scheme": "bearer"}
+    }
+  }
+}`
+
+func TestGenerate(t *testing.T) {
+	since := time.Date(2026, 1, 2, 15, 0, 0, 0, time.UTC)
+	sunset := time.Date(2026, 7, 1, 0, 0, 0, 0, time.UTC)
+	doc, err := generate([]Route{
+		{Name: "users.get", Method: http.MethodGet, Path: "/users/:id", Security: "accessToken", Scopes: []string{"users:read"}},
+		{
+			Name: "users.legacy", Method: http.MethodGet, Path: "/legacy/users/*rest",
+			Deprecation: &Deprecation{Since: since, Sunset: sunset, Replacement: "users.get"},
+		},
+		{
+			Name: "users.create", Method: http.MethodPost, Path: "/users", Security: "adminToken", Scopes: []string{"admin"},
+			DeprecatedFields: map[string]Deprecation{"nick": {Sunset: sunset, Replacement: "name"}},
+		},
+	}, []byte(testOverlay))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var spec struct {
+		Paths map[string]map[string]map[string]interface{} `json:"paths"`
+	}
+	if err := json.Unmarshal(doc, &spec); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		path, method, key string
+		want              string
+	}{
+		{"/users/{id}", "get", "parameters", `[{"in":"path","name":"id","required":true,"schema":{"type":"string"}}]`},
+		{"/users/{id}", "get", "security", `[{"accessToken":["users:read"]}]`},
+		{"/users/{id}", "get", "deprecated", `null`},
+		{"/legacy/users/{rest}", "get", "security", `null`},
+		{"/legacy/users/{rest}", "get", "deprecated", `true`},
+		{"/legacy/users/{rest}", "get", "x-deprecated-since", `"2026-01-02"`},
+		{"/legacy/users/{rest}", "get", "x-sunset", `"2026-07-01"`},
+		{"/legacy/users/{rest}", "get", "x-replacement", `"users.get"`},
+		{"/users", "post", "security", `[{"adminToken":["admin"]}]`},
+		{"/users", "post", "deprecated", `null`},
+		{"/users", "post", "parameters", `[{"deprecated":true,"in":"query","name":"nick","schema":{},"x-replacement":"name","x-sunset":"2026-07-01"}]`},
+		{"/users", "post", "requestBody", `{"content":{"application/json":{"schema":{"properties":{"nick":{"deprecated":true,"x-replacement":"name","x-sunset":"2026-07-01"}},"type":"object"}}}}`},
+	} {
+		got, _ := json.Marshal(spec.Paths[tc.path][tc.method][tc.key])
+		if string(got) != tc.want {
+			t.Errorf("%s %s %s = %s, want %s", strings.ToUpper(tc.method), tc.path, tc.key, got, tc.want)
+		}
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		route   Route
+		overlay string
+	}{
+		{"unknown scheme", Route{Name: "r", Method: http.MethodGet, Path: "/", Security: "apiKey"}, testOverlay},
+		{"unknown scope", Route{Name: "r", Method: http.MethodGet, Path: "/", Security: "accessToken", Scopes: []string{"users:write"}}, testOverlay},
+		{"overlay without a route", Route{Name: "r", Method: http.MethodGet, Path: "/"}, strings.Replace(testOverlay, `"operations": {}`, `"operations": {"gone": {}}`, 1)},
+	} {
+		if doc, err := generate([]Route{tc.route}, []byte(tc.overlay)); err == nil {
+			t.Errorf("%s: generated %d bytes, want an error", tc.name, len(doc))
+		}
+	}
+}
+
+func TestPath(t *testing.T) {
+	got := []string{Path("/media/*key"), Path("/api/v1/users:method"), Path("/orgs/:id/members/:user")}
+	want := []string{"/media/{key}", "/api/v1/users{method}", "/orgs/{id}/members/{user}"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Path = %q, want %q", got, want)
+	}
+}
+
+func TestMatchPath(t *testing.T) {
+	for _, tc := range []struct {
+		template, path string
+		want           bool
+	}{
+		{"/users/{id}", "/users/7", true},
+		{"/users/{id}", "/users/7/roles", false},
+		{"/users/{id}", "/users/", false},
+		{"/api/v1/users{method}", "/api/v1/users:batchGet", true},
+		{"/a.b/{x}", "/aXb/1", false},
+	} {
+		if got := matchPath(tc.template, tc.path); got != tc.want {
+			t.Errorf("matchPath(%q, %q) = %v, want %v", tc.template, tc.path, got, tc.want)
+		}
+	}
+}
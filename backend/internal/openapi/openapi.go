@@ -0,0 +1,156 @@
+// Package openapi generates the OpenAPI document of the API from the route
+// table and checks responses against it.
+package openapi
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"pygorp/backend/internal/jsonschema"
+)
+
+// Document is the checked-in output of Generate for the route table. Run
+// `pygorp routes --openapi > internal/openapi/openapi.json` after changing
+// routes or the overlay; the routes tests fail while it is out of date.
+//
+//go:embed openapi.json
+var Document []byte
+
+// Spec is the part of an OpenAPI 3.1 document the checks use.
+type Spec struct {
+	Paths      map[string]map[string]*Operation `json:"paths"`
+	Components struct {
+		Schemas map[string]*jsonschema.Schema `json:"schemas"`
+	} `json:"components"`
+}
+
+// Operation is one method on a path. OperationID is the route name.
+type Operation struct {
+	OperationID string                `json:"operationId"`
+	Security    []map[string][]string `json:"security"`
+	Responses   map[string]*Response  `json:"responses"`
+}
+
+// Response is a documented status code of an operation, keyed by media type.
+type Response struct {
+	Content map[string]struct {
+		Schema *jsonschema.Schema `json:"schema"`
+	} `json:"content"`
+}
+
+const schemaRef = "#/components/schemas/"
+
+// Load parses the embedded document and resolves its schema references.
+func Load() (*Spec, error) {
+	var spec Spec
+	if err := json.Unmarshal(Document, &spec); err != nil {
+		return nil, fmt.Errorf("invalid OpenAPI document: %v", err)
+	}
+
+	lookup := func(ref string) (*jsonschema.Schema, bool) {
+		s, ok := spec.Components.Schemas[strings.TrimPrefix(ref, schemaRef)]
+		return s, ok && strings.HasPrefix(ref, schemaRef)
+	}
+	for name, s := range spec.Components.Schemas {
+		if err := s.Resolve(lookup); err != nil {
+			return nil, fmt.Errorf("schema %s: %v", name, err)
+		}
+	}
+	for path, methods := range spec.Paths {
+		for method, op := range methods {
+			for status, resp := range op.Responses {
+				for mediaType, content := range resp.Content {
+					if err := content.Schema.Resolve(lookup); err != nil {
+						return nil, fmt.Errorf("%s %s %s %s: %v", method, path, status, mediaType, err)
+					}
+				}
+			}
+		}
+	}
+	return &spec, nil
+}
+
+// Find returns the operation documented for a request, with the path
+// template it matched, such as "/api/v1/users/{id}". Like the router, it
+// prefers the template with the fewest parameters.
+func (s *Spec) Find(method, path string) (string, *Operation, bool) {
+	var found string
+	for template, methods := range s.Paths {
+		if _, ok := methods[strings.ToLower(method)]; !ok || !matchPath(template, path) {
+			continue
+		}
+		if found == "" || strings.Count(template, "{") < strings.Count(found, "{") {
+			found = template
+		}
+	}
+	if found == "" {
+		return "", nil, false
+	}
+	return found, s.Paths[found][strings.ToLower(method)], true
+}
+
+// Documented reports whether the overlay describes the operation's
+// responses, rather than leaving them to the generated default.
+func (op *Operation) Documented() bool {
+	_, ok := op.Responses["default"]
+	return !ok || len(op.Responses) > 1
+}
+
+// Check validates a response against the operation: the status code must
+// be documented, with the response's media type, and the body must match
+// that media type's schema. Operations the overlay does not describe accept
+// any response.
+func (op *Operation) Check(status int, contentType string, body []byte) error {
+	if !op.Documented() {
+		return nil
+	}
+	resp, ok := op.Responses[strconv.Itoa(status)]
+	if !ok {
+		return fmt.Errorf("status %d is not documented", status)
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return fmt.Errorf("invalid Content-Type %q", contentType)
+	}
+	content, ok := resp.Content[mediaType]
+	if !ok {
+		return fmt.Errorf("status %d does not document %s", status, mediaType)
+	}
+	if content.Schema == nil {
+		return nil
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return fmt.Errorf("body is not JSON: %v", err)
+	}
+	return content.Schema.Validate("body", v)
+}
+
+// matchPath reports whether a request path fits a template, whose
+// "{name}" parameters match any text within one path segment.
+func matchPath(template, path string) bool {
+	var pattern strings.Builder
+	pattern.WriteString("^")
+	for rest := template; ; {
+		start := strings.IndexByte(rest, '{')
+		if start < 0 {
+			pattern.WriteString(regexp.QuoteMeta(rest))
+			break
+		}
+		end := strings.IndexByte(rest[start:], '}')
+		if end < 0 {
+			return false
+		}
+		pattern.WriteString(regexp.QuoteMeta(rest[:start]) + "[^/]+")
+		rest = rest[start+end+1:]
+	}
+	pattern.WriteString("$")
+	matched, _ := regexp.MatchString(pattern.String(), path)
+	return matched
+}
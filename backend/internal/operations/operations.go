@@ -0,0 +1,242 @@
+package operations
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"time"
+
+	"pygorp/backend/internal/clock"
+	"pygorp/backend/internal/database"
+	"pygorp/backend/internal/idgen"
+	"pygorp/backend/internal/jobs"
+)
+
+// Operation statuses
+const (
+	StatusPending   = "pending"
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+// ErrNotFound is returned when no operation has the given ID.
+var ErrNotFound = errors.New("operation not found")
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+func init() {
+	database.UseColumns("operations", "id, type, status, progress, job_id, result, result_file, result_content_type, error, created_by, created_at, updated_at, completed_at")
+}
+
+// Operation tracks a long-running request processed by the job queue.
+type Operation struct {
+	ID          string          `json:"id"`
+	Type        string          `json:"type"`
+	Status      string          `json:"status"`
+	Progress    int             `json:"progress"`
+	Result      json.RawMessage `json:"result,omitempty"`
+	ResultURL   string          `json:"result_url,omitempty"`
+	Error       *string         `json:"error,omitempty"`
+	CreatedBy   string          `json:"-"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+	CompletedAt *time.Time      `json:"completed_at,omitempty"`
+}
+
+// Result is what an operation handler produces. Data is returned inline in
+// the operation resource; File is served from the result URL.
+type Result struct {
+	Data        interface{}
+	File        []byte
+	ContentType string
+}
+
+// Task is handed to an operation handler.
+type Task struct {
+	OperationID string
+	Params      json.RawMessage
+}
+
+// HandlerFunc performs the work of an operation.
+type HandlerFunc func(ctx context.Context, task *Task) (*Result, error)
+
+type payload struct {
+	OperationID string          `json:"operation_id"`
+	Params      json.RawMessage `json:"params"`
+}
+
+// Register adds a job handler that keeps the operation resource in sync
+// with the job: running on start, succeeded with its result, or failed once
+// the job runs out of attempts.
+func Register(opType string, handler HandlerFunc) {
+	jobs.Register(jobType(opType), func(ctx context.Context, job *jobs.Job) error {
+		var p payload
+		if err := json.Unmarshal(job.Payload, &p); err != nil {
+			return fmt.Errorf("invalid operation payload: %v", err)
+		}
+
+		if _, err := database.DB.ExecContext(ctx,
+			"UPDATE operations SET status = 'running' WHERE id = $1", p.OperationID); err != nil {
+			return err
+		}
+
+		result, err := handler(ctx, &Task{OperationID: p.OperationID, Params: p.Params})
+		if err != nil {
+			if job.Attempts >= job.MaxAttempts {
+				fail(ctx, p.OperationID, err)
+			}
+			return err
+		}
+		return complete(ctx, p.OperationID, result)
+	})
+}
+
+// Start creates an operation and enqueues its job in one transaction.
+// createdBy names the principal that started it, such as "user:12"; only
+// that principal can read the operation and its result through the API.
+func Start(ctx context.Context, opType, createdBy string, params interface{}) (*Operation, error) {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode operation params: %v", err)
+	}
+
+	tx, err := database.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	id, now := idgen.NewID(), clock.Now()
+	if _, err := tx.ExecContext(ctx,
+		"INSERT INTO operations (id, type, created_by, created_at, updated_at) VALUES ($1, $2, NULLIF($3, ''), $4, $4)",
+		id, opType, createdBy, now); err != nil {
+		return nil, err
+	}
+
+	job, err := jobs.EnqueueWith(ctx, tx, jobs.DefaultQueue, jobType(opType), payload{OperationID: id, Params: data})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE operations SET job_id = $1 WHERE id = $2", job.ID, id); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return Get(ctx, id)
+}
+
+// Get returns the operation with the given ID.
+func Get(ctx context.Context, id string) (*Operation, error) {
+	if !uuidPattern.MatchString(id) {
+		return nil, ErrNotFound
+	}
+
+	var op Operation
+	var hasFile bool
+	err := database.DB.QueryRowContext(ctx, `
+		SELECT id, type, status, progress, result, result_file IS NOT NULL, error, COALESCE(created_by, ''),
+			created_at, updated_at, completed_at
+		FROM operations WHERE id = $1`, id).
+		Scan(&op.ID, &op.Type, &op.Status, &op.Progress, &op.Result, &hasFile, &op.Error, &op.CreatedBy,
+			&op.CreatedAt, &op.UpdatedAt, &op.CompletedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if hasFile {
+		op.ResultURL = "/api/v1/operations/" + op.ID + "/result"
+	}
+	return &op, nil
+}
+
+// ResultFile returns the file produced by a succeeded operation.
+func ResultFile(ctx context.Context, id string) ([]byte, string, error) {
+	if !uuidPattern.MatchString(id) {
+		return nil, "", ErrNotFound
+	}
+
+	var file []byte
+	var contentType sql.NullString
+	err := database.DB.QueryRowContext(ctx,
+		"SELECT result_file, result_content_type FROM operations WHERE id = $1 AND result_file IS NOT NULL", id).
+		Scan(&file, &contentType)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, "", ErrNotFound
+	}
+	return file, contentType.String, err
+}
+
+// SetProgress records completion percentage, clamped to 0-100.
+func (t *Task) SetProgress(ctx context.Context, percent int) {
+	if percent < 0 {
+		percent = 0
+	}
+	if percent > 100 {
+		percent = 100
+	}
+	if _, err := database.DB.ExecContext(ctx,
+		"UPDATE operations SET progress = $1 WHERE id = $2", percent, t.OperationID); err != nil {
+		log.Printf("Failed to update progress of operation %s: %v", t.OperationID, err)
+	}
+}
+
+func complete(ctx context.Context, id string, result *Result) error {
+	var data []byte
+	var file []byte
+	var contentType *string
+	if result != nil {
+		if result.Data != nil {
+			var err error
+			if data, err = json.Marshal(result.Data); err != nil {
+				return fmt.Errorf("failed to encode operation result: %v", err)
+			}
+		}
+		if result.File != nil {
+			file = result.File
+			contentType = &result.ContentType
+		}
+	}
+
+	_, err := database.DB.ExecContext(ctx, `
+		UPDATE operations
+		SET status = 'succeeded', progress = 100, result = $2, result_file = $3, result_content_type = $4,
+			error = NULL, completed_at = $5
+		WHERE id = $1`, id, data, file, contentType, clock.Now())
+	return err
+}
+
+func fail(ctx context.Context, id string, cause error) {
+	if _, err := database.DB.ExecContext(ctx,
+		"UPDATE operations SET status = 'failed', error = $2, completed_at = $3 WHERE id = $1",
+		id, cause.Error(), clock.Now()); err != nil {
+		log.Printf("Failed to mark operation %s failed: %v", id, err)
+	}
+}
+
+func jobType(opType string) string {
+	return "operation:" + opType
+}
+
+// JobCancelled marks the operation backed by a cancelled job as failed.
+func JobCancelled(ctx context.Context, jobID int64) error {
+	_, err := database.DB.ExecContext(ctx,
+		"UPDATE operations SET status = 'failed', error = 'cancelled', completed_at = $2 WHERE job_id = $1", jobID, clock.Now())
+	return err
+}
+
+// JobRetried resets the operation backed by a requeued job to pending.
+func JobRetried(ctx context.Context, jobID int64) error {
+	_, err := database.DB.ExecContext(ctx,
+		"UPDATE operations SET status = 'pending', progress = 0, error = NULL, completed_at = NULL WHERE job_id = $1", jobID)
+	return err
+}
@@ -0,0 +1,318 @@
+package operations
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"pygorp/backend/internal/config"
+	"pygorp/backend/internal/database"
+	"pygorp/backend/internal/disposable"
+	"pygorp/backend/internal/events"
+	"pygorp/backend/internal/mailer"
+	"pygorp/backend/internal/models"
+	"pygorp/backend/internal/repository"
+	"pygorp/backend/internal/sanitize"
+	"pygorp/backend/internal/securezip"
+	"pygorp/backend/internal/templates"
+)
+
+// Operation types
+const (
+	TypeUserExport     = "users.export"
+	TypeUserImport     = "users.import"
+	TypeUserBulkDelete = "users.bulk_delete"
+	TypeUserBulkUpdate = "users.bulk_update"
+)
+
+// chunkSize is the number of records processed between progress updates.
+const chunkSize = 100
+
+// ExportParams configures an export. When Encrypt is set the CSV is put in a
+// password-protected ZIP, and the generated password is emailed to
+// PasswordTo rather than stored with the result.
+type ExportParams struct {
+	Encrypt    bool   `json:"encrypt"`
+	PasswordTo string `json:"password_to,omitempty"`
+	// Name greets the recipient in the password email.
+	Name string `json:"name,omitempty"`
+}
+
+// exportPasswordLength is long enough to resist offline guessing against
+// the archive, given the key derivation's low iteration count.
+const exportPasswordLength = 24
+
+// ImportParams carries an uploaded CSV with an email,name header.
+type ImportParams struct {
+	CSV string `json:"csv"`
+}
+
+type BulkParams struct {
+	IDs   []int                    `json:"ids"`
+	Patch models.UpdateUserRequest `json:"patch"`
+}
+
+func init() {
+	Register(TypeUserExport, exportUsers)
+	Register(TypeUserImport, importUsers)
+	Register(TypeUserBulkDelete, bulkDeleteUsers)
+	Register(TypeUserBulkUpdate, bulkUpdateUsers)
+}
+
+func exportUsers(ctx context.Context, task *Task) (*Result, error) {
+	var params ExportParams
+	if len(task.Params) > 0 {
+		if err := json.Unmarshal(task.Params, &params); err != nil {
+			return nil, err
+		}
+	}
+
+	var total int
+	if err := database.DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM users").Scan(&total); err != nil {
+		return nil, err
+	}
+
+	rows, err := database.DB.QueryContext(ctx, "SELECT id, email, name, created_at, updated_at FROM users ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"id", "email", "name", "created_at", "updated_at"})
+
+	written := 0
+	for rows.Next() {
+		var user models.User
+		if err := rows.Scan(&user.ID, &user.Email, &user.Name, &user.CreatedAt, &user.UpdatedAt); err != nil {
+			return nil, err
+		}
+		w.Write([]string{
+			strconv.Itoa(user.ID), user.Email, user.Name,
+			user.CreatedAt.Format(time.RFC3339), user.UpdatedAt.Format(time.RFC3339),
+		})
+
+		written++
+		if written%chunkSize == 0 && total > 0 {
+			task.SetProgress(ctx, written*100/total)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, err
+	}
+
+	if params.Encrypt {
+		return encryptExport(ctx, task, &params, buf.Bytes(), written)
+	}
+	return &Result{
+		Data:        map[string]interface{}{"rows": written},
+		File:        buf.Bytes(),
+		ContentType: "text/csv",
+	}, nil
+}
+
+// encryptExport zips the CSV with a new password and emails the password.
+// A retry generates a new file and password, so a failed delivery never
+// leaves a file nobody can open.
+func encryptExport(ctx context.Context, task *Task, params *ExportParams, data []byte, rows int) (*Result, error) {
+	password, err := securezip.NewPassword(exportPasswordLength)
+	if err != nil {
+		return nil, err
+	}
+	file, err := securezip.Encrypt([]securezip.File{{Name: "users.csv", Data: data}}, password)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt export: %v", err)
+	}
+
+	m, err := mailer.Default()
+	if err != nil {
+		return nil, err
+	}
+	if err := m.SendTemplate(ctx, params.PasswordTo, templates.ExportPassword, templates.Data{
+		Name:     params.Name,
+		Link:     "/api/v1/operations/" + task.OperationID + "/result",
+		Password: password,
+	}); err != nil {
+		return nil, fmt.Errorf("failed to send export password: %v", err)
+	}
+
+	return &Result{
+		Data:        map[string]interface{}{"rows": rows, "encrypted": true, "password_sent_to": params.PasswordTo},
+		File:        file,
+		ContentType: "application/zip",
+	}, nil
+}
+
+type importError struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+func importUsers(ctx context.Context, task *Task) (*Result, error) {
+	var params ImportParams
+	if err := json.Unmarshal(task.Params, &params); err != nil {
+		return nil, err
+	}
+
+	r := csv.NewReader(strings.NewReader(params.CSV))
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV: %v", err)
+	}
+	if len(records) == 0 {
+		return &Result{Data: map[string]interface{}{"created": 0, "skipped": 0, "errors": []importError{}}}, nil
+	}
+
+	emailCol, nameCol := -1, -1
+	for i, h := range records[0] {
+		switch strings.ToLower(strings.TrimSpace(h)) {
+		case "email":
+			emailCol = i
+		case "name":
+			nameCol = i
+		}
+	}
+	if emailCol < 0 || nameCol < 0 {
+		return nil, fmt.Errorf("CSV header must include email and name columns")
+	}
+
+	blocked, err := repository.Blocklist(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load blocked words: %v", err)
+	}
+
+	created, skipped := 0, 0
+	errs := []importError{}
+	rows := records[1:]
+	for i, rec := range rows {
+		line := i + 2
+		email, name, err := cleanImportRow(rec[emailCol], rec[nameCol])
+		if err != nil {
+			errs = append(errs, importError{Line: line, Error: err.Error()})
+			continue
+		}
+		if email == "" || !strings.Contains(email, "@") || len(name) < 2 || len(name) > 100 {
+			errs = append(errs, importError{Line: line, Error: "invalid email or name"})
+			continue
+		}
+		if err := blocked.Check("name", name); err != nil {
+			errs = append(errs, importError{Line: line, Error: err.Error()})
+			continue
+		}
+		flag, err := checkDisposable(ctx, email)
+		if err != nil {
+			errs = append(errs, importError{Line: line, Error: err.Error()})
+			continue
+		}
+
+		var id int
+		err = database.DB.QueryRowContext(ctx,
+			"INSERT INTO users (email, name) VALUES ($1, $2) ON CONFLICT (email) DO NOTHING RETURNING id", email, name).Scan(&id)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			skipped++
+		case err != nil:
+			errs = append(errs, importError{Line: line, Error: "failed to insert user"})
+			continue
+		default:
+			created++
+			events.Publish(ctx, events.UserCreated, 1, events.UserCreatedV1{ID: id, Email: email, Name: name})
+			if flag {
+				if err := repository.FlagUser(ctx, id, repository.FlagDisposableEmail, "imported with "+email); err != nil {
+					log.Printf("Failed to flag user %d: %v", id, err)
+				}
+			}
+		}
+
+		if (i+1)%chunkSize == 0 {
+			task.SetProgress(ctx, (i+1)*100/len(rows))
+		}
+	}
+
+	return &Result{Data: map[string]interface{}{"created": created, "skipped": skipped, "errors": errs}}, nil
+}
+
+// checkDisposable applies the disposable_emails setting to an imported
+// user as signups do: in reject mode the row fails, and in flag mode it
+// reports whether the new user should be flagged. A failed lookup is logged
+// and lets the row through.
+func checkDisposable(ctx context.Context, email string) (flag bool, err error) {
+	mode := config.Current().DisposableEmails
+	if mode == "off" {
+		return false, nil
+	}
+	found, err := disposable.Check(ctx, email)
+	if err != nil {
+		log.Printf("Failed to check disposable email domains: %v", err)
+		return false, nil
+	}
+	if found && mode == "reject" {
+		return false, errors.New("email addresses from disposable domains are not accepted")
+	}
+	return found, nil
+}
+
+// cleanImportRow sanitizes an imported email and name the way creating a
+// user through the API does: the request model's sanitize rules, then
+// normalization and rejection of invisible or bidirectional characters.
+func cleanImportRow(email, name string) (string, string, error) {
+	req := models.CreateUserRequest{Email: email, Name: name}
+	sanitize.Struct(&req)
+
+	var err error
+	if req.Email, err = sanitize.Email("email", req.Email); err != nil {
+		return "", "", err
+	}
+	if req.Name, err = sanitize.Name("name", req.Name); err != nil {
+		return "", "", err
+	}
+	return req.Email, req.Name, nil
+}
+
+func bulkDeleteUsers(ctx context.Context, task *Task) (*Result, error) {
+	return runBulk(ctx, task, func(ids []int, _ BulkParams) []models.BulkItemResult {
+		return repository.BulkDeleteUsers(ctx, ids)
+	})
+}
+
+func bulkUpdateUsers(ctx context.Context, task *Task) (*Result, error) {
+	return runBulk(ctx, task, func(ids []int, params BulkParams) []models.BulkItemResult {
+		return repository.BulkUpdateUsers(ctx, ids, params.Patch)
+	})
+}
+
+func runBulk(ctx context.Context, task *Task, op func([]int, BulkParams) []models.BulkItemResult) (*Result, error) {
+	var params BulkParams
+	if err := json.Unmarshal(task.Params, &params); err != nil {
+		return nil, err
+	}
+
+	results := make([]models.BulkItemResult, 0, len(params.IDs))
+	summary := map[string]int{}
+	for start := 0; start < len(params.IDs); start += chunkSize {
+		end := start + chunkSize
+		if end > len(params.IDs) {
+			end = len(params.IDs)
+		}
+		for _, r := range op(params.IDs[start:end], params) {
+			results = append(results, r)
+			summary[r.Status]++
+		}
+		task.SetProgress(ctx, end*100/len(params.IDs))
+	}
+
+	return &Result{Data: map[string]interface{}{"results": results, "summary": summary}}, nil
+}
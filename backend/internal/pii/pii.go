@@ -0,0 +1,139 @@
+// Package pii keeps personal data out of API responses for callers that
+// are not allowed to see it. Fields named in the policy, wherever they
+// appear in a JSON response, are masked (j***@example.com) or omitted
+// unless the caller's token grants Scope, so handlers need not check it.
+package pii
+
+import (
+	"bytes"
+	"encoding/json"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"pygorp/backend/internal/auth"
+	"pygorp/backend/internal/render"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Scope lets a caller see personal data in responses.
+const Scope = "users:read_pii"
+
+// Action is what callers without Scope get instead of a field.
+type Action int
+
+const (
+	// Mask hides all of an email field but its first character and domain.
+	Mask Action = iota
+	// Omit removes the field.
+	Omit
+)
+
+// policy names the fields holding personal data. Fields are matched by
+// name at any depth, so embedded users and event payloads are covered too.
+var policy = map[string]Action{
+	"email":       Mask,
+	"password_to": Mask,
+}
+
+// Allowed reports whether the caller may see personal data. Like
+// RequireScopes, anonymous requests are allowed when AUTH_SECRET is unset,
+// since no tokens can be issued then.
+func Allowed(c *gin.Context) bool {
+	granted, ok := c.Get("auth_scopes")
+	if !ok {
+		return !auth.Enabled()
+	}
+	scopes, _ := granted.([]string)
+	return slices.Contains(scopes, Scope)
+}
+
+// MaskEmail hides all but the first character of an email's local part,
+// keeping the domain. Values that are not emails are hidden entirely.
+func MaskEmail(email string) string {
+	local, domain, ok := strings.Cut(email, "@")
+	if !ok || local == "" {
+		return "***"
+	}
+	r, _ := utf8.DecodeRuneInString(local)
+	return string(r) + "***@" + domain
+}
+
+// Middleware redacts JSON responses to callers that are not Allowed. It
+// relies on Authenticate having run.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if Allowed(c) {
+			c.Next()
+			return
+		}
+
+		// JSON responses are held back so they can be redacted once the
+		// handler is done. Files and other responses are written through.
+		w := render.NewBuffer(c.Writer, render.HoldJSON)
+		c.Writer = w
+		c.Next()
+		c.Writer = w.ResponseWriter
+
+		if !w.Buffered() {
+			return
+		}
+		body := w.Bytes()
+		if redacted, ok := redactBody(body); ok {
+			body = redacted
+		}
+		w.Release(body)
+	}
+}
+
+func redactBody(body []byte) ([]byte, bool) {
+	var v interface{}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return nil, false
+	}
+	if !redact(v) {
+		return body, true
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, false
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), true
+}
+
+// redact applies the policy to v in place, reporting whether anything
+// changed.
+func redact(v interface{}) bool {
+	changed := false
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			action, ok := policy[key]
+			if !ok {
+				changed = redact(value) || changed
+				continue
+			}
+			switch action {
+			case Omit:
+				delete(v, key)
+				changed = true
+			case Mask:
+				if s, ok := value.(string); ok && s != "" {
+					v[key] = MaskEmail(s)
+					changed = true
+				}
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			changed = redact(item) || changed
+		}
+	}
+	return changed
+}
@@ -0,0 +1,150 @@
+package policy
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"pygorp/backend/internal/database"
+
+	"github.com/lib/pq"
+)
+
+// Permissions an ACL entry grants, from weakest to strongest. Each implies
+// the ones before it.
+const (
+	PermissionRead  = "read"
+	PermissionWrite = "write"
+)
+
+// ErrEntryNotFound is returned when an ACL entry does not exist.
+var ErrEntryNotFound = errors.New("acl entry not found")
+
+const aclColumns = "id, resource_type, resource_id, principal, permission, granted_by, created_at, updated_at"
+
+func init() {
+	database.UseColumns("acl_entries", aclColumns)
+}
+
+// Resource is something ACL entries share, such as project 12.
+type Resource struct {
+	Type string
+	ID   int
+}
+
+// ACLEntry grants a principal, a subject such as "user:5" or "team:3", a
+// permission on a resource.
+type ACLEntry struct {
+	ID           int       `json:"id"`
+	ResourceType string    `json:"resource_type"`
+	ResourceID   int       `json:"resource_id"`
+	Principal    string    `json:"principal"`
+	Permission   string    `json:"permission"`
+	GrantedBy    *int      `json:"granted_by"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// Covers reports whether holding permission also grants want.
+func Covers(permission, want string) bool {
+	return rank(permission) >= rank(want) && rank(want) > 0
+}
+
+func rank(permission string) int {
+	switch permission {
+	case PermissionRead:
+		return 1
+	case PermissionWrite:
+		return 2
+	}
+	return 0
+}
+
+func scanEntry(row interface{ Scan(...interface{}) error }) (ACLEntry, error) {
+	var e ACLEntry
+	var grantedBy sql.NullInt64
+	err := row.Scan(&e.ID, &e.ResourceType, &e.ResourceID, &e.Principal, &e.Permission, &grantedBy, &e.CreatedAt, &e.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return e, ErrEntryNotFound
+	}
+	if grantedBy.Valid {
+		id := int(grantedBy.Int64)
+		e.GrantedBy = &id
+	}
+	return e, err
+}
+
+// ListACL returns a resource's entries ordered by principal.
+func ListACL(ctx context.Context, r Resource) ([]ACLEntry, error) {
+	rows, err := database.DB.QueryContext(ctx,
+		"SELECT "+aclColumns+" FROM acl_entries WHERE resource_type = $1 AND resource_id = $2 ORDER BY principal",
+		r.Type, r.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []ACLEntry{}
+	for rows.Next() {
+		e, err := scanEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// Grant shares a resource with a principal, replacing the permission of an
+// existing entry for the same principal.
+func Grant(ctx context.Context, r Resource, principal, permission string, grantedBy int) (ACLEntry, error) {
+	return scanEntry(database.DB.QueryRowContext(ctx, `
+		INSERT INTO acl_entries (resource_type, resource_id, principal, permission, granted_by)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (resource_type, resource_id, principal)
+		DO UPDATE SET permission = EXCLUDED.permission, granted_by = EXCLUDED.granted_by, updated_at = NOW()
+		RETURNING `+aclColumns,
+		r.Type, r.ID, principal, permission, grantedBy))
+}
+
+// Revoke deletes one of a resource's entries and returns it.
+func Revoke(ctx context.Context, r Resource, id int) (ACLEntry, error) {
+	return scanEntry(database.DB.QueryRowContext(ctx,
+		"DELETE FROM acl_entries WHERE id = $1 AND resource_type = $2 AND resource_id = $3 RETURNING "+aclColumns,
+		id, r.Type, r.ID))
+}
+
+// Granted returns the strongest permission the entries of a resource give
+// any of the subjects, or "" when they give none.
+func Granted(ctx context.Context, subjects []string, r Resource) (string, error) {
+	// "write" sorts after "read", so MAX picks the stronger.
+	var permission sql.NullString
+	err := database.DB.QueryRowContext(ctx, `
+		SELECT MAX(permission) FROM acl_entries
+		WHERE resource_type = $1 AND resource_id = $2 AND principal = ANY($3) AND permission IN ($4, $5)`,
+		r.Type, r.ID, pq.Array(subjects), PermissionRead, PermissionWrite).Scan(&permission)
+	return permission.String, err
+}
+
+// Shared returns the IDs of the resources of a type that the subjects have
+// been granted any permission on.
+func Shared(ctx context.Context, subjects []string, resourceType string) ([]int, error) {
+	rows, err := database.DB.QueryContext(ctx,
+		"SELECT DISTINCT resource_id FROM acl_entries WHERE resource_type = $1 AND principal = ANY($2) ORDER BY resource_id",
+		resourceType, pq.Array(subjects))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
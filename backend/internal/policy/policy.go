@@ -0,0 +1,169 @@
+package policy
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Effects.
+const (
+	EffectAllow = "allow"
+	EffectDeny  = "deny"
+)
+
+// Modes, set by the policy_mode runtime setting.
+const (
+	ModeOff     = "off"
+	ModeAudit   = "audit"
+	ModeEnforce = "enforce"
+)
+
+// UserPlaceholder in a resource pattern is replaced with the requesting
+// user's ID, so one policy can grant access to each user's own records.
+const UserPlaceholder = "{user}"
+
+// Policy grants or denies subjects some actions on some resources. Each list
+// holds patterns where "*" matches any run of characters, including none.
+//
+// Subjects are "user:<id>", "group:<id>", "team:<id>", "service:<name>",
+// "service_account:<name>", or "anonymous".
+// Actions are route names such as "users.update". Resources are request
+// paths such as "/api/v1/users/42".
+type Policy struct {
+	ID          int       `json:"id"`
+	Effect      string    `json:"effect" binding:"required"`
+	Subjects    []string  `json:"subjects" binding:"required,min=1"`
+	Actions     []string  `json:"actions" binding:"required,min=1"`
+	Resources   []string  `json:"resources" binding:"required,min=1"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// Check rejects policies that could never match.
+func (p *Policy) Check() error {
+	if p.Effect != EffectAllow && p.Effect != EffectDeny {
+		return fmt.Errorf("effect must be allow or deny")
+	}
+	for field, patterns := range map[string][]string{"subjects": p.Subjects, "actions": p.Actions, "resources": p.Resources} {
+		if len(patterns) == 0 {
+			return fmt.Errorf("%s must not be empty", field)
+		}
+		for _, pattern := range patterns {
+			if strings.TrimSpace(pattern) == "" {
+				return fmt.Errorf("%s must not contain empty patterns", field)
+			}
+		}
+	}
+	return nil
+}
+
+// Request is one access check.
+type Request struct {
+	// Subjects are every identity the caller acts as: the user, their
+	// groups, and the calling service.
+	Subjects []string `json:"subjects" binding:"required,min=1"`
+	// UserID replaces UserPlaceholder in resource patterns. Empty when the
+	// caller is not a user.
+	UserID   string `json:"user_id"`
+	Action   string `json:"action" binding:"required"`
+	Resource string `json:"resource" binding:"required"`
+}
+
+// Decision is the outcome of evaluating a request.
+type Decision struct {
+	Allowed bool   `json:"allowed"`
+	Reason  string `json:"reason"`
+	// Matched lists the IDs of the policies that matched the request.
+	Matched []int `json:"matched"`
+}
+
+// Evaluate decides a request. A matching deny wins over any allow, and a
+// request no policy allows is denied.
+func Evaluate(policies []*Policy, req Request) Decision {
+	d := Decision{Matched: []int{}}
+	allowed, denied := false, false
+	for _, p := range policies {
+		if !p.matches(req) {
+			continue
+		}
+		d.Matched = append(d.Matched, p.ID)
+		if p.Effect == EffectDeny {
+			denied = true
+		} else {
+			allowed = true
+		}
+	}
+	sort.Ints(d.Matched)
+
+	switch {
+	case denied:
+		d.Reason = "denied by policy"
+	case allowed:
+		d.Allowed = true
+		d.Reason = "allowed by policy"
+	default:
+		d.Reason = "no policy allows this request"
+	}
+	return d
+}
+
+func (p *Policy) matches(req Request) bool {
+	if !matchAny(p.Actions, req.Action) {
+		return false
+	}
+	subject := false
+	for _, s := range req.Subjects {
+		if matchAny(p.Subjects, s) {
+			subject = true
+			break
+		}
+	}
+	if !subject {
+		return false
+	}
+	for _, pattern := range p.Resources {
+		if strings.Contains(pattern, UserPlaceholder) {
+			if req.UserID == "" {
+				continue
+			}
+			pattern = strings.ReplaceAll(pattern, UserPlaceholder, req.UserID)
+		}
+		if match(pattern, req.Resource) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchAny(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		if match(pattern, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// match reports whether value matches a pattern in which "*" stands for any
+// run of characters.
+func match(pattern, value string) bool {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == value
+	}
+	if !strings.HasPrefix(value, parts[0]) {
+		return false
+	}
+	value = value[len(parts[0]):]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(value, part)
+		if i < 0 {
+			return false
+		}
+		value = value[i+len(part):]
+	}
+	return strings.HasSuffix(value, parts[len(parts)-1])
+}
@@ -0,0 +1,158 @@
+package policy
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"pygorp/backend/internal/database"
+
+	"github.com/lib/pq"
+)
+
+// ErrNotFound is returned when a policy does not exist.
+var ErrNotFound = errors.New("policy not found")
+
+// cacheTTL bounds how long other instances keep serving policies after one
+// is changed. Changes made through this instance apply immediately.
+const cacheTTL = 30 * time.Second
+
+const columns = "id, effect, subjects, actions, resources, description, created_at, updated_at"
+
+func init() {
+	database.UseColumns("policies", columns)
+}
+
+var cache struct {
+	sync.Mutex
+	policies []*Policy
+	loaded   time.Time
+}
+
+func scan(row interface{ Scan(...interface{}) error }) (*Policy, error) {
+	var p Policy
+	err := row.Scan(&p.ID, &p.Effect, pq.Array(&p.Subjects), pq.Array(&p.Actions), pq.Array(&p.Resources),
+		&p.Description, &p.CreatedAt, &p.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return &p, err
+}
+
+// List returns every policy ordered by ID.
+func List(ctx context.Context) ([]*Policy, error) {
+	rows, err := database.DB.QueryContext(ctx, "SELECT "+columns+" FROM policies ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	policies := []*Policy{}
+	for rows.Next() {
+		p, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, p)
+	}
+	return policies, rows.Err()
+}
+
+// Current returns the policies used for request checks, reloading them
+// from the database when the cache has expired.
+func Current(ctx context.Context) ([]*Policy, error) {
+	cache.Lock()
+	defer cache.Unlock()
+
+	if cache.policies != nil && time.Since(cache.loaded) < cacheTTL {
+		return cache.policies, nil
+	}
+	policies, err := List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cache.policies, cache.loaded = policies, time.Now()
+	return policies, nil
+}
+
+// Invalidate drops the cached policies, so the next check reloads them. Call
+// it after changing the policies table other than through this package.
+func Invalidate() {
+	cache.Lock()
+	cache.policies = nil
+	cache.Unlock()
+}
+
+func Get(ctx context.Context, id int) (*Policy, error) {
+	return scan(database.DB.QueryRowContext(ctx, "SELECT "+columns+" FROM policies WHERE id = $1", id))
+}
+
+func Create(ctx context.Context, p *Policy) (*Policy, error) {
+	defer Invalidate()
+	return scan(database.DB.QueryRowContext(ctx,
+		"INSERT INTO policies (effect, subjects, actions, resources, description) VALUES ($1, $2, $3, $4, $5) RETURNING "+columns,
+		p.Effect, pq.Array(p.Subjects), pq.Array(p.Actions), pq.Array(p.Resources), p.Description))
+}
+
+func Update(ctx context.Context, id int, p *Policy) (*Policy, error) {
+	defer Invalidate()
+	return scan(database.DB.QueryRowContext(ctx,
+		"UPDATE policies SET effect = $1, subjects = $2, actions = $3, resources = $4, description = $5, updated_at = NOW() WHERE id = $6 RETURNING "+columns,
+		p.Effect, pq.Array(p.Subjects), pq.Array(p.Actions), pq.Array(p.Resources), p.Description, id))
+}
+
+func Delete(ctx context.Context, id int) error {
+	defer Invalidate()
+	result, err := database.DB.ExecContext(ctx, "DELETE FROM policies WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Subjects returns the subjects a caller acts as. userID, service, and
+// serviceAccount may be empty; a caller with none is "anonymous". Numeric
+// user IDs also yield the user's groups and teams.
+func Subjects(ctx context.Context, userID, service, serviceAccount string) ([]string, error) {
+	var subjects []string
+	if userID != "" {
+		subjects = append(subjects, "user:"+userID)
+	}
+	if _, err := strconv.Atoi(userID); err == nil {
+		rows, err := database.DB.QueryContext(ctx, `
+			SELECT 'group', group_id FROM group_members WHERE user_id = $1
+			UNION ALL SELECT 'team', team_id FROM team_members WHERE user_id = $1
+			ORDER BY 1, 2`, userID)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var kind string
+			var id int
+			if err := rows.Scan(&kind, &id); err != nil {
+				return nil, err
+			}
+			subjects = append(subjects, kind+":"+strconv.Itoa(id))
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	if service != "" {
+		subjects = append(subjects, "service:"+service)
+	}
+	if serviceAccount != "" {
+		subjects = append(subjects, "service_account:"+serviceAccount)
+	}
+	if len(subjects) == 0 {
+		subjects = append(subjects, "anonymous")
+	}
+	return subjects, nil
+}
@@ -0,0 +1,223 @@
+// Package previews renders resized previews of image and PDF attachments
+// on demand. Requests queue a job and the worker stores the result, so
+// each size of each file is rendered once.
+package previews
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"pygorp/backend/internal/auth"
+	"pygorp/backend/internal/clock"
+	"pygorp/backend/internal/database"
+	"pygorp/backend/internal/jobs"
+)
+
+// Preview statuses.
+const (
+	StatusPending = "pending"
+	StatusReady   = "ready"
+	StatusFailed  = "failed"
+)
+
+// Job types.
+const (
+	RenderJob = "previews.render"
+	GCJob     = "previews.gc"
+)
+
+// Bounds on requested sizes. Sizes are rounded up to a multiple of step so
+// clients asking for slightly different sizes share renders.
+const (
+	DefaultSize = 256
+	MaxSize     = 1024
+	step        = 32
+)
+
+var (
+	// ErrNotFound is returned when a preview does not exist.
+	ErrNotFound = errors.New("preview not found")
+	// ErrUnsupported is returned for content types that have no preview.
+	ErrUnsupported = errors.New("previews are only available for JPEG, PNG, and GIF images and PDFs")
+)
+
+const previewColumns = "id, source_key, width, height, status, storage_key, content_type, error, created_at, updated_at"
+
+func init() {
+	database.UseColumns("attachment_previews", previewColumns)
+	jobs.Register(RenderJob, func(ctx context.Context, job *jobs.Job) error {
+		var p renderPayload
+		if err := json.Unmarshal(job.Payload, &p); err != nil {
+			return fmt.Errorf("invalid preview payload: %v", err)
+		}
+		return render(ctx, job, p)
+	})
+	jobs.Register(GCJob, collect)
+	jobs.Every(GCJob, jobs.DefaultQueue, time.Hour)
+}
+
+// Preview is one rendered size of a source object.
+type Preview struct {
+	ID          int       `json:"id"`
+	SourceKey   string    `json:"-"`
+	Width       int       `json:"width"`
+	Height      int       `json:"height"`
+	Status      string    `json:"status"`
+	StorageKey  string    `json:"-"`
+	ContentType string    `json:"content_type,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	// Set by Sign on ready previews.
+	URL          string     `json:"url,omitempty"`
+	URLExpiresAt *time.Time `json:"url_expires_at,omitempty"`
+}
+
+type renderPayload struct {
+	PreviewID   int    `json:"preview_id"`
+	ContentType string `json:"content_type"`
+}
+
+// URLTTL is how long signed preview URLs work at least, read from
+// PREVIEW_URL_TTL and defaulting to an hour.
+func URLTTL() time.Duration {
+	if d, err := time.ParseDuration(os.Getenv("PREVIEW_URL_TTL")); err == nil && d > 0 {
+		return d
+	}
+	return time.Hour
+}
+
+// Supported reports whether files of a content type can be previewed.
+func Supported(contentType string) bool {
+	_, ok := decoders[mediaType(contentType)]
+	return ok
+}
+
+// Size parses a requested dimension, defaulting to DefaultSize, capping it
+// at MaxSize, and rounding it up to a multiple of step.
+func Size(raw string) (int, error) {
+	if raw == "" {
+		return DefaultSize, nil
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 1 {
+		return 0, errors.New("must be a positive integer")
+	}
+	n = min((n+step-1)/step*step, MaxSize)
+	return n, nil
+}
+
+// Request returns the preview of a source object that fits in width x
+// height, queueing it for rendering the first time it is asked for.
+func Request(ctx context.Context, sourceKey, contentType string, width, height int) (Preview, error) {
+	if !Supported(contentType) {
+		return Preview{}, ErrUnsupported
+	}
+
+	tx, err := database.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return Preview{}, err
+	}
+	defer tx.Rollback()
+
+	now := clock.Now()
+	p, err := scanPreview(tx.QueryRowContext(ctx, `
+		INSERT INTO attachment_previews (source_key, width, height, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $4)
+		ON CONFLICT (source_key, width, height) DO NOTHING
+		RETURNING `+previewColumns,
+		sourceKey, width, height, now))
+	if errors.Is(err, ErrNotFound) {
+		return scanPreview(database.DB.QueryRowContext(ctx,
+			"SELECT "+previewColumns+" FROM attachment_previews WHERE source_key = $1 AND width = $2 AND height = $3",
+			sourceKey, width, height))
+	}
+	if err != nil {
+		return p, err
+	}
+
+	if _, err := jobs.EnqueueWith(ctx, tx, jobs.DefaultQueue, RenderJob, renderPayload{PreviewID: p.ID, ContentType: contentType}); err != nil {
+		return p, err
+	}
+	return p, tx.Commit()
+}
+
+// Get returns a preview by ID.
+func Get(ctx context.Context, id int) (Preview, error) {
+	return scanPreview(database.DB.QueryRowContext(ctx,
+		"SELECT "+previewColumns+" FROM attachment_previews WHERE id = $1", id))
+}
+
+func scanPreview(row interface{ Scan(...interface{}) error }) (Preview, error) {
+	var p Preview
+	var storageKey sql.NullString
+	err := row.Scan(&p.ID, &p.SourceKey, &p.Width, &p.Height, &p.Status, &storageKey, &p.ContentType, &p.Error, &p.CreatedAt, &p.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return p, ErrNotFound
+	}
+	p.StorageKey = storageKey.String
+	return p, err
+}
+
+// collect deletes the previews of objects no attachment uses any more.
+// Their objects are queued in storage_deletions by a trigger.
+func collect(ctx context.Context, job *jobs.Job) error {
+	result, err := database.DB.ExecContext(ctx, `
+		DELETE FROM attachment_previews p
+		WHERE NOT EXISTS (SELECT 1 FROM attachments a WHERE a.storage_key = p.source_key)`)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n > 0 {
+		log.Printf("Deleted %d orphaned previews", n)
+	}
+	return nil
+}
+
+// linkPurpose separates preview URL signatures from other signed links.
+const linkPurpose = "preview"
+
+// ErrInvalidURL is returned for preview URLs that were not signed here or
+// have expired.
+var ErrInvalidURL = errors.New("invalid or expired preview URL")
+
+// Sign adds a signed URL to a ready preview that anyone holding it can
+// fetch until URLExpiresAt. Expiry is rounded to URLTTL windows, so the
+// URL stays the same for a while and browsers can cache what it returns.
+func Sign(p Preview) Preview {
+	ttl := URLTTL()
+	expires := clock.Now().Truncate(ttl).Add(2 * ttl)
+	token := auth.SignLink(linkPurpose, strconv.Itoa(p.ID)+"-"+strconv.FormatInt(expires.Unix(), 10))
+	p.URL, p.URLExpiresAt = "/previews/"+token, &expires
+	return p
+}
+
+// Verify returns the preview ID and expiry in a token made by Sign.
+func Verify(token string) (int, time.Time, error) {
+	signed, err := auth.ParseLink(linkPurpose, token)
+	if err != nil {
+		return 0, time.Time{}, ErrInvalidURL
+	}
+	rawID, rawExpires, _ := strings.Cut(signed, "-")
+	id, err := strconv.Atoi(rawID)
+	if err != nil {
+		return 0, time.Time{}, ErrInvalidURL
+	}
+	unix, err := strconv.ParseInt(rawExpires, 10, 64)
+	if err != nil {
+		return 0, time.Time{}, ErrInvalidURL
+	}
+	expires := time.Unix(unix, 0)
+	if !clock.Now().Before(expires) {
+		return 0, time.Time{}, ErrInvalidURL
+	}
+	return id, expires, nil
+}
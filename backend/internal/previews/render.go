@@ -0,0 +1,242 @@
+package previews
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"log"
+	"mime"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"pygorp/backend/internal/clock"
+	"pygorp/backend/internal/database"
+	"pygorp/backend/internal/idgen"
+	"pygorp/backend/internal/jobs"
+	"pygorp/backend/internal/storage"
+)
+
+// maxSourcePixels rejects sources that would take too much memory to
+// decode.
+const maxSourcePixels = 40_000_000
+
+// pdfTimeout bounds how long rendering a PDF page may take.
+const pdfTimeout = 30 * time.Second
+
+// errRender wraps failures that retrying will not fix, such as a corrupt
+// file.
+type errRender struct{ msg string }
+
+func (e errRender) Error() string { return e.msg }
+
+// decoders turn a source file into an image no larger than the box asked
+// for, by content type.
+var decoders = map[string]func(ctx context.Context, data []byte, width, height int) (image.Image, error){
+	"image/jpeg":      decodeImage,
+	"image/png":       decodeImage,
+	"image/gif":       decodeImage,
+	"application/pdf": decodePDF,
+}
+
+// render produces a preview and stores it. Retries only happen for
+// storage and database errors; the preview is marked failed when the
+// source cannot be rendered or the job runs out of attempts.
+func render(ctx context.Context, job *jobs.Job, p renderPayload) error {
+	preview, err := Get(ctx, p.PreviewID)
+	if errors.Is(err, ErrNotFound) {
+		// Collected while the job was queued.
+		return nil
+	}
+	if err != nil || preview.Status == StatusReady {
+		return err
+	}
+
+	err = renderPreview(ctx, preview, p.ContentType)
+	var failure errRender
+	switch {
+	case errors.As(err, &failure):
+		return markFailed(ctx, preview.ID, failure.msg)
+	case err != nil && job.Attempts >= job.MaxAttempts:
+		if markErr := markFailed(ctx, preview.ID, "Failed to render preview"); markErr != nil {
+			log.Printf("Failed to mark preview %d failed: %v", preview.ID, markErr)
+		}
+	}
+	return err
+}
+
+func renderPreview(ctx context.Context, preview Preview, contentType string) error {
+	decode, ok := decoders[mediaType(contentType)]
+	if !ok {
+		return errRender{ErrUnsupported.Error()}
+	}
+	store, err := storage.Default()
+	if err != nil {
+		return err
+	}
+	data, err := store.Get(ctx, preview.SourceKey)
+	if errors.Is(err, storage.ErrNotFound) {
+		return errRender{"The attachment's file is missing"}
+	}
+	if err != nil {
+		return err
+	}
+
+	src, err := decode(ctx, data, preview.Width, preview.Height)
+	if err != nil {
+		return err
+	}
+	img := resizeFit(src, preview.Width, preview.Height)
+
+	var buf bytes.Buffer
+	ext, previewType := ".png", "image/png"
+	if mediaType(contentType) == "image/jpeg" {
+		ext, previewType = ".jpeg", "image/jpeg"
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 85})
+	} else {
+		err = png.Encode(&buf, img)
+	}
+	if err != nil {
+		return err
+	}
+
+	key := "previews/" + idgen.NewID() + ext
+	if err := store.Put(ctx, key, previewType, buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to store preview: %v", err)
+	}
+	result, err := database.DB.ExecContext(ctx, `
+		UPDATE attachment_previews SET status = $2, storage_key = $3, content_type = $4, error = '', updated_at = $5
+		WHERE id = $1 AND status <> $2`,
+		preview.ID, StatusReady, key, previewType, clock.Now())
+	if err == nil {
+		var n int64
+		if n, err = result.RowsAffected(); err == nil && n == 0 {
+			// Collected, or rendered by another run, in the meantime.
+			err = store.Delete(ctx, key)
+		}
+	}
+	return err
+}
+
+func markFailed(ctx context.Context, id int, msg string) error {
+	_, err := database.DB.ExecContext(ctx,
+		"UPDATE attachment_previews SET status = $2, error = $3, updated_at = $4 WHERE id = $1 AND status = $5",
+		id, StatusFailed, msg, clock.Now(), StatusPending)
+	return err
+}
+
+func decodeImage(ctx context.Context, data []byte, width, height int) (image.Image, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, errRender{"The image could not be read"}
+	}
+	if cfg.Width*cfg.Height > maxSourcePixels {
+		return nil, errRender{"The image is too large to preview"}
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, errRender{"The image could not be read"}
+	}
+	return img, nil
+}
+
+// decodePDF renders the first page of a PDF with pdftoppm from poppler,
+// or the command in PREVIEW_PDFTOPPM, scaled to fit the box.
+func decodePDF(ctx context.Context, data []byte, width, height int) (image.Image, error) {
+	command := os.Getenv("PREVIEW_PDFTOPPM")
+	if command == "" {
+		command = "pdftoppm"
+	}
+	if _, err := exec.LookPath(command); err != nil {
+		return nil, errRender{"PDF previews are not available on this server"}
+	}
+
+	dir, err := os.MkdirTemp("", "preview-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	in := filepath.Join(dir, "source.pdf")
+	if err := os.WriteFile(in, data, 0o600); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, pdfTimeout)
+	defer cancel()
+	out := filepath.Join(dir, "page")
+	cmd := exec.CommandContext(ctx, command, "-png", "-f", "1", "-l", "1", "-singlefile",
+		"-scale-to-x", fmt.Sprint(width), "-scale-to-y", "-1", in, out)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		log.Printf("pdftoppm failed: %v: %s", err, output)
+		return nil, errRender{"The PDF could not be rendered"}
+	}
+
+	page, err := os.ReadFile(out + ".png")
+	if err != nil {
+		return nil, errRender{"The PDF could not be rendered"}
+	}
+	return decodeImage(ctx, page, width, height)
+}
+
+// resizeFit scales src down to fit in width x height, keeping its aspect
+// ratio and averaging the source pixels that fall into each destination
+// pixel. Sources that already fit are returned as they are.
+func resizeFit(src image.Image, width, height int) image.Image {
+	b := src.Bounds()
+	if b.Dx() <= width && b.Dy() <= height {
+		return src
+	}
+	w, h := width, b.Dy()*width/b.Dx()
+	if h > height {
+		w, h = b.Dx()*height/b.Dy(), height
+	}
+	w, h = max(w, 1), max(h, 1)
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+
+	for dy := 0; dy < h; dy++ {
+		sy0 := b.Min.Y + dy*b.Dy()/h
+		sy1 := b.Min.Y + (dy+1)*b.Dy()/h
+		for dx := 0; dx < w; dx++ {
+			sx0 := b.Min.X + dx*b.Dx()/w
+			sx1 := b.Min.X + (dx+1)*b.Dx()/w
+
+			var r, g, bl, a, n uint64
+			for sy := sy0; sy < sy1; sy++ {
+				for sx := sx0; sx < sx1; sx++ {
+					pr, pg, pb, pa := src.At(sx, sy).RGBA()
+					r += uint64(pr)
+					g += uint64(pg)
+					bl += uint64(pb)
+					a += uint64(pa)
+					n++
+				}
+			}
+			if n == 0 {
+				continue
+			}
+			dst.Set(dx, dy, color.RGBA64{
+				R: uint16(r / n),
+				G: uint16(g / n),
+				B: uint16(bl / n),
+				A: uint16(a / n),
+			})
+		}
+	}
+	return dst
+}
+
+// mediaType strips any parameters from a content type.
+func mediaType(contentType string) string {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return contentType
+	}
+	return mt
+}
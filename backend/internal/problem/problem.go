@@ -0,0 +1,209 @@
+// Package problem renders error responses as RFC 7807 problem details
+// (application/problem+json). Handlers keep answering errors with
+// {"error": "...", "code": "..."}; the middleware rewrites them on the way
+// out into
+//
+//	{"type": "/problems/not_found", "title": "Not found", "status": 404,
+//	 "detail": "User not found", "instance": "/api/v1/users/7", "code": "not_found"}
+//
+// Each code has a type URI documented by GET /problems/:code. Errors without
+// a code, or with one that is not documented, get the code of their status.
+// Other fields of the error body, such as max_limit or request_id, are kept
+// as extension members.
+//
+// The runtime config's error_format switches back to the legacy
+// {"error": ...} shape while clients migrate; clients that send
+// "Accept: application/problem+json" get problem details either way.
+package problem
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+
+	"pygorp/backend/internal/config"
+	"pygorp/backend/internal/render"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MediaType is the problem details media type.
+const MediaType = "application/problem+json"
+
+// Type documents one kind of problem.
+type Type struct {
+	Code        string `json:"code"`
+	URI         string `json:"type"`
+	Status      int    `json:"status"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+}
+
+// byStatus are the types of errors that have no code of their own.
+var byStatus = map[int]Type{
+	http.StatusBadRequest:            {Code: "bad_request", Title: "Bad request", Description: "The request is malformed or a parameter is invalid; detail says which."},
+	http.StatusUnauthorized:          {Code: "unauthorized", Title: "Unauthorized", Description: "The request has no valid credentials. Sign in or send a valid bearer token."},
+	http.StatusForbidden:             {Code: "forbidden", Title: "Forbidden", Description: "The caller is authenticated but may not do this, for example because a token lacks a scope."},
+	http.StatusNotFound:              {Code: "not_found", Title: "Not found", Description: "The addressed resource does not exist or is not visible to the caller."},
+	http.StatusMethodNotAllowed:      {Code: "method_not_allowed", Title: "Method not allowed", Description: "The route does not support the request method."},
+	http.StatusNotAcceptable:         {Code: "not_acceptable", Title: "Not acceptable", Description: "None of the media types in Accept can be served."},
+	http.StatusConflict:              {Code: "conflict", Title: "Conflict", Description: "The request conflicts with the resource's current state, such as a duplicate email."},
+	http.StatusGone:                  {Code: "gone", Title: "Gone", Description: "The resource existed but has been removed for good."},
+	http.StatusPreconditionFailed:    {Code: "precondition_failed", Title: "Precondition failed", Description: "A conditional header such as If-Match did not match the resource's current state."},
+	http.StatusRequestEntityTooLarge: {Code: "payload_too_large", Title: "Payload too large", Description: "The request body exceeds the size the route accepts."},
+	http.StatusUnsupportedMediaType:  {Code: "unsupported_media_type", Title: "Unsupported media type", Description: "The request body's Content-Type is not accepted by the route."},
+	http.StatusUnprocessableEntity:   {Code: "unprocessable_entity", Title: "Unprocessable entity", Description: "The request is well-formed but its content cannot be processed."},
+	http.StatusLocked:                {Code: "locked", Title: "Locked", Description: "The resource is under legal hold and cannot be deleted until the hold is cleared."},
+	http.StatusTooManyRequests:       {Code: "rate_limited", Title: "Too many requests", Description: "The client exceeded its rate limit. Retry after the time in Retry-After."},
+	http.StatusInternalServerError:   {Code: "internal_error", Title: "Internal server error", Description: "The server failed to handle the request. Report the request_id when it persists."},
+	http.StatusNotImplemented:        {Code: "not_implemented", Title: "Not implemented", Description: "The server does not support this feature in its current configuration."},
+	http.StatusBadGateway:            {Code: "bad_gateway", Title: "Bad gateway", Description: "A service the request depends on answered with an error."},
+	http.StatusServiceUnavailable:    {Code: "service_unavailable", Title: "Service unavailable", Description: "The server cannot handle the request right now, for example while read-only. Retry later."},
+	http.StatusGatewayTimeout:        {Code: "gateway_timeout", Title: "Gateway timeout", Description: "A service the request depends on did not answer in time."},
+}
+
+// byCode are the types of errors handlers mark with a code because clients
+// are expected to handle them.
+var byCode = map[string]Type{
+	"blocked_word":        {Status: http.StatusBadRequest, Title: "Blocked word", Description: "A field contains a word on the blocklist; detail names the field."},
+	"disposable_email":    {Status: http.StatusBadRequest, Title: "Disposable email", Description: "Email addresses from disposable domains are not accepted."},
+	"undeliverable_email": {Status: http.StatusBadRequest, Title: "Undeliverable email", Description: "The email's domain has no MX records, so it cannot receive email."},
+	"limit_too_large":     {Status: http.StatusBadRequest, Title: "Limit too large", Description: "The limit parameter exceeds max_limit; page through the list with offset instead."},
+	"too_many_rows":       {Status: http.StatusBadRequest, Title: "Too many rows", Description: "The result exceeds max_rows, the most one request may return; page through it with limit and offset."},
+	"consent_required":    {Status: http.StatusUnavailableForLegalReasons, Title: "Consent required", Description: "The user must accept the current version of the policies in consents, at consent_url, before using the API again. Route groups can be configured to answer 409 instead."},
+	"overloaded":          {Status: http.StatusServiceUnavailable, Title: "Overloaded", Description: "The server is shedding load. Retry after the time in Retry-After."},
+}
+
+func init() {
+	for status, t := range byStatus {
+		t.Status = status
+		t.URI = "/problems/" + t.Code
+		byStatus[status] = t
+		byCode[t.Code] = t
+	}
+	for code, t := range byCode {
+		t.Code = code
+		t.URI = "/problems/" + code
+		byCode[code] = t
+	}
+}
+
+// Types returns every documented problem type, by code.
+func Types() []Type {
+	types := make([]Type, 0, len(byCode))
+	for _, t := range byCode {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i].Code < types[j].Code })
+	return types
+}
+
+// Lookup returns the problem type of a code.
+func Lookup(code string) (Type, bool) {
+	t, ok := byCode[code]
+	return t, ok
+}
+
+// ForStatus returns the type of errors with a status and no code of their
+// own. Statuses without one get about:blank, as RFC 7807 suggests.
+func ForStatus(status int) Type {
+	if t, ok := byStatus[status]; ok {
+		return t
+	}
+	return Type{URI: "about:blank", Status: status, Title: http.StatusText(status)}
+}
+
+// Middleware rewrites JSON error bodies with an "error" field into problem
+// details. It must run outside the error renderer so server errors are
+// converted after they have been scrubbed, and outside JSON:API so clients
+// asking for JSON:API keep getting its error documents.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if config.Current().ErrorFormat == "legacy" && !accepts(c.GetHeader("Accept")) {
+			c.Next()
+			return
+		}
+
+		w := render.NewBuffer(c.Writer, isError)
+		c.Writer = w
+		c.Next()
+		c.Writer = w.ResponseWriter
+
+		if !w.Buffered() {
+			return
+		}
+		body := w.Bytes()
+		if render.IsJSON(w.Header().Get("Content-Type")) {
+			if problem, ok := convert(c, w.Status(), body); ok {
+				w.Header().Set("Content-Type", MediaType)
+				body = problem
+			}
+		}
+		w.Release(body)
+	}
+}
+
+func accepts(header string) bool {
+	for _, entry := range strings.Split(header, ",") {
+		if mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(entry)); err == nil && mediaType == MediaType {
+			return true
+		}
+	}
+	return false
+}
+
+// isError holds back error responses so they can be rewritten once the
+// handler is done. Other responses are written through.
+func isError(w gin.ResponseWriter) bool {
+	return w.Status() >= http.StatusBadRequest
+}
+
+// convert rewrites a legacy error body into problem details. Bodies without
+// an "error" string, such as SCIM errors, are left alone.
+func convert(c *gin.Context, status int, body []byte) ([]byte, bool) {
+	var fields map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if err := dec.Decode(&fields); err != nil {
+		return nil, false
+	}
+	detail, ok := fields["error"].(string)
+	if !ok {
+		return nil, false
+	}
+
+	code, _ := fields["code"].(string)
+	t, ok := Lookup(code)
+	if !ok {
+		t = ForStatus(status)
+		if code == "" {
+			code = t.Code
+		}
+	}
+
+	problem := map[string]interface{}{}
+	for key, value := range fields {
+		if key != "error" {
+			problem[key] = value
+		}
+	}
+	problem["type"] = t.URI
+	problem["title"] = t.Title
+	problem["status"] = status
+	problem["detail"] = detail
+	problem["instance"] = c.Request.URL.Path
+	if code != "" {
+		problem["code"] = code
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(problem); err != nil {
+		return nil, false
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), true
+}
@@ -0,0 +1,90 @@
+package proxy
+
+import (
+	"sync"
+	"time"
+)
+
+type breakerState int
+
+const (
+	stateClosed breakerState = iota
+	stateOpen
+	stateHalfOpen
+)
+
+func (s breakerState) String() string {
+	switch s {
+	case stateOpen:
+		return "open"
+	case stateHalfOpen:
+		return "half_open"
+	default:
+		return "closed"
+	}
+}
+
+// breaker opens after Threshold consecutive failures and rejects requests
+// until Cooldown has passed. It then lets a single trial request through:
+// success closes it again, failure reopens it.
+type breaker struct {
+	Threshold int
+	Cooldown  time.Duration
+
+	mu       sync.Mutex
+	state    breakerState
+	failures int
+	openedAt time.Time
+	trial    bool
+}
+
+// Allow reports whether a request may proceed, and if not, how long until
+// the breaker will accept a trial.
+func (b *breaker) Allow() (bool, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case stateOpen:
+		wait := b.Cooldown - time.Since(b.openedAt)
+		if wait > 0 {
+			return false, wait
+		}
+		b.state = stateHalfOpen
+		b.trial = true
+		return true, 0
+	case stateHalfOpen:
+		if b.trial {
+			return false, b.Cooldown
+		}
+		b.trial = true
+		return true, 0
+	default:
+		return true, 0
+	}
+}
+
+// Record updates the breaker with the outcome of a request.
+func (b *breaker) Record(success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.trial = false
+	if success {
+		b.state = stateClosed
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.state == stateHalfOpen || b.failures >= b.Threshold {
+		b.state = stateOpen
+		b.openedAt = time.Now()
+	}
+}
+
+func (b *breaker) State() breakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
@@ -0,0 +1,303 @@
+// Package proxy forwards API requests to the Python service.
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"pygorp/backend/internal/env"
+	"pygorp/backend/internal/metrics"
+	"pygorp/backend/internal/middleware"
+	"pygorp/backend/internal/render"
+	"pygorp/backend/internal/svcauth"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxBodyBytes caps request bodies, which are buffered so they can be
+// replayed on retry.
+const maxBodyBytes = 10 << 20
+
+// Hop-by-hop headers are meaningful for a single connection only.
+var hopHeaders = []string{
+	"Connection", "Keep-Alive", "Proxy-Authenticate", "Proxy-Authorization",
+	"Te", "Trailer", "Transfer-Encoding", "Upgrade",
+}
+
+// Config controls how requests are forwarded.
+type Config struct {
+	// Target is the base URL of the Python service.
+	Target *url.URL
+	// StripPrefix is removed from the incoming path before forwarding.
+	StripPrefix string
+	// Budget bounds the whole request, including retries.
+	Budget time.Duration
+	// AttemptTimeout bounds a single attempt within the budget.
+	AttemptTimeout time.Duration
+	// Retries is the number of extra attempts for idempotent requests.
+	Retries int
+
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+}
+
+// Proxy is a reverse proxy with retries and a circuit breaker.
+type Proxy struct {
+	cfg     Config
+	client  *http.Client
+	breaker *breaker
+}
+
+func New(cfg Config) *Proxy {
+	return &Proxy{
+		cfg: cfg,
+		client: &http.Client{
+			// Redirects are passed through to the caller.
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+		breaker: &breaker{Threshold: cfg.BreakerThreshold, Cooldown: cfg.BreakerCooldown},
+	}
+}
+
+// FromEnv builds the proxy for the Python service from AI_SERVICE_URL and
+// related settings. Paths under stripPrefix are forwarded beneath
+// PY_SERVICE_BASE_PATH on the service.
+func FromEnv(stripPrefix string) (*Proxy, error) {
+	target, err := url.Parse(env.String("AI_SERVICE_URL", "http://localhost:8000"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid AI_SERVICE_URL: %v", err)
+	}
+	target.Path = strings.TrimRight(target.Path, "/") + env.String("PY_SERVICE_BASE_PATH", "/api/v1")
+	budgetSeconds, _ := strconv.Atoi(env.String("AI_SERVICE_TIMEOUT", "30"))
+	attemptTimeout, err := time.ParseDuration(env.String("PROXY_ATTEMPT_TIMEOUT", "10s"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid PROXY_ATTEMPT_TIMEOUT: %v", err)
+	}
+	retries, _ := strconv.Atoi(env.String("PROXY_RETRIES", "2"))
+	threshold, _ := strconv.Atoi(env.String("PROXY_BREAKER_THRESHOLD", "5"))
+	cooldown, err := time.ParseDuration(env.String("PROXY_BREAKER_COOLDOWN", "30s"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid PROXY_BREAKER_COOLDOWN: %v", err)
+	}
+
+	return New(Config{
+		Target:           target,
+		StripPrefix:      stripPrefix,
+		Budget:           time.Duration(budgetSeconds) * time.Second,
+		AttemptTimeout:   attemptTimeout,
+		Retries:          retries,
+		BreakerThreshold: threshold,
+		BreakerCooldown:  cooldown,
+	}), nil
+}
+
+// Handler forwards the request and copies the upstream response back.
+func (p *Proxy) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if ok, wait := p.breaker.Allow(); !ok {
+			metrics.ProxyRequests.WithLabelValues("rejected").Inc()
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			render.JSON(c, http.StatusServiceUnavailable, gin.H{"error": "Python service is unavailable"})
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxBodyBytes+1))
+		if err != nil {
+			render.JSON(c, http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+			return
+		}
+		if len(body) > maxBodyBytes {
+			render.JSON(c, http.StatusRequestEntityTooLarge, gin.H{"error": "Request body is too large"})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), p.cfg.Budget)
+		defer cancel()
+
+		resp, err := p.roundTrip(ctx, c, body)
+		if err != nil {
+			p.breaker.Record(false)
+			metrics.ProxyRequests.WithLabelValues("error").Inc()
+			log.Printf("Proxy request to %s failed: %v", c.Request.URL.Path, err)
+			if errors.Is(err, context.DeadlineExceeded) {
+				render.JSON(c, http.StatusGatewayTimeout, gin.H{"error": "Python service timed out"})
+				return
+			}
+			render.JSON(c, http.StatusBadGateway, gin.H{"error": "Python service is unreachable"})
+			return
+		}
+		defer resp.Body.Close()
+
+		p.breaker.Record(resp.StatusCode < 500)
+		metrics.ProxyRequests.WithLabelValues(strconv.Itoa(resp.StatusCode/100) + "xx").Inc()
+
+		header := c.Writer.Header()
+		for key, values := range resp.Header {
+			header[key] = values
+		}
+		removeHopHeaders(header)
+		c.Status(resp.StatusCode)
+		io.Copy(c.Writer, resp.Body)
+	}
+}
+
+// roundTrip sends the request, retrying idempotent methods on connection
+// errors and gateway failures while the budget allows.
+func (p *Proxy) roundTrip(ctx context.Context, c *gin.Context, body []byte) (*http.Response, error) {
+	attempts := 1
+	if isIdempotent(c.Request.Method) {
+		attempts += p.cfg.Retries
+	}
+
+	var lastErr error
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			backoff := time.Duration(attempt*attempt) * 100 * time.Millisecond
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(backoff):
+			}
+		}
+
+		resp, err := p.attempt(ctx, c, body)
+		if err == nil && !retryableStatus(resp.StatusCode) {
+			return resp, nil
+		}
+		if err == nil {
+			if attempt == attempts-1 {
+				return resp, nil
+			}
+			resp.Body.Close()
+			lastErr = fmt.Errorf("upstream returned %d", resp.StatusCode)
+			continue
+		}
+		lastErr = err
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+	}
+	return nil, lastErr
+}
+
+// attempt makes one upstream request. AttemptTimeout bounds the wait for
+// response headers; the body is then streamed within the overall budget.
+func (p *Proxy) attempt(ctx context.Context, c *gin.Context, body []byte) (*http.Response, error) {
+	attemptCtx, cancel := context.WithCancel(ctx)
+	req, err := http.NewRequestWithContext(attemptCtx, c.Request.Method, p.targetURL(c.Request.URL), bytes.NewReader(body))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	p.copyHeaders(req, c, ctx)
+
+	timer := time.AfterFunc(p.cfg.AttemptTimeout, cancel)
+	resp, err := p.client.Do(req)
+	if !timer.Stop() && err != nil && ctx.Err() == nil {
+		cancel()
+		return nil, fmt.Errorf("attempt timed out after %s: %w", p.cfg.AttemptTimeout, context.DeadlineExceeded)
+	}
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+func (p *Proxy) targetURL(in *url.URL) string {
+	out := *p.cfg.Target
+	out.Path = strings.TrimRight(p.cfg.Target.Path, "/") + "/" + strings.TrimLeft(strings.TrimPrefix(in.Path, p.cfg.StripPrefix), "/")
+	out.RawQuery = in.RawQuery
+	return out.String()
+}
+
+// copyHeaders forwards the client's headers and adds request context: the
+// request ID, the caller's identity, and the remaining timeout budget.
+func (p *Proxy) copyHeaders(req *http.Request, c *gin.Context, ctx context.Context) {
+	for key, values := range c.Request.Header {
+		// Identity headers are only ever set by the backend.
+		if strings.HasPrefix(key, "X-Auth-") || key == svcauth.Header {
+			continue
+		}
+		req.Header[key] = append([]string(nil), values...)
+	}
+	removeHopHeaders(req.Header)
+	req.Host = p.cfg.Target.Host
+
+	if clientIP, _, err := net.SplitHostPort(c.Request.RemoteAddr); err == nil {
+		if prior := req.Header.Get("X-Forwarded-For"); prior != "" {
+			clientIP = prior + ", " + clientIP
+		}
+		req.Header.Set("X-Forwarded-For", clientIP)
+	}
+	req.Header.Set("X-Forwarded-Host", c.Request.Host)
+	proto := "http"
+	if c.Request.TLS != nil {
+		proto = "https"
+	}
+	req.Header.Set("X-Forwarded-Proto", proto)
+
+	if id := c.GetString("request_id"); id != "" {
+		req.Header.Set(middleware.RequestIDHeader, id)
+	}
+	if subject := c.GetString("auth_subject"); subject != "" {
+		req.Header.Set("X-Auth-Subject", subject)
+	}
+	if scopes := c.GetStringSlice("auth_scopes"); len(scopes) > 0 {
+		req.Header.Set("X-Auth-Scopes", strings.Join(scopes, " "))
+	}
+	if svcauth.Enabled() {
+		if token, err := svcauth.Issue(svcauth.Python, c.GetString("auth_subject")); err == nil {
+			req.Header.Set(svcauth.Header, token)
+		}
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		req.Header.Set("X-Request-Timeout-Ms", strconv.FormatInt(time.Until(deadline).Milliseconds(), 10))
+	}
+}
+
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+func removeHopHeaders(h http.Header) {
+	for _, name := range h.Values("Connection") {
+		for _, field := range strings.Split(name, ",") {
+			h.Del(strings.TrimSpace(field))
+		}
+	}
+	for _, name := range hopHeaders {
+		h.Del(name)
+	}
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+func retryableStatus(code int) bool {
+	return code == http.StatusBadGateway || code == http.StatusServiceUnavailable || code == http.StatusGatewayTimeout
+}
@@ -0,0 +1,57 @@
+// Package quota assigns tenants their share of the API. A plan sets rate
+// limits per rate-limit class and a daily request quota; orgs are put on a
+// plan, and requests by their users count against it instead of the
+// per-client-IP limits in the runtime config.
+package quota
+
+import (
+	"fmt"
+	"time"
+
+	"pygorp/backend/internal/config"
+)
+
+// DefaultClass is the plan rate limit used for classes the plan does not
+// list.
+const DefaultClass = "default"
+
+const maxNameLength = 50
+
+// Plan is a tier of service. A DailyQuota of 0 means no quota.
+type Plan struct {
+	Name       string                      `json:"name"`
+	RateLimits map[string]config.RateLimit `json:"rate_limits"`
+	DailyQuota int                         `json:"daily_quota"`
+	CreatedAt  time.Time                   `json:"created_at"`
+	UpdatedAt  time.Time                   `json:"updated_at"`
+}
+
+// Check rejects plans that cannot be stored or enforced.
+func (p *Plan) Check() error {
+	if p.Name == "" || len(p.Name) > maxNameLength {
+		return fmt.Errorf("name must be 1 to %d characters", maxNameLength)
+	}
+	if p.DailyQuota < 0 {
+		return fmt.Errorf("daily_quota must not be negative")
+	}
+	for class, limit := range p.RateLimits {
+		if limit.RequestsPerSecond < 0 {
+			return fmt.Errorf("rate_limits.%s.requests_per_second must not be negative", class)
+		}
+		if limit.RequestsPerSecond > 0 && limit.Burst < 1 {
+			return fmt.Errorf("rate_limits.%s.burst must be at least 1", class)
+		}
+	}
+	return nil
+}
+
+// RateLimitFor returns the plan's limit for class, or its default limit.
+// ok is false when the plan sets neither, leaving the class to the runtime
+// config.
+func (p *Plan) RateLimitFor(class string) (limit config.RateLimit, ok bool) {
+	if limit, ok = p.RateLimits[class]; ok {
+		return limit, true
+	}
+	limit, ok = p.RateLimits[DefaultClass]
+	return limit, ok
+}
@@ -0,0 +1,205 @@
+package quota
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"pygorp/backend/internal/clock"
+	"pygorp/backend/internal/database"
+
+	"github.com/lib/pq"
+)
+
+var (
+	// ErrNotFound is returned when a plan does not exist.
+	ErrNotFound = errors.New("plan not found")
+	// ErrOrgNotFound is returned when assigning a plan to a missing org.
+	ErrOrgNotFound = errors.New("org not found")
+)
+
+// cacheTTL bounds how long other instances keep enforcing a plan after it
+// is changed, or after an org or user moves to another plan. Changes made
+// through this instance apply immediately.
+const cacheTTL = 30 * time.Second
+
+const columns = "name, rate_limits, daily_quota, created_at, updated_at"
+
+func init() {
+	database.UseColumns("plans", columns)
+	database.UseColumns("orgs", "id, plan")
+	database.UseColumns("org_usage", "org_id, day, requests")
+}
+
+// Tenant is the org a user belongs to and the plan it is on.
+type Tenant struct {
+	OrgID int
+	Plan  *Plan
+}
+
+// org is a cached user's org. A zero id means the user has none.
+type org struct {
+	id   int
+	plan string
+}
+
+var cache struct {
+	sync.Mutex
+	plans  map[string]*Plan
+	orgs   map[string]org
+	loaded time.Time
+}
+
+func scan(row interface{ Scan(...interface{}) error }) (*Plan, error) {
+	var (
+		p      Plan
+		limits []byte
+	)
+	err := row.Scan(&p.Name, &limits, &p.DailyQuota, &p.CreatedAt, &p.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(limits, &p.RateLimits); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// List returns every plan ordered by name.
+func List(ctx context.Context) ([]*Plan, error) {
+	rows, err := database.DB.QueryContext(ctx, "SELECT "+columns+" FROM plans ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	plans := []*Plan{}
+	for rows.Next() {
+		p, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		plans = append(plans, p)
+	}
+	return plans, rows.Err()
+}
+
+// Put creates or replaces the plan named p.Name.
+func Put(ctx context.Context, p *Plan) (*Plan, error) {
+	defer Invalidate()
+	limits, err := json.Marshal(p.RateLimits)
+	if err != nil {
+		return nil, err
+	}
+	now := clock.Now()
+	return scan(database.DB.QueryRowContext(ctx, `
+		INSERT INTO plans (name, rate_limits, daily_quota, created_at, updated_at) VALUES ($1, $2, $3, $4, $4)
+		ON CONFLICT (name) DO UPDATE SET rate_limits = EXCLUDED.rate_limits, daily_quota = EXCLUDED.daily_quota, updated_at = EXCLUDED.updated_at
+		RETURNING `+columns,
+		p.Name, limits, p.DailyQuota, now))
+}
+
+// Delete removes a plan. Orgs on it go back to per-client-IP limits.
+func Delete(ctx context.Context, name string) error {
+	defer Invalidate()
+	result, err := database.DB.ExecContext(ctx, "DELETE FROM plans WHERE name = $1", name)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// SetOrgPlan puts an org on a plan, or takes it off its plan when plan is
+// empty.
+func SetOrgPlan(ctx context.Context, orgID int, plan string) error {
+	defer Invalidate()
+	result, err := database.DB.ExecContext(ctx,
+		"UPDATE orgs SET plan = NULLIF($1, ''), updated_at = $2 WHERE id = $3", plan, clock.Now(), orgID)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrOrgNotFound
+	}
+	return nil
+}
+
+// Invalidate drops the cached plans and user orgs, so the next request
+// reloads them.
+func Invalidate() {
+	cache.Lock()
+	cache.plans, cache.orgs = nil, nil
+	cache.Unlock()
+}
+
+// ForUser returns the tenant of a user ID ("auth_subject"), or nil when the
+// user belongs to no org or the org has no plan.
+func ForUser(ctx context.Context, userID string) (*Tenant, error) {
+	if _, err := strconv.Atoi(userID); err != nil {
+		return nil, nil
+	}
+	plans, err := currentPlans(ctx)
+	if err != nil || len(plans) == 0 {
+		return nil, err
+	}
+
+	cache.Lock()
+	o, ok := cache.orgs[userID]
+	cache.Unlock()
+	if !ok {
+		var id sql.NullInt64
+		var plan sql.NullString
+		err := database.DB.QueryRowContext(ctx,
+			"SELECT o.id, o.plan FROM users u JOIN orgs o ON o.id = u.org_id WHERE u.id = $1", userID).Scan(&id, &plan)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		o = org{id: int(id.Int64), plan: plan.String}
+		cache.Lock()
+		if cache.orgs != nil {
+			cache.orgs[userID] = o
+		}
+		cache.Unlock()
+	}
+
+	plan := plans[o.plan]
+	if o.id == 0 || plan == nil {
+		return nil, nil
+	}
+	return &Tenant{OrgID: o.id, Plan: plan}, nil
+}
+
+// currentPlans returns the plans by name, reloading them and forgetting
+// user orgs when the cache has expired.
+func currentPlans(ctx context.Context) (map[string]*Plan, error) {
+	cache.Lock()
+	defer cache.Unlock()
+
+	if cache.plans != nil && time.Since(cache.loaded) < cacheTTL {
+		return cache.plans, nil
+	}
+	list, err := List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	plans := make(map[string]*Plan, len(list))
+	for _, p := range list {
+		plans[p.Name] = p
+	}
+	cache.plans, cache.orgs, cache.loaded = plans, map[string]org{}, time.Now()
+	return plans, nil
+}
@@ -0,0 +1,152 @@
+package quota
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"pygorp/backend/internal/clock"
+	"pygorp/backend/internal/database"
+
+	"github.com/lib/pq"
+)
+
+// flushInterval is how often each instance adds its request counts to
+// org_usage and reads back the totals of every instance.
+const flushInterval = 5 * time.Second
+
+const flushUsageQuery = `
+	INSERT INTO org_usage (org_id, day, requests)
+	SELECT * FROM unnest($1::int[], $2::date[], $3::bigint[]) AS u(org_id, day, requests)
+	WHERE EXISTS (SELECT 1 FROM orgs WHERE orgs.id = u.org_id)
+	ON CONFLICT (org_id, day) DO UPDATE SET requests = org_usage.requests + EXCLUDED.requests
+	RETURNING org_id, day, requests`
+
+type usageKey struct {
+	org int
+	day string
+}
+
+// counter is one org's requests on one day: the total of every instance
+// as of the last flush, plus those counted here since.
+type counter struct {
+	total   int64
+	pending int64
+}
+
+var usage struct {
+	sync.Mutex
+	once   sync.Once
+	counts map[usageKey]*counter
+}
+
+// Use counts a request by an org against its daily quota (0 for none) and
+// returns how many requests the org has made today, this one included. ok
+// is false, and the request is not counted, when the quota is used up.
+//
+// Instances share their counts every flushInterval, so together they can
+// go over a quota by the requests they take in that time.
+func Use(orgID, quota int) (used int64, ok bool) {
+	usage.once.Do(func() { go flushUsage() })
+
+	key := usageKey{org: orgID, day: Day(clock.Now())}
+	usage.Lock()
+	defer usage.Unlock()
+
+	if usage.counts == nil {
+		usage.counts = map[usageKey]*counter{}
+	}
+	c := usage.counts[key]
+	if c == nil {
+		c = &counter{}
+		usage.counts[key] = c
+	}
+	used = c.total + c.pending
+	if quota > 0 && used >= int64(quota) {
+		return used, false
+	}
+	c.pending++
+	return used + 1, true
+}
+
+// Day returns the UTC day quotas are counted on.
+func Day(t time.Time) string {
+	return t.UTC().Format(time.DateOnly)
+}
+
+// NextDay returns when the quota day after t starts.
+func NextDay(t time.Time) time.Time {
+	y, m, d := t.UTC().Date()
+	return time.Date(y, m, d+1, 0, 0, 0, 0, time.UTC)
+}
+
+func flushUsage() {
+	for range time.Tick(flushInterval) {
+		if err := flush(context.Background()); err != nil {
+			log.Printf("Failed to flush org usage: %v", err)
+		}
+	}
+}
+
+// flush adds the pending counts to org_usage and takes the new totals.
+// Orgs without pending requests are included too, to pick up what other
+// instances counted. Days before today are dropped once written.
+func flush(ctx context.Context) error {
+	today := Day(clock.Now())
+
+	usage.Lock()
+	var (
+		orgs    []int
+		days    []string
+		pending []int64
+	)
+	for key, c := range usage.counts {
+		orgs, days, pending = append(orgs, key.org), append(days, key.day), append(pending, c.pending)
+		c.pending = 0
+	}
+	usage.Unlock()
+	if len(orgs) == 0 {
+		return nil
+	}
+
+	totals := map[usageKey]int64{}
+	err := func() error {
+		rows, err := database.DB.QueryContext(ctx, flushUsageQuery, pq.Array(orgs), pq.Array(days), pq.Array(pending))
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var (
+				key   usageKey
+				day   time.Time
+				total int64
+			)
+			if err := rows.Scan(&key.org, &day, &total); err != nil {
+				return err
+			}
+			key.day = day.Format(time.DateOnly)
+			totals[key] = total
+		}
+		return rows.Err()
+	}()
+
+	usage.Lock()
+	defer usage.Unlock()
+	for i, org := range orgs {
+		key := usageKey{org: org, day: days[i]}
+		c := usage.counts[key]
+		if err != nil {
+			c.pending += pending[i]
+			continue
+		}
+		if total, ok := totals[key]; ok {
+			c.total = total
+		}
+		if key.day < today {
+			delete(usage.counts, key)
+		}
+	}
+	return err
+}
@@ -0,0 +1,243 @@
+// Package redis is a minimal Redis client covering the list commands the
+// backend uses to exchange messages with the Python service.
+package redis
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"pygorp/backend/internal/env"
+)
+
+// ErrNil is returned when Redis replies with a nil value, such as a
+// blocking pop that timed out.
+var ErrNil = errors.New("redis: nil reply")
+
+// Client keeps a small pool of connections to one Redis server.
+type Client struct {
+	addr     string
+	password string
+	db       int
+	pool     chan *conn
+}
+
+type conn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+var (
+	defaultClient *Client
+	initErr       error
+	once          sync.Once
+)
+
+// Default returns a client for REDIS_URL.
+func Default() (*Client, error) {
+	once.Do(func() {
+		defaultClient, initErr = New(env.String("REDIS_URL", "redis://localhost:6379"))
+	})
+	return defaultClient, initErr
+}
+
+// New parses a redis://[:password@]host:port[/db] URL.
+func New(rawURL string) (*Client, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "redis" {
+		return nil, fmt.Errorf("invalid redis URL %q", rawURL)
+	}
+
+	c := &Client{addr: u.Host, pool: make(chan *conn, 8)}
+	if u.Port() == "" {
+		c.addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	if password, ok := u.User.Password(); ok {
+		c.password = password
+	}
+	if db := strings.TrimPrefix(u.Path, "/"); db != "" {
+		if c.db, err = strconv.Atoi(db); err != nil {
+			return nil, fmt.Errorf("invalid redis database %q", db)
+		}
+	}
+	return c, nil
+}
+
+// LPush prepends values to a list.
+func (c *Client) LPush(ctx context.Context, key string, values ...string) error {
+	_, err := c.Do(ctx, append([]string{"LPUSH", key}, values...)...)
+	return err
+}
+
+// BRPop blocks for up to timeout waiting for a value at the tail of key.
+// It returns ErrNil when the timeout passes.
+func (c *Client) BRPop(ctx context.Context, key string, timeout time.Duration) (string, error) {
+	seconds := strconv.FormatFloat(timeout.Seconds(), 'f', 3, 64)
+	reply, err := c.Do(ctx, "BRPOP", key, seconds)
+	if err != nil {
+		return "", err
+	}
+	pair, ok := reply.([]interface{})
+	if !ok || len(pair) != 2 {
+		return "", fmt.Errorf("redis: unexpected BRPOP reply %v", reply)
+	}
+	value, _ := pair[1].(string)
+	return value, nil
+}
+
+// Expire sets a key's time to live.
+func (c *Client) Expire(ctx context.Context, key string, ttl time.Duration) error {
+	_, err := c.Do(ctx, "EXPIRE", key, strconv.Itoa(int(ttl.Seconds())))
+	return err
+}
+
+// Del removes keys.
+func (c *Client) Del(ctx context.Context, keys ...string) error {
+	_, err := c.Do(ctx, append([]string{"DEL"}, keys...)...)
+	return err
+}
+
+// Do sends a command and returns its reply: a string, an int64, a slice of
+// replies, or ErrNil.
+func (c *Client) Do(ctx context.Context, args ...string) (interface{}, error) {
+	cn, err := c.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		cn.SetDeadline(deadline)
+	} else {
+		cn.SetDeadline(time.Time{})
+	}
+	// Blocking commands are bounded by their own timeout, so only abort them
+	// early when the context is cancelled.
+	stop := context.AfterFunc(ctx, func() { cn.SetDeadline(time.Now()) })
+	defer stop()
+
+	reply, err := cn.do(args)
+	if err != nil && !errors.Is(err, ErrNil) {
+		var redisErr replyError
+		if !errors.As(err, &redisErr) {
+			cn.Close()
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return nil, err
+		}
+	}
+	c.put(cn)
+	return reply, err
+}
+
+func (c *Client) get(ctx context.Context) (*conn, error) {
+	select {
+	case cn := <-c.pool:
+		return cn, nil
+	default:
+	}
+
+	var d net.Dialer
+	nc, err := d.DialContext(ctx, "tcp", c.addr)
+	if err != nil {
+		return nil, err
+	}
+	cn := &conn{Conn: nc, r: bufio.NewReader(nc)}
+	if c.password != "" {
+		if _, err := cn.do([]string{"AUTH", c.password}); err != nil {
+			cn.Close()
+			return nil, err
+		}
+	}
+	if c.db != 0 {
+		if _, err := cn.do([]string{"SELECT", strconv.Itoa(c.db)}); err != nil {
+			cn.Close()
+			return nil, err
+		}
+	}
+	return cn, nil
+}
+
+func (c *Client) put(cn *conn) {
+	select {
+	case c.pool <- cn:
+	default:
+		cn.Close()
+	}
+}
+
+type replyError string
+
+func (e replyError) Error() string { return "redis: " + string(e) }
+
+func (cn *conn) do(args []string) (interface{}, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := cn.Write([]byte(b.String())); err != nil {
+		return nil, err
+	}
+	return cn.readReply()
+}
+
+func (cn *conn) readReply() (interface{}, error) {
+	line, err := cn.r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("redis: empty reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, replyError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, ErrNil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(cn.r, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, ErrNil
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			item, err := cn.readReply()
+			if err != nil && !errors.Is(err, ErrNil) {
+				return nil, err
+			}
+			items[i] = item
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("redis: unexpected reply %q", line)
+	}
+}
@@ -0,0 +1,24 @@
+// Package region identifies where this instance runs, from REGION, so
+// responses, metrics, and logs can say which region served a request and
+// the database layer can prefer region-local read replicas.
+package region
+
+import "os"
+
+// Name returns the configured region, or "" when REGION is unset.
+func Name() string {
+	return os.Getenv("REGION")
+}
+
+// ServedBy identifies this instance for the X-Served-By header: the region
+// and hostname, e.g. "eu-west-1/api-7f9c".
+func ServedBy() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	if r := Name(); r != "" {
+		return r + "/" + host
+	}
+	return host
+}
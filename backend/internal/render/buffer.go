@@ -0,0 +1,90 @@
+package render
+
+import (
+	"bytes"
+	"mime"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// IsJSON reports whether contentType is application/json.
+func IsJSON(contentType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	return mediaType == "application/json"
+}
+
+// IsAnyJSON reports whether contentType is application/json or a +json
+// type, such as problem details or JSON:API.
+func IsAnyJSON(contentType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// Buffer is a response writer for middleware that rewrites responses once
+// the handler is done. It holds back the status line, headers, and body of
+// responses hold accepts, deciding when the handler first writes; other
+// responses are written through.
+//
+//	w := render.NewBuffer(c.Writer, render.HoldAll)
+//	c.Writer = w
+//	c.Next()
+//	c.Writer = w.ResponseWriter
+//	w.Release(rewrite(w.Bytes()))
+type Buffer struct {
+	gin.ResponseWriter
+	hold     func(gin.ResponseWriter) bool
+	body     bytes.Buffer
+	buffered bool
+}
+
+// NewBuffer returns a Buffer writing to w.
+func NewBuffer(w gin.ResponseWriter, hold func(gin.ResponseWriter) bool) *Buffer {
+	return &Buffer{ResponseWriter: w, hold: hold}
+}
+
+// HoldAll holds back every response.
+func HoldAll(gin.ResponseWriter) bool { return true }
+
+// HoldJSON holds back application/json responses.
+func HoldJSON(w gin.ResponseWriter) bool { return IsJSON(w.Header().Get("Content-Type")) }
+
+func (b *Buffer) Write(data []byte) (int, error) {
+	if !b.buffered && !b.hold(b.ResponseWriter) {
+		return b.ResponseWriter.Write(data)
+	}
+	if !b.buffered {
+		b.buffered = true
+		b.Header().Del("Content-Length")
+	}
+	return b.body.Write(data)
+}
+
+func (b *Buffer) WriteString(s string) (int, error) {
+	return b.Write([]byte(s))
+}
+
+// WriteHeaderNow is deferred until Release for responses that are held back.
+func (b *Buffer) WriteHeaderNow() {
+	if !b.buffered && !b.hold(b.ResponseWriter) {
+		b.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+// Buffered reports whether the response was held back.
+func (b *Buffer) Buffered() bool {
+	return b.buffered
+}
+
+// Bytes returns the held-back body.
+func (b *Buffer) Bytes() []byte {
+	return b.body.Bytes()
+}
+
+// Release writes the status line and headers, which may have been changed
+// since the handler set them, and body in place of the held-back body.
+func (b *Buffer) Release(body []byte) {
+	b.Header().Del("Content-Length")
+	b.ResponseWriter.WriteHeaderNow()
+	b.ResponseWriter.Write(body)
+}
@@ -0,0 +1,67 @@
+package render
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestBuffer(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	for _, tc := range []struct {
+		name        string
+		contentType string
+		held        bool
+	}{
+		{"json is held back", "application/json; charset=utf-8", true},
+		{"other types are written through", "text/csv", false},
+	} {
+		rec := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(rec)
+		w := NewBuffer(c.Writer, HoldJSON)
+		c.Writer = w
+
+		c.Header("Content-Type", tc.contentType)
+		c.Header("Content-Length", "2")
+		c.Status(http.StatusCreated)
+		c.Writer.WriteHeaderNow()
+		c.Writer.WriteString("{}")
+		if written := rec.Body.Len() > 0; w.Buffered() != tc.held || written == tc.held {
+			t.Errorf("%s: buffered = %v with %q written", tc.name, w.Buffered(), rec.Body.String())
+			continue
+		}
+		if !tc.held {
+			continue
+		}
+
+		w.Header().Set("X-Rewritten", "yes")
+		w.Release([]byte(`{"ok":true}`))
+		if rec.Code != http.StatusCreated || rec.Body.String() != `{"ok":true}` || rec.Header().Get("X-Rewritten") != "yes" || rec.Header().Get("Content-Length") != "" {
+			t.Errorf("%s: released %d %v %q", tc.name, rec.Code, rec.Header(), rec.Body.String())
+		}
+	}
+}
+
+func TestIsJSON(t *testing.T) {
+	for _, tc := range []struct {
+		contentType string
+		json, any   bool
+	}{
+		{"application/json", true, true},
+		{"application/json; charset=utf-8", true, true},
+		{"application/problem+json", false, true},
+		{"application/vnd.api+json", false, true},
+		{"text/plain", false, false},
+		{"", false, false},
+	} {
+		if got := IsJSON(tc.contentType); got != tc.json {
+			t.Errorf("IsJSON(%q) = %v", tc.contentType, got)
+		}
+		if got := IsAnyJSON(tc.contentType); got != tc.any {
+			t.Errorf("IsAnyJSON(%q) = %v", tc.contentType, got)
+		}
+	}
+}
@@ -0,0 +1,451 @@
+// Package render writes JSON responses with the deployment's rendering
+// policy, in place of gin's c.JSON:
+//
+//   - nulls: optional fields with no value (nil pointers, slices, and maps)
+//     are rendered as null (keep), left out of their object (omit), or
+//     rendered as their type's empty value (default): "" for strings, 0 for
+//     numbers, false, [], or {}. Other nil values stay null under default.
+//   - timestamps: every time.Time is rendered in UTC as RFC 3339 with the
+//     fraction it has (rfc3339), always with milliseconds (rfc3339_ms), or
+//     in whole seconds (rfc3339_s).
+//   - numbers: floats are always written in plain decimal notation, never
+//     with an exponent, and NaN and infinities become null.
+//
+// Otherwise values are encoded like encoding/json: json tags, omitempty,
+// embedded structs, and Marshaler implementations are honoured, struct
+// fields keep their order, and map keys are sorted.
+package render
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"pygorp/backend/internal/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Null policies.
+const (
+	NullsKeep    = "keep"
+	NullsOmit    = "omit"
+	NullsDefault = "default"
+)
+
+// Timestamp formats.
+const (
+	TimestampsRFC3339      = "rfc3339"
+	TimestampsRFC3339Milli = "rfc3339_ms"
+	TimestampsRFC3339Sec   = "rfc3339_s"
+)
+
+var timestampLayouts = map[string]string{
+	TimestampsRFC3339:      time.RFC3339Nano,
+	TimestampsRFC3339Milli: "2006-01-02T15:04:05.000Z07:00",
+	TimestampsRFC3339Sec:   time.RFC3339,
+}
+
+// JSON writes obj as the response with status, rendered with the current
+// runtime config's policy.
+func JSON(c *gin.Context, status int, obj interface{}) {
+	body, err := Marshal(obj, config.Current().Render)
+	if err != nil {
+		c.Error(err)
+		status, body = http.StatusInternalServerError, []byte(`{"error":"Failed to render response"}`)
+	}
+	c.Data(status, "application/json; charset=utf-8", body)
+}
+
+// AbortJSON stops the handler chain and writes obj like JSON.
+func AbortJSON(c *gin.Context, status int, obj interface{}) {
+	c.Abort()
+	JSON(c, status, obj)
+}
+
+// Marshal encodes v with a rendering policy.
+func Marshal(v interface{}, policy config.Render) ([]byte, error) {
+	e := &encoder{policy: policy, layout: timestampLayouts[policy.Timestamps]}
+	if e.layout == "" {
+		e.layout = time.RFC3339Nano
+	}
+	if err := e.value(reflect.ValueOf(v)); err != nil {
+		return nil, err
+	}
+	return e.buf.Bytes(), nil
+}
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	numberType        = reflect.TypeOf(json.Number(""))
+	marshalerType     = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+type encoder struct {
+	buf    bytes.Buffer
+	policy config.Render
+	layout string
+}
+
+func (e *encoder) value(v reflect.Value) error {
+	if !v.IsValid() {
+		e.buf.WriteString("null")
+		return nil
+	}
+	t := v.Type()
+	if isNil(v) && !marshalsNil(v) {
+		e.null(t)
+		return nil
+	}
+
+	switch {
+	case t == timeType:
+		e.str(v.Interface().(time.Time).UTC().Format(e.layout))
+		return nil
+	case t == numberType:
+		n := v.String()
+		if n == "" {
+			n = "0"
+		}
+		e.buf.WriteString(n)
+		return nil
+	}
+	if m, ok := implements(v, marshalerType); ok {
+		raw, err := m.(json.Marshaler).MarshalJSON()
+		if err != nil {
+			return err
+		}
+		return json.Compact(&e.buf, raw)
+	}
+	if m, ok := implements(v, textMarshalerType); ok && v.Kind() != reflect.String {
+		text, err := m.(encoding.TextMarshaler).MarshalText()
+		if err != nil {
+			return err
+		}
+		e.str(string(text))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		e.buf.WriteString(strconv.FormatBool(v.Bool()))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		e.buf.WriteString(strconv.FormatInt(v.Int(), 10))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		e.buf.WriteString(strconv.FormatUint(v.Uint(), 10))
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			e.buf.WriteString("null")
+			break
+		}
+		e.buf.WriteString(strconv.FormatFloat(f, 'f', -1, t.Bits()))
+	case reflect.String:
+		e.str(v.String())
+	case reflect.Interface, reflect.Pointer:
+		return e.value(v.Elem())
+	case reflect.Struct:
+		return e.structValue(v)
+	case reflect.Map:
+		return e.mapValue(v)
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			// Like encoding/json, bytes are base64.
+			b, _ := json.Marshal(v.Bytes())
+			e.buf.Write(b)
+			break
+		}
+		fallthrough
+	case reflect.Array:
+		e.buf.WriteByte('[')
+		for i := 0; i < v.Len(); i++ {
+			if i > 0 {
+				e.buf.WriteByte(',')
+			}
+			if err := e.value(v.Index(i)); err != nil {
+				return err
+			}
+		}
+		e.buf.WriteByte(']')
+	default:
+		return fmt.Errorf("render: unsupported type %s", t)
+	}
+	return nil
+}
+
+// null writes a nil value of type t according to the null policy.
+func (e *encoder) null(t reflect.Type) {
+	_, custom := implements(reflect.Zero(t), marshalerType)
+	if e.policy.Nulls == NullsDefault && !custom {
+		if t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+		switch t.Kind() {
+		case reflect.Slice:
+			if t.Elem().Kind() == reflect.Uint8 {
+				e.buf.WriteString(`""`)
+				return
+			}
+			e.buf.WriteString("[]")
+			return
+		case reflect.Map:
+			e.buf.WriteString("{}")
+			return
+		case reflect.String:
+			e.buf.WriteString(`""`)
+			return
+		case reflect.Bool:
+			e.buf.WriteString("false")
+			return
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64:
+			e.buf.WriteString("0")
+			return
+		}
+	}
+	e.buf.WriteString("null")
+}
+
+// str writes a JSON string, escaped like encoding/json.
+func (e *encoder) str(s string) {
+	b, _ := json.Marshal(s)
+	e.buf.Write(b)
+}
+
+func (e *encoder) structValue(v reflect.Value) error {
+	e.buf.WriteByte('{')
+	first := true
+	for _, f := range fields(v.Type()) {
+		fv, err := v.FieldByIndexErr(f.index)
+		if err != nil {
+			// Behind a nil embedded pointer.
+			continue
+		}
+		if f.omitEmpty && isEmpty(fv) {
+			continue
+		}
+		if e.policy.Nulls == NullsOmit && isNil(fv) {
+			continue
+		}
+		if !first {
+			e.buf.WriteByte(',')
+		}
+		first = false
+		e.str(f.name)
+		e.buf.WriteByte(':')
+		if f.quoted && !isNil(fv) {
+			var inner encoder
+			inner.policy, inner.layout = e.policy, e.layout
+			if err := inner.value(fv); err != nil {
+				return err
+			}
+			e.str(inner.buf.String())
+			continue
+		}
+		if err := e.value(fv); err != nil {
+			return err
+		}
+	}
+	e.buf.WriteByte('}')
+	return nil
+}
+
+func (e *encoder) mapValue(v reflect.Value) error {
+	type entry struct {
+		key   string
+		value reflect.Value
+	}
+	entries := make([]entry, 0, v.Len())
+	iter := v.MapRange()
+	for iter.Next() {
+		key, err := mapKey(iter.Key())
+		if err != nil {
+			return err
+		}
+		entries = append(entries, entry{key, iter.Value()})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].key < entries[j].key })
+
+	e.buf.WriteByte('{')
+	for i, en := range entries {
+		if i > 0 {
+			e.buf.WriteByte(',')
+		}
+		e.str(en.key)
+		e.buf.WriteByte(':')
+		if err := e.value(en.value); err != nil {
+			return err
+		}
+	}
+	e.buf.WriteByte('}')
+	return nil
+}
+
+func mapKey(k reflect.Value) (string, error) {
+	if k.Kind() == reflect.String {
+		return k.String(), nil
+	}
+	if m, ok := implements(k, textMarshalerType); ok {
+		text, err := m.(encoding.TextMarshaler).MarshalText()
+		return string(text), err
+	}
+	switch k.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(k.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(k.Uint(), 10), nil
+	}
+	return "", fmt.Errorf("render: unsupported map key type %s", k.Type())
+}
+
+// implements returns v, or its address, as an interface value when it
+// implements iface.
+func implements(v reflect.Value, iface reflect.Type) (interface{}, bool) {
+	if v.Type().Implements(iface) {
+		return v.Interface(), true
+	}
+	if v.CanAddr() && reflect.PointerTo(v.Type()).Implements(iface) {
+		return v.Addr().Interface(), true
+	}
+	return nil, false
+}
+
+// marshalsNil reports whether a nil v is still encoded by its own methods,
+// as encoding/json does for nil slices and maps such as net.IP.
+func marshalsNil(v reflect.Value) bool {
+	if k := v.Kind(); k == reflect.Pointer || k == reflect.Interface {
+		return false
+	}
+	return v.Type().Implements(marshalerType) || v.Type().Implements(textMarshalerType)
+}
+
+func isNil(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface, reflect.Map, reflect.Slice:
+		return v.IsNil()
+	}
+	return false
+}
+
+// isEmpty reports the values omitempty leaves out, as encoding/json does.
+func isEmpty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Pointer:
+		return v.IsNil()
+	}
+	return false
+}
+
+// field is an encoded struct field.
+type field struct {
+	name      string
+	index     []int
+	omitEmpty bool
+	quoted    bool
+}
+
+var fieldCache sync.Map // reflect.Type -> []field
+
+// fields returns the encoded fields of a struct type in order. Fields of
+// embedded structs without a name are promoted in place unless a field
+// nearer the top has the same name.
+func fields(t reflect.Type) []field {
+	if cached, ok := fieldCache.Load(t); ok {
+		return cached.([]field)
+	}
+	all := collectFields(t)
+	depth := map[string]int{}
+	for _, f := range all {
+		if d, ok := depth[f.name]; !ok || len(f.index) < d {
+			depth[f.name] = len(f.index)
+		}
+	}
+	var list []field
+	taken := map[string]bool{}
+	for _, f := range all {
+		if len(f.index) == depth[f.name] && !taken[f.name] {
+			taken[f.name] = true
+			list = append(list, f)
+		}
+	}
+	fieldCache.Store(t, list)
+	return list
+}
+
+// collectFields lists a struct's fields with those of embedded structs
+// inlined, before resolving names.
+func collectFields(t reflect.Type) []field {
+	var list []field
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		ft := sf.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if sf.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			for _, f := range collectFields(ft) {
+				f.index = append([]int{i}, f.index...)
+				list = append(list, f)
+			}
+			continue
+		}
+		if !sf.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		list = append(list, field{
+			name:      name,
+			index:     []int{i},
+			omitEmpty: hasOption(opts, "omitempty"),
+			quoted:    hasOption(opts, "string") && isQuotable(ft.Kind()),
+		})
+	}
+	return list
+}
+
+func hasOption(opts, name string) bool {
+	for _, o := range strings.Split(opts, ",") {
+		if o == name {
+			return true
+		}
+	}
+	return false
+}
+
+// isQuotable reports the kinds the ",string" option applies to.
+func isQuotable(k reflect.Kind) bool {
+	switch k {
+	case reflect.Bool, reflect.String, reflect.Float32, reflect.Float64,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return true
+	}
+	return false
+}
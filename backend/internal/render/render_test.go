@@ -0,0 +1,151 @@
+package render
+
+import (
+	"encoding/json"
+	"math"
+	"net"
+	"testing"
+	"time"
+
+	"pygorp/backend/internal/config"
+)
+
+type base struct {
+	ID        int       `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type named struct {
+	Name string `json:"name"`
+}
+
+type tagged struct {
+	Name string `json:"name"`
+}
+
+type parityRecord struct {
+	base
+	*named
+	Title    string            `json:"title"`
+	Note     *string           `json:"note"`
+	Tags     []string          `json:"tags"`
+	Attrs    map[string]string `json:"attrs"`
+	Skipped  string            `json:"-"`
+	Empty    string            `json:"empty,omitempty"`
+	Count    int64             `json:"count,string"`
+	Ratio    float64           `json:"ratio"`
+	Small    float32           `json:"small"`
+	Raw      json.RawMessage   `json:"raw"`
+	Number   json.Number       `json:"number"`
+	Bytes    []byte            `json:"bytes"`
+	IP       net.IP            `json:"ip"`
+	Any      interface{}       `json:"any"`
+	Untagged bool
+	hidden   int
+}
+
+// TestMarshalParity checks that with the keep policy and UTC timestamps,
+// Marshal writes exactly what encoding/json does.
+func TestMarshalParity(t *testing.T) {
+	note := "<b>&</b>"
+	created := time.Date(2026, 1, 2, 3, 4, 5, 600000000, time.UTC)
+	policy := config.Render{Nulls: NullsKeep, Timestamps: TimestampsRFC3339}
+
+	for _, v := range []interface{}{
+		nil,
+		true,
+		-42,
+		uint8(7),
+		3.25,
+		0.000001,
+		1e20,
+		float32(0.1),
+		"quote \" and  ",
+		created,
+		[]int{1, 2, 3},
+		[]int(nil),
+		[2]string{"a", "b"},
+		map[string]int{"b": 2, "a": 1},
+		map[int]bool{10: true, 2: false},
+		map[string]interface{}{"nested": []interface{}{1, "two", nil, map[string]interface{}{}}},
+		parityRecord{},
+		parityRecord{
+			base:     base{ID: 1, CreatedAt: created},
+			named:    &named{Name: "Ada"},
+			Title:    "Engineer",
+			Note:     &note,
+			Tags:     []string{"x"},
+			Attrs:    map[string]string{"k": "v"},
+			Skipped:  "never",
+			Count:    9007199254740993,
+			Ratio:    -12.5,
+			Small:    1.5,
+			Raw:      json.RawMessage(`{ "a" : [1, 2] }`),
+			Number:   "12.50",
+			Bytes:    []byte("hi"),
+			IP:       net.ParseIP("192.0.2.1"),
+			Any:      map[string]interface{}{"deep": &note},
+			Untagged: true,
+			hidden:   1,
+		},
+		&tagged{Name: "pointer"},
+	} {
+		want, err := json.Marshal(v)
+		if err != nil {
+			t.Fatalf("json.Marshal(%#v): %v", v, err)
+		}
+		got, err := Marshal(v, policy)
+		if err != nil {
+			t.Errorf("Marshal(%#v): %v", v, err)
+			continue
+		}
+		if string(got) != string(want) {
+			t.Errorf("Marshal(%#v)\n got %s\nwant %s", v, got, want)
+		}
+	}
+}
+
+// TestMarshalDifferences covers where Marshal deliberately departs from
+// encoding/json.
+func TestMarshalDifferences(t *testing.T) {
+	type left struct{ Name string }
+	type right struct{ Name string }
+	type conflict struct {
+		left
+		right
+	}
+	type optional struct {
+		Name  *string           `json:"name"`
+		Tags  []string          `json:"tags"`
+		Attrs map[string]string `json:"attrs"`
+		Count *int              `json:"count"`
+		At    *time.Time        `json:"at"`
+	}
+	at := time.Date(2026, 1, 2, 3, 4, 5, 600000000, time.FixedZone("CET", 3600))
+
+	for _, tc := range []struct {
+		name   string
+		v      interface{}
+		policy config.Render
+		want   string
+	}{
+		{"keep nulls", optional{}, config.Render{Nulls: NullsKeep}, `{"name":null,"tags":null,"attrs":null,"count":null,"at":null}`},
+		{"omit nulls", optional{}, config.Render{Nulls: NullsOmit}, `{}`},
+		{"default nulls", optional{}, config.Render{Nulls: NullsDefault}, `{"name":"","tags":[],"attrs":{},"count":0,"at":null}`},
+		{"utc timestamps", at, config.Render{Timestamps: TimestampsRFC3339}, `"2026-01-02T02:04:05.6Z"`},
+		{"millisecond timestamps", at, config.Render{Timestamps: TimestampsRFC3339Milli}, `"2026-01-02T02:04:05.600Z"`},
+		{"second timestamps", at, config.Render{Timestamps: TimestampsRFC3339Sec}, `"2026-01-02T02:04:05Z"`},
+		{"no exponents", []float64{1e21, 1e-7}, config.Render{}, `[1000000000000000000000,0.0000001]`},
+		{"non-finite floats", []float64{math.NaN(), math.Inf(1)}, config.Render{}, `[null,null]`},
+		{"duplicate names keep the first", conflict{left{"l"}, right{"r"}}, config.Render{}, `{"Name":"l"}`},
+	} {
+		got, err := Marshal(tc.v, tc.policy)
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if string(got) != tc.want {
+			t.Errorf("%s: got %s, want %s", tc.name, got, tc.want)
+		}
+	}
+}
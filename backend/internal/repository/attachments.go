@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"pygorp/backend/internal/clock"
+	"pygorp/backend/internal/database"
+	"pygorp/backend/internal/models"
+	"pygorp/backend/internal/sqlb"
+
+	"github.com/lib/pq"
+)
+
+const attachmentColumns = "id, resource_type, resource_id, filename, content_type, size, sha256, storage_key, uploaded_by, created_at"
+
+func init() {
+	database.UseColumns("attachments", attachmentColumns)
+	database.UseColumns("storage_deletions", "storage_key, deleted_at")
+}
+
+func scanAttachment(row scanner) (models.Attachment, error) {
+	var a models.Attachment
+	var sha256 sql.NullString
+	var uploadedBy sql.NullInt64
+	err := row.Scan(&a.ID, &a.ResourceType, &a.ResourceID, &a.Filename, &a.ContentType, &a.Size, &sha256, &a.StorageKey, &uploadedBy, &a.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return a, ErrNotFound
+	}
+	a.SHA256, a.UploadedBy = sha256.String, nullableID(uploadedBy)
+	return a, err
+}
+
+// ListAttachments returns a page of the files attached to a resource,
+// newest first.
+func ListAttachments(ctx context.Context, resourceType string, resourceID int, page sqlb.Page) ([]models.Attachment, error) {
+	query, args, err := sqlb.Select(attachmentColumns).From("attachments").
+		Where("resource_type = ?", resourceType).Where("resource_id = ?", resourceID).
+		OrderBy("created_at DESC", "id DESC").Page(page).Build()
+	if err != nil {
+		return nil, err
+	}
+	return queryAll(ctx, scanAttachment, query, args...)
+}
+
+func GetAttachment(ctx context.Context, resourceType string, resourceID, id int) (models.Attachment, error) {
+	return queryOne(ctx, scanAttachment,
+		"SELECT "+attachmentColumns+" FROM attachments WHERE id = $1 AND resource_type = $2 AND resource_id = $3",
+		id, resourceType, resourceID)
+}
+
+// GetAttachmentByID returns an attachment whatever it is attached to, for
+// endpoints that check access to its resource themselves.
+func GetAttachmentByID(ctx context.Context, id int) (models.Attachment, error) {
+	return queryOne(ctx, scanAttachment,
+		"SELECT "+attachmentColumns+" FROM attachments WHERE id = $1", id)
+}
+
+// CreateAttachment records a file already put in storage. The attachment
+// takes over a reference to its blob acquired with AcquireBlob.
+func CreateAttachment(ctx context.Context, a models.Attachment) (models.Attachment, error) {
+	return queryOne(ctx, scanAttachment, `
+		INSERT INTO attachments (resource_type, resource_id, filename, content_type, size, sha256, storage_key, uploaded_by, created_at)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7, $8, $9)
+		RETURNING `+attachmentColumns,
+		a.ResourceType, a.ResourceID, a.Filename, a.ContentType, a.Size, a.SHA256, a.StorageKey, a.UploadedBy, clock.Now())
+}
+
+// DeleteAttachment deletes an attachment's row. A trigger releases its
+// blob, as it does when the resource is deleted.
+func DeleteAttachment(ctx context.Context, resourceType string, resourceID, id int) error {
+	return execAffectingOne(ctx, "DELETE FROM attachments WHERE id = $1 AND resource_type = $2 AND resource_id = $3",
+		id, resourceType, resourceID)
+}
+
+// PendingStorageDeletions returns up to limit keys of objects waiting to be
+// deleted from storage, oldest first.
+func PendingStorageDeletions(ctx context.Context, limit int) ([]string, error) {
+	rows, err := database.DB.QueryContext(ctx,
+		"SELECT storage_key FROM storage_deletions ORDER BY deleted_at LIMIT $1", limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// ClearStorageDeletions forgets keys whose objects have been deleted.
+func ClearStorageDeletions(ctx context.Context, keys []string) error {
+	_, err := database.DB.ExecContext(ctx, "DELETE FROM storage_deletions WHERE storage_key = ANY($1)", pq.Array(keys))
+	return err
+}
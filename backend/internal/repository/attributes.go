@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+
+	"pygorp/backend/internal/attributes"
+	"pygorp/backend/internal/clock"
+	"pygorp/backend/internal/database"
+)
+
+const attributeColumns = "name, type, required, indexed, validation, description, created_at, updated_at"
+
+func init() {
+	database.UseColumns("user_attribute_definitions", attributeColumns)
+}
+
+func scanAttribute(row scanner) (*attributes.Definition, error) {
+	var d attributes.Definition
+	var validation []byte
+	if err := row.Scan(&d.Name, &d.Type, &d.Required, &d.Indexed, &validation, &d.Description, &d.CreatedAt, &d.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(validation, &d.Validation); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// ListAttributeDefinitions returns the custom user attribute definitions
+// ordered by name.
+func ListAttributeDefinitions(ctx context.Context) ([]*attributes.Definition, error) {
+	rows, err := database.DB.QueryContext(ctx, "SELECT "+attributeColumns+" FROM user_attribute_definitions ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	defs := []*attributes.Definition{}
+	for rows.Next() {
+		d, err := scanAttribute(rows)
+		if err != nil {
+			return nil, err
+		}
+		defs = append(defs, d)
+	}
+	return defs, rows.Err()
+}
+
+// AttributeRegistry loads the definitions used to validate user attributes.
+func AttributeRegistry(ctx context.Context) (attributes.Registry, error) {
+	defs, err := ListAttributeDefinitions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return attributes.NewRegistry(defs), nil
+}
+
+// PutAttributeDefinition creates or replaces a definition. Values already
+// stored on users are not revalidated.
+func PutAttributeDefinition(ctx context.Context, d *attributes.Definition) (*attributes.Definition, error) {
+	validation, err := json.Marshal(d.Validation)
+	if err != nil {
+		return nil, err
+	}
+	return scanAttribute(database.DB.QueryRowContext(ctx, `
+		INSERT INTO user_attribute_definitions (name, type, required, indexed, validation, description, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $7)
+		ON CONFLICT (name) DO UPDATE SET
+			type = EXCLUDED.type, required = EXCLUDED.required, indexed = EXCLUDED.indexed,
+			validation = EXCLUDED.validation, description = EXCLUDED.description, updated_at = EXCLUDED.updated_at
+		RETURNING `+attributeColumns,
+		d.Name, d.Type, d.Required, d.Indexed, validation, d.Description, clock.Now()))
+}
+
+// DeleteAttributeDefinition removes a definition and the attribute from
+// every user in one transaction.
+func DeleteAttributeDefinition(ctx context.Context, name string) error {
+	tx, err := database.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, "DELETE FROM user_attribute_definitions WHERE name = $1", name)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE users SET attributes = attributes - $1::text WHERE attributes ? $1::text", name); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,131 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"pygorp/backend/internal/database"
+	"pygorp/backend/internal/models"
+	"pygorp/backend/internal/sqlb"
+)
+
+// The benchmarks time the read paths against the database configured by
+// the DB_* variables, using the users already there, and are skipped when
+// it cannot be reached. They only read, so they are safe to point at a copy
+// of production data:
+//
+//	go test ./internal/repository -run '^$' -bench . -benchmem
+var (
+	benchOnce   sync.Once
+	benchErr    string
+	benchSample []models.User
+)
+
+// benchUsers connects once and returns a page of existing users.
+func benchUsers(b *testing.B) []models.User {
+	benchOnce.Do(func() {
+		if err := database.InitDB(); err != nil {
+			benchErr = err.Error()
+			return
+		}
+		users, err := ListUsers(context.Background(), UserQuery{Page: sqlb.Page{Limit: 50}})
+		if err != nil {
+			benchErr = err.Error()
+			return
+		}
+		if len(users) == 0 {
+			benchErr = "no users to benchmark against; run `pygorp seed` first"
+			return
+		}
+		benchSample = users
+	})
+	if benchErr != "" {
+		b.Skip(benchErr)
+	}
+	b.ReportAllocs()
+	return benchSample
+}
+
+// benchRead runs op b.N times, failing on the first error.
+func benchRead(b *testing.B, op func(ctx context.Context) error) {
+	ctx := context.Background()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := op(ctx); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkListUsers(b *testing.B) {
+	users := benchUsers(b)
+	b.Run("page", func(b *testing.B) {
+		benchRead(b, func(ctx context.Context) error {
+			_, err := ListUsers(ctx, UserQuery{Page: sqlb.Page{Limit: 50}})
+			return err
+		})
+	})
+	initial := "a"
+	if r := []rune(users[0].Name); len(r) > 0 {
+		initial = string(r[:1])
+	}
+	b.Run("name_contains", func(b *testing.B) {
+		benchRead(b, func(ctx context.Context) error {
+			_, err := ListUsers(ctx, UserQuery{Filter: models.UserFilter{NameContains: initial}, Page: sqlb.Page{Limit: 50}})
+			return err
+		})
+	})
+}
+
+func BenchmarkCountUsers(b *testing.B) {
+	benchUsers(b)
+	benchRead(b, func(ctx context.Context) error {
+		_, err := CountUsers(ctx, models.UserFilter{})
+		return err
+	})
+}
+
+func BenchmarkGetUser(b *testing.B) {
+	id := benchUsers(b)[0].ID
+	benchRead(b, func(ctx context.Context) error {
+		_, err := GetUser(ctx, id)
+		return err
+	})
+}
+
+func BenchmarkGetUserByEmail(b *testing.B) {
+	email := benchUsers(b)[0].Email
+	benchRead(b, func(ctx context.Context) error {
+		_, err := GetUserByEmail(ctx, email)
+		return err
+	})
+}
+
+func BenchmarkUsersByIDs(b *testing.B) {
+	users := benchUsers(b)
+	ids := make([]int, len(users))
+	for i, u := range users {
+		ids[i] = u.ID
+	}
+	benchRead(b, func(ctx context.Context) error {
+		_, err := UsersByIDs(ctx, ids)
+		return err
+	})
+}
+
+func BenchmarkSuggestUsers(b *testing.B) {
+	prefix := string([]rune(benchUsers(b)[0].Email)[:2])
+	benchRead(b, func(ctx context.Context) error {
+		_, err := SuggestUsers(ctx, prefix, 10)
+		return err
+	})
+}
+
+func BenchmarkListGroups(b *testing.B) {
+	benchUsers(b)
+	benchRead(b, func(ctx context.Context) error {
+		_, err := ListGroups(ctx, sqlb.Page{Limit: 50})
+		return err
+	})
+}
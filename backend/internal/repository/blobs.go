@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"pygorp/backend/internal/clock"
+	"pygorp/backend/internal/database"
+	"pygorp/backend/internal/models"
+
+	"github.com/lib/pq"
+)
+
+func init() {
+	database.UseColumns("blobs", "sha256, storage_key, size, ref_count, stored, created_at, unreferenced_at")
+}
+
+// AcquireBlob takes a reference to the blob with a hash, adding it if it
+// is new, and returns it. Holding a reference keeps the blob from being
+// collected, so its object can be relied on once Stored is set.
+func AcquireBlob(ctx context.Context, sha256, key string, size int64) (models.Blob, error) {
+	var b models.Blob
+	err := database.DB.QueryRowContext(ctx, `
+		INSERT INTO blobs (sha256, storage_key, size, ref_count) VALUES ($1, $2, $3, 1)
+		ON CONFLICT (sha256) DO UPDATE SET ref_count = blobs.ref_count + 1, unreferenced_at = NULL
+		RETURNING sha256, storage_key, size, ref_count, stored`,
+		sha256, key, size).Scan(&b.SHA256, &b.StorageKey, &b.Size, &b.RefCount, &b.Stored)
+	return b, err
+}
+
+// MarkBlobStored records that a blob's object has been put in storage.
+func MarkBlobStored(ctx context.Context, sha256 string) error {
+	_, err := database.DB.ExecContext(ctx, "UPDATE blobs SET stored = TRUE WHERE sha256 = $1", sha256)
+	return err
+}
+
+// ReleaseBlob drops a reference taken with AcquireBlob that no attachment
+// took over.
+func ReleaseBlob(ctx context.Context, sha256 string) error {
+	_, err := database.DB.ExecContext(ctx, `
+		UPDATE blobs
+		SET ref_count = ref_count - 1,
+			unreferenced_at = CASE WHEN ref_count = 1 THEN $2 ELSE unreferenced_at END
+		WHERE sha256 = $1 AND ref_count > 0`,
+		sha256, clock.Now())
+	return err
+}
+
+// CollectBlobs deletes up to limit blobs that have had no references since
+// before cutoff. deleteObject is called for each one's object while the
+// rows are locked, so an upload of the same content waits and then stores
+// it afresh; blobs whose objects cannot be deleted are kept for the next
+// run. It returns how many were deleted.
+func CollectBlobs(ctx context.Context, cutoff time.Time, limit int, deleteObject func(key string) error) (int, error) {
+	tx, err := database.BeginTx(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT sha256, storage_key FROM blobs
+		WHERE ref_count = 0 AND unreferenced_at < $1
+		ORDER BY unreferenced_at LIMIT $2
+		FOR UPDATE SKIP LOCKED`,
+		cutoff, limit)
+	if err != nil {
+		return 0, err
+	}
+	keys := map[string]string{}
+	for rows.Next() {
+		var sha256, key string
+		if err := rows.Scan(&sha256, &key); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		keys[sha256] = key
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	var deleted []string
+	for sha256, key := range keys {
+		if err := deleteObject(key); err != nil {
+			continue
+		}
+		deleted = append(deleted, sha256)
+	}
+	if len(deleted) == 0 {
+		return 0, nil
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM blobs WHERE sha256 = ANY($1)", pq.Array(deleted)); err != nil {
+		return 0, err
+	}
+	return len(deleted), tx.Commit()
+}
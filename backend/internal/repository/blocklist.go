@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"context"
+
+	"pygorp/backend/internal/blocklist"
+	"pygorp/backend/internal/database"
+)
+
+const blockedWordColumns = "word, match_type, created_at"
+
+func init() {
+	database.UseColumns("blocked_words", blockedWordColumns)
+}
+
+func scanBlockedWord(row scanner) (*blocklist.Entry, error) {
+	e := blocklist.Entry{Source: "database"}
+	if err := row.Scan(&e.Word, &e.Match, &e.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// ListBlockedWords returns the blocked words stored in the database ordered
+// by word.
+func ListBlockedWords(ctx context.Context) (blocklist.List, error) {
+	rows, err := database.DB.QueryContext(ctx, "SELECT "+blockedWordColumns+" FROM blocked_words ORDER BY word")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := blocklist.List{}
+	for rows.Next() {
+		e, err := scanBlockedWord(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, e)
+	}
+	return list, rows.Err()
+}
+
+// Blocklist loads the words checked against user-provided names, from the
+// database and BLOCKLIST_FILE.
+func Blocklist(ctx context.Context) (blocklist.List, error) {
+	stored, err := ListBlockedWords(ctx)
+	if err != nil {
+		return nil, err
+	}
+	fromFile, err := blocklist.File()
+	if err != nil {
+		return nil, err
+	}
+	return append(stored, fromFile...), nil
+}
+
+// PutBlockedWord adds a word or changes how it is matched. Existing names
+// are not rechecked.
+func PutBlockedWord(ctx context.Context, e *blocklist.Entry) (*blocklist.Entry, error) {
+	return scanBlockedWord(database.DB.QueryRowContext(ctx, `
+		INSERT INTO blocked_words (word, match_type)
+		VALUES ($1, $2)
+		ON CONFLICT (word) DO UPDATE SET match_type = EXCLUDED.match_type
+		RETURNING `+blockedWordColumns,
+		e.Word, e.Match))
+}
+
+// DeleteBlockedWord removes a word from the database. Words in
+// BLOCKLIST_FILE cannot be removed this way.
+func DeleteBlockedWord(ctx context.Context, word string) error {
+	return execAffectingOne(ctx, "DELETE FROM blocked_words WHERE word = $1", word)
+}
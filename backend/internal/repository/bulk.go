@@ -0,0 +1,139 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"pygorp/backend/internal/clock"
+	"pygorp/backend/internal/database"
+	"pygorp/backend/internal/events"
+	"pygorp/backend/internal/models"
+	"pygorp/backend/internal/sqlb"
+
+	"github.com/lib/pq"
+)
+
+// Bulk item statuses
+const (
+	BulkDeleted  = "deleted"
+	BulkUpdated  = "updated"
+	BulkRestored = "restored"
+	BulkPurged   = "purged"
+	BulkLocked   = "locked"
+	BulkNotFound = "not_found"
+	BulkFailed   = "failed"
+)
+
+// bulkBatchSize is the number of rows changed per transaction, keeping lock
+// time per batch short.
+const bulkBatchSize = 100
+
+// FindUserIDs returns the IDs of users matching filter, at most limit.
+func FindUserIDs(ctx context.Context, filter models.UserFilter, limit int) ([]int, error) {
+	conds := userFilter(filter)
+	if len(conds) == 0 {
+		return nil, fmt.Errorf("filter must set at least one field")
+	}
+
+	q := sqlb.Select("id").From("users").OrderBy("id").Limit(limit)
+	for _, cond := range conds {
+		q.WhereExpr(cond)
+	}
+	query, args, err := q.Build()
+	if err != nil {
+		return nil, err
+	}
+
+	return queryAll(ctx, scanID, query, args...)
+}
+
+// BulkDeleteUsers deletes users in batched transactions and reports the
+// outcome per ID. Users under legal hold are skipped.
+func BulkDeleteUsers(ctx context.Context, ids []int) []models.BulkItemResult {
+	results := skipHeldUsers(ctx, ids, func(ids []int) []models.BulkItemResult {
+		return runBulk(ctx, ids, BulkDeleted, func(tx *sql.Tx, batch []int) (*sql.Rows, error) {
+			return tx.QueryContext(ctx, "DELETE FROM users WHERE id = ANY($1) AND "+notHeld+" RETURNING id", pq.Array(batch))
+		})
+	})
+	for _, r := range results {
+		if r.Status == BulkDeleted {
+			events.Publish(ctx, events.UserDeleted, 1, events.UserDeletedV1{ID: r.ID})
+		}
+	}
+	return results
+}
+
+// BulkUpdateUsers applies the same patch to every user in ids. Only fields
+// that are safe to share across rows may be set.
+func BulkUpdateUsers(ctx context.Context, ids []int, patch models.UpdateUserRequest) []models.BulkItemResult {
+	now := clock.Now()
+	results := runBulk(ctx, ids, BulkUpdated, func(tx *sql.Tx, batch []int) (*sql.Rows, error) {
+		return tx.QueryContext(ctx,
+			"UPDATE users SET name = COALESCE(NULLIF($1, ''), name), updated_at = $3 WHERE id = ANY($2) RETURNING id",
+			patch.Name, pq.Array(batch), now)
+	})
+	if patch.Name != "" {
+		for _, r := range results {
+			if r.Status == BulkUpdated {
+				events.Publish(ctx, events.UserUpdated, 2, events.UserUpdatedV2{ID: r.ID, Fields: []string{"name"}})
+			}
+		}
+	}
+	return results
+}
+
+// runBulk executes op per batch in its own transaction. op must return the
+// IDs it touched; IDs it did not return are reported as not found. A failed
+// batch is rolled back and reported without stopping later batches.
+func runBulk(ctx context.Context, ids []int, status string, op func(*sql.Tx, []int) (*sql.Rows, error)) []models.BulkItemResult {
+	results := make([]models.BulkItemResult, 0, len(ids))
+	for start := 0; start < len(ids); start += bulkBatchSize {
+		end := start + bulkBatchSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+		batch := ids[start:end]
+
+		touched, err := runBatch(ctx, batch, op)
+		for _, id := range batch {
+			switch {
+			case err != nil:
+				results = append(results, models.BulkItemResult{ID: id, Status: BulkFailed, Error: "batch failed and was rolled back"})
+			case touched[id]:
+				results = append(results, models.BulkItemResult{ID: id, Status: status})
+			default:
+				results = append(results, models.BulkItemResult{ID: id, Status: BulkNotFound})
+			}
+		}
+	}
+	return results
+}
+
+func runBatch(ctx context.Context, batch []int, op func(*sql.Tx, []int) (*sql.Rows, error)) (map[int]bool, error) {
+	tx, err := database.BeginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := op(tx, batch)
+	if err != nil {
+		return nil, err
+	}
+	touched := map[int]bool{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		touched[id] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return touched, tx.Commit()
+}
@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"pygorp/backend/internal/clock"
+	"pygorp/backend/internal/database"
+	"pygorp/backend/internal/models"
+	"pygorp/backend/internal/sqlb"
+
+	"github.com/lib/pq"
+)
+
+// ErrParentNotFound is returned when replying to a comment that is not on
+// the same resource or was deleted.
+var ErrParentNotFound = errors.New("parent comment not found")
+
+const commentColumns = "id, resource_type, resource_id, parent_id, author_id, body, mentions, " +
+	"(SELECT COUNT(*) FROM comments replies WHERE replies.parent_id = comments.id), " +
+	"created_at, updated_at, deleted_at"
+
+func init() {
+	database.UseColumns("comments", "id, resource_type, resource_id, parent_id, author_id, body, mentions, created_at, updated_at, deleted_at")
+}
+
+func scanComment(row scanner) (models.Comment, error) {
+	var cm models.Comment
+	var parentID, authorID sql.NullInt64
+	var mentions pq.Int64Array
+	err := row.Scan(&cm.ID, &cm.ResourceType, &cm.ResourceID, &parentID, &authorID, &cm.Body, &mentions,
+		&cm.ReplyCount, &cm.CreatedAt, &cm.UpdatedAt, &cm.DeletedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return cm, ErrNotFound
+	}
+	cm.ParentID, cm.AuthorID = nullableID(parentID), nullableID(authorID)
+	cm.Mentions = make([]int, len(mentions))
+	for i, id := range mentions {
+		cm.Mentions[i] = int(id)
+	}
+	return cm, err
+}
+
+// ListComments returns a page of the comments on a resource, oldest first.
+// A parentID of 0 lists the top-level comments, and any other the replies
+// to that comment.
+func ListComments(ctx context.Context, resourceType string, resourceID, parentID int, page sqlb.Page) ([]models.Comment, error) {
+	q := sqlb.Select(commentColumns).From("comments").
+		Where("resource_type = ?", resourceType).Where("resource_id = ?", resourceID)
+	if parentID == 0 {
+		q.Where("parent_id IS NULL")
+	} else {
+		q.Where("parent_id = ?", parentID)
+	}
+	query, args, err := q.OrderBy("created_at", "id").Page(page).Build()
+	if err != nil {
+		return nil, err
+	}
+	return queryAll(ctx, scanComment, query, args...)
+}
+
+func GetComment(ctx context.Context, resourceType string, resourceID, id int) (models.Comment, error) {
+	return queryOne(ctx, scanComment,
+		"SELECT "+commentColumns+" FROM comments WHERE id = $1 AND resource_type = $2 AND resource_id = $3",
+		id, resourceType, resourceID)
+}
+
+// CreateComment adds a comment to a resource. A reply's parent must be a
+// comment on the same resource that was not deleted, or ErrParentNotFound
+// is returned.
+func CreateComment(ctx context.Context, resourceType string, resourceID, authorID int, req models.CreateCommentRequest, mentions []int) (models.Comment, error) {
+	cm, err := queryOne(ctx, scanComment, `
+		INSERT INTO comments (resource_type, resource_id, parent_id, author_id, body, mentions, created_at, updated_at)
+		SELECT $1, $2, $3::int, $4, $5, $6, $7, $7
+		WHERE $3::int IS NULL OR EXISTS (
+			SELECT 1 FROM comments WHERE id = $3 AND resource_type = $1 AND resource_id = $2 AND deleted_at IS NULL)
+		RETURNING `+commentColumns,
+		resourceType, resourceID, req.ParentID, authorID, req.Body, pq.Array(mentions), clock.Now())
+	if errors.Is(err, ErrNotFound) {
+		return cm, ErrParentNotFound
+	}
+	return cm, err
+}
+
+// UpdateComment replaces the body and mentions of a comment that was not
+// deleted.
+func UpdateComment(ctx context.Context, resourceType string, resourceID, id int, body string, mentions []int) (models.Comment, error) {
+	return queryOne(ctx, scanComment, `
+		UPDATE comments SET body = $4, mentions = $5, updated_at = $6
+		WHERE id = $1 AND resource_type = $2 AND resource_id = $3 AND deleted_at IS NULL
+		RETURNING `+commentColumns,
+		id, resourceType, resourceID, body, pq.Array(mentions), clock.Now())
+}
+
+// DeleteComment clears a comment's body and marks it deleted, keeping its
+// replies in place.
+func DeleteComment(ctx context.Context, resourceType string, resourceID, id int) error {
+	now := clock.Now()
+	return execAffectingOne(ctx, `
+		UPDATE comments SET body = '', mentions = '{}', deleted_at = $4, updated_at = $4
+		WHERE id = $1 AND resource_type = $2 AND resource_id = $3 AND deleted_at IS NULL`,
+		id, resourceType, resourceID, now)
+}
@@ -0,0 +1,264 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"pygorp/backend/internal/clock"
+	"pygorp/backend/internal/database"
+	"pygorp/backend/internal/models"
+	"pygorp/backend/internal/sqlb"
+
+	"github.com/lib/pq"
+)
+
+// ErrGroupExists is returned when a group name is already taken.
+var ErrGroupExists = errors.New("group name already exists")
+
+const groupColumns = "id, name, description, " +
+	"(SELECT COUNT(*) FROM group_members WHERE group_members.group_id = groups.id), " +
+	"created_at, updated_at"
+
+const (
+	getGroupQuery    = "SELECT " + groupColumns + " FROM groups WHERE id = $1"
+	createGroupQuery = "INSERT INTO groups (name, description, created_at, updated_at) VALUES ($1, $2, $3, $3) RETURNING " + groupColumns
+	updateGroupQuery = "UPDATE groups SET name = COALESCE(NULLIF($1, ''), name), description = COALESCE(NULLIF($2, ''), description), updated_at = $4 WHERE id = $3 RETURNING " + groupColumns
+	deleteGroupQuery = "DELETE FROM groups WHERE id = $1"
+
+	// Unknown user IDs drop out of the SELECT, and existing members are
+	// skipped by the conflict clause.
+	addMembersQuery = "INSERT INTO group_members (group_id, user_id) " +
+		"SELECT $1, id FROM users WHERE id = ANY($2) " +
+		"ON CONFLICT DO NOTHING RETURNING user_id"
+	removeMemberQuery = "DELETE FROM group_members WHERE group_id = $1 AND user_id = $2"
+)
+
+func init() {
+	database.UseColumns("groups", "id, name, description, created_at, updated_at")
+	database.UseColumns("group_members", "group_id, user_id, added_at")
+}
+
+func scanGroup(row scanner) (models.Group, error) {
+	var g models.Group
+	err := row.Scan(&g.ID, &g.Name, &g.Description, &g.MemberCount, &g.CreatedAt, &g.UpdatedAt)
+	return g, err
+}
+
+// groupError maps missing rows and duplicate names to the package errors.
+func groupError(err error) error {
+	var pqErr *pq.Error
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return ErrNotFound
+	case errors.As(err, &pqErr) && pqErr.Code == "23505":
+		return ErrGroupExists
+	}
+	return err
+}
+
+// ListGroups returns a page of groups ordered by name.
+func ListGroups(ctx context.Context, page sqlb.Page) ([]models.Group, error) {
+	query, args, err := sqlb.Select(groupColumns).From("groups").OrderBy("name").Page(page).Build()
+	if err != nil {
+		return nil, err
+	}
+	return queryGroups(ctx, query, args...)
+}
+
+// UserGroups returns a page of the groups a user belongs to, ordered by name.
+func UserGroups(ctx context.Context, userID int, page sqlb.Page) ([]models.Group, error) {
+	query, args, err := sqlb.Select(groupColumns).From("groups").
+		Where("id IN (SELECT group_id FROM group_members WHERE user_id = ?)", userID).
+		OrderBy("name").Page(page).Build()
+	if err != nil {
+		return nil, err
+	}
+	return queryGroups(ctx, query, args...)
+}
+
+func queryGroups(ctx context.Context, query string, args ...interface{}) ([]models.Group, error) {
+	var groups []models.Group
+	err := database.WithStmt(ctx, query, func(stmt *sql.Stmt) error {
+		rows, err := stmt.QueryContext(ctx, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		groups = []models.Group{}
+		for rows.Next() {
+			g, err := scanGroup(rows)
+			if err != nil {
+				return err
+			}
+			groups = append(groups, g)
+		}
+		return rows.Err()
+	})
+	return groups, err
+}
+
+func GetGroup(ctx context.Context, id int) (models.Group, error) {
+	var g models.Group
+	err := database.WithStmt(ctx, getGroupQuery, func(stmt *sql.Stmt) error {
+		var err error
+		g, err = scanGroup(stmt.QueryRowContext(ctx, id))
+		return err
+	})
+	return g, groupError(err)
+}
+
+func CreateGroup(ctx context.Context, req models.CreateGroupRequest) (models.Group, error) {
+	var g models.Group
+	err := database.WithStmt(ctx, createGroupQuery, func(stmt *sql.Stmt) error {
+		var err error
+		g, err = scanGroup(stmt.QueryRowContext(ctx, req.Name, req.Description, clock.Now()))
+		return err
+	})
+	return g, groupError(err)
+}
+
+func UpdateGroup(ctx context.Context, id int, req models.UpdateGroupRequest) (models.Group, error) {
+	var g models.Group
+	err := database.WithStmt(ctx, updateGroupQuery, func(stmt *sql.Stmt) error {
+		var err error
+		g, err = scanGroup(stmt.QueryRowContext(ctx, req.Name, req.Description, id, clock.Now()))
+		return err
+	})
+	return g, groupError(err)
+}
+
+// DeleteGroup removes a group along with its memberships.
+func DeleteGroup(ctx context.Context, id int) error {
+	return execAffectingOne(ctx, deleteGroupQuery, id)
+}
+
+// ListGroupMembers returns a page of a group's members in the order they
+// were added.
+func ListGroupMembers(ctx context.Context, groupID int, page sqlb.Page) ([]models.GroupMember, error) {
+	query, args, err := sqlb.Select(userColumns+", added_at").
+		From("users JOIN group_members ON group_members.user_id = users.id").
+		Where("group_id = ?", groupID).
+		OrderBy("added_at", "id").Page(page).Build()
+	if err != nil {
+		return nil, err
+	}
+
+	var members []models.GroupMember
+	err = database.WithStmt(ctx, query, func(stmt *sql.Stmt) error {
+		rows, err := stmt.QueryContext(ctx, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		members = []models.GroupMember{}
+		for rows.Next() {
+			var m models.GroupMember
+			if m.User, err = scanUser(withAddedAt{rows, &m.AddedAt}); err != nil {
+				return err
+			}
+			members = append(members, m)
+		}
+		return rows.Err()
+	})
+	return members, err
+}
+
+// withAddedAt appends the membership's added_at column to a row scanned by
+// scanUser.
+type withAddedAt struct {
+	row     scanner
+	addedAt *time.Time
+}
+
+func (w withAddedAt) Scan(dest ...interface{}) error {
+	return w.row.Scan(append(dest, w.addedAt)...)
+}
+
+// AddGroupMembers adds users to a group and returns the IDs that were newly
+// added. Existing members and unknown users are skipped.
+func AddGroupMembers(ctx context.Context, groupID int, userIDs []int) ([]int, error) {
+	var added []int
+	err := database.WithStmt(ctx, addMembersQuery, func(stmt *sql.Stmt) error {
+		rows, err := stmt.QueryContext(ctx, groupID, pq.Array(userIDs))
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		added = []int{}
+		for rows.Next() {
+			var id int
+			if err := rows.Scan(&id); err != nil {
+				return err
+			}
+			added = append(added, id)
+		}
+		return rows.Err()
+	})
+	return added, err
+}
+
+func RemoveGroupMember(ctx context.Context, groupID, userID int) error {
+	return execAffectingOne(ctx, removeMemberQuery, groupID, userID)
+}
+
+// execAffectingOne runs a statement and returns ErrNotFound when it touched
+// no rows.
+func execAffectingOne(ctx context.Context, query string, args ...interface{}) error {
+	return database.WithStmt(ctx, query, func(stmt *sql.Stmt) error {
+		result, err := stmt.ExecContext(ctx, args...)
+		if err != nil {
+			return err
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			return ErrNotFound
+		}
+		return nil
+	})
+}
+
+// queryOne runs a query for one row through WithStmt, so it runs in the
+// request transaction when there is one, and scans the row with scan.
+func queryOne[T any](ctx context.Context, scan func(scanner) (T, error), query string, args ...interface{}) (T, error) {
+	var v T
+	err := database.WithStmt(ctx, query, func(stmt *sql.Stmt) error {
+		var err error
+		v, err = scan(stmt.QueryRowContext(ctx, args...))
+		return err
+	})
+	return v, err
+}
+
+// queryAll runs a query through WithStmt and scans each row with scan. It
+// returns an empty slice rather than nil when no rows match.
+func queryAll[T any](ctx context.Context, scan func(scanner) (T, error), query string, args ...interface{}) ([]T, error) {
+	var list []T
+	err := database.WithStmt(ctx, query, func(stmt *sql.Stmt) error {
+		rows, err := stmt.QueryContext(ctx, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		list = []T{}
+		for rows.Next() {
+			v, err := scan(rows)
+			if err != nil {
+				return err
+			}
+			list = append(list, v)
+		}
+		return rows.Err()
+	})
+	return list, err
+}
+
+func scanID(row scanner) (int, error) {
+	var id int
+	err := row.Scan(&id)
+	return id, err
+}
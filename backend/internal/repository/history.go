@@ -0,0 +1,149 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	"pygorp/backend/internal/clock"
+	"pygorp/backend/internal/database"
+	"pygorp/backend/internal/events"
+	"pygorp/backend/internal/models"
+	"pygorp/backend/internal/sqlb"
+
+	"github.com/lib/pq"
+)
+
+// ErrVersionNotFound is returned when restoring a user to a version it does
+// not have.
+var ErrVersionNotFound = errors.New("version not found")
+
+// User history is written by the record_user_history trigger, so every
+// update is recorded, whichever path made it.
+const (
+	userVersionColumns = "version, changes, changed_by, changed_at, restored_from"
+
+	userHistoryQuery = `
+		SELECT ` + userVersionColumns + ` FROM user_history
+		WHERE user_id = $1 AND ($2::text[] IS NULL OR changes ?| $2)
+		ORDER BY version DESC LIMIT $3 OFFSET $4`
+	userVersionQuery = "SELECT " + userVersionColumns + ", state FROM user_history WHERE user_id = $1 AND version = $2"
+
+	// restoreUserQuery puts back the profile fields of a version. Org
+	// membership and the fields SCIM manages are left as they are.
+	restoreUserQuery = `
+		UPDATE users SET email = h.state->>'email', name = h.state->>'name', attributes = h.state->'attributes',
+			avatar = NULLIF(h.state->'avatar', 'null'), updated_at = $3
+		FROM user_history h
+		WHERE users.id = $1 AND h.user_id = users.id AND h.version = $2
+		AND ($4::timestamptz[] IS NULL OR updated_at = ANY($4)) AND ($5::timestamptz IS NULL OR updated_at < $5)
+		RETURNING ` + userColumns
+)
+
+// ListUserHistory returns a page of a user's versions, newest first, without
+// their state. With fields, only versions changing one of them are listed.
+func ListUserHistory(ctx context.Context, id int, fields []string, page sqlb.Page) ([]models.UserVersion, error) {
+	var filter interface{}
+	if len(fields) > 0 {
+		filter = pq.StringArray(fields)
+	}
+	return queryAll(ctx, func(row scanner) (models.UserVersion, error) {
+		return scanUserVersion(row)
+	}, userHistoryQuery, id, filter, page.Limit, page.Offset)
+}
+
+// GetUserVersion returns one version of a user with its state.
+func GetUserVersion(ctx context.Context, id, version int) (models.UserVersion, error) {
+	var state []byte
+	v, err := queryOne(ctx, func(row scanner) (models.UserVersion, error) {
+		return scanUserVersion(row, &state)
+	}, userVersionQuery, id, version)
+	v.State = state
+	return v, err
+}
+
+// RestoreUserVersion puts a user's email, name, attributes, and avatar back
+// as they were in an earlier version, if pre allows its current version.
+// The restore is recorded in the history as a new version naming the one it
+// restored, unless nothing changed.
+func RestoreUserVersion(ctx context.Context, id, version int, pre Precondition) (models.User, error) {
+	var user models.User
+	tx, err := database.BeginTx(ctx)
+	if err != nil {
+		return user, err
+	}
+	defer tx.Rollback()
+
+	// Locking the user keeps other writes from adding versions meanwhile.
+	var latest int
+	var found bool
+	err = tx.QueryRowContext(ctx, `
+		SELECT (SELECT COALESCE(MAX(version), 0) FROM user_history WHERE user_id = users.id),
+			EXISTS (SELECT 1 FROM user_history WHERE user_id = users.id AND version = $2)
+		FROM users WHERE id = $1 FOR UPDATE`, id, version).Scan(&latest, &found)
+	if errors.Is(err, sql.ErrNoRows) {
+		return user, ErrNotFound
+	}
+	if err != nil {
+		return user, err
+	}
+	if !found {
+		return user, ErrVersionNotFound
+	}
+
+	versions, before := pre.args()
+	user, err = scanUser(tx.QueryRowContext(ctx, restoreUserQuery, id, version, clock.Now(), versions, before))
+	var pqErr *pq.Error
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return user, ErrPreconditionFailed
+	case errors.As(err, &pqErr) && pqErr.Code == "23505":
+		return user, ErrUserExists
+	case err != nil:
+		return user, err
+	}
+
+	var changes []byte
+	err = tx.QueryRowContext(ctx,
+		"UPDATE user_history SET restored_from = $2 WHERE user_id = $1 AND version > $3 RETURNING changes",
+		id, version, latest).Scan(&changes)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return user, err
+	}
+	var changed map[string]json.RawMessage
+	if changes != nil {
+		if err := json.Unmarshal(changes, &changed); err != nil {
+			return user, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return user, err
+	}
+
+	var fields []string
+	for _, field := range []string{"email", "name", "attributes", "avatar"} {
+		if _, ok := changed[field]; ok {
+			fields = append(fields, field)
+		}
+	}
+	if len(fields) > 0 {
+		events.Publish(ctx, events.UserUpdated, 2, events.UserUpdatedV2{ID: id, Fields: fields})
+	}
+	return user, nil
+}
+
+func scanUserVersion(row scanner, extra ...interface{}) (models.UserVersion, error) {
+	var v models.UserVersion
+	var changes []byte
+	var changedBy, restoredFrom sql.NullInt64
+	err := row.Scan(append([]interface{}{&v.Version, &changes, &changedBy, &v.ChangedAt, &restoredFrom}, extra...)...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return v, ErrNotFound
+	}
+	if err != nil {
+		return v, err
+	}
+	v.ChangedBy, v.RestoredFrom = nullableID(changedBy), nullableID(restoredFrom)
+	return v, json.Unmarshal(changes, &v.Changes)
+}
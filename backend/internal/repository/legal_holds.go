@@ -0,0 +1,141 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"pygorp/backend/internal/clock"
+	"pygorp/backend/internal/database"
+	"pygorp/backend/internal/models"
+	"pygorp/backend/internal/sqlb"
+
+	"github.com/lib/pq"
+)
+
+// ErrLegalHold is returned when deleting a user under legal hold.
+var ErrLegalHold = errors.New("user is under legal hold")
+
+const (
+	legalHoldColumns = "user_id, reason, created_at"
+
+	// notHeld is a condition keeping users under legal hold out of a
+	// statement on users.
+	notHeld = "NOT EXISTS (SELECT 1 FROM legal_holds WHERE legal_holds.user_id = users.id)"
+
+	underLegalHoldQuery = "SELECT EXISTS (SELECT 1 FROM legal_holds WHERE user_id = $1)"
+)
+
+func scanLegalHold(row scanner) (models.LegalHold, error) {
+	var h models.LegalHold
+	err := row.Scan(&h.UserID, &h.Reason, &h.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return h, ErrNotFound
+	}
+	return h, err
+}
+
+// ListLegalHolds returns a page of legal holds, newest first.
+func ListLegalHolds(ctx context.Context, page sqlb.Page) ([]models.LegalHold, error) {
+	query, args, err := sqlb.Select(legalHoldColumns).From("legal_holds").OrderBy("created_at DESC", "user_id").Page(page).Build()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := database.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	holds := []models.LegalHold{}
+	for rows.Next() {
+		h, err := scanLegalHold(rows)
+		if err != nil {
+			return nil, err
+		}
+		holds = append(holds, h)
+	}
+	return holds, rows.Err()
+}
+
+// SetLegalHold places a user under legal hold, or changes the reason of its
+// hold. It returns the hold and whether it already existed.
+func SetLegalHold(ctx context.Context, userID int, reason string) (models.LegalHold, bool, error) {
+	var h models.LegalHold
+	var existed bool
+	err := database.DB.QueryRowContext(ctx, `
+		INSERT INTO legal_holds (user_id, reason, created_at) VALUES ($1, $2, $3)
+		ON CONFLICT (user_id) DO UPDATE SET reason = EXCLUDED.reason
+		RETURNING `+legalHoldColumns+`, (xmax <> 0)`, userID, reason, clock.Now()).
+		Scan(&h.UserID, &h.Reason, &h.CreatedAt, &existed)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+		return h, false, ErrNotFound
+	}
+	return h, existed, err
+}
+
+// ClearLegalHold lifts a user's legal hold and returns it.
+func ClearLegalHold(ctx context.Context, userID int) (models.LegalHold, error) {
+	return scanLegalHold(database.DB.QueryRowContext(ctx,
+		"DELETE FROM legal_holds WHERE user_id = $1 RETURNING "+legalHoldColumns, userID))
+}
+
+// UnderLegalHold reports whether a user is under legal hold.
+func UnderLegalHold(ctx context.Context, userID int) (bool, error) {
+	var held bool
+	err := database.WithStmt(ctx, underLegalHoldQuery, func(stmt *sql.Stmt) error {
+		return stmt.QueryRowContext(ctx, userID).Scan(&held)
+	})
+	return held, err
+}
+
+// legalHoldError reports deletes refused by the legal_holds foreign key as
+// ErrLegalHold.
+func legalHoldError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23503" && pqErr.Constraint == "legal_holds_user_id_fkey" {
+		return ErrLegalHold
+	}
+	return err
+}
+
+// skipHeldUsers runs a bulk operation on the users of ids not under legal
+// hold and reports the others as BulkLocked, in the order of ids.
+func skipHeldUsers(ctx context.Context, ids []int, op func([]int) []models.BulkItemResult) []models.BulkItemResult {
+	var held pq.Int64Array
+	err := database.DB.QueryRowContext(ctx,
+		"SELECT ARRAY(SELECT user_id FROM legal_holds WHERE user_id = ANY($1))", pq.Array(ids)).Scan(&held)
+	if err != nil {
+		results := make([]models.BulkItemResult, len(ids))
+		for i, id := range ids {
+			results[i] = models.BulkItemResult{ID: id, Status: BulkFailed, Error: "could not check for legal holds"}
+		}
+		return results
+	}
+
+	locked := map[int]bool{}
+	for _, id := range held {
+		locked[int(id)] = true
+	}
+	var rest []int
+	for _, id := range ids {
+		if !locked[id] {
+			rest = append(rest, id)
+		}
+	}
+	done := map[int]models.BulkItemResult{}
+	for _, r := range op(rest) {
+		done[r.ID] = r
+	}
+
+	results := make([]models.BulkItemResult, len(ids))
+	for i, id := range ids {
+		if locked[id] {
+			results[i] = models.BulkItemResult{ID: id, Status: BulkLocked, Error: "user is under legal hold"}
+		} else {
+			results[i] = done[id]
+		}
+	}
+	return results
+}
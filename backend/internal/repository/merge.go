@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"pygorp/backend/internal/database"
+	"pygorp/backend/internal/events"
+	"pygorp/backend/internal/models"
+)
+
+// ErrMergeSelf is returned when merging a user into itself.
+var ErrMergeSelf = errors.New("cannot merge a user into itself")
+
+// MergeResult describes a completed merge. Moved counts the rows
+// re-parented to the target, by kind.
+type MergeResult struct {
+	SourceID int              `json:"source_id"`
+	User     models.User      `json:"user"`
+	Moved    map[string]int64 `json:"moved"`
+}
+
+// mergeMoves re-parent rows from the source user ($2) to the target ($1).
+// Rows the target already has, such as a shared group membership, are left
+// to be deleted with the source.
+var mergeMoves = []struct {
+	kind, query string
+}{
+	{"ai_requests", "UPDATE ai_requests SET user_id = $1 WHERE user_id = $2"},
+	{"group_memberships", `
+		INSERT INTO group_members (group_id, user_id, added_at)
+		SELECT group_id, $1, added_at FROM group_members WHERE user_id = $2
+		ON CONFLICT DO NOTHING`},
+	// Teams only take members of their org, so memberships move only when
+	// the target is in the same org.
+	{"team_memberships", `
+		INSERT INTO team_members (team_id, user_id, added_at)
+		SELECT tm.team_id, $1, tm.added_at FROM team_members tm
+		JOIN teams ON teams.id = tm.team_id
+		JOIN users ON users.id = $1 AND users.org_id = teams.org_id
+		WHERE tm.user_id = $2
+		ON CONFLICT DO NOTHING`},
+	{"projects", "UPDATE projects SET created_by = $1 WHERE created_by = $2"},
+	{"passkeys", "UPDATE webauthn_credentials SET user_id = $1 WHERE user_id = $2"},
+	{"flags", `
+		INSERT INTO user_flags (user_id, flag, reason, created_at)
+		SELECT $1, flag, reason, created_at FROM user_flags WHERE user_id = $2
+		ON CONFLICT DO NOTHING`},
+	{"policies", `
+		UPDATE policies SET subjects = array_replace(subjects, 'user:' || $2::text, 'user:' || $1::text), updated_at = NOW()
+		WHERE 'user:' || $2::text = ANY(subjects)`},
+	{"shares", `
+		UPDATE acl_entries s SET principal = 'user:' || $1::text, updated_at = NOW()
+		WHERE principal = 'user:' || $2::text AND NOT EXISTS (
+			SELECT 1 FROM acl_entries t WHERE t.resource_type = s.resource_type AND t.resource_id = s.resource_id
+			AND t.principal = 'user:' || $1::text)`},
+}
+
+// MergeUsers moves everything that belongs to the source user to the
+// target in one transaction and deletes the source, which ends its
+// sessions. Attributes the target lacks are copied from the source. The
+// merge is recorded in the event log as user.merged, followed by
+// user.deleted so sync clients get a tombstone for the source.
+func MergeUsers(ctx context.Context, sourceID, targetID int) (*MergeResult, error) {
+	if sourceID == targetID {
+		return nil, ErrMergeSelf
+	}
+
+	tx, err := database.BeginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var locked int
+	err = tx.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM (SELECT id FROM users WHERE id IN ($1, $2) ORDER BY id FOR UPDATE) AS locked",
+		sourceID, targetID).Scan(&locked)
+	if err != nil {
+		return nil, err
+	}
+	if locked != 2 {
+		return nil, ErrNotFound
+	}
+
+	result, err := tx.ExecContext(ctx, `
+		UPDATE users t SET attributes = s.attributes || t.attributes
+		FROM users s
+		WHERE t.id = $1 AND s.id = $2 AND s.attributes || t.attributes <> t.attributes`,
+		targetID, sourceID)
+	if err != nil {
+		return nil, err
+	}
+	attributesChanged, _ := result.RowsAffected()
+
+	moved := map[string]int64{}
+	for _, m := range mergeMoves {
+		result, err := tx.ExecContext(ctx, m.query, targetID, sourceID)
+		if err != nil {
+			return nil, err
+		}
+		moved[m.kind], _ = result.RowsAffected()
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM users WHERE id = $1", sourceID); err != nil {
+		return nil, legalHoldError(err)
+	}
+	user, err := scanUser(tx.QueryRowContext(ctx, getUserQuery, targetID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	events.Publish(ctx, events.UserMerged, 1, events.UserMergedV1{ID: sourceID, Into: targetID, Moved: moved})
+	events.Publish(ctx, events.UserDeleted, 1, events.UserDeletedV1{ID: sourceID})
+	if attributesChanged > 0 {
+		events.Publish(ctx, events.UserUpdated, 2, events.UserUpdatedV2{ID: targetID, Fields: []string{"attributes"}})
+	}
+	return &MergeResult{SourceID: sourceID, User: user, Moved: moved}, nil
+}
@@ -0,0 +1,354 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+
+	"pygorp/backend/internal/clock"
+	"pygorp/backend/internal/database"
+	"pygorp/backend/internal/idgen"
+	"pygorp/backend/internal/models"
+
+	"github.com/lib/pq"
+)
+
+var (
+	// ErrAlreadyInvited is returned when an email already has an open
+	// invitation to the org that has not expired.
+	ErrAlreadyInvited = errors.New("email already has an open invitation")
+	// ErrOtherOrg is returned when a user who belongs to one org accepts an
+	// invitation to another.
+	ErrOtherOrg = errors.New("user belongs to another org")
+	// ErrLastOwner is returned when a change would leave an org that has an
+	// owner without one.
+	ErrLastOwner = errors.New("an org must keep at least one owner")
+	// ErrNotOwner is returned when someone other than an owner transfers
+	// ownership.
+	ErrNotOwner = errors.New("only an owner can transfer ownership")
+)
+
+const invitationColumns = "id, org_id, email, role, invited_by, expires_at, sent_at, created_at"
+
+// openInvitation matches invitations that were neither accepted nor revoked.
+const openInvitation = "accepted_at IS NULL AND revoked_at IS NULL"
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+func init() {
+	database.UseColumns("users", "org_id, org_role")
+	database.UseColumns("org_invitations", invitationColumns+", accepted_at, accepted_by, revoked_at")
+}
+
+func scanInvitation(row scanner) (models.OrgInvitation, error) {
+	var inv models.OrgInvitation
+	var invitedBy sql.NullInt64
+	err := row.Scan(&inv.ID, &inv.OrgID, &inv.Email, &inv.Role, &invitedBy, &inv.ExpiresAt, &inv.SentAt, &inv.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return inv, ErrNotFound
+	}
+	if invitedBy.Valid {
+		id := int(invitedBy.Int64)
+		inv.InvitedBy = &id
+	}
+	return inv, err
+}
+
+// OrgName returns the name of an org.
+func OrgName(ctx context.Context, orgID int) (string, error) {
+	var name string
+	err := database.WithStmt(ctx, "SELECT name FROM orgs WHERE id = $1", func(stmt *sql.Stmt) error {
+		return stmt.QueryRowContext(ctx, orgID).Scan(&name)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrNotFound
+	}
+	return name, err
+}
+
+// OrgRole returns a user's role in an org, or ErrNotFound when the user
+// is not in it.
+func OrgRole(ctx context.Context, orgID, userID int) (string, error) {
+	var role sql.NullString
+	err := database.WithStmt(ctx, "SELECT org_role FROM users WHERE id = $1 AND org_id = $2", func(stmt *sql.Stmt) error {
+		return stmt.QueryRowContext(ctx, userID, orgID).Scan(&role)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	if !role.Valid {
+		return models.OrgRoleMember, nil
+	}
+	return role.String, nil
+}
+
+// CreateOrgInvitation stores a new invitation, expiring at expiresAt. An
+// expired open invitation for the same email is revoked and replaced.
+func CreateOrgInvitation(ctx context.Context, orgID int, email, role string, invitedBy int, expiresAt time.Time) (models.OrgInvitation, error) {
+	tx, err := database.BeginTx(ctx)
+	if err != nil {
+		return models.OrgInvitation{}, err
+	}
+	defer tx.Rollback()
+
+	now := clock.Now()
+	_, err = tx.ExecContext(ctx, `
+		UPDATE org_invitations SET revoked_at = $3
+		WHERE org_id = $1 AND LOWER(email) = LOWER($2) AND `+openInvitation+` AND expires_at <= $3`,
+		orgID, email, now)
+	if err != nil {
+		return models.OrgInvitation{}, err
+	}
+	inv, err := scanInvitation(tx.QueryRowContext(ctx, `
+		INSERT INTO org_invitations (id, org_id, email, role, invited_by, expires_at, sent_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $7) RETURNING `+invitationColumns,
+		idgen.NewID(), orgID, email, role, invitedBy, expiresAt, now))
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return inv, ErrAlreadyInvited
+	}
+	if err != nil {
+		return inv, err
+	}
+	return inv, tx.Commit()
+}
+
+// ListOrgInvitations returns an org's open invitations, newest first,
+// including expired ones that can still be resent.
+func ListOrgInvitations(ctx context.Context, orgID int) ([]models.OrgInvitation, error) {
+	return queryAll(ctx, scanInvitation,
+		"SELECT "+invitationColumns+" FROM org_invitations WHERE org_id = $1 AND "+openInvitation+" ORDER BY created_at DESC", orgID)
+}
+
+// GetOrgInvitation returns an open invitation, expired or not.
+func GetOrgInvitation(ctx context.Context, id string) (models.OrgInvitation, error) {
+	if !uuidPattern.MatchString(id) {
+		return models.OrgInvitation{}, ErrNotFound
+	}
+	return queryOne(ctx, scanInvitation,
+		"SELECT "+invitationColumns+" FROM org_invitations WHERE id = $1 AND "+openInvitation, id)
+}
+
+// RenewOrgInvitation records that an open invitation of the org was sent
+// again and moves its expiry to expiresAt.
+func RenewOrgInvitation(ctx context.Context, orgID int, id string, expiresAt time.Time) (models.OrgInvitation, error) {
+	if !uuidPattern.MatchString(id) {
+		return models.OrgInvitation{}, ErrNotFound
+	}
+	return queryOne(ctx, scanInvitation, `
+		UPDATE org_invitations SET expires_at = $3, sent_at = $4
+		WHERE id = $1 AND org_id = $2 AND `+openInvitation+` RETURNING `+invitationColumns,
+		id, orgID, expiresAt, clock.Now())
+}
+
+// RevokeOrgInvitation stops an open invitation of the org from being
+// accepted.
+func RevokeOrgInvitation(ctx context.Context, orgID int, id string) error {
+	if !uuidPattern.MatchString(id) {
+		return ErrNotFound
+	}
+	return execAffectingOne(ctx,
+		"UPDATE org_invitations SET revoked_at = $3 WHERE id = $1 AND org_id = $2 AND "+openInvitation,
+		id, orgID, clock.Now())
+}
+
+// AcceptOrgInvitation uses up an open, unexpired invitation and puts the
+// user in its org with its role, in one transaction, reporting whether the
+// user joined. A user already in the org keeps their role; one in another
+// org gets ErrOtherOrg.
+func AcceptOrgInvitation(ctx context.Context, id string, userID int) (inv models.OrgInvitation, joined bool, err error) {
+	if !uuidPattern.MatchString(id) {
+		return inv, false, ErrNotFound
+	}
+	tx, err := database.BeginTx(ctx)
+	if err != nil {
+		return inv, false, err
+	}
+	defer tx.Rollback()
+
+	now := clock.Now()
+	inv, err = scanInvitation(tx.QueryRowContext(ctx, `
+		UPDATE org_invitations SET accepted_at = $2, accepted_by = $3
+		WHERE id = $1 AND `+openInvitation+` AND expires_at > $2 RETURNING `+invitationColumns,
+		id, now, userID))
+	if err != nil {
+		return inv, false, err
+	}
+
+	var orgID sql.NullInt64
+	var email string
+	err = tx.QueryRowContext(ctx, "SELECT org_id, email FROM users WHERE id = $1 FOR UPDATE", userID).Scan(&orgID, &email)
+	if errors.Is(err, sql.ErrNoRows) {
+		return inv, false, ErrNotFound
+	}
+	if err != nil {
+		return inv, false, err
+	}
+	if !strings.EqualFold(email, inv.Email) {
+		return inv, false, ErrNotFound
+	}
+	switch {
+	case !orgID.Valid:
+		_, err = tx.ExecContext(ctx, "UPDATE users SET org_id = $2, org_role = $3, updated_at = $4 WHERE id = $1",
+			userID, inv.OrgID, inv.Role, now)
+		if err != nil {
+			return inv, false, err
+		}
+		joined = true
+	case int(orgID.Int64) != inv.OrgID:
+		return inv, false, ErrOtherOrg
+	}
+	return inv, joined, tx.Commit()
+}
+
+// ListOrgMembers returns the users in an org, owners first, then by name.
+// A non-zero teamID keeps only the members of that team.
+func ListOrgMembers(ctx context.Context, orgID, teamID int) ([]models.OrgMember, error) {
+	return queryAll(ctx, func(row scanner) (models.OrgMember, error) {
+		var m models.OrgMember
+		err := row.Scan(&m.ID, &m.Email, &m.Name, &m.Role)
+		return m, err
+	}, `
+		SELECT id, email, name, COALESCE(org_role, $2) FROM users WHERE org_id = $1
+		AND ($5 = 0 OR id IN (SELECT user_id FROM team_members WHERE team_id = $5))
+		ORDER BY org_role = $3 DESC NULLS LAST, org_role = $4 DESC NULLS LAST, name, id`,
+		orgID, models.OrgRoleMember, models.OrgRoleOwner, models.OrgRoleAdmin, teamID)
+}
+
+// SetOrgRole changes a member's role and returns the previous one. The last
+// owner cannot be demoted.
+func SetOrgRole(ctx context.Context, orgID, userID int, role string) (string, error) {
+	return changeMember(ctx, orgID, userID, func(tx *sql.Tx, now time.Time) error {
+		_, err := tx.ExecContext(ctx, "UPDATE users SET org_role = $2, updated_at = $3 WHERE id = $1", userID, role, now)
+		return err
+	}, role != models.OrgRoleOwner)
+}
+
+// RemoveOrgMember takes a user out of an org and its teams, drops what the
+// org's projects were shared with them, and returns the role they had. The
+// last owner cannot be removed.
+func RemoveOrgMember(ctx context.Context, orgID, userID int) (string, error) {
+	return changeMember(ctx, orgID, userID, func(tx *sql.Tx, now time.Time) error {
+		_, err := tx.ExecContext(ctx, "UPDATE users SET org_id = NULL, org_role = NULL, updated_at = $2 WHERE id = $1", userID, now)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx,
+			"DELETE FROM team_members WHERE user_id = $1 AND team_id IN (SELECT id FROM teams WHERE org_id = $2)", userID, orgID)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `
+			DELETE FROM acl_entries WHERE principal = 'user:' || $1::text
+			AND resource_type = $3 AND resource_id IN (SELECT id FROM projects WHERE org_id = $2)`, userID, orgID, models.ProjectResource)
+		return err
+	}, true)
+}
+
+// changeMember locks a member's row and applies change in a transaction,
+// refusing with ErrLastOwner when the member is the org's only owner and
+// demotes is set. It returns the member's role before the change.
+func changeMember(ctx context.Context, orgID, userID int, change func(*sql.Tx, time.Time) error, demotes bool) (string, error) {
+	tx, err := database.BeginTx(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	// Lock the member and every owner, in ID order, so two owners cannot
+	// demote each other at once.
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id, org_role FROM users WHERE org_id = $1 AND (id = $2 OR org_role = $3) ORDER BY id FOR UPDATE`,
+		orgID, userID, models.OrgRoleOwner)
+	if err != nil {
+		return "", err
+	}
+	var (
+		previous string
+		owners   int
+	)
+	for rows.Next() {
+		var id int
+		var role sql.NullString
+		if err := rows.Scan(&id, &role); err != nil {
+			rows.Close()
+			return "", err
+		}
+		if role.String == models.OrgRoleOwner {
+			owners++
+		}
+		if id == userID {
+			previous = models.OrgRoleMember
+			if role.Valid {
+				previous = role.String
+			}
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+	if previous == "" {
+		return "", ErrNotFound
+	}
+	if demotes && previous == models.OrgRoleOwner && owners <= 1 {
+		return previous, ErrLastOwner
+	}
+
+	if err := change(tx, clock.Now()); err != nil {
+		return previous, err
+	}
+	return previous, tx.Commit()
+}
+
+// TransferOrgOwnership makes a member the owner of an org, in place of the
+// owner handing it over, who becomes an admin.
+func TransferOrgOwnership(ctx context.Context, orgID, fromID, toID int) error {
+	tx, err := database.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	roles := map[int]sql.NullString{}
+	rows, err := tx.QueryContext(ctx,
+		"SELECT id, org_role FROM users WHERE id IN ($2, $3) AND org_id = $1 ORDER BY id FOR UPDATE", orgID, fromID, toID)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var id int
+		var role sql.NullString
+		if err := rows.Scan(&id, &role); err != nil {
+			rows.Close()
+			return err
+		}
+		roles[id] = role
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if roles[fromID].String != models.OrgRoleOwner {
+		return ErrNotOwner
+	}
+	if _, ok := roles[toID]; !ok {
+		return ErrNotFound
+	}
+	now := clock.Now()
+	_, err = tx.ExecContext(ctx, `
+		UPDATE users SET org_role = CASE WHEN id = $1 THEN $3 ELSE $4 END, updated_at = $5
+		WHERE id IN ($1, $2)`,
+		toID, fromID, models.OrgRoleOwner, models.OrgRoleAdmin, now)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,131 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"pygorp/backend/internal/auth"
+	"pygorp/backend/internal/clock"
+	"pygorp/backend/internal/database"
+	"pygorp/backend/internal/models"
+
+	"github.com/lib/pq"
+)
+
+const personalTokenColumns = "id, user_id, name, prefix, scopes, expires_at, last_used_at, revoked_at, created_at"
+
+const (
+	listPersonalTokensQuery  = "SELECT " + personalTokenColumns + " FROM personal_access_tokens WHERE user_id = $1 ORDER BY id"
+	createPersonalTokenQuery = "INSERT INTO personal_access_tokens (user_id, name, prefix, token_hash, scopes, expires_at, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING " + personalTokenColumns
+	revokePersonalTokenQuery = "UPDATE personal_access_tokens SET revoked_at = $3 WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL"
+
+	// Tokens of users pending deletion, and revoked or expired tokens, do
+	// not match.
+	authenticatePersonalTokenQuery = "SELECT t.token_hash, t.id, t.user_id, t.name, t.prefix, t.scopes, t.expires_at, t.last_used_at, t.revoked_at, t.created_at " +
+		"FROM personal_access_tokens t JOIN users u ON u.id = t.user_id " +
+		"WHERE t.prefix = $1 AND t.revoked_at IS NULL AND t.expires_at > $2 AND u.purge_at IS NULL"
+	// last_used_at is only written once a minute per token.
+	touchPersonalTokenQuery = "UPDATE personal_access_tokens SET last_used_at = $2 WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < $2::timestamptz - INTERVAL '1 minute')"
+)
+
+func init() {
+	database.UseColumns("personal_access_tokens", personalTokenColumns+", token_hash")
+}
+
+func scanPersonalToken(row scanner) (models.PersonalAccessToken, error) {
+	var t models.PersonalAccessToken
+	err := row.Scan(&t.ID, &t.UserID, &t.Name, &t.Prefix, pq.Array(&t.Scopes), &t.ExpiresAt, &t.LastUsedAt, &t.RevokedAt, &t.CreatedAt)
+	return t, err
+}
+
+// ListPersonalTokens returns every token of a user, including revoked and
+// expired ones, oldest first.
+func ListPersonalTokens(ctx context.Context, userID int) ([]models.PersonalAccessToken, error) {
+	var tokens []models.PersonalAccessToken
+	err := database.WithStmt(ctx, listPersonalTokensQuery, func(stmt *sql.Stmt) error {
+		rows, err := stmt.QueryContext(ctx, userID)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		tokens = []models.PersonalAccessToken{}
+		for rows.Next() {
+			t, err := scanPersonalToken(rows)
+			if err != nil {
+				return err
+			}
+			tokens = append(tokens, t)
+		}
+		return rows.Err()
+	})
+	return tokens, err
+}
+
+// CreatePersonalToken generates a token for a user that expires at
+// expiresAt. The returned secret cannot be retrieved again.
+func CreatePersonalToken(ctx context.Context, userID int, name string, scopes []string, expiresAt time.Time) (models.CreatedPersonalAccessToken, error) {
+	token, prefix, hash := auth.NewPersonalToken()
+	created := models.CreatedPersonalAccessToken{Token: token}
+	err := database.WithStmt(ctx, createPersonalTokenQuery, func(stmt *sql.Stmt) error {
+		var err error
+		created.PersonalAccessToken, err = scanPersonalToken(stmt.QueryRowContext(ctx, userID, name, prefix, hash, pq.Array(scopes), expiresAt, clock.Now()))
+		return err
+	})
+	return created, err
+}
+
+func RevokePersonalToken(ctx context.Context, userID, tokenID int) error {
+	return execAffectingOne(ctx, revokePersonalTokenQuery, tokenID, userID, clock.Now())
+}
+
+// AuthenticatePersonalToken returns the token a presented secret belongs
+// to, or ErrNotFound when it is unknown, revoked, or expired, or its user
+// is pending deletion.
+func AuthenticatePersonalToken(ctx context.Context, token string) (models.PersonalAccessToken, error) {
+	prefix, hash, ok := auth.ParsePersonalToken(token)
+	if !ok {
+		return models.PersonalAccessToken{}, ErrNotFound
+	}
+
+	var (
+		t          models.PersonalAccessToken
+		storedHash string
+	)
+	now := clock.Now()
+	err := database.WithStmt(ctx, authenticatePersonalTokenQuery, func(stmt *sql.Stmt) error {
+		var err error
+		t, err = scanPersonalToken(withHash{stmt.QueryRowContext(ctx, prefix, now), &storedHash})
+		return err
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.PersonalAccessToken{}, ErrNotFound
+	}
+	if err != nil {
+		return models.PersonalAccessToken{}, err
+	}
+	if !auth.HashesMatch(hash, storedHash) {
+		return models.PersonalAccessToken{}, ErrNotFound
+	}
+
+	if err := database.WithStmt(ctx, touchPersonalTokenQuery, func(stmt *sql.Stmt) error {
+		_, err := stmt.ExecContext(ctx, t.ID, now)
+		return err
+	}); err != nil {
+		return models.PersonalAccessToken{}, err
+	}
+	return t, nil
+}
+
+// withHash prepends a token_hash column to a row scanned by
+// scanPersonalToken.
+type withHash struct {
+	row  scanner
+	hash *string
+}
+
+func (w withHash) Scan(dest ...interface{}) error {
+	return w.row.Scan(append([]interface{}{w.hash}, dest...)...)
+}
@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// ErrPreconditionFailed is returned when a conditional write finds the row
+// changed since the client read it.
+var ErrPreconditionFailed = errors.New("precondition failed")
+
+// Precondition limits a write to versions of a row the client has seen. A
+// row's version is its updated_at, which every write changes. Versions lists
+// the versions the row may have (If-Match), and UnmodifiedSince rejects rows
+// updated after it, to the second (If-Unmodified-Since). The zero
+// Precondition allows any version.
+type Precondition struct {
+	Versions        []time.Time
+	UnmodifiedSince time.Time
+}
+
+// IsZero reports whether p allows any version.
+func (p Precondition) IsZero() bool {
+	return p.Versions == nil && p.UnmodifiedSince.IsZero()
+}
+
+// args returns the query arguments for
+//
+//	($n::timestamptz[] IS NULL OR updated_at = ANY($n)) AND ($m::timestamptz IS NULL OR updated_at < $m)
+//
+// with NULL for the conditions p does not set.
+func (p Precondition) args() (versions, before interface{}) {
+	if p.Versions != nil {
+		a := make(pq.StringArray, len(p.Versions))
+		for i, v := range p.Versions {
+			a[i] = v.UTC().Format(time.RFC3339Nano)
+		}
+		versions = a
+	}
+	if !p.UnmodifiedSince.IsZero() {
+		before = p.UnmodifiedSince.Truncate(time.Second).Add(time.Second)
+	}
+	return versions, before
+}
@@ -0,0 +1,160 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+	"pygorp/backend/internal/clock"
+	"pygorp/backend/internal/database"
+	"pygorp/backend/internal/models"
+	"pygorp/backend/internal/sqlb"
+)
+
+// ErrTeamNotFound is returned when a project is put in a team that is not
+// in its org.
+var ErrTeamNotFound = errors.New("team not found")
+
+const projectColumns = "id, org_id, team_id, name, description, created_by, created_at, updated_at"
+
+func init() {
+	database.UseColumns("projects", projectColumns)
+}
+
+// ProjectQuery selects an org's projects for a member.
+type ProjectQuery struct {
+	// ViewerID is the member listing; unless AllTeams is set, only projects
+	// without a team, in one of the viewer's teams, or in SharedIDs are
+	// returned.
+	ViewerID  int
+	AllTeams  bool
+	SharedIDs []int
+	// TeamID, when non-zero, keeps only the team's projects.
+	TeamID int
+	Page   sqlb.Page
+}
+
+func scanProject(row scanner) (models.Project, error) {
+	var p models.Project
+	var teamID, createdBy sql.NullInt64
+	err := row.Scan(&p.ID, &p.OrgID, &teamID, &p.Name, &p.Description, &createdBy, &p.CreatedAt, &p.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return p, ErrNotFound
+	}
+	p.TeamID, p.CreatedBy = nullableID(teamID), nullableID(createdBy)
+	return p, err
+}
+
+func nullableID(n sql.NullInt64) *int {
+	if !n.Valid {
+		return nil
+	}
+	id := int(n.Int64)
+	return &id
+}
+
+// projectTeamError reports a team_id outside the org, which leaves the
+// INSERT ... SELECT or UPDATE without rows, as ErrTeamNotFound.
+func projectTeamError(err error, teamID *int) error {
+	if errors.Is(err, ErrNotFound) && teamID != nil && *teamID != 0 {
+		return ErrTeamNotFound
+	}
+	return err
+}
+
+// ListProjects returns a page of an org's projects, newest first.
+func ListProjects(ctx context.Context, orgID int, opts ProjectQuery) ([]models.Project, error) {
+	q := sqlb.Select(projectColumns).From("projects").Where("org_id = ?", orgID).Where("deleted_at IS NULL")
+	if opts.TeamID != 0 {
+		q.Where("team_id = ?", opts.TeamID)
+	}
+	if !opts.AllTeams {
+		q.Where("(team_id IS NULL OR team_id IN (SELECT team_id FROM team_members WHERE user_id = ?) OR id = ANY(?))",
+			opts.ViewerID, pq.Array(opts.SharedIDs))
+	}
+	query, args, err := q.OrderBy("created_at DESC", "id DESC").Page(opts.Page).Build()
+	if err != nil {
+		return nil, err
+	}
+	return queryAll(ctx, scanProject, query, args...)
+}
+
+func GetProject(ctx context.Context, orgID, id int) (models.Project, error) {
+	return queryOne(ctx, scanProject,
+		"SELECT "+projectColumns+" FROM projects WHERE id = $1 AND org_id = $2 AND deleted_at IS NULL", id, orgID)
+}
+
+// ProjectOrg returns the ID of the org a project belongs to. Projects in the
+// trash are not found.
+func ProjectOrg(ctx context.Context, id int) (int, error) {
+	var orgID int
+	err := database.WithStmt(ctx, "SELECT org_id FROM projects WHERE id = $1 AND deleted_at IS NULL", func(stmt *sql.Stmt) error {
+		return stmt.QueryRowContext(ctx, id).Scan(&orgID)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrNotFound
+	}
+	return orgID, err
+}
+
+// CreateProject adds a project to an org. ErrTeamNotFound is returned when
+// the team is not the org's.
+func CreateProject(ctx context.Context, orgID, createdBy int, req models.CreateProjectRequest) (models.Project, error) {
+	p, err := queryOne(ctx, scanProject, `
+		INSERT INTO projects (org_id, team_id, name, description, created_by, created_at, updated_at)
+		SELECT $1, $2::int, $3, $4, $5, $6, $6
+		WHERE $2::int IS NULL OR EXISTS (SELECT 1 FROM teams WHERE id = $2 AND org_id = $1)
+		RETURNING `+projectColumns,
+		orgID, req.TeamID, req.Name, req.Description, createdBy, clock.Now())
+	return p, projectTeamError(err, req.TeamID)
+}
+
+// UpdateProject changes a project. A TeamID of 0 takes it out of its team,
+// and nil leaves the team unchanged.
+func UpdateProject(ctx context.Context, orgID, id int, req models.UpdateProjectRequest) (models.Project, error) {
+	p, err := queryOne(ctx, scanProject, `
+		UPDATE projects SET name = COALESCE(NULLIF($3, ''), name), description = COALESCE(NULLIF($4, ''), description),
+			team_id = CASE WHEN $5::int IS NULL THEN team_id ELSE NULLIF($5, 0) END, updated_at = $6
+		WHERE id = $1 AND org_id = $2 AND deleted_at IS NULL
+		AND ($5::int IS NULL OR $5 = 0 OR EXISTS (SELECT 1 FROM teams WHERE id = $5 AND org_id = $2))
+		RETURNING `+projectColumns,
+		id, orgID, req.Name, req.Description, req.TeamID, clock.Now())
+	if errors.Is(err, ErrNotFound) && req.TeamID != nil && *req.TeamID != 0 {
+		// Tell a missing project apart from a team outside the org.
+		if _, err := GetProject(ctx, orgID, id); err != nil {
+			return p, err
+		}
+	}
+	return p, projectTeamError(err, req.TeamID)
+}
+
+// DeleteProject moves a project to the trash, where it is kept for grace
+// and can be restored until it is purged. by is the user deleting it.
+func DeleteProject(ctx context.Context, orgID, id, by int, grace time.Duration) error {
+	now := clock.Now()
+	return execAffectingOne(ctx,
+		"UPDATE projects SET deleted_at = $3, deleted_by = $4, purge_at = $5 WHERE id = $1 AND org_id = $2 AND deleted_at IS NULL",
+		id, orgID, now, nullableUserID(by), now.Add(grace))
+}
+
+// PurgeDeletedProjects deletes the projects whose time in the trash has
+// ended and returns their IDs.
+func PurgeDeletedProjects(ctx context.Context) ([]int, error) {
+	rows, err := database.DB.QueryContext(ctx, "DELETE FROM projects WHERE purge_at <= $1 RETURNING id", clock.Now())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
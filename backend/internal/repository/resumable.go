@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"pygorp/backend/internal/clock"
+	"pygorp/backend/internal/database"
+	"pygorp/backend/internal/models"
+
+	"github.com/lib/pq"
+)
+
+const resumableUploadColumns = "id, target, resource_type, resource_id, filename, metadata, upload_length, upload_offset, " +
+	"chunk_keys, created_by, created_at, updated_at, expires_at, completed_at, result"
+
+func init() {
+	database.UseColumns("resumable_uploads", resumableUploadColumns)
+}
+
+func scanResumableUpload(row scanner) (models.ResumableUpload, error) {
+	var u models.ResumableUpload
+	var resourceType sql.NullString
+	var resourceID sql.NullInt64
+	var completedAt sql.NullTime
+	err := row.Scan(&u.ID, &u.Target, &resourceType, &resourceID, &u.Filename, &u.Metadata, &u.Length, &u.Offset,
+		(*pq.StringArray)(&u.ChunkKeys), &u.CreatedBy, &u.CreatedAt, &u.UpdatedAt, &u.ExpiresAt, &completedAt, &u.Result)
+	if errors.Is(err, sql.ErrNoRows) {
+		return u, ErrNotFound
+	}
+	u.ResourceType, u.ResourceID = resourceType.String, int(resourceID.Int64)
+	if completedAt.Valid {
+		u.CompletedAt = &completedAt.Time
+	}
+	return u, err
+}
+
+func CreateResumableUpload(ctx context.Context, u models.ResumableUpload) (models.ResumableUpload, error) {
+	var resourceType sql.NullString
+	var resourceID sql.NullInt64
+	if u.ResourceType != "" {
+		resourceType = sql.NullString{String: u.ResourceType, Valid: true}
+		resourceID = sql.NullInt64{Int64: int64(u.ResourceID), Valid: true}
+	}
+	now := clock.Now()
+	return scanResumableUpload(database.DB.QueryRowContext(ctx, `
+		INSERT INTO resumable_uploads (id, target, resource_type, resource_id, filename, metadata, upload_length, created_by, created_at, updated_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $9, $10)
+		RETURNING `+resumableUploadColumns,
+		u.ID, u.Target, resourceType, resourceID, u.Filename, u.Metadata, u.Length, u.CreatedBy, now, u.ExpiresAt))
+}
+
+// GetResumableUpload returns one of a user's uploads that has not expired.
+func GetResumableUpload(ctx context.Context, id string, userID int) (models.ResumableUpload, error) {
+	return scanResumableUpload(database.DB.QueryRowContext(ctx,
+		"SELECT "+resumableUploadColumns+" FROM resumable_uploads WHERE id = $1 AND created_by = $2 AND expires_at > $3",
+		id, userID, clock.Now()))
+}
+
+// AppendResumableChunk records a chunk stored at key as the n bytes after
+// offset, and pushes back the upload's expiry. It returns ErrNotFound if
+// the upload is no longer at offset, as when another request appended
+// first.
+func AppendResumableChunk(ctx context.Context, id string, offset, n int64, key string, expiresAt time.Time) (models.ResumableUpload, error) {
+	return scanResumableUpload(database.DB.QueryRowContext(ctx, `
+		UPDATE resumable_uploads
+		SET upload_offset = upload_offset + $3, chunk_keys = array_append(chunk_keys, $4), updated_at = $5, expires_at = $6
+		WHERE id = $1 AND upload_offset = $2 AND upload_offset + $3 <= upload_length AND completed_at IS NULL
+		RETURNING `+resumableUploadColumns,
+		id, offset, n, key, clock.Now(), expiresAt))
+}
+
+// ClaimResumableUpload marks a fully received upload completed so only one
+// request hands it to its target. It returns ErrNotFound if the upload is
+// not complete or was already claimed.
+func ClaimResumableUpload(ctx context.Context, id string) (models.ResumableUpload, error) {
+	now := clock.Now()
+	return scanResumableUpload(database.DB.QueryRowContext(ctx, `
+		UPDATE resumable_uploads SET completed_at = $2, updated_at = $2
+		WHERE id = $1 AND upload_offset = upload_length AND completed_at IS NULL
+		RETURNING `+resumableUploadColumns,
+		id, now))
+}
+
+// ReleaseResumableUpload undoes a claim whose target failed, so it can be
+// tried again.
+func ReleaseResumableUpload(ctx context.Context, id string) error {
+	_, err := database.DB.ExecContext(ctx, "UPDATE resumable_uploads SET completed_at = NULL WHERE id = $1", id)
+	return err
+}
+
+// FinishResumableUpload records what a claimed upload created and drops
+// its chunks, which a trigger queues for deletion from storage.
+func FinishResumableUpload(ctx context.Context, id, result string) (models.ResumableUpload, error) {
+	return scanResumableUpload(database.DB.QueryRowContext(ctx, `
+		UPDATE resumable_uploads SET result = $2, chunk_keys = '{}', updated_at = $3
+		WHERE id = $1
+		RETURNING `+resumableUploadColumns,
+		id, result, clock.Now()))
+}
+
+func DeleteResumableUpload(ctx context.Context, id string, userID int) error {
+	return execAffectingOne(ctx, "DELETE FROM resumable_uploads WHERE id = $1 AND created_by = $2", id, userID)
+}
+
+// DeleteExpiredResumableUploads deletes the uploads that expired before
+// now, queueing their chunks for deletion, and returns how many there
+// were.
+func DeleteExpiredResumableUploads(ctx context.Context, now time.Time) (int64, error) {
+	result, err := database.DB.ExecContext(ctx, "DELETE FROM resumable_uploads WHERE expires_at <= $1", now)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
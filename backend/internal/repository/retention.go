@@ -0,0 +1,132 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"pygorp/backend/internal/clock"
+	"pygorp/backend/internal/database"
+	"pygorp/backend/internal/events"
+
+	"github.com/lib/pq"
+)
+
+// retentionTarget describes the records of a retention target: the table
+// they live in, aliased t; which of them are older than the cutoff ($1);
+// and how a preview names them.
+type retentionTarget struct {
+	table string
+	where string
+	name  string
+}
+
+var retentionTargets = map[string]retentionTarget{
+	"events":      {table: "events", where: "t.recorded_at < $1", name: "t.id::text"},
+	"sent_emails": {table: "sent_emails", where: "t.created_at < $1", name: "t.id::text"},
+	"ai_requests": {table: "ai_requests", where: "t.created_at < $1 AND " + notHeldUserID, name: "t.id::text"},
+	// Sessions go once they have been expired or revoked for the period.
+	// They are named by their user, since their IDs are credentials.
+	"sessions": {table: "sessions", where: "LEAST(t.revoked_at, t.expires_at) < $1 AND " + notHeldUserID, name: "t.user_id::text"},
+	// Each user's latest version is kept, so its history still shows
+	// what it looks like.
+	"user_history": {table: "user_history",
+		where: "t.changed_at < $1 AND t.version < (SELECT MAX(version) FROM user_history WHERE user_id = t.user_id) AND " + notHeldUserID,
+		name:  "t.user_id || '/' || t.version"},
+	// Users are inactive when they have not changed or used a session
+	// since the cutoff. Users pending deletion are left to be purged.
+	"inactive_users": {table: "users",
+		where: "t.anonymized_at IS NULL AND t.purge_at IS NULL AND t.created_at < $1 AND t.updated_at < $1 " +
+			"AND NOT EXISTS (SELECT 1 FROM sessions WHERE user_id = t.id AND last_used_at >= $1) " +
+			"AND NOT EXISTS (SELECT 1 FROM legal_holds WHERE user_id = t.id)",
+		name: "t.id::text"},
+}
+
+// notHeldUserID keeps the records of users under legal hold out of a
+// retention target.
+const notHeldUserID = "NOT EXISTS (SELECT 1 FROM legal_holds WHERE user_id = t.user_id)"
+
+func lookupRetentionTarget(name string) (retentionTarget, error) {
+	target, ok := retentionTargets[name]
+	if !ok {
+		return target, fmt.Errorf("unknown retention target %q", name)
+	}
+	return target, nil
+}
+
+// PreviewRetention counts the records of a retention target older than
+// cutoff and names up to sample of them.
+func PreviewRetention(ctx context.Context, name string, cutoff time.Time, sample int) (int, []string, error) {
+	target, err := lookupRetentionTarget(name)
+	if err != nil {
+		return 0, nil, err
+	}
+	var count int
+	err = database.DB.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM "+target.table+" t WHERE "+target.where, cutoff).Scan(&count)
+	if err != nil {
+		return 0, nil, err
+	}
+	names := []string{}
+	err = database.DB.QueryRowContext(ctx,
+		"SELECT ARRAY(SELECT "+target.name+" FROM "+target.table+" t WHERE "+target.where+" LIMIT $2)",
+		cutoff, sample).Scan((*pq.StringArray)(&names))
+	return count, names, err
+}
+
+// ApplyRetention purges, or for inactive users anonymizes, up to limit
+// records of a retention target older than cutoff and returns how many it
+// handled.
+func ApplyRetention(ctx context.Context, name string, cutoff time.Time, limit int) (int, error) {
+	target, err := lookupRetentionTarget(name)
+	if err != nil {
+		return 0, err
+	}
+	if name == "inactive_users" {
+		return anonymizeUsers(ctx, target, cutoff, limit)
+	}
+	result, err := database.DB.ExecContext(ctx,
+		"DELETE FROM "+target.table+" WHERE ctid = ANY(ARRAY(SELECT t.ctid FROM "+target.table+" t WHERE "+target.where+" LIMIT $2))",
+		cutoff, limit)
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	return int(n), err
+}
+
+// anonymizeUsers replaces the personal data of inactive users with
+// placeholders and clears their history, which holds it too.
+func anonymizeUsers(ctx context.Context, target retentionTarget, cutoff time.Time, limit int) (int, error) {
+	tx, err := database.BeginTx(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	now := clock.Now()
+	var ids []int64
+	err = tx.QueryRowContext(ctx, `
+		WITH anonymized AS (
+			UPDATE users SET email = 'anonymized-' || id || '@anonymized.invalid', name = 'Anonymized user',
+				attributes = '{}', avatar = NULL, external_id = NULL, anonymized_at = $3, updated_at = $3
+			WHERE id = ANY(ARRAY(SELECT t.id FROM users t WHERE `+target.where+` LIMIT $2))
+			RETURNING id
+		)
+		SELECT ARRAY(SELECT id FROM anonymized)`, cutoff, limit, now).Scan((*pq.Int64Array)(&ids))
+	if err != nil {
+		return 0, err
+	}
+	// The history trigger has recorded the old values by now.
+	if _, err := tx.ExecContext(ctx, "DELETE FROM user_history WHERE user_id = ANY($1)", pq.Int64Array(ids)); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	for _, id := range ids {
+		events.Publish(ctx, events.UserUpdated, 2, events.UserUpdatedV2{ID: int(id), Fields: []string{"email", "name", "attributes", "avatar"}})
+	}
+	return len(ids), nil
+}
@@ -0,0 +1,182 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"pygorp/backend/internal/clock"
+	"pygorp/backend/internal/database"
+	"pygorp/backend/internal/events"
+	"pygorp/backend/internal/models"
+	"pygorp/backend/internal/sqlb"
+
+	"github.com/lib/pq"
+)
+
+// ErrUserExists is returned when an email or external ID is already taken.
+var ErrUserExists = errors.New("user already exists")
+
+const provisionedColumns = userColumns + ", external_id, active"
+
+const (
+	getProvisionedQuery = "SELECT " + provisionedColumns + " FROM users WHERE id = $1"
+	provisionUserQuery  = "INSERT INTO users (email, name, external_id, active, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $5) RETURNING " + provisionedColumns
+	replaceUserQuery    = "UPDATE users SET email = $1, name = $2, external_id = $3, active = $4, updated_at = $6 WHERE id = $5 RETURNING " + provisionedColumns
+)
+
+func init() {
+	database.UseColumns("users", "external_id, active")
+}
+
+// ProvisionRequest holds the fields an identity provider manages.
+type ProvisionRequest struct {
+	Email      string
+	Name       string
+	ExternalID *string
+	Active     bool
+}
+
+func scanProvisioned(row scanner) (models.ProvisionedUser, error) {
+	var u models.ProvisionedUser
+	var externalID sql.NullString
+	var err error
+	u.User, err = scanUser(withProvisioning{row, &externalID, &u.Active})
+	if externalID.Valid {
+		u.ExternalID = &externalID.String
+	}
+	return u, err
+}
+
+// withProvisioning appends the external_id and active columns to a row
+// scanned by scanUser.
+type withProvisioning struct {
+	row        scanner
+	externalID *sql.NullString
+	active     *bool
+}
+
+func (w withProvisioning) Scan(dest ...interface{}) error {
+	return w.row.Scan(append(dest, w.externalID, w.active)...)
+}
+
+// provisionError maps missing rows and unique violations to the package
+// errors.
+func provisionError(err error) error {
+	var pqErr *pq.Error
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return ErrNotFound
+	case errors.As(err, &pqErr) && pqErr.Code == "23505":
+		return ErrUserExists
+	}
+	return err
+}
+
+// CountProvisionedUsers counts the users matching where. A zero where
+// matches all.
+func CountProvisionedUsers(ctx context.Context, where sqlb.Expr) (int, error) {
+	q := sqlb.Select("COUNT(*)").From("users")
+	if where.SQL != "" {
+		q.WhereExpr(where)
+	}
+	query, args, err := q.Build()
+	if err != nil {
+		return 0, err
+	}
+
+	var total int
+	err = database.WithStmt(ctx, query, func(stmt *sql.Stmt) error {
+		return stmt.QueryRowContext(ctx, args...).Scan(&total)
+	})
+	return total, err
+}
+
+// ListProvisionedUsers returns a page of users matching where, ordered by
+// ID. A zero where matches all.
+func ListProvisionedUsers(ctx context.Context, where sqlb.Expr, page sqlb.Page) ([]models.ProvisionedUser, error) {
+	q := sqlb.Select(provisionedColumns).From("users").OrderBy("id").Page(page)
+	if where.SQL != "" {
+		q.WhereExpr(where)
+	}
+	query, args, err := q.Build()
+	if err != nil {
+		return nil, err
+	}
+
+	var users []models.ProvisionedUser
+	err = database.WithStmt(ctx, query, func(stmt *sql.Stmt) error {
+		rows, err := stmt.QueryContext(ctx, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		users = []models.ProvisionedUser{}
+		for rows.Next() {
+			u, err := scanProvisioned(rows)
+			if err != nil {
+				return err
+			}
+			users = append(users, u)
+		}
+		return rows.Err()
+	})
+	return users, err
+}
+
+func GetProvisionedUser(ctx context.Context, id int) (models.ProvisionedUser, error) {
+	var u models.ProvisionedUser
+	err := database.WithStmt(ctx, getProvisionedQuery, func(stmt *sql.Stmt) error {
+		var err error
+		u, err = scanProvisioned(stmt.QueryRowContext(ctx, id))
+		return err
+	})
+	return u, provisionError(err)
+}
+
+// ProvisionUser creates a user on behalf of an identity provider.
+func ProvisionUser(ctx context.Context, req ProvisionRequest) (models.ProvisionedUser, error) {
+	var u models.ProvisionedUser
+	err := database.WithStmt(ctx, provisionUserQuery, func(stmt *sql.Stmt) error {
+		var err error
+		u, err = scanProvisioned(stmt.QueryRowContext(ctx, req.Email, req.Name, req.ExternalID, req.Active))
+		return err
+	})
+	if err != nil {
+		return u, provisionError(err)
+	}
+	events.Publish(ctx, events.UserCreated, 1, events.UserCreatedV1{ID: u.ID, Email: u.Email, Name: u.Name})
+	return u, nil
+}
+
+// ReplaceProvisionedUser overwrites the provisioned fields of a user.
+// Deactivating a user keeps the row; DeleteUser removes it.
+func ReplaceProvisionedUser(ctx context.Context, id int, req ProvisionRequest) (models.ProvisionedUser, error) {
+	before, err := GetProvisionedUser(ctx, id)
+	if err != nil {
+		return before, err
+	}
+
+	var u models.ProvisionedUser
+	err = database.WithStmt(ctx, replaceUserQuery, func(stmt *sql.Stmt) error {
+		var err error
+		u, err = scanProvisioned(stmt.QueryRowContext(ctx, req.Email, req.Name, req.ExternalID, req.Active, id, clock.Now()))
+		return err
+	})
+	if err != nil {
+		return u, provisionError(err)
+	}
+
+	var fields []string
+	if u.Email != before.Email {
+		fields = append(fields, "email")
+	}
+	if u.Name != before.Name {
+		fields = append(fields, "name")
+	}
+	if len(fields) > 0 {
+		events.Publish(ctx, events.UserUpdated, 2, events.UserUpdatedV2{ID: id, Fields: fields})
+	}
+	return u, nil
+}
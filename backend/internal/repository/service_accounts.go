@@ -0,0 +1,226 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"pygorp/backend/internal/auth"
+	"pygorp/backend/internal/clock"
+	"pygorp/backend/internal/database"
+	"pygorp/backend/internal/models"
+	"pygorp/backend/internal/sqlb"
+
+	"github.com/lib/pq"
+)
+
+// ErrServiceAccountExists is returned when a service account name is
+// already taken.
+var ErrServiceAccountExists = errors.New("service account name already exists")
+
+const (
+	serviceAccountColumns = "id, name, description, scopes, disabled_at, created_at, updated_at"
+	apiKeyColumns         = "id, service_account_id, name, prefix, expires_at, last_used_at, revoked_at, created_at"
+)
+
+const (
+	getServiceAccountQuery     = "SELECT " + serviceAccountColumns + " FROM service_accounts WHERE id = $1"
+	createServiceAccountQuery  = "INSERT INTO service_accounts (name, description, scopes, created_at, updated_at) VALUES ($1, $2, $3, $4, $4) RETURNING " + serviceAccountColumns
+	updateServiceAccountQuery  = "UPDATE service_accounts SET description = COALESCE($1, description), scopes = COALESCE($2, scopes), updated_at = $4 WHERE id = $3 RETURNING " + serviceAccountColumns
+	disableServiceAccountQuery = "UPDATE service_accounts SET disabled_at = CASE WHEN $1 THEN COALESCE(disabled_at, $3) END, updated_at = $3 WHERE id = $2 RETURNING " + serviceAccountColumns
+	deleteServiceAccountQuery  = "DELETE FROM service_accounts WHERE id = $1"
+
+	listAPIKeysQuery  = "SELECT " + apiKeyColumns + " FROM service_account_keys WHERE service_account_id = $1 ORDER BY id"
+	createAPIKeyQuery = "INSERT INTO service_account_keys (service_account_id, name, prefix, key_hash, expires_at, created_at) VALUES ($1, $2, $3, $4, $5, $6) RETURNING " + apiKeyColumns
+	revokeAPIKeyQuery = "UPDATE service_account_keys SET revoked_at = $3 WHERE id = $1 AND service_account_id = $2 AND revoked_at IS NULL"
+
+	// Keys of disabled accounts, and revoked or expired keys, do not match.
+	authenticateAPIKeyQuery = "SELECT k.id, k.key_hash, a.id, a.name, a.description, a.scopes, a.disabled_at, a.created_at, a.updated_at " +
+		"FROM service_account_keys k JOIN service_accounts a ON a.id = k.service_account_id " +
+		"WHERE k.prefix = $1 AND k.revoked_at IS NULL AND (k.expires_at IS NULL OR k.expires_at > $2) AND a.disabled_at IS NULL"
+	// last_used_at is only written once a minute per key.
+	touchAPIKeyQuery = "UPDATE service_account_keys SET last_used_at = $2 WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < $2::timestamptz - INTERVAL '1 minute')"
+)
+
+func init() {
+	database.UseColumns("service_accounts", serviceAccountColumns)
+	database.UseColumns("service_account_keys", apiKeyColumns+", key_hash")
+}
+
+func scanServiceAccount(row scanner) (models.ServiceAccount, error) {
+	var a models.ServiceAccount
+	err := row.Scan(&a.ID, &a.Name, &a.Description, pq.Array(&a.Scopes), &a.DisabledAt, &a.CreatedAt, &a.UpdatedAt)
+	return a, err
+}
+
+func scanAPIKey(row scanner) (models.APIKey, error) {
+	var k models.APIKey
+	err := row.Scan(&k.ID, &k.ServiceAccountID, &k.Name, &k.Prefix, &k.ExpiresAt, &k.LastUsedAt, &k.RevokedAt, &k.CreatedAt)
+	return k, err
+}
+
+// serviceAccountError maps missing rows and duplicate names to the package
+// errors.
+func serviceAccountError(err error) error {
+	var pqErr *pq.Error
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return ErrNotFound
+	case errors.As(err, &pqErr) && pqErr.Code == "23505":
+		return ErrServiceAccountExists
+	}
+	return err
+}
+
+// ListServiceAccounts returns a page of service accounts ordered by name.
+func ListServiceAccounts(ctx context.Context, page sqlb.Page) ([]models.ServiceAccount, error) {
+	query, args, err := sqlb.Select(serviceAccountColumns).From("service_accounts").OrderBy("name").Page(page).Build()
+	if err != nil {
+		return nil, err
+	}
+
+	var accounts []models.ServiceAccount
+	err = database.WithStmt(ctx, query, func(stmt *sql.Stmt) error {
+		rows, err := stmt.QueryContext(ctx, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		accounts = []models.ServiceAccount{}
+		for rows.Next() {
+			a, err := scanServiceAccount(rows)
+			if err != nil {
+				return err
+			}
+			accounts = append(accounts, a)
+		}
+		return rows.Err()
+	})
+	return accounts, err
+}
+
+func GetServiceAccount(ctx context.Context, id int) (models.ServiceAccount, error) {
+	return queryServiceAccount(ctx, getServiceAccountQuery, id)
+}
+
+func CreateServiceAccount(ctx context.Context, req models.CreateServiceAccountRequest) (models.ServiceAccount, error) {
+	return queryServiceAccount(ctx, createServiceAccountQuery, req.Name, req.Description, pq.Array(req.Scopes), clock.Now())
+}
+
+func UpdateServiceAccount(ctx context.Context, id int, req models.UpdateServiceAccountRequest) (models.ServiceAccount, error) {
+	var scopes interface{}
+	if req.Scopes != nil {
+		scopes = pq.Array(req.Scopes)
+	}
+	return queryServiceAccount(ctx, updateServiceAccountQuery, req.Description, scopes, id, clock.Now())
+}
+
+// SetServiceAccountDisabled disables or re-enables a service account. Keys
+// of a disabled account are rejected but kept, so enabling it restores them.
+func SetServiceAccountDisabled(ctx context.Context, id int, disabled bool) (models.ServiceAccount, error) {
+	return queryServiceAccount(ctx, disableServiceAccountQuery, disabled, id, clock.Now())
+}
+
+// DeleteServiceAccount removes a service account along with its keys.
+func DeleteServiceAccount(ctx context.Context, id int) error {
+	return execAffectingOne(ctx, deleteServiceAccountQuery, id)
+}
+
+func queryServiceAccount(ctx context.Context, query string, args ...interface{}) (models.ServiceAccount, error) {
+	var a models.ServiceAccount
+	err := database.WithStmt(ctx, query, func(stmt *sql.Stmt) error {
+		var err error
+		a, err = scanServiceAccount(stmt.QueryRowContext(ctx, args...))
+		return err
+	})
+	return a, serviceAccountError(err)
+}
+
+// ListAPIKeys returns every key of a service account, including revoked
+// ones, oldest first.
+func ListAPIKeys(ctx context.Context, accountID int) ([]models.APIKey, error) {
+	var keys []models.APIKey
+	err := database.WithStmt(ctx, listAPIKeysQuery, func(stmt *sql.Stmt) error {
+		rows, err := stmt.QueryContext(ctx, accountID)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		keys = []models.APIKey{}
+		for rows.Next() {
+			k, err := scanAPIKey(rows)
+			if err != nil {
+				return err
+			}
+			keys = append(keys, k)
+		}
+		return rows.Err()
+	})
+	return keys, err
+}
+
+// CreateAPIKey generates a key for a service account. The returned secret
+// cannot be retrieved again.
+func CreateAPIKey(ctx context.Context, accountID int, req models.CreateAPIKeyRequest) (models.CreatedAPIKey, error) {
+	key, prefix, hash := auth.NewAPIKey()
+	created := models.CreatedAPIKey{Key: key}
+	err := database.WithStmt(ctx, createAPIKeyQuery, func(stmt *sql.Stmt) error {
+		var err error
+		created.APIKey, err = scanAPIKey(stmt.QueryRowContext(ctx, accountID, req.Name, prefix, hash, req.ExpiresAt, clock.Now()))
+		return err
+	})
+	return created, err
+}
+
+func RevokeAPIKey(ctx context.Context, accountID, keyID int) error {
+	return execAffectingOne(ctx, revokeAPIKeyQuery, keyID, accountID, clock.Now())
+}
+
+// AuthenticateAPIKey returns the enabled service account a presented key
+// belongs to, or ErrNotFound when the key is unknown, revoked, or expired.
+func AuthenticateAPIKey(ctx context.Context, key string) (models.ServiceAccount, error) {
+	prefix, hash, ok := auth.ParseAPIKey(key)
+	if !ok {
+		return models.ServiceAccount{}, ErrNotFound
+	}
+
+	var (
+		a          models.ServiceAccount
+		keyID      int
+		storedHash string
+	)
+	now := clock.Now()
+	err := database.WithStmt(ctx, authenticateAPIKeyQuery, func(stmt *sql.Stmt) error {
+		var err error
+		a, err = scanServiceAccount(withKey{stmt.QueryRowContext(ctx, prefix, now), &keyID, &storedHash})
+		return err
+	})
+	if err != nil {
+		return models.ServiceAccount{}, serviceAccountError(err)
+	}
+	if !auth.HashesMatch(hash, storedHash) {
+		return models.ServiceAccount{}, ErrNotFound
+	}
+
+	if err := database.WithStmt(ctx, touchAPIKeyQuery, func(stmt *sql.Stmt) error {
+		_, err := stmt.ExecContext(ctx, keyID, now)
+		return err
+	}); err != nil {
+		return models.ServiceAccount{}, err
+	}
+	return a, nil
+}
+
+// withKey prepends the key's id and key_hash columns to a row scanned by
+// scanServiceAccount.
+type withKey struct {
+	row  scanner
+	id   *int
+	hash *string
+}
+
+func (w withKey) Scan(dest ...interface{}) error {
+	return w.row.Scan(append([]interface{}{w.id, w.hash}, dest...)...)
+}
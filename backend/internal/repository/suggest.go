@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"pygorp/backend/internal/database"
+	"pygorp/backend/internal/models"
+	"pygorp/backend/internal/sqlb"
+)
+
+// suggestUsersQuery matches a prefix of the name, of any word in the name,
+// or of the email. Every condition can use the trigram indexes. Whole-name
+// and email prefixes rank first, then the closest names. Users pending
+// deletion are left out.
+const suggestUsersQuery = `
+	SELECT id, email, name FROM users
+	WHERE purge_at IS NULL
+		AND (lower(name) LIKE $1 OR lower(name) LIKE '% ' || $1 OR lower(email) LIKE $1)
+	ORDER BY (lower(name) LIKE $1 OR lower(email) LIKE $1) DESC,
+		similarity(lower(name), $2) DESC, name, id
+	LIMIT $3`
+
+// SuggestUsers returns up to limit users whose name or email starts with
+// prefix, case-insensitively.
+func SuggestUsers(ctx context.Context, prefix string, limit int) ([]models.UserSuggestion, error) {
+	prefix = strings.ToLower(prefix)
+	suggestions := []models.UserSuggestion{}
+	err := database.WithReadStmt(ctx, suggestUsersQuery, func(stmt *sql.Stmt) error {
+		rows, err := stmt.QueryContext(ctx, sqlb.EscapeLike(prefix)+"%", prefix, limit)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		suggestions = suggestions[:0]
+		for rows.Next() {
+			var s models.UserSuggestion
+			if err := rows.Scan(&s.ID, &s.Email, &s.Name); err != nil {
+				return err
+			}
+			suggestions = append(suggestions, s)
+		}
+		return rows.Err()
+	})
+	return suggestions, err
+}
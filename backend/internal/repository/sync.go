@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+
+	"pygorp/backend/internal/events"
+	"pygorp/backend/internal/models"
+)
+
+// UserDelta is the set of user changes after a position in the event log.
+// Cursor is the position to resume from on the next sync.
+type UserDelta struct {
+	Created []models.User      `json:"created"`
+	Updated []models.User      `json:"updated"`
+	Deleted []models.Tombstone `json:"deleted"`
+	Cursor  int64              `json:"-"`
+	HasMore bool               `json:"-"`
+}
+
+// UserSnapshot returns every user as created, with a cursor taken before
+// reading them. Changes racing the snapshot are replayed by the next sync,
+// which clients apply idempotently.
+func UserSnapshot(ctx context.Context) (*UserDelta, error) {
+	cursor, err := events.LatestSeq(ctx)
+	if err != nil {
+		return nil, err
+	}
+	users, err := ListUsers(ctx, UserQuery{Sort: []string{"id ASC"}})
+	if err != nil {
+		return nil, err
+	}
+	if users == nil {
+		users = []models.User{}
+	}
+	return &UserDelta{Created: users, Updated: []models.User{}, Deleted: []models.Tombstone{}, Cursor: cursor}, nil
+}
+
+// UserChanges folds up to limit user events after afterSeq into the current
+// state of each changed user. Users created and deleted within the window are
+// omitted, since the client never saw them.
+func UserChanges(ctx context.Context, afterSeq int64, limit int) (*UserDelta, error) {
+	list, err := events.List(ctx, afterSeq, []string{events.UserCreated, events.UserUpdated, events.UserDeleted}, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	delta := &UserDelta{
+		Created: []models.User{},
+		Updated: []models.User{},
+		Deleted: []models.Tombstone{},
+		Cursor:  afterSeq,
+		HasMore: len(list) == limit,
+	}
+
+	var order []int
+	created := map[int]bool{}
+	deleted := map[int]models.Tombstone{}
+	seen := map[int]bool{}
+	for _, e := range list {
+		delta.Cursor = e.Seq
+
+		var data struct {
+			ID int `json:"id"`
+		}
+		if err := json.Unmarshal(e.Data, &data); err != nil {
+			return nil, err
+		}
+		if !seen[data.ID] {
+			seen[data.ID] = true
+			order = append(order, data.ID)
+		}
+		switch e.Type {
+		case events.UserCreated:
+			created[data.ID] = true
+		case events.UserDeleted:
+			deleted[data.ID] = models.Tombstone{ID: data.ID, DeletedAt: e.OccurredAt}
+		}
+	}
+
+	var live []int
+	for _, id := range order {
+		if tombstone, ok := deleted[id]; ok {
+			if !created[id] {
+				delta.Deleted = append(delta.Deleted, tombstone)
+			}
+			continue
+		}
+		live = append(live, id)
+	}
+	if len(live) == 0 {
+		return delta, nil
+	}
+
+	users, err := UsersByIDs(ctx, live)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range live {
+		user, ok := users[id]
+		if !ok {
+			// Deleted after the window; a later sync reports the tombstone.
+			continue
+		}
+		if created[id] {
+			delta.Created = append(delta.Created, user)
+		} else {
+			delta.Updated = append(delta.Updated, user)
+		}
+	}
+	return delta, nil
+}
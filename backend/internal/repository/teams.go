@@ -0,0 +1,135 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"pygorp/backend/internal/clock"
+	"pygorp/backend/internal/database"
+	"pygorp/backend/internal/models"
+	"pygorp/backend/internal/sqlb"
+
+	"github.com/lib/pq"
+)
+
+var (
+	// ErrTeamExists is returned when an org already has a team by the name.
+	ErrTeamExists = errors.New("team name already exists")
+	// ErrTeamHasProjects is returned when deleting a team that still has
+	// projects without asking for them to be deleted too.
+	ErrTeamHasProjects = errors.New("team has projects")
+)
+
+const teamColumns = "id, org_id, name, description, " +
+	"(SELECT COUNT(*) FROM team_members WHERE team_members.team_id = teams.id), " +
+	"created_at, updated_at"
+
+func init() {
+	database.UseColumns("teams", "id, org_id, name, description, created_at, updated_at")
+	database.UseColumns("team_members", "team_id, user_id, added_at")
+}
+
+func scanTeam(row scanner) (models.Team, error) {
+	var t models.Team
+	err := row.Scan(&t.ID, &t.OrgID, &t.Name, &t.Description, &t.MemberCount, &t.CreatedAt, &t.UpdatedAt)
+	return t, teamError(err)
+}
+
+// teamError maps missing rows and duplicate names to the package errors.
+func teamError(err error) error {
+	var pqErr *pq.Error
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return ErrNotFound
+	case errors.As(err, &pqErr) && pqErr.Code == "23505":
+		return ErrTeamExists
+	}
+	return err
+}
+
+// ListTeams returns a page of an org's teams ordered by name.
+func ListTeams(ctx context.Context, orgID int, page sqlb.Page) ([]models.Team, error) {
+	query, args, err := sqlb.Select(teamColumns).From("teams").
+		Where("org_id = ?", orgID).OrderBy("name").Page(page).Build()
+	if err != nil {
+		return nil, err
+	}
+	return queryAll(ctx, scanTeam, query, args...)
+}
+
+func GetTeam(ctx context.Context, orgID, teamID int) (models.Team, error) {
+	return queryOne(ctx, scanTeam,
+		"SELECT "+teamColumns+" FROM teams WHERE id = $1 AND org_id = $2", teamID, orgID)
+}
+
+func CreateTeam(ctx context.Context, orgID int, req models.CreateTeamRequest) (models.Team, error) {
+	return queryOne(ctx, scanTeam,
+		"INSERT INTO teams (org_id, name, description, created_at, updated_at) VALUES ($1, $2, $3, $4, $4) RETURNING "+teamColumns,
+		orgID, req.Name, req.Description, clock.Now())
+}
+
+func UpdateTeam(ctx context.Context, orgID, teamID int, req models.UpdateTeamRequest) (models.Team, error) {
+	return queryOne(ctx, scanTeam, `
+		UPDATE teams SET name = COALESCE(NULLIF($3, ''), name), description = COALESCE(NULLIF($4, ''), description), updated_at = $5
+		WHERE id = $1 AND org_id = $2 RETURNING `+teamColumns,
+		teamID, orgID, req.Name, req.Description, clock.Now())
+}
+
+// DeleteTeam removes a team and its memberships. A team with projects is
+// only deleted with deleteProjects set, and its projects go with it;
+// otherwise ErrTeamHasProjects is returned. It returns how many projects
+// the team had.
+func DeleteTeam(ctx context.Context, orgID, teamID int, deleteProjects bool) (int, error) {
+	tx, err := database.BeginTx(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// Locking the team holds back projects being created in it meanwhile.
+	var projects int
+	err = tx.QueryRowContext(ctx, `
+		SELECT (SELECT COUNT(*) FROM projects WHERE team_id = teams.id AND deleted_at IS NULL) FROM teams
+		WHERE id = $1 AND org_id = $2 FOR UPDATE`, teamID, orgID).Scan(&projects)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	if projects > 0 && !deleteProjects {
+		return projects, ErrTeamHasProjects
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM teams WHERE id = $1", teamID); err != nil {
+		return projects, err
+	}
+	return projects, tx.Commit()
+}
+
+// AddTeamMembers adds users to a team and returns the IDs that were newly
+// added. Existing members and users outside the team's org are skipped.
+func AddTeamMembers(ctx context.Context, orgID, teamID int, userIDs []int) ([]int, error) {
+	return queryAll(ctx, scanID, `
+		INSERT INTO team_members (team_id, user_id, added_at)
+		SELECT teams.id, users.id, $4 FROM teams JOIN users ON users.org_id = teams.org_id
+		WHERE teams.id = $1 AND teams.org_id = $2 AND users.id = ANY($3)
+		ON CONFLICT DO NOTHING RETURNING user_id`,
+		teamID, orgID, pq.Array(userIDs), clock.Now())
+}
+
+func RemoveTeamMember(ctx context.Context, orgID, teamID, userID int) error {
+	return execAffectingOne(ctx, `
+		DELETE FROM team_members WHERE team_id = $1 AND user_id = $2
+		AND team_id IN (SELECT id FROM teams WHERE org_id = $3)`, teamID, userID, orgID)
+}
+
+// InTeam reports whether a user is a member of a team.
+func InTeam(ctx context.Context, teamID, userID int) (bool, error) {
+	var in bool
+	err := database.WithStmt(ctx, "SELECT EXISTS (SELECT 1 FROM team_members WHERE team_id = $1 AND user_id = $2)", func(stmt *sql.Stmt) error {
+		return stmt.QueryRowContext(ctx, teamID, userID).Scan(&in)
+	})
+	return in, err
+}
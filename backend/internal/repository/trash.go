@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"pygorp/backend/internal/events"
+	"pygorp/backend/internal/models"
+	"pygorp/backend/internal/sqlb"
+
+	"github.com/lib/pq"
+)
+
+// trashQuery lists users pending deletion and deleted projects alike.
+const trashQuery = `
+	SELECT type, id, org_id, name, deleted_at, deleted_by, purge_at FROM (
+		SELECT 'user' AS type, id, NULL::int AS org_id, name, deletion_requested_at AS deleted_at,
+			deletion_requested_by AS deleted_by, purge_at
+		FROM users WHERE purge_at IS NOT NULL
+		UNION ALL
+		SELECT 'project', id, org_id, name, deleted_at, deleted_by, purge_at
+		FROM projects WHERE deleted_at IS NOT NULL
+	) trash
+	WHERE $1 = '' OR type = $1
+	ORDER BY deleted_at DESC, type, id
+	LIMIT $2 OFFSET $3`
+
+// ListTrash returns a page of the trash, most recently deleted first,
+// optionally only one kind of resource (models.TrashUser or
+// models.TrashProject).
+func ListTrash(ctx context.Context, kind string, page sqlb.Page) ([]models.TrashItem, error) {
+	return queryAll(ctx, func(row scanner) (models.TrashItem, error) {
+		var item models.TrashItem
+		var orgID, deletedBy sql.NullInt64
+		err := row.Scan(&item.Type, &item.ID, &orgID, &item.Name, &item.DeletedAt, &deletedBy, &item.PurgeAt)
+		item.OrgID, item.DeletedBy = nullableID(orgID), nullableID(deletedBy)
+		return item, err
+	}, trashQuery, kind, page.Limit, page.Offset)
+}
+
+// RestoreUsers cancels the deletion of users in the trash. Users that are
+// not pending deletion are reported as not found.
+func RestoreUsers(ctx context.Context, ids []int) []models.BulkItemResult {
+	return runBulk(ctx, ids, BulkRestored, func(tx *sql.Tx, batch []int) (*sql.Rows, error) {
+		return tx.QueryContext(ctx, `
+			UPDATE users SET deletion_requested_at = NULL, purge_at = NULL, deletion_requested_by = NULL
+			WHERE id = ANY($1) AND purge_at IS NOT NULL RETURNING id`, pq.Array(batch))
+	})
+}
+
+// RestoreProjects takes projects out of the trash.
+func RestoreProjects(ctx context.Context, ids []int) []models.BulkItemResult {
+	return runBulk(ctx, ids, BulkRestored, func(tx *sql.Tx, batch []int) (*sql.Rows, error) {
+		return tx.QueryContext(ctx, `
+			UPDATE projects SET deleted_at = NULL, deleted_by = NULL, purge_at = NULL
+			WHERE id = ANY($1) AND deleted_at IS NOT NULL RETURNING id`, pq.Array(batch))
+	})
+}
+
+// PurgeUsers deletes users in the trash for good without waiting for their
+// grace period to end. Users under legal hold are skipped.
+func PurgeUsers(ctx context.Context, ids []int) []models.BulkItemResult {
+	results := skipHeldUsers(ctx, ids, func(ids []int) []models.BulkItemResult {
+		return runBulk(ctx, ids, BulkPurged, func(tx *sql.Tx, batch []int) (*sql.Rows, error) {
+			return tx.QueryContext(ctx, "DELETE FROM users WHERE id = ANY($1) AND purge_at IS NOT NULL AND "+notHeld+" RETURNING id", pq.Array(batch))
+		})
+	})
+	for _, r := range results {
+		if r.Status == BulkPurged {
+			events.Publish(ctx, events.UserDeleted, 1, events.UserDeletedV1{ID: r.ID})
+		}
+	}
+	return results
+}
+
+// PurgeProjects deletes projects in the trash for good.
+func PurgeProjects(ctx context.Context, ids []int) []models.BulkItemResult {
+	return runBulk(ctx, ids, BulkPurged, func(tx *sql.Tx, batch []int) (*sql.Rows, error) {
+		return tx.QueryContext(ctx, "DELETE FROM projects WHERE id = ANY($1) AND deleted_at IS NOT NULL RETURNING id", pq.Array(batch))
+	})
+}
+
+// nullableUserID stores a missing user, such as the anonymous caller when
+// authentication is off, as NULL.
+func nullableUserID(id int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(id), Valid: id > 0}
+}
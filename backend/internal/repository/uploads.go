@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"pygorp/backend/internal/clock"
+	"pygorp/backend/internal/database"
+	"pygorp/backend/internal/models"
+)
+
+const presignedUploadColumns = "id, resource_type, resource_id, filename, content_type, size, storage_key, created_by, created_at, expires_at, completed_at"
+
+func init() {
+	database.UseColumns("presigned_uploads", presignedUploadColumns)
+}
+
+func scanPresignedUpload(row scanner) (models.PresignedUpload, error) {
+	var u models.PresignedUpload
+	var completedAt sql.NullTime
+	err := row.Scan(&u.ID, &u.ResourceType, &u.ResourceID, &u.Filename, &u.ContentType, &u.Size, &u.StorageKey,
+		&u.CreatedBy, &u.CreatedAt, &u.ExpiresAt, &completedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return u, ErrNotFound
+	}
+	if completedAt.Valid {
+		u.CompletedAt = &completedAt.Time
+	}
+	return u, err
+}
+
+func CreatePresignedUpload(ctx context.Context, u models.PresignedUpload) (models.PresignedUpload, error) {
+	return scanPresignedUpload(database.DB.QueryRowContext(ctx, `
+		INSERT INTO presigned_uploads (id, resource_type, resource_id, filename, content_type, size, storage_key, created_by, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING `+presignedUploadColumns,
+		u.ID, u.ResourceType, u.ResourceID, u.Filename, u.ContentType, u.Size, u.StorageKey, u.CreatedBy, clock.Now(), u.ExpiresAt))
+}
+
+// ClaimPresignedUpload marks a user's unexpired upload completed and
+// returns it, so only one request turns it into an attachment. It returns
+// ErrNotFound if the upload is not theirs, has expired, or was completed.
+func ClaimPresignedUpload(ctx context.Context, id string, userID int) (models.PresignedUpload, error) {
+	now := clock.Now()
+	return scanPresignedUpload(database.DB.QueryRowContext(ctx, `
+		UPDATE presigned_uploads SET completed_at = $3
+		WHERE id = $1 AND created_by = $2 AND completed_at IS NULL AND expires_at > $3
+		RETURNING `+presignedUploadColumns,
+		id, userID, now))
+}
+
+// ReleasePresignedUpload undoes a claim whose attachment could not be
+// created, so the client can fix the object and complete it again.
+func ReleasePresignedUpload(ctx context.Context, id string) error {
+	_, err := database.DB.ExecContext(ctx, "UPDATE presigned_uploads SET completed_at = NULL WHERE id = $1", id)
+	return err
+}
+
+// DeleteExpiredPresignedUploads deletes the uploads whose URLs expired
+// before now, queueing their staged objects for deletion, and returns how
+// many there were.
+func DeleteExpiredPresignedUploads(ctx context.Context, now time.Time) (int64, error) {
+	result, err := database.DB.ExecContext(ctx, "DELETE FROM presigned_uploads WHERE expires_at <= $1", now)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
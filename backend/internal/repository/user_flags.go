@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"pygorp/backend/internal/database"
+	"pygorp/backend/internal/models"
+	"pygorp/backend/internal/sqlb"
+)
+
+const (
+	userFlagColumns = "user_id, flag, reason, created_at"
+	flagUserQuery   = "INSERT INTO user_flags (user_id, flag, reason) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING"
+)
+
+// Flags set on users.
+const (
+	FlagDisposableEmail = "disposable_email"
+)
+
+func init() {
+	database.UseColumns("user_flags", userFlagColumns)
+}
+
+func scanUserFlag(row scanner) (models.UserFlag, error) {
+	var f models.UserFlag
+	err := row.Scan(&f.UserID, &f.Flag, &f.Reason, &f.CreatedAt)
+	return f, err
+}
+
+// FlagUser flags a user for review. Flagging again keeps the original
+// reason.
+func FlagUser(ctx context.Context, userID int, flag, reason string) error {
+	return database.WithStmt(ctx, flagUserQuery, func(stmt *sql.Stmt) error {
+		_, err := stmt.ExecContext(ctx, userID, flag, reason)
+		return err
+	})
+}
+
+// ListUserFlags returns a page of flags newest first, optionally only those
+// named flag.
+func ListUserFlags(ctx context.Context, flag string, page sqlb.Page) ([]models.UserFlag, error) {
+	q := sqlb.Select(userFlagColumns).From("user_flags").OrderBy("created_at DESC", "user_id").Page(page)
+	if flag != "" {
+		q.Where("flag = ?", flag)
+	}
+	query, args, err := q.Build()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := database.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	flags := []models.UserFlag{}
+	for rows.Next() {
+		f, err := scanUserFlag(rows)
+		if err != nil {
+			return nil, err
+		}
+		flags = append(flags, f)
+	}
+	return flags, rows.Err()
+}
+
+// ClearUserFlag removes a flag once the user has been reviewed.
+func ClearUserFlag(ctx context.Context, userID int, flag string) error {
+	return execAffectingOne(ctx, "DELETE FROM user_flags WHERE user_id = $1 AND flag = $2", userID, flag)
+}
@@ -0,0 +1,511 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"pygorp/backend/internal/clock"
+	"pygorp/backend/internal/database"
+	"pygorp/backend/internal/events"
+	"pygorp/backend/internal/models"
+	"pygorp/backend/internal/sqlb"
+	"pygorp/backend/internal/storage"
+
+	"github.com/lib/pq"
+)
+
+// ErrNotFound is returned when the requested row does not exist.
+var ErrNotFound = errors.New("not found")
+
+const userColumns = "id, email, name, avatar, attributes, created_at, updated_at, deletion_requested_at, purge_at"
+
+const (
+	getUserQuery     = "SELECT " + userColumns + " FROM users WHERE id = $1"
+	userByEmailQuery = "SELECT " + userColumns + " FROM users WHERE email = $1"
+	usersByIDsQuery  = "SELECT " + userColumns + " FROM users WHERE id = ANY($1)"
+	createUserQuery  = "INSERT INTO users (email, name, attributes, created_at, updated_at) VALUES ($1, $2, jsonb_strip_nulls($3), $4, $4) RETURNING " + userColumns
+	updateUserQuery  = "UPDATE users SET email = COALESCE($1, email), name = COALESCE($2, name), attributes = jsonb_strip_nulls(attributes || $4), updated_at = $5 " +
+		"WHERE id = $3 AND ($6::timestamptz[] IS NULL OR updated_at = ANY($6)) AND ($7::timestamptz IS NULL OR updated_at < $7) RETURNING " + userColumns
+	deleteUserQuery = "DELETE FROM users WHERE id = $1"
+	setAvatarQuery  = "UPDATE users SET avatar = $1, updated_at = $3 WHERE id = $2"
+
+	requestDeletionQuery = "UPDATE users SET deletion_requested_at = COALESCE(deletion_requested_at, $2), purge_at = COALESCE(purge_at, $3), " +
+		"deletion_requested_by = CASE WHEN deletion_requested_at IS NULL THEN $6 ELSE deletion_requested_by END " +
+		"WHERE id = $1 AND ($4::timestamptz[] IS NULL OR updated_at = ANY($4)) AND ($5::timestamptz IS NULL OR updated_at < $5) RETURNING " + userColumns
+	cancelDeletionQuery = "UPDATE users SET deletion_requested_at = NULL, purge_at = NULL, deletion_requested_by = NULL WHERE id = $1 AND purge_at IS NOT NULL RETURNING " + userColumns
+	purgeUsersQuery     = "DELETE FROM users WHERE purge_at <= $1 AND " + notHeld + " RETURNING id"
+
+	// The WHERE skips no-op updates; xmax is 0 only for freshly inserted rows.
+	upsertUserQuery = "INSERT INTO users (email, name, attributes, created_at, updated_at) VALUES ($1, $2, jsonb_strip_nulls($3), $4, $4) " +
+		"ON CONFLICT (email) DO UPDATE SET name = EXCLUDED.name, attributes = jsonb_strip_nulls(users.attributes || $3), updated_at = $4 " +
+		"WHERE (users.name, users.attributes) IS DISTINCT FROM (EXCLUDED.name, jsonb_strip_nulls(users.attributes || $3)) " +
+		"RETURNING " + userColumns + ", (xmax = 0)"
+)
+
+func init() {
+	database.UseColumns("users", userColumns)
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanUser(row scanner) (models.User, error) {
+	var user models.User
+	var avatar, attrs []byte
+	var deletionRequestedAt, purgeAt sql.NullTime
+	if err := row.Scan(&user.ID, &user.Email, &user.Name, &avatar, &attrs, &user.CreatedAt, &user.UpdatedAt, &deletionRequestedAt, &purgeAt); err != nil {
+		return user, err
+	}
+	if purgeAt.Valid {
+		user.Deletion = &models.Deletion{RequestedAt: deletionRequestedAt.Time, PurgeAt: purgeAt.Time}
+	}
+	if err := json.Unmarshal(attrs, &user.Attributes); err != nil {
+		return user, err
+	}
+	if avatar != nil {
+		var variants []models.AvatarVariant
+		if err := json.Unmarshal(avatar, &variants); err != nil {
+			return user, err
+		}
+		user.Avatar = models.NewAvatar(variants, objectURL)
+	}
+	return user, nil
+}
+
+// objectURL resolves a storage key to the URL clients fetch it from.
+func objectURL(key string) string {
+	store, err := storage.Default()
+	if err != nil {
+		return ""
+	}
+	return store.URL(key)
+}
+
+// UserQuery selects, orders, and pages the users returned by ListUsers.
+type UserQuery struct {
+	Filter models.UserFilter
+	// Sort holds ORDER BY terms from sqlb.ParseSort with UserSortFields.
+	// The default is newest first.
+	Sort []string
+	Page sqlb.Page
+}
+
+// UserSortFields are the fields users can be sorted by, keyed by API name.
+var UserSortFields = map[string]string{
+	"id":         "id",
+	"email":      "email",
+	"name":       "name",
+	"created_at": "created_at",
+	"updated_at": "updated_at",
+}
+
+// userFilter turns a filter into conditions. Empty fields are ignored.
+func userFilter(filter models.UserFilter) []sqlb.Expr {
+	var conds []sqlb.Expr
+	if filter.EmailDomain != "" {
+		conds = append(conds, sqlb.Cond("email ILIKE ?", "%@"+sqlb.EscapeLike(filter.EmailDomain)))
+	}
+	if filter.NameContains != "" {
+		conds = append(conds, sqlb.Cond("name ILIKE ?", "%"+sqlb.EscapeLike(filter.NameContains)+"%"))
+	}
+	if len(filter.Attributes) > 0 {
+		conds = append(conds, sqlb.Cond("attributes @> ?", attributesJSON(filter.Attributes)))
+	}
+	if filter.GroupID != 0 {
+		conds = append(conds, sqlb.Cond("id IN (SELECT user_id FROM group_members WHERE group_id = ?)", filter.GroupID))
+	}
+	if filter.TeamID != 0 {
+		conds = append(conds, sqlb.Cond("id IN (SELECT user_id FROM team_members WHERE team_id = ?)", filter.TeamID))
+	}
+	return conds
+}
+
+func ListUsers(ctx context.Context, uq UserQuery) ([]models.User, error) {
+	q := sqlb.Select(userColumns).From("users").Page(uq.Page)
+	for _, cond := range userFilter(uq.Filter) {
+		q.WhereExpr(cond)
+	}
+	if len(uq.Sort) > 0 {
+		q.OrderBy(uq.Sort...)
+	} else {
+		q.OrderBy("created_at DESC")
+	}
+	// Break ties so pages don't overlap.
+	q.OrderBy("id DESC")
+
+	query, args, err := q.Build()
+	if err != nil {
+		return nil, err
+	}
+
+	var users []models.User
+	err = database.WithReadStmt(ctx, query, func(stmt *sql.Stmt) error {
+		rows, err := stmt.QueryContext(ctx, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		users = nil
+		for rows.Next() {
+			user, err := scanUser(rows)
+			if err != nil {
+				return err
+			}
+			users = append(users, user)
+		}
+		return rows.Err()
+	})
+	return users, err
+}
+
+// CountUsers counts the users matching filter, ignoring paging.
+func CountUsers(ctx context.Context, filter models.UserFilter) (int, error) {
+	q := sqlb.Select("COUNT(*)").From("users")
+	for _, cond := range userFilter(filter) {
+		q.WhereExpr(cond)
+	}
+	query, args, err := q.Build()
+	if err != nil {
+		return 0, err
+	}
+
+	var total int
+	err = database.WithReadStmt(ctx, query, func(stmt *sql.Stmt) error {
+		return stmt.QueryRowContext(ctx, args...).Scan(&total)
+	})
+	return total, err
+}
+
+// CountUsersWhere counts the users matching a condition built elsewhere,
+// such as a compiled segment.
+func CountUsersWhere(ctx context.Context, where sqlb.Expr) (int, error) {
+	query, args, err := sqlb.Select("COUNT(*)").From("users").WhereExpr(where).Build()
+	if err != nil {
+		return 0, err
+	}
+
+	var total int
+	err = database.WithReadStmt(ctx, query, func(stmt *sql.Stmt) error {
+		return stmt.QueryRowContext(ctx, args...).Scan(&total)
+	})
+	return total, err
+}
+
+// ListUsersWhere returns a page of the users matching a condition, newest
+// first.
+func ListUsersWhere(ctx context.Context, where sqlb.Expr, page sqlb.Page) ([]models.User, error) {
+	query, args, err := sqlb.Select(userColumns).From("users").WhereExpr(where).
+		OrderBy("created_at DESC", "id DESC").Page(page).Build()
+	if err != nil {
+		return nil, err
+	}
+
+	var users []models.User
+	err = database.WithReadStmt(ctx, query, func(stmt *sql.Stmt) error {
+		rows, err := stmt.QueryContext(ctx, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		users = []models.User{}
+		for rows.Next() {
+			user, err := scanUser(rows)
+			if err != nil {
+				return err
+			}
+			users = append(users, user)
+		}
+		return rows.Err()
+	})
+	return users, err
+}
+
+func GetUser(ctx context.Context, id int) (models.User, error) {
+	var user models.User
+	err := database.WithStmt(ctx, getUserQuery, func(stmt *sql.Stmt) error {
+		var err error
+		user, err = scanUser(stmt.QueryRowContext(ctx, id))
+		return err
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return user, ErrNotFound
+	}
+	return user, err
+}
+
+// GetUserByEmail looks a user up by exact email address.
+func GetUserByEmail(ctx context.Context, email string) (models.User, error) {
+	var user models.User
+	err := database.WithStmt(ctx, userByEmailQuery, func(stmt *sql.Stmt) error {
+		var err error
+		user, err = scanUser(stmt.QueryRowContext(ctx, email))
+		return err
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return user, ErrNotFound
+	}
+	return user, err
+}
+
+// UsersByIDs fetches several users in one query, keyed by ID.
+func UsersByIDs(ctx context.Context, ids []int) (map[int]models.User, error) {
+	users := make(map[int]models.User, len(ids))
+	err := database.WithStmt(ctx, usersByIDsQuery, func(stmt *sql.Stmt) error {
+		rows, err := stmt.QueryContext(ctx, pq.Array(ids))
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			user, err := scanUser(rows)
+			if err != nil {
+				return err
+			}
+			users[user.ID] = user
+		}
+		return rows.Err()
+	})
+	return users, err
+}
+
+func CreateUser(ctx context.Context, req models.CreateUserRequest) (models.User, error) {
+	var user models.User
+	err := database.WithStmt(ctx, createUserQuery, func(stmt *sql.Stmt) error {
+		var err error
+		user, err = scanUser(stmt.QueryRowContext(ctx, req.Email, req.Name, attributesJSON(req.Attributes), clock.Now()))
+		return err
+	})
+	if err == nil {
+		events.Publish(ctx, events.UserCreated, 1, events.UserCreatedV1{ID: user.ID, Email: user.Email, Name: user.Name})
+	}
+	return user, err
+}
+
+// attributesJSON encodes attributes for a JSONB parameter, treating a nil
+// map as empty.
+func attributesJSON(attrs map[string]interface{}) []byte {
+	if attrs == nil {
+		return []byte("{}")
+	}
+	data, _ := json.Marshal(attrs)
+	return data
+}
+
+// Upsert outcomes.
+const (
+	UpsertCreated   = "created"
+	UpsertUpdated   = "updated"
+	UpsertUnchanged = "unchanged"
+)
+
+// withInserted appends the "(xmax = 0)" column to a row scanned by scanUser.
+type withInserted struct {
+	row      scanner
+	inserted *bool
+}
+
+func (w withInserted) Scan(dest ...interface{}) error {
+	return w.row.Scan(append(dest, w.inserted)...)
+}
+
+// UpsertUser creates the user with req.Email or updates its name, and
+// reports which happened.
+func UpsertUser(ctx context.Context, req models.CreateUserRequest) (models.User, string, error) {
+	var user models.User
+	var inserted bool
+	err := database.WithStmt(ctx, upsertUserQuery, func(stmt *sql.Stmt) error {
+		var err error
+		user, err = scanUser(withInserted{stmt.QueryRowContext(ctx, req.Email, req.Name, attributesJSON(req.Attributes), clock.Now()), &inserted})
+		return err
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		// The row exists with the same values.
+		user, err = GetUserByEmail(ctx, req.Email)
+		return user, UpsertUnchanged, err
+	}
+	if err != nil {
+		return user, "", err
+	}
+
+	if inserted {
+		events.Publish(ctx, events.UserCreated, 1, events.UserCreatedV1{ID: user.ID, Email: user.Email, Name: user.Name})
+		return user, UpsertCreated, nil
+	}
+	fields := []string{"name"}
+	if len(req.Attributes) > 0 {
+		fields = append(fields, "attributes")
+	}
+	events.Publish(ctx, events.UserUpdated, 2, events.UserUpdatedV2{ID: user.ID, Fields: fields})
+	return user, UpsertUpdated, nil
+}
+
+// UpdateUser applies req to a user whose version pre allows.
+func UpdateUser(ctx context.Context, id int, req models.UpdateUserRequest, pre Precondition) (models.User, error) {
+	var user models.User
+	versions, before := pre.args()
+	err := database.WithStmt(ctx, updateUserQuery, func(stmt *sql.Stmt) error {
+		var err error
+		user, err = scanUser(stmt.QueryRowContext(ctx, req.Email, req.Name, id, attributesJSON(req.Attributes), clock.Now(), versions, before))
+		return err
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return user, notUpdated(ctx, id, pre)
+	}
+	if err == nil {
+		var fields []string
+		if req.Email != "" {
+			fields = append(fields, "email")
+		}
+		if req.Name != "" {
+			fields = append(fields, "name")
+		}
+		if len(req.Attributes) > 0 {
+			fields = append(fields, "attributes")
+		}
+		if len(fields) > 0 {
+			events.Publish(ctx, events.UserUpdated, 2, events.UserUpdatedV2{ID: id, Fields: fields})
+		}
+	}
+	return user, err
+}
+
+// DeleteUser deletes a user for good. Users under legal hold are refused
+// with ErrLegalHold.
+func DeleteUser(ctx context.Context, id int) error {
+	var affected int64
+	err := database.WithStmt(ctx, deleteUserQuery, func(stmt *sql.Stmt) error {
+		result, err := stmt.ExecContext(ctx, id)
+		if err != nil {
+			return err
+		}
+		affected, err = result.RowsAffected()
+		return err
+	})
+	if err != nil {
+		return legalHoldError(err)
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	events.Publish(ctx, events.UserDeleted, 1, events.UserDeletedV1{ID: id})
+	return nil
+}
+
+// ErrNotPendingDeletion is returned when cancelling the deletion of a user
+// whose deletion was never requested.
+var ErrNotPendingDeletion = errors.New("user is not pending deletion")
+
+// RequestUserDeletion marks a user whose version pre allows for deletion
+// after grace. by is the user asking. Requesting it again keeps the
+// original schedule and requester. Users under legal hold cannot be
+// deleted.
+func RequestUserDeletion(ctx context.Context, id, by int, grace time.Duration, pre Precondition) (models.User, error) {
+	var user models.User
+	held, err := UnderLegalHold(ctx, id)
+	if err != nil {
+		return user, err
+	}
+	if held {
+		return user, ErrLegalHold
+	}
+	now := clock.Now()
+	versions, before := pre.args()
+	err = database.WithStmt(ctx, requestDeletionQuery, func(stmt *sql.Stmt) error {
+		var err error
+		user, err = scanUser(stmt.QueryRowContext(ctx, id, now, now.Add(grace), versions, before, nullableUserID(by)))
+		return err
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return user, notUpdated(ctx, id, pre)
+	}
+	return user, err
+}
+
+// notUpdated tells why a conditional write to a user touched no row:
+// ErrNotFound when there is no such user, ErrPreconditionFailed when pre
+// did not allow its version.
+func notUpdated(ctx context.Context, id int, pre Precondition) error {
+	if pre.IsZero() {
+		return ErrNotFound
+	}
+	if _, err := GetUser(ctx, id); err != nil {
+		return err
+	}
+	return ErrPreconditionFailed
+}
+
+// CancelUserDeletion restores a user pending deletion.
+func CancelUserDeletion(ctx context.Context, id int) (models.User, error) {
+	var user models.User
+	err := database.WithStmt(ctx, cancelDeletionQuery, func(stmt *sql.Stmt) error {
+		var err error
+		user, err = scanUser(stmt.QueryRowContext(ctx, id))
+		return err
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		if _, err := GetUser(ctx, id); err != nil {
+			return user, err
+		}
+		return user, ErrNotPendingDeletion
+	}
+	return user, err
+}
+
+// PurgeDeletedUsers deletes the users whose grace period has ended and
+// returns their IDs.
+func PurgeDeletedUsers(ctx context.Context) ([]int, error) {
+	rows, err := database.DB.QueryContext(ctx, purgeUsersQuery, clock.Now())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		events.Publish(ctx, events.UserDeleted, 1, events.UserDeletedV1{ID: id})
+	}
+	return ids, nil
+}
+
+// SetUserAvatar replaces the stored avatar variants for a user.
+func SetUserAvatar(ctx context.Context, id int, variants []models.AvatarVariant) error {
+	data, err := json.Marshal(variants)
+	if err != nil {
+		return err
+	}
+
+	var affected int64
+	err = database.WithStmt(ctx, setAvatarQuery, func(stmt *sql.Stmt) error {
+		result, err := stmt.ExecContext(ctx, data, id, clock.Now())
+		if err != nil {
+			return err
+		}
+		affected, err = result.RowsAffected()
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	events.Publish(ctx, events.UserUpdated, 2, events.UserUpdatedV2{ID: id, Fields: []string{"avatar"}})
+	return nil
+}
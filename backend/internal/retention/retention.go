@@ -0,0 +1,70 @@
+// Package retention purges data whose retention period has ended. Its jobs
+// are scheduled on the default queue, so any worker processing that queue
+// runs them.
+package retention
+
+import (
+	"context"
+	"log"
+	"os"
+	"time"
+
+	"pygorp/backend/internal/jobs"
+	"pygorp/backend/internal/repository"
+)
+
+// Jobs purging deleted resources whose grace period has ended.
+const (
+	PurgeUsersJob    = "users.purge"
+	PurgeProjectsJob = "projects.purge"
+)
+
+func init() {
+	jobs.Register(PurgeUsersJob, purgeUsers)
+	jobs.Every(PurgeUsersJob, jobs.DefaultQueue, time.Hour)
+	jobs.Register(PurgeProjectsJob, purgeProjects)
+	jobs.Every(PurgeProjectsJob, jobs.DefaultQueue, time.Hour)
+}
+
+// DeletionGrace is how long a user whose deletion was requested is kept
+// and can be restored, read from USER_DELETION_GRACE and defaulting to 30
+// days.
+func DeletionGrace() time.Duration {
+	return grace("USER_DELETION_GRACE")
+}
+
+// ProjectDeletionGrace is how long a deleted project stays in the trash and
+// can be restored, read from PROJECT_DELETION_GRACE and defaulting to 30
+// days.
+func ProjectDeletionGrace() time.Duration {
+	return grace("PROJECT_DELETION_GRACE")
+}
+
+func grace(key string) time.Duration {
+	if d, err := time.ParseDuration(os.Getenv(key)); err == nil && d >= 0 {
+		return d
+	}
+	return 30 * 24 * time.Hour
+}
+
+func purgeUsers(ctx context.Context, job *jobs.Job) error {
+	ids, err := repository.PurgeDeletedUsers(ctx)
+	if err != nil {
+		return err
+	}
+	if len(ids) > 0 {
+		log.Printf("Purged %d users after their deletion grace period", len(ids))
+	}
+	return nil
+}
+
+func purgeProjects(ctx context.Context, job *jobs.Job) error {
+	ids, err := repository.PurgeDeletedProjects(ctx)
+	if err != nil {
+		return err
+	}
+	if len(ids) > 0 {
+		log.Printf("Purged %d projects after their time in the trash", len(ids))
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package retention
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"pygorp/backend/internal/clock"
+	"pygorp/backend/internal/config"
+	"pygorp/backend/internal/jobs"
+	"pygorp/backend/internal/metrics"
+	"pygorp/backend/internal/repository"
+)
+
+// ApplyRulesJob applies the enabled retention rules of the runtime config.
+const ApplyRulesJob = "retention.apply"
+
+// ruleBatch is how many records a rule handles per statement, so a rule
+// that has fallen far behind does not hold locks for long.
+const ruleBatch = 1000
+
+// previewSample is how many affected records a preview names.
+const previewSample = 10
+
+func init() {
+	jobs.Register(ApplyRulesJob, applyRules)
+	jobs.Every(ApplyRulesJob, jobs.DefaultQueue, time.Hour)
+}
+
+// Preview is what a retention rule affects as of now.
+type Preview struct {
+	config.RetentionRule
+	// Cutoff is the time records must be older than to be affected.
+	Cutoff time.Time `json:"cutoff"`
+	// Affected is how many records the rule would purge or anonymize, and
+	// Sample names up to ten of them.
+	Affected int      `json:"affected"`
+	Sample   []string `json:"sample"`
+}
+
+// PreviewRules previews every rule of the runtime config, enabled or not.
+func PreviewRules(ctx context.Context) ([]Preview, error) {
+	rules := config.Current().Retention
+	previews := make([]Preview, 0, len(rules))
+	for _, rule := range rules {
+		p := Preview{RetentionRule: rule, Cutoff: clock.Now().Add(-rule.AfterDuration())}
+		var err error
+		p.Affected, p.Sample, err = repository.PreviewRetention(ctx, rule.Target, p.Cutoff, previewSample)
+		if err != nil {
+			return nil, err
+		}
+		previews = append(previews, p)
+	}
+	return previews, nil
+}
+
+func applyRules(ctx context.Context, job *jobs.Job) error {
+	for _, rule := range config.Current().Retention {
+		if !rule.Enabled {
+			continue
+		}
+		cutoff := clock.Now().Add(-rule.AfterDuration())
+		total := 0
+		for {
+			n, err := repository.ApplyRetention(ctx, rule.Target, cutoff, ruleBatch)
+			if err != nil {
+				return err
+			}
+			total += n
+			metrics.RetentionRecords.WithLabelValues(rule.Name, rule.Action).Add(float64(n))
+			if n < ruleBatch {
+				break
+			}
+		}
+		if total > 0 {
+			log.Printf("Retention rule %s: %sd %d %s older than %s", rule.Name, rule.Action, total, rule.Target, rule.After)
+		}
+	}
+	return nil
+}
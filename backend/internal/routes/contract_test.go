@@ -0,0 +1,151 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"pygorp/backend/internal/config"
+	"pygorp/backend/internal/openapi"
+
+	"github.com/gin-gonic/gin"
+)
+
+// exchange is a response recorded by recordResponses.
+type exchange struct {
+	method, path string
+	status       int
+	contentType  string
+	body         []byte
+}
+
+// recordResponses is the contract tests' response-recording middleware: it
+// passes each request to next and keeps a copy of the response.
+func recordResponses(next http.Handler, record func(exchange)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := httptest.NewRecorder()
+		next.ServeHTTP(rec, r)
+		record(exchange{
+			method:      r.Method,
+			path:        r.URL.Path,
+			status:      rec.Code,
+			contentType: rec.Header().Get("Content-Type"),
+			body:        rec.Body.Bytes(),
+		})
+		for key, values := range rec.Header() {
+			w.Header()[key] = values
+		}
+		w.WriteHeader(rec.Code)
+		w.Write(rec.Body.Bytes())
+	})
+}
+
+// TestContract sends requests that need no database through the router and
+// checks every response against the OpenAPI document, failing on status
+// codes, media types, or bodies the document does not describe. Every
+// operation whose responses the overlay describes must be exercised.
+func TestContract(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("ADMIN_TOKEN", "contract-test")
+	if err := config.Init(); err != nil {
+		t.Fatal(err)
+	}
+	spec, err := openapi.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var recorded []exchange
+	handler := recordResponses(NewRouter(), func(e exchange) { recorded = append(recorded, e) })
+	for _, req := range []struct {
+		method, path, token string
+	}{
+		{http.MethodGet, "/health", ""},
+		{http.MethodGet, "/api/v1/ping", ""},
+		{http.MethodGet, "/api/v1/version", ""},
+		{http.MethodGet, "/api/v1/users/abc", ""},
+		{http.MethodGet, "/api/v1/operations/not-an-operation", ""},
+		{http.MethodGet, "/admin/routes", "contract-test"},
+		{http.MethodGet, "/admin/routes", "wrong"},
+	} {
+		r := httptest.NewRequest(req.method, req.path, nil)
+		if req.token != "" {
+			r.Header.Set("Authorization", "Bearer "+req.token)
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	exercised := map[*openapi.Operation]bool{}
+	for _, e := range recorded {
+		template, op, ok := spec.Find(e.method, e.path)
+		if !ok {
+			t.Errorf("%s %s is not documented", e.method, e.path)
+			continue
+		}
+		exercised[op] = true
+		if err := op.Check(e.status, e.contentType, e.body); err != nil {
+			t.Errorf("%s %s (%s): %v\n%s", e.method, e.path, template, err, e.body)
+		}
+	}
+	for path, methods := range spec.Paths {
+		for method, op := range methods {
+			if op.Documented() && !exercised[op] {
+				t.Errorf("%s %s is documented but no contract test request reaches it", strings.ToUpper(method), path)
+			}
+		}
+	}
+}
+
+// TestContractRoutes checks that every route in the table has an operation
+// in the OpenAPI document with its name, method, path and scopes, and that
+// the document has no other operations.
+func TestContractRoutes(t *testing.T) {
+	spec, err := openapi.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	documented := map[string]string{}
+	scopes := map[string][]string{}
+	for path, methods := range spec.Paths {
+		for method, op := range methods {
+			documented[op.OperationID] = strings.ToUpper(method) + " " + path
+			for _, requirement := range op.Security {
+				for _, s := range requirement {
+					scopes[op.OperationID] = append(scopes[op.OperationID], s...)
+				}
+			}
+		}
+	}
+	for _, info := range List() {
+		got, ok := documented[info.Name]
+		if !ok {
+			t.Errorf("route %s (%s %s) has no operation in the OpenAPI document", info.Name, info.Method, info.Path)
+			continue
+		}
+		delete(documented, info.Name)
+		if want := info.Method + " " + openapi.Path(info.Path); got != want {
+			t.Errorf("route %s is %s, documented as %s", info.Name, want, got)
+		}
+		if got := scopes[info.Name]; len(info.Scopes) > 0 && !slices.Equal(got, info.Scopes) {
+			t.Errorf("route %s requires scopes %q, documented as %q", info.Name, info.Scopes, got)
+		}
+	}
+	for name, op := range documented {
+		t.Errorf("%s is documented as %q but there is no such route", op, name)
+	}
+}
+
+// TestOpenAPIUpToDate checks that the checked-in OpenAPI document is what
+// the route table generates.
+func TestOpenAPIUpToDate(t *testing.T) {
+	doc, err := OpenAPI()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(doc) != string(openapi.Document) {
+		t.Error("internal/openapi/openapi.json is out of date; run `go run . routes --openapi > internal/openapi/openapi.json`")
+	}
+}
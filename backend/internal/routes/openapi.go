@@ -0,0 +1,73 @@
+package routes
+
+import (
+	"slices"
+
+	"pygorp/backend/internal/openapi"
+)
+
+// securitySchemes maps each authentication middleware to the OpenAPI
+// security scheme describing the credentials it accepts.
+var securitySchemes = map[string]string{
+	"authenticate": "accessToken",
+	"admin_auth":   "adminToken",
+	"scim_auth":    "scimToken",
+	"service_auth": "serviceToken",
+}
+
+// OpenAPI generates the OpenAPI document for the route table, with one
+// operation per route. The checked-in openapi.Document is its output.
+func OpenAPI() ([]byte, error) {
+	list := List()
+	spec := make([]openapi.Route, 0, len(list))
+	for _, r := range list {
+		route := openapi.Route{
+			Name:        r.Name,
+			Method:      r.Method,
+			Path:        r.Path,
+			Security:    security(r),
+			Scopes:      r.Scopes,
+			Deprecation: openAPIDeprecation(r.Deprecation),
+		}
+		for field, d := range r.DeprecatedFields {
+			if route.DeprecatedFields == nil {
+				route.DeprecatedFields = map[string]openapi.Deprecation{}
+			}
+			route.DeprecatedFields[field] = *openAPIDeprecation(d)
+		}
+		spec = append(spec, route)
+	}
+	return openapi.Generate(spec)
+}
+
+// security returns the security scheme a route requires, if any. Admin,
+// SCIM and service credentials are always required; access tokens only
+// when the route requires scopes, since Authenticate lets anonymous callers
+// through.
+func security(r Info) string {
+	for _, m := range r.Middleware {
+		scheme, ok := securitySchemes[m]
+		if !ok {
+			continue
+		}
+		if m == "authenticate" && !slices.Contains(r.Middleware, "require_scopes") {
+			return ""
+		}
+		return scheme
+	}
+	return ""
+}
+
+func openAPIDeprecation(d *DeprecationInfo) *openapi.Deprecation {
+	if d == nil {
+		return nil
+	}
+	out := &openapi.Deprecation{Replacement: d.Replacement}
+	if d.Since != nil {
+		out.Since = *d.Since
+	}
+	if d.Sunset != nil {
+		out.Sunset = *d.Sunset
+	}
+	return out
+}
@@ -0,0 +1,394 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"pygorp/backend/internal/hal"
+	"pygorp/backend/internal/loadshed"
+	"pygorp/backend/internal/middleware"
+	"pygorp/backend/internal/render"
+	"pygorp/backend/internal/web"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Rate-limit classes. Limits per class are configured in the runtime config.
+const (
+	RateLimitDefault = "default"
+	RateLimitWrite   = "write"
+	RateLimitAuth    = "auth"
+	RateLimitNone    = "none"
+)
+
+// Route describes a single endpoint. The same description drives route
+// registration, the route listing, and per-route policies.
+type Route struct {
+	Name    string
+	Method  string
+	Path    string
+	Handler gin.HandlerFunc
+	Scopes  []string
+	// SignedIn rejects anonymous callers like Scopes does, for routes any
+	// authenticated caller may use.
+	SignedIn       bool
+	RateLimitClass string
+	// Priority decides which routes are shed first under load: one of the
+	// loadshed priorities, defaulting to the group's, then normal.
+	Priority    string
+	Deprecation *Deprecation
+	// DeprecatedFields are deprecated query parameters and top-level JSON
+	// body fields, by name. Requests using one are answered with the
+	// deprecation headers.
+	DeprecatedFields map[string]Deprecation
+	// LogBody adds the request headers and body, scrubbed of emails and
+	// credentials, to the route's request log records.
+	LogBody bool
+	// Atomic routes make all their writes through the request transaction,
+	// so they may run in an atomic batch. Reads always may.
+	Atomic bool
+	// Resource is the type of the resources the route returns, used for
+	// JSON:API responses. It defaults to the last fixed segment of the path,
+	// e.g. "users" for /users/:id.
+	Resource string
+}
+
+// Deprecation marks a route or field as deprecated, optionally with a removal
+// date and a replacement: a route name for routes, a field name for fields.
+type Deprecation struct {
+	Since       time.Time
+	Sunset      time.Time
+	Replacement string
+}
+
+// Middleware is a named middleware constructor. Construction is deferred so
+// that listing routes does not start middleware background work.
+type Middleware struct {
+	Name string
+	New  func() gin.HandlerFunc
+}
+
+// Group is a set of routes sharing a path prefix and middleware chain.
+// RateLimitClass and Priority apply to routes that do not declare their
+// own. Authorize checks every route in the group against the policy engine.
+// A route's Scopes are required in every group, so the group's
+// authentication middleware must set the granted scopes.
+type Group struct {
+	Prefix         string
+	Middleware     []Middleware
+	RateLimitClass string
+	Priority       string
+	Authorize      bool
+	Routes         []Route
+}
+
+// Info is the printable form of a registered route.
+type Info struct {
+	Name           string           `json:"name"`
+	Method         string           `json:"method"`
+	Path           string           `json:"path"`
+	Handler        string           `json:"handler"`
+	Middleware     []string         `json:"middleware"`
+	Scopes         []string         `json:"scopes"`
+	RateLimitClass string           `json:"rate_limit_class"`
+	Priority       string           `json:"priority"`
+	Deprecation    *DeprecationInfo `json:"deprecation,omitempty"`
+	// DeprecatedFields are the route's deprecated request fields.
+	DeprecatedFields map[string]*DeprecationInfo `json:"deprecated_fields,omitempty"`
+	Atomic           bool                        `json:"atomic,omitempty"`
+}
+
+type DeprecationInfo struct {
+	Since       *time.Time `json:"since,omitempty"`
+	Sunset      *time.Time `json:"sunset,omitempty"`
+	Replacement string     `json:"replacement,omitempty"`
+}
+
+// router is the engine NewRouter built last, through which batch requests
+// are sent.
+var router atomic.Pointer[gin.Engine]
+
+func dispatch(w http.ResponseWriter, r *http.Request) {
+	router.Load().ServeHTTP(w, r)
+}
+
+// NewRouter builds the Gin engine from the route table.
+func NewRouter() *gin.Engine {
+	r := gin.New()
+	router.Store(r)
+	for _, m := range globalMiddleware() {
+		r.Use(m.New())
+	}
+
+	// One limiter per class across all groups, so a client's budget covers
+	// every route in the class wherever it is mounted.
+	limiters := map[string]gin.HandlerFunc{}
+	for _, g := range groups() {
+		rg := r.Group(g.Prefix)
+		for _, m := range g.Middleware {
+			rg.Use(m.New())
+		}
+
+		for _, route := range g.Routes {
+			route.RateLimitClass = rateLimitClass(g, route)
+			route.Priority = priority(g, route)
+
+			var chain []gin.HandlerFunc
+			for _, m := range policies(g, route) {
+				if m.Name == "rate_limit:"+route.RateLimitClass {
+					if _, ok := limiters[route.RateLimitClass]; !ok {
+						limiters[route.RateLimitClass] = m.New()
+					}
+					chain = append(chain, limiters[route.RateLimitClass])
+					continue
+				}
+				chain = append(chain, m.New())
+			}
+			chain = append(chain, route.Handler)
+			rg.Handle(route.Method, route.Path, chain...)
+		}
+	}
+
+	if web.Enabled() {
+		r.NoRoute(web.Handler())
+	}
+	return r
+}
+
+// policies returns the per-route middleware derived from route metadata.
+func policies(g Group, route Route) []Middleware {
+	name, priority, resource, group := route.Name, route.Priority, resourceType(route), g.Prefix
+	policies := []Middleware{
+		{Name: "slo", New: func() gin.HandlerFunc {
+			return middleware.TrackSLO(group)
+		}},
+		{Name: "load_shed", New: func() gin.HandlerFunc {
+			return middleware.LoadShed(name, priority)
+		}},
+		{Name: "route_name", New: func() gin.HandlerFunc {
+			return func(c *gin.Context) {
+				c.Set("route_name", name)
+				c.Set("resource_type", resource)
+				c.Next()
+			}
+		}},
+	}
+
+	if route.RateLimitClass != RateLimitNone {
+		class := route.RateLimitClass
+		policies = append(policies, Middleware{Name: "rate_limit:" + class, New: func() gin.HandlerFunc {
+			return middleware.RateLimit(class)
+		}})
+	}
+
+	if scopes := route.Scopes; len(scopes) > 0 || route.SignedIn {
+		policies = append(policies, Middleware{Name: "require_scopes", New: func() gin.HandlerFunc {
+			return middleware.RequireScopes(scopes...)
+		}})
+	}
+	if g.Authorize {
+		policies = append(policies, Middleware{Name: "authorize", New: func() gin.HandlerFunc {
+			return middleware.Authorize(name)
+		}})
+	}
+
+	if route.LogBody {
+		policies = append(policies, Middleware{Name: "log_body", New: middleware.LogBody})
+	}
+
+	if route.Deprecation != nil || len(route.DeprecatedFields) > 0 {
+		d, fields := route.Deprecation, route.DeprecatedFields
+		policies = append(policies, Middleware{Name: "deprecation", New: func() gin.HandlerFunc {
+			var notice *middleware.DeprecationNotice
+			if d != nil {
+				notice = &middleware.DeprecationNotice{Since: d.Since, Sunset: d.Sunset, Successor: routePath(d.Replacement)}
+			}
+			notices := make(map[string]middleware.DeprecationNotice, len(fields))
+			for field, f := range fields {
+				notices[field] = middleware.DeprecationNotice{Since: f.Since, Sunset: f.Sunset}
+			}
+			return middleware.Deprecation(name, notice, notices)
+		}})
+	}
+	return policies
+}
+
+// List returns every route in the table with its full middleware chain.
+func List() []Info {
+	var global []string
+	for _, m := range globalMiddleware() {
+		global = append(global, m.Name)
+	}
+
+	var infos []Info
+	for _, g := range groups() {
+		groupChain := append([]string{}, global...)
+		for _, m := range g.Middleware {
+			groupChain = append(groupChain, m.Name)
+		}
+		for _, route := range g.Routes {
+			route.RateLimitClass = rateLimitClass(g, route)
+			route.Priority = priority(g, route)
+
+			chain := append([]string{}, groupChain...)
+			for _, m := range policies(g, route) {
+				chain = append(chain, m.Name)
+			}
+			scopes := route.Scopes
+			if scopes == nil {
+				scopes = []string{}
+			}
+			info := Info{
+				Name:           route.Name,
+				Method:         route.Method,
+				Path:           joinPath(g.Prefix, route.Path),
+				Handler:        runtime.FuncForPC(reflect.ValueOf(route.Handler).Pointer()).Name(),
+				Middleware:     chain,
+				Scopes:         scopes,
+				RateLimitClass: route.RateLimitClass,
+				Priority:       route.Priority,
+				Deprecation:    deprecationInfo(route.Deprecation),
+				Atomic:         route.Atomic,
+			}
+			for field, d := range route.DeprecatedFields {
+				if info.DeprecatedFields == nil {
+					info.DeprecatedFields = map[string]*DeprecationInfo{}
+				}
+				info.DeprecatedFields[field] = deprecationInfo(&d)
+			}
+			infos = append(infos, info)
+		}
+	}
+	return infos
+}
+
+// links returns the GET routes of the table as HAL link templates.
+// Deprecated routes are left out so clients are not led to them.
+func links() hal.Registry {
+	registry := hal.Registry{}
+	for _, g := range groups() {
+		for _, route := range g.Routes {
+			if route.Method == http.MethodGet && route.Deprecation == nil {
+				registry.Add(joinPath(g.Prefix, route.Path))
+			}
+		}
+	}
+	return registry
+}
+
+var (
+	atomicOnce   sync.Once
+	atomicRouter *gin.Engine
+)
+
+// newAtomicRouter builds a router holding only the Atomic routes, each
+// answering 204, so requests are matched to them the way the real router
+// would.
+func newAtomicRouter() *gin.Engine {
+	r := gin.New()
+	for _, g := range groups() {
+		for _, route := range g.Routes {
+			if route.Atomic {
+				r.Handle(route.Method, joinPath(g.Prefix, route.Path), func(c *gin.Context) {
+					c.Status(http.StatusNoContent)
+				})
+			}
+		}
+	}
+	return r
+}
+
+// isAtomic reports whether a request is handled by an Atomic route.
+func isAtomic(method, path string) bool {
+	req, err := http.NewRequest(method, path, nil)
+	if err != nil {
+		return false
+	}
+	atomicOnce.Do(func() { atomicRouter = newAtomicRouter() })
+	w := httptest.NewRecorder()
+	atomicRouter.ServeHTTP(w, req)
+	return w.Code == http.StatusNoContent
+}
+
+// routePath returns the full path of the named route, or "" when there is
+// none.
+func routePath(name string) string {
+	for _, g := range groups() {
+		for _, route := range g.Routes {
+			if name != "" && route.Name == name {
+				return joinPath(g.Prefix, route.Path)
+			}
+		}
+	}
+	return ""
+}
+
+func listRoutes(c *gin.Context) {
+	render.JSON(c, http.StatusOK, gin.H{"data": List()})
+}
+
+func rateLimitClass(g Group, route Route) string {
+	if route.RateLimitClass != "" {
+		return route.RateLimitClass
+	}
+	if g.RateLimitClass != "" {
+		return g.RateLimitClass
+	}
+	return RateLimitDefault
+}
+
+func priority(g Group, route Route) string {
+	if route.Priority != "" {
+		return route.Priority
+	}
+	if g.Priority != "" {
+		return g.Priority
+	}
+	return loadshed.PriorityNormal
+}
+
+func deprecationInfo(d *Deprecation) *DeprecationInfo {
+	if d == nil {
+		return nil
+	}
+	info := &DeprecationInfo{Replacement: d.Replacement}
+	if !d.Since.IsZero() {
+		info.Since = &d.Since
+	}
+	if !d.Sunset.IsZero() {
+		info.Sunset = &d.Sunset
+	}
+	return info
+}
+
+// resourceType returns the route's Resource, or the last segment of its
+// path that is not a parameter, up to any custom method (users:method).
+func resourceType(route Route) string {
+	if route.Resource != "" {
+		return route.Resource
+	}
+	segments := strings.Split(strings.Trim(route.Path, "/"), "/")
+	for i := len(segments) - 1; i >= 0; i-- {
+		if s := segments[i]; s != "" && !strings.HasPrefix(s, ":") && !strings.HasPrefix(s, "*") {
+			s, _, _ = strings.Cut(s, ":")
+			return s
+		}
+	}
+	return ""
+}
+
+func joinPath(prefix, path string) string {
+	if path == "" {
+		return prefix
+	}
+	if prefix == "/" {
+		return path
+	}
+	return prefix + path
+}
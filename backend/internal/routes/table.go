@@ -0,0 +1,398 @@
+package routes
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"pygorp/backend/internal/batch"
+	"pygorp/backend/internal/config"
+	"pygorp/backend/internal/hal"
+	"pygorp/backend/internal/handlers"
+	"pygorp/backend/internal/jsonapi"
+	"pygorp/backend/internal/loadshed"
+	"pygorp/backend/internal/metrics"
+	"pygorp/backend/internal/middleware"
+	"pygorp/backend/internal/pii"
+	"pygorp/backend/internal/problem"
+	"pygorp/backend/internal/proxy"
+	"pygorp/backend/internal/render"
+	"pygorp/backend/internal/svcauth"
+
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+)
+
+func globalMiddleware() []Middleware {
+	return []Middleware{
+		{Name: "request_id", New: middleware.RequestID},
+		{Name: "served_by", New: middleware.ServedBy},
+		{Name: "logger", New: middleware.RequestLogger},
+		{Name: "access_log", New: middleware.AccessLog},
+		{Name: "problem", New: problem.Middleware},
+		{Name: "json_api", New: jsonapi.Middleware},
+		{Name: "hal", New: func() gin.HandlerFunc { return hal.Middleware(links()) }},
+		{Name: "errors", New: middleware.Errors},
+		{Name: "audit", New: middleware.Audit},
+		{Name: "cors", New: newCORS},
+		{Name: "read_only", New: middleware.RejectWritesWhenReadOnly},
+	}
+}
+
+// groups is the central route table. Add new endpoints here.
+func groups() []Group {
+	return []Group{
+		{
+			Prefix:         "/",
+			RateLimitClass: RateLimitNone,
+			Priority:       loadshed.PriorityCritical,
+			Routes: []Route{
+				{Name: "health", Method: http.MethodGet, Path: "/health", Handler: handlers.Health},
+				{Name: "ready", Method: http.MethodGet, Path: "/readyz", Handler: handlers.Ready},
+				{Name: "metrics", Method: http.MethodGet, Path: "/metrics", Handler: metrics.Handler()},
+				{Name: "media", Method: http.MethodGet, Path: "/media/*key", Handler: handlers.ServeMedia, Priority: loadshed.PriorityNormal},
+				{Name: "previews.get", Method: http.MethodGet, Path: "/previews/:token", Handler: handlers.ServePreview, Priority: loadshed.PriorityNormal},
+				{Name: "problems.list", Method: http.MethodGet, Path: "/problems", Handler: handlers.ListProblemTypes},
+				{Name: "problems.get", Method: http.MethodGet, Path: "/problems/:code", Handler: handlers.GetProblemType},
+			},
+		},
+		{
+			Prefix:    "/api/v1",
+			Authorize: true,
+			Middleware: []Middleware{
+				{Name: "authenticate", New: middleware.Authenticate},
+				{Name: "tenant", New: middleware.Tenant},
+				{Name: "consent", New: func() gin.HandlerFunc { return middleware.RequireConsent("/api/v1") }},
+				{Name: "pii", New: pii.Middleware},
+			},
+			Routes: []Route{
+				{Name: "ping", Method: http.MethodGet, Path: "/ping", Handler: handlers.Ping},
+				{Name: "version", Method: http.MethodGet, Path: "/version", Handler: handlers.GetVersion},
+				{Name: "batch", Method: http.MethodPost, Path: "/batch", Handler: batch.Handler("/api/v1", http.HandlerFunc(dispatch), isAtomic), RateLimitClass: RateLimitWrite},
+
+				// User routes
+				{Name: "users.list", Method: http.MethodGet, Path: "/users", Handler: handlers.GetUsers, Scopes: []string{"users:read"}},
+				{Name: "users.table", Method: http.MethodGet, Path: "/users/table", Handler: handlers.UserTable, Scopes: []string{"users:read"}, Priority: loadshed.PriorityLow},
+				{Name: "users.suggest", Method: http.MethodGet, Path: "/users/suggest", Handler: handlers.SuggestUsers, Scopes: []string{"users:read"}},
+				{Name: "users.get", Method: http.MethodGet, Path: "/users/:id", Handler: handlers.GetUser, Scopes: []string{"users:read"}},
+				{Name: "users.create", Method: http.MethodPost, Path: "/users", Handler: handlers.CreateUser, Scopes: []string{"users:write"}, RateLimitClass: RateLimitWrite, Atomic: true},
+				{Name: "users.upsert", Method: http.MethodPut, Path: "/users:method", Handler: handlers.UserMethod, Scopes: []string{"users:write"}, RateLimitClass: RateLimitWrite, Atomic: true},
+				{Name: "users.update", Method: http.MethodPut, Path: "/users/:id", Handler: handlers.UpdateUser, Scopes: []string{"users:write"}, RateLimitClass: RateLimitWrite, Atomic: true},
+				{Name: "users.delete", Method: http.MethodDelete, Path: "/users/:id", Handler: handlers.DeleteUser, Scopes: []string{"users:write"}, RateLimitClass: RateLimitWrite, Atomic: true},
+				{Name: "users.deletion.cancel", Method: http.MethodDelete, Path: "/users/:id/deletion", Handler: handlers.CancelUserDeletion, Scopes: []string{"users:write"}, RateLimitClass: RateLimitWrite, Resource: "users", Atomic: true},
+				{Name: "users.bulk_delete", Method: http.MethodDelete, Path: "/users", Handler: handlers.BulkDeleteUsers, Scopes: []string{"users:write"}, RateLimitClass: RateLimitWrite},
+				{Name: "users.export", Method: http.MethodPost, Path: "/users/export", Handler: handlers.ExportUsers, Scopes: []string{"users:read", "users:read_pii"}, RateLimitClass: RateLimitWrite, Priority: loadshed.PriorityLow},
+				{Name: "users.import", Method: http.MethodPost, Path: "/users/import", Handler: handlers.ImportUsers, Scopes: []string{"users:write"}, RateLimitClass: RateLimitWrite, Priority: loadshed.PriorityLow},
+				{Name: "users.import.tus", Method: http.MethodPost, Path: "/users/import/tus", Handler: handlers.CreateImportUpload, Scopes: []string{"users:write"}, RateLimitClass: RateLimitWrite, Priority: loadshed.PriorityLow},
+				{Name: "users.avatar", Method: http.MethodPost, Path: "/users/:id/avatar", Handler: handlers.UploadAvatar, Scopes: []string{"users:write"}, RateLimitClass: RateLimitWrite},
+				{Name: "users.bulk_update", Method: http.MethodPatch, Path: "/users/bulk", Handler: handlers.BulkUpdateUsers, Scopes: []string{"users:write"}, RateLimitClass: RateLimitWrite},
+				{Name: "segments.preview", Method: http.MethodGet, Path: "/segments/preview", Handler: handlers.PreviewSegment, Scopes: []string{"users:read"}},
+				{Name: "users.groups", Method: http.MethodGet, Path: "/users/:id/groups", Handler: handlers.GetUserGroups, Scopes: []string{"groups:read"}},
+				{Name: "users.history", Method: http.MethodGet, Path: "/users/:id/history", Handler: handlers.ListUserHistory, Scopes: []string{"users:read"}},
+				{Name: "users.history.version", Method: http.MethodGet, Path: "/users/:id/history/:version", Handler: handlers.GetUserVersion, Scopes: []string{"users:read"}},
+				{Name: "users.restore", Method: http.MethodPost, Path: "/users/:id/restore", Handler: handlers.RestoreUser, Scopes: []string{"users:write"}, RateLimitClass: RateLimitWrite, Resource: "users"},
+
+				// Group routes
+				{Name: "groups.list", Method: http.MethodGet, Path: "/groups", Handler: handlers.ListGroups, Scopes: []string{"groups:read"}},
+				{Name: "groups.get", Method: http.MethodGet, Path: "/groups/:id", Handler: handlers.GetGroup, Scopes: []string{"groups:read"}},
+				{Name: "groups.create", Method: http.MethodPost, Path: "/groups", Handler: handlers.CreateGroup, Scopes: []string{"groups:write"}, RateLimitClass: RateLimitWrite, Atomic: true},
+				{Name: "groups.update", Method: http.MethodPut, Path: "/groups/:id", Handler: handlers.UpdateGroup, Scopes: []string{"groups:write"}, RateLimitClass: RateLimitWrite, Atomic: true},
+				{Name: "groups.delete", Method: http.MethodDelete, Path: "/groups/:id", Handler: handlers.DeleteGroup, Scopes: []string{"groups:write"}, RateLimitClass: RateLimitWrite, Atomic: true},
+				{Name: "groups.members.list", Method: http.MethodGet, Path: "/groups/:id/members", Handler: handlers.ListGroupMembers, Scopes: []string{"groups:read"}},
+				{Name: "groups.members.add", Method: http.MethodPost, Path: "/groups/:id/members", Handler: handlers.AddGroupMembers, Scopes: []string{"groups:write"}, RateLimitClass: RateLimitWrite, Atomic: true},
+				{Name: "groups.members.remove", Method: http.MethodDelete, Path: "/groups/:id/members/:user_id", Handler: handlers.RemoveGroupMember, Scopes: []string{"groups:write"}, RateLimitClass: RateLimitWrite, Atomic: true},
+
+				// Org members and invitations
+				{Name: "orgs.members.list", Method: http.MethodGet, Path: "/orgs/:id/members", Handler: handlers.ListOrgMembers, Scopes: []string{"orgs:read"}},
+				{Name: "orgs.members.role", Method: http.MethodPut, Path: "/orgs/:id/members/:user_id/role", Handler: handlers.SetOrgMemberRole, Scopes: []string{"orgs:write"}, RateLimitClass: RateLimitWrite},
+				{Name: "orgs.members.remove", Method: http.MethodDelete, Path: "/orgs/:id/members/:user_id", Handler: handlers.RemoveOrgMember, Scopes: []string{"orgs:write"}, RateLimitClass: RateLimitWrite},
+				{Name: "orgs.transfer", Method: http.MethodPost, Path: "/orgs/:id/transfer", Handler: handlers.TransferOrgOwnership, Scopes: []string{"orgs:write"}, RateLimitClass: RateLimitWrite},
+				{Name: "orgs.invitations.list", Method: http.MethodGet, Path: "/orgs/:id/invitations", Handler: handlers.ListOrgInvitations, Scopes: []string{"orgs:read"}},
+				{Name: "orgs.invitations.create", Method: http.MethodPost, Path: "/orgs/:id/invitations", Handler: handlers.CreateOrgInvitation, Scopes: []string{"orgs:write"}, RateLimitClass: RateLimitWrite},
+				{Name: "orgs.invitations.resend", Method: http.MethodPost, Path: "/orgs/:id/invitations/:invitation_id/resend", Handler: handlers.ResendOrgInvitation, Scopes: []string{"orgs:write"}, RateLimitClass: RateLimitWrite},
+				{Name: "orgs.invitations.revoke", Method: http.MethodDelete, Path: "/orgs/:id/invitations/:invitation_id", Handler: handlers.RevokeOrgInvitation, Scopes: []string{"orgs:write"}, RateLimitClass: RateLimitWrite},
+				{Name: "invitations.accept", Method: http.MethodPost, Path: "/invitations/accept", Handler: handlers.AcceptOrgInvitation, RateLimitClass: RateLimitAuth},
+
+				// Teams and their projects
+				{Name: "orgs.teams.list", Method: http.MethodGet, Path: "/orgs/:id/teams", Handler: handlers.ListTeams, Scopes: []string{"orgs:read"}},
+				{Name: "orgs.teams.create", Method: http.MethodPost, Path: "/orgs/:id/teams", Handler: handlers.CreateTeam, Scopes: []string{"orgs:write"}, RateLimitClass: RateLimitWrite},
+				{Name: "orgs.teams.get", Method: http.MethodGet, Path: "/orgs/:id/teams/:team_id", Handler: handlers.GetTeam, Scopes: []string{"orgs:read"}},
+				{Name: "orgs.teams.update", Method: http.MethodPut, Path: "/orgs/:id/teams/:team_id", Handler: handlers.UpdateTeam, Scopes: []string{"orgs:write"}, RateLimitClass: RateLimitWrite},
+				{Name: "orgs.teams.delete", Method: http.MethodDelete, Path: "/orgs/:id/teams/:team_id", Handler: handlers.DeleteTeam, Scopes: []string{"orgs:write"}, RateLimitClass: RateLimitWrite},
+				{Name: "orgs.teams.members.add", Method: http.MethodPost, Path: "/orgs/:id/teams/:team_id/members", Handler: handlers.AddTeamMembers, Scopes: []string{"orgs:write"}, RateLimitClass: RateLimitWrite},
+				{Name: "orgs.teams.members.remove", Method: http.MethodDelete, Path: "/orgs/:id/teams/:team_id/members/:user_id", Handler: handlers.RemoveTeamMember, Scopes: []string{"orgs:write"}, RateLimitClass: RateLimitWrite},
+				{Name: "orgs.projects.list", Method: http.MethodGet, Path: "/orgs/:id/projects", Handler: handlers.ListProjects, Scopes: []string{"orgs:read"}},
+				{Name: "orgs.projects.create", Method: http.MethodPost, Path: "/orgs/:id/projects", Handler: handlers.CreateProject, Scopes: []string{"orgs:write"}, RateLimitClass: RateLimitWrite},
+				{Name: "orgs.projects.get", Method: http.MethodGet, Path: "/orgs/:id/projects/:project_id", Handler: handlers.GetProject, Scopes: []string{"orgs:read"}},
+				{Name: "orgs.projects.update", Method: http.MethodPut, Path: "/orgs/:id/projects/:project_id", Handler: handlers.UpdateProject, Scopes: []string{"orgs:write"}, RateLimitClass: RateLimitWrite},
+				{Name: "orgs.projects.delete", Method: http.MethodDelete, Path: "/orgs/:id/projects/:project_id", Handler: handlers.DeleteProject, Scopes: []string{"orgs:write"}, RateLimitClass: RateLimitWrite},
+				{Name: "orgs.projects.acl.list", Method: http.MethodGet, Path: "/orgs/:id/projects/:project_id/acl", Handler: handlers.ListProjectACL, Scopes: []string{"orgs:read"}},
+				{Name: "orgs.projects.acl.share", Method: http.MethodPost, Path: "/orgs/:id/projects/:project_id/acl", Handler: handlers.ShareProject, Scopes: []string{"orgs:write"}, RateLimitClass: RateLimitWrite},
+				{Name: "orgs.projects.acl.unshare", Method: http.MethodDelete, Path: "/orgs/:id/projects/:project_id/acl/:entry_id", Handler: handlers.UnshareProject, Scopes: []string{"orgs:write"}, RateLimitClass: RateLimitWrite},
+				{Name: "orgs.projects.comments.list", Method: http.MethodGet, Path: "/orgs/:id/projects/:project_id/comments", Handler: handlers.ListProjectComments, Scopes: []string{"orgs:read"}},
+				{Name: "orgs.projects.comments.create", Method: http.MethodPost, Path: "/orgs/:id/projects/:project_id/comments", Handler: handlers.CreateProjectComment, Scopes: []string{"orgs:write"}, RateLimitClass: RateLimitWrite},
+				{Name: "orgs.projects.comments.get", Method: http.MethodGet, Path: "/orgs/:id/projects/:project_id/comments/:comment_id", Handler: handlers.GetProjectComment, Scopes: []string{"orgs:read"}},
+				{Name: "orgs.projects.comments.update", Method: http.MethodPut, Path: "/orgs/:id/projects/:project_id/comments/:comment_id", Handler: handlers.UpdateProjectComment, Scopes: []string{"orgs:write"}, RateLimitClass: RateLimitWrite},
+				{Name: "orgs.projects.comments.delete", Method: http.MethodDelete, Path: "/orgs/:id/projects/:project_id/comments/:comment_id", Handler: handlers.DeleteProjectComment, Scopes: []string{"orgs:write"}, RateLimitClass: RateLimitWrite},
+				{Name: "orgs.projects.attachments.list", Method: http.MethodGet, Path: "/orgs/:id/projects/:project_id/attachments", Handler: handlers.ListProjectAttachments, Scopes: []string{"orgs:read"}},
+				{Name: "orgs.projects.attachments.upload", Method: http.MethodPost, Path: "/orgs/:id/projects/:project_id/attachments", Handler: handlers.UploadProjectAttachment, Scopes: []string{"orgs:write"}, RateLimitClass: RateLimitWrite},
+				{Name: "orgs.projects.attachments.get", Method: http.MethodGet, Path: "/orgs/:id/projects/:project_id/attachments/:attachment_id", Handler: handlers.GetProjectAttachment, Scopes: []string{"orgs:read"}},
+				{Name: "orgs.projects.attachments.download", Method: http.MethodGet, Path: "/orgs/:id/projects/:project_id/attachments/:attachment_id/download", Handler: handlers.DownloadProjectAttachment, Scopes: []string{"orgs:read"}},
+				{Name: "orgs.projects.attachments.delete", Method: http.MethodDelete, Path: "/orgs/:id/projects/:project_id/attachments/:attachment_id", Handler: handlers.DeleteProjectAttachment, Scopes: []string{"orgs:write"}, RateLimitClass: RateLimitWrite},
+				{Name: "attachments.preview", Method: http.MethodGet, Path: "/attachments/:id/preview", Handler: handlers.GetAttachmentPreview, Scopes: []string{"orgs:read"}},
+				{Name: "orgs.projects.attachments.tus", Method: http.MethodPost, Path: "/orgs/:id/projects/:project_id/attachments/tus", Handler: handlers.CreateAttachmentUpload, Scopes: []string{"orgs:write"}, RateLimitClass: RateLimitWrite},
+
+				// Direct-to-storage uploads
+				{Name: "uploads.presign", Method: http.MethodPost, Path: "/uploads/presign", Handler: handlers.PresignUpload, Scopes: []string{"orgs:write"}, RateLimitClass: RateLimitWrite},
+				{Name: "uploads.complete", Method: http.MethodPost, Path: "/uploads/:upload_id/complete", Handler: handlers.CompleteUpload, Scopes: []string{"orgs:write"}, RateLimitClass: RateLimitWrite},
+
+				// Resumable uploads (tus); each is created under its target
+				{Name: "uploads.tus.options", Method: http.MethodOptions, Path: "/uploads/tus", Handler: handlers.TusOptions},
+				{Name: "uploads.tus.head", Method: http.MethodHead, Path: "/uploads/tus/:upload_id", Handler: handlers.HeadUpload},
+				{Name: "uploads.tus.patch", Method: http.MethodPatch, Path: "/uploads/tus/:upload_id", Handler: handlers.PatchUpload, RateLimitClass: RateLimitWrite},
+				{Name: "uploads.tus.delete", Method: http.MethodDelete, Path: "/uploads/tus/:upload_id", Handler: handlers.DeleteUpload, RateLimitClass: RateLimitWrite},
+
+				// Delta sync for offline-first clients
+				{Name: "sync", Method: http.MethodGet, Path: "/sync", Handler: handlers.SyncUsers, Scopes: []string{"users:read"}},
+				{Name: "trash.list", Method: http.MethodGet, Path: "/trash", Handler: handlers.ListTrash, Scopes: []string{"trash:read"}},
+				{Name: "trash.restore", Method: http.MethodPost, Path: "/trash/restore", Handler: handlers.RestoreTrash, Scopes: []string{"trash:write"}, RateLimitClass: RateLimitWrite},
+				{Name: "trash.purge", Method: http.MethodPost, Path: "/trash/purge", Handler: handlers.PurgeTrash, Scopes: []string{"trash:write"}, RateLimitClass: RateLimitWrite},
+
+				// Tasks offloaded to the Python worker
+				{Name: "tasks.create", Method: http.MethodPost, Path: "/tasks", Handler: handlers.CreateTask, Scopes: []string{"tasks:write"}, RateLimitClass: RateLimitWrite},
+
+				// Domain events for consumers
+				{Name: "events.list", Method: http.MethodGet, Path: "/events", Handler: handlers.ListEvents, Scopes: []string{"events:read"}},
+				{Name: "event_schemas.list", Method: http.MethodGet, Path: "/event-schemas", Handler: handlers.ListEventSchemas},
+				{Name: "event_schemas.get", Method: http.MethodGet, Path: "/event-schemas/:type/:version", Handler: handlers.GetEventSchema},
+
+				// The caller's personal access tokens
+				{Name: "me.tokens.list", Method: http.MethodGet, Path: "/me/tokens", Handler: handlers.ListPersonalTokens},
+				{Name: "me.tokens.create", Method: http.MethodPost, Path: "/me/tokens", Handler: handlers.CreatePersonalToken, RateLimitClass: RateLimitWrite},
+				{Name: "me.tokens.revoke", Method: http.MethodDelete, Path: "/me/tokens/:id", Handler: handlers.RevokePersonalToken, RateLimitClass: RateLimitWrite},
+
+				// Policy documents and the caller's consent to them
+				{Name: "policy_documents.list", Method: http.MethodGet, Path: "/policy-documents", Handler: handlers.ListPolicyDocuments},
+				{Name: "policy_documents.get", Method: http.MethodGet, Path: "/policy-documents/:kind/:version", Handler: handlers.GetPolicyDocument},
+				{Name: "me.consents.list", Method: http.MethodGet, Path: "/me/consents", Handler: handlers.ListMyConsents},
+				{Name: "me.consents.give", Method: http.MethodPost, Path: "/me/consents", Handler: handlers.GiveConsent, RateLimitClass: RateLimitWrite},
+
+				// Long-running operations
+				{Name: "operations.get", Method: http.MethodGet, Path: "/operations/:id", Handler: handlers.GetOperation, SignedIn: true},
+				{Name: "operations.result", Method: http.MethodGet, Path: "/operations/:id/result", Handler: handlers.GetOperationResult, SignedIn: true},
+			},
+		},
+		{
+			// Forwarded to the Python service
+			Prefix:    "/api/v1/py",
+			Authorize: true,
+			Priority:  loadshed.PriorityLow,
+			Middleware: []Middleware{
+				{Name: "authenticate", New: middleware.Authenticate},
+				{Name: "consent", New: func() gin.HandlerFunc { return middleware.RequireConsent("/api/v1/py") }},
+			},
+			Routes: pythonRoutes(),
+		},
+		{
+			// Sign-in and session management
+			Prefix: "/api/v1/auth",
+			Middleware: []Middleware{
+				{Name: "authenticate", New: middleware.Authenticate},
+				{Name: "consent", New: func() gin.HandlerFunc { return middleware.RequireConsent("/api/v1/auth") }},
+			},
+			Routes: []Route{
+				{Name: "auth.refresh", Method: http.MethodPost, Path: "/refresh", Handler: handlers.RefreshSession, RateLimitClass: RateLimitWrite},
+				{Name: "auth.logout", Method: http.MethodPost, Path: "/logout", Handler: handlers.Logout, RateLimitClass: RateLimitWrite},
+
+				// Magic links
+				{Name: "auth.magic_link.request", Method: http.MethodPost, Path: "/magic-link", Handler: handlers.RequestMagicLink, RateLimitClass: RateLimitAuth},
+				{Name: "auth.magic_link.consume", Method: http.MethodPost, Path: "/magic-link/consume", Handler: handlers.ConsumeMagicLink, RateLimitClass: RateLimitAuth},
+
+				// Passkeys
+				{Name: "auth.webauthn.register.begin", Method: http.MethodPost, Path: "/webauthn/register/begin", Handler: handlers.BeginPasskeyRegistration, RateLimitClass: RateLimitWrite},
+				{Name: "auth.webauthn.register.finish", Method: http.MethodPost, Path: "/webauthn/register/finish", Handler: handlers.FinishPasskeyRegistration, RateLimitClass: RateLimitWrite},
+				{Name: "auth.webauthn.login.begin", Method: http.MethodPost, Path: "/webauthn/login/begin", Handler: handlers.BeginPasskeyLogin, RateLimitClass: RateLimitWrite},
+				{Name: "auth.webauthn.login.finish", Method: http.MethodPost, Path: "/webauthn/login/finish", Handler: handlers.FinishPasskeyLogin, RateLimitClass: RateLimitWrite},
+				{Name: "auth.webauthn.credentials.list", Method: http.MethodGet, Path: "/webauthn/credentials", Handler: handlers.ListPasskeys},
+				{Name: "auth.webauthn.credentials.delete", Method: http.MethodDelete, Path: "/webauthn/credentials/:id", Handler: handlers.DeletePasskey, RateLimitClass: RateLimitWrite},
+			},
+		},
+		{
+			// Called by the Python service
+			Prefix:         "/internal",
+			RateLimitClass: RateLimitNone,
+			Authorize:      true,
+			Middleware: []Middleware{
+				{Name: "service_auth", New: func() gin.HandlerFunc { return middleware.ServiceAuth(svcauth.Backend) }},
+				{Name: "tenant", New: middleware.Tenant},
+			},
+			Routes: []Route{
+				{Name: "internal.users.get", Method: http.MethodGet, Path: "/users/:id", Handler: handlers.GetUser},
+			},
+		},
+		{
+			// SCIM 2.0 provisioning for identity providers
+			Prefix: "/scim/v2",
+			Middleware: []Middleware{
+				{Name: "scim_auth", New: middleware.ScimAuth},
+			},
+			Routes: []Route{
+				{Name: "scim.users.list", Method: http.MethodGet, Path: "/Users", Handler: handlers.ScimListUsers, Scopes: []string{"scim"}},
+				{Name: "scim.users.get", Method: http.MethodGet, Path: "/Users/:id", Handler: handlers.ScimGetUser, Scopes: []string{"scim"}},
+				{Name: "scim.users.create", Method: http.MethodPost, Path: "/Users", Handler: handlers.ScimCreateUser, Scopes: []string{"scim"}, RateLimitClass: RateLimitWrite, LogBody: true},
+				{Name: "scim.users.replace", Method: http.MethodPut, Path: "/Users/:id", Handler: handlers.ScimReplaceUser, Scopes: []string{"scim"}, RateLimitClass: RateLimitWrite, LogBody: true},
+				{Name: "scim.users.patch", Method: http.MethodPatch, Path: "/Users/:id", Handler: handlers.ScimPatchUser, Scopes: []string{"scim"}, RateLimitClass: RateLimitWrite, LogBody: true},
+				{Name: "scim.users.delete", Method: http.MethodDelete, Path: "/Users/:id", Handler: handlers.ScimDeleteUser, Scopes: []string{"scim"}, RateLimitClass: RateLimitWrite},
+			},
+		},
+		{
+			Prefix:         "/admin",
+			RateLimitClass: RateLimitNone,
+			Priority:       loadshed.PriorityCritical,
+			Middleware: []Middleware{
+				{Name: "admin_auth", New: middleware.AdminAuth},
+			},
+			Routes: []Route{
+				{Name: "admin.config.reload", Method: http.MethodPost, Path: "/config/reload", Handler: handlers.ReloadConfig, Scopes: []string{"admin"}},
+				{Name: "admin.log_level.get", Method: http.MethodGet, Path: "/log-level", Handler: handlers.GetLogLevel, Scopes: []string{"admin"}},
+				{Name: "admin.log_level.set", Method: http.MethodPut, Path: "/log-level", Handler: handlers.SetLogLevel, Scopes: []string{"admin"}},
+				{Name: "admin.read_only.get", Method: http.MethodGet, Path: "/read-only", Handler: handlers.GetReadOnly, Scopes: []string{"admin"}},
+				{Name: "admin.read_only.set", Method: http.MethodPut, Path: "/read-only", Handler: handlers.SetReadOnly, Scopes: []string{"admin"}},
+				{Name: "admin.routes", Method: http.MethodGet, Path: "/routes", Handler: listRoutes, Scopes: []string{"admin"}},
+				{Name: "admin.load", Method: http.MethodGet, Path: "/load", Handler: handlers.GetLoad, Scopes: []string{"admin"}},
+				{Name: "admin.slo", Method: http.MethodGet, Path: "/slo", Handler: handlers.GetSLO, Scopes: []string{"admin"}},
+				{Name: "admin.leaders", Method: http.MethodGet, Path: "/leaders", Handler: handlers.ListLeaders, Scopes: []string{"admin"}},
+
+				// Test data
+				{Name: "admin.fixtures.generate", Method: http.MethodPost, Path: "/fixtures/generate", Handler: handlers.GenerateFixtures, Scopes: []string{"admin"}, Priority: loadshed.PriorityLow},
+				{Name: "admin.testing.reset", Method: http.MethodPost, Path: "/testing/reset", Handler: handlers.ResetDatabase, Scopes: []string{"admin"}},
+
+				// Backups
+				{Name: "admin.backups.list", Method: http.MethodGet, Path: "/backups", Handler: handlers.ListBackups, Scopes: []string{"admin"}},
+				{Name: "admin.backups.create", Method: http.MethodPost, Path: "/backups", Handler: handlers.CreateBackup, Scopes: []string{"admin"}},
+				{Name: "admin.backups.restore", Method: http.MethodPost, Path: "/backups/restore", Handler: handlers.RestoreBackup, Scopes: []string{"admin"}},
+
+				// Policies
+				{Name: "admin.policies.list", Method: http.MethodGet, Path: "/policies", Handler: handlers.ListPolicies, Scopes: []string{"admin"}},
+				{Name: "admin.policies.create", Method: http.MethodPost, Path: "/policies", Handler: handlers.CreatePolicy, Scopes: []string{"admin"}},
+				{Name: "admin.policies.evaluate", Method: http.MethodPost, Path: "/policies/evaluate", Handler: handlers.EvaluatePolicies, Scopes: []string{"admin"}},
+				{Name: "admin.policies.get", Method: http.MethodGet, Path: "/policies/:id", Handler: handlers.GetPolicy, Scopes: []string{"admin"}},
+				{Name: "admin.policies.update", Method: http.MethodPut, Path: "/policies/:id", Handler: handlers.UpdatePolicy, Scopes: []string{"admin"}},
+				{Name: "admin.policies.delete", Method: http.MethodDelete, Path: "/policies/:id", Handler: handlers.DeletePolicy, Scopes: []string{"admin"}},
+
+				// Service accounts
+				{Name: "admin.service_accounts.list", Method: http.MethodGet, Path: "/service-accounts", Handler: handlers.ListServiceAccounts, Scopes: []string{"admin"}},
+				{Name: "admin.service_accounts.create", Method: http.MethodPost, Path: "/service-accounts", Handler: handlers.CreateServiceAccount, Scopes: []string{"admin"}},
+				{Name: "admin.service_accounts.get", Method: http.MethodGet, Path: "/service-accounts/:id", Handler: handlers.GetServiceAccount, Scopes: []string{"admin"}},
+				{Name: "admin.service_accounts.update", Method: http.MethodPut, Path: "/service-accounts/:id", Handler: handlers.UpdateServiceAccount, Scopes: []string{"admin"}},
+				{Name: "admin.service_accounts.delete", Method: http.MethodDelete, Path: "/service-accounts/:id", Handler: handlers.DeleteServiceAccount, Scopes: []string{"admin"}},
+				{Name: "admin.service_accounts.disable", Method: http.MethodPost, Path: "/service-accounts/:id/disable", Handler: handlers.DisableServiceAccount, Scopes: []string{"admin"}},
+				{Name: "admin.service_accounts.enable", Method: http.MethodPost, Path: "/service-accounts/:id/enable", Handler: handlers.EnableServiceAccount, Scopes: []string{"admin"}},
+				{Name: "admin.service_accounts.keys.list", Method: http.MethodGet, Path: "/service-accounts/:id/keys", Handler: handlers.ListAPIKeys, Scopes: []string{"admin"}},
+				{Name: "admin.service_accounts.keys.create", Method: http.MethodPost, Path: "/service-accounts/:id/keys", Handler: handlers.CreateAPIKey, Scopes: []string{"admin"}},
+				{Name: "admin.service_accounts.keys.revoke", Method: http.MethodDelete, Path: "/service-accounts/:id/keys/:key_id", Handler: handlers.RevokeAPIKey, Scopes: []string{"admin"}},
+
+				// Custom user attributes
+				{Name: "admin.user_attributes.list", Method: http.MethodGet, Path: "/user-attributes", Handler: handlers.ListUserAttributes, Scopes: []string{"admin"}},
+				{Name: "admin.user_attributes.put", Method: http.MethodPut, Path: "/user-attributes/:name", Handler: handlers.PutUserAttribute, Scopes: []string{"admin"}},
+				{Name: "admin.user_attributes.delete", Method: http.MethodDelete, Path: "/user-attributes/:name", Handler: handlers.DeleteUserAttribute, Scopes: []string{"admin"}},
+
+				// Plans: per-org rate limits and quotas
+				{Name: "admin.plans.list", Method: http.MethodGet, Path: "/plans", Handler: handlers.ListPlans, Scopes: []string{"admin"}},
+				{Name: "admin.plans.put", Method: http.MethodPut, Path: "/plans/:name", Handler: handlers.PutPlan, Scopes: []string{"admin"}},
+				{Name: "admin.plans.delete", Method: http.MethodDelete, Path: "/plans/:name", Handler: handlers.DeletePlan, Scopes: []string{"admin"}},
+				{Name: "admin.orgs.plan", Method: http.MethodPut, Path: "/orgs/:id/plan", Handler: handlers.SetOrgPlan, Scopes: []string{"admin"}},
+
+				// Org members
+				{Name: "admin.orgs.members.role", Method: http.MethodPut, Path: "/orgs/:id/members/:user_id/role", Handler: handlers.AdminSetOrgMemberRole, Scopes: []string{"admin"}},
+
+				// Users
+				{Name: "admin.users.merge", Method: http.MethodPost, Path: "/users/:id/merge", Handler: handlers.MergeUser, Scopes: []string{"admin"}},
+				{Name: "admin.legal_holds.list", Method: http.MethodGet, Path: "/legal-holds", Handler: handlers.ListLegalHolds, Scopes: []string{"admin"}},
+				{Name: "admin.users.legal_hold.set", Method: http.MethodPut, Path: "/users/:id/legal-hold", Handler: handlers.SetLegalHold, Scopes: []string{"admin"}},
+				{Name: "admin.users.legal_hold.clear", Method: http.MethodDelete, Path: "/users/:id/legal-hold", Handler: handlers.ClearLegalHold, Scopes: []string{"admin"}},
+
+				// Policy documents users consent to
+				{Name: "admin.policy_documents.list", Method: http.MethodGet, Path: "/policy-documents", Handler: handlers.ListPolicyDocumentVersions, Scopes: []string{"admin"}},
+				{Name: "admin.policy_documents.publish", Method: http.MethodPost, Path: "/policy-documents", Handler: handlers.PublishPolicyDocument, Scopes: []string{"admin"}},
+
+				// Email campaigns to user segments
+				{Name: "admin.campaigns.list", Method: http.MethodGet, Path: "/campaigns", Handler: handlers.ListCampaigns, Scopes: []string{"admin"}},
+				{Name: "admin.campaigns.create", Method: http.MethodPost, Path: "/campaigns", Handler: handlers.CreateCampaign, Scopes: []string{"admin"}},
+				{Name: "admin.campaigns.get", Method: http.MethodGet, Path: "/campaigns/:id", Handler: handlers.GetCampaign, Scopes: []string{"admin"}},
+				{Name: "admin.campaigns.cancel", Method: http.MethodPost, Path: "/campaigns/:id/cancel", Handler: handlers.CancelCampaign, Scopes: []string{"admin"}},
+
+				// Blocked words in user-provided names
+				{Name: "admin.blocked_words.list", Method: http.MethodGet, Path: "/blocked-words", Handler: handlers.ListBlockedWords, Scopes: []string{"admin"}},
+				{Name: "admin.blocked_words.put", Method: http.MethodPut, Path: "/blocked-words/:word", Handler: handlers.PutBlockedWord, Scopes: []string{"admin"}},
+				{Name: "admin.blocked_words.delete", Method: http.MethodDelete, Path: "/blocked-words/:word", Handler: handlers.DeleteBlockedWord, Scopes: []string{"admin"}},
+
+				// Users flagged for review
+				{Name: "admin.user_flags.list", Method: http.MethodGet, Path: "/user-flags", Handler: handlers.ListUserFlags, Scopes: []string{"admin"}},
+				{Name: "admin.user_flags.clear", Method: http.MethodDelete, Path: "/user-flags/:user_id/:flag", Handler: handlers.ClearUserFlag, Scopes: []string{"admin"}},
+
+				// Job queue
+				{Name: "admin.jobs.list", Method: http.MethodGet, Path: "/jobs", Handler: handlers.ListJobs, Scopes: []string{"admin"}},
+				{Name: "admin.jobs.stats", Method: http.MethodGet, Path: "/jobs/stats", Handler: handlers.GetJobStats, Scopes: []string{"admin"}},
+				{Name: "admin.jobs.dead.retry", Method: http.MethodPost, Path: "/jobs/dead/retry", Handler: handlers.RetryDeadJobs, Scopes: []string{"admin"}},
+				{Name: "admin.jobs.dead.purge", Method: http.MethodDelete, Path: "/jobs/dead", Handler: handlers.PurgeDeadJobs, Scopes: []string{"admin"}},
+				{Name: "admin.jobs.get", Method: http.MethodGet, Path: "/jobs/:id", Handler: handlers.GetJob, Scopes: []string{"admin"}},
+				{Name: "admin.jobs.retry", Method: http.MethodPost, Path: "/jobs/:id/retry", Handler: handlers.RetryJob, Scopes: []string{"admin"}},
+				{Name: "admin.jobs.cancel", Method: http.MethodPost, Path: "/jobs/:id/cancel", Handler: handlers.CancelJob, Scopes: []string{"admin"}},
+
+				// Long-running operations, whoever started them
+				{Name: "admin.operations.get", Method: http.MethodGet, Path: "/operations/:id", Handler: handlers.AdminGetOperation, Scopes: []string{"admin"}},
+				{Name: "admin.operations.result", Method: http.MethodGet, Path: "/operations/:id/result", Handler: handlers.AdminGetOperationResult, Scopes: []string{"admin"}},
+
+				// Data retention
+				{Name: "admin.retention.preview", Method: http.MethodGet, Path: "/retention", Handler: handlers.PreviewRetention, Scopes: []string{"admin"}},
+			},
+		},
+		{
+			Prefix:         "/dev",
+			RateLimitClass: RateLimitNone,
+			Middleware: []Middleware{
+				{Name: "dev_only", New: middleware.DevOnly},
+			},
+			Routes: []Route{
+				{Name: "dev.emails.list", Method: http.MethodGet, Path: "/emails", Handler: handlers.ListEmailTemplates},
+				{Name: "dev.emails.preview", Method: http.MethodGet, Path: "/emails/:name", Handler: handlers.PreviewEmailTemplate},
+			},
+		},
+	}
+}
+
+// pythonRoutes forwards every method under /api/v1/py to the Python service.
+func pythonRoutes() []Route {
+	var handler gin.HandlerFunc
+	p, err := proxy.FromEnv("/api/v1/py")
+	if err != nil {
+		log.Printf("Python proxy disabled: %v", err)
+		handler = func(c *gin.Context) {
+			render.JSON(c, http.StatusServiceUnavailable, gin.H{"error": "Python service is not configured"})
+		}
+	} else {
+		handler = p.Handler()
+	}
+
+	var routes []Route
+	for _, method := range []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete} {
+		routes = append(routes, Route{Name: "py.proxy." + strings.ToLower(method), Method: method, Path: "/*path", Handler: handler})
+	}
+	return routes
+}
+
+func newCORS() gin.HandlerFunc {
+	corsConfig := cors.DefaultConfig()
+	corsConfig.AllowOriginFunc = func(origin string) bool {
+		return config.Current().AllowsOrigin(origin)
+	}
+	corsConfig.AllowMethods = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
+	corsConfig.AllowHeaders = []string{"Origin", "Content-Type", "Authorization", middleware.RequestIDHeader,
+		"Tus-Resumable", "Upload-Length", "Upload-Offset", "Upload-Metadata"}
+	corsConfig.ExposeHeaders = []string{middleware.RequestIDHeader, middleware.ServedByHeader,
+		"Location", "Tus-Resumable", "Tus-Version", "Tus-Extension", "Tus-Max-Size",
+		"Upload-Length", "Upload-Offset", "Upload-Metadata", "Upload-Expires", "Upload-Result",
+		"Deprecation", "Sunset", "Link", "X-Deprecated-Fields", "ETag", "Last-Modified"}
+	return cors.New(corsConfig)
+}
@@ -0,0 +1,50 @@
+package sanitize
+
+import (
+	"fmt"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Name normalizes a user-visible name to NFC and rejects characters that
+// let two different names render the same: format characters such as
+// zero-width spaces and bidirectional overrides, and anything else that is
+// not printable. The plain space is allowed. field names the value in the
+// error.
+func Name(field, s string) (string, error) {
+	s = norm.NFC.String(s)
+	for _, r := range s {
+		if err := checkRune(field, r); err != nil {
+			return "", err
+		}
+	}
+	return s, nil
+}
+
+// Email normalizes an email address to NFC and rejects the characters Name
+// rejects, plus any whitespace.
+func Email(field, s string) (string, error) {
+	s = norm.NFC.String(s)
+	for _, r := range s {
+		if unicode.IsSpace(r) {
+			return "", fmt.Errorf("%s must not contain whitespace", field)
+		}
+		if err := checkRune(field, r); err != nil {
+			return "", err
+		}
+	}
+	return s, nil
+}
+
+func checkRune(field string, r rune) error {
+	switch {
+	case r == unicode.ReplacementChar:
+		return fmt.Errorf("%s is not valid UTF-8", field)
+	case unicode.Is(unicode.Cf, r):
+		return fmt.Errorf("%s must not contain invisible or bidirectional control characters", field)
+	case !unicode.IsPrint(r):
+		return fmt.Errorf("%s must contain only printable characters", field)
+	}
+	return nil
+}
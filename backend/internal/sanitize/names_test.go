@@ -0,0 +1,24 @@
+package sanitize
+
+import (
+	"testing"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// FuzzName checks that accepted names are NFC and accepted again unchanged,
+// so storing a normalized name never makes it invalid.
+func FuzzName(f *testing.F) {
+	f.Fuzz(func(t *testing.T, s string) {
+		name, err := Name("name", s)
+		if err != nil {
+			return
+		}
+		if !norm.NFC.IsNormalString(name) {
+			t.Fatalf("%q normalized to %q, which is not NFC", s, name)
+		}
+		if again, err := Name("name", name); err != nil || again != name {
+			t.Fatalf("%q normalized to %q, then to %q, %v", s, name, again, err)
+		}
+	})
+}
@@ -0,0 +1,126 @@
+// Package sanitize cleans bound request strings according to struct tags.
+//
+// A string, *string, or []string field opts in with a sanitize tag listing
+// rules, applied in this order:
+//
+//	trim       remove leading and trailing whitespace
+//	control    remove control characters; with multiline, keep \n and \t
+//	html       escape <, >, &, ' and " so the value is inert in HTML
+//
+// For example `sanitize:"trim,control,html"`. Nested structs, pointers, and
+// slices are walked; other fields are left alone.
+package sanitize
+
+import (
+	"html"
+	"reflect"
+	"strings"
+	"unicode"
+
+	"github.com/gin-gonic/gin/binding"
+)
+
+type rules struct {
+	trim, control, multiline, html bool
+}
+
+func parseRules(tag string) rules {
+	var r rules
+	for _, name := range strings.Split(tag, ",") {
+		switch strings.TrimSpace(name) {
+		case "trim":
+			r.trim = true
+		case "control":
+			r.control = true
+		case "multiline":
+			r.multiline = true
+		case "html":
+			r.html = true
+		}
+	}
+	return r
+}
+
+func (r rules) apply(s string) string {
+	if r.trim {
+		s = strings.TrimSpace(s)
+	}
+	if r.control {
+		s = strings.Map(func(c rune) rune {
+			if unicode.IsControl(c) && !(r.multiline && (c == '\n' || c == '\t')) {
+				return -1
+			}
+			return c
+		}, s)
+	}
+	if r.html {
+		s = html.EscapeString(s)
+	}
+	return s
+}
+
+// Struct sanitizes the tagged string fields of the struct v points to.
+func Struct(v interface{}) {
+	walk(reflect.ValueOf(v))
+}
+
+func walk(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if !v.IsNil() {
+			walk(v.Elem())
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			walk(v.Index(i))
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field, value := t.Field(i), v.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			if tag, ok := field.Tag.Lookup("sanitize"); ok {
+				setStrings(value, parseRules(tag))
+				continue
+			}
+			walk(value)
+		}
+	}
+}
+
+func setStrings(v reflect.Value, r rules) {
+	switch v.Kind() {
+	case reflect.String:
+		if v.CanSet() {
+			v.SetString(r.apply(v.String()))
+		}
+	case reflect.Pointer:
+		if !v.IsNil() {
+			setStrings(v.Elem(), r)
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			setStrings(v.Index(i), r)
+		}
+	}
+}
+
+// Validator wraps a binding validator so requests are sanitized before they
+// are validated, making rules like min=2 apply to the cleaned value.
+func Validator(next binding.StructValidator) binding.StructValidator {
+	return validator{next}
+}
+
+type validator struct {
+	binding.StructValidator
+}
+
+func (v validator) ValidateStruct(obj interface{}) error {
+	Struct(obj)
+	if v.StructValidator == nil {
+		return nil
+	}
+	return v.StructValidator.ValidateStruct(obj)
+}
@@ -0,0 +1,62 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	"pygorp/backend/internal/env"
+)
+
+// Scanner inspects uploaded content before it is accepted.
+type Scanner interface {
+	Scan(ctx context.Context, r io.Reader) error
+}
+
+// InfectedError is returned when a scanner finds malware in the content.
+type InfectedError struct {
+	Signature string
+}
+
+func (e *InfectedError) Error() string {
+	return fmt.Sprintf("file is infected: %s", e.Signature)
+}
+
+// IsInfected reports whether err carries an *InfectedError.
+func IsInfected(err error) bool {
+	var infected *InfectedError
+	return errors.As(err, &infected)
+}
+
+var (
+	defaultScanner Scanner
+	initErr        error
+	once           sync.Once
+)
+
+// Default returns the scanner configured by SCANNER ("clamav", or "none"
+// and unset to disable scanning). Any other value is an error, so a typo
+// cannot silently turn scanning off.
+func Default() (Scanner, error) {
+	once.Do(func() {
+		switch name := env.String("SCANNER", "none"); name {
+		case "clamav":
+			defaultScanner = NewClamAV(env.String("CLAMAV_ADDR", "localhost:3310"), 30*time.Second)
+		case "none":
+			log.Printf("Upload scanning disabled")
+			defaultScanner = Noop{}
+		default:
+			initErr = fmt.Errorf("unknown scanner %q (want clamav or none)", name)
+		}
+	})
+	return defaultScanner, initErr
+}
+
+// Noop accepts every file. It is meant for development.
+type Noop struct{}
+
+func (Noop) Scan(ctx context.Context, r io.Reader) error { return nil }
@@ -0,0 +1,384 @@
+package scim
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"pygorp/backend/internal/sqlb"
+)
+
+// Filter is a parsed SCIM filter expression (RFC 7644, section 3.4.2.2).
+type Filter interface {
+	// SQL translates the filter into a condition on the mapped columns.
+	SQL(attrs map[string]Attribute) (sqlb.Expr, error)
+}
+
+// Attribute types.
+const (
+	String          = "string"
+	CaseExactString = "case_exact_string"
+	Boolean         = "boolean"
+	Integer         = "integer"
+	DateTime        = "datetime"
+)
+
+// Attribute maps a SCIM attribute path to a column. Column is trusted SQL.
+type Attribute struct {
+	Column string
+	Type   string
+}
+
+type compare struct {
+	path  string
+	op    string
+	value interface{}
+}
+
+type logical struct {
+	op          string
+	left, right Filter
+}
+
+type not struct {
+	filter Filter
+}
+
+// ParseFilter parses a filter such as
+// `userName eq "bjensen" and not (emails co "@example.org")`.
+func ParseFilter(s string) (Filter, error) {
+	tokens, err := tokenize(s)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	f, err := p.or("")
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q", p.tokens[p.pos].text)
+	}
+	return f, nil
+}
+
+type token struct {
+	text   string
+	quoted bool
+}
+
+func tokenize(s string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(s); {
+		r := rune(s[i])
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case strings.ContainsRune("()[]", r):
+			tokens = append(tokens, token{text: string(r)})
+			i++
+		case r == '"':
+			// Strings use JSON escaping.
+			j := i + 1
+			for ; j < len(s) && s[j] != '"'; j++ {
+				if s[j] == '\\' {
+					j++
+				}
+			}
+			if j >= len(s) {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			var text string
+			if err := json.Unmarshal([]byte(s[i:j+1]), &text); err != nil {
+				return nil, fmt.Errorf("invalid string %s", s[i:j+1])
+			}
+			tokens = append(tokens, token{text: text, quoted: true})
+			i = j + 1
+		default:
+			j := i
+			for j < len(s) && !unicode.IsSpace(rune(s[j])) && !strings.ContainsRune(`()[]"`, rune(s[j])) {
+				j++
+			}
+			tokens = append(tokens, token{text: s[i:j]})
+			i = j
+		}
+	}
+	return tokens, nil
+}
+
+// maxDepth bounds nesting of parentheses, not, and value filters, so a
+// hostile filter cannot make the parser use unbounded stack.
+const maxDepth = 32
+
+type parser struct {
+	tokens []token
+	pos    int
+	depth  int
+}
+
+func (p *parser) peek() (token, bool) {
+	if p.pos >= len(p.tokens) {
+		return token{}, false
+	}
+	return p.tokens[p.pos], true
+}
+
+func (p *parser) keyword(word string) bool {
+	t, ok := p.peek()
+	if ok && !t.quoted && strings.EqualFold(t.text, word) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(text string) error {
+	t, ok := p.peek()
+	if !ok || t.quoted || t.text != text {
+		return fmt.Errorf("expected %q", text)
+	}
+	p.pos++
+	return nil
+}
+
+// or, and, and unary parse in order of increasing precedence. prefix is the
+// attribute path of an enclosing value filter, e.g. "emails".
+func (p *parser) or(prefix string) (Filter, error) {
+	if p.depth++; p.depth > maxDepth {
+		return nil, errors.New("filter is nested too deeply")
+	}
+	defer func() { p.depth-- }()
+
+	left, err := p.and(prefix)
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("or") {
+		right, err := p.and(prefix)
+		if err != nil {
+			return nil, err
+		}
+		left = logical{op: "or", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) and(prefix string) (Filter, error) {
+	left, err := p.unary(prefix)
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("and") {
+		right, err := p.unary(prefix)
+		if err != nil {
+			return nil, err
+		}
+		left = logical{op: "and", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) unary(prefix string) (Filter, error) {
+	if p.keyword("not") {
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		f, err := p.or(prefix)
+		if err != nil {
+			return nil, err
+		}
+		return not{f}, p.expect(")")
+	}
+	if t, ok := p.peek(); ok && !t.quoted && t.text == "(" {
+		p.pos++
+		f, err := p.or(prefix)
+		if err != nil {
+			return nil, err
+		}
+		return f, p.expect(")")
+	}
+	return p.attrExp(prefix)
+}
+
+func (p *parser) attrExp(prefix string) (Filter, error) {
+	t, ok := p.peek()
+	if !ok || t.quoted || strings.ContainsAny(t.text, "()[]") {
+		return nil, fmt.Errorf("expected an attribute")
+	}
+	p.pos++
+	path := normalizePath(t.text)
+	if prefix != "" {
+		path = prefix + "." + path
+	}
+
+	// A value filter such as emails[type eq "work"].
+	if next, ok := p.peek(); ok && !next.quoted && next.text == "[" {
+		if prefix != "" {
+			return nil, fmt.Errorf("value filters cannot be nested")
+		}
+		p.pos++
+		f, err := p.or(path)
+		if err != nil {
+			return nil, err
+		}
+		return f, p.expect("]")
+	}
+
+	opToken, ok := p.peek()
+	if !ok || opToken.quoted {
+		return nil, fmt.Errorf("expected an operator after %s", t.text)
+	}
+	op := strings.ToLower(opToken.text)
+	p.pos++
+	switch op {
+	case "pr":
+		return compare{path: path, op: op}, nil
+	case "eq", "ne", "co", "sw", "ew", "gt", "ge", "lt", "le":
+	default:
+		return nil, fmt.Errorf("unknown operator %q", opToken.text)
+	}
+
+	valueToken, ok := p.peek()
+	if !ok {
+		return nil, fmt.Errorf("expected a value after %s", opToken.text)
+	}
+	p.pos++
+	value, err := literal(valueToken)
+	if err != nil {
+		return nil, err
+	}
+	return compare{path: path, op: op, value: value}, nil
+}
+
+func literal(t token) (interface{}, error) {
+	if t.quoted {
+		return t.text, nil
+	}
+	switch strings.ToLower(t.text) {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	}
+	if n, err := strconv.ParseFloat(t.text, 64); err == nil {
+		return n, nil
+	}
+	return nil, fmt.Errorf("invalid value %q", t.text)
+}
+
+// normalizePath lowercases an attribute path and strips the core User schema
+// URN, since attribute names are case-insensitive.
+func normalizePath(path string) string {
+	path = strings.ToLower(path)
+	return strings.TrimPrefix(path, strings.ToLower(UserSchema)+":")
+}
+
+func (f logical) SQL(attrs map[string]Attribute) (sqlb.Expr, error) {
+	left, err := f.left.SQL(attrs)
+	if err != nil {
+		return left, err
+	}
+	right, err := f.right.SQL(attrs)
+	if err != nil {
+		return right, err
+	}
+	if f.op == "or" {
+		return sqlb.Or(left, right), nil
+	}
+	return sqlb.And(left, right), nil
+}
+
+func (f not) SQL(attrs map[string]Attribute) (sqlb.Expr, error) {
+	e, err := f.filter.SQL(attrs)
+	return sqlb.Not(e), err
+}
+
+var sqlOps = map[string]string{"eq": "=", "ne": "<>", "gt": ">", "ge": ">=", "lt": "<", "le": "<="}
+
+func (f compare) SQL(attrs map[string]Attribute) (sqlb.Expr, error) {
+	attr, ok := attrs[f.path]
+	if !ok {
+		return sqlb.Expr{}, fmt.Errorf("cannot filter on %q", f.path)
+	}
+	col := attr.Column
+
+	switch {
+	case f.op == "pr":
+		return sqlb.Cond(col + " IS NOT NULL"), nil
+	case f.value == nil && f.op == "eq":
+		return sqlb.Cond(col + " IS NULL"), nil
+	case f.value == nil && f.op == "ne":
+		return sqlb.Cond(col + " IS NOT NULL"), nil
+	case f.value == nil:
+		return sqlb.Expr{}, fmt.Errorf("%s cannot compare with null", f.op)
+	}
+
+	switch attr.Type {
+	case Boolean:
+		b, ok := f.value.(bool)
+		if !ok || (f.op != "eq" && f.op != "ne") {
+			return sqlb.Expr{}, fmt.Errorf("%s only supports eq and ne with true or false", f.path)
+		}
+		return sqlb.Cond(col+" "+sqlOps[f.op]+" ?", b), nil
+
+	case Integer:
+		// SCIM ids are strings; compare them as numbers when they are.
+		var n int64
+		switch v := f.value.(type) {
+		case float64:
+			n = int64(v)
+		case string:
+			parsed, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return sqlb.Cond("FALSE"), nil
+			}
+			n = parsed
+		default:
+			return sqlb.Expr{}, fmt.Errorf("%s needs a number", f.path)
+		}
+		op, ok := sqlOps[f.op]
+		if !ok {
+			return sqlb.Expr{}, fmt.Errorf("%s does not support %s", f.path, f.op)
+		}
+		return sqlb.Cond(col+" "+op+" ?", n), nil
+
+	case DateTime:
+		s, _ := f.value.(string)
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return sqlb.Expr{}, fmt.Errorf("%s needs an RFC 3339 date-time", f.path)
+		}
+		op, ok := sqlOps[f.op]
+		if !ok {
+			return sqlb.Expr{}, fmt.Errorf("%s does not support %s", f.path, f.op)
+		}
+		return sqlb.Cond(col+" "+op+" ?", t), nil
+	}
+
+	s, ok := f.value.(string)
+	if !ok {
+		return sqlb.Expr{}, fmt.Errorf("%s needs a string", f.path)
+	}
+	like, cmp := "ILIKE", "lower("+col+")"
+	arg := interface{}(strings.ToLower(s))
+	if attr.Type == CaseExactString {
+		like, cmp, arg = "LIKE", col, s
+	}
+	switch f.op {
+	case "co":
+		return sqlb.Cond(col+" "+like+" ?", "%"+sqlb.EscapeLike(s)+"%"), nil
+	case "sw":
+		return sqlb.Cond(col+" "+like+" ?", sqlb.EscapeLike(s)+"%"), nil
+	case "ew":
+		return sqlb.Cond(col+" "+like+" ?", "%"+sqlb.EscapeLike(s)), nil
+	case "ne":
+		return sqlb.Cond(cmp+" IS DISTINCT FROM ?", arg), nil
+	}
+	return sqlb.Cond(cmp+" "+sqlOps[f.op]+" ?", arg), nil
+}
@@ -0,0 +1,26 @@
+package scim
+
+import (
+	"testing"
+
+	"pygorp/backend/internal/sqlb"
+)
+
+// FuzzSCIMFilter parses filters from the query string and checks that any
+// filter translated to SQL builds into a statement whose placeholders match
+// its arguments, so filter values never end up in the SQL itself.
+func FuzzSCIMFilter(f *testing.F) {
+	f.Fuzz(func(t *testing.T, s string) {
+		filter, err := ParseFilter(s)
+		if err != nil {
+			return
+		}
+		expr, err := filter.SQL(UserAttributes)
+		if err != nil {
+			return
+		}
+		if _, _, err := sqlb.Select("id").From("users").WhereExpr(expr).Build(); err != nil {
+			t.Fatalf("filter %q built invalid SQL %q: %v", s, expr.SQL, err)
+		}
+	})
+}
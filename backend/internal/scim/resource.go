@@ -0,0 +1,302 @@
+// Package scim implements the parts of SCIM 2.0 (RFC 7643 and 7644) needed
+// for identity providers to provision users.
+package scim
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+
+	"pygorp/backend/internal/models"
+)
+
+// Schema URNs.
+const (
+	UserSchema         = "urn:ietf:params:scim:schemas:core:2.0:User"
+	ListResponseSchema = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	PatchOpSchema      = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	ErrorSchema        = "urn:ietf:params:scim:api:messages:2.0:Error"
+)
+
+// ContentType is the media type of SCIM requests and responses.
+const ContentType = "application/scim+json"
+
+// UserAttributes maps filterable User attributes to users columns. Each user
+// has exactly one email, reported as the primary work address.
+var UserAttributes = map[string]Attribute{
+	"id":                {Column: "id", Type: Integer},
+	"externalid":        {Column: "external_id", Type: CaseExactString},
+	"username":          {Column: "email", Type: String},
+	"displayname":       {Column: "name", Type: String},
+	"name.formatted":    {Column: "name", Type: String},
+	"emails":            {Column: "email", Type: String},
+	"emails.value":      {Column: "email", Type: String},
+	"emails.type":       {Column: "'work'", Type: String},
+	"emails.primary":    {Column: "TRUE", Type: Boolean},
+	"active":            {Column: "active", Type: Boolean},
+	"meta.created":      {Column: "created_at", Type: DateTime},
+	"meta.lastmodified": {Column: "updated_at", Type: DateTime},
+}
+
+// User is the SCIM User resource.
+type User struct {
+	Schemas     []string `json:"schemas"`
+	ID          string   `json:"id,omitempty"`
+	ExternalID  string   `json:"externalId,omitempty"`
+	UserName    string   `json:"userName"`
+	Name        *Name    `json:"name,omitempty"`
+	DisplayName string   `json:"displayName,omitempty"`
+	Emails      []Email  `json:"emails,omitempty"`
+	Active      *bool    `json:"active,omitempty"`
+	Meta        *Meta    `json:"meta,omitempty"`
+
+	// storedName is set while Name.Formatted and DisplayName hold the
+	// stored name rather than values from the request.
+	storedName bool
+}
+
+type Name struct {
+	Formatted  string `json:"formatted,omitempty"`
+	GivenName  string `json:"givenName,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+}
+
+type Email struct {
+	Value   string `json:"value"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+}
+
+type Meta struct {
+	ResourceType string    `json:"resourceType"`
+	Created      time.Time `json:"created"`
+	LastModified time.Time `json:"lastModified"`
+	Location     string    `json:"location"`
+}
+
+// ListResponse wraps a page of resources. StartIndex is 1-based.
+type ListResponse struct {
+	Schemas      []string `json:"schemas"`
+	TotalResults int      `json:"totalResults"`
+	StartIndex   int      `json:"startIndex"`
+	ItemsPerPage int      `json:"itemsPerPage"`
+	Resources    []User   `json:"Resources"`
+}
+
+// Error is the SCIM error response. ScimType is set for 400 and 409 errors
+// with a standard detail type, such as invalidFilter or uniqueness.
+type Error struct {
+	Schemas  []string `json:"schemas"`
+	Status   string   `json:"status"`
+	ScimType string   `json:"scimType,omitempty"`
+	Detail   string   `json:"detail"`
+}
+
+func NewError(status int, scimType, detail string) Error {
+	return Error{Schemas: []string{ErrorSchema}, Status: strconv.Itoa(status), ScimType: scimType, Detail: detail}
+}
+
+// FromModel builds the resource for a stored user. location is the URL of
+// the Users endpoint.
+func FromModel(u models.ProvisionedUser, location string) User {
+	active := u.Active
+	res := User{
+		Schemas:     []string{UserSchema},
+		ID:          strconv.Itoa(u.ID),
+		UserName:    u.Email,
+		Name:        &Name{Formatted: u.Name},
+		DisplayName: u.Name,
+		Emails:      []Email{{Value: u.Email, Type: "work", Primary: true}},
+		Active:      &active,
+		Meta: &Meta{
+			ResourceType: "User",
+			Created:      u.CreatedAt,
+			LastModified: u.UpdatedAt,
+			Location:     location + "/" + strconv.Itoa(u.ID),
+		},
+		storedName: true,
+	}
+	if u.ExternalID != nil {
+		res.ExternalID = *u.ExternalID
+	}
+	return res
+}
+
+// Fields returns the stored fields for a resource. The email is userName,
+// or the primary email when userName is not an address; the name falls back
+// from formatted to displayName to given and family names, then to the
+// email's local part.
+func (u *User) Fields() (email, name string, externalID *string, active bool, err error) {
+	email = u.UserName
+	if _, err := mail.ParseAddress(email); err != nil {
+		email = ""
+		for _, e := range u.Emails {
+			if e.Primary || email == "" {
+				email = e.Value
+			}
+		}
+		if _, err := mail.ParseAddress(email); err != nil {
+			return "", "", nil, false, fmt.Errorf("userName or emails must contain an email address")
+		}
+	}
+
+	switch {
+	case u.Name != nil && u.Name.Formatted != "":
+		name = u.Name.Formatted
+	case u.DisplayName != "":
+		name = u.DisplayName
+	case u.Name != nil && (u.Name.GivenName != "" || u.Name.FamilyName != ""):
+		name = strings.TrimSpace(u.Name.GivenName + " " + u.Name.FamilyName)
+	default:
+		name = strings.SplitN(email, "@", 2)[0]
+	}
+	if len([]rune(name)) < 2 || len([]rune(name)) > 100 {
+		return "", "", nil, false, fmt.Errorf("name must be between 2 and 100 characters")
+	}
+
+	if u.ExternalID != "" {
+		externalID = &u.ExternalID
+	}
+	active = u.Active == nil || *u.Active
+	return email, name, externalID, active, nil
+}
+
+// PatchRequest is a SCIM PATCH body.
+type PatchRequest struct {
+	Schemas    []string         `json:"schemas"`
+	Operations []PatchOperation `json:"Operations" binding:"required,min=1"`
+}
+
+type PatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value"`
+}
+
+// Apply applies the operations to u in order. Operation names are
+// case-insensitive, since some identity providers send "Replace".
+func (r *PatchRequest) Apply(u *User) error {
+	for _, op := range r.Operations {
+		path := normalizePath(op.Path)
+		switch strings.ToLower(op.Op) {
+		case "add", "replace":
+			if path == "" {
+				var values map[string]json.RawMessage
+				if err := json.Unmarshal(op.Value, &values); err != nil {
+					return fmt.Errorf("a patch without a path needs an object value")
+				}
+				for key, value := range values {
+					if err := u.set(normalizePath(key), value); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+			if err := u.set(path, op.Value); err != nil {
+				return err
+			}
+		case "remove":
+			if err := u.remove(path); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unknown patch op %q", op.Op)
+		}
+	}
+	return nil
+}
+
+func (u *User) set(path string, value json.RawMessage) error {
+	if u.Name == nil {
+		u.Name = &Name{}
+	}
+	// The first name change replaces the stored name, so a patch of only
+	// givenName or displayName is not shadowed by name.formatted.
+	if u.storedName && (path == "displayname" || strings.HasPrefix(path, "name")) {
+		u.Name.Formatted, u.DisplayName = "", ""
+		u.storedName = false
+	}
+	var target interface{}
+	switch path {
+	case "username":
+		target = &u.UserName
+	case "displayname":
+		target = &u.DisplayName
+	case "externalid":
+		target = &u.ExternalID
+	case "name":
+		target = u.Name
+	case "name.formatted":
+		target = &u.Name.Formatted
+	case "name.givenname":
+		target = &u.Name.GivenName
+	case "name.familyname":
+		target = &u.Name.FamilyName
+	case "emails":
+		target = &u.Emails
+	case "emails.value", `emails[type eq "work"].value`, "emails[primary eq true].value":
+		var email string
+		if err := json.Unmarshal(value, &email); err != nil {
+			return fmt.Errorf("%s must be a string", path)
+		}
+		u.Emails = []Email{{Value: email, Type: "work", Primary: true}}
+		return nil
+	case "active":
+		active, err := parseBool(value)
+		if err != nil {
+			return err
+		}
+		u.Active = &active
+		return nil
+	default:
+		return fmt.Errorf("cannot modify %q", path)
+	}
+	if err := json.Unmarshal(value, target); err != nil {
+		return fmt.Errorf("invalid value for %s", path)
+	}
+	return nil
+}
+
+func (u *User) remove(path string) error {
+	switch path {
+	case "externalid":
+		u.ExternalID = ""
+	case "displayname":
+		u.DisplayName = ""
+	case "name.givenname", "name.familyname", "name.formatted", "name":
+		if u.Name == nil || path == "name" {
+			u.Name = nil
+			return nil
+		}
+		switch path {
+		case "name.givenname":
+			u.Name.GivenName = ""
+		case "name.familyname":
+			u.Name.FamilyName = ""
+		default:
+			u.Name.Formatted = ""
+		}
+	default:
+		return fmt.Errorf("cannot remove %q", path)
+	}
+	return nil
+}
+
+// parseBool accepts JSON booleans and the "True"/"False" strings some
+// identity providers send.
+func parseBool(value json.RawMessage) (bool, error) {
+	var b bool
+	if err := json.Unmarshal(value, &b); err == nil {
+		return b, nil
+	}
+	var s string
+	if err := json.Unmarshal(value, &s); err == nil {
+		if b, err := strconv.ParseBool(s); err == nil {
+			return b, nil
+		}
+	}
+	return false, fmt.Errorf("active must be a boolean")
+}
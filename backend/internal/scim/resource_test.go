@@ -0,0 +1,28 @@
+package scim
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"pygorp/backend/internal/models"
+)
+
+// FuzzSCIMPatch applies PATCH bodies to a stored user and reads the fields
+// back, as the PATCH endpoint does.
+func FuzzSCIMPatch(f *testing.F) {
+	f.Fuzz(func(t *testing.T, body []byte) {
+		var patch PatchRequest
+		if err := json.Unmarshal(body, &patch); err != nil {
+			return
+		}
+		u := FromModel(models.ProvisionedUser{
+			User:   models.User{ID: 7, Email: "bjensen@example.com", Name: "Barbara Jensen", CreatedAt: time.Unix(0, 0), UpdatedAt: time.Unix(0, 0)},
+			Active: true,
+		}, "/scim/v2/Users")
+		if err := patch.Apply(&u); err != nil {
+			return
+		}
+		u.Fields()
+	})
+}
@@ -0,0 +1,342 @@
+// Package segments selects users with a small filter language, the building
+// block for targeted notifications. A segment such as
+//
+//	signed_up >= now-30d and tag = "beta" and sign_ins[7d] >= 3
+//
+// picks users who signed up in the last 30 days, are in the beta group, and
+// signed in at least three times in the last week. Conditions combine with
+// and, or, not, and parentheses.
+//
+// Segments compile to a condition on the users table. Fields map to fixed
+// SQL and every value is a bind parameter, so nothing from a segment is
+// spliced into the statement.
+package segments
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"pygorp/backend/internal/sqlb"
+)
+
+// Bounds on segments, so a hostile one cannot make the parser or the query
+// do unbounded work.
+const (
+	MaxLength     = 2000
+	maxDepth      = 32
+	maxConditions = 20
+	maxWindow     = 10 * 365 * 24 * time.Hour
+)
+
+// activity is a table counted by an activity condition. Every kind is
+// counted by the rows' created_at.
+type activity struct {
+	table  string
+	userID string
+}
+
+// Activities are the kinds of activity segments can count, keyed by name.
+var activities = map[string]activity{
+	"sign_ins":    {table: "sessions", userID: "user_id"},
+	"comments":    {table: "comments", userID: "author_id"},
+	"uploads":     {table: "attachments", userID: "uploaded_by"},
+	"projects":    {table: "projects", userID: "created_by"},
+	"ai_requests": {table: "ai_requests", userID: "user_id"},
+}
+
+var sqlOps = map[string]string{"=": "=", "!=": "<>", "<": "<", "<=": "<=", ">": ">", ">=": ">="}
+
+// Compile parses a segment and returns the condition on users it stands
+// for. Relative dates such as now-30d are taken from now. Users whose
+// deletion is pending are never in a segment.
+func Compile(src string, now time.Time) (sqlb.Expr, error) {
+	if strings.TrimSpace(src) == "" {
+		return sqlb.Expr{}, errors.New("segment is empty")
+	}
+	if len(src) > MaxLength {
+		return sqlb.Expr{}, fmt.Errorf("segment must be at most %d characters", MaxLength)
+	}
+	tokens, err := tokenize(src)
+	if err != nil {
+		return sqlb.Expr{}, err
+	}
+	p := &parser{tokens: tokens, now: now}
+	where, err := p.or()
+	if err != nil {
+		return sqlb.Expr{}, err
+	}
+	if t, ok := p.peek(); ok {
+		return sqlb.Expr{}, fmt.Errorf("unexpected %q", t.text)
+	}
+	return sqlb.And(where, sqlb.Cond("purge_at IS NULL")), nil
+}
+
+type token struct {
+	text   string
+	quoted bool
+}
+
+// isWord reports runes that make up names, numbers, dates, and windows.
+func isWord(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("_-+:.", r)
+}
+
+func tokenize(s string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(s); {
+		r := rune(s[i])
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case strings.ContainsRune("()[]", r):
+			tokens = append(tokens, token{text: string(r)})
+			i++
+		case strings.ContainsRune("<>=!", r):
+			j := i
+			for j < len(s) && strings.ContainsRune("<>=!", rune(s[j])) {
+				j++
+			}
+			tokens = append(tokens, token{text: s[i:j]})
+			i = j
+		case r == '"':
+			// Strings use JSON escaping.
+			j := i + 1
+			for ; j < len(s) && s[j] != '"'; j++ {
+				if s[j] == '\\' {
+					j++
+				}
+			}
+			if j >= len(s) {
+				return nil, errors.New("unterminated string")
+			}
+			var text string
+			if err := json.Unmarshal([]byte(s[i:j+1]), &text); err != nil {
+				return nil, fmt.Errorf("invalid string %s", s[i:j+1])
+			}
+			tokens = append(tokens, token{text: text, quoted: true})
+			i = j + 1
+		case isWord(r):
+			j := i
+			for j < len(s) && isWord(rune(s[j])) {
+				j++
+			}
+			tokens = append(tokens, token{text: s[i:j]})
+			i = j
+		default:
+			return nil, fmt.Errorf("unexpected %q", string(r))
+		}
+	}
+	return tokens, nil
+}
+
+type parser struct {
+	tokens     []token
+	pos        int
+	depth      int
+	conditions int
+	now        time.Time
+}
+
+func (p *parser) peek() (token, bool) {
+	if p.pos >= len(p.tokens) {
+		return token{}, false
+	}
+	return p.tokens[p.pos], true
+}
+
+func (p *parser) keyword(word string) bool {
+	t, ok := p.peek()
+	if ok && !t.quoted && strings.EqualFold(t.text, word) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(text string) error {
+	t, ok := p.peek()
+	if !ok || t.quoted || t.text != text {
+		return fmt.Errorf("expected %q", text)
+	}
+	p.pos++
+	return nil
+}
+
+// or, and, and unary parse in order of increasing precedence.
+func (p *parser) or() (sqlb.Expr, error) {
+	if p.depth++; p.depth > maxDepth {
+		return sqlb.Expr{}, errors.New("segment is nested too deeply")
+	}
+	defer func() { p.depth-- }()
+
+	exprs := []sqlb.Expr{}
+	for {
+		e, err := p.and()
+		if err != nil {
+			return e, err
+		}
+		exprs = append(exprs, e)
+		if !p.keyword("or") {
+			return sqlb.Or(exprs...), nil
+		}
+	}
+}
+
+func (p *parser) and() (sqlb.Expr, error) {
+	exprs := []sqlb.Expr{}
+	for {
+		e, err := p.unary()
+		if err != nil {
+			return e, err
+		}
+		exprs = append(exprs, e)
+		if !p.keyword("and") {
+			return sqlb.And(exprs...), nil
+		}
+	}
+}
+
+func (p *parser) unary() (sqlb.Expr, error) {
+	if p.keyword("not") {
+		if p.depth++; p.depth > maxDepth {
+			return sqlb.Expr{}, errors.New("segment is nested too deeply")
+		}
+		defer func() { p.depth-- }()
+		e, err := p.unary()
+		return sqlb.Not(e), err
+	}
+	if t, ok := p.peek(); ok && !t.quoted && t.text == "(" {
+		p.pos++
+		e, err := p.or()
+		if err != nil {
+			return e, err
+		}
+		return e, p.expect(")")
+	}
+	return p.condition()
+}
+
+// condition parses one field comparison.
+func (p *parser) condition() (sqlb.Expr, error) {
+	if p.conditions++; p.conditions > maxConditions {
+		return sqlb.Expr{}, fmt.Errorf("segment has more than %d conditions", maxConditions)
+	}
+	t, ok := p.peek()
+	if !ok || t.quoted || !isWord(rune(t.text[0])) {
+		return sqlb.Expr{}, errors.New("expected a field")
+	}
+	p.pos++
+	field := strings.ToLower(t.text)
+
+	// An activity window such as sign_ins[30d].
+	var window time.Duration
+	if next, ok := p.peek(); ok && !next.quoted && next.text == "[" {
+		p.pos++
+		raw, ok := p.peek()
+		if !ok || raw.quoted {
+			return sqlb.Expr{}, fmt.Errorf("expected a window after %s[", field)
+		}
+		p.pos++
+		d, err := parseWindow(raw.text)
+		if err != nil {
+			return sqlb.Expr{}, err
+		}
+		if err := p.expect("]"); err != nil {
+			return sqlb.Expr{}, err
+		}
+		window = d
+	}
+
+	opToken, ok := p.peek()
+	op, known := sqlOps[opToken.text]
+	if !ok || opToken.quoted || !known {
+		return sqlb.Expr{}, fmt.Errorf("expected =, !=, <, <=, >, or >= after %s", field)
+	}
+	p.pos++
+	value, ok := p.peek()
+	if !ok || (!value.quoted && !isWord(rune(value.text[0]))) {
+		return sqlb.Expr{}, fmt.Errorf("expected a value after %s %s", field, opToken.text)
+	}
+	p.pos++
+
+	if a, ok := activities[field]; ok {
+		return activityCondition(a, field, window, op, value, p.now)
+	}
+	if window != 0 {
+		return sqlb.Expr{}, fmt.Errorf("%s does not take a window", field)
+	}
+	switch field {
+	case "signed_up":
+		if op == "=" || op == "<>" {
+			return sqlb.Expr{}, errors.New("signed_up only supports <, <=, >, and >=")
+		}
+		at, err := parseDate(value.text, p.now)
+		if err != nil {
+			return sqlb.Expr{}, err
+		}
+		return sqlb.Cond("created_at "+op+" ?", at), nil
+	case "tag":
+		// Tags are the names of the groups a user is in.
+		in := sqlb.Cond("id IN (SELECT gm.user_id FROM group_members gm JOIN groups g ON g.id = gm.group_id WHERE g.name = ?)", value.text)
+		switch op {
+		case "=":
+			return in, nil
+		case "<>":
+			return sqlb.Not(in), nil
+		}
+		return sqlb.Expr{}, errors.New("tag only supports = and !=")
+	}
+	return sqlb.Expr{}, fmt.Errorf("unknown field %q", t.text)
+}
+
+// activityCondition compares the number of a user's rows of an activity,
+// within window of now when it is set.
+func activityCondition(a activity, field string, window time.Duration, op string, value token, now time.Time) (sqlb.Expr, error) {
+	n, err := strconv.Atoi(value.text)
+	if value.quoted || err != nil || n < 0 {
+		return sqlb.Expr{}, fmt.Errorf("%s needs a count", field)
+	}
+	count := "SELECT COUNT(*) FROM " + a.table + " WHERE " + a.userID + " = users.id"
+	if window == 0 {
+		return sqlb.Cond("("+count+") "+op+" ?", n), nil
+	}
+	return sqlb.Cond("("+count+" AND created_at >= ?) "+op+" ?", now.Add(-window), n), nil
+}
+
+// parseWindow parses a duration such as 12h, 30d, or 4w.
+func parseWindow(s string) (time.Duration, error) {
+	units := map[byte]time.Duration{'h': time.Hour, 'd': 24 * time.Hour, 'w': 7 * 24 * time.Hour}
+	if len(s) < 2 {
+		return 0, fmt.Errorf("invalid window %q", s)
+	}
+	unit, ok := units[s[len(s)-1]]
+	n, err := strconv.Atoi(s[:len(s)-1])
+	if !ok || err != nil || n < 1 || time.Duration(n) > maxWindow/unit {
+		return 0, fmt.Errorf("invalid window %q; use hours, days, or weeks, e.g. 30d, up to 10 years", s)
+	}
+	return time.Duration(n) * unit, nil
+}
+
+// parseDate parses a date (2024-01-31, midnight UTC), an RFC 3339
+// date-time, or a time relative to now such as now-30d.
+func parseDate(s string, now time.Time) (time.Time, error) {
+	if rel, ok := strings.CutPrefix(strings.ToLower(s), "now-"); ok {
+		d, err := parseWindow(rel)
+		return now.Add(-d), err
+	}
+	if strings.EqualFold(s, "now") {
+		return now, nil
+	}
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q; use 2024-01-31, an RFC 3339 date-time, or now-30d", s)
+}
@@ -0,0 +1,199 @@
+package selfcheck
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"pygorp/backend/internal/database"
+	"pygorp/backend/internal/env"
+)
+
+// Clock skew against the database beyond these is reported. Service tokens
+// and job scheduling compare timestamps across hosts.
+const (
+	skewWarn = 5 * time.Second
+	skewFail = time.Minute
+)
+
+// requirement lists variables that must be set when a setting selects the
+// feature that needs them.
+type requirement struct {
+	when   func() bool
+	vars   []string
+	reason string
+}
+
+var requirements = []requirement{
+	{envIs("STORAGE_DRIVER", "s3"), []string{"S3_BUCKET", "S3_ACCESS_KEY_ID", "S3_SECRET_ACCESS_KEY"}, "STORAGE_DRIVER=s3"},
+	{envIs("BACKUP_DRIVER", "s3"), []string{"BACKUP_S3_BUCKET", "S3_ACCESS_KEY_ID", "S3_SECRET_ACCESS_KEY"}, "BACKUP_DRIVER=s3"},
+	{envListHas("MAIL_PROVIDERS", "sendgrid"), []string{"SENDGRID_API_KEY"}, "MAIL_PROVIDERS includes sendgrid"},
+	{envListHas("MAIL_PROVIDERS", "ses"), []string{"SES_ACCESS_KEY_ID", "SES_SECRET_ACCESS_KEY"}, "MAIL_PROVIDERS includes ses"},
+}
+
+// connected is set once the database check succeeds. Checks that need the
+// database are skipped without it rather than repeating the same error.
+var connected bool
+
+// Default returns the checks run before the server or worker starts. The
+// database check connects database.DB, so later checks can use it.
+func Default() []Check {
+	return []Check{
+		{Name: "env", Critical: true, Run: checkEnv},
+		{Name: "database", Critical: true, Run: checkDatabase},
+		{Name: "migrations", Critical: true, Run: checkMigrations},
+		{Name: "row_level_security", Critical: true, Run: checkRLS},
+		{Name: "temp_dirs", Critical: true, Run: checkTempDirs},
+		{Name: "clock_skew", Critical: true, Run: checkClockSkew},
+	}
+}
+
+func checkEnv(ctx context.Context) (string, error) {
+	var missing []string
+	for _, req := range requirements {
+		if !req.when() {
+			continue
+		}
+		for _, name := range req.vars {
+			if os.Getenv(name) == "" {
+				missing = append(missing, fmt.Sprintf("%s (required by %s)", name, req.reason))
+			}
+		}
+	}
+	if len(missing) > 0 {
+		return "", fmt.Errorf("missing %s", strings.Join(missing, ", "))
+	}
+
+	var unset []string
+	for _, name := range []string{"DB_PASSWORD", "ADMIN_TOKEN", "SVC_AUTH_SECRET"} {
+		if os.Getenv(name) == "" {
+			unset = append(unset, name)
+		}
+	}
+	if len(unset) > 0 && os.Getenv("GIN_MODE") != "debug" {
+		return "", Warning("unset in release mode: " + strings.Join(unset, ", "))
+	}
+	return "required variables set", nil
+}
+
+func checkDatabase(ctx context.Context) (string, error) {
+	if database.DB == nil {
+		if err := database.InitDB(); err != nil {
+			return "", err
+		}
+	}
+	if err := database.DB.PingContext(ctx); err != nil {
+		return "", fmt.Errorf("ping failed: %v", err)
+	}
+	var serverVersion string
+	if err := database.DB.QueryRowContext(ctx, "SHOW server_version").Scan(&serverVersion); err != nil {
+		return "", err
+	}
+	connected = true
+	return "PostgreSQL " + serverVersion, nil
+}
+
+func checkMigrations(ctx context.Context) (string, error) {
+	if !connected {
+		return "", Skipped("no database connection")
+	}
+	migrations, err := database.MigrationStatus()
+	if err != nil {
+		return "", err
+	}
+	var pending []string
+	for _, m := range migrations {
+		if m.AppliedAt == nil {
+			pending = append(pending, fmt.Sprintf("%04d_%s", m.Version, m.Name))
+		}
+	}
+	if len(pending) > 0 {
+		return "", fmt.Errorf("%d pending (%s); run \"pygorp migrate up\"", len(pending), strings.Join(pending, ", "))
+	}
+	return fmt.Sprintf("%d applied", len(migrations)), nil
+}
+
+// checkRLS verifies that with DB_RLS on, the policies apply to the backend's
+// connections.
+func checkRLS(ctx context.Context) (string, error) {
+	if !database.RLSEnabled() {
+		return "off", nil
+	}
+	if !connected {
+		return "", Skipped("no database connection")
+	}
+	if err := database.CheckRLS(ctx); err != nil {
+		return "", err
+	}
+	return "enforced", nil
+}
+
+// checkTempDirs verifies the directories the process writes to: the system
+// temp dir (uploads, backups) and local storage and backup directories.
+func checkTempDirs(ctx context.Context) (string, error) {
+	dirs := []string{os.TempDir()}
+	if env.String("STORAGE_DRIVER", "local") == "local" {
+		dirs = append(dirs, env.String("STORAGE_DIR", "./data"))
+	}
+	if env.String("BACKUP_DRIVER", "local") == "local" {
+		dirs = append(dirs, env.String("BACKUP_DIR", "./backups"))
+	}
+
+	for _, dir := range dirs {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return "", fmt.Errorf("%s: %v", dir, err)
+		}
+		f, err := os.CreateTemp(dir, ".selfcheck-*")
+		if err != nil {
+			return "", fmt.Errorf("%s is not writable: %v", dir, err)
+		}
+		f.Close()
+		os.Remove(f.Name())
+	}
+	return strings.Join(dirs, ", "), nil
+}
+
+// checkClockSkew compares the local clock with the database server's.
+func checkClockSkew(ctx context.Context) (string, error) {
+	if !connected {
+		return "", Skipped("no database connection")
+	}
+	before := time.Now()
+	var dbNow time.Time
+	if err := database.DB.QueryRowContext(ctx, "SELECT now()").Scan(&dbNow); err != nil {
+		return "", err
+	}
+	after := time.Now()
+
+	// Compare against the midpoint to discount the round trip.
+	local := before.Add(after.Sub(before) / 2)
+	skew := local.Sub(dbNow)
+	if skew < 0 {
+		skew = -skew
+	}
+	detail := fmt.Sprintf("%s from database", skew.Round(time.Millisecond))
+	switch {
+	case skew > skewFail:
+		return "", fmt.Errorf("clock is %s; sync the host clock (NTP)", detail)
+	case skew > skewWarn:
+		return "", Warning("clock is " + detail)
+	}
+	return detail, nil
+}
+
+func envIs(key, value string) func() bool {
+	return func() bool { return os.Getenv(key) == value }
+}
+
+func envListHas(key, value string) func() bool {
+	return func() bool {
+		for _, item := range strings.Split(os.Getenv(key), ",") {
+			if strings.TrimSpace(item) == value {
+				return true
+			}
+		}
+		return false
+	}
+}
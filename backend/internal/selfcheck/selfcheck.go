@@ -0,0 +1,110 @@
+package selfcheck
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Check statuses.
+const (
+	StatusOK   = "ok"
+	StatusWarn = "warn"
+	StatusFail = "fail"
+	StatusSkip = "skip"
+)
+
+// Check is one startup check. A failing critical check stops the process;
+// other failures are reported as warnings.
+type Check struct {
+	Name     string
+	Critical bool
+	Run      func(ctx context.Context) (string, error)
+}
+
+// Result is the outcome of one check.
+type Result struct {
+	Name     string        `json:"name"`
+	Status   string        `json:"status"`
+	Critical bool          `json:"critical"`
+	Detail   string        `json:"detail"`
+	Duration time.Duration `json:"duration_ns"`
+}
+
+// Report collects the results of a self-check run.
+type Report struct {
+	Results []Result `json:"results"`
+}
+
+// Warning is returned by a check that passed with a problem worth reporting.
+type Warning string
+
+func (w Warning) Error() string { return string(w) }
+
+// Skipped is returned by a check that could not run because an earlier
+// check failed.
+type Skipped string
+
+func (s Skipped) Error() string { return string(s) }
+
+// Run executes checks in order. Checks run even after a failure so the
+// report lists every problem at once.
+func Run(ctx context.Context, checks []Check) *Report {
+	report := &Report{}
+	for _, check := range checks {
+		start := time.Now()
+		detail, err := check.Run(ctx)
+		result := Result{Name: check.Name, Status: StatusOK, Critical: check.Critical, Detail: detail, Duration: time.Since(start)}
+		if err != nil {
+			result.Detail = err.Error()
+			switch err.(type) {
+			case Skipped:
+				result.Status = StatusSkip
+			case Warning:
+				result.Status = StatusWarn
+			default:
+				result.Status = StatusFail
+				if !check.Critical {
+					result.Status = StatusWarn
+				}
+			}
+		}
+		report.Results = append(report.Results, result)
+	}
+	return report
+}
+
+// Err summarizes the failed critical checks, or returns nil.
+func (r *Report) Err() error {
+	var failed []string
+	for _, result := range r.Results {
+		if result.Status == StatusFail {
+			failed = append(failed, result.Name+": "+result.Detail)
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	return fmt.Errorf("startup checks failed:\n  %s", strings.Join(failed, "\n  "))
+}
+
+// Print writes the report as a table.
+func (r *Report) Print(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "CHECK\tSTATUS\tDETAIL")
+	for _, result := range r.Results {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", result.Name, strings.ToUpper(result.Status), result.Detail)
+	}
+	tw.Flush()
+}
+
+// PrintJSON writes the report as JSON.
+func (r *Report) PrintJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
@@ -0,0 +1,209 @@
+package slo
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"pygorp/backend/internal/config"
+	"pygorp/backend/internal/mailer"
+	"pygorp/backend/internal/metrics"
+	"pygorp/backend/internal/templates"
+)
+
+// Alert severities.
+const (
+	SeverityPage   = "page"
+	SeverityTicket = "ticket"
+)
+
+// rule raises an alert when an objective burns its budget faster than
+// burnRate over both the long and the short window. The short window lets
+// the alert clear soon after the burn stops.
+type rule struct {
+	severity    string
+	long, short time.Duration
+	burnRate    float64
+}
+
+// rules are the multiwindow burn rate alerts of the SRE workbook: a page
+// when an hour spends 2% of a 30-day budget, and a ticket when six hours
+// spend 5% of it.
+var rules = []rule{
+	{severity: SeverityPage, long: time.Hour, short: 5 * time.Minute, burnRate: 14.4},
+	{severity: SeverityTicket, long: 6 * time.Hour, short: 30 * time.Minute, burnRate: 6},
+}
+
+// minRequests is how many requests a rule's long window needs before it
+// can raise an alert, so a few failures on an idle instance do not.
+const minRequests = 100
+
+// evaluateEvery is how often burn rates are checked against the rules.
+const evaluateEvery = time.Minute
+
+// firing holds the alerts raised and not yet cleared, by alertKey.
+var firing struct {
+	sync.Mutex
+	alerts map[string]bool
+}
+
+func alertKey(group, objective, severity string) string {
+	return group + " " + objective + " " + severity
+}
+
+// Window is how an objective did over one window.
+type Window struct {
+	Window   string  `json:"window"`
+	Requests int64   `json:"requests"`
+	Bad      int64   `json:"bad"`
+	BurnRate float64 `json:"burn_rate"`
+}
+
+// Objective is the state of one objective of a route group.
+type Objective struct {
+	Objective string  `json:"objective"`
+	Target    float64 `json:"target"`
+	// Threshold is the latency requests must be answered within, for the
+	// latency objective.
+	Threshold string   `json:"threshold,omitempty"`
+	Windows   []Window `json:"windows"`
+	// Alerts are the severities of the alerts firing.
+	Alerts []string `json:"alerts"`
+}
+
+// Group is the state of the objectives of a route group.
+type Group struct {
+	Group      string      `json:"group"`
+	Objectives []Objective `json:"objectives"`
+}
+
+// objectives returns the objectives set by s, without their windows.
+func objectives(s config.SLO) []Objective {
+	var list []Objective
+	if s.Availability > 0 {
+		list = append(list, Objective{Objective: Availability, Target: s.Availability})
+	}
+	if s.LatencyTarget > 0 {
+		list = append(list, Objective{Objective: Latency, Target: s.LatencyTarget, Threshold: s.Latency})
+	}
+	return list
+}
+
+// Report returns the state of every route group with objectives in the
+// runtime config, ordered by prefix.
+func Report() []Group {
+	slos := config.Current().SLOs
+	now := time.Now()
+	firing.Lock()
+	defer firing.Unlock()
+
+	groups := []Group{}
+	for _, prefix := range sortedKeys(slos) {
+		t := trackerFor(prefix)
+		g := Group{Group: prefix, Objectives: []Objective{}}
+		for _, o := range objectives(slos[prefix]) {
+			for _, w := range Windows {
+				c := t.sum(now, w)
+				bad := c.bad(o.Objective)
+				o.Windows = append(o.Windows, Window{
+					Window:   windowName(w),
+					Requests: c.requests,
+					Bad:      bad,
+					BurnRate: BurnRate(c.requests, bad, o.Target),
+				})
+			}
+			o.Alerts = []string{}
+			for _, r := range rules {
+				if firing.alerts[alertKey(prefix, o.Objective, r.severity)] {
+					o.Alerts = append(o.Alerts, r.severity)
+				}
+			}
+			g.Objectives = append(g.Objectives, o)
+		}
+		groups = append(groups, g)
+	}
+	return groups
+}
+
+// Start checks the objectives every minute until ctx is cancelled.
+func Start(ctx context.Context) {
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(evaluateEvery):
+			}
+			evaluate(ctx)
+		}
+	}()
+}
+
+// evaluate exports the burn rates of every objective and raises or clears
+// its alerts.
+func evaluate(ctx context.Context) {
+	cfg := config.Current()
+	now := time.Now()
+	firing.Lock()
+	defer firing.Unlock()
+	if firing.alerts == nil {
+		firing.alerts = map[string]bool{}
+	}
+
+	for _, prefix := range sortedKeys(cfg.SLOs) {
+		t := trackerFor(prefix)
+		for _, o := range objectives(cfg.SLOs[prefix]) {
+			burn := map[time.Duration]float64{}
+			requests := map[time.Duration]int64{}
+			for _, w := range Windows {
+				c := t.sum(now, w)
+				burn[w] = BurnRate(c.requests, c.bad(o.Objective), o.Target)
+				requests[w] = c.requests
+				metrics.SLOBurnRate.WithLabelValues(prefix, o.Objective, windowName(w)).Set(burn[w])
+			}
+
+			for _, r := range rules {
+				key := alertKey(prefix, o.Objective, r.severity)
+				burning := requests[r.long] >= minRequests && burn[r.long] > r.burnRate && burn[r.short] > r.burnRate
+				switch {
+				case burning && !firing.alerts[key]:
+					firing.alerts[key] = true
+					metrics.SLOAlerts.WithLabelValues(prefix, o.Objective, r.severity).Inc()
+					alert(ctx, cfg.SLOAlertEmails, prefix, o, r, burn[r.long], burn[r.short])
+				case !burning && firing.alerts[key]:
+					delete(firing.alerts, key)
+					log.Printf("SLO %s alert cleared: %s %s", r.severity, prefix, o.Objective)
+				}
+			}
+		}
+	}
+}
+
+// alert logs an alert and emails it to recipients.
+func alert(ctx context.Context, recipients []string, group string, o Objective, r rule, long, short float64) {
+	host, _ := os.Hostname()
+	subject := fmt.Sprintf("SLO %s: %s %s", r.severity, group, o.Objective)
+	message := fmt.Sprintf("The %s objective of %s (%g%%) is burning its error budget %.1f times too fast over the last %s and %.1f times over the last %s, on %s.",
+		o.Objective, group, o.Target*100, long, windowName(r.long), short, windowName(r.short), host)
+	if o.Threshold != "" {
+		message += fmt.Sprintf(" Requests count against it when they take longer than %s.", o.Threshold)
+	}
+	log.Printf("%s. %s", subject, message)
+
+	for _, to := range recipients {
+		if err := mailer.Enqueue(ctx, to, templates.SLOAlert, templates.Data{Subject: subject, Message: message}); err != nil {
+			log.Printf("Failed to queue SLO alert to %s: %v", to, err)
+		}
+	}
+}
+
+// windowName formats a window as 5m or 6h.
+func windowName(d time.Duration) string {
+	if d%time.Hour == 0 {
+		return fmt.Sprintf("%dh", d/time.Hour)
+	}
+	return fmt.Sprintf("%dm", d/time.Minute)
+}
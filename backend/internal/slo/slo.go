@@ -0,0 +1,132 @@
+// Package slo tracks service level objectives per route group in process.
+// A group's availability objective is the share of requests that must not
+// fail with a 5xx, and its latency objective the share that must be
+// answered within a threshold; the runtime config's slos sets both.
+//
+// Requests are counted in one-minute buckets over the last six hours, from
+// which the burn rate of each objective's error budget is computed over
+// several windows: how many times faster than the SLO allows the budget is
+// being spent. An objective burning too fast over both a long and a short
+// window raises an alert, logged and emailed to slo_alert_emails, once until
+// it recovers. Each instance tracks only the requests it serves.
+package slo
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// bucketWidth is the resolution requests are counted at.
+	bucketWidth = time.Minute
+	// history is how far back requests are counted, the longest window.
+	history    = 6 * time.Hour
+	numBuckets = int(history / bucketWidth)
+)
+
+// Objectives.
+const (
+	Availability = "availability"
+	Latency      = "latency"
+)
+
+// Windows are the windows burn rates are reported over.
+var Windows = []time.Duration{5 * time.Minute, 30 * time.Minute, time.Hour, 6 * time.Hour}
+
+type counts struct {
+	requests int64
+	errors   int64
+	slow     int64
+}
+
+// bad returns the requests that missed an objective.
+func (c counts) bad(objective string) int64 {
+	if objective == Latency {
+		return c.slow
+	}
+	return c.errors
+}
+
+type bucket struct {
+	// minute is the Unix minute the bucket counts, so buckets left over
+	// from an earlier lap of the ring are told apart.
+	minute int64
+	counts
+}
+
+// tracker is a ring of per-minute counts for one route group.
+type tracker struct {
+	mu      sync.Mutex
+	buckets [numBuckets]bucket
+}
+
+func (t *tracker) add(now time.Time, failed, slow bool) {
+	minute := now.Unix() / 60
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	b := &t.buckets[minute%int64(numBuckets)]
+	if b.minute != minute {
+		*b = bucket{minute: minute}
+	}
+	b.requests++
+	if failed {
+		b.errors++
+	}
+	if slow {
+		b.slow++
+	}
+}
+
+// sum adds up the counts of the window ending now, current minute included.
+func (t *tracker) sum(now time.Time, window time.Duration) counts {
+	minute := now.Unix() / 60
+	first := minute - int64(window/bucketWidth)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var total counts
+	for _, b := range t.buckets {
+		if b.minute > first && b.minute <= minute {
+			total.requests += b.requests
+			total.errors += b.errors
+			total.slow += b.slow
+		}
+	}
+	return total
+}
+
+var trackers sync.Map // route group prefix -> *tracker
+
+func trackerFor(group string) *tracker {
+	if t, ok := trackers.Load(group); ok {
+		return t.(*tracker)
+	}
+	t, _ := trackers.LoadOrStore(group, &tracker{})
+	return t.(*tracker)
+}
+
+// Record counts a request to a route group: failed when its status is a
+// 5xx, and slow when it took longer than threshold, if one is set.
+func Record(group string, status int, took, threshold time.Duration) {
+	trackerFor(group).add(time.Now(), status >= 500, threshold > 0 && took > threshold)
+}
+
+// BurnRate is the share of requests that missed an objective divided by
+// the share its target allows. Without requests it is zero.
+func BurnRate(requests, bad int64, target float64) float64 {
+	if requests == 0 {
+		return 0
+	}
+	return (float64(bad) / float64(requests)) / (1 - target)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,48 @@
+package sqlb
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Page is a limit/offset window. A zero Limit means no limit.
+type Page struct {
+	Limit  int
+	Offset int
+}
+
+// ParseSort turns a sort parameter such as "-created_at,name" into ORDER BY
+// terms. Fields map API names to columns; anything else is rejected, which
+// keeps user input out of the SQL.
+func ParseSort(param string, fields map[string]string) ([]string, error) {
+	if param == "" {
+		return nil, nil
+	}
+
+	var terms []string
+	for _, field := range strings.Split(param, ",") {
+		field = strings.TrimSpace(field)
+		direction := "ASC"
+		if strings.HasPrefix(field, "-") {
+			direction = "DESC"
+			field = field[1:]
+		}
+		column, ok := fields[field]
+		if !ok {
+			allowed := make([]string, 0, len(fields))
+			for name := range fields {
+				allowed = append(allowed, name)
+			}
+			sort.Strings(allowed)
+			return nil, fmt.Errorf("cannot sort by %q (allowed: %s)", field, strings.Join(allowed, ", "))
+		}
+		terms = append(terms, column+" "+direction)
+	}
+	return terms, nil
+}
+
+// EscapeLike escapes LIKE wildcards so input matches literally.
+func EscapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
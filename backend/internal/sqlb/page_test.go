@@ -0,0 +1,59 @@
+package sqlb
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseSort(t *testing.T) {
+	fields := map[string]string{"name": "name", "created_at": "users.created_at"}
+
+	for _, tc := range []struct {
+		param string
+		terms []string
+	}{
+		{"", nil},
+		{"name", []string{"name ASC"}},
+		{"-created_at, name", []string{"users.created_at DESC", "name ASC"}},
+	} {
+		terms, err := ParseSort(tc.param, fields)
+		if err != nil {
+			t.Errorf("ParseSort(%q): %v", tc.param, err)
+			continue
+		}
+		if !reflect.DeepEqual(terms, tc.terms) {
+			t.Errorf("ParseSort(%q) = %q, want %q", tc.param, terms, tc.terms)
+		}
+	}
+
+	for _, param := range []string{"email", "users.created_at", "name;DROP TABLE users", "name DESC", "--name", "name,"} {
+		if terms, err := ParseSort(param, fields); err == nil {
+			t.Errorf("ParseSort(%q) = %q, want an error", param, terms)
+		}
+	}
+}
+
+func TestEscapeLike(t *testing.T) {
+	if got, want := EscapeLike(`50%_off\`), `50\%\_off\\`; got != want {
+		t.Errorf("EscapeLike = %q, want %q", got, want)
+	}
+}
+
+// FuzzSortParam parses sort parameters and checks that only whitelisted
+// columns and directions reach ORDER BY.
+func FuzzSortParam(f *testing.F) {
+	fields := map[string]string{"id": "id", "name": "name", "created_at": "created_at"}
+	f.Fuzz(func(t *testing.T, param string) {
+		terms, err := ParseSort(param, fields)
+		if err != nil {
+			return
+		}
+		for _, term := range terms {
+			column, direction, _ := strings.Cut(term, " ")
+			if fields[column] != column || (direction != "ASC" && direction != "DESC") {
+				t.Fatalf("%q produced ORDER BY term %q", param, term)
+			}
+		}
+	})
+}
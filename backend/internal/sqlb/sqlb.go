@@ -0,0 +1,158 @@
+// Package sqlb builds SELECT statements from composable parts. Conditions
+// use ? placeholders, which are numbered ($1, $2, ...) when the statement is
+// built, so filters can be added in any order without tracking positions.
+package sqlb
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Select starts a SELECT of the given columns.
+func Select(columns ...string) *SelectBuilder {
+	return &SelectBuilder{columns: columns}
+}
+
+type SelectBuilder struct {
+	columns []string
+	from    string
+	where   []Expr
+	orderBy []string
+	limit   int
+	offset  int
+}
+
+// Expr is a SQL fragment with ? placeholders and their arguments.
+type Expr struct {
+	SQL  string
+	Args []interface{}
+}
+
+// Cond creates an Expr. The number of ? must match len(args).
+func Cond(sql string, args ...interface{}) Expr {
+	return Expr{SQL: sql, Args: args}
+}
+
+// Or joins expressions with OR, wrapped in parentheses.
+func Or(exprs ...Expr) Expr {
+	return join(exprs, " OR ")
+}
+
+// And joins expressions with AND, wrapped in parentheses.
+func And(exprs ...Expr) Expr {
+	return join(exprs, " AND ")
+}
+
+// Not negates an expression.
+func Not(e Expr) Expr {
+	return Expr{SQL: "NOT (" + e.SQL + ")", Args: e.Args}
+}
+
+func (b *SelectBuilder) From(table string) *SelectBuilder {
+	b.from = table
+	return b
+}
+
+// Where adds a condition; conditions are joined with AND.
+func (b *SelectBuilder) Where(sql string, args ...interface{}) *SelectBuilder {
+	return b.WhereExpr(Cond(sql, args...))
+}
+
+func (b *SelectBuilder) WhereExpr(e Expr) *SelectBuilder {
+	b.where = append(b.where, e)
+	return b
+}
+
+// OrderBy appends sort terms. Terms are trusted SQL: build them from a
+// whitelist, e.g. with ParseSort, never from raw input.
+func (b *SelectBuilder) OrderBy(terms ...string) *SelectBuilder {
+	b.orderBy = append(b.orderBy, terms...)
+	return b
+}
+
+// Limit sets the row limit. Zero means no limit.
+func (b *SelectBuilder) Limit(n int) *SelectBuilder {
+	b.limit = n
+	return b
+}
+
+func (b *SelectBuilder) Offset(n int) *SelectBuilder {
+	b.offset = n
+	return b
+}
+
+// Page applies a limit and offset.
+func (b *SelectBuilder) Page(p Page) *SelectBuilder {
+	return b.Limit(p.Limit).Offset(p.Offset)
+}
+
+// Build returns the statement with numbered placeholders and its arguments.
+func (b *SelectBuilder) Build() (string, []interface{}, error) {
+	if len(b.columns) == 0 || b.from == "" {
+		return "", nil, errors.New("sqlb: select needs columns and a table")
+	}
+
+	var sb strings.Builder
+	sb.WriteString("SELECT " + strings.Join(b.columns, ", ") + " FROM " + b.from)
+
+	var args []interface{}
+	if len(b.where) > 0 {
+		parts := make([]string, len(b.where))
+		for i, e := range b.where {
+			parts[i] = e.SQL
+			args = append(args, e.Args...)
+		}
+		sb.WriteString(" WHERE " + strings.Join(parts, " AND "))
+	}
+	if len(b.orderBy) > 0 {
+		sb.WriteString(" ORDER BY " + strings.Join(b.orderBy, ", "))
+	}
+	if b.limit > 0 {
+		sb.WriteString(" LIMIT ?")
+		args = append(args, b.limit)
+	}
+	if b.offset > 0 {
+		sb.WriteString(" OFFSET ?")
+		args = append(args, b.offset)
+	}
+
+	query, err := numberPlaceholders(sb.String(), len(args))
+	return query, args, err
+}
+
+func join(exprs []Expr, sep string) Expr {
+	parts := make([]string, len(exprs))
+	var args []interface{}
+	for i, e := range exprs {
+		parts[i] = e.SQL
+		args = append(args, e.Args...)
+	}
+	if len(parts) == 1 {
+		return Expr{SQL: parts[0], Args: args}
+	}
+	return Expr{SQL: "(" + strings.Join(parts, sep) + ")", Args: args}
+}
+
+// numberPlaceholders rewrites ? to $1, $2, ... outside quoted strings.
+func numberPlaceholders(query string, want int) (string, error) {
+	var sb strings.Builder
+	n := 0
+	inQuote := false
+	for _, r := range query {
+		switch {
+		case r == '\'':
+			inQuote = !inQuote
+			sb.WriteRune(r)
+		case r == '?' && !inQuote:
+			n++
+			fmt.Fprintf(&sb, "$%d", n)
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	if n != want {
+		return "", fmt.Errorf("sqlb: %d placeholders for %d arguments", n, want)
+	}
+	return sb.String(), nil
+}
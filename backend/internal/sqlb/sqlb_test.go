@@ -0,0 +1,105 @@
+package sqlb
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBuild(t *testing.T) {
+	after := time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)
+
+	for _, tc := range []struct {
+		name  string
+		build *SelectBuilder
+		query string
+		args  []interface{}
+	}{
+		{
+			name:  "plain",
+			build: Select("id", "name").From("users"),
+			query: "SELECT id, name FROM users",
+		},
+		{
+			name:  "filters are joined with AND",
+			build: Select("id").From("users").Where("email ILIKE ?", "%@example.com").Where("team_id = ?", 7),
+			query: "SELECT id FROM users WHERE email ILIKE $1 AND team_id = $2",
+			args:  []interface{}{"%@example.com", 7},
+		},
+		{
+			name: "nested expressions keep argument order",
+			build: Select("id").From("users").WhereExpr(And(
+				Or(Cond("name = ?", "a"), Cond("name = ?", "b")),
+				Not(Cond("id = ANY(?)", "{1,2}")),
+			)),
+			query: "SELECT id FROM users WHERE ((name = $1 OR name = $2) AND NOT (id = ANY($3)))",
+			args:  []interface{}{"a", "b", "{1,2}"},
+		},
+		{
+			name:  "a single expression is not wrapped",
+			build: Select("id").From("users").WhereExpr(Or(Cond("id = ?", 1))),
+			query: "SELECT id FROM users WHERE id = $1",
+			args:  []interface{}{1},
+		},
+		{
+			name:  "sorting",
+			build: Select("id").From("users").OrderBy("created_at DESC").OrderBy("id DESC"),
+			query: "SELECT id FROM users ORDER BY created_at DESC, id DESC",
+		},
+		{
+			name:  "limit and offset follow the filter arguments",
+			build: Select("id").From("users").Where("team_id = ?", 7).Page(Page{Limit: 50, Offset: 100}),
+			query: "SELECT id FROM users WHERE team_id = $1 LIMIT $2 OFFSET $3",
+			args:  []interface{}{7, 50, 100},
+		},
+		{
+			name:  "zero limit and offset are left out",
+			build: Select("id").From("users").Page(Page{}),
+			query: "SELECT id FROM users",
+		},
+		{
+			name: "cursor pagination",
+			build: Select("id").From("users").
+				Where("team_id = ?", 7).
+				Where("(created_at, id) < (?, ?)", after, 42).
+				OrderBy("created_at DESC", "id DESC").
+				Limit(20),
+			query: "SELECT id FROM users WHERE team_id = $1 AND (created_at, id) < ($2, $3) ORDER BY created_at DESC, id DESC LIMIT $4",
+			args:  []interface{}{7, after, 42, 20},
+		},
+		{
+			name:  "question marks in string literals are not placeholders",
+			build: Select("id").From("users").Where("name <> '?' AND email = ?", "a@example.com"),
+			query: "SELECT id FROM users WHERE name <> '?' AND email = $1",
+			args:  []interface{}{"a@example.com"},
+		},
+	} {
+		query, args, err := tc.build.Build()
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if query != tc.query {
+			t.Errorf("%s: query\n got %s\nwant %s", tc.name, query, tc.query)
+		}
+		if !reflect.DeepEqual(args, tc.args) {
+			t.Errorf("%s: args = %v, want %v", tc.name, args, tc.args)
+		}
+	}
+}
+
+func TestBuildErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		build *SelectBuilder
+	}{
+		{"no table", Select("id")},
+		{"no columns", Select().From("users")},
+		{"too few arguments", Select("id").From("users").Where("id = ? OR id = ?", 1)},
+		{"too many arguments", Select("id").From("users").Where("id = ?", 1, 2)},
+	} {
+		if query, _, err := tc.build.Build(); err == nil {
+			t.Errorf("%s: built %q, want an error", tc.name, query)
+		}
+	}
+}
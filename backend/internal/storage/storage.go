@@ -0,0 +1,109 @@
+// Package storage keeps uploaded and generated files in object storage.
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"pygorp/backend/internal/env"
+)
+
+// ErrNotFound is returned when an object does not exist.
+var ErrNotFound = errors.New("object not found")
+
+// Store is an object store addressed by slash-separated keys.
+type Store interface {
+	Put(ctx context.Context, key, contentType string, data []byte) error
+	Get(ctx context.Context, key string) ([]byte, error)
+	// PutFile and GetFile move an object to and from a local file without
+	// holding it in memory, for objects such as backups that may be large.
+	PutFile(ctx context.Context, key, contentType, path string) error
+	GetFile(ctx context.Context, key, path string) error
+	Delete(ctx context.Context, key string) error
+	// List returns the objects whose keys start with prefix, sorted by key.
+	List(ctx context.Context, prefix string) ([]Object, error)
+	// URL returns the address clients use to fetch the object.
+	URL(key string) string
+}
+
+// Presigner is implemented by stores that clients can upload to directly,
+// such as S3, without sending the bytes through the API.
+type Presigner interface {
+	// PresignPut returns a URL that accepts one PUT of key with the given
+	// Content-Type until it expires.
+	PresignPut(key, contentType string, expires time.Duration) (string, error)
+	// Stat describes an object without fetching it.
+	Stat(ctx context.Context, key string) (Object, error)
+}
+
+// Object describes a stored object.
+type Object struct {
+	Key          string    `json:"key"`
+	Size         int64     `json:"size"`
+	LastModified time.Time `json:"last_modified"`
+}
+
+var (
+	defaultStore Store
+	initErr      error
+	once         sync.Once
+)
+
+// Default returns the store configured by STORAGE_DRIVER ("local" or "s3").
+func Default() (Store, error) {
+	once.Do(func() {
+		switch driver := env.String("STORAGE_DRIVER", "local"); driver {
+		case "local":
+			defaultStore = NewLocal(env.String("STORAGE_DIR", "./data"), env.String("STORAGE_PUBLIC_URL", "/media"))
+		case "s3":
+			defaultStore = NewS3(S3Config{
+				Endpoint:        os.Getenv("S3_ENDPOINT"),
+				Region:          env.String("S3_REGION", "us-east-1"),
+				Bucket:          os.Getenv("S3_BUCKET"),
+				AccessKeyID:     os.Getenv("S3_ACCESS_KEY_ID"),
+				SecretAccessKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+				PublicURL:       os.Getenv("STORAGE_PUBLIC_URL"),
+			})
+		default:
+			initErr = fmt.Errorf("unknown storage driver %q", driver)
+		}
+		if initErr == nil {
+			log.Printf("Using %s object storage", env.String("STORAGE_DRIVER", "local"))
+		}
+	})
+	return defaultStore, initErr
+}
+
+// ContentKey builds a content-addressed key so identical files share one
+// object and keys never need invalidating.
+func ContentKey(prefix string, data []byte, ext string) string {
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	return strings.Trim(prefix, "/") + "/" + hash[:2] + "/" + hash + ext
+}
+
+// copyToFile writes r to the file at path, removing the file if the copy
+// fails part way.
+func copyToFile(path string, r io.Reader) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, r)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path)
+	}
+	return err
+}
@@ -0,0 +1,151 @@
+// Package svcauth issues and verifies the short-lived tokens that the Go
+// backend and the Python service use to authenticate calls to each other.
+//
+// Tokens are HS256 JWTs signed with the shared SVC_AUTH_SECRET. During
+// rotation SVC_AUTH_PREVIOUS_SECRET is still accepted for verification.
+package svcauth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"pygorp/backend/internal/clock"
+)
+
+// Header carries the service token, leaving Authorization free for the
+// end user's credentials.
+const Header = "X-Service-Token"
+
+// Service identities, used as issuer and audience.
+const (
+	Backend = "pygorp-backend"
+	Python  = "pygorp-python"
+)
+
+// DefaultTTL keeps tokens short-lived so a leaked one is of little use.
+const DefaultTTL = time.Minute
+
+// clockSkew tolerates small clock differences between services.
+const clockSkew = 30 * time.Second
+
+var (
+	ErrDisabled     = errors.New("service auth is not configured")
+	ErrInvalidToken = errors.New("invalid service token")
+	ErrExpired      = errors.New("service token has expired")
+	ErrAudience     = errors.New("service token has the wrong audience")
+)
+
+// Claims are the registered JWT claims used between services. Subject
+// optionally names the end user the call is made on behalf of.
+type Claims struct {
+	Issuer    string `json:"iss"`
+	Audience  string `json:"aud"`
+	Subject   string `json:"sub,omitempty"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+	ID        string `json:"jti"`
+}
+
+var header = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// Enabled reports whether a shared secret is configured.
+func Enabled() bool {
+	return os.Getenv("SVC_AUTH_SECRET") != ""
+}
+
+// Issue creates a token from the backend to audience, valid for DefaultTTL.
+func Issue(audience, subject string) (string, error) {
+	secret := os.Getenv("SVC_AUTH_SECRET")
+	if secret == "" {
+		return "", ErrDisabled
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	now := clock.Now()
+	claims := Claims{
+		Issuer:    Backend,
+		Audience:  audience,
+		Subject:   subject,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(DefaultTTL).Unix(),
+		ID:        hex.EncodeToString(id),
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := header + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return unsigned + "." + sign(unsigned, secret), nil
+}
+
+// Verify checks the token's signature, expiry, and audience.
+func Verify(token, audience string) (*Claims, error) {
+	secrets := []string{os.Getenv("SVC_AUTH_SECRET"), os.Getenv("SVC_AUTH_PREVIOUS_SECRET")}
+	if secrets[0] == "" {
+		return nil, ErrDisabled
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+	var head struct {
+		Alg string `json:"alg"`
+	}
+	rawHeader, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil || json.Unmarshal(rawHeader, &head) != nil || head.Alg != "HS256" {
+		return nil, ErrInvalidToken
+	}
+
+	unsigned := parts[0] + "." + parts[1]
+	valid := false
+	for _, secret := range secrets {
+		if secret != "" && hmac.Equal([]byte(parts[2]), []byte(sign(unsigned, secret))) {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return nil, ErrInvalidToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	var claims Claims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	now := clock.Now()
+	if now.After(time.Unix(claims.ExpiresAt, 0).Add(clockSkew)) {
+		return nil, ErrExpired
+	}
+	if time.Unix(claims.IssuedAt, 0).After(now.Add(clockSkew)) {
+		return nil, fmt.Errorf("%w: issued in the future", ErrInvalidToken)
+	}
+	if claims.Audience != audience {
+		return nil, ErrAudience
+	}
+	return &claims, nil
+}
+
+func sign(unsigned, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(unsigned))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
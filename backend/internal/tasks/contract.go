@@ -0,0 +1,83 @@
+package tasks
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// This file is the contract between the backend and the Python worker
+// (ai-service/worker.py). Keep the two in sync and bump ProtocolVersion on
+// incompatible changes.
+
+// ProtocolVersion is sent with every request so the worker can reject
+// messages it does not understand.
+const ProtocolVersion = 1
+
+// QueueKey is the Redis list the backend pushes requests onto and the
+// worker pops from.
+const QueueKey = "pygorp:tasks"
+
+// ReplyKeyPrefix plus the task ID names the Redis list the worker pushes
+// replies onto.
+const ReplyKeyPrefix = "pygorp:task_replies:"
+
+// Kinds lists the tasks the Python worker implements and the input fields
+// each one requires.
+var Kinds = map[string][]string{
+	"analyze.text":  {"text"},
+	"analyze.image": {},
+	"ml.predict":    {"model_name", "input_data"},
+}
+
+// Request asks the worker to run a task.
+type Request struct {
+	Version  int             `json:"version"`
+	ID       string          `json:"id"`
+	Kind     string          `json:"kind"`
+	Input    json.RawMessage `json:"input"`
+	ReplyTo  string          `json:"reply_to"`
+	Deadline time.Time       `json:"deadline"`
+}
+
+// Reply types
+const (
+	ReplyProgress = "progress"
+	ReplyResult   = "result"
+	ReplyError    = "error"
+)
+
+// Reply reports progress or the outcome of a task. A task sends any number
+// of progress replies followed by exactly one result or error.
+type Reply struct {
+	Type     string          `json:"type"`
+	Progress int             `json:"progress,omitempty"`
+	Result   json.RawMessage `json:"result,omitempty"`
+	Error    string          `json:"error,omitempty"`
+}
+
+// Validate checks that kind is known and input has its required fields.
+func Validate(kind string, input json.RawMessage) error {
+	required, ok := Kinds[kind]
+	if !ok {
+		known := make([]string, 0, len(Kinds))
+		for k := range Kinds {
+			known = append(known, k)
+		}
+		sort.Strings(known)
+		return fmt.Errorf("unknown task kind %q (expected one of %s)", kind, strings.Join(known, ", "))
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(input, &fields); err != nil {
+		return fmt.Errorf("input must be a JSON object")
+	}
+	for _, name := range required {
+		if _, ok := fields[name]; !ok {
+			return fmt.Errorf("input.%s is required for %s tasks", name, kind)
+		}
+	}
+	return nil
+}
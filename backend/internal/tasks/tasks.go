@@ -0,0 +1,116 @@
+// Package tasks offloads compute work to the Python worker. Each task is an
+// operation: clients poll it through the operations API while a Go job
+// dispatches the request over Redis and relays the worker's replies.
+package tasks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"pygorp/backend/internal/operations"
+	"pygorp/backend/internal/redis"
+)
+
+// OperationType is the operation type for offloaded tasks.
+const OperationType = "python.task"
+
+const (
+	DefaultTimeout = 5 * time.Minute
+	MaxTimeout     = time.Hour
+)
+
+// pollInterval bounds each wait for a reply so shutdown is noticed promptly.
+const pollInterval = 5 * time.Second
+
+// Params are stored with the operation.
+type Params struct {
+	Kind           string          `json:"kind"`
+	Input          json.RawMessage `json:"input"`
+	TimeoutSeconds int             `json:"timeout_seconds,omitempty"`
+}
+
+func (p Params) timeout() time.Duration {
+	if p.TimeoutSeconds <= 0 {
+		return DefaultTimeout
+	}
+	if d := time.Duration(p.TimeoutSeconds) * time.Second; d < MaxTimeout {
+		return d
+	}
+	return MaxTimeout
+}
+
+func init() {
+	operations.Register(OperationType, run)
+}
+
+// run dispatches the task and waits for the worker's result, recording
+// progress as it arrives. A retry of the job dispatches the task again.
+func run(ctx context.Context, task *operations.Task) (*operations.Result, error) {
+	var p Params
+	if err := json.Unmarshal(task.Params, &p); err != nil {
+		return nil, fmt.Errorf("invalid task params: %v", err)
+	}
+
+	client, err := redis.Default()
+	if err != nil {
+		return nil, err
+	}
+
+	replyKey := ReplyKeyPrefix + task.OperationID
+	deadline := time.Now().Add(p.timeout())
+
+	// Drop replies left over from an earlier attempt.
+	if err := client.Del(ctx, replyKey); err != nil {
+		return nil, fmt.Errorf("failed to reach redis: %v", err)
+	}
+
+	req, err := json.Marshal(Request{
+		Version:  ProtocolVersion,
+		ID:       task.OperationID,
+		Kind:     p.Kind,
+		Input:    p.Input,
+		ReplyTo:  replyKey,
+		Deadline: deadline.UTC(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := client.LPush(ctx, QueueKey, string(req)); err != nil {
+		return nil, fmt.Errorf("failed to dispatch task: %v", err)
+	}
+
+	for {
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return nil, fmt.Errorf("task %s timed out after %s", p.Kind, p.timeout())
+		}
+
+		msg, err := client.BRPop(ctx, replyKey, min(remaining, pollInterval))
+		if errors.Is(err, redis.ErrNil) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read task reply: %v", err)
+		}
+
+		var reply Reply
+		if err := json.Unmarshal([]byte(msg), &reply); err != nil {
+			return nil, fmt.Errorf("invalid task reply: %v", err)
+		}
+		switch reply.Type {
+		case ReplyProgress:
+			task.SetProgress(ctx, reply.Progress)
+		case ReplyResult:
+			client.Del(ctx, replyKey)
+			return &operations.Result{Data: reply.Result}, nil
+		case ReplyError:
+			client.Del(ctx, replyKey)
+			return nil, fmt.Errorf("task failed: %s", reply.Error)
+		default:
+			return nil, fmt.Errorf("unknown task reply type %q", reply.Type)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package templates
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	texttemplate "text/template"
+
+	"pygorp/backend/internal/env"
+
+	"github.com/gin-gonic/gin"
+)
+
+//go:embed emails/*.html
+var embedded embed.FS
+
+// Email names
+const (
+	Verification   = "verification"
+	PasswordReset  = "password_reset"
+	Welcome        = "welcome"
+	MagicLink      = "magic_link"
+	ExportPassword = "export_password"
+	OrgInvitation  = "org_invitation"
+	Mention        = "mention"
+	Announcement   = "announcement"
+	SLOAlert       = "slo_alert"
+)
+
+// Campaigns lists the templates that can be sent to a segment of users.
+// The others carry per-message tokens or context.
+var Campaigns = []string{Announcement, Welcome}
+
+// Data is the set of values available to email templates.
+type Data struct {
+	AppName   string
+	Name      string
+	Link      string
+	ExpiresIn string
+	// Org and InvitedBy name the org and the person behind an invitation.
+	Org       string
+	InvitedBy string
+	// Author, Project, and Excerpt describe a comment that mentions the
+	// recipient.
+	Author  string
+	Project string
+	Excerpt string
+	// Subject and Message are the content of an announcement or an SLO
+	// alert.
+	Subject string
+	Message string
+	// Password opens an encrypted export. It is only ever sent directly,
+	// never through the job queue, so it is not stored.
+	Password string
+}
+
+// Email is a rendered message ready to hand to the mailer.
+type Email struct {
+	Subject string `json:"subject"`
+	HTML    string `json:"html"`
+	Text    string `json:"text"`
+}
+
+// parsed holds one email parsed twice: as HTML for the body, which is
+// escaped, and as text for the subject and plain-text part, which are not.
+type parsed struct {
+	html *htmltemplate.Template
+	text *texttemplate.Template
+}
+
+var (
+	mu    sync.Mutex
+	cache = map[string]*parsed{}
+)
+
+// Names lists the available email templates.
+func Names() []string {
+	entries, _ := embedded.ReadDir("emails")
+	var names []string
+	for _, e := range entries {
+		name := strings.TrimSuffix(e.Name(), ".html")
+		if name != "layout" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Render executes the named email template. A file with the same name in
+// TEMPLATES_DIR overrides the embedded one, as does layout.html.
+func Render(name string, data Data) (*Email, error) {
+	if data.AppName == "" {
+		data.AppName = env.String("APP_NAME", "PyGoRP")
+	}
+
+	tmpl, err := load(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var subject, html, text bytes.Buffer
+	if err := tmpl.text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return nil, fmt.Errorf("failed to render %s subject: %v", name, err)
+	}
+	if err := tmpl.html.ExecuteTemplate(&html, "layout", data); err != nil {
+		return nil, fmt.Errorf("failed to render %s html: %v", name, err)
+	}
+	if err := tmpl.text.ExecuteTemplate(&text, "text", data); err != nil {
+		return nil, fmt.Errorf("failed to render %s text: %v", name, err)
+	}
+
+	return &Email{
+		Subject: strings.TrimSpace(subject.String()),
+		HTML:    html.String(),
+		Text:    strings.TrimSpace(text.String()) + "\n",
+	}, nil
+}
+
+// load parses a template with the layout, caching the result outside of
+// debug mode so template edits show up immediately in development.
+func load(name string) (*parsed, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if tmpl, ok := cache[name]; ok && !gin.IsDebugging() {
+		return tmpl, nil
+	}
+
+	layout, err := read("layout")
+	if err != nil {
+		return nil, err
+	}
+	body, err := read(name)
+	if err != nil {
+		return nil, err
+	}
+
+	html, err := htmltemplate.New(name).Parse(layout)
+	if err == nil {
+		_, err = html.Parse(body)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template %s: %v", name, err)
+	}
+	text, err := texttemplate.New(name).Parse(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template %s: %v", name, err)
+	}
+
+	tmpl := &parsed{html: html, text: text}
+	cache[name] = tmpl
+	return tmpl, nil
+}
+
+func read(name string) (string, error) {
+	if dir := os.Getenv("TEMPLATES_DIR"); dir != "" {
+		data, err := os.ReadFile(filepath.Join(dir, name+".html"))
+		if err == nil {
+			return string(data), nil
+		}
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+	}
+
+	data, err := embedded.ReadFile("emails/" + name + ".html")
+	if err != nil {
+		return "", fmt.Errorf("unknown email template %q", name)
+	}
+	return string(data), nil
+}
@@ -0,0 +1,197 @@
+// Package testutil holds helpers for handler tests. Golden compares a JSON
+// response with a file under testdata/, after replacing the values that
+// change between runs; run the tests with -update to rewrite the files.
+package testutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files with the current output")
+
+var (
+	uuidPattern      = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	timestampPattern = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:?\d{2})?$`)
+)
+
+// Option adjusts normalization.
+type Option func(*normalizer)
+
+// IgnoreKeys replaces the values of the named object keys, at any depth,
+// with "<key>". Use it for values that vary but are neither UUIDs nor
+// timestamps, such as serial IDs or request IDs.
+func IgnoreKeys(keys ...string) Option {
+	return func(n *normalizer) {
+		for _, k := range keys {
+			n.ignore[k] = true
+		}
+	}
+}
+
+// Golden compares got, a JSON document, with testdata/<name>.json. UUIDs
+// and timestamps are replaced with numbered placeholders such as "<uuid-1>"
+// first, so the same value keeps the same placeholder and the file still
+// shows which fields are equal.
+func Golden(t testing.TB, name string, got []byte, opts ...Option) {
+	t.Helper()
+
+	normalized, err := Normalize(got, opts...)
+	if err != nil {
+		t.Fatalf("golden %s: %v", name, err)
+	}
+
+	path := filepath.Join("testdata", name+".json")
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("golden %s: %v", name, err)
+		}
+		if err := os.WriteFile(path, normalized, 0o644); err != nil {
+			t.Fatalf("golden %s: %v", name, err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		t.Fatalf("golden file %s does not exist; run the test with -update to create it", path)
+	}
+	if err != nil {
+		t.Fatalf("golden %s: %v", name, err)
+	}
+	if !bytes.Equal(want, normalized) {
+		t.Errorf("response differs from %s (run with -update to accept):\n%s", path, diff(string(want), string(normalized)))
+	}
+}
+
+// GoldenResponse records the status and JSON body of a handler response
+// and compares them with testdata/<name>.json.
+func GoldenResponse(t testing.TB, name string, w *httptest.ResponseRecorder, opts ...Option) {
+	t.Helper()
+
+	body := json.RawMessage(w.Body.Bytes())
+	if len(bytes.TrimSpace(body)) == 0 {
+		body = json.RawMessage("null")
+	}
+	doc, err := json.Marshal(struct {
+		Status int             `json:"status"`
+		Body   json.RawMessage `json:"body"`
+	}{w.Code, body})
+	if err != nil {
+		t.Fatalf("golden %s: response is not JSON: %v", name, err)
+	}
+	Golden(t, name, doc, opts...)
+}
+
+// Normalize decodes a JSON document, replaces varying values, and encodes
+// it again indented with sorted keys.
+func Normalize(doc []byte, opts ...Option) ([]byte, error) {
+	var v interface{}
+	dec := json.NewDecoder(bytes.NewReader(doc))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %v", err)
+	}
+
+	n := &normalizer{ignore: map[string]bool{}, seen: map[string]string{}, counts: map[string]int{}}
+	for _, opt := range opts {
+		opt(n)
+	}
+
+	// Placeholders contain angle brackets, which Marshal would escape.
+	var out bytes.Buffer
+	enc := json.NewEncoder(&out)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(n.walk(v)); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+type normalizer struct {
+	ignore map[string]bool
+	seen   map[string]string
+	counts map[string]int
+}
+
+func (n *normalizer) walk(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		// Visit keys in the order they are encoded, so placeholders are
+		// numbered the same way on every run.
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if n.ignore[k] && v[k] != nil {
+				v[k] = "<" + k + ">"
+				continue
+			}
+			v[k] = n.walk(v[k])
+		}
+		return v
+	case []interface{}:
+		for i := range v {
+			v[i] = n.walk(v[i])
+		}
+		return v
+	case string:
+		switch {
+		case uuidPattern.MatchString(v):
+			return n.placeholder("uuid", v)
+		case timestampPattern.MatchString(v):
+			return n.placeholder("timestamp", v)
+		}
+	}
+	return v
+}
+
+// placeholder numbers distinct values of a kind in the order they are met.
+func (n *normalizer) placeholder(kind, value string) string {
+	key := kind + "\x00" + value
+	if p, ok := n.seen[key]; ok {
+		return p
+	}
+	n.counts[kind]++
+	p := fmt.Sprintf("<%s-%d>", kind, n.counts[kind])
+	n.seen[key] = p
+	return p
+}
+
+// diff shows the first differing line of two documents with a little
+// context, which is usually enough to spot a serializer change.
+func diff(want, got string) string {
+	wl, gl := strings.Split(want, "\n"), strings.Split(got, "\n")
+	i := 0
+	for i < len(wl) && i < len(gl) && wl[i] == gl[i] {
+		i++
+	}
+	start := i - 3
+	if start < 0 {
+		start = 0
+	}
+
+	var b strings.Builder
+	for j := start; j < i; j++ {
+		fmt.Fprintf(&b, "  %s\n", wl[j])
+	}
+	for j := i; j < i+3 && j < len(wl); j++ {
+		fmt.Fprintf(&b, "- %s\n", wl[j])
+	}
+	for j := i; j < i+3 && j < len(gl); j++ {
+		fmt.Fprintf(&b, "+ %s\n", gl[j])
+	}
+	return b.String()
+}
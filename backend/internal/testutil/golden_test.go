@@ -0,0 +1,76 @@
+package testutil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const userJSON = `{
+	"data": {
+		"id": 42,
+		"org_id": "0b6cbb71-1b36-4b8c-9d8f-0a3c5d7e9f10",
+		"created_by": "7f1e2d3c-4b5a-4968-8776-655443322110",
+		"owner": "0B6CBB71-1B36-4B8C-9D8F-0A3C5D7E9F10",
+		"email": "jane@example.com",
+		"created_at": "2026-10-16T09:15:20Z",
+		"updated_at": "2026-10-16T09:15:20.123456+02:00",
+		"deleted_at": null,
+		"last_seen": "2026-10-16 09:15:21",
+		"attributes": {"request_id": "abc123", "tags": ["x", "2026-01-01T00:00:00Z"]}
+	},
+	"request_id": null
+}`
+
+func TestGolden(t *testing.T) {
+	Golden(t, "user", []byte(userJSON), IgnoreKeys("id", "request_id"))
+}
+
+func TestGoldenResponse(t *testing.T) {
+	w := httptest.NewRecorder()
+	w.WriteHeader(http.StatusCreated)
+	w.WriteString(userJSON)
+	GoldenResponse(t, "responses/created", w, IgnoreKeys("id", "request_id"))
+
+	empty := httptest.NewRecorder()
+	empty.WriteHeader(http.StatusNoContent)
+	GoldenResponse(t, "responses/no_content", empty)
+}
+
+func TestNormalize(t *testing.T) {
+	// Keys are visited in sorted order, so "a" is numbered before "b" even
+	// though it comes second, and equal values share a placeholder.
+	got, err := Normalize([]byte(`{
+		"b": "2026-10-16T09:15:20Z",
+		"a": "2026-10-17T00:00:00Z",
+		"c": ["2026-10-16T09:15:20Z", "not a timestamp"]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{
+  "a": "<timestamp-1>",
+  "b": "<timestamp-2>",
+  "c": [
+    "<timestamp-2>",
+    "not a timestamp"
+  ]
+}
+`
+	if string(got) != want {
+		t.Errorf("Normalize =\n%s\nwant\n%s", got, want)
+	}
+
+	if _, err := Normalize([]byte(`{"a": `)); err == nil || !strings.Contains(err.Error(), "invalid JSON") {
+		t.Errorf("Normalize of truncated JSON: err = %v", err)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	got := diff("{\n  \"a\": 1,\n  \"b\": 2\n}", "{\n  \"a\": 1,\n  \"b\": 3\n}")
+	want := "  {\n    \"a\": 1,\n-   \"b\": 2\n- }\n+   \"b\": 3\n+ }\n"
+	if got != want {
+		t.Errorf("diff =\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,156 @@
+// Package tus keeps uploads made with the tus resumable upload protocol
+// (https://tus.io): each chunk is stored as an object and the offset is
+// tracked in Postgres, so an interrupted upload can carry on from where it
+// stopped. Abandoned uploads expire in the background.
+package tus
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"pygorp/backend/internal/clock"
+	"pygorp/backend/internal/idgen"
+	"pygorp/backend/internal/jobs"
+	"pygorp/backend/internal/models"
+	"pygorp/backend/internal/repository"
+	"pygorp/backend/internal/storage"
+)
+
+// Version is the protocol version spoken, sent as Tus-Resumable.
+const Version = "1.0.0"
+
+// Extensions lists the protocol extensions supported.
+const Extensions = "creation,expiration,termination"
+
+// ExpireJob deletes uploads that have not been added to within TTL.
+const ExpireJob = "tus.expire"
+
+var (
+	// ErrOffsetMismatch is returned when a chunk does not start at the
+	// upload's current offset.
+	ErrOffsetMismatch = errors.New("Upload-Offset does not match the upload's offset")
+	// ErrTooLong is returned for chunks that go past the upload's length.
+	ErrTooLong = errors.New("the chunk goes past Upload-Length")
+)
+
+func init() {
+	jobs.Register(ExpireJob, expire)
+	jobs.Every(ExpireJob, jobs.DefaultQueue, 10*time.Minute)
+}
+
+// TTL is how long an upload is kept after it was created or last added to,
+// read from TUS_UPLOAD_TTL and defaulting to 24 hours.
+func TTL() time.Duration {
+	if d, err := time.ParseDuration(os.Getenv("TUS_UPLOAD_TTL")); err == nil && d > 0 {
+		return d
+	}
+	return 24 * time.Hour
+}
+
+// ParseMetadata decodes an Upload-Metadata header: comma-separated pairs
+// of a key and an optional base64 value.
+func ParseMetadata(header string) (map[string]string, error) {
+	metadata := map[string]string{}
+	if strings.TrimSpace(header) == "" {
+		return metadata, nil
+	}
+	for _, pair := range strings.Split(header, ",") {
+		key, encoded, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key == "" {
+			return nil, errors.New("Upload-Metadata has an empty key")
+		}
+		value, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("Upload-Metadata value for %s is not base64", key)
+		}
+		metadata[key] = string(value)
+	}
+	return metadata, nil
+}
+
+// Create records a new upload for u's target, owner, and length.
+func Create(ctx context.Context, u models.ResumableUpload) (models.ResumableUpload, error) {
+	u.ID = idgen.NewID()
+	u.ExpiresAt = clock.Now().Add(TTL())
+	return repository.CreateResumableUpload(ctx, u)
+}
+
+// Append stores the chunk in r, which must start at offset, and returns
+// the upload with its new offset. An empty chunk leaves it unchanged.
+func Append(ctx context.Context, u models.ResumableUpload, offset int64, r io.Reader) (models.ResumableUpload, error) {
+	if offset != u.Offset {
+		return u, ErrOffsetMismatch
+	}
+	data, err := io.ReadAll(io.LimitReader(r, u.Length-u.Offset+1))
+	if err != nil {
+		return u, err
+	}
+	if int64(len(data)) > u.Length-u.Offset {
+		return u, ErrTooLong
+	}
+	if len(data) == 0 {
+		return u, nil
+	}
+
+	store, err := storage.Default()
+	if err != nil {
+		return u, err
+	}
+	key := "tus/" + u.ID + "/" + idgen.NewID()
+	if err := store.Put(ctx, key, "application/octet-stream", data); err != nil {
+		return u, fmt.Errorf("failed to store chunk: %v", err)
+	}
+	updated, err := repository.AppendResumableChunk(ctx, u.ID, offset, int64(len(data)), key, clock.Now().Add(TTL()))
+	if err != nil {
+		if err := store.Delete(ctx, key); err != nil {
+			log.Printf("Failed to delete unrecorded chunk %s: %v", key, err)
+		}
+		if errors.Is(err, repository.ErrNotFound) {
+			return u, ErrOffsetMismatch
+		}
+		return u, err
+	}
+	return updated, nil
+}
+
+// Assemble joins the chunks of a fully received upload.
+func Assemble(ctx context.Context, u models.ResumableUpload) ([]byte, error) {
+	store, err := storage.Default()
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	buf.Grow(int(u.Length))
+	for _, key := range u.ChunkKeys {
+		chunk, err := store.Get(ctx, key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read chunk %s: %v", key, err)
+		}
+		buf.Write(chunk)
+	}
+	if int64(buf.Len()) != u.Length {
+		return nil, fmt.Errorf("upload %s assembled to %d bytes, want %d", u.ID, buf.Len(), u.Length)
+	}
+	return buf.Bytes(), nil
+}
+
+// expire deletes uploads past their expiry. Their chunks are queued in
+// storage_deletions, which the attachments sweep empties.
+func expire(ctx context.Context, job *jobs.Job) error {
+	n, err := repository.DeleteExpiredResumableUploads(ctx, clock.Now())
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		log.Printf("Expired %d resumable uploads", n)
+	}
+	return nil
+}
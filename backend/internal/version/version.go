@@ -0,0 +1,33 @@
+package version
+
+import "runtime"
+
+// Build information, populated at build time via -ldflags, e.g.
+//
+//	go build -ldflags "-X pygorp/backend/internal/version.Version=1.2.0"
+var (
+	Version   = "dev"
+	GitSHA    = "unknown"
+	BuildDate = "unknown"
+)
+
+type Info struct {
+	Version   string `json:"version"`
+	GitSHA    string `json:"git_sha"`
+	BuildDate string `json:"build_date"`
+	GoVersion string `json:"go_version"`
+}
+
+func Get() Info {
+	return Info{
+		Version:   Version,
+		GitSHA:    GitSHA,
+		BuildDate: BuildDate,
+		GoVersion: runtime.Version(),
+	}
+}
+
+// String identifies the build, e.g. "1.2.0 (abc1234)".
+func (i Info) String() string {
+	return i.Version + " (" + i.GitSHA + ")"
+}
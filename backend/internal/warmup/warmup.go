@@ -0,0 +1,132 @@
+// Package warmup primes a freshly started server before it reports ready:
+// it prepares the hot statements, loads recently active users so their rows
+// are in the database's buffer cache, fills the in-process caches, and sends
+// a few synthetic requests through the router. Until it finishes, /readyz
+// answers 503 so load balancers keep traffic on the old instances.
+package warmup
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"pygorp/backend/internal/env"
+	"pygorp/backend/internal/models"
+	"pygorp/backend/internal/policy"
+	"pygorp/backend/internal/repository"
+	"pygorp/backend/internal/sqlb"
+)
+
+var running atomic.Bool
+
+// Done reports whether warm-up has finished or was never started.
+func Done() bool {
+	return !running.Load()
+}
+
+// Start marks the server as warming up and runs the steps in the
+// background against handler. Failed steps are logged and skipped: a cold
+// server is better than one that never becomes ready.
+func Start(handler http.Handler) {
+	if env.String("WARMUP", "true") != "true" {
+		return
+	}
+	running.Store(true)
+	go func() {
+		defer running.Store(false)
+		ctx, cancel := context.WithTimeout(context.Background(), env.Duration("WARMUP_TIMEOUT", 30*time.Second))
+		defer cancel()
+
+		start := time.Now()
+		for _, step := range steps(handler) {
+			stepStart := time.Now()
+			detail, err := step.run(ctx)
+			if err != nil {
+				log.Printf("Warm-up step %s failed: %v", step.name, err)
+				continue
+			}
+			log.Printf("Warm-up step %s: %s (%s)", step.name, detail, time.Since(stepStart).Round(time.Millisecond))
+		}
+		log.Printf("Warm-up finished in %s", time.Since(start).Round(time.Millisecond))
+	}()
+}
+
+type step struct {
+	name string
+	run  func(ctx context.Context) (string, error)
+}
+
+func steps(handler http.Handler) []step {
+	return []step{
+		{"recent_users", recentUsers},
+		{"caches", caches},
+		{"requests", func(ctx context.Context) (string, error) { return requests(ctx, handler) }},
+	}
+}
+
+// recentUsers runs the user list, count, and lookup queries, which prepares
+// their statements, and fetches the most recently created users one by one
+// as the first requests after a deploy tend to.
+func recentUsers(ctx context.Context) (string, error) {
+	n := env.Int("WARMUP_USERS", 100)
+	users, err := repository.ListUsers(ctx, repository.UserQuery{Page: sqlb.Page{Limit: n}})
+	if err != nil {
+		return "", err
+	}
+	if _, err := repository.CountUsers(ctx, models.UserFilter{}); err != nil {
+		return "", err
+	}
+	for _, user := range users {
+		if _, err := repository.GetUser(ctx, user.ID); err != nil {
+			return "", err
+		}
+	}
+	if len(users) > 0 {
+		if _, err := repository.GetUserByEmail(ctx, users[0].Email); err != nil {
+			return "", err
+		}
+	}
+	return fmt.Sprintf("loaded %d users", len(users)), nil
+}
+
+// caches loads what request checks read on every write: policies, attribute
+// definitions, and blocked words.
+func caches(ctx context.Context) (string, error) {
+	if _, err := policy.Current(ctx); err != nil {
+		return "", fmt.Errorf("policies: %v", err)
+	}
+	if _, err := repository.AttributeRegistry(ctx); err != nil {
+		return "", fmt.Errorf("attribute definitions: %v", err)
+	}
+	if _, err := repository.Blocklist(ctx); err != nil {
+		return "", fmt.Errorf("blocked words: %v", err)
+	}
+	return "policies, attribute definitions, blocked words", nil
+}
+
+// requests sends GETs for WARMUP_PATHS through the router in-process, which
+// exercises the middleware chain without touching the network.
+func requests(ctx context.Context, handler http.Handler) (string, error) {
+	paths := strings.Split(env.String("WARMUP_PATHS", "/health,/api/v1/ping,/api/v1/version"), ",")
+	sent := 0
+	for _, path := range paths {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+		req := httptest.NewRequest(http.MethodGet, path, nil).WithContext(ctx)
+		req.Header.Set("User-Agent", "pygorp-warmup")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code >= 500 {
+			return "", fmt.Errorf("GET %s returned %d", path, w.Code)
+		}
+		sent++
+	}
+	return fmt.Sprintf("sent %d requests", sent), nil
+}
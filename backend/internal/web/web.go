@@ -0,0 +1,103 @@
+// Package web serves the compiled frontend embedded in the binary.
+package web
+
+import (
+	"bytes"
+	"embed"
+	"io/fs"
+	"log"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"pygorp/backend/internal/env"
+	"pygorp/backend/internal/render"
+
+	"github.com/gin-gonic/gin"
+)
+
+//go:embed all:dist
+var dist embed.FS
+
+// apiPrefixes are never answered with the SPA so unknown API paths still
+// return JSON 404s.
+var apiPrefixes = []string{"/api/", "/admin/", "/dev/", "/internal/", "/media/", "/previews/", "/problems/", "/scim/"}
+
+// Enabled reports whether the frontend should be served. It is off when
+// SERVE_FRONTEND=false (the frontend is hosted separately) or when the
+// binary was built without a frontend bundle.
+func Enabled() bool {
+	if env.String("SERVE_FRONTEND", "true") != "true" {
+		return false
+	}
+	if _, err := fs.Stat(dist, "dist/index.html"); err != nil {
+		log.Printf("No embedded frontend found, serving the API only")
+		return false
+	}
+	return true
+}
+
+// Handler serves files from the bundle and falls back to index.html so
+// client-side routes work on reload. Use it as the router's NoRoute handler.
+func Handler() gin.HandlerFunc {
+	files, _ := fs.Sub(dist, "dist")
+
+	return func(c *gin.Context) {
+		reqPath := c.Request.URL.Path
+		if (c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead) || isAPIPath(reqPath) {
+			render.JSON(c, http.StatusNotFound, gin.H{"error": "Not found"})
+			return
+		}
+
+		name := strings.TrimPrefix(path.Clean(reqPath), "/")
+		for _, candidate := range []string{name, name + ".html", path.Join(name, "index.html")} {
+			if serveFile(c, files, candidate) {
+				return
+			}
+		}
+
+		// Missing assets are real 404s; anything else is a client-side route.
+		if path.Ext(name) != "" {
+			render.JSON(c, http.StatusNotFound, gin.H{"error": "Not found"})
+			return
+		}
+		serveFile(c, files, "index.html")
+	}
+}
+
+func serveFile(c *gin.Context, files fs.FS, name string) bool {
+	if name == "" || name == "." {
+		return false
+	}
+	data, err := fs.ReadFile(files, name)
+	if err != nil {
+		return false
+	}
+
+	c.Header("Cache-Control", cacheControl(name))
+	http.ServeContent(c.Writer, c.Request, name, time.Time{}, bytes.NewReader(data))
+	return true
+}
+
+// cacheControl lets browsers keep fingerprinted build assets forever while
+// always revalidating HTML so new deploys are picked up.
+func cacheControl(name string) string {
+	switch {
+	case strings.HasPrefix(name, "_next/static/"), strings.HasPrefix(name, "assets/"):
+		return "public, max-age=31536000, immutable"
+	case strings.HasSuffix(name, ".html"):
+		return "no-cache"
+	default:
+		return "public, max-age=3600"
+	}
+}
+
+func isAPIPath(p string) bool {
+	for _, prefix := range apiPrefixes {
+		if strings.HasPrefix(p+"/", prefix) {
+			return true
+		}
+	}
+	return false
+}
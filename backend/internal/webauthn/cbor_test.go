@@ -0,0 +1,14 @@
+package webauthn
+
+import "testing"
+
+// FuzzCBOR decodes attestation objects and public keys as they arrive from
+// authenticators.
+func FuzzCBOR(f *testing.F) {
+	f.Fuzz(func(t *testing.T, data []byte) {
+		_, n, err := decodeCBOR(data)
+		if err == nil && (n < 1 || n > len(data)) {
+			t.Fatalf("decoded %d bytes of %d", n, len(data))
+		}
+	})
+}
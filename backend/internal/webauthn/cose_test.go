@@ -0,0 +1,15 @@
+package webauthn
+
+import "testing"
+
+// FuzzCOSEKey parses credential public keys and verifies a signature with
+// any key that parses.
+func FuzzCOSEKey(f *testing.F) {
+	f.Fuzz(func(t *testing.T, raw []byte) {
+		key, err := parseCOSEKey(raw)
+		if err != nil {
+			return
+		}
+		key.verify([]byte("data"), []byte("signature"))
+	})
+}
@@ -0,0 +1,205 @@
+package webauthn
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"time"
+
+	"pygorp/backend/internal/clock"
+	"pygorp/backend/internal/database"
+
+	"github.com/lib/pq"
+)
+
+// ChallengeTTL is how long a ceremony may take. Challenges are single-use.
+const ChallengeTTL = 5 * time.Minute
+
+// Ceremony kinds.
+const (
+	KindRegister = "register"
+	KindLogin    = "login"
+)
+
+var (
+	ErrChallenge        = errors.New("challenge is invalid or has expired")
+	ErrNotFound         = errors.New("credential not found")
+	ErrCredentialExists = errors.New("credential is already registered")
+)
+
+const credentialColumns = "id, user_id, user_handle, public_key, sign_count, transports, aaguid, name, created_at, last_used_at"
+
+func init() {
+	database.UseColumns("webauthn_challenges", "id, kind, challenge, user_id, email, name, user_handle, expires_at")
+	database.UseColumns("webauthn_credentials", credentialColumns)
+}
+
+// Challenge is a pending ceremony. For registrations of new accounts UserID
+// is nil and Email and Name describe the account to create.
+type Challenge struct {
+	ID         string
+	Kind       string
+	Challenge  []byte
+	UserID     *int
+	Email      string
+	Name       string
+	UserHandle []byte
+}
+
+// StoredCredential is a registered passkey.
+type StoredCredential struct {
+	ID         Bytes      `json:"id"`
+	UserID     int        `json:"user_id"`
+	UserHandle []byte     `json:"-"`
+	PublicKey  []byte     `json:"-"`
+	SignCount  uint32     `json:"-"`
+	Transports []string   `json:"transports"`
+	AAGUID     Bytes      `json:"aaguid"`
+	Name       string     `json:"name"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+}
+
+// Descriptor returns the credential as listed in ceremony options.
+func (c *StoredCredential) Descriptor() CredentialDescriptor {
+	return CredentialDescriptor{Type: "public-key", ID: c.ID, Transports: c.Transports}
+}
+
+// NewChallenge stores a ceremony with a fresh random challenge and ID.
+func NewChallenge(ctx context.Context, ch Challenge) (*Challenge, error) {
+	ch.ID, ch.Challenge = base64.RawURLEncoding.EncodeToString(random(16)), random(32)
+
+	// Expired challenges are only useful to attackers; sweep them here.
+	now := clock.Now()
+	if _, err := database.DB.ExecContext(ctx, "DELETE FROM webauthn_challenges WHERE expires_at < $1", now); err != nil {
+		return nil, err
+	}
+	_, err := database.DB.ExecContext(ctx, `
+		INSERT INTO webauthn_challenges (id, kind, challenge, user_id, email, name, user_handle, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		ch.ID, ch.Kind, ch.Challenge, ch.UserID, ch.Email, ch.Name, ch.UserHandle, now.Add(ChallengeTTL))
+	if err != nil {
+		return nil, err
+	}
+	return &ch, nil
+}
+
+// ConsumeChallenge removes and returns a pending ceremony of kind.
+func ConsumeChallenge(ctx context.Context, id, kind string) (*Challenge, error) {
+	ch := Challenge{ID: id, Kind: kind}
+	var userID sql.NullInt64
+	var expiresAt time.Time
+	err := database.DB.QueryRowContext(ctx, `
+		DELETE FROM webauthn_challenges WHERE id = $1 AND kind = $2
+		RETURNING challenge, user_id, email, name, user_handle, expires_at`, id, kind).
+		Scan(&ch.Challenge, &userID, &ch.Email, &ch.Name, &ch.UserHandle, &expiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrChallenge
+	}
+	if err != nil {
+		return nil, err
+	}
+	if clock.Now().After(expiresAt) {
+		return nil, ErrChallenge
+	}
+	if userID.Valid {
+		id := int(userID.Int64)
+		ch.UserID = &id
+	}
+	return &ch, nil
+}
+
+func scanCredential(row interface{ Scan(...interface{}) error }) (*StoredCredential, error) {
+	var c StoredCredential
+	var signCount int64
+	err := row.Scan((*[]byte)(&c.ID), &c.UserID, &c.UserHandle, &c.PublicKey, &signCount,
+		pq.Array(&c.Transports), (*[]byte)(&c.AAGUID), &c.Name, &c.CreatedAt, &c.LastUsedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	c.SignCount = uint32(signCount)
+	return &c, err
+}
+
+// SaveCredential stores a verified credential for a user.
+func SaveCredential(ctx context.Context, userID int, userHandle []byte, cred *Credential, transports []string, name string) (*StoredCredential, error) {
+	if transports == nil {
+		transports = []string{}
+	}
+	c, err := scanCredential(database.DB.QueryRowContext(ctx, `
+		INSERT INTO webauthn_credentials (id, user_id, user_handle, public_key, sign_count, transports, aaguid, name)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING `+credentialColumns,
+		cred.ID, userID, userHandle, cred.PublicKey, int64(cred.SignCount), pq.Array(transports), cred.AAGUID, name))
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return nil, ErrCredentialExists
+	}
+	return c, err
+}
+
+// GetCredential looks a credential up by its ID.
+func GetCredential(ctx context.Context, id []byte) (*StoredCredential, error) {
+	return scanCredential(database.DB.QueryRowContext(ctx, "SELECT "+credentialColumns+" FROM webauthn_credentials WHERE id = $1", id))
+}
+
+// UserCredentials returns a user's credentials, oldest first.
+func UserCredentials(ctx context.Context, userID int) ([]*StoredCredential, error) {
+	rows, err := database.DB.QueryContext(ctx, "SELECT "+credentialColumns+" FROM webauthn_credentials WHERE user_id = $1 ORDER BY created_at", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	creds := []*StoredCredential{}
+	for rows.Next() {
+		c, err := scanCredential(rows)
+		if err != nil {
+			return nil, err
+		}
+		creds = append(creds, c)
+	}
+	return creds, rows.Err()
+}
+
+// UserHandle returns the handle authenticators know a user by, creating one
+// for a user without credentials. Every credential of a user shares it, so
+// authenticators replace rather than duplicate a user's passkey.
+func UserHandle(ctx context.Context, userID *int) ([]byte, error) {
+	if userID != nil {
+		var handle []byte
+		err := database.DB.QueryRowContext(ctx, "SELECT user_handle FROM webauthn_credentials WHERE user_id = $1 LIMIT 1", *userID).Scan(&handle)
+		if err == nil {
+			return handle, nil
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+	}
+	return random(32), nil
+}
+
+// RecordUse stores the new sign count after a successful sign-in.
+func RecordUse(ctx context.Context, id []byte, signCount uint32) error {
+	_, err := database.DB.ExecContext(ctx, "UPDATE webauthn_credentials SET sign_count = $1, last_used_at = $3 WHERE id = $2", int64(signCount), id, clock.Now())
+	return err
+}
+
+// DeleteCredential removes one of a user's credentials.
+func DeleteCredential(ctx context.Context, userID int, id []byte) error {
+	result, err := database.DB.ExecContext(ctx, "DELETE FROM webauthn_credentials WHERE id = $1 AND user_id = $2", id, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func random(n int) []byte {
+	b := make([]byte, n)
+	rand.Read(b)
+	return b
+}
@@ -0,0 +1,228 @@
+// Package webauthn registers passkeys and verifies sign-ins with them
+// (W3C Web Authentication, level 2). Attestation statements are not
+// verified: registration asks for "none", which is what passkeys provide.
+package webauthn
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"pygorp/backend/internal/env"
+)
+
+// Authenticator data flags.
+const (
+	flagUserPresent = 0x01
+	flagAttested    = 0x40
+)
+
+// Config identifies the relying party. Origins are the web origins allowed
+// to perform ceremonies, e.g. https://app.example.com.
+type Config struct {
+	RPID    string
+	RPName  string
+	Origins []string
+}
+
+// ConfigFromEnv reads WEBAUTHN_RP_ID, WEBAUTHN_RP_NAME, and WEBAUTHN_ORIGINS.
+func ConfigFromEnv() Config {
+	cfg := Config{
+		RPID:   env.String("WEBAUTHN_RP_ID", "localhost"),
+		RPName: env.String("WEBAUTHN_RP_NAME", env.String("APP_NAME", "PyGoRP")),
+	}
+	for _, origin := range strings.Split(env.String("WEBAUTHN_ORIGINS", "http://localhost:3000"), ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			cfg.Origins = append(cfg.Origins, origin)
+		}
+	}
+	return cfg
+}
+
+// Bytes is binary data encoded as unpadded base64url in JSON, the encoding
+// browser WebAuthn helpers use.
+type Bytes []byte
+
+func (b Bytes) MarshalJSON() ([]byte, error) {
+	return json.Marshal(base64.RawURLEncoding.EncodeToString(b))
+}
+
+func (b *Bytes) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	// Accept padded and standard base64 from clients that send it.
+	s = strings.TrimRight(strings.NewReplacer("+", "-", "/", "_").Replace(s), "=")
+	decoded, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return fmt.Errorf("invalid base64url: %v", err)
+	}
+	*b = decoded
+	return nil
+}
+
+// Credential is a verified new credential.
+type Credential struct {
+	ID        []byte
+	PublicKey []byte
+	SignCount uint32
+	AAGUID    []byte
+}
+
+// AttestationResponse is the response of navigator.credentials.create().
+type AttestationResponse struct {
+	ClientDataJSON    Bytes    `json:"clientDataJSON" binding:"required"`
+	AttestationObject Bytes    `json:"attestationObject" binding:"required"`
+	Transports        []string `json:"transports"`
+}
+
+// AssertionResponse is the response of navigator.credentials.get().
+type AssertionResponse struct {
+	ClientDataJSON    Bytes `json:"clientDataJSON" binding:"required"`
+	AuthenticatorData Bytes `json:"authenticatorData" binding:"required"`
+	Signature         Bytes `json:"signature" binding:"required"`
+	UserHandle        Bytes `json:"userHandle"`
+}
+
+type clientData struct {
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+	Origin    string `json:"origin"`
+}
+
+type authenticatorData struct {
+	rpIDHash  []byte
+	flags     byte
+	signCount uint32
+	aaguid    []byte
+	credID    []byte
+	publicKey []byte
+}
+
+// VerifyRegistration checks an attestation for challenge and returns the
+// new credential.
+func (cfg Config) VerifyRegistration(challenge []byte, resp AttestationResponse) (*Credential, error) {
+	if err := cfg.checkClientData(resp.ClientDataJSON, "webauthn.create", challenge); err != nil {
+		return nil, err
+	}
+
+	v, _, err := decodeCBOR(resp.AttestationObject)
+	if err != nil {
+		return nil, fmt.Errorf("invalid attestation object: %v", err)
+	}
+	obj, ok := v.(map[interface{}]interface{})
+	if !ok {
+		return nil, errors.New("invalid attestation object")
+	}
+	rawAuthData, ok := obj["authData"].([]byte)
+	if !ok {
+		return nil, errors.New("attestation object has no authData")
+	}
+
+	ad, err := cfg.parseAuthData(rawAuthData)
+	if err != nil {
+		return nil, err
+	}
+	if ad.flags&flagAttested == 0 || ad.publicKey == nil {
+		return nil, errors.New("authenticator data has no credential")
+	}
+	if _, err := parseCOSEKey(ad.publicKey); err != nil {
+		return nil, err
+	}
+	return &Credential{ID: ad.credID, PublicKey: ad.publicKey, SignCount: ad.signCount, AAGUID: ad.aaguid}, nil
+}
+
+// VerifyAssertion checks a sign-in for challenge against a stored public key
+// and sign count, and returns the new sign count. A count that does not
+// increase means the authenticator may have been cloned.
+func (cfg Config) VerifyAssertion(challenge, storedKey []byte, storedCount uint32, resp AssertionResponse) (uint32, error) {
+	if err := cfg.checkClientData(resp.ClientDataJSON, "webauthn.get", challenge); err != nil {
+		return 0, err
+	}
+	ad, err := cfg.parseAuthData(resp.AuthenticatorData)
+	if err != nil {
+		return 0, err
+	}
+
+	key, err := parseCOSEKey(storedKey)
+	if err != nil {
+		return 0, err
+	}
+	clientHash := sha256.Sum256(resp.ClientDataJSON)
+	signed := append(append([]byte(nil), resp.AuthenticatorData...), clientHash[:]...)
+	if !key.verify(signed, resp.Signature) {
+		return 0, errors.New("invalid signature")
+	}
+
+	if (ad.signCount != 0 || storedCount != 0) && ad.signCount <= storedCount {
+		return 0, errors.New("sign count did not increase; the authenticator may be cloned")
+	}
+	return ad.signCount, nil
+}
+
+func (cfg Config) checkClientData(raw []byte, wantType string, challenge []byte) error {
+	var cd clientData
+	if err := json.Unmarshal(raw, &cd); err != nil {
+		return fmt.Errorf("invalid client data: %v", err)
+	}
+	if cd.Type != wantType {
+		return fmt.Errorf("client data type is %q, want %q", cd.Type, wantType)
+	}
+	got, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(cd.Challenge, "="))
+	if err != nil || subtle.ConstantTimeCompare(got, challenge) != 1 {
+		return errors.New("challenge does not match")
+	}
+	for _, origin := range cfg.Origins {
+		if cd.Origin == origin {
+			return nil
+		}
+	}
+	return fmt.Errorf("origin %q is not allowed", cd.Origin)
+}
+
+// parseAuthData parses authenticator data and checks the relying party ID
+// hash and the user-present flag.
+func (cfg Config) parseAuthData(data []byte) (*authenticatorData, error) {
+	if len(data) < 37 {
+		return nil, errors.New("authenticator data is too short")
+	}
+	ad := &authenticatorData{
+		rpIDHash:  data[:32],
+		flags:     data[32],
+		signCount: binary.BigEndian.Uint32(data[33:37]),
+	}
+	rpIDHash := sha256.Sum256([]byte(cfg.RPID))
+	if !bytes.Equal(ad.rpIDHash, rpIDHash[:]) {
+		return nil, errors.New("relying party ID does not match")
+	}
+	if ad.flags&flagUserPresent == 0 {
+		return nil, errors.New("user was not present")
+	}
+
+	if ad.flags&flagAttested != 0 {
+		rest := data[37:]
+		if len(rest) < 18 {
+			return nil, errors.New("attested credential data is too short")
+		}
+		ad.aaguid = rest[:16]
+		idLen := int(binary.BigEndian.Uint16(rest[16:18]))
+		rest = rest[18:]
+		if len(rest) < idLen {
+			return nil, errors.New("credential ID is truncated")
+		}
+		ad.credID, rest = rest[:idLen], rest[idLen:]
+		_, n, err := decodeCBOR(rest)
+		if err != nil {
+			return nil, fmt.Errorf("invalid credential public key: %v", err)
+		}
+		ad.publicKey = rest[:n]
+	}
+	return ad, nil
+}
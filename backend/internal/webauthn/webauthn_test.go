@@ -0,0 +1,23 @@
+package webauthn
+
+import (
+	"crypto/sha256"
+	"testing"
+)
+
+// FuzzAuthData parses authenticator data following a valid relying party
+// ID hash, so the fuzzer reaches the flags and attested credential data.
+func FuzzAuthData(f *testing.F) {
+	cfg := Config{RPID: "localhost"}
+	hash := sha256.Sum256([]byte(cfg.RPID))
+	f.Fuzz(func(t *testing.T, rest []byte) {
+		data := append(hash[:len(hash):len(hash)], rest...)
+		ad, err := cfg.parseAuthData(data)
+		if err != nil {
+			return
+		}
+		if len(ad.credID)+len(ad.publicKey) > len(data) {
+			t.Fatalf("parsed more than %d bytes", len(data))
+		}
+	})
+}
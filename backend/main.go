@@ -1,72 +1,7 @@
 package main
 
-import (
-	"log"
-	"net/http"
-	"os"
-
-	"pygorp/backend/internal/database"
-	"pygorp/backend/internal/handlers"
-
-	"github.com/gin-gonic/gin"
-	"github.com/gin-contrib/cors"
-)
+import "pygorp/backend/cmd"
 
 func main() {
-	// Set Gin mode
-	gin.SetMode(gin.ReleaseMode)
-	if os.Getenv("GIN_MODE") == "debug" {
-		gin.SetMode(gin.DebugMode)
-	}
-
-	// Initialize database
-	if err := database.InitDB(); err != nil {
-		log.Fatal("Failed to connect to database:", err)
-	}
-	defer database.CloseDB()
-
-	// Initialize Gin router
-	r := gin.Default()
-
-	// Configure CORS
-	config := cors.DefaultConfig()
-	config.AllowOrigins = []string{"http://localhost:3000", "http://localhost:3001"}
-	config.AllowMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
-	config.AllowHeaders = []string{"Origin", "Content-Type", "Authorization"}
-	r.Use(cors.New(config))
-
-	// Health check endpoint
-	r.GET("/health", func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{
-			"status": "healthy",
-			"service": "pygorp-backend",
-		})
-	})
-
-	// API routes
-	api := r.Group("/api/v1")
-	{
-		api.GET("/ping", func(c *gin.Context) {
-			c.JSON(http.StatusOK, gin.H{"message": "pong"})
-		})
-
-		// User routes
-		users := api.Group("/users")
-		{
-			users.GET("", handlers.GetUsers)
-			users.GET("/:id", handlers.GetUser)
-			users.POST("", handlers.CreateUser)
-			users.PUT("/:id", handlers.UpdateUser)
-			users.DELETE("/:id", handlers.DeleteUser)
-		}
-	}
-
-	// Get port from environment or default to 8080
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
-	}
-
-	log.Printf("Starting PyGoRP Backend server on port %s", port)
-	log.Fatal(r.Run(":" + port))
+	cmd.Execute()
 }
@@ -1,7 +1,8 @@
 import type { NextConfig } from "next";
 
 const nextConfig: NextConfig = {
-  output: 'standalone',
+  // NEXT_OUTPUT=export builds a static bundle the backend can embed.
+  output: process.env.NEXT_OUTPUT === 'export' ? 'export' : 'standalone',
 };
 
 export default nextConfig;